@@ -2,6 +2,7 @@
 package address
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/kklash/bitcoinlib/base58check"
@@ -42,6 +43,8 @@ func Make(addressFormat constants.AddressFormat, data []byte) (string, error) {
 		return MakeP2WPKHFromPublicKey(data)
 	case constants.FormatP2WSH:
 		return MakeP2WSHFromScript(data)
+	case constants.FormatP2TR:
+		return MakeP2TR(data, nil)
 	default:
 		return "", ErrInvalidAddressFormat
 	}
@@ -49,7 +52,8 @@ func Make(addressFormat constants.AddressFormat, data []byte) (string, error) {
 
 // MakeFromHash creates an address of the given addressFormat using a public-key or script-hash.
 // For P2SH, P2PKH and P2WPKH address formats, hashed must be length 20. For P2WSH,
-// hashed must be length 32. Returns ErrInvalidHash if the slice length does not match.
+// hashed must be length 32. For P2TR, hashed is the 32-byte tweaked output public key.
+// Returns ErrInvalidHash if the slice length does not match.
 //
 //	 import (
 //		  "github.com/kklash/bitcoinlib/address"
@@ -74,7 +78,7 @@ func MakeFromHash(addressFormat constants.AddressFormat, hashed []byte) (string,
 	}
 
 	var desiredHashLength int
-	if addressFormat == constants.FormatP2WSH {
+	if addressFormat == constants.FormatP2WSH || addressFormat == constants.FormatP2TR {
 		desiredHashLength = 32
 	} else {
 		desiredHashLength = 20
@@ -100,6 +104,8 @@ func MakeFromHash(addressFormat constants.AddressFormat, hashed []byte) (string,
 		var h [32]byte
 		copy(h[:], hashed)
 		return MakeP2WSHFromHash(h)
+	case constants.FormatP2TR:
+		return MakeP2TRFromOutputKey(hashed)
 	default:
 		return "", ErrInvalidAddressFormat
 	}
@@ -134,10 +140,20 @@ func DecodeBase58Address(address string) (uint16, [20]byte, error) {
 }
 
 // DecodeBech32Address returns the prefix, version, and hash
-// contained within a bech32 encoded address. Returns
-// ErrInvalidAddress if the payload is not of the expected length.
+// contained within a bech32 or bech32m encoded address. Returns
+// ErrInvalidAddress if the payload is not of the expected length,
+// or if the address is not encoded with the checksum BIP350
+// requires for its witness version.
 func DecodeBech32Address(address string) (hrp string, version byte, payload []byte, err error) {
 	hrp, version, payload, err = bech32.Decode(address)
+	if errors.Is(err, bech32.ErrInvalidBech32Checksum) {
+		hrp, version, payload, err = bech32.DecodeM(address)
+		if err == nil && version == constants.WitnessVersionZero {
+			err = fmt.Errorf("%w: witness version zero must use bech32 encoding", ErrInvalidAddress)
+		}
+	} else if err == nil && version != constants.WitnessVersionZero {
+		err = fmt.Errorf("%w: witness version %d must use bech32m encoding", ErrInvalidAddress, version)
+	}
 	if err != nil {
 		return
 	}
@@ -173,13 +189,22 @@ func Decode(address string) (constants.AddressFormat, []byte, error) {
 		err = fmt.Errorf("%w: failed to decode address as base58 or bech32", ErrInvalidAddress)
 	} else if hrp != constants.CurrentNetwork.Bech32 {
 		err = fmt.Errorf("%w: unexpected bech32 prefix '%s'", ErrInvalidAddress, hrp)
-	} else if witnessVersion != constants.WitnessVersionZero {
+	} else if witnessVersion != constants.WitnessVersionZero && witnessVersion != constants.WitnessVersionOne {
 		err = fmt.Errorf("%w: unexpected witness version '0x%.2X'", ErrInvalidAddress, witnessVersion)
 	}
 	if err != nil {
 		return constants.FormatNONSTANDARD, nil, err
 	}
 
+	if witnessVersion == constants.WitnessVersionOne {
+		if len(witnessProgram) != constants.PublicKeySchnorrLength {
+			err := fmt.Errorf("%w: unexpected taproot witness program length %d", ErrInvalidAddress, len(witnessProgram))
+			return constants.FormatNONSTANDARD, nil, err
+		}
+		scriptPubKey, _ := script.MakeP2TRFromOutputKey(witnessProgram)
+		return constants.FormatP2TR, scriptPubKey, nil
+	}
+
 	switch len(witnessProgram) {
 	case 20:
 		var keyHash [20]byte
@@ -3,6 +3,7 @@ package address
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"testing"
@@ -66,6 +67,14 @@ func TestMakeAndDecode(t *testing.T) {
 			address:      "bc1qwykqv3jz9fhzckamfar57hrfp55pze7kyl59tt4v9x3nmgvrjxgs5xwjnw",
 			scriptPubKey: hex2bytes("0020712c0646422a6e2c5bbb4f474f5c690d281167d627e855aeac29a33da1839191"),
 		},
+		Fixture{
+			input:        hex2bytes("d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d"),
+			hash:         hex2bytes("53a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343"),
+			format:       constants.FormatP2TR,
+			network:      constants.BitcoinNetwork,
+			address:      "bc1p2wsldez5mud2yam29q22wgfh9439spgduvct83k3pm50fcxa5dps59h4z5",
+			scriptPubKey: hex2bytes("512053a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343"),
+		},
 	}
 
 	for _, fixture := range fixtures {
@@ -224,6 +233,12 @@ func TestDecodeBech32Address(t *testing.T) {
 			version: 0,
 			payload: hex2bytes("72302c30ce6e7a70077aaea4e408b85605cbea38"),
 		},
+		{
+			address: "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0",
+			hrp:     "bc",
+			version: 1,
+			payload: hex2bytes("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"),
+		},
 		{
 			address: "ltc1q5pg8p84y84af2vtpe0lcxamem3yqjnac9qdxrl",
 			hrp:     "ltc",
@@ -255,3 +270,17 @@ func TestDecodeBech32Address(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeBech32Address_WrongEncoding(t *testing.T) {
+	// From BIP350 invalid address test vectors.
+	invalidAddresses := []string{
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh",                                 // witness v0 with bech32m checksum
+		"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7k7grplx", // witness v1 with bech32 checksum
+	}
+
+	for _, addr := range invalidAddresses {
+		if _, _, _, err := DecodeBech32Address(addr); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("expected ErrInvalidAddress when decoding %s; got %v", addr, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package address
+
+import (
+	"github.com/kklash/bitcoinlib/bech32"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/taproot"
+)
+
+// MakeP2TR creates a bech32m-encoded P2TR taproot address using the given 32-byte
+// internal public key and optional MAST script tree. If scriptTree is nil, the
+// address can only be spent using a signature by the internal key.
+func MakeP2TR(internalPublicKey []byte, scriptTree script.Hasher) (string, error) {
+	if len(internalPublicKey) != constants.PublicKeySchnorrLength {
+		return "", ErrInvalidPublicKeyLength
+	}
+
+	var h []byte
+	if scriptTree != nil {
+		scriptTreeHash := scriptTree.Hash()
+		h = scriptTreeHash[:]
+	}
+
+	outputPublicKey, _, err := taproot.TweakPublicKey(internalPublicKey, h)
+	if err != nil {
+		return "", err
+	}
+
+	return MakeP2TRFromOutputKey(outputPublicKey)
+}
+
+// MakeP2TRFromOutputKey creates a bech32m-encoded P2TR taproot address using
+// the given 32-byte output public key, which has already been tweaked.
+func MakeP2TRFromOutputKey(outputPublicKey []byte) (string, error) {
+	if len(constants.CurrentNetwork.Bech32) == 0 {
+		return "", ErrNoSegwitSupport
+	} else if len(outputPublicKey) != constants.PublicKeySchnorrLength {
+		return "", ErrInvalidPublicKeyLength
+	}
+
+	address, err := bech32.EncodeM(
+		constants.CurrentNetwork.Bech32,
+		constants.WitnessVersionOne,
+		outputPublicKey,
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	return address, nil
+}
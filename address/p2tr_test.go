@@ -0,0 +1,45 @@
+package address
+
+import (
+	"testing"
+
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/script"
+)
+
+func TestMakeP2TR(t *testing.T) {
+	type Fixture struct {
+		internalPublicKey []byte
+		scriptTree        script.Hasher
+		address           string
+	}
+
+	// From BIP341 test vectors.
+	fixtures := []Fixture{
+		{
+			internalPublicKey: hex2bytes("d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d"),
+			scriptTree:        nil,
+			address:           "bc1p2wsldez5mud2yam29q22wgfh9439spgduvct83k3pm50fcxa5dps59h4z5",
+		},
+		{
+			internalPublicKey: hex2bytes("187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27"),
+			scriptTree: &script.MastLeaf{
+				Version: constants.TaprootLeafVersionTapscript,
+				Script:  hex2bytes("20d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8ac"),
+			},
+			address: "bc1pz37fc4cn9ah8anwm4xqqhvxygjf9rjf2resrw8h8w4tmvcs0863sa2e586",
+		},
+	}
+
+	for _, fixture := range fixtures {
+		addr, err := MakeP2TR(fixture.internalPublicKey, fixture.scriptTree)
+		if err != nil {
+			t.Errorf("failed to make P2TR address: %s", err)
+			continue
+		}
+
+		if addr != fixture.address {
+			t.Errorf("P2TR address does not match fixture\nwanted %s\ngot %s", fixture.address, addr)
+		}
+	}
+}
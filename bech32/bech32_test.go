@@ -67,3 +67,75 @@ func TestBech32(t *testing.T) {
 		"tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy",
 	)
 }
+
+func TestBech32m(t *testing.T) {
+	type Fixture struct {
+		hrp     string
+		version byte
+		data    []byte
+		encoded string
+	}
+
+	// These fixtures pulled from BIP350
+	fixtures := []Fixture{
+		{
+			hrp:     "bc",
+			version: 1,
+			data:    hex2bytes("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"),
+			encoded: "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0",
+		},
+		{
+			hrp:     "bc",
+			version: 16,
+			data:    hex2bytes("751e"),
+			encoded: "bc1sw50qgdz25j",
+		},
+		{
+			hrp:     "bc",
+			version: 2,
+			data:    hex2bytes("751e76e8199196d454941c45d1b3a323"),
+			encoded: "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs",
+		},
+		{
+			hrp:     "tb",
+			version: 1,
+			data:    hex2bytes("000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"),
+			encoded: "tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c",
+		},
+	}
+
+	for _, fixture := range fixtures {
+		encoded, err := EncodeM(fixture.hrp, fixture.version, fixture.data)
+		if err != nil {
+			t.Errorf("failed to encode bech32m string: %s", err)
+			return
+		} else if encoded != fixture.encoded {
+			t.Errorf("Bech32m encoded output did not match\nWanted %s\nGot    %s", fixture.encoded, encoded)
+			return
+		}
+
+		hrp, version, decoded, err := DecodeM(fixture.encoded)
+		if err != nil {
+			t.Errorf("failed to decode bech32m string: %s", err)
+			return
+		} else if hrp != fixture.hrp {
+			t.Errorf("Bech32m decoded HRP does not match\nWanted %s\nGot    %s", fixture.hrp, hrp)
+			return
+		} else if version != fixture.version {
+			t.Errorf("Bech32m decoded version byte does not match\nWanted %d\nGot    %d", fixture.version, version)
+			return
+		} else if !bytes.Equal(decoded, fixture.data) {
+			t.Errorf("Bech32m decoded bytes do not match\nWanted %x\nGot    %x", fixture.data, decoded)
+			return
+		}
+
+		if _, _, _, err := Decode(fixture.encoded); err != ErrInvalidBech32Checksum {
+			t.Errorf("expected bech32m string to fail bech32 checksum validation; got %v", err)
+			return
+		}
+	}
+
+	if _, _, _, err := DecodeM("bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"); err != ErrInvalidBech32Checksum {
+		t.Errorf("expected bech32 string to fail bech32m checksum validation; got %v", err)
+	}
+}
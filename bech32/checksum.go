@@ -49,12 +49,12 @@ func bech32Polymod(values []uint5) int {
 
 // def bech32_create_checksum(hrp, data):
 //   values = bech32_hrp_expand(hrp) + data
-//   polymod = bech32_polymod(values + [0,0,0,0,0,0]) ^ 1
+//   polymod = bech32_polymod(values + [0,0,0,0,0,0]) ^ const
 //   return [(polymod >> 5 * (5 - i)) & 31 for i in range(6)]
 
-func bech32CreateChecksum(hrp string, values []uint5) []uint5 {
+func bech32CreateChecksum(hrp string, values []uint5, checksumConst int) []uint5 {
 	values = append(bech32HrpExpand(hrp), values...)
-	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ 1
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ checksumConst
 
 	checksum := make([]uint5, 6)
 	for i := 0; i < len(checksum); i++ {
@@ -65,9 +65,9 @@ func bech32CreateChecksum(hrp string, values []uint5) []uint5 {
 }
 
 // def bech32_verify_checksum(hrp, data):
-//   return bech32_polymod(bech32_hrp_expand(hrp) + data) == 1
+//   return bech32_polymod(bech32_hrp_expand(hrp) + data) == const
 
-func bech32VerifyChecksum(hrp string, values []uint5) bool {
+func bech32VerifyChecksum(hrp string, values []uint5, checksumConst int) bool {
 	values = append(bech32HrpExpand(hrp), values...)
-	return bech32Polymod(values) == 1
+	return bech32Polymod(values) == checksumConst
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/kklash/bitcoinlib/constants"
 	"github.com/kklash/bits"
 )
 
@@ -82,7 +83,7 @@ func separateBechAndHrp(bechAndHrp string) (hrp, bech string) {
 	return
 }
 
-func bechToBitGroups(hrp, bech string) ([]bits.Bits, error) {
+func bechToBitGroups(hrp, bech string, checksumConst int) ([]bits.Bits, error) {
 	bitGroups := make([]bits.Bits, len(bech))
 	indices := make([]uint5, len(bech))
 	for i, c := range []byte(bech) {
@@ -92,7 +93,7 @@ func bechToBitGroups(hrp, bech string) ([]bits.Bits, error) {
 	}
 
 	// validate the checksum
-	if !bech32VerifyChecksum(hrp, indices) {
+	if !bech32VerifyChecksum(hrp, indices, checksumConst) {
 		return nil, ErrInvalidBech32Checksum
 	}
 
@@ -103,6 +104,17 @@ func bechToBitGroups(hrp, bech string) ([]bits.Bits, error) {
 // decode it. Returns an error wrapping ErrInvalidBech32 if the given string is not
 // valid bech32. Returns the human readable prefix, version number, and payload.
 func Decode(bechAndHrp string) (hrp string, version byte, data []byte, err error) {
+	return decode(bechAndHrp, constants.Bech32ChecksumConst)
+}
+
+// DecodeM decodes a bech32m string as specified in BIP350. It behaves identically to
+// Decode, except that the checksum is verified using the bech32m constant. Returns
+// ErrInvalidBech32Checksum if given a string with a bech32 (non-bech32m) checksum.
+func DecodeM(bechAndHrp string) (hrp string, version byte, data []byte, err error) {
+	return decode(bechAndHrp, constants.Bech32mChecksumConst)
+}
+
+func decode(bechAndHrp string, checksumConst int) (hrp string, version byte, data []byte, err error) {
 	if err = Validate(bechAndHrp); err != nil {
 		return
 	}
@@ -110,7 +122,7 @@ func Decode(bechAndHrp string) (hrp string, version byte, data []byte, err error
 	bechAndHrp = strings.ToLower(bechAndHrp)
 
 	hrp, bech := separateBechAndHrp(bechAndHrp)
-	bitGroups, err := bechToBitGroups(hrp, bech)
+	bitGroups, err := bechToBitGroups(hrp, bech, checksumConst)
 	if err != nil {
 		return
 	}
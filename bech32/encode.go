@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/kklash/bitcoinlib/constants"
 	"github.com/kklash/bits"
 )
 
@@ -15,9 +16,10 @@ var (
 	ErrInvalidVersion = fmt.Errorf("Cannot encode version byte higher than %d", len(Alphabet)-1)
 )
 
-// encodeValues converts an hrp and a slice of alphabet indeces to a bech32 string.
-func encodeValues(hrp string, values []uint5) string {
-	values = append(values, bech32CreateChecksum(hrp, values)...)
+// encodeValues converts an hrp and a slice of alphabet indeces to a bech32 string,
+// using the given checksum constant to differentiate bech32 from bech32m.
+func encodeValues(hrp string, values []uint5, checksumConst int) string {
+	values = append(values, bech32CreateChecksum(hrp, values, checksumConst)...)
 
 	bech32 := hrp + Separator
 	for i := 0; i < len(values); i++ {
@@ -51,6 +53,17 @@ func bytesToIndeces(data []byte) []uint5 {
 // the given human readable prefix, separator, version byte, payload data
 // and checksum in base32.
 func Encode(hrp string, version byte, data []byte) (string, error) {
+	return encode(hrp, version, data, constants.Bech32ChecksumConst)
+}
+
+// EncodeM encodes the given data as a bech32m string as specified in BIP350. It is identical
+// to Encode, except for the constant used to compute the checksum. Segwit addresses with
+// witness version 1 or higher should be encoded using EncodeM.
+func EncodeM(hrp string, version byte, data []byte) (string, error) {
+	return encode(hrp, version, data, constants.Bech32mChecksumConst)
+}
+
+func encode(hrp string, version byte, data []byte, checksumConst int) (string, error) {
 	var err error
 	if data == nil || len(data) == 0 {
 		err = ErrInvalidPayload
@@ -64,5 +77,5 @@ func Encode(hrp string, version byte, data []byte) (string, error) {
 
 	values := bytesToIndeces(data)
 	values = append([]uint5{uint5(version)}, values...)
-	return encodeValues(hrp, values), nil
+	return encodeValues(hrp, values, checksumConst), nil
 }
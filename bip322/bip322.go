@@ -0,0 +1,116 @@
+// Package bip322 provides signing and verification of generic signed messages as specified
+// in BIP322, which allow the owner of any address, including P2WSH and P2TR addresses,
+// to prove control of it. Signatures are made by signing a virtual transaction which spends
+// a virtual output locked by the address.
+package bip322
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kklash/bitcoinlib/bhash"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+// Format selects the encoding of a BIP322 signature.
+type Format int
+
+const (
+	// FormatSimple signatures encode only the witness of the virtual to_sign transaction.
+	// They can only be used with native segwit addresses.
+	FormatSimple Format = iota
+
+	// FormatFull signatures encode the entire virtual to_sign transaction.
+	// They can be used with any address.
+	FormatFull
+)
+
+// MessageTag is the BIP340 tag used to hash messages.
+const MessageTag = "BIP0322-signed-message"
+
+var (
+	// ErrInvalidSignature is returned when verifying a signature which cannot be decoded,
+	// or whose to_sign transaction is not structured as required by BIP322.
+	ErrInvalidSignature = errors.New("BIP322 signature is not formatted correctly")
+
+	// ErrUnsupportedAddressFormat is returned when signing for an address format which
+	// cannot be signed for with a single private key, or in the requested Format.
+	ErrUnsupportedAddressFormat = errors.New("address format is not supported for BIP322 signing")
+
+	// ErrPrivateKeyMismatch is returned when signing for an address or
+	// output which is not controlled by the given private key.
+	ErrPrivateKeyMismatch = errors.New("private key does not control the address")
+
+	// ErrInconclusive is returned when a signature can be neither proven valid nor invalid,
+	// because it spends an output using rules reserved for future soft forks, or because it
+	// spends an output which was not provided to the verifier.
+	ErrInconclusive = errors.New("BIP322 signature verification is inconclusive")
+)
+
+var messageHasher = bhash.NewTaggedHasher(MessageTag)
+
+// Hash returns the BIP340 tagged hash of the given message, using MessageTag.
+func Hash(msg string) (hashed [32]byte) {
+	copy(hashed[:], messageHasher([]byte(msg)))
+	return
+}
+
+// ToSpend returns the virtual to_spend transaction, whose only output is locked by the
+// scriptPubKey of the address being signed for, and which commits to the message.
+func ToSpend(scriptPubKey []byte, msg string) *tx.Tx {
+	hash := Hash(msg)
+
+	scriptSig := make([]byte, 0, 34)
+	scriptSig = append(scriptSig, constants.OP_0)
+	scriptSig = append(scriptSig, script.PushData(hash[:])...)
+
+	return &tx.Tx{
+		Version: 0,
+		Inputs: []*tx.Input{
+			{
+				PrevOut:  &tx.PrevOut{Hash: [32]byte{}, Index: 0xffffffff},
+				Script:   scriptSig,
+				Sequence: 0,
+			},
+		},
+		Outputs: []*tx.Output{
+			{
+				Value:  0,
+				Script: scriptPubKey,
+			},
+		},
+		Locktime: 0,
+	}
+}
+
+// ToSign returns the unsigned virtual to_sign transaction, which spends the first output of
+// toSpend and has a single OP_RETURN output. Extra inputs can be appended to the returned
+// transaction to create a proof of funds.
+func ToSign(toSpend *tx.Tx) (*tx.Tx, error) {
+	toSpendHash, err := toSpend.Hash(false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash to_spend transaction: %w", err)
+	}
+
+	toSign := &tx.Tx{
+		Version: 0,
+		Inputs: []*tx.Input{
+			{
+				PrevOut:  &tx.PrevOut{Hash: toSpendHash, Index: 0},
+				Script:   []byte{},
+				Sequence: 0,
+			},
+		},
+		Outputs: []*tx.Output{
+			{
+				Value:  0,
+				Script: []byte{constants.OP_RETURN},
+			},
+		},
+		Locktime: 0,
+	}
+
+	return toSign, nil
+}
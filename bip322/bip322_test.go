@@ -0,0 +1,325 @@
+package bip322
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/address"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/ecc"
+	"github.com/kklash/bitcoinlib/message"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/unspent"
+	"github.com/kklash/bitcoinlib/wif"
+)
+
+func hex2bytes(h string) []byte {
+	d, _ := hex.DecodeString(h)
+	return d
+}
+
+// These fixtures are taken from the BIP322 test vectors.
+const (
+	testWIF            = "L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k"
+	testAddressP2WPKH  = "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l"
+	testAddressP2TR    = "bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3"
+	testSignatureP2TR  = "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ=="
+	testSignatureHello = "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI="
+)
+
+func TestHash(t *testing.T) {
+	fixtures := map[string]string{
+		"":            "c90c269c4f8fcbe6880f72a721ddfbf1914268a794cbb21cfafee13770ae19f1",
+		"Hello World": "f0eb03b1a75ac6d9847f55c624a99169b5dccba2a31f5b23bea77ba270de0a7a",
+	}
+
+	for msg, expected := range fixtures {
+		hash := Hash(msg)
+		if actual := hex.EncodeToString(hash[:]); actual != expected {
+			t.Errorf("unexpected message hash for %q\nWanted %s\nGot    %s", msg, expected, actual)
+			continue
+		}
+	}
+}
+
+func TestVirtualTransactions(t *testing.T) {
+	type Fixture struct {
+		msg         string
+		toSpendTxid string
+		toSignTxid  string
+	}
+
+	fixtures := []Fixture{
+		{
+			msg:         "",
+			toSpendTxid: "c5680aa69bb8d860bf82d4e9cd3504b55dde018de765a91bb566283c545a99a7",
+			toSignTxid:  "1e9654e951a5ba44c8604c4de6c67fd78a27e81dcadcfe1edf638ba3aaebaed6",
+		},
+		{
+			msg:         "Hello World",
+			toSpendTxid: "b79d196740ad5217771c1098fc4a4b51e0535c32236c71f1ea4d61a2d603352b",
+			toSignTxid:  "88737ae86f2077145f93cc4b153ae9a1cb8d56afa511988c149c5c8c9d93bddf",
+		},
+	}
+
+	_, scriptPubKey, err := address.Decode(testAddressP2WPKH)
+	if err != nil {
+		t.Errorf("failed to decode address: %s", err)
+		return
+	}
+
+	for _, fixture := range fixtures {
+		toSpend := ToSpend(scriptPubKey, fixture.msg)
+		toSpendTxid, err := toSpend.Id(false)
+		if err != nil {
+			t.Errorf("failed to compute to_spend txid: %s", err)
+			continue
+		} else if toSpendTxid != fixture.toSpendTxid {
+			t.Errorf("to_spend txid does not match\nWanted %s\nGot    %s", fixture.toSpendTxid, toSpendTxid)
+			continue
+		}
+
+		toSign, err := ToSign(toSpend)
+		if err != nil {
+			t.Errorf("failed to create to_sign: %s", err)
+			continue
+		}
+
+		toSignTxid, err := toSign.Id(false)
+		if err != nil {
+			t.Errorf("failed to compute to_sign txid: %s", err)
+			continue
+		} else if toSignTxid != fixture.toSignTxid {
+			t.Errorf("to_sign txid does not match\nWanted %s\nGot    %s", fixture.toSignTxid, toSignTxid)
+			continue
+		}
+	}
+}
+
+func TestVerify_Vectors(t *testing.T) {
+	type Fixture struct {
+		address   string
+		msg       string
+		signature string
+	}
+
+	fixtures := []Fixture{
+		{testAddressP2WPKH, "Hello World", testSignatureHello},
+		{testAddressP2TR, "Hello World", testSignatureP2TR},
+	}
+
+	for _, fixture := range fixtures {
+		valid, err := Verify(fixture.address, fixture.msg, fixture.signature)
+		if err != nil {
+			t.Errorf("failed to verify signature for %s: %s", fixture.address, err)
+			continue
+		} else if !valid {
+			t.Errorf("expected signature for %s on %q to be valid", fixture.address, fixture.msg)
+			continue
+		}
+
+		// Signatures must not be valid for other messages.
+		valid, err = Verify(fixture.address, fixture.msg+"!", fixture.signature)
+		if err != nil {
+			t.Errorf("failed to verify signature for %s: %s", fixture.address, err)
+			continue
+		} else if valid {
+			t.Errorf("expected signature for %s to be invalid for a different message", fixture.address)
+			continue
+		}
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	privateKey, _, _, err := wif.Decode(testWIF)
+	if err != nil {
+		t.Errorf("failed to decode WIF key: %s", err)
+		return
+	}
+
+	type Fixture struct {
+		addressFormat constants.AddressFormat
+		compressed    bool
+		format        Format
+	}
+
+	fixtures := []Fixture{
+		{constants.FormatP2PKH, true, FormatFull},
+		{constants.FormatP2PKH, false, FormatFull},
+		{constants.FormatP2SH, true, FormatFull},
+		{constants.FormatP2WPKH, true, FormatFull},
+		{constants.FormatP2WPKH, true, FormatSimple},
+		{constants.FormatP2TR, true, FormatFull},
+		{constants.FormatP2TR, true, FormatSimple},
+	}
+
+	const msg = "Hello World"
+
+	for _, fixture := range fixtures {
+		data := ecc.GetPublicKey(privateKey, fixture.compressed)
+		switch fixture.addressFormat {
+		case constants.FormatP2SH:
+			data, _ = script.MakeP2WPKHFromPublicKey(data)
+		case constants.FormatP2TR:
+			data = ecc.GetPublicKeySchnorr(privateKey)
+		}
+
+		addr, err := address.Make(fixture.addressFormat, data)
+		if err != nil {
+			t.Errorf("failed to make %s address: %s", fixture.addressFormat, err)
+			continue
+		}
+
+		signature, err := Sign(privateKey, fixture.compressed, addr, msg, fixture.format)
+		if err != nil {
+			t.Errorf("failed to sign message for %s: %s", addr, err)
+			continue
+		}
+
+		valid, err := Verify(addr, msg, signature)
+		if err != nil {
+			t.Errorf("failed to verify signature for %s: %s", addr, err)
+			continue
+		} else if !valid {
+			t.Errorf("expected signature for %s to be valid", addr)
+			continue
+		}
+
+		valid, err = Verify(addr, "Goodbye World", signature)
+		if err != nil {
+			t.Errorf("failed to verify signature for %s: %s", addr, err)
+			continue
+		} else if valid {
+			t.Errorf("expected signature for %s to be invalid for a different message", addr)
+			continue
+		}
+	}
+
+	// The simple format cannot be used for addresses which need a scriptSig.
+	p2pkhAddress, _ := address.Make(constants.FormatP2PKH, ecc.GetPublicKeyCompressed(privateKey))
+	if _, err := Sign(privateKey, true, p2pkhAddress, msg, FormatSimple); !errors.Is(err, ErrUnsupportedAddressFormat) {
+		t.Errorf("expected ErrUnsupportedAddressFormat for simple P2PKH signature, got %v", err)
+		return
+	}
+
+	// Signing for a different key's address must fail.
+	if _, err := Sign(privateKey, true, "bc1qngw83fg8dz0k749cg7k3emc7v98wy0c74dlrkd", msg, FormatSimple); !errors.Is(err, ErrPrivateKeyMismatch) {
+		t.Errorf("expected ErrPrivateKeyMismatch, got %v", err)
+		return
+	}
+
+	// Legacy BIP137 signatures are accepted for P2PKH addresses.
+	legacySignature, err := message.Sign(privateKey, true, msg, constants.FormatP2PKH)
+	if err != nil {
+		t.Errorf("failed to create legacy signature: %s", err)
+		return
+	}
+	if valid, err := Verify(p2pkhAddress, msg, legacySignature); err != nil || !valid {
+		t.Errorf("expected legacy signature to be valid; got valid=%v, err=%v", valid, err)
+		return
+	}
+}
+
+func TestSignAndVerifyProofOfFunds(t *testing.T) {
+	privateKey, _, _, err := wif.Decode(testWIF)
+	if err != nil {
+		t.Errorf("failed to decode WIF key: %s", err)
+		return
+	}
+
+	publicKey := ecc.GetPublicKeyCompressed(privateKey)
+	p2wpkh, _ := script.MakeP2WPKHFromPublicKey(publicKey)
+	p2tr, _ := script.MakeP2TR(ecc.GetPublicKeySchnorr(privateKey), nil)
+
+	utxos := []*unspent.Output{
+		{
+			Outpoint: &tx.PrevOut{Hash: [32]byte{1}, Index: 3},
+			TxOut:    &tx.Output{Value: 150000, Script: p2wpkh},
+		},
+		{
+			Outpoint: &tx.PrevOut{Hash: [32]byte{2}, Index: 0},
+			TxOut:    &tx.Output{Value: 20000, Script: p2tr},
+		},
+	}
+
+	const msg = "proof of funds"
+
+	signature, err := SignProofOfFunds(privateKey, true, testAddressP2WPKH, msg, utxos)
+	if err != nil {
+		t.Errorf("failed to sign proof of funds: %s", err)
+		return
+	}
+
+	valid, err := VerifyProofOfFunds(testAddressP2WPKH, msg, signature, utxos)
+	if err != nil {
+		t.Errorf("failed to verify proof of funds: %s", err)
+		return
+	} else if !valid {
+		t.Errorf("expected proof of funds to be valid")
+		return
+	}
+
+	// Without the spent outputs, the proof cannot be verified.
+	if _, err := Verify(testAddressP2WPKH, msg, signature); !errors.Is(err, ErrInconclusive) {
+		t.Errorf("expected ErrInconclusive without utxos, got %v", err)
+		return
+	}
+
+	// Proofs with the wrong amounts are invalid, as segwit signatures commit to amounts.
+	wrongUtxos := []*unspent.Output{utxos[0].Clone(), utxos[1].Clone()}
+	wrongUtxos[0].TxOut.Value++
+	valid, err = VerifyProofOfFunds(testAddressP2WPKH, msg, signature, wrongUtxos)
+	if err != nil {
+		t.Errorf("failed to verify proof of funds: %s", err)
+		return
+	} else if valid {
+		t.Errorf("expected proof of funds with wrong amounts to be invalid")
+		return
+	}
+
+	// Outputs which are not controlled by the key cannot be spent.
+	otherKey := hex2bytes("0000000000000000000000000000000000000000000000000000000000000003")
+	otherScript, _ := script.MakeP2WPKHFromPublicKey(ecc.GetPublicKeyCompressed(otherKey))
+	foreignUtxos := []*unspent.Output{{
+		Outpoint: &tx.PrevOut{Hash: [32]byte{3}, Index: 0},
+		TxOut:    &tx.Output{Value: 1000, Script: otherScript},
+	}}
+	if _, err := SignProofOfFunds(privateKey, true, testAddressP2WPKH, msg, foreignUtxos); !errors.Is(err, ErrPrivateKeyMismatch) {
+		t.Errorf("expected ErrPrivateKeyMismatch, got %v", err)
+		return
+	}
+}
+
+func TestVerify_Invalid(t *testing.T) {
+	if _, err := Verify(testAddressP2WPKH, "Hello World", "not base64!"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for bad base64, got %v", err)
+		return
+	}
+
+	if _, err := Verify(testAddressP2WPKH, "Hello World", base64.StdEncoding.EncodeToString([]byte{5, 1})); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for truncated witness, got %v", err)
+		return
+	}
+
+	if _, err := Verify("notanaddress", "Hello World", testSignatureHello); !errors.Is(err, address.ErrInvalidAddress) {
+		t.Errorf("expected ErrInvalidAddress, got %v", err)
+		return
+	}
+
+	// A full signature whose to_sign transaction has the wrong output.
+	_, scriptPubKey, _ := address.Decode(testAddressP2WPKH)
+	toSign, _ := ToSign(ToSpend(scriptPubKey, "Hello World"))
+	toSign.Outputs[0].Value = 1
+	witnessBytes, _ := base64.StdEncoding.DecodeString(testSignatureHello)
+	witness, _ := tx.WitnessFromReader(bytes.NewReader(witnessBytes))
+	toSign.Witnesses = []tx.Witness{witness}
+	fullSignature := base64.StdEncoding.EncodeToString(toSign.Bytes())
+	if _, err := Verify(testAddressP2WPKH, "Hello World", fullSignature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for bad to_sign output, got %v", err)
+		return
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/kklash/bitcoinlib/bip322
+
+go 1.18
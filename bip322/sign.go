@@ -0,0 +1,163 @@
+package bip322
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/kklash/bitcoinlib/address"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/ecc"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/signer"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/unspent"
+	"github.com/kklash/bitcoinlib/wif"
+)
+
+// Sign signs the given message for an address controlled by privateKey, and returns a
+// base64-encoded BIP322 signature in the given format. The address is decoded using
+// constants.CurrentNetwork. Supported address formats are:
+//
+//   - constants.FormatP2PKH: a P2PKH address of the compressed or uncompressed public key.
+//   - constants.FormatP2SH: a P2SH-P2WPKH nested segwit address.
+//   - constants.FormatP2WPKH: a native P2WPKH segwit address.
+//   - constants.FormatP2TR: a P2TR address whose output key is the BIP86 tweak of the public key.
+//
+// P2PKH and P2SH addresses can only be signed for using FormatFull. Returns an error
+// wrapping ErrUnsupportedAddressFormat if the address cannot be signed for, and
+// ErrPrivateKeyMismatch if the address is not controlled by privateKey.
+func Sign(privateKey []byte, compressed bool, addr, msg string, format Format) (string, error) {
+	toSign, err := sign(privateKey, compressed, addr, msg, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if format == FormatFull {
+		return base64.StdEncoding.EncodeToString(toSign.Bytes()), nil
+	}
+
+	if len(toSign.Inputs[0].Script) != 0 {
+		return "", fmt.Errorf("%w: simple format cannot be used with a scriptSig", ErrUnsupportedAddressFormat)
+	}
+
+	return base64.StdEncoding.EncodeToString(toSign.Witnesses[0].Bytes()), nil
+}
+
+// SignWIF signs the given message with a WIF-encoded private key. Whether the public key is
+// compressed is determined by the WIF key. See Sign for the supported address formats.
+func SignWIF(wifKey, addr, msg string, format Format) (string, error) {
+	privateKey, _, compressed, err := wif.Decode(wifKey)
+	if err != nil {
+		return "", err
+	}
+
+	return Sign(privateKey, compressed, addr, msg, format)
+}
+
+// SignProofOfFunds signs the given message for an address controlled by privateKey, and also
+// spends the given unspent outputs in the to_sign transaction, proving that the signer controls
+// them. Each output must be locked by a script of a supported format which privateKey controls.
+// Proofs of funds are always encoded in FormatFull.
+func SignProofOfFunds(
+	privateKey []byte,
+	compressed bool,
+	addr, msg string,
+	utxos []*unspent.Output,
+) (string, error) {
+	toSign, err := sign(privateKey, compressed, addr, msg, utxos)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(toSign.Bytes()), nil
+}
+
+// sign builds and signs the to_sign transaction for the given address and message,
+// with extra inputs spending the given unspent outputs.
+func sign(privateKey []byte, compressed bool, addr, msg string, utxos []*unspent.Output) (*tx.Tx, error) {
+	_, scriptPubKey, err := address.Decode(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	toSign, err := ToSign(ToSpend(scriptPubKey, msg))
+	if err != nil {
+		return nil, err
+	}
+
+	prevOuts := []*tx.Output{{Value: 0, Script: scriptPubKey}}
+	for _, utxo := range utxos {
+		toSign.Inputs = append(toSign.Inputs, &tx.Input{
+			PrevOut:  utxo.Outpoint.Clone(),
+			Script:   []byte{},
+			Sequence: 0,
+		})
+		prevOuts = append(prevOuts, utxo.TxOut.Clone())
+	}
+
+	for i := range toSign.Inputs {
+		if err := signInput(toSign, i, privateKey, compressed, prevOuts); err != nil {
+			return nil, err
+		}
+	}
+
+	return toSign, nil
+}
+
+// signInput signs input nInput of toSign, which spends prevOuts[nInput], using the signer
+// function appropriate for the format of the previous output script.
+func signInput(toSign *tx.Tx, nInput int, privateKey []byte, compressed bool, prevOuts []*tx.Output) error {
+	prevOut := prevOuts[nInput]
+	addressFormat := script.ClassifyOutput(prevOut.Script)
+
+	expectedScript, err := scriptForKey(privateKey, compressed, addressFormat)
+	if err != nil {
+		return err
+	} else if !bytes.Equal(expectedScript, prevOut.Script) {
+		return fmt.Errorf("%w: input %d", ErrPrivateKeyMismatch, nInput)
+	}
+
+	switch addressFormat {
+	case constants.FormatP2PKH:
+		if compressed {
+			return signer.SignInputP2PKH(toSign, nInput, privateKey, constants.SigHashAll)
+		}
+		return signer.SignInputP2PKHUncompressed(toSign, nInput, privateKey, constants.SigHashAll)
+
+	case constants.FormatP2SH:
+		return signer.SignInputP2SHNestedP2WPKH(toSign, nInput, privateKey, constants.SigHashAll, prevOut.Value)
+
+	case constants.FormatP2WPKH:
+		return signer.SignInputP2WPKH(toSign, nInput, privateKey, constants.SigHashAll, prevOut.Value)
+
+	default: // constants.FormatP2TR
+		return signer.SignInputP2TR(toSign, nInput, privateKey, constants.SigHashDefault, prevOuts)
+	}
+}
+
+// scriptForKey returns the output script of the given format which is controlled by privateKey.
+func scriptForKey(privateKey []byte, compressed bool, addressFormat constants.AddressFormat) ([]byte, error) {
+	publicKey := ecc.GetPublicKey(privateKey, compressed)
+
+	switch {
+	case addressFormat == constants.FormatP2PKH:
+		return script.MakeP2PKHFromPublicKey(publicKey)
+
+	case addressFormat == constants.FormatP2SH && compressed:
+		p2wpkh, err := script.MakeP2WPKHFromPublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return script.MakeP2SHFromScript(p2wpkh), nil
+
+	case addressFormat == constants.FormatP2WPKH && compressed:
+		return script.MakeP2WPKHFromPublicKey(publicKey)
+
+	case addressFormat == constants.FormatP2TR:
+		return script.MakeP2TR(ecc.GetPublicKeySchnorr(privateKey), nil)
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAddressFormat, addressFormat)
+	}
+}
@@ -0,0 +1,128 @@
+package bip322
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/kklash/bitcoinlib/address"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/interpreter"
+	"github.com/kklash/bitcoinlib/message"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/unspent"
+)
+
+// Verify returns true if the given base64-encoded BIP322 signature on msg was created by the
+// owner of the given address. The address is decoded using constants.CurrentNetwork. Simple and
+// full signatures are detected automatically. Legacy BIP137 signatures are also accepted for P2PKH
+// addresses, as allowed by BIP322.
+//
+// Returns an error wrapping ErrInvalidSignature if the signature cannot be decoded, or an error
+// wrapping address.ErrInvalidAddress if the address cannot be decoded. Returns ErrInconclusive
+// if the signature spends outputs other than the virtual to_spend output, or uses spending rules
+// reserved for future soft forks. Returns false with no error if the signature is well-formed,
+// but was not made by the owner of the address.
+func Verify(addr, msg, signature string) (bool, error) {
+	return VerifyProofOfFunds(addr, msg, signature, nil)
+}
+
+// VerifyProofOfFunds verifies a BIP322 signature like Verify, but also accepts full signatures
+// whose to_sign transaction spends any of the given unspent outputs, proving that the signer
+// controls them. It is the caller's responsibility to check that the outputs are unspent.
+func VerifyProofOfFunds(addr, msg, signature string, utxos []*unspent.Output) (bool, error) {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+
+	addressFormat, scriptPubKey, err := address.Decode(addr)
+	if err != nil {
+		return false, err
+	}
+
+	if addressFormat == constants.FormatP2PKH && len(sig) == constants.CompactSignatureLength {
+		return message.Verify(addr, msg, signature)
+	}
+
+	toSpend := ToSpend(scriptPubKey, msg)
+	toSign, err := decodeToSign(sig, toSpend)
+	if err != nil {
+		return false, err
+	}
+
+	if err := checkToSign(toSign); err != nil {
+		return false, err
+	}
+
+	// A to_sign transaction which spends a different to_spend transaction
+	// is a signature on a different message, or for a different address.
+	toSpendHash, err := toSpend.Hash(false)
+	if err != nil {
+		return false, err
+	} else if prevOut := toSign.Inputs[0].PrevOut; prevOut.Hash != toSpendHash || prevOut.Index != 0 {
+		return false, nil
+	}
+
+	prevOuts := []*tx.Output{toSpend.Outputs[0]}
+	unspentOutputs := unspent.NewOutputSet(utxos)
+	for _, input := range toSign.Inputs[1:] {
+		utxo := unspentOutputs.GetByOutpoint(input.PrevOut)
+		if utxo == nil {
+			return false, fmt.Errorf("%w: unknown output %s is spent", ErrInconclusive, input.PrevOut)
+		}
+		prevOuts = append(prevOuts, utxo.TxOut)
+	}
+
+	err = interpreter.VerifyTx(toSign, prevOuts, interpreter.StandardFlags)
+	if errors.Is(err, interpreter.ErrDiscourageUpgradableWitnessProgram) {
+		return false, fmt.Errorf("%w: %s", ErrInconclusive, err)
+	} else if errors.Is(err, interpreter.ErrScriptFailed) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// decodeToSign decodes a full or simple signature into the to_sign transaction.
+// A signature is treated as full-format if it is a canonically serialized transaction.
+func decodeToSign(sig []byte, toSpend *tx.Tx) (*tx.Tx, error) {
+	if toSign, err := tx.FromBytes(sig); err == nil && bytes.Equal(toSign.Bytes(), sig) {
+		return toSign, nil
+	}
+
+	reader := bytes.NewReader(sig)
+	witness, err := tx.WitnessFromReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	} else if reader.Len() != 0 {
+		return nil, fmt.Errorf("%w: unexpected trailing data after witness", ErrInvalidSignature)
+	}
+
+	toSign, err := ToSign(toSpend)
+	if err != nil {
+		return nil, err
+	}
+	toSign.Witnesses = []tx.Witness{witness}
+
+	return toSign, nil
+}
+
+// checkToSign checks that the to_sign transaction has at least one input,
+// and has the single empty OP_RETURN output required by BIP322.
+func checkToSign(toSign *tx.Tx) error {
+	if len(toSign.Inputs) == 0 {
+		return fmt.Errorf("%w: to_sign transaction has no inputs", ErrInvalidSignature)
+	}
+
+	if len(toSign.Outputs) != 1 ||
+		toSign.Outputs[0].Value != 0 ||
+		!bytes.Equal(toSign.Outputs[0].Script, []byte{constants.OP_RETURN}) {
+		return fmt.Errorf("%w: to_sign transaction must have a single empty OP_RETURN output", ErrInvalidSignature)
+	}
+
+	return nil
+}
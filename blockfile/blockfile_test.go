@@ -0,0 +1,281 @@
+package blockfile
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+const testMagic uint32 = 0xdab5bffa // regtest
+
+// makeBlock returns a block with a single coinbase transaction, building on parent. nonce
+// distinguishes sibling blocks.
+func makeBlock(t *testing.T, parent *blocks.Block, nonce uint32) *blocks.Block {
+	header := &blockheader.BlockHeader{Version: 1, NBits: 0x207fffff, Nonce: nonce}
+	if parent != nil {
+		prevHash, err := parent.Header.Hash()
+		if err != nil {
+			t.Fatalf("failed to hash block: %s", err)
+		}
+		header.PreviousHeaderHash = prevHash
+		header.Time = parent.Header.Time + 1
+	}
+
+	coinbase := &tx.Tx{
+		Version: 1,
+		Inputs: []*tx.Input{{
+			PrevOut:  &tx.PrevOut{Index: 0xffffffff},
+			Script:   bytes.Repeat([]byte{byte(nonce)}, 4),
+			Sequence: 0xffffffff,
+		}},
+		Outputs: []*tx.Output{{Value: 5000000000, Script: []byte{0x51}}},
+	}
+
+	return &blocks.Block{Header: header, Transactions: []*tx.Tx{coinbase}}
+}
+
+func makeChain(t *testing.T, parent *blocks.Block, length int, nonce uint32) []*blocks.Block {
+	chain := make([]*blocks.Block, length)
+	for i := range chain {
+		chain[i] = makeBlock(t, parent, nonce)
+		parent = chain[i]
+	}
+	return chain
+}
+
+func record(magic uint32, block *blocks.Block) []byte {
+	data := block.Bytes()
+	header := make([]byte, recordHeaderSize)
+	binary.LittleEndian.PutUint32(header, magic)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(header, data...)
+}
+
+// writeBlockFile writes the given block records to a file, obfuscated with key, and followed
+// by trailing bytes such as a partial record.
+func writeBlockFile(t *testing.T, path string, key [XORKeySize]byte, trailing []byte, blockList ...*blocks.Block) {
+	writePaddedBlockFile(t, path, key, trailing, 0, blockList...)
+}
+
+// writePaddedBlockFile is like writeBlockFile, but also ends the file with the given number of
+// zero bytes of padding, which Bitcoin Core preallocates without obfuscating it.
+func writePaddedBlockFile(t *testing.T, path string, key [XORKeySize]byte, trailing []byte, padding int, blockList ...*blocks.Block) {
+	var data []byte
+	for _, block := range blockList {
+		data = append(data, record(testMagic, block)...)
+	}
+	data = append(data, trailing...)
+	xorAt(data, key, 0)
+	data = append(data, make([]byte, padding)...)
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write block file: %s", err)
+	}
+}
+
+func blockHashes(t *testing.T, blockList []*blocks.Block) [][32]byte {
+	hashes := make([][32]byte, len(blockList))
+	for i, block := range blockList {
+		hash, err := block.Header.Hash()
+		if err != nil {
+			t.Fatalf("failed to hash block: %s", err)
+		}
+		hashes[i] = hash
+	}
+	return hashes
+}
+
+func TestBuildIndex(t *testing.T) {
+	blocksDir := t.TempDir()
+	key := [XORKeySize]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	if err := os.WriteFile(filepath.Join(blocksDir, "xor.dat"), key[:], 0o600); err != nil {
+		t.Fatalf("failed to write xor.dat: %s", err)
+	}
+
+	// Main chain of 6 blocks, and a stale 2-block fork from height 2.
+	mainChain := makeChain(t, nil, 6, 0)
+	fork := makeChain(t, mainChain[1], 2, 1)
+
+	// An orphaned block whose parent is not in the files.
+	orphan := makeBlock(t, makeBlock(t, nil, 99), 99)
+
+	// A partially written block at the end of the last file.
+	partial := record(testMagic, mainChain[0])[:50]
+
+	writePaddedBlockFile(t, filepath.Join(blocksDir, "blk00000.dat"), key, nil, 100,
+		mainChain[0], fork[0], mainChain[2], mainChain[1], fork[1], orphan)
+	writeBlockFile(t, filepath.Join(blocksDir, "blk00001.dat"), key, partial,
+		mainChain[4], mainChain[3], mainChain[5], mainChain[3])
+
+	// Files with other names are ignored.
+	os.WriteFile(filepath.Join(blocksDir, "rev00000.dat"), []byte("not a block file"), 0o600)
+
+	index, err := BuildIndex(blocksDir, testMagic)
+	if err != nil {
+		t.Errorf("failed to build index: %s", err)
+		return
+	}
+	defer index.Close()
+
+	if index.Height() != 5 {
+		t.Errorf("incorrect index height\nWanted 5\nGot    %d", index.Height())
+		return
+	}
+
+	expectedHashes := blockHashes(t, mainChain)
+	for height, expectedHash := range expectedHashes {
+		hash, err := index.BlockHash(uint32(height))
+		if err != nil {
+			t.Errorf("failed to get block hash at height %d: %s", height, err)
+			return
+		} else if hash != expectedHash {
+			t.Errorf("incorrect hash at height %d\nWanted %x\nGot    %x", height, expectedHash, hash)
+			return
+		}
+
+		block, err := index.ReadBlock(uint32(height))
+		if err != nil {
+			t.Errorf("failed to read block at height %d: %s", height, err)
+			return
+		} else if !reflect.DeepEqual(block, mainChain[height]) {
+			t.Errorf("incorrect block at height %d", height)
+			return
+		}
+	}
+
+	if _, err := index.ReadBlock(6); !errors.Is(err, ErrHeightOutOfRange) {
+		t.Errorf("expected ErrHeightOutOfRange, got %v", err)
+		return
+	}
+
+	nextBlock := index.StreamBlocks(context.Background(), 2, 5)
+	var streamed []*blocks.Block
+	for {
+		block, err := nextBlock()
+		if err != nil {
+			t.Errorf("failed to stream blocks: %s", err)
+			return
+		} else if block == nil {
+			break
+		}
+		streamed = append(streamed, block)
+	}
+
+	if !reflect.DeepEqual(blockHashes(t, streamed), expectedHashes[2:]) {
+		t.Errorf("incorrect streamed blocks")
+		return
+	}
+
+	nextBlock = index.StreamBlocks(context.Background(), 4, 7)
+	for i := 0; i < 2; i++ {
+		if block, err := nextBlock(); err != nil || block == nil {
+			t.Errorf("expected block %d from stream, got %v", i, err)
+			return
+		}
+	}
+	if _, err := nextBlock(); !errors.Is(err, ErrHeightOutOfRange) {
+		t.Errorf("expected ErrHeightOutOfRange from stream, got %v", err)
+		return
+	}
+}
+
+func TestBuildIndex_MostWork(t *testing.T) {
+	blocksDir := t.TempDir()
+
+	// The longer branch has less work than the branch with a lower target.
+	genesis := makeBlock(t, nil, 0)
+	long := makeChain(t, genesis, 3, 1)
+
+	heavy := makeBlock(t, genesis, 2)
+	heavy.Header.NBits = 0x1f00ffff
+
+	writeBlockFile(t, filepath.Join(blocksDir, "blk00000.dat"), [XORKeySize]byte{}, nil,
+		genesis, long[0], long[1], long[2], heavy)
+
+	index, err := BuildIndex(blocksDir, testMagic)
+	if err != nil {
+		t.Errorf("failed to build index: %s", err)
+		return
+	}
+	defer index.Close()
+
+	if hash, _ := index.BlockHash(index.Height()); index.Height() != 1 || hash != blockHashes(t, []*blocks.Block{heavy})[0] {
+		t.Errorf("expected most-work tip at height 1, got %x at height %d", hash, index.Height())
+		return
+	}
+}
+
+func TestBuildIndex_Errors(t *testing.T) {
+	blocksDir := t.TempDir()
+	genesis := makeBlock(t, nil, 0)
+	child := makeBlock(t, genesis, 0)
+
+	writeBlockFile(t, filepath.Join(blocksDir, "blk00000.dat"), [XORKeySize]byte{}, nil, child)
+	if _, err := BuildIndex(blocksDir, testMagic); !errors.Is(err, ErrNoGenesisBlock) {
+		t.Errorf("expected ErrNoGenesisBlock, got %v", err)
+		return
+	}
+
+	if _, err := BuildIndex(blocksDir, 0xd9b4bef9); !errors.Is(err, ErrInvalidMagic) {
+		t.Errorf("expected ErrInvalidMagic, got %v", err)
+		return
+	}
+
+	// A truncated block is only tolerated at the end of the last file.
+	writeBlockFile(t, filepath.Join(blocksDir, "blk00000.dat"), [XORKeySize]byte{}, record(testMagic, child)[:90], genesis)
+	writeBlockFile(t, filepath.Join(blocksDir, "blk00001.dat"), [XORKeySize]byte{}, nil, child)
+	if _, err := BuildIndex(blocksDir, testMagic); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+		return
+	}
+
+	os.WriteFile(filepath.Join(blocksDir, "xor.dat"), []byte{1, 2, 3}, 0o600)
+	if _, err := BuildIndex(blocksDir, testMagic); !errors.Is(err, ErrInvalidXORKey) {
+		t.Errorf("expected ErrInvalidXORKey, got %v", err)
+		return
+	}
+}
+
+func TestReader(t *testing.T) {
+	genesis := makeBlock(t, nil, 0)
+	child := makeBlock(t, genesis, 0)
+
+	data := append(record(testMagic, genesis), record(testMagic, child)...)
+	key := [XORKeySize]byte{0xff, 0, 0xff, 0, 0xff, 0, 0xff, 0}
+	xorAt(data, key, 0)
+
+	// Start reading from the second record, as if seeking into the file.
+	offset := int64(len(record(testMagic, genesis)))
+	reader := NewReader(NewXORReader(bytes.NewReader(data[offset:]), key, offset), testMagic)
+
+	block, err := reader.NextBlock()
+	if err != nil {
+		t.Errorf("failed to read block: %s", err)
+		return
+	} else if !reflect.DeepEqual(block, child) {
+		t.Errorf("incorrect block read")
+		return
+	}
+
+	if _, err := reader.NextBlock(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+		return
+	}
+
+	bad := record(testMagic, genesis)
+	binary.LittleEndian.PutUint32(bad[4:], 10)
+	if _, err := NewReader(bytes.NewReader(bad), testMagic).NextBlock(); !errors.Is(err, ErrInvalidRecord) {
+		t.Errorf("expected ErrInvalidRecord, got %v", err)
+		return
+	}
+}
@@ -0,0 +1,202 @@
+package blockfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/ecc"
+	"github.com/kklash/bitcoinlib/script"
+)
+
+// ErrInvalidUndo is returned when undo data is not formatted correctly.
+var ErrInvalidUndo = errors.New("undo data is not formatted correctly")
+
+// maxScriptSize is the maximum size of a spendable output script. Bitcoin Core replaces
+// larger scripts in compressed coins with a single OP_RETURN.
+const maxScriptSize = 10000
+
+// numSpecialScripts is the number of compressed script types which are encoded
+// without their full script.
+const numSpecialScripts = 6
+
+// readVarInt reads an integer in the base-128 format which Bitcoin Core uses for its own
+// databases. Unlike the CompactSize format of the P2P protocol, each byte holds 7 bits of
+// the number, most significant first, and the high bit marks continuation. One is subtracted
+// from every non-final byte, so that each number has exactly one encoding.
+func readVarInt(reader io.ByteReader) (uint64, error) {
+	var n uint64
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		if n > (^uint64(0))>>7 {
+			return 0, fmt.Errorf("%w: varint overflows 64 bits", ErrInvalidUndo)
+		}
+		n = (n << 7) | uint64(b&0x7f)
+
+		if b&0x80 == 0 {
+			return n, nil
+		} else if n == ^uint64(0) {
+			return 0, fmt.Errorf("%w: varint overflows 64 bits", ErrInvalidUndo)
+		}
+		n++
+	}
+}
+
+// appendVarInt appends n to buf in the format read by readVarInt.
+func appendVarInt(buf []byte, n uint64) []byte {
+	var tmp [10]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(n & 0x7f)
+	for n > 0x7f {
+		n = (n >> 7) - 1
+		i--
+		tmp[i] = byte(n&0x7f) | 0x80
+	}
+	return append(buf, tmp[i:]...)
+}
+
+// compressAmount compresses an output value in satoshis, by removing trailing zeros which
+// are common in amounts chosen by people.
+func compressAmount(n uint64) uint64 {
+	if n == 0 {
+		return 0
+	}
+
+	e := uint64(0)
+	for n%10 == 0 && e < 9 {
+		n /= 10
+		e++
+	}
+
+	if e < 9 {
+		d := n % 10
+		n /= 10
+		return 1 + (n*9+d-1)*10 + e
+	}
+	return 1 + (n-1)*10 + 9
+}
+
+// decompressAmount reverses compressAmount.
+func decompressAmount(x uint64) uint64 {
+	if x == 0 {
+		return 0
+	}
+
+	x--
+	e := x % 10
+	x /= 10
+
+	var n uint64
+	if e < 9 {
+		d := (x % 9) + 1
+		x /= 9
+		n = x*10 + d
+	} else {
+		n = x + 1
+	}
+
+	for ; e > 0; e-- {
+		n *= 10
+	}
+	return n
+}
+
+// appendCompressedScript appends an output script to buf in Bitcoin Core's compressed format.
+// P2PKH, P2SH and P2PK scripts are replaced by a type byte and their hash or public key X
+// coordinate. Other scripts are prefixed by their length plus numSpecialScripts.
+func appendCompressedScript(buf []byte, scriptPubKey []byte) []byte {
+	switch {
+	case script.IsP2PKH(scriptPubKey):
+		return append(append(buf, 0x00), scriptPubKey[3:23]...)
+
+	case script.IsP2SH(scriptPubKey):
+		return append(append(buf, 0x01), scriptPubKey[2:22]...)
+
+	case len(scriptPubKey) == constants.PublicKeyCompressedLength+2 &&
+		scriptPubKey[0] == byte(constants.PublicKeyCompressedLength) &&
+		scriptPubKey[len(scriptPubKey)-1] == constants.OP_CHECKSIG &&
+		ecc.IsCompressedPublicKey(scriptPubKey[1:len(scriptPubKey)-1]):
+		return append(buf, scriptPubKey[1:len(scriptPubKey)-1]...)
+
+	case len(scriptPubKey) == constants.PublicKeyUncompressedLength+2 &&
+		scriptPubKey[0] == byte(constants.PublicKeyUncompressedLength) &&
+		scriptPubKey[1] == 0x04 &&
+		scriptPubKey[len(scriptPubKey)-1] == constants.OP_CHECKSIG:
+		// Only valid uncompressed keys can be recovered from their X coordinate.
+		if compressed, err := ecc.CompressPublicKey(scriptPubKey[1 : len(scriptPubKey)-1]); err == nil {
+			return append(append(buf, compressed[0]+2), compressed[1:]...)
+		}
+	}
+
+	buf = appendVarInt(buf, uint64(len(scriptPubKey))+numSpecialScripts)
+	return append(buf, scriptPubKey...)
+}
+
+// readCompressedScript reads an output script in the format written by appendCompressedScript.
+func readCompressedScript(reader *bytes.Reader) ([]byte, error) {
+	size, err := readVarInt(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if size >= numSpecialScripts {
+		size -= numSpecialScripts
+		if size > uint64(reader.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		} else if size > maxScriptSize {
+			reader.Seek(int64(size), io.SeekCurrent)
+			return []byte{constants.OP_RETURN}, nil
+		}
+
+		scriptPubKey := make([]byte, size)
+		if _, err := io.ReadFull(reader, scriptPubKey); err != nil {
+			return nil, err
+		}
+		return scriptPubKey, nil
+	}
+
+	payloadSize := 32
+	if size < 2 {
+		payloadSize = 20
+	}
+	payload := make([]byte, payloadSize)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+
+	switch size {
+	case 0x00:
+		var hash [20]byte
+		copy(hash[:], payload)
+		return script.MakeP2PKHFromHash(hash), nil
+
+	case 0x01:
+		var hash [20]byte
+		copy(hash[:], payload)
+		return script.MakeP2SHFromHash(hash), nil
+
+	case 0x02, 0x03:
+		publicKey := append([]byte{byte(size)}, payload...)
+		return makeP2PK(publicKey), nil
+
+	default:
+		publicKey, err := ecc.UncompressPublicKey(append([]byte{byte(size) - 2}, payload...))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid compressed public key: %s", ErrInvalidUndo, err)
+		}
+		return makeP2PK(publicKey), nil
+	}
+}
+
+func makeP2PK(publicKey []byte) []byte {
+	scriptPubKey := make([]byte, 0, len(publicKey)+2)
+	scriptPubKey = append(scriptPubKey, byte(len(publicKey)))
+	scriptPubKey = append(scriptPubKey, publicKey...)
+	return append(scriptPubKey, constants.OP_CHECKSIG)
+}
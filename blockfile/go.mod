@@ -0,0 +1,3 @@
+module github.com/kklash/bitcoinlib/blockfile
+
+go 1.18
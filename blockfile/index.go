@@ -0,0 +1,468 @@
+package blockfile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kklash/bitcoinlib/blocks"
+)
+
+var (
+	// ErrNoGenesisBlock is returned by BuildIndex if no block in the block files has a zero
+	// previous header hash. This happens if the node is pruned and the first files were deleted.
+	ErrNoGenesisBlock = errors.New("genesis block not found in block files")
+
+	// ErrHeightOutOfRange is returned when requesting a block above the tip of an Index.
+	ErrHeightOutOfRange = errors.New("block height out of range")
+)
+
+type indexEntry struct {
+	hash     [32]byte
+	prevHash [32]byte
+	file     int
+	offset   int64
+	size     uint32
+
+	parent *indexEntry
+	height int
+	work   *big.Int // total work of the chain ending at this block
+}
+
+// Index locates the blocks of the most-work chain within a node's blk*.dat files. Blocks are
+// stored in the order the node downloaded them, which differs from height order, and the
+// files may also contain stale blocks from forks. Index reads the header of every block, links
+// them by their previous header hashes, and picks the chain with the most total work.
+//
+// Block hashes are in RPC byte order, as returned by blockheader.BlockHeader.Hash.
+type Index struct {
+	files []string
+	key   [XORKeySize]byte
+	magic uint32
+	chain []*indexEntry
+
+	mutex     sync.Mutex
+	openFiles map[int]*os.File
+
+	undoMutex sync.Mutex
+	undoFiles map[int]*undoFile
+}
+
+// undoLocation is the position of an undo record within a rev*.dat file.
+type undoLocation struct {
+	offset   int64
+	size     uint32
+	checksum [checksumSize]byte
+	matched  bool
+}
+
+// undoFile tracks which records of a rev*.dat file have been matched with blocks.
+type undoFile struct {
+	file    *os.File
+	records []*undoLocation
+	byBlock map[[32]byte]*undoLocation
+	next    int // index of the record after the last one matched
+}
+
+// BuildIndex reads the headers of every block in the blk*.dat files in the given blocks directory,
+// such as ~/.bitcoin/blocks, and builds an Index of the most-work chain. magic is the network magic,
+// such as wire.MagicMainnet. If the last block of the last file is incomplete, because the node is
+// still writing it, that block is ignored.
+func BuildIndex(blocksDir string, magic uint32) (*Index, error) {
+	key, err := ReadXORKey(blocksDir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := BlockFiles(blocksDir)
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := make(map[[32]byte]*indexEntry)
+	var ordered []*indexEntry
+
+	for fileNumber, path := range files {
+		reader, file, err := OpenFile(path, key, magic)
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			header, offset, size, err := reader.NextHeader()
+			if err == io.EOF {
+				break
+			} else if err == io.ErrUnexpectedEOF && fileNumber == len(files)-1 {
+				break
+			} else if err != nil {
+				file.Close()
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+
+			hash, err := header.Hash()
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+
+			// Duplicates can occur if the node re-downloaded a block after a crash.
+			if _, exists := byHash[hash]; exists {
+				continue
+			}
+
+			work, err := header.Work()
+			if err != nil {
+				file.Close()
+				return nil, fmt.Errorf("%s: block %x: %w", path, hash, err)
+			}
+
+			entry := &indexEntry{
+				hash:     hash,
+				prevHash: header.PreviousHeaderHash,
+				file:     fileNumber,
+				offset:   offset,
+				size:     size,
+				work:     work,
+			}
+			byHash[hash] = entry
+			ordered = append(ordered, entry)
+		}
+
+		file.Close()
+	}
+
+	tip, err := findBestTip(ordered, byHash)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]*indexEntry, tip.height+1)
+	for entry := tip; entry != nil; entry = entry.parent {
+		chain[entry.height] = entry
+	}
+
+	index := &Index{
+		files:     files,
+		key:       key,
+		magic:     magic,
+		chain:     chain,
+		openFiles: make(map[int]*os.File),
+		undoFiles: make(map[int]*undoFile),
+	}
+	return index, nil
+}
+
+// findBestTip links each entry to its parent, computes heights and total work, and returns the
+// entry with the most total work. Ties are broken in favor of the block which appears first in
+// the files. Entries which do not descend from a genesis block are ignored.
+func findBestTip(ordered []*indexEntry, byHash map[[32]byte]*indexEntry) (*indexEntry, error) {
+	const (
+		unvisited = -1
+		orphaned  = -2
+	)
+	for _, entry := range ordered {
+		entry.height = unvisited
+	}
+
+	// orphan stands in for the missing parent of a branch which cannot be connected to a genesis block.
+	orphan := &indexEntry{height: orphaned}
+
+	var (
+		best  *indexEntry
+		stack []*indexEntry
+	)
+
+	for _, entry := range ordered {
+		// Walk back to the first ancestor which has been visited, or to the genesis block.
+		stack = stack[:0]
+		ancestor := entry
+		for ancestor != nil && ancestor.height == unvisited {
+			stack = append(stack, ancestor)
+
+			if ancestor.prevHash == ([32]byte{}) {
+				ancestor = nil
+			} else if parent, ok := byHash[ancestor.prevHash]; ok {
+				ancestor = parent
+			} else {
+				ancestor = orphan
+			}
+		}
+
+		// Assign heights and total work forward from that ancestor.
+		for i := len(stack) - 1; i >= 0; i-- {
+			e := stack[i]
+			switch {
+			case ancestor == nil:
+				e.height = 0
+			case ancestor.height == orphaned:
+				e.height = orphaned
+			default:
+				e.parent = ancestor
+				e.height = ancestor.height + 1
+				e.work.Add(e.work, ancestor.work)
+			}
+			ancestor = e
+		}
+
+		if entry.height >= 0 && (best == nil || entry.work.Cmp(best.work) > 0) {
+			best = entry
+		}
+	}
+
+	if best == nil {
+		return nil, ErrNoGenesisBlock
+	}
+	return best, nil
+}
+
+// Height returns the height of the tip of the index's chain.
+func (index *Index) Height() uint32 {
+	return uint32(len(index.chain) - 1)
+}
+
+// BlockHash returns the hash of the block at the given height.
+func (index *Index) BlockHash(height uint32) ([32]byte, error) {
+	if int(height) >= len(index.chain) {
+		return [32]byte{}, ErrHeightOutOfRange
+	}
+	return index.chain[height].hash, nil
+}
+
+// ReadBlock reads the block at the given height from the block files.
+func (index *Index) ReadBlock(height uint32) (*blocks.Block, error) {
+	if int(height) >= len(index.chain) {
+		return nil, ErrHeightOutOfRange
+	}
+	entry := index.chain[height]
+
+	file, err := index.openFile(entry.file)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, entry.size)
+	if _, err := file.ReadAt(data, entry.offset); err != nil {
+		return nil, fmt.Errorf("%s: %w", index.files[entry.file], err)
+	}
+	xorAt(data, index.key, entry.offset)
+
+	return blocks.FromReader(bytes.NewReader(data))
+}
+
+func (index *Index) openFile(fileNumber int) (*os.File, error) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	if file, ok := index.openFiles[fileNumber]; ok {
+		return file, nil
+	}
+
+	file, err := os.Open(index.files[fileNumber])
+	if err != nil {
+		return nil, err
+	}
+	index.openFiles[fileNumber] = file
+	return file, nil
+}
+
+// StreamBlocks returns a function which returns the blocks of the index's chain in ascending
+// height order, from fromHeight to toHeight inclusive. It returns two nil values once all blocks
+// have been returned. Blocks are read ahead in a separate goroutine, which stops if ctx is canceled.
+// The returned function can be used as a blockscan.NextBlockFunc.
+func (index *Index) StreamBlocks(ctx context.Context, fromHeight, toHeight uint32) func() (*blocks.Block, error) {
+	blockQueue := make(chan *blocks.Block, 16)
+	errorQueue := make(chan error, 1)
+
+	go func() {
+		defer close(blockQueue)
+
+		for height := fromHeight; height <= toHeight; height++ {
+			block, err := index.ReadBlock(height)
+			if err != nil {
+				errorQueue <- err
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				errorQueue <- ctx.Err()
+				return
+			case blockQueue <- block:
+			}
+
+			if height == ^uint32(0) {
+				return
+			}
+		}
+	}()
+
+	return func() (*blocks.Block, error) {
+		block, more := <-blockQueue
+		if !more {
+			select {
+			case err := <-errorQueue:
+				return nil, err
+			default:
+				return nil, nil
+			}
+		}
+		return block, nil
+	}
+}
+
+// ReadUndo reads the undo data of the block at the given height from the rev*.dat file which
+// corresponds to the block's blk*.dat file. The genesis block has no undo data, so an empty
+// BlockUndo is returned for height zero. Returns ErrUndoNotFound if the node has not connected
+// the block, or its undo data has been pruned.
+//
+// Undo records do not identify their blocks, so the first call for each rev*.dat file reads the
+// location of every record, and records are matched to blocks by their checksums. Records are
+// usually stored in height order, so reading the undo data of consecutive blocks is fast. The
+// block is also read, to check that the undo data matches its inputs.
+func (index *Index) ReadUndo(height uint32) (*BlockUndo, error) {
+	if int(height) >= len(index.chain) {
+		return nil, ErrHeightOutOfRange
+	} else if height == 0 {
+		return new(BlockUndo), nil
+	}
+	entry := index.chain[height]
+
+	block, err := index.ReadBlock(height)
+	if err != nil {
+		return nil, err
+	}
+
+	index.undoMutex.Lock()
+	defer index.undoMutex.Unlock()
+
+	uf, err := index.openUndoFile(entry.file)
+	if err != nil {
+		return nil, err
+	}
+
+	if location, ok := uf.byBlock[entry.hash]; ok {
+		data, err := index.readUndoData(uf, location)
+		if err != nil {
+			return nil, err
+		}
+		return UndoFromBytes(data)
+	}
+
+	for n := 0; n < len(uf.records); n++ {
+		i := (uf.next + n) % len(uf.records)
+		location := uf.records[i]
+		if location.matched {
+			continue
+		}
+
+		data, err := index.readUndoData(uf, location)
+		if err != nil {
+			return nil, err
+		}
+
+		if undoChecksum(entry.prevHash, data) != location.checksum {
+			continue
+		}
+
+		// Stale blocks with the same parent also match the checksum, so the
+		// undo data must match the block's inputs too.
+		undo, err := UndoFromBytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", uf.file.Name(), err)
+		} else if _, err := undo.PrevOuts(block); err != nil {
+			continue
+		}
+
+		location.matched = true
+		uf.byBlock[entry.hash] = location
+		uf.next = i + 1
+		return undo, nil
+	}
+
+	return nil, fmt.Errorf("%w: %x at height %d", ErrUndoNotFound, entry.hash, height)
+}
+
+// openUndoFile opens the rev*.dat file with the given number and reads the location of its records.
+// The caller must hold index.undoMutex.
+func (index *Index) openUndoFile(fileNumber int) (*undoFile, error) {
+	if uf, ok := index.undoFiles[fileNumber]; ok {
+		return uf, nil
+	}
+
+	dir, name := filepath.Split(index.files[fileNumber])
+	path := filepath.Join(dir, "rev"+strings.TrimPrefix(name, "blk"))
+
+	reader, file, err := OpenFile(path, index.key, index.magic)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrUndoNotFound, err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	uf := &undoFile{byBlock: make(map[[32]byte]*undoLocation)}
+	for {
+		record, err := reader.NextUndoRecord()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// The node may still be writing the last record.
+			break
+		} else if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		uf.records = append(uf.records, &undoLocation{
+			offset:   record.Offset,
+			size:     uint32(len(record.Data)),
+			checksum: record.Checksum,
+		})
+	}
+	file.Close()
+
+	// Reopen the file for random access, since OpenFile reads through a buffer.
+	if uf.file, err = os.Open(path); err != nil {
+		return nil, err
+	}
+	index.undoFiles[fileNumber] = uf
+	return uf, nil
+}
+
+func (index *Index) readUndoData(uf *undoFile, location *undoLocation) ([]byte, error) {
+	data := make([]byte, location.size)
+	if _, err := uf.file.ReadAt(data, location.offset); err != nil {
+		return nil, fmt.Errorf("%s: %w", uf.file.Name(), err)
+	}
+	xorAt(data, index.key, location.offset)
+	return data, nil
+}
+
+// Close closes the block and undo files opened by ReadBlock and ReadUndo.
+func (index *Index) Close() error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	var firstErr error
+	for fileNumber, file := range index.openFiles {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(index.openFiles, fileNumber)
+	}
+
+	index.undoMutex.Lock()
+	defer index.undoMutex.Unlock()
+
+	for fileNumber, uf := range index.undoFiles {
+		if err := uf.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(index.undoFiles, fileNumber)
+	}
+	return firstErr
+}
@@ -0,0 +1,194 @@
+// Package blockfile reads blocks directly from the blk*.dat files in a Bitcoin Core data directory,
+// which is much faster than fetching them over RPC when scanning the whole chain.
+package blockfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+)
+
+// recordHeaderSize is the size of the network magic and length which precede each block in a file.
+const recordHeaderSize = 8
+
+// maxBlockSize bounds the length prefix of a block record. It is the maximum serialized size of a
+// block, which is the same as the maximum block weight.
+const maxBlockSize = 4000000
+
+var (
+	// ErrInvalidMagic is returned if a record in a block file begins with the wrong network magic.
+	ErrInvalidMagic = errors.New("block file record has incorrect network magic")
+
+	// ErrInvalidRecord is returned if a record in a block file has an invalid length.
+	ErrInvalidRecord = errors.New("block file record is not formatted correctly")
+)
+
+// Reader reads records sequentially from a single blk*.dat or rev*.dat file.
+type Reader struct {
+	reader io.Reader
+	key    [XORKeySize]byte
+	magic  uint32
+	offset int64
+}
+
+// NewReader returns a Reader which reads records from reader, which must not be obfuscated.
+// magic is the network magic, such as wire.MagicMainnet. Use NewObfuscatedReader to read
+// files obfuscated with a key from xor.dat.
+func NewReader(reader io.Reader, magic uint32) *Reader {
+	return &Reader{reader: reader, magic: magic}
+}
+
+// NewObfuscatedReader returns a Reader which reads records from reader, de-obfuscating them
+// with the given key. reader must start reading at the beginning of the file.
+func NewObfuscatedReader(reader io.Reader, key [XORKeySize]byte, magic uint32) *Reader {
+	return &Reader{reader: NewXORReader(reader, key, 0), key: key, magic: magic}
+}
+
+// Offset returns the position in the file of the next record.
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
+// nextRecord reads the magic and length of the next record, which must be at least minSize.
+// It returns io.EOF at the end of the file, or when it reaches the zero padding which Bitcoin
+// Core preallocates after the last record. Bitcoin Core does not obfuscate the padding, so it is
+// detected before de-obfuscating the record header, but padding which was obfuscated along
+// with the records is also accepted.
+func (r *Reader) nextRecord(minSize uint32) (uint32, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r.reader, header[:]); err == io.ErrUnexpectedEOF {
+		return 0, io.EOF
+	} else if err != nil {
+		return 0, err
+	}
+
+	// XORing again with the key recovers the bytes as they are in the file.
+	raw := header
+	xorAt(raw[:], r.key, r.offset)
+	r.offset += recordHeaderSize
+
+	magic := binary.LittleEndian.Uint32(header[:4])
+	size := binary.LittleEndian.Uint32(header[4:])
+
+	if binary.LittleEndian.Uint32(raw[:4]) == 0 || magic == 0 {
+		return 0, io.EOF
+	} else if magic != r.magic {
+		return 0, fmt.Errorf("%w: %08x at offset %d", ErrInvalidMagic, magic, r.offset-recordHeaderSize)
+	} else if size < minSize || size > maxBlockSize {
+		return 0, fmt.Errorf("%w: record size %d at offset %d", ErrInvalidRecord, size, r.offset-recordHeaderSize)
+	}
+
+	return size, nil
+}
+
+// NextBlock reads and decodes the next block in the file. It returns io.EOF when no blocks remain.
+func (r *Reader) NextBlock() (*blocks.Block, error) {
+	size, err := r.nextRecord(blockheader.BlockHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.reader, data); err != nil {
+		return nil, truncated(err)
+	}
+	r.offset += int64(size)
+
+	return blocks.FromReader(bytes.NewReader(data))
+}
+
+// NextHeader reads the header of the next block in the file, and skips the rest of the block.
+// It also returns the offset of the block data in the file, and its size. It returns io.EOF
+// when no blocks remain.
+func (r *Reader) NextHeader() (header *blockheader.BlockHeader, offset int64, size uint32, err error) {
+	size, err = r.nextRecord(blockheader.BlockHeaderSize)
+	if err != nil {
+		return
+	}
+	offset = r.offset
+
+	header, err = blockheader.FromReader(io.LimitReader(r.reader, blockheader.BlockHeaderSize))
+	if err != nil {
+		err = truncated(err)
+		return
+	}
+
+	if _, err = io.CopyN(io.Discard, r.reader, int64(size)-blockheader.BlockHeaderSize); err != nil {
+		err = truncated(err)
+		return
+	}
+
+	r.offset += int64(size)
+	return
+}
+
+// truncated converts errors caused by a record which was cut short, usually because the node was
+// still writing it, into io.ErrUnexpectedEOF.
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, blockheader.ErrInvalidFormat) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// BlockFiles returns the paths of the blk*.dat files in the given blocks directory, in order.
+func BlockFiles(blocksDir string) ([]string, error) {
+	return numberedFiles(blocksDir, "blk")
+}
+
+func numberedFiles(blocksDir, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(blocksDir)
+	if err != nil {
+		return nil, err
+	}
+
+	type numberedFile struct {
+		number int
+		path   string
+	}
+
+	var files []numberedFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".dat") {
+			continue
+		}
+
+		number, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".dat"))
+		if err != nil {
+			continue
+		}
+		files = append(files, numberedFile{number, filepath.Join(blocksDir, name)})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].number < files[j].number })
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.path
+	}
+	return paths, nil
+}
+
+// OpenFile opens the blk*.dat or rev*.dat file at the given path, de-obfuscating it with the given key, and
+// returns a Reader for it along with the file, which the caller must close.
+func OpenFile(path string, key [XORKeySize]byte, magic uint32) (*Reader, *os.File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader := NewObfuscatedReader(bufio.NewReaderSize(file, 1<<20), key, magic)
+	return reader, file, nil
+}
@@ -0,0 +1,304 @@
+package blockfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/kklash/bitcoinlib/bhash"
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/common"
+	"github.com/kklash/bitcoinlib/feecalc"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/varint"
+)
+
+// checksumSize is the size of the checksum which follows the undo data of each record in a rev*.dat file.
+const checksumSize = 32
+
+var (
+	// ErrUndoMismatch is returned when undo data does not match the inputs of a block.
+	ErrUndoMismatch = errors.New("undo data does not match block")
+
+	// ErrUndoNotFound is returned by Index.ReadUndo if the undo data for a block cannot
+	// be found in the corresponding rev*.dat file.
+	ErrUndoNotFound = errors.New("undo data not found for block")
+)
+
+// SpentOutput is a previous output spent by a transaction input, as recorded in Bitcoin Core's undo data.
+type SpentOutput struct {
+	// Height is the height of the block which created the output.
+	Height uint32
+
+	// Coinbase is true if the output was created by a coinbase transaction.
+	Coinbase bool
+
+	// Output is the spent output.
+	Output *tx.Output
+}
+
+// BlockUndo holds the outputs spent by a block, which Bitcoin Core stores in its rev*.dat
+// files so that it can disconnect the block during a reorg. Txs has one entry for each
+// transaction in the block except the coinbase, which itself holds one SpentOutput for
+// each of the transaction's inputs, in order.
+type BlockUndo struct {
+	Txs [][]*SpentOutput
+}
+
+// UndoFromBytes decodes serialized undo data for a block, as stored in rev*.dat files.
+func UndoFromBytes(data []byte) (*BlockUndo, error) {
+	reader := bytes.NewReader(data)
+
+	nTxs, err := readCount(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	undo := &BlockUndo{Txs: make([][]*SpentOutput, nTxs)}
+	for i := range undo.Txs {
+		nInputs, err := readCount(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		spent := make([]*SpentOutput, nInputs)
+		for j := range spent {
+			if spent[j], err = readSpentOutput(reader); err != nil {
+				return nil, fmt.Errorf("%w: tx %d input %d: %s", ErrInvalidUndo, i+1, j, err)
+			}
+		}
+		undo.Txs[i] = spent
+	}
+
+	if reader.Len() != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidUndo, reader.Len())
+	}
+
+	return undo, nil
+}
+
+// readCount reads a CompactSize length prefix, ensuring it cannot exceed the remaining data.
+// Each item counted takes at least one byte.
+func readCount(reader *bytes.Reader) (int, error) {
+	count, err := varint.FromReader(reader)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidUndo, err)
+	} else if uint64(count) > uint64(reader.Len()) {
+		return 0, fmt.Errorf("%w: count %d exceeds data size", ErrInvalidUndo, count)
+	}
+	return int(count), nil
+}
+
+func readSpentOutput(reader *bytes.Reader) (*SpentOutput, error) {
+	code, err := readVarInt(reader)
+	if err != nil {
+		return nil, err
+	} else if code>>1 > uint64(^uint32(0)) {
+		return nil, fmt.Errorf("height %d out of range", code>>1)
+	}
+
+	spent := &SpentOutput{
+		Height:   uint32(code >> 1),
+		Coinbase: code&1 == 1,
+	}
+
+	// Undo data written by old versions of Bitcoin Core includes the transaction
+	// version after the height. It is no longer used.
+	if spent.Height > 0 {
+		if _, err := readVarInt(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	amount, err := readVarInt(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	scriptPubKey, err := readCompressedScript(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	spent.Output = &tx.Output{Value: decompressAmount(amount), Script: scriptPubKey}
+	return spent, nil
+}
+
+// Bytes serializes the undo data in the format used by rev*.dat files.
+func (undo *BlockUndo) Bytes() []byte {
+	buf := varint.VarInt(len(undo.Txs)).Bytes()
+	for _, spentOutputs := range undo.Txs {
+		buf = append(buf, varint.VarInt(len(spentOutputs)).Bytes()...)
+
+		for _, spent := range spentOutputs {
+			code := uint64(spent.Height) << 1
+			if spent.Coinbase {
+				code |= 1
+			}
+			buf = appendVarInt(buf, code)
+			if spent.Height > 0 {
+				buf = appendVarInt(buf, 0)
+			}
+
+			buf = appendVarInt(buf, compressAmount(spent.Output.Value))
+			buf = appendCompressedScript(buf, spent.Output.Script)
+		}
+	}
+	return buf
+}
+
+// WriteTo writes the serialized undo data to w.
+func (undo *BlockUndo) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(undo.Bytes())
+	return int64(n), err
+}
+
+// PrevOuts pairs the outputs in the undo data with the inputs of the given block, which spend
+// them. It returns a map of each input's previous output to the output it spends. Returns
+// ErrUndoMismatch if the shape of the undo data does not match the block's transactions.
+func (undo *BlockUndo) PrevOuts(block *blocks.Block) (map[tx.PrevOut]*SpentOutput, error) {
+	if len(block.Transactions) == 0 || len(undo.Txs) != len(block.Transactions)-1 {
+		return nil, fmt.Errorf(
+			"%w: undo data has %d transactions, block has %d non-coinbase transactions",
+			ErrUndoMismatch, len(undo.Txs), len(block.Transactions)-1,
+		)
+	}
+
+	prevOuts := make(map[tx.PrevOut]*SpentOutput)
+	for i, txn := range block.Transactions[1:] {
+		if len(undo.Txs[i]) != len(txn.Inputs) {
+			return nil, fmt.Errorf(
+				"%w: undo data has %d outputs for tx %d, which has %d inputs",
+				ErrUndoMismatch, len(undo.Txs[i]), i+1, len(txn.Inputs),
+			)
+		}
+
+		for j, input := range txn.Inputs {
+			prevOuts[*input.PrevOut] = undo.Txs[i][j]
+		}
+	}
+
+	return prevOuts, nil
+}
+
+// PrevOutFunc returns a feecalc.PrevOutFunc which looks up the outputs spent by the
+// given block. It can be used as blocks.ValidationOptions.PrevOut.
+func (undo *BlockUndo) PrevOutFunc(block *blocks.Block) (feecalc.PrevOutFunc, error) {
+	prevOuts, err := undo.PrevOuts(block)
+	if err != nil {
+		return nil, err
+	}
+
+	getPrevOut := func(prevOut *tx.PrevOut) (*tx.Output, error) {
+		spent, ok := prevOuts[*prevOut]
+		if !ok {
+			return nil, fmt.Errorf(
+				"%w: %x:%d not spent by block",
+				feecalc.ErrPrevOutNotFound, common.ReverseBytes(prevOut.Hash[:]), prevOut.Index,
+			)
+		}
+		return spent.Output, nil
+	}
+	return getPrevOut, nil
+}
+
+// PrevCoinFunc returns a function which looks up the outputs spent by the given block, along
+// with the heights at which they were created. It can be used as blocks.ValidationOptions.PrevCoin,
+// so that coinbase maturity is checked.
+func (undo *BlockUndo) PrevCoinFunc(block *blocks.Block) (func(*tx.PrevOut) (*blocks.Coin, error), error) {
+	prevOuts, err := undo.PrevOuts(block)
+	if err != nil {
+		return nil, err
+	}
+
+	getPrevCoin := func(prevOut *tx.PrevOut) (*blocks.Coin, error) {
+		spent, ok := prevOuts[*prevOut]
+		if !ok {
+			return nil, fmt.Errorf(
+				"%w: %x:%d not spent by block",
+				feecalc.ErrPrevOutNotFound, common.ReverseBytes(prevOut.Hash[:]), prevOut.Index,
+			)
+		}
+		return &blocks.Coin{Output: spent.Output, Height: int(spent.Height), Coinbase: spent.Coinbase}, nil
+	}
+	return getPrevCoin, nil
+}
+
+// PrevOutValueFunc returns a feecalc.PrevOutValueFunc which looks up the values of outputs
+// spent by the given block, which can be used to compute fees for the block's transactions.
+func (undo *BlockUndo) PrevOutValueFunc(block *blocks.Block) (feecalc.PrevOutValueFunc, error) {
+	getPrevOut, err := undo.PrevOutFunc(block)
+	if err != nil {
+		return nil, err
+	}
+	return getPrevOut.ValueFunc(), nil
+}
+
+// PrevOutScripts returns the output scripts of every output spent by the block, in the
+// order of the block's inputs. These can be passed to gcs.BuildBasicFilter.
+func (undo *BlockUndo) PrevOutScripts() [][]byte {
+	var scripts [][]byte
+	for _, spentOutputs := range undo.Txs {
+		for _, spent := range spentOutputs {
+			scripts = append(scripts, spent.Output.Script)
+		}
+	}
+	return scripts
+}
+
+// UndoRecord is a record read from a rev*.dat file, which holds the serialized
+// undo data for one block.
+type UndoRecord struct {
+	// Offset is the position of the undo data in the file.
+	Offset int64
+
+	// Data is the serialized undo data, which can be decoded with UndoFromBytes.
+	Data []byte
+
+	// Checksum commits to the undo data and the hash of the previous block.
+	Checksum [checksumSize]byte
+}
+
+// undoChecksum computes the checksum which Bitcoin Core stores after undo data. prevHash is the
+// hash of the parent of the block which the undo data belongs to, in RPC byte order.
+func undoChecksum(prevHash [32]byte, data []byte) [32]byte {
+	buf := make([]byte, 0, 32+len(data))
+	buf = append(buf, common.ReverseBytes(prevHash[:])...)
+	buf = append(buf, data...)
+	return bhash.DoubleSha256(buf)
+}
+
+// Verify returns true if the record's checksum is valid for a block whose previous header
+// hash is prevHash, in RPC byte order. Since undo records do not say which block they belong
+// to, this is how they are matched with blocks.
+func (record *UndoRecord) Verify(prevHash [32]byte) bool {
+	return undoChecksum(prevHash, record.Data) == record.Checksum
+}
+
+// Undo decodes the record's undo data.
+func (record *UndoRecord) Undo() (*BlockUndo, error) {
+	return UndoFromBytes(record.Data)
+}
+
+// NextUndoRecord reads the next record from a rev*.dat file. It returns io.EOF when no records remain.
+func (r *Reader) NextUndoRecord() (*UndoRecord, error) {
+	size, err := r.nextRecord(1)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &UndoRecord{
+		Offset: r.offset,
+		Data:   make([]byte, size),
+	}
+	if _, err := io.ReadFull(r.reader, record.Data); err != nil {
+		return nil, truncated(err)
+	}
+	if _, err := io.ReadFull(r.reader, record.Checksum[:]); err != nil {
+		return nil, truncated(err)
+	}
+	r.offset += int64(size) + checksumSize
+
+	return record, nil
+}
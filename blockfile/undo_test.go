@@ -0,0 +1,346 @@
+package blockfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/ecc"
+	"github.com/kklash/bitcoinlib/feecalc"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+func hex2bytes(h string) []byte {
+	b, _ := hex.DecodeString(h)
+	return b
+}
+
+func TestVarInt(t *testing.T) {
+	// Test vectors from Bitcoin Core's serialize_tests.
+	fixtures := []struct {
+		n       uint64
+		encoded string
+	}{
+		{0, "00"},
+		{0x7f, "7f"},
+		{0x80, "8000"},
+		{0x1234, "a334"},
+		{0xffff, "82fe7f"},
+		{0x123456, "c7e756"},
+		{0x80123456, "86ffc7e756"},
+		{0xffffffff, "8efefefe7f"},
+		{^uint64(0), "80fefefefefefefefe7f"},
+	}
+
+	for _, fixture := range fixtures {
+		encoded := hex.EncodeToString(appendVarInt(nil, fixture.n))
+		if encoded != fixture.encoded {
+			t.Errorf("failed to encode varint %d\nWanted %s\nGot    %s", fixture.n, fixture.encoded, encoded)
+			continue
+		}
+
+		n, err := readVarInt(bytes.NewReader(hex2bytes(fixture.encoded)))
+		if err != nil {
+			t.Errorf("failed to decode varint %s: %s", fixture.encoded, err)
+			continue
+		} else if n != fixture.n {
+			t.Errorf("failed to decode varint %s\nWanted %d\nGot    %d", fixture.encoded, fixture.n, n)
+			continue
+		}
+	}
+
+	if _, err := readVarInt(bytes.NewReader(hex2bytes("81fefefefefefefefe7f"))); !errors.Is(err, ErrInvalidUndo) {
+		t.Errorf("expected ErrInvalidUndo for overflowing varint, got %v", err)
+		return
+	}
+}
+
+func TestCompressAmount(t *testing.T) {
+	// Test vectors from Bitcoin Core's compress_tests.
+	fixtures := []struct {
+		amount     uint64
+		compressed uint64
+	}{
+		{0, 0x0},
+		{1, 0x1},
+		{1000000, 0x7},
+		{100000000, 0x9},
+		{5000000000, 0x32},
+		{2100000000000000, 0x1406f40},
+	}
+
+	for _, fixture := range fixtures {
+		if compressed := compressAmount(fixture.amount); compressed != fixture.compressed {
+			t.Errorf("failed to compress %d\nWanted %#x\nGot    %#x", fixture.amount, fixture.compressed, compressed)
+			continue
+		}
+		if amount := decompressAmount(fixture.compressed); amount != fixture.amount {
+			t.Errorf("failed to decompress %#x\nWanted %d\nGot    %d", fixture.compressed, fixture.amount, amount)
+			continue
+		}
+	}
+
+	for amount := uint64(0); amount < 100000; amount++ {
+		if decompressAmount(compressAmount(amount)) != amount {
+			t.Errorf("amount %d does not survive compression", amount)
+			return
+		}
+	}
+}
+
+func TestCompressedScript(t *testing.T) {
+	privateKey := bytes.Repeat([]byte{0x11}, 32)
+
+	fixtures := []struct {
+		name           string
+		scriptPubKey   []byte
+		compressedSize int
+	}{
+		{"p2pkh", hex2bytes("76a914c41c836560406c6169537f9dc9520184879f03e288ac"), 21},
+		{"p2sh", hex2bytes("a914aefdfa5219b0ecda7f0f75a2221111769dc848c187"), 21},
+		{"p2pk compressed", makeP2PK(ecc.GetPublicKeyCompressed(privateKey)), 33},
+		{"p2pk uncompressed", makeP2PK(ecc.GetPublicKeyUncompressed(privateKey)), 33},
+		{"p2wpkh", hex2bytes("0014751e76e8199196d454941c45d1b3a323f1433bd6"), 23},
+		{"empty", []byte{}, 1},
+	}
+
+	for _, fixture := range fixtures {
+		compressed := appendCompressedScript(nil, fixture.scriptPubKey)
+		if len(compressed) != fixture.compressedSize {
+			t.Errorf("%s: incorrect compressed size\nWanted %d\nGot    %d", fixture.name, fixture.compressedSize, len(compressed))
+			continue
+		}
+
+		scriptPubKey, err := readCompressedScript(bytes.NewReader(compressed))
+		if err != nil {
+			t.Errorf("%s: failed to decompress script: %s", fixture.name, err)
+			continue
+		} else if !bytes.Equal(scriptPubKey, fixture.scriptPubKey) {
+			t.Errorf("%s: script does not survive compression\nWanted %x\nGot    %x", fixture.name, fixture.scriptPubKey, scriptPubKey)
+			continue
+		}
+	}
+
+	// An uncompressed key which is not on the curve is stored in full.
+	invalidP2PK := makeP2PK(append([]byte{0x04}, bytes.Repeat([]byte{0x01}, 64)...))
+	if compressed := appendCompressedScript(nil, invalidP2PK); len(compressed) != len(invalidP2PK)+1 {
+		t.Errorf("expected invalid P2PK script to be stored in full, got %x", compressed)
+		return
+	}
+}
+
+func TestUndoFromBytes(t *testing.T) {
+	// A P2PKH coinbase output worth 50 BTC created at height 100. Old versions of Bitcoin
+	// Core stored the transaction version after the height, which must be ignored.
+	data := hex2bytes("0101804901320062e907b15cbf27d5425399ebf6f0fb50ebb88f18")
+
+	undo, err := UndoFromBytes(data)
+	if err != nil {
+		t.Errorf("failed to decode undo data: %s", err)
+		return
+	}
+
+	expected := &BlockUndo{
+		Txs: [][]*SpentOutput{{
+			{
+				Height:   100,
+				Coinbase: true,
+				Output: &tx.Output{
+					Value:  5000000000,
+					Script: hex2bytes("76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac"),
+				},
+			},
+		}},
+	}
+
+	if !reflect.DeepEqual(undo, expected) {
+		t.Errorf("decoded undo data does not match expected")
+		return
+	}
+
+	if encoded := undo.Bytes(); !bytes.Equal(encoded, hex2bytes("0101804900320062e907b15cbf27d5425399ebf6f0fb50ebb88f18")) {
+		t.Errorf("failed to encode undo data: %x", encoded)
+		return
+	}
+
+	invalid := [][]byte{
+		{},
+		{0x01},
+		hex2bytes("ff0000000000000001"),
+		data[:len(data)-1],
+		append(data, 0x00),
+	}
+	for _, buf := range invalid {
+		if _, err := UndoFromBytes(buf); !errors.Is(err, ErrInvalidUndo) {
+			t.Errorf("expected ErrInvalidUndo decoding %x, got %v", buf, err)
+			continue
+		}
+	}
+}
+
+// spend returns a transaction spending the given outputs of txn.
+func spend(t *testing.T, txn *tx.Tx, value uint64, indexes ...uint32) *tx.Tx {
+	hash, err := txn.Hash(false)
+	if err != nil {
+		t.Fatalf("failed to hash tx: %s", err)
+	}
+
+	spender := &tx.Tx{
+		Version: 2,
+		Outputs: []*tx.Output{{Value: value, Script: hex2bytes("0014751e76e8199196d454941c45d1b3a323f1433bd6")}},
+	}
+	for _, index := range indexes {
+		spender.Inputs = append(spender.Inputs, &tx.Input{
+			PrevOut:  &tx.PrevOut{Hash: hash, Index: index},
+			Script:   []byte{},
+			Sequence: 0xffffffff,
+		})
+	}
+	return spender
+}
+
+func undoRecord(prevBlock, block *blocks.Block, undo *BlockUndo) []byte {
+	prevHash, _ := prevBlock.Header.Hash()
+	data := undo.Bytes()
+	checksum := undoChecksum(prevHash, data)
+
+	header := make([]byte, recordHeaderSize)
+	binary.LittleEndian.PutUint32(header, testMagic)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(append(header, data...), checksum[:]...)
+}
+
+func TestIndex_ReadUndo(t *testing.T) {
+	blocksDir := t.TempDir()
+	key := [XORKeySize]byte{0xde, 0xad, 0xbe, 0xef, 0x01, 0x02, 0x03, 0x04}
+	if err := os.WriteFile(filepath.Join(blocksDir, "xor.dat"), key[:], 0o600); err != nil {
+		t.Fatalf("failed to write xor.dat: %s", err)
+	}
+
+	chain := makeChain(t, nil, 4, 0)
+
+	// Block 2 spends the coinbase output of block 1, and block 3 spends that spend.
+	coinbase := chain[1].Transactions[0]
+	spend1 := spend(t, coinbase, 4999990000, 0)
+	spend2 := spend(t, spend1, 4999980000, 0)
+	chain[2].Transactions = append(chain[2].Transactions, spend1)
+	chain[3].Transactions = append(chain[3].Transactions, spend2)
+	for i := 3; i < len(chain); i++ {
+		chain[i].Header.PreviousHeaderHash = blockHashes(t, chain[i-1:i])[0]
+	}
+
+	undos := []*BlockUndo{
+		{},
+		{},
+		{Txs: [][]*SpentOutput{{{Height: 1, Coinbase: true, Output: coinbase.Outputs[0]}}}},
+		{Txs: [][]*SpentOutput{{{Height: 2, Output: spend1.Outputs[0]}}}},
+	}
+
+	// A stale block's undo data, which matches no block in the chain.
+	stale := makeBlock(t, chain[1], 7)
+
+	var revData []byte
+	revData = append(revData, undoRecord(chain[0], chain[1], undos[1])...)
+	revData = append(revData, undoRecord(chain[2], chain[3], undos[3])...)
+	revData = append(revData, undoRecord(chain[1], stale, new(BlockUndo))...)
+	revData = append(revData, undoRecord(chain[1], chain[2], undos[2])...)
+	xorAt(revData, key, 0)
+	revData = append(revData, make([]byte, 64)...)
+
+	writePaddedBlockFile(t, filepath.Join(blocksDir, "blk00000.dat"), key, nil, 64, chain...)
+	if err := os.WriteFile(filepath.Join(blocksDir, "rev00000.dat"), revData, 0o600); err != nil {
+		t.Fatalf("failed to write rev file: %s", err)
+	}
+
+	index, err := BuildIndex(blocksDir, testMagic)
+	if err != nil {
+		t.Errorf("failed to build index: %s", err)
+		return
+	}
+	defer index.Close()
+
+	// Read twice to check previously matched records are found again.
+	for _, height := range []uint32{0, 1, 2, 3, 3, 2, 1} {
+		undo, err := index.ReadUndo(height)
+		if err != nil {
+			t.Errorf("failed to read undo data at height %d: %s", height, err)
+			return
+		}
+
+		if !reflect.DeepEqual(undo.Bytes(), undos[height].Bytes()) {
+			t.Errorf("incorrect undo data at height %d", height)
+			return
+		}
+
+		block, err := index.ReadBlock(height)
+		if err != nil {
+			t.Errorf("failed to read block at height %d: %s", height, err)
+			return
+		}
+
+		getPrevOutValue, err := undo.PrevOutValueFunc(block)
+		if err != nil {
+			t.Errorf("failed to pair undo data with block %d: %s", height, err)
+			return
+		}
+
+		fees, err := feecalc.TotalFeesForBlock(block, getPrevOutValue)
+		if err != nil {
+			t.Errorf("failed to compute fees for block %d: %s", height, err)
+			return
+		} else if height >= 2 && fees != 10000 {
+			t.Errorf("incorrect fees for block %d\nWanted 10000\nGot    %d", height, fees)
+			return
+		}
+	}
+
+	getPrevOutValue, err := undos[2].PrevOutValueFunc(chain[2])
+	if err != nil {
+		t.Errorf("failed to pair undo data with block: %s", err)
+		return
+	} else if _, err := getPrevOutValue(spend2.Inputs[0].PrevOut); !errors.Is(err, feecalc.ErrPrevOutNotFound) {
+		t.Errorf("expected ErrPrevOutNotFound, got %v", err)
+		return
+	}
+
+	getPrevCoin, err := undos[2].PrevCoinFunc(chain[2])
+	if err != nil {
+		t.Errorf("failed to pair undo data with block: %s", err)
+		return
+	}
+	expectedCoin := &blocks.Coin{Output: coinbase.Outputs[0], Height: 1, Coinbase: true}
+	if coin, err := getPrevCoin(spend1.Inputs[0].PrevOut); err != nil {
+		t.Errorf("failed to get spent coin: %s", err)
+		return
+	} else if !reflect.DeepEqual(coin, expectedCoin) {
+		t.Errorf("incorrect spent coin\nWanted %+v\nGot    %+v", expectedCoin, coin)
+		return
+	}
+
+	if _, err := undos[2].PrevOuts(chain[1]); !errors.Is(err, ErrUndoMismatch) {
+		t.Errorf("expected ErrUndoMismatch, got %v", err)
+		return
+	}
+
+	// Block 4 is not connected, so it has no undo data.
+	chain = append(chain, makeBlock(t, chain[3], 0))
+	writeBlockFile(t, filepath.Join(blocksDir, "blk00000.dat"), key, nil, chain...)
+
+	index, err = BuildIndex(blocksDir, testMagic)
+	if err != nil {
+		t.Errorf("failed to build index: %s", err)
+		return
+	}
+	defer index.Close()
+
+	if _, err := index.ReadUndo(4); !errors.Is(err, ErrUndoNotFound) {
+		t.Errorf("expected ErrUndoNotFound, got %v", err)
+		return
+	}
+}
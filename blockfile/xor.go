@@ -0,0 +1,64 @@
+package blockfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// XORKeySize is the size of the key used to obfuscate block and undo files.
+const XORKeySize = 8
+
+// ErrInvalidXORKey is returned by ReadXORKey if the xor.dat file has the wrong size.
+var ErrInvalidXORKey = errors.New("xor.dat key has incorrect size")
+
+// ReadXORKey reads the key from the xor.dat file in the given blocks directory. Since version 28,
+// Bitcoin Core obfuscates new blk*.dat and rev*.dat files by XORing them with this key. If
+// the file does not exist, the files are not obfuscated, and the all-zero key is returned.
+func ReadXORKey(blocksDir string) ([XORKeySize]byte, error) {
+	var key [XORKeySize]byte
+
+	data, err := os.ReadFile(filepath.Join(blocksDir, "xor.dat"))
+	if errors.Is(err, os.ErrNotExist) {
+		return key, nil
+	} else if err != nil {
+		return key, err
+	} else if len(data) != XORKeySize {
+		return key, fmt.Errorf("%w: %d bytes", ErrInvalidXORKey, len(data))
+	}
+
+	copy(key[:], data)
+	return key, nil
+}
+
+// xorAt XORs buf with the key, as if buf were read from the given offset in an obfuscated file.
+func xorAt(buf []byte, key [XORKeySize]byte, offset int64) {
+	if key == ([XORKeySize]byte{}) {
+		return
+	}
+
+	for i := range buf {
+		buf[i] ^= key[(offset+int64(i))%XORKeySize]
+	}
+}
+
+type xorReader struct {
+	reader io.Reader
+	key    [XORKeySize]byte
+	offset int64
+}
+
+// NewXORReader returns a reader which de-obfuscates data read from reader using the given key.
+// offset is the position in the underlying file from which reader starts reading.
+func NewXORReader(reader io.Reader, key [XORKeySize]byte, offset int64) io.Reader {
+	return &xorReader{reader: reader, key: key, offset: offset}
+}
+
+func (xr *xorReader) Read(buf []byte) (int, error) {
+	n, err := xr.reader.Read(buf)
+	xorAt(buf[:n], xr.key, xr.offset)
+	xr.offset += int64(n)
+	return n, err
+}
@@ -130,8 +130,30 @@ func (header *BlockHeader) Bytes() []byte {
 }
 
 // TargetNBits returns the 256-bit nBits target value after decoding header.NBits from compact notation.
-func (header *BlockHeader) TargetNBits() *big.Int {
-	return calculateTargetNBits(header.NBits)
+// Returns ErrInvalidTarget if the target overflows 256 bits.
+func (header *BlockHeader) TargetNBits() (*big.Int, error) {
+	return NBitsToTarget(header.NBits)
+}
+
+// Difficulty returns the difficulty of the header's target relative to the genesis block target.
+// Returns ErrInvalidTarget if the target overflows 256 bits or is zero.
+func (header *BlockHeader) Difficulty() (float64, error) {
+	target, err := header.TargetNBits()
+	if err != nil {
+		return 0, err
+	}
+	return TargetDifficulty(target)
+}
+
+// Work returns the expected number of hashes needed to produce a header with the
+// same target as this header. The total work of a chain is the sum of the work of
+// its headers. Returns ErrInvalidTarget if the target overflows 256 bits.
+func (header *BlockHeader) Work() (*big.Int, error) {
+	target, err := header.TargetNBits()
+	if err != nil {
+		return nil, err
+	}
+	return TargetWork(target), nil
 }
 
 // Hash returns the reversed double SHA256 hash of the serialized block header,
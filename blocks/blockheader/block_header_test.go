@@ -94,7 +94,12 @@ func testBlockHeader() error {
 			return fmt.Errorf("Header hash does not match\nWanted %s\nGot    %s", fixture.Hash, headerHashHex)
 		}
 
-		if nBitsHex := fmt.Sprintf("%x", header.TargetNBits()); nBitsHex != fixture.TargetNBits {
+		target, err := header.TargetNBits()
+		if err != nil {
+			return fmt.Errorf("Failed to decode target NBits: %s", err)
+		}
+
+		if nBitsHex := fmt.Sprintf("%x", target); nBitsHex != fixture.TargetNBits {
 			return fmt.Errorf("Target NBits does not match\nWanted %s\nGot    %s", fixture.TargetNBits, nBitsHex)
 		}
 
@@ -1,34 +1,108 @@
 package blockheader
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 )
 
+var (
+	// ErrInvalidTarget is returned when an nBits value encodes a target which overflows 256
+	// bits, or when calculating the difficulty of a header whose target is zero.
+	ErrInvalidTarget = errors.New("nBits does not encode a valid target")
+)
+
+// GenesisNBits is the nBits value of the genesis block, whose target is the
+// easiest allowed on mainnet. It defines a difficulty of 1.
+const GenesisNBits uint32 = 0x1d00ffff
+
+var twoTo256 = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// NBitsToTarget decodes a 256-bit target from its compact nBits notation. As in Bitcoin
+// Core, a target with the sign bit set is treated as zero, which no header hash can be
+// less than. Returns ErrInvalidTarget if the target overflows 256 bits.
+//
 // https://developer.bitcoin.org/reference/block_chain.html#target-nbits
-func calculateTargetNBits(nBits uint32) *big.Int {
-	significand := nBits & 0x00ffffff
+func NBitsToTarget(nBits uint32) (*big.Int, error) {
+	exponent := nBits >> (8 * 3)
+	significand := nBits & 0x007fffff
+
+	if significand != 0 && (exponent > 34 ||
+		(significand > 0xff && exponent > 33) ||
+		(significand > 0xffff && exponent > 32)) {
+		return nil, fmt.Errorf("%w: nBits %#08x overflows 256 bits", ErrInvalidTarget, nBits)
+	}
 
 	// Handle negative bit set in NBits significand.
 	// When parsing nBits, Bitcoin Core converts a negative target threshold into a
 	// target of zero, which the header hash can equal (in theory, at least).
-	if significand&0x800000 != 0 {
-		return big.NewInt(0)
+	if nBits&0x00800000 != 0 {
+		return big.NewInt(0), nil
 	}
 
-	exponent := nBits >> (8 * 3)
-
-	// for small exponents values, multiplying a bignum by a negative exponent will give you 1 instead of zero.
+	// For small exponent values, shifting left by a negative amount is not possible,
 	// so instead we right shift the significand to discard the unused bytes.
 	if exponent < 3 {
-		significand >>= (8 * (3 - exponent))
-	} else if exponent > 32 {
-		panic("cannot calculate target nbits; exponent overflows uint256")
+		return big.NewInt(int64(significand >> (8 * (3 - exponent)))), nil
+	}
+
+	target := big.NewInt(int64(significand))
+	return target.Lsh(target, uint(8*(exponent-3))), nil
+}
+
+// TargetToNBits encodes a target in compact nBits notation, keeping only its three most
+// significant bytes. This is the inverse of NBitsToTarget, and matches Bitcoin Core's
+// GetCompact: if the highest bit of the significand would be set, the significand is
+// shifted right by one byte so it is not mistaken for the sign bit. Negative targets
+// are encoded with the sign bit set.
+func TargetToNBits(target *big.Int) uint32 {
+	abs := new(big.Int).Abs(target)
+	exponent := uint32((abs.BitLen() + 7) / 8)
+
+	var significand uint32
+	if exponent <= 3 {
+		significand = uint32(abs.Uint64()) << (8 * (3 - exponent))
+	} else {
+		significand = uint32(abs.Rsh(abs, uint(8*(exponent-3))).Uint64())
+	}
+
+	if significand&0x00800000 != 0 {
+		significand >>= 8
+		exponent++
+	}
+
+	nBits := significand | exponent<<24
+	if target.Sign() < 0 && significand != 0 {
+		nBits |= 0x00800000
+	}
+	return nBits
+}
+
+// TargetWork returns the expected number of hashes needed to find a header hash less
+// than or equal to the given target, which is 2^256 / (target + 1). A target of zero
+// has zero work, as in Bitcoin Core, because no valid header can be found for it.
+func TargetWork(target *big.Int) *big.Int {
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
 	}
 
-	bigSignificand := big.NewInt(int64(significand))
-	bigExponent := big.NewInt(int64(exponent) - 3)
-	base := big.NewInt(256)
-	basePowExponent := new(big.Int).Exp(base, bigExponent, nil)
-	target := new(big.Int).Mul(bigSignificand, basePowExponent)
-	return target
+	denominator := new(big.Int).Add(target, big.NewInt(1))
+	return denominator.Div(twoTo256, denominator)
+}
+
+// TargetDifficulty returns the difficulty of a target, which is how many times harder
+// it is to meet than the target of the genesis block. Returns ErrInvalidTarget if
+// the target is zero or negative.
+func TargetDifficulty(target *big.Int) (float64, error) {
+	if target.Sign() <= 0 {
+		return 0, fmt.Errorf("%w: target is zero", ErrInvalidTarget)
+	}
+
+	genesisTarget, _ := NBitsToTarget(GenesisNBits)
+	difficulty, _ := new(big.Float).Quo(
+		new(big.Float).SetInt(genesisTarget),
+		new(big.Float).SetInt(target),
+	).Float64()
+
+	return difficulty, nil
 }
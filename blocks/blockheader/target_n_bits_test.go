@@ -1,8 +1,9 @@
 package blockheader
 
 import (
+	"errors"
+	"math"
 	"math/big"
-	"strings"
 	"testing"
 )
 
@@ -40,27 +41,112 @@ func TestTargetNBits(t *testing.T) {
 	for _, fixture := range fixtures {
 		expected, _ := new(big.Int).SetString(fixture.targetNBits, 0)
 
+		actual, err := NBitsToTarget(fixture.nBits)
 		if expected == nil {
-			defer func() {
-				panicValue := recover()
-				if panicValue == nil {
-					return
-				}
-
-				panicString, ok := panicValue.(string)
-				if !ok || fixture.targetNBits != "" {
-					t.Errorf("unexpected panic value: %v", panicValue)
-				} else if !strings.Contains(panicString, "exponent overflows uint256") {
-					t.Errorf("Expected overflow panic")
-				}
-			}()
+			if !errors.Is(err, ErrInvalidTarget) {
+				t.Errorf("expected ErrInvalidTarget for nBits %#08x, got %v", fixture.nBits, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("failed to decode nBits %#08x: %s", fixture.nBits, err)
+			return
 		}
 
-		actual := calculateTargetNBits(fixture.nBits)
-
-		if expected != nil && actual.Cmp(expected) != 0 {
+		if actual.Cmp(expected) != 0 {
 			t.Errorf("target NBits did not calculate correctly\nWanted 0x%x\nGot    0x%x", expected, actual)
 			return
 		}
 	}
 }
+
+func TestTargetToNBits(t *testing.T) {
+	// These fixtures are taken from Bitcoin Core's arith_uint256 tests.
+	fixtures := []struct {
+		target string
+		nBits  uint32
+	}{
+		{"0x0", 0x00000000},
+		{"0x12", 0x01120000},
+		{"-0x7e", 0x01fe0000},
+		{"0x80", 0x02008000},
+		{"0x1234", 0x02123400},
+		{"0x123456", 0x03123456},
+		{"0x12345600", 0x04123456},
+		{"-0x12345600", 0x04923456},
+		{"0x92340000", 0x05009234},
+		{"0xFFFF0000000000000000000000000000000000000000000000000000", 0x1d00ffff},
+		{"0x1234560000000000000000000000000000000000000000000000000000000000", 0x20123456},
+		{"0x123456789", 0x05012345},
+	}
+
+	for _, fixture := range fixtures {
+		target, _ := new(big.Int).SetString(fixture.target, 0)
+
+		if actual := TargetToNBits(target); actual != fixture.nBits {
+			t.Errorf("target %s encoded incorrectly\nWanted %#08x\nGot    %#08x", fixture.target, fixture.nBits, actual)
+			continue
+		}
+	}
+}
+
+func TestTargetWork(t *testing.T) {
+	fixtures := []struct {
+		nBits uint32
+		work  string
+	}{
+		// Bitcoin Core reports the chainwork of the genesis block as 0x100010001.
+		{GenesisNBits, "0x100010001"},
+		{0x207fffff, "0x2"},
+		{0x04923456, "0x0"},
+	}
+
+	for _, fixture := range fixtures {
+		header := &BlockHeader{NBits: fixture.nBits}
+		expected, _ := new(big.Int).SetString(fixture.work, 0)
+
+		work, err := header.Work()
+		if err != nil {
+			t.Errorf("failed to calculate work for nBits %#08x: %s", fixture.nBits, err)
+			continue
+		}
+
+		if work.Cmp(expected) != 0 {
+			t.Errorf("incorrect work for nBits %#08x\nWanted %s\nGot    %#x", fixture.nBits, fixture.work, work)
+			continue
+		}
+	}
+
+	if _, err := (&BlockHeader{NBits: 0xff123456}).Work(); !errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("expected ErrInvalidTarget for overflowing nBits, got %v", err)
+		return
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	fixtures := []struct {
+		nBits      uint32
+		difficulty float64
+	}{
+		{GenesisNBits, 1},
+		{0x1b0404cb, 16307.420938523983},
+		{0x170e134e, 19997335994446.113}, // Mainnet block 654894
+	}
+
+	for _, fixture := range fixtures {
+		difficulty, err := (&BlockHeader{NBits: fixture.nBits}).Difficulty()
+		if err != nil {
+			t.Errorf("failed to calculate difficulty for nBits %#08x: %s", fixture.nBits, err)
+			continue
+		}
+
+		if math.Abs(difficulty-fixture.difficulty)/fixture.difficulty > 1e-12 {
+			t.Errorf("incorrect difficulty for nBits %#08x\nWanted %f\nGot    %f", fixture.nBits, fixture.difficulty, difficulty)
+			continue
+		}
+	}
+
+	if _, err := (&BlockHeader{NBits: 0x04923456}).Difficulty(); !errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("expected ErrInvalidTarget for zero target, got %v", err)
+		return
+	}
+}
@@ -0,0 +1,246 @@
+// Package headerchain validates chains of block headers, checking proof-of-work, difficulty
+// retargeting and timestamps according to the consensus rules of a network, and selects
+// the chain with the most cumulative work.
+package headerchain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+)
+
+// MaxFutureBlockTime is how far a header's timestamp may be ahead of the current time.
+const MaxFutureBlockTime = 2 * time.Hour
+
+// MedianTimeSpan is the number of previous headers whose median time
+// a new header's timestamp must be greater than.
+const MedianTimeSpan = 11
+
+var (
+	// ErrInvalidTarget is returned if a header's nBits does not encode a valid target.
+	ErrInvalidTarget = errors.New("header has invalid proof-of-work target")
+
+	// ErrHighHash is returned if a header's hash does not meet its target.
+	ErrHighHash = errors.New("header hash does not meet proof-of-work target")
+
+	// ErrBadDifficulty is returned if a header's nBits is not the value required by
+	// the difficulty adjustment rules.
+	ErrBadDifficulty = errors.New("header has incorrect difficulty")
+
+	// ErrTimeTooOld is returned if a header's timestamp is not greater than
+	// the median time of the previous MedianTimeSpan headers.
+	ErrTimeTooOld = errors.New("header timestamp is too early")
+
+	// ErrTimeTooNew is returned if a header's timestamp is more than
+	// MaxFutureBlockTime ahead of the current time.
+	ErrTimeTooNew = errors.New("header timestamp is too far in the future")
+
+	// ErrUnknownParent is returned when adding a header whose previous header is not in the chain.
+	ErrUnknownParent = errors.New("header's previous block is unknown")
+)
+
+// Node is a validated header in a Chain.
+type Node struct {
+	Header *blockheader.BlockHeader
+	Hash   [32]byte
+	Height int
+
+	// ChainWork is the total expected number of hashes needed to produce
+	// the chain of headers from genesis up to and including this header.
+	ChainWork *big.Int
+
+	// Parent is the node of the previous header, or nil for the genesis header.
+	Parent *Node
+}
+
+// Ancestor returns the ancestor of node at the given height, or nil if height
+// is negative or greater than the node's height.
+func (node *Node) Ancestor(height int) *Node {
+	if height < 0 || height > node.Height {
+		return nil
+	}
+
+	for node.Height > height {
+		node = node.Parent
+	}
+	return node
+}
+
+// MedianTimePast returns the median timestamp of this node and up to MedianTimeSpan-1 of its ancestors.
+func (node *Node) MedianTimePast() uint32 {
+	times := make([]uint32, 0, MedianTimeSpan)
+	for ; node != nil && len(times) < MedianTimeSpan; node = node.Parent {
+		times = append(times, node.Header.Time)
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[len(times)/2]
+}
+
+// Chain is an in-memory tree of validated block headers, rooted at the genesis header.
+// It tracks the tip of the chain with the most cumulative work. Chain is not safe
+// for concurrent use.
+type Chain struct {
+	params *Params
+	nodes  map[[32]byte]*Node
+	tip    *Node
+
+	// Now returns the current time, used to reject headers with timestamps too far
+	// in the future. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewChain returns a Chain containing only the genesis header of the given network.
+func NewChain(params *Params) (*Chain, error) {
+	genesis := params.Genesis
+	hash, err := genesis.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := decodeTarget(genesis.NBits, params.PowLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &Node{
+		Header:    &genesis,
+		Hash:      hash,
+		Height:    0,
+		ChainWork: blockheader.TargetWork(target),
+	}
+
+	chain := &Chain{
+		params: params,
+		nodes:  map[[32]byte]*Node{hash: node},
+		tip:    node,
+		Now:    time.Now,
+	}
+	return chain, nil
+}
+
+// Params returns the consensus parameters of the chain.
+func (chain *Chain) Params() *Params {
+	return chain.params
+}
+
+// Tip returns the last node of the chain with the most cumulative work.
+// If two chains have equal work, the one seen first is preferred.
+func (chain *Chain) Tip() *Node {
+	return chain.tip
+}
+
+// Genesis returns the node of the genesis header.
+func (chain *Chain) Genesis() *Node {
+	return chain.tip.Ancestor(0)
+}
+
+// Get returns the node with the given header hash, or nil if it is not in the chain.
+func (chain *Chain) Get(hash [32]byte) *Node {
+	return chain.nodes[hash]
+}
+
+// InBestChain returns true if the node is an ancestor of, or equal to, the tip.
+func (chain *Chain) InBestChain(node *Node) bool {
+	return chain.tip.Ancestor(node.Height) == node
+}
+
+// Locator returns a block locator for the best chain, suitable for a getheaders message.
+// It lists the hashes of the last 10 headers, followed by hashes at exponentially
+// increasing distances back from the tip, always ending with the genesis hash.
+func (chain *Chain) Locator() [][32]byte {
+	var locator [][32]byte
+
+	step := 1
+	for node := chain.tip; ; {
+		locator = append(locator, node.Hash)
+		if node.Height == 0 {
+			break
+		}
+
+		height := node.Height - step
+		if height < 0 {
+			height = 0
+		}
+		node = node.Ancestor(height)
+
+		if len(locator) > 10 {
+			step *= 2
+		}
+	}
+
+	return locator
+}
+
+// Add validates a header and adds it to the chain, returning its node. The header's
+// previous header must already be in the chain. Adding a header which is already in
+// the chain returns its existing node.
+//
+// Returns ErrUnknownParent if the previous header is unknown, ErrInvalidTarget or
+// ErrHighHash if the proof-of-work is invalid, ErrBadDifficulty if nBits is not the
+// value required by the difficulty adjustment rules, or ErrTimeTooOld or ErrTimeTooNew
+// if the timestamp is out of range.
+func (chain *Chain) Add(header *blockheader.BlockHeader) (*Node, error) {
+	hash, err := header.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	if node, ok := chain.nodes[hash]; ok {
+		return node, nil
+	}
+
+	parent, ok := chain.nodes[header.PreviousHeaderHash]
+	if !ok {
+		return nil, fmt.Errorf("%w: %x", ErrUnknownParent, header.PreviousHeaderHash)
+	}
+
+	if err := CheckProofOfWork(header, chain.params); err != nil {
+		return nil, err
+	}
+
+	if expected := nextWorkRequired(parent, header, chain.params); header.NBits != expected {
+		return nil, fmt.Errorf("%w: expected nBits %#08x, got %#08x", ErrBadDifficulty, expected, header.NBits)
+	}
+
+	if mtp := parent.MedianTimePast(); header.Time <= mtp {
+		return nil, fmt.Errorf("%w: %d <= median time past %d", ErrTimeTooOld, header.Time, mtp)
+	}
+
+	maxTime := chain.Now().Add(MaxFutureBlockTime).Unix()
+	if int64(header.Time) > maxTime {
+		return nil, fmt.Errorf("%w: %d > %d", ErrTimeTooNew, header.Time, maxTime)
+	}
+
+	// The target was already decoded and checked by CheckProofOfWork.
+	target, _ := decodeTarget(header.NBits, chain.params.PowLimit)
+
+	node := &Node{
+		Header:    header,
+		Hash:      hash,
+		Height:    parent.Height + 1,
+		ChainWork: new(big.Int).Add(parent.ChainWork, blockheader.TargetWork(target)),
+		Parent:    parent,
+	}
+
+	chain.nodes[hash] = node
+	if node.ChainWork.Cmp(chain.tip.ChainWork) > 0 {
+		chain.tip = node
+	}
+
+	return node, nil
+}
+
+// AddHeaders adds a sequence of headers to the chain, stopping at the first invalid header.
+func (chain *Chain) AddHeaders(headers []*blockheader.BlockHeader) error {
+	for i, header := range headers {
+		if _, err := chain.Add(header); err != nil {
+			return fmt.Errorf("header %d: %w", i, err)
+		}
+	}
+	return nil
+}
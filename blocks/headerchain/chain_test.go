@@ -0,0 +1,299 @@
+package headerchain
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+)
+
+const testGenesisTime = 1600000000
+
+// mine increments the header nonce until its hash meets the target encoded in nBits.
+func mine(header *blockheader.BlockHeader, params *Params) {
+	for CheckProofOfWork(header, params) != nil {
+		header.Nonce++
+	}
+}
+
+// newTestParams returns regtest-like parameters with a retarget interval of 4 blocks.
+func newTestParams() *Params {
+	params := &Params{
+		Name: "test",
+		Genesis: blockheader.BlockHeader{
+			Version: 1,
+			Time:    testGenesisTime,
+			NBits:   0x207fffff,
+		},
+		PowLimit:       RegtestParams.PowLimit,
+		TargetTimespan: 40 * time.Second,
+		TargetSpacing:  10 * time.Second,
+	}
+	mine(&params.Genesis, params)
+	return params
+}
+
+func newTestChain(t *testing.T, params *Params) *Chain {
+	chain, err := NewChain(params)
+	if err != nil {
+		t.Fatalf("failed to create chain: %s", err)
+	}
+	chain.Now = func() time.Time { return time.Unix(testGenesisTime+86400, 0) }
+	return chain
+}
+
+// nextHeader returns a mined header following parent, with the given time
+// delta from the parent and the nBits required by the chain.
+func nextHeader(chain *Chain, parent *Node, timeDelta uint32) *blockheader.BlockHeader {
+	header := &blockheader.BlockHeader{
+		Version:            1,
+		PreviousHeaderHash: parent.Hash,
+		Time:               parent.Header.Time + timeDelta,
+	}
+	header.NBits = nextWorkRequired(parent, header, chain.Params())
+	mine(header, chain.Params())
+	return header
+}
+
+// extend adds n headers to the chain following parent, each spaced by timeDelta.
+func extend(t *testing.T, chain *Chain, parent *Node, n int, timeDelta uint32) *Node {
+	for i := 0; i < n; i++ {
+		node, err := chain.Add(nextHeader(chain, parent, timeDelta))
+		if err != nil {
+			t.Fatalf("failed to add header at height %d: %s", parent.Height+1, err)
+		}
+		parent = node
+	}
+	return parent
+}
+
+func TestChain_Retarget(t *testing.T) {
+	params := newTestParams()
+	chain := newTestChain(t, params)
+
+	// Blocks arrive twice as fast as the target spacing.
+	parent := extend(t, chain, chain.Genesis(), 3, 5)
+
+	// The retarget period measures the time between blocks 0 and 3.
+	expectedTarget := new(big.Int).Mul(params.PowLimit, big.NewInt(15))
+	expectedTarget.Div(expectedTarget, big.NewInt(40))
+	expectedNBits := blockheader.TargetToNBits(expectedTarget)
+
+	header := &blockheader.BlockHeader{
+		Version:            1,
+		PreviousHeaderHash: parent.Hash,
+		Time:               parent.Header.Time + 5,
+		NBits:              parent.Header.NBits,
+	}
+	mine(header, params)
+	if _, err := chain.Add(header); !errors.Is(err, ErrBadDifficulty) {
+		t.Errorf("expected ErrBadDifficulty for header without retarget, got %v", err)
+		return
+	}
+
+	header.NBits = expectedNBits
+	mine(header, params)
+	node, err := chain.Add(header)
+	if err != nil {
+		t.Errorf("failed to add retargeted header: %s", err)
+		return
+	}
+
+	// Blocks after the retarget must keep the new difficulty.
+	tip := extend(t, chain, node, 3, 5)
+	if tip.Header.NBits != expectedNBits {
+		t.Errorf("expected difficulty to remain %#08x, got %#08x", expectedNBits, tip.Header.NBits)
+		return
+	}
+
+	// Slow blocks lower the difficulty again, but not past the proof-of-work limit.
+	tip = extend(t, chain, tip, 12, 100)
+	if tip.Header.NBits != blockheader.TargetToNBits(params.PowLimit) {
+		t.Errorf("expected difficulty to return to powLimit, got %#08x", tip.Header.NBits)
+		return
+	}
+}
+
+func TestChain_NoRetargeting(t *testing.T) {
+	params := newTestParams()
+	params.NoRetargeting = true
+	chain := newTestChain(t, params)
+
+	tip := extend(t, chain, chain.Genesis(), 9, 1)
+	if tip.Header.NBits != params.Genesis.NBits {
+		t.Errorf("expected difficulty to stay constant, got %#08x", tip.Header.NBits)
+		return
+	}
+}
+
+func TestChain_MinDifficultyBlocks(t *testing.T) {
+	params := newTestParams()
+	params.AllowMinDifficultyBlocks = true
+	chain := newTestChain(t, params)
+	powLimitNBits := blockheader.TargetToNBits(params.PowLimit)
+
+	// Make the difficulty harder than the limit.
+	retargeted := extend(t, chain, chain.Genesis(), 4, 5)
+	if retargeted.Header.NBits == powLimitNBits {
+		t.Fatalf("expected retarget to raise difficulty")
+	}
+
+	// A block more than 20 seconds after its parent may use the minimum difficulty.
+	minDifficulty := &blockheader.BlockHeader{
+		Version:            1,
+		PreviousHeaderHash: retargeted.Hash,
+		Time:               retargeted.Header.Time + 21,
+		NBits:              powLimitNBits,
+	}
+	mine(minDifficulty, params)
+	minNode, err := chain.Add(minDifficulty)
+	if err != nil {
+		t.Errorf("failed to add min-difficulty header: %s", err)
+		return
+	}
+
+	// The next block must return to the difficulty of the last regular block.
+	header := &blockheader.BlockHeader{
+		Version:            1,
+		PreviousHeaderHash: minNode.Hash,
+		Time:               minNode.Header.Time + 5,
+		NBits:              powLimitNBits,
+	}
+	mine(header, params)
+	if _, err := chain.Add(header); !errors.Is(err, ErrBadDifficulty) {
+		t.Errorf("expected ErrBadDifficulty for min-difficulty header without delay, got %v", err)
+		return
+	}
+
+	header.NBits = retargeted.Header.NBits
+	mine(header, params)
+	if _, err := chain.Add(header); err != nil {
+		t.Errorf("failed to add header after min-difficulty header: %s", err)
+		return
+	}
+
+	// Without the testnet rule, the delayed min-difficulty block is rejected.
+	params.AllowMinDifficultyBlocks = false
+	minDifficulty.Time++
+	mine(minDifficulty, params)
+	if _, err := chain.Add(minDifficulty); !errors.Is(err, ErrBadDifficulty) {
+		t.Errorf("expected ErrBadDifficulty without testnet rule, got %v", err)
+		return
+	}
+}
+
+func TestChain_Timestamps(t *testing.T) {
+	params := newTestParams()
+	params.NoRetargeting = true
+	chain := newTestChain(t, params)
+
+	// Timestamps 1 through 11 seconds after genesis give a median time past of genesis + 6.
+	tip := extend(t, chain, chain.Genesis(), 11, 1)
+	if mtp := tip.MedianTimePast(); mtp != testGenesisTime+6 {
+		t.Fatalf("unexpected median time past: %d", mtp)
+	}
+
+	header := &blockheader.BlockHeader{
+		Version:            1,
+		PreviousHeaderHash: tip.Hash,
+		Time:               testGenesisTime + 6,
+		NBits:              tip.Header.NBits,
+	}
+	mine(header, params)
+	if _, err := chain.Add(header); !errors.Is(err, ErrTimeTooOld) {
+		t.Errorf("expected ErrTimeTooOld, got %v", err)
+		return
+	}
+
+	// Timestamps may go backwards, as long as they are after the median time past.
+	header.Time = testGenesisTime + 7
+	mine(header, params)
+	if _, err := chain.Add(header); err != nil {
+		t.Errorf("failed to add header with timestamp before its parent: %s", err)
+		return
+	}
+
+	header = &blockheader.BlockHeader{
+		Version:            1,
+		PreviousHeaderHash: tip.Hash,
+		Time:               uint32(chain.Now().Add(MaxFutureBlockTime).Unix()) + 1,
+		NBits:              tip.Header.NBits,
+	}
+	mine(header, params)
+	if _, err := chain.Add(header); !errors.Is(err, ErrTimeTooNew) {
+		t.Errorf("expected ErrTimeTooNew, got %v", err)
+		return
+	}
+}
+
+func TestChain_BestChain(t *testing.T) {
+	params := newTestParams()
+	params.NoRetargeting = true
+	chain := newTestChain(t, params)
+	genesis := chain.Genesis()
+
+	tipA := extend(t, chain, genesis, 3, 10)
+	if chain.Tip() != tipA {
+		t.Fatalf("expected tip to be the end of chain A")
+	}
+
+	// A fork of equal work does not replace the first-seen chain.
+	tipB := extend(t, chain, genesis, 3, 11)
+	if chain.Tip() != tipA {
+		t.Errorf("expected tip to remain on chain A after equal-work fork")
+		return
+	}
+
+	tipB = extend(t, chain, tipB, 1, 11)
+	if chain.Tip() != tipB {
+		t.Errorf("expected tip to switch to chain B with more work")
+		return
+	} else if chain.InBestChain(tipA) {
+		t.Errorf("expected chain A to be a stale fork")
+		return
+	} else if !chain.InBestChain(genesis) {
+		t.Errorf("expected genesis to be in best chain")
+		return
+	}
+
+	expectedWork := new(big.Int).Mul(genesis.ChainWork, big.NewInt(5))
+	if tipB.ChainWork.Cmp(expectedWork) != 0 {
+		t.Errorf("incorrect chain work\nWanted %d\nGot    %d", expectedWork, tipB.ChainWork)
+		return
+	}
+
+	// Adding a known header returns its existing node.
+	if node, err := chain.Add(tipA.Header); err != nil || node != tipA {
+		t.Errorf("expected existing node when re-adding header, got %v, %v", node, err)
+		return
+	}
+
+	orphan := &blockheader.BlockHeader{PreviousHeaderHash: [32]byte{1}, NBits: params.Genesis.NBits}
+	if _, err := chain.Add(orphan); !errors.Is(err, ErrUnknownParent) {
+		t.Errorf("expected ErrUnknownParent, got %v", err)
+		return
+	}
+}
+
+func TestChain_Locator(t *testing.T) {
+	params := newTestParams()
+	params.NoRetargeting = true
+	chain := newTestChain(t, params)
+
+	tip := extend(t, chain, chain.Genesis(), 40, 10)
+	locator := chain.Locator()
+
+	expectedHeights := []int{40, 39, 38, 37, 36, 35, 34, 33, 32, 31, 30, 29, 27, 23, 15, 0}
+	if len(locator) != len(expectedHeights) {
+		t.Fatalf("expected locator with %d hashes, got %d", len(expectedHeights), len(locator))
+	}
+
+	for i, height := range expectedHeights {
+		if locator[i] != tip.Ancestor(height).Hash {
+			t.Errorf("locator entry %d should be the hash at height %d", i, height)
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package headerchain
+
+import (
+	"encoding/hex"
+	"math/big"
+	"time"
+
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+)
+
+// Params holds the consensus rules for block headers on a network.
+type Params struct {
+	// Name is a human-readable name for the network.
+	Name string
+
+	// Genesis is the header of the first block in the chain.
+	Genesis blockheader.BlockHeader
+
+	// PowLimit is the highest (easiest) allowed proof-of-work target.
+	PowLimit *big.Int
+
+	// TargetTimespan is the period over which the difficulty is retargeted, and
+	// TargetSpacing is the desired time between blocks. The number of blocks between
+	// each retarget is TargetTimespan / TargetSpacing.
+	TargetTimespan time.Duration
+	TargetSpacing  time.Duration
+
+	// AllowMinDifficultyBlocks enables the testnet rule which allows a block to be mined
+	// at PowLimit difficulty if its timestamp is more than twice TargetSpacing after its parent.
+	AllowMinDifficultyBlocks bool
+
+	// NoRetargeting disables difficulty retargeting, as on regtest.
+	NoRetargeting bool
+}
+
+// RetargetInterval returns the number of blocks between each difficulty retarget.
+func (params *Params) RetargetInterval() int {
+	return int(params.TargetTimespan / params.TargetSpacing)
+}
+
+func mustDecodeHash(s string) (hash [32]byte) {
+	decoded, err := hex.DecodeString(s)
+	if err != nil || len(decoded) != 32 {
+		panic("invalid hash constant: " + s)
+	}
+	copy(hash[:], decoded)
+	return
+}
+
+func mustDecodeBigInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("invalid integer constant: " + s)
+	}
+	return n
+}
+
+var genesisMerkleRoot = mustDecodeHash("4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b")
+
+var (
+	MainnetParams = &Params{
+		Name: "mainnet",
+		Genesis: blockheader.BlockHeader{
+			Version:        1,
+			MerkleRootHash: genesisMerkleRoot,
+			Time:           1231006505,
+			NBits:          0x1d00ffff,
+			Nonce:          2083236893,
+		},
+		PowLimit:       mustDecodeBigInt("00000000ffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+		TargetTimespan: 14 * 24 * time.Hour,
+		TargetSpacing:  10 * time.Minute,
+	}
+
+	Testnet3Params = &Params{
+		Name: "testnet3",
+		Genesis: blockheader.BlockHeader{
+			Version:        1,
+			MerkleRootHash: genesisMerkleRoot,
+			Time:           1296688602,
+			NBits:          0x1d00ffff,
+			Nonce:          414098458,
+		},
+		PowLimit:                 mustDecodeBigInt("00000000ffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+		TargetTimespan:           14 * 24 * time.Hour,
+		TargetSpacing:            10 * time.Minute,
+		AllowMinDifficultyBlocks: true,
+	}
+
+	SignetParams = &Params{
+		Name: "signet",
+		Genesis: blockheader.BlockHeader{
+			Version:        1,
+			MerkleRootHash: genesisMerkleRoot,
+			Time:           1598918400,
+			NBits:          0x1e0377ae,
+			Nonce:          52613770,
+		},
+		PowLimit:       mustDecodeBigInt("00000377ae000000000000000000000000000000000000000000000000000000"),
+		TargetTimespan: 14 * 24 * time.Hour,
+		TargetSpacing:  10 * time.Minute,
+	}
+
+	RegtestParams = &Params{
+		Name: "regtest",
+		Genesis: blockheader.BlockHeader{
+			Version:        1,
+			MerkleRootHash: genesisMerkleRoot,
+			Time:           1296688602,
+			NBits:          0x207fffff,
+			Nonce:          2,
+		},
+		PowLimit:                 mustDecodeBigInt("7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+		TargetTimespan:           14 * 24 * time.Hour,
+		TargetSpacing:            10 * time.Minute,
+		AllowMinDifficultyBlocks: true,
+		NoRetargeting:            true,
+	}
+)
@@ -0,0 +1,110 @@
+package headerchain
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+)
+
+// decodeTarget decodes a compact nBits target, and checks it is valid for the network.
+// Returns ErrInvalidTarget if the target is negative, zero, overflows 256 bits, or is
+// easier than powLimit.
+func decodeTarget(nBits uint32, powLimit *big.Int) (*big.Int, error) {
+	target, err := blockheader.NBitsToTarget(nBits)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTarget, err)
+	}
+
+	// Negative targets are decoded as zero.
+	if target.Sign() == 0 {
+		return nil, fmt.Errorf("%w: nBits %#08x is zero or negative", ErrInvalidTarget, nBits)
+	} else if target.Cmp(powLimit) > 0 {
+		return nil, fmt.Errorf("%w: nBits %#08x is above the proof-of-work limit", ErrInvalidTarget, nBits)
+	}
+
+	return target, nil
+}
+
+// CheckProofOfWork checks that the header's nBits encodes a valid target no easier
+// than params.PowLimit, and that the header hash is less than or equal to the target.
+// Returns ErrInvalidTarget or ErrHighHash if the checks fail.
+func CheckProofOfWork(header *blockheader.BlockHeader, params *Params) error {
+	target, err := decodeTarget(header.NBits, params.PowLimit)
+	if err != nil {
+		return err
+	}
+
+	hash, err := header.Hash()
+	if err != nil {
+		return err
+	}
+
+	if new(big.Int).SetBytes(hash[:]).Cmp(target) > 0 {
+		return fmt.Errorf("%w: %x", ErrHighHash, hash)
+	}
+
+	return nil
+}
+
+// nextWorkRequired returns the nBits value required for a header whose parent is
+// the given node, following the rules of Bitcoin Core's GetNextWorkRequired.
+func nextWorkRequired(parent *Node, header *blockheader.BlockHeader, params *Params) uint32 {
+	powLimitNBits := blockheader.TargetToNBits(params.PowLimit)
+	interval := params.RetargetInterval()
+
+	if (parent.Height+1)%interval != 0 {
+		if params.AllowMinDifficultyBlocks {
+			// If the new block's timestamp is more than twice the target spacing
+			// after its parent, then it is allowed to mine a min-difficulty block.
+			spacing := uint32(params.TargetSpacing.Seconds())
+			if int64(header.Time) > int64(parent.Header.Time)+2*int64(spacing) {
+				return powLimitNBits
+			}
+
+			// Otherwise, return the difficulty of the last block
+			// which was not mined under the min-difficulty rule.
+			node := parent
+			for node.Parent != nil && node.Height%interval != 0 && node.Header.NBits == powLimitNBits {
+				node = node.Parent
+			}
+			return node.Header.NBits
+		}
+
+		return parent.Header.NBits
+	}
+
+	first := parent.Ancestor(parent.Height - (interval - 1))
+	return calculateNextWorkRequired(parent.Header.NBits, int64(parent.Header.Time), int64(first.Header.Time), params)
+}
+
+// calculateNextWorkRequired returns the retargeted nBits value following a block with
+// the given nBits and time, given the time of the first block in the retarget period.
+// The actual timespan of the period is clamped to within a factor of 4 of the target.
+func calculateNextWorkRequired(lastNBits uint32, lastTime, firstTime int64, params *Params) uint32 {
+	if params.NoRetargeting {
+		return lastNBits
+	}
+
+	targetTimespan := int64(params.TargetTimespan.Seconds())
+	actualTimespan := lastTime - firstTime
+	if actualTimespan < targetTimespan/4 {
+		actualTimespan = targetTimespan / 4
+	} else if actualTimespan > targetTimespan*4 {
+		actualTimespan = targetTimespan * 4
+	}
+
+	target, err := decodeTarget(lastNBits, params.PowLimit)
+	if err != nil {
+		// The last header was already validated, so this only happens for an invalid genesis.
+		target = params.PowLimit
+	}
+
+	target = new(big.Int).Mul(target, big.NewInt(actualTimespan))
+	target.Div(target, big.NewInt(targetTimespan))
+	if target.Cmp(params.PowLimit) > 0 {
+		target = params.PowLimit
+	}
+
+	return blockheader.TargetToNBits(target)
+}
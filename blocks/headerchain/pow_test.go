@@ -0,0 +1,94 @@
+package headerchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestCalculateNextWorkRequired(t *testing.T) {
+	// These fixtures are taken from Bitcoin Core's pow_tests.
+	fixtures := []struct {
+		name      string
+		lastNBits uint32
+		lastTime  int64
+		firstTime int64
+		expected  uint32
+	}{
+		{"normal retarget", 0x1d00ffff, 1262152739, 1261130161, 0x1d00d86a},
+		{"pow limit", 0x1d00ffff, 1233061996, 1231006505, 0x1d00ffff},
+		{"lower limit actual", 0x1c05a3f4, 1279297671, 1279008237, 0x1c0168fd},
+		{"upper limit actual", 0x1c387f6f, 1269211443, 1263163443, 0x1d00e1fd},
+	}
+
+	for _, fixture := range fixtures {
+		actual := calculateNextWorkRequired(fixture.lastNBits, fixture.lastTime, fixture.firstTime, MainnetParams)
+		if actual != fixture.expected {
+			t.Errorf("%s: incorrect next work required\nWanted %#08x\nGot    %#08x", fixture.name, fixture.expected, actual)
+			continue
+		}
+	}
+
+	if actual := calculateNextWorkRequired(0x207fffff, 1000, 0, RegtestParams); actual != 0x207fffff {
+		t.Errorf("expected no retargeting on regtest, got %#08x", actual)
+		return
+	}
+}
+
+func TestDecodeTarget_Invalid(t *testing.T) {
+	for _, nBits := range []uint32{
+		0x00000000, // zero
+		0x01003456, // zero after truncation
+		0x04923456, // negative
+		0xff123456, // overflow
+		0x21010000, // above powLimit
+	} {
+		if _, err := decodeTarget(nBits, RegtestParams.PowLimit); !errors.Is(err, ErrInvalidTarget) {
+			t.Errorf("expected ErrInvalidTarget for nBits %#08x, got %v", nBits, err)
+			continue
+		}
+	}
+
+	// Mainnet rejects targets easier than 0x1d00ffff.
+	if _, err := decodeTarget(0x1d010000, MainnetParams.PowLimit); !errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("expected ErrInvalidTarget for target above mainnet powLimit, got %v", err)
+		return
+	}
+}
+
+func TestParams_Genesis(t *testing.T) {
+	fixtures := []struct {
+		params *Params
+		hash   string
+	}{
+		{MainnetParams, "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"},
+		{Testnet3Params, "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943"},
+		{SignetParams, "00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6"},
+		{RegtestParams, "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206"},
+	}
+
+	for _, fixture := range fixtures {
+		hash, err := fixture.params.Genesis.Hash()
+		if err != nil {
+			t.Errorf("%s: failed to hash genesis header: %s", fixture.params.Name, err)
+			continue
+		}
+
+		if actual := hex.EncodeToString(hash[:]); actual != fixture.hash {
+			t.Errorf("%s: incorrect genesis hash\nWanted %s\nGot    %s", fixture.params.Name, fixture.hash, actual)
+			continue
+		}
+
+		if err := CheckProofOfWork(&fixture.params.Genesis, fixture.params); err != nil {
+			t.Errorf("%s: genesis header failed proof-of-work check: %s", fixture.params.Name, err)
+			continue
+		}
+	}
+
+	badGenesis := MainnetParams.Genesis
+	badGenesis.Nonce++
+	if err := CheckProofOfWork(&badGenesis, MainnetParams); !errors.Is(err, ErrHighHash) {
+		t.Errorf("expected ErrHighHash, got %v", err)
+		return
+	}
+}
@@ -0,0 +1,62 @@
+package merkle
+
+import (
+	"errors"
+
+	"github.com/kklash/bitcoinlib/bhash"
+)
+
+// ErrIndexOutOfRange is returned by MerkleBranch if the index of
+// the leaf to prove is not within the slice of hashes.
+var ErrIndexOutOfRange = errors.New("merkle leaf index out of range")
+
+// MerkleBranch returns the sibling hashes needed to prove that the hash at the given index
+// is included in the merkle tree of hashes, ordered from the leaves to the root. All hashes
+// are in internal byte order. The branch can be checked using MerkleBranchRoot.
+func MerkleBranch(hashes [][32]byte, index int) ([][32]byte, error) {
+	if index < 0 || index >= len(hashes) {
+		return nil, ErrIndexOutOfRange
+	}
+
+	var branch [][32]byte
+	for len(hashes) > 1 {
+		if len(hashes)%2 == 1 {
+			hashes = append(hashes[:len(hashes):len(hashes)], hashes[len(hashes)-1])
+		}
+		branch = append(branch, hashes[index^1])
+
+		nextRow := make([][32]byte, len(hashes)/2)
+		for i := 0; i < len(nextRow); i++ {
+			nextRow[i] = bhash.DoubleSha256(concatHashes(hashes[i*2], hashes[i*2+1]))
+		}
+		hashes = nextRow
+		index /= 2
+	}
+
+	return branch, nil
+}
+
+// MerkleBranchRoot computes the merkle root implied by the given leaf hash at the given
+// index in the tree, and its merkle branch as returned by MerkleBranch. All hashes are in
+// internal byte order. The leaf is proven to be in the tree if the result equals its root.
+func MerkleBranchRoot(leaf [32]byte, branch [][32]byte, index int) [32]byte {
+	hash := leaf
+	for _, sibling := range branch {
+		if index%2 == 1 {
+			hash = bhash.DoubleSha256(concatHashes(sibling, hash))
+		} else {
+			hash = bhash.DoubleSha256(concatHashes(hash, sibling))
+		}
+		index /= 2
+	}
+	return hash
+}
+
+// VerifyMerkleBranch returns true if the merkle branch proves that leaf is at the given
+// index of the merkle tree with the given root. All hashes are in internal byte order.
+func VerifyMerkleBranch(leaf [32]byte, branch [][32]byte, index int, root [32]byte) bool {
+	if index < 0 || index >= 1<<len(branch) {
+		return false
+	}
+	return MerkleBranchRoot(leaf, branch, index) == root
+}
@@ -0,0 +1,55 @@
+package merkle
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/common"
+)
+
+// internalHashes returns the fixture's txids in internal byte order.
+func (fixture *Fixture) internalHashes() [][32]byte {
+	hashes := make([][32]byte, len(fixture.Txids))
+	for i, txid := range fixture.Txids {
+		copy(hashes[i][:], common.ReverseBytes(txid[:]))
+	}
+	return hashes
+}
+
+func TestMerkleBranch(t *testing.T) {
+	fixtures, err := getFixtures()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, fixture := range fixtures {
+		hashes := fixture.internalHashes()
+		root := MerkleRootHashInternal(hashes)
+
+		for index, leaf := range hashes {
+			branch, err := MerkleBranch(hashes, index)
+			if err != nil {
+				t.Errorf("failed to build merkle branch: %s", err)
+				return
+			}
+
+			if !VerifyMerkleBranch(leaf, branch, index, root) {
+				t.Errorf("failed to verify merkle branch for tx %d in %q", index, fixture.Comment)
+				return
+			}
+
+			wrongLeaf := leaf
+			wrongLeaf[0] ^= 1
+			if VerifyMerkleBranch(wrongLeaf, branch, index, root) {
+				t.Errorf("verified merkle branch with wrong leaf for tx %d in %q", index, fixture.Comment)
+				return
+			}
+		}
+	}
+
+	if _, err := MerkleBranch([][32]byte{{1}}, 1); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("expected ErrIndexOutOfRange, got %v", err)
+		return
+	}
+}
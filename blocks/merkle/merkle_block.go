@@ -0,0 +1,87 @@
+package merkle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+	"github.com/kklash/bitcoinlib/common"
+)
+
+// ErrMerkleRootMismatch is returned by MerkleBlock.ExtractMatches if the partial
+// merkle tree does not match the merkle root in the block header.
+var ErrMerkleRootMismatch = errors.New("partial merkle tree does not match block header merkle root")
+
+// MerkleBlock is a block header with a partial merkle tree proving the inclusion of some of the
+// block's transactions. This is the payload of BIP37 merkleblock messages, and the proof format
+// returned by Bitcoin Core's gettxoutproof RPC and accepted by verifytxoutproof.
+type MerkleBlock struct {
+	Header *blockheader.BlockHeader
+	Tree   *PartialMerkleTree
+}
+
+// NewMerkleBlock creates a MerkleBlock for the given block header, proving the inclusion of
+// each transaction whose entry in matches is true. Hashes are the hashes of all transactions
+// in the block, in internal byte order.
+func NewMerkleBlock(header *blockheader.BlockHeader, hashes [][32]byte, matches []bool) (*MerkleBlock, error) {
+	tree, err := NewPartialMerkleTree(hashes, matches)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MerkleBlock{Header: header, Tree: tree}, nil
+}
+
+// ExtractMatches returns the hashes of the matched transactions, in internal byte order, along
+// with their indexes in the block. It returns an error wrapping ErrInvalidPartialMerkleTree if
+// the tree is malformed, or ErrMerkleRootMismatch if the tree does not match the block header.
+func (mb *MerkleBlock) ExtractMatches() ([][32]byte, []uint32, error) {
+	root, matches, indexes, err := mb.Tree.ExtractMatches()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	common.ReverseBytesInPlace(root[:])
+	if root != mb.Header.MerkleRootHash {
+		return nil, nil, fmt.Errorf("%w: got %x", ErrMerkleRootMismatch, root)
+	}
+
+	return matches, indexes, nil
+}
+
+// MerkleBlockFromReader decodes a serialized MerkleBlock using data from reader.
+// Returns ErrInvalidPartialMerkleTreeFormat if the block is not properly formatted.
+func MerkleBlockFromReader(reader io.Reader) (*MerkleBlock, error) {
+	header, err := blockheader.FromReader(reader)
+	if err != nil {
+		return nil, ErrInvalidPartialMerkleTreeFormat
+	}
+
+	tree, err := PartialMerkleTreeFromReader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MerkleBlock{Header: header, Tree: tree}, nil
+}
+
+// WriteTo implements the io.WriterTo interface. Writes the serialized MerkleBlock to the given io.Writer.
+func (mb *MerkleBlock) WriteTo(w io.Writer) (n int64, err error) {
+	n, err = mb.Header.WriteTo(w)
+	if err != nil {
+		return
+	}
+
+	c, err := mb.Tree.WriteTo(w)
+	n += c
+	return
+}
+
+// Bytes returns the serialized MerkleBlock as a byte-slice.
+func (mb *MerkleBlock) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	mb.WriteTo(buf)
+	return buf.Bytes()
+}
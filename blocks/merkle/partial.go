@@ -0,0 +1,282 @@
+package merkle
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/kklash/bitcoinlib/bhash"
+	"github.com/kklash/bitcoinlib/varint"
+)
+
+// MaxPartialMerkleTreeTxCount is the maximum number of transactions a partial merkle tree may
+// describe: the number of transactions of the minimum size which could fit in a block.
+const MaxPartialMerkleTreeTxCount = 4000000 / 240
+
+var (
+	// ErrInvalidPartialMerkleTree is returned when extracting matches from a
+	// PartialMerkleTree which is malformed, or which has unused hashes or flags.
+	ErrInvalidPartialMerkleTree = errors.New("partial merkle tree is invalid")
+
+	// ErrInvalidPartialMerkleTreeFormat is returned when decoding a PartialMerkleTree
+	// or MerkleBlock which is not encoded properly.
+	ErrInvalidPartialMerkleTreeFormat = errors.New("partial merkle tree is not formatted correctly")
+)
+
+// PartialMerkleTree represents a subset of a block's merkle tree which proves that a set
+// of transactions were included in the block, as used by BIP37 merkleblock messages and
+// by Bitcoin Core's gettxoutproof RPC. All hashes are in internal byte order.
+type PartialMerkleTree struct {
+	// TxCount is the total number of transactions in the block.
+	TxCount uint32
+
+	// Hashes are the hashes of the subtrees and matched transactions, in depth-first order.
+	Hashes [][32]byte
+
+	// Flags indicate, in depth-first order, whether each node visited is an
+	// ancestor of a matched transaction, or is a matched transaction.
+	Flags []bool
+}
+
+// NewPartialMerkleTree builds a PartialMerkleTree from the hashes of all transactions in a
+// block, in internal byte order, proving the inclusion of each transaction whose entry in
+// matches is true. The matches slice must have the same length as hashes.
+func NewPartialMerkleTree(hashes [][32]byte, matches []bool) (*PartialMerkleTree, error) {
+	if len(hashes) == 0 || len(hashes) != len(matches) {
+		return nil, fmt.Errorf("%w: %d hashes and %d matches", ErrInvalidPartialMerkleTree, len(hashes), len(matches))
+	}
+
+	tree := &PartialMerkleTree{TxCount: uint32(len(hashes))}
+	tree.build(tree.height(), 0, hashes, matches)
+	return tree, nil
+}
+
+// treeWidth returns the number of nodes at the given height of the tree, where leaves are height 0.
+func (tree *PartialMerkleTree) treeWidth(height uint) uint32 {
+	return uint32((uint64(tree.TxCount) + (1 << height) - 1) >> height)
+}
+
+// height returns the height of the root of the tree.
+func (tree *PartialMerkleTree) height() uint {
+	var height uint
+	for tree.treeWidth(height) > 1 {
+		height++
+	}
+	return height
+}
+
+func (tree *PartialMerkleTree) calcHash(height uint, pos uint32, hashes [][32]byte) [32]byte {
+	if height == 0 {
+		return hashes[pos]
+	}
+
+	left := tree.calcHash(height-1, pos*2, hashes)
+	right := left
+	if pos*2+1 < tree.treeWidth(height-1) {
+		right = tree.calcHash(height-1, pos*2+1, hashes)
+	}
+	return bhash.DoubleSha256(concatHashes(left, right))
+}
+
+func (tree *PartialMerkleTree) build(height uint, pos uint32, hashes [][32]byte, matches []bool) {
+	isParentOfMatch := false
+	for i := uint64(pos) << height; i < (uint64(pos)+1)<<height && i < uint64(tree.TxCount); i++ {
+		if matches[i] {
+			isParentOfMatch = true
+			break
+		}
+	}
+	tree.Flags = append(tree.Flags, isParentOfMatch)
+
+	if height == 0 || !isParentOfMatch {
+		tree.Hashes = append(tree.Hashes, tree.calcHash(height, pos, hashes))
+		return
+	}
+
+	tree.build(height-1, pos*2, hashes, matches)
+	if pos*2+1 < tree.treeWidth(height-1) {
+		tree.build(height-1, pos*2+1, hashes, matches)
+	}
+}
+
+// treeExtractor holds the state of a depth-first traversal of a PartialMerkleTree.
+type treeExtractor struct {
+	tree     *PartialMerkleTree
+	bitsUsed int
+	hashUsed int
+	matches  [][32]byte
+	indexes  []uint32
+}
+
+func (ex *treeExtractor) extract(height uint, pos uint32) ([32]byte, error) {
+	if ex.bitsUsed >= len(ex.tree.Flags) {
+		return [32]byte{}, fmt.Errorf("%w: ran out of flags", ErrInvalidPartialMerkleTree)
+	}
+	isParentOfMatch := ex.tree.Flags[ex.bitsUsed]
+	ex.bitsUsed++
+
+	if height == 0 || !isParentOfMatch {
+		if ex.hashUsed >= len(ex.tree.Hashes) {
+			return [32]byte{}, fmt.Errorf("%w: ran out of hashes", ErrInvalidPartialMerkleTree)
+		}
+		hash := ex.tree.Hashes[ex.hashUsed]
+		ex.hashUsed++
+
+		if height == 0 && isParentOfMatch {
+			ex.matches = append(ex.matches, hash)
+			ex.indexes = append(ex.indexes, pos)
+		}
+		return hash, nil
+	}
+
+	left, err := ex.extract(height-1, pos*2)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	right := left
+	if pos*2+1 < ex.tree.treeWidth(height-1) {
+		if right, err = ex.extract(height-1, pos*2+1); err != nil {
+			return [32]byte{}, err
+		}
+		// Identical siblings would allow the tree to be mutated, as in CVE-2012-2459.
+		if right == left {
+			return [32]byte{}, fmt.Errorf("%w: duplicate sibling hashes", ErrInvalidPartialMerkleTree)
+		}
+	}
+
+	return bhash.DoubleSha256(concatHashes(left, right)), nil
+}
+
+// ExtractMatches traverses the tree and returns its merkle root in internal byte order, along
+// with the hashes of the matched transactions and their indexes in the block. The caller must
+// compare the returned root with the block header's merkle root. Returns an error wrapping
+// ErrInvalidPartialMerkleTree if the tree is malformed.
+func (tree *PartialMerkleTree) ExtractMatches() (root [32]byte, matches [][32]byte, indexes []uint32, err error) {
+	if tree.TxCount == 0 {
+		return root, nil, nil, fmt.Errorf("%w: no transactions", ErrInvalidPartialMerkleTree)
+	} else if tree.TxCount > MaxPartialMerkleTreeTxCount {
+		return root, nil, nil, fmt.Errorf("%w: too many transactions", ErrInvalidPartialMerkleTree)
+	} else if len(tree.Hashes) > int(tree.TxCount) {
+		return root, nil, nil, fmt.Errorf("%w: more hashes than transactions", ErrInvalidPartialMerkleTree)
+	} else if len(tree.Flags) < len(tree.Hashes) {
+		return root, nil, nil, fmt.Errorf("%w: fewer flags than hashes", ErrInvalidPartialMerkleTree)
+	}
+
+	ex := &treeExtractor{tree: tree}
+	root, err = ex.extract(tree.height(), 0)
+	if err != nil {
+		return [32]byte{}, nil, nil, err
+	}
+
+	// All hashes must be used, and any unused flags may only pad the last byte.
+	if (ex.bitsUsed+7)/8 != (len(tree.Flags)+7)/8 {
+		return [32]byte{}, nil, nil, fmt.Errorf("%w: unused flags", ErrInvalidPartialMerkleTree)
+	} else if ex.hashUsed != len(tree.Hashes) {
+		return [32]byte{}, nil, nil, fmt.Errorf("%w: unused hashes", ErrInvalidPartialMerkleTree)
+	}
+
+	return root, ex.matches, ex.indexes, nil
+}
+
+// PartialMerkleTreeFromReader decodes a serialized PartialMerkleTree using data from reader.
+// Returns ErrInvalidPartialMerkleTreeFormat if the tree is not properly formatted.
+func PartialMerkleTreeFromReader(reader io.Reader) (*PartialMerkleTree, error) {
+	tree, err := partialMerkleTreeFromReader(reader)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, ErrInvalidPartialMerkleTreeFormat
+	} else if err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+func partialMerkleTreeFromReader(reader io.Reader) (*PartialMerkleTree, error) {
+	tree := new(PartialMerkleTree)
+	if err := binary.Read(reader, binary.LittleEndian, &tree.TxCount); err != nil {
+		return nil, err
+	}
+
+	nHashes, err := varint.FromReader(reader)
+	if err != nil {
+		return nil, err
+	} else if nHashes > MaxPartialMerkleTreeTxCount {
+		return nil, ErrInvalidPartialMerkleTreeFormat
+	}
+
+	tree.Hashes = make([][32]byte, nHashes)
+	for i := range tree.Hashes {
+		if _, err := io.ReadFull(reader, tree.Hashes[i][:]); err != nil {
+			return nil, err
+		}
+	}
+
+	nFlagBytes, err := varint.FromReader(reader)
+	if err != nil {
+		return nil, err
+	} else if nFlagBytes > (MaxPartialMerkleTreeTxCount*2+7)/8 {
+		return nil, ErrInvalidPartialMerkleTreeFormat
+	}
+
+	flagBytes := make([]byte, nFlagBytes)
+	if _, err := io.ReadFull(reader, flagBytes); err != nil {
+		return nil, err
+	}
+
+	tree.Flags = make([]bool, len(flagBytes)*8)
+	for i := range tree.Flags {
+		tree.Flags[i] = flagBytes[i/8]&(1<<(i%8)) != 0
+	}
+
+	return tree, nil
+}
+
+// WriteTo implements the io.WriterTo interface. Writes the serialized
+// PartialMerkleTree to the given io.Writer.
+func (tree *PartialMerkleTree) WriteTo(w io.Writer) (n int64, err error) {
+	if err = binary.Write(w, binary.LittleEndian, tree.TxCount); err != nil {
+		return
+	}
+	n += 4
+
+	c64, err := varint.VarInt(len(tree.Hashes)).WriteTo(w)
+	n += c64
+	if err != nil {
+		return
+	}
+
+	for _, hash := range tree.Hashes {
+		c, err := w.Write(hash[:])
+		n += int64(c)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	flagBytes := make([]byte, (len(tree.Flags)+7)/8)
+	for i, flag := range tree.Flags {
+		if flag {
+			flagBytes[i/8] |= 1 << (i % 8)
+		}
+	}
+
+	c64, err = varint.VarInt(len(flagBytes)).WriteTo(w)
+	n += c64
+	if err != nil {
+		return
+	}
+
+	c, err := w.Write(flagBytes)
+	n += int64(c)
+	return
+}
+
+// Bytes returns the serialized PartialMerkleTree as a byte-slice.
+func (tree *PartialMerkleTree) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	tree.WriteTo(buf)
+	return buf.Bytes()
+}
@@ -0,0 +1,146 @@
+package merkle
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+)
+
+func TestPartialMerkleTree(t *testing.T) {
+	fixtures, err := getFixtures()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	rng := rand.New(rand.NewSource(1))
+
+	for _, fixture := range fixtures {
+		hashes := fixture.internalHashes()
+		header := &blockheader.BlockHeader{MerkleRootHash: MerkleRootHash(fixture.Txids)}
+
+		for trial := 0; trial < 20; trial++ {
+			matches := make([]bool, len(hashes))
+			var expectedMatches [][32]byte
+			var expectedIndexes []uint32
+			for i := range matches {
+				if matches[i] = rng.Intn(4) == 0; matches[i] {
+					expectedMatches = append(expectedMatches, hashes[i])
+					expectedIndexes = append(expectedIndexes, uint32(i))
+				}
+			}
+
+			mb, err := NewMerkleBlock(header, hashes, matches)
+			if err != nil {
+				t.Errorf("failed to create merkle block: %s", err)
+				return
+			}
+
+			decoded, err := MerkleBlockFromReader(bytes.NewReader(mb.Bytes()))
+			if err != nil {
+				t.Errorf("failed to decode merkle block: %s", err)
+				return
+			}
+
+			actualMatches, actualIndexes, err := decoded.ExtractMatches()
+			if err != nil {
+				t.Errorf("failed to extract matches from %q: %s", fixture.Comment, err)
+				return
+			}
+
+			if !reflect.DeepEqual(actualMatches, expectedMatches) || !reflect.DeepEqual(actualIndexes, expectedIndexes) {
+				t.Errorf(
+					"extracted matches do not match\nWanted %x %v\nGot    %x %v",
+					expectedMatches, expectedIndexes, actualMatches, actualIndexes,
+				)
+				return
+			}
+
+			if !bytes.Equal(decoded.Bytes(), mb.Bytes()) {
+				t.Errorf("merkle block did not re-encode identically")
+				return
+			}
+		}
+	}
+}
+
+func TestPartialMerkleTree_Invalid(t *testing.T) {
+	fixtures, err := getFixtures()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	fixture := fixtures[0]
+	hashes := fixture.internalHashes()
+	matches := make([]bool, len(hashes))
+	matches[len(matches)-1] = true
+
+	header := &blockheader.BlockHeader{MerkleRootHash: MerkleRootHash(fixture.Txids)}
+	mb, err := NewMerkleBlock(header, hashes, matches)
+	if err != nil {
+		t.Errorf("failed to create merkle block: %s", err)
+		return
+	}
+
+	if _, _, err := mb.ExtractMatches(); err != nil {
+		t.Errorf("failed to extract matches: %s", err)
+		return
+	}
+
+	wrongHeader := *header
+	wrongHeader.MerkleRootHash[0] ^= 1
+	wrongRoot := &MerkleBlock{Header: &wrongHeader, Tree: mb.Tree}
+	if _, _, err := wrongRoot.ExtractMatches(); !errors.Is(err, ErrMerkleRootMismatch) {
+		t.Errorf("expected ErrMerkleRootMismatch, got %v", err)
+		return
+	}
+
+	mutations := map[string]func(tree *PartialMerkleTree){
+		"no transactions": func(tree *PartialMerkleTree) { tree.TxCount = 0 },
+		"extra hash":      func(tree *PartialMerkleTree) { tree.Hashes = append(tree.Hashes, [32]byte{}) },
+		"missing hash":    func(tree *PartialMerkleTree) { tree.Hashes = tree.Hashes[:len(tree.Hashes)-1] },
+		"extra flags":     func(tree *PartialMerkleTree) { tree.Flags = append(tree.Flags, make([]bool, 8)...) },
+		"missing flags":   func(tree *PartialMerkleTree) { tree.Flags = tree.Flags[:1] },
+	}
+
+	for name, mutate := range mutations {
+		tree := &PartialMerkleTree{
+			TxCount: mb.Tree.TxCount,
+			Hashes:  append([][32]byte{}, mb.Tree.Hashes...),
+			Flags:   append([]bool{}, mb.Tree.Flags...),
+		}
+		mutate(tree)
+
+		if _, _, _, err := tree.ExtractMatches(); !errors.Is(err, ErrInvalidPartialMerkleTree) {
+			t.Errorf("%s: expected ErrInvalidPartialMerkleTree, got %v", name, err)
+			continue
+		}
+	}
+
+	if _, err := MerkleBlockFromReader(bytes.NewReader(mb.Bytes()[:90])); !errors.Is(err, ErrInvalidPartialMerkleTreeFormat) {
+		t.Errorf("expected ErrInvalidPartialMerkleTreeFormat, got %v", err)
+		return
+	}
+}
+
+// A tree of 3 transactions where the last is duplicated has the same root as a tree of 4
+// transactions whose last two are identical. Extraction must reject the latter (CVE-2012-2459).
+func TestPartialMerkleTree_DuplicateSiblings(t *testing.T) {
+	hashes := [][32]byte{{1}, {2}, {3}, {3}}
+	tree, err := NewPartialMerkleTree(hashes, []bool{false, false, true, true})
+	if err != nil {
+		t.Errorf("failed to create partial merkle tree: %s", err)
+		return
+	}
+
+	if _, _, _, err := tree.ExtractMatches(); !errors.Is(err, ErrInvalidPartialMerkleTree) {
+		t.Errorf("expected ErrInvalidPartialMerkleTree, got %v", err)
+		return
+	}
+
+}
@@ -0,0 +1,134 @@
+package merkle
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/kklash/bitcoinlib/bhash"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+const (
+	// WitnessReservedValueSize is the size of the witness reserved value, which
+	// must be the only item in the coinbase input witness of a segwit block.
+	WitnessReservedValueSize = 32
+
+	// WitnessCommitmentScriptSize is the minimum size of a coinbase output script
+	// containing a witness commitment.
+	WitnessCommitmentScriptSize = 38
+)
+
+// WitnessCommitmentHeader is the prefix of a coinbase output script which commits to the
+// witness merkle root: OP_RETURN, a 36 byte push, and the BIP141 tag 0xaa21a9ed.
+var WitnessCommitmentHeader = []byte{constants.OP_RETURN, 0x24, 0xaa, 0x21, 0xa9, 0xed}
+
+// DefaultWitnessReservedValue is the witness reserved value used by miners today.
+// BIP141 reserves it for future commitments, so it is all zeros.
+var DefaultWitnessReservedValue [32]byte
+
+var (
+	// ErrInvalidWitnessReservedValue is returned by CoinbaseWitnessReservedValue if the
+	// coinbase witness is not a single item of WitnessReservedValueSize bytes.
+	ErrInvalidWitnessReservedValue = errors.New("coinbase witness is not a valid witness reserved value")
+
+	// ErrNoTransactions is returned by WitnessMerkleRoot if given no transactions.
+	ErrNoTransactions = errors.New("cannot compute merkle root of zero transactions")
+)
+
+// WitnessMerkleRoot computes the witness merkle root of the given block transactions, in internal
+// byte order. The first transaction must be the coinbase, whose wtxid is replaced by zeros.
+func WitnessMerkleRoot(txs []*tx.Tx) ([32]byte, error) {
+	if len(txs) == 0 {
+		return [32]byte{}, ErrNoTransactions
+	}
+
+	wtxids := make([][32]byte, len(txs))
+	for i := 1; i < len(txs); i++ {
+		wtxid, err := txs[i].Hash(true)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		wtxids[i] = wtxid
+	}
+
+	return MerkleRootHashInternal(wtxids), nil
+}
+
+// WitnessCommitment computes the commitment to the given witness merkle
+// root and witness reserved value, as placed in the coinbase by BIP141.
+func WitnessCommitment(witnessRoot, reservedValue [32]byte) [32]byte {
+	return bhash.DoubleSha256(concatHashes(witnessRoot, reservedValue))
+}
+
+// WitnessCommitmentScript returns the coinbase output script which contains the given commitment.
+func WitnessCommitmentScript(commitment [32]byte) []byte {
+	outputScript := make([]byte, 0, WitnessCommitmentScriptSize)
+	outputScript = append(outputScript, WitnessCommitmentHeader...)
+	return append(outputScript, commitment[:]...)
+}
+
+// ParseWitnessCommitment returns the witness commitment contained in the given
+// output script. The returned bool is false if the script has no commitment.
+func ParseWitnessCommitment(outputScript []byte) ([32]byte, bool) {
+	var commitment [32]byte
+	if len(outputScript) < WitnessCommitmentScriptSize || !bytes.HasPrefix(outputScript, WitnessCommitmentHeader) {
+		return commitment, false
+	}
+	copy(commitment[:], outputScript[len(WitnessCommitmentHeader):])
+	return commitment, true
+}
+
+// FindWitnessCommitment returns the index of the coinbase output which contains the
+// witness commitment, and the commitment itself. If multiple outputs have a commitment,
+// the last one is used. If there is no commitment, the returned index is -1.
+func FindWitnessCommitment(coinbase *tx.Tx) (int, [32]byte) {
+	for i := len(coinbase.Outputs) - 1; i >= 0; i-- {
+		if commitment, ok := ParseWitnessCommitment(coinbase.Outputs[i].Script); ok {
+			return i, commitment
+		}
+	}
+	return -1, [32]byte{}
+}
+
+// CoinbaseWitnessReservedValue returns the witness reserved value from the coinbase input witness.
+// Returns ErrInvalidWitnessReservedValue if the witness is not a single 32-byte item.
+func CoinbaseWitnessReservedValue(coinbase *tx.Tx) ([32]byte, error) {
+	var reservedValue [32]byte
+	if len(coinbase.Witnesses) == 0 ||
+		len(coinbase.Witnesses[0]) != 1 ||
+		len(coinbase.Witnesses[0][0]) != WitnessReservedValueSize {
+		return reservedValue, ErrInvalidWitnessReservedValue
+	}
+	copy(reservedValue[:], coinbase.Witnesses[0][0])
+	return reservedValue, nil
+}
+
+// SetWitnessCommitment sets the coinbase witness to the given reserved value, and commits to the
+// witness merkle root of txs, which must begin with the coinbase. The commitment output is
+// replaced if the coinbase already has one, or else appended to the coinbase outputs.
+//
+// Since the coinbase wtxid is always zero in the witness merkle tree, the coinbase
+// may be modified further without invalidating the commitment.
+func SetWitnessCommitment(txs []*tx.Tx, reservedValue [32]byte) error {
+	witnessRoot, err := WitnessMerkleRoot(txs)
+	if err != nil {
+		return err
+	}
+
+	coinbase := txs[0]
+	coinbase.Witnesses = []tx.Witness{{append([]byte{}, reservedValue[:]...)}}
+
+	output := &tx.Output{
+		Value:  0,
+		Script: WitnessCommitmentScript(WitnessCommitment(witnessRoot, reservedValue)),
+	}
+
+	if index, _ := FindWitnessCommitment(coinbase); index >= 0 {
+		coinbase.Outputs[index] = output
+	} else {
+		coinbase.Outputs = append(coinbase.Outputs, output)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package merkle
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+func makeTestCoinbase() *tx.Tx {
+	return &tx.Tx{
+		Version: 1,
+		Inputs: []*tx.Input{{
+			PrevOut:  &tx.PrevOut{Index: 0xffffffff},
+			Script:   []byte{0x03, 0x01, 0x02, 0x03},
+			Sequence: 0xffffffff,
+		}},
+		Outputs: []*tx.Output{{Value: 625000000, Script: []byte{constants.OP_TRUE}}},
+	}
+}
+
+func TestWitnessCommitment_EmptyBlock(t *testing.T) {
+	coinbase := makeTestCoinbase()
+	if err := SetWitnessCommitment([]*tx.Tx{coinbase}, DefaultWitnessReservedValue); err != nil {
+		t.Errorf("failed to set witness commitment: %s", err)
+		return
+	}
+
+	// Every block containing only a coinbase has this commitment output.
+	expected := "6a24aa21a9ede2f61c3f71d1defd3fa999dfa36953755c690689799962b48bebd836974e8cf9"
+	if len(coinbase.Outputs) != 2 {
+		t.Errorf("expected commitment output to be appended")
+		return
+	} else if actual := hex.EncodeToString(coinbase.Outputs[1].Script); actual != expected {
+		t.Errorf("witness commitment script does not match\nWanted %s\nGot    %s", expected, actual)
+		return
+	}
+
+	reservedValue, err := CoinbaseWitnessReservedValue(coinbase)
+	if err != nil {
+		t.Errorf("failed to get reserved value: %s", err)
+		return
+	} else if reservedValue != DefaultWitnessReservedValue {
+		t.Errorf("unexpected reserved value: %x", reservedValue)
+		return
+	}
+}
+
+func TestSetWitnessCommitment(t *testing.T) {
+	coinbase := makeTestCoinbase()
+	spend := &tx.Tx{
+		Version: 2,
+		Inputs: []*tx.Input{{
+			PrevOut:  &tx.PrevOut{Hash: [32]byte{1}},
+			Script:   []byte{},
+			Sequence: 0xffffffff,
+		}},
+		Outputs:   []*tx.Output{{Value: 1000, Script: []byte{constants.OP_TRUE}}},
+		Witnesses: []tx.Witness{{{0xab, 0xcd}}},
+	}
+	txs := []*tx.Tx{coinbase, spend}
+
+	reservedValue := [32]byte{0xff}
+	if err := SetWitnessCommitment(txs, reservedValue); err != nil {
+		t.Errorf("failed to set witness commitment: %s", err)
+		return
+	}
+
+	wtxid, err := spend.Hash(true)
+	if err != nil {
+		t.Errorf("failed to hash tx: %s", err)
+		return
+	}
+	expectedRoot := MerkleRootHashInternal([][32]byte{{}, wtxid})
+
+	witnessRoot, err := WitnessMerkleRoot(txs)
+	if err != nil {
+		t.Errorf("failed to compute witness root: %s", err)
+		return
+	} else if witnessRoot != expectedRoot {
+		t.Errorf("witness merkle root does not match\nWanted %x\nGot    %x", expectedRoot, witnessRoot)
+		return
+	}
+
+	index, commitment := FindWitnessCommitment(coinbase)
+	if index != 1 {
+		t.Errorf("expected commitment at output 1, got %d", index)
+		return
+	} else if commitment != WitnessCommitment(expectedRoot, reservedValue) {
+		t.Errorf("commitment does not match")
+		return
+	}
+
+	// Setting the commitment again replaces the existing output.
+	spend.Witnesses[0][0] = []byte{0xef}
+	if err := SetWitnessCommitment(txs, reservedValue); err != nil {
+		t.Errorf("failed to set witness commitment: %s", err)
+		return
+	} else if len(coinbase.Outputs) != 2 {
+		t.Errorf("expected commitment output to be replaced, got %d outputs", len(coinbase.Outputs))
+		return
+	} else if _, updated := FindWitnessCommitment(coinbase); updated == commitment {
+		t.Errorf("expected commitment to change")
+		return
+	}
+}
+
+func TestParseWitnessCommitment(t *testing.T) {
+	commitment := [32]byte{1, 2, 3}
+	outputScript := WitnessCommitmentScript(commitment)
+
+	if parsed, ok := ParseWitnessCommitment(outputScript); !ok || parsed != commitment {
+		t.Errorf("failed to parse witness commitment script")
+		return
+	}
+
+	// Extra data after the commitment is allowed.
+	if parsed, ok := ParseWitnessCommitment(append(outputScript, 0x01)); !ok || parsed != commitment {
+		t.Errorf("failed to parse witness commitment script with trailing data")
+		return
+	}
+
+	if _, ok := ParseWitnessCommitment(outputScript[:37]); ok {
+		t.Errorf("parsed truncated witness commitment script")
+		return
+	}
+
+	if index, _ := FindWitnessCommitment(makeTestCoinbase()); index != -1 {
+		t.Errorf("found witness commitment in coinbase without one")
+		return
+	}
+}
+
+func TestCoinbaseWitnessReservedValue(t *testing.T) {
+	coinbase := makeTestCoinbase()
+	if _, err := CoinbaseWitnessReservedValue(coinbase); !errors.Is(err, ErrInvalidWitnessReservedValue) {
+		t.Errorf("expected ErrInvalidWitnessReservedValue, got %v", err)
+		return
+	}
+
+	coinbase.Witnesses = []tx.Witness{{make([]byte, 31)}}
+	if _, err := CoinbaseWitnessReservedValue(coinbase); !errors.Is(err, ErrInvalidWitnessReservedValue) {
+		t.Errorf("expected ErrInvalidWitnessReservedValue, got %v", err)
+		return
+	}
+
+	if _, err := WitnessMerkleRoot(nil); !errors.Is(err, ErrNoTransactions) {
+		t.Errorf("expected ErrNoTransactions, got %v", err)
+		return
+	}
+}
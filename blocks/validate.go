@@ -0,0 +1,584 @@
+package blocks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/kklash/bitcoinlib/bhash"
+	"github.com/kklash/bitcoinlib/blocks/merkle"
+	"github.com/kklash/bitcoinlib/common"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/varint"
+)
+
+const (
+	// MaxBlockWeight is the maximum weight of a block, as per BIP141.
+	MaxBlockWeight = 4000000
+
+	// MaxBlockSigOpsCost is the maximum total signature operation cost of a block.
+	// Legacy and P2SH signature operations cost 4, and witness signature operations cost 1.
+	MaxBlockSigOpsCost = 80000
+
+	// WitnessScaleFactor is the weight of each byte of non-witness data, relative to witness data.
+	WitnessScaleFactor = 4
+
+	// MaxMoney is the maximum number of satoshis which can exist. Output values above it are invalid.
+	MaxMoney uint64 = 21000000 * constants.SatoshisPerBitcoin
+
+	// SubsidyHalvingInterval is the number of blocks after which the block subsidy is halved.
+	SubsidyHalvingInterval = 210000
+
+	// CoinbaseScriptMinSize and CoinbaseScriptMaxSize are the limits on the size of
+	// the coinbase input script.
+	CoinbaseScriptMinSize = 2
+	CoinbaseScriptMaxSize = 100
+
+	// CoinbaseMaturity is the number of blocks after which coinbase outputs may be spent.
+	CoinbaseMaturity = 100
+)
+
+// Activation heights of soft forks on mainnet which affect block validation.
+const (
+	MainnetBIP34Height  = 227931
+	MainnetBIP113Height = 419328
+	MainnetSegwitHeight = 481824
+)
+
+var (
+	// ErrNoTransactions is returned by Validate if a block has no transactions.
+	ErrNoTransactions = errors.New("block has no transactions")
+
+	// ErrBadMerkleRoot is returned by Validate if the header's merkle root does not
+	// match the transactions in the block.
+	ErrBadMerkleRoot = errors.New("block merkle root does not match transactions")
+
+	// ErrMutatedMerkleTree is returned by Validate if the block's merkle tree contains
+	// duplicate subtrees, which allows different transaction lists to produce the same
+	// merkle root, as described in CVE-2012-2459.
+	ErrMutatedMerkleTree = errors.New("block merkle tree is mutated by duplicate transactions")
+
+	// ErrBadCoinbase is returned by Validate if the first transaction of a block is not a
+	// coinbase, if any other transaction is a coinbase, or if the coinbase script is invalid.
+	ErrBadCoinbase = errors.New("block has an invalid coinbase transaction")
+
+	// ErrBadCoinbaseHeight is returned by Validate if BIP34 is enforced and the coinbase
+	// script does not begin with the block height.
+	ErrBadCoinbaseHeight = errors.New("block coinbase does not commit to block height")
+
+	// ErrBadWitnessCommitment is returned by Validate if the coinbase witness commitment
+	// does not match the witness data of the block.
+	ErrBadWitnessCommitment = errors.New("block witness commitment is invalid")
+
+	// ErrUnexpectedWitness is returned by Validate if a block contains witness data
+	// without a witness commitment, or before segwit is enforced.
+	ErrUnexpectedWitness = errors.New("block has unexpected witness data")
+
+	// ErrBlockTooLarge is returned by Validate if the block exceeds MaxBlockWeight.
+	ErrBlockTooLarge = errors.New("block is too large")
+
+	// ErrTooManySigOps is returned by Validate if the block exceeds MaxBlockSigOpsCost.
+	ErrTooManySigOps = errors.New("block has too many signature operations")
+
+	// ErrBadTransaction is returned by Validate if a transaction in the block is malformed,
+	// such as having no inputs or outputs, duplicate inputs, or output values out of range.
+	ErrBadTransaction = errors.New("block contains an invalid transaction")
+
+	// ErrNonFinalTransaction is returned by Validate if the block contains a
+	// transaction whose locktime has not yet passed.
+	ErrNonFinalTransaction = errors.New("block contains a non-final transaction")
+
+	// ErrInsufficientInputValue is returned by Validate if a transaction in the block
+	// spends more value than its inputs provide.
+	ErrInsufficientInputValue = errors.New("transaction output value exceeds input value")
+
+	// ErrCoinbaseValueTooHigh is returned by Validate if the coinbase outputs are worth
+	// more than the block subsidy plus the fees of the block's transactions.
+	ErrCoinbaseValueTooHigh = errors.New("block coinbase pays more than subsidy plus fees")
+
+	// ErrImmatureCoinbaseSpend is returned by Validate if a transaction in the block spends
+	// a coinbase output which was created fewer than CoinbaseMaturity blocks earlier.
+	ErrImmatureCoinbaseSpend = errors.New("transaction spends immature coinbase output")
+
+	// ErrCoinbaseMaturityUnchecked is returned by Validate if PrevOut is set without PrevCoin
+	// or SkipCoinbaseMaturity, since PrevOut cannot tell which outputs were created by a coinbase.
+	ErrCoinbaseMaturityUnchecked = errors.New("coinbase maturity cannot be checked without PrevCoin")
+)
+
+// Coin is an output spent by a block, along with the context of the transaction
+// which created it, which is needed to enforce coinbase maturity.
+type Coin struct {
+	// Output is the spent output.
+	Output *tx.Output
+
+	// Height is the height of the block which created the output.
+	Height int
+
+	// Coinbase is true if the output was created by a coinbase transaction.
+	Coinbase bool
+}
+
+// ValidationOptions provides the context needed to validate a block against the consensus rules.
+type ValidationOptions struct {
+	// Height is the height of the block in the chain.
+	Height int
+
+	// MedianTimePast is the median time of the 11 blocks before this one.
+	// It is used to check transaction finality if EnforceBIP113 is true.
+	MedianTimePast uint32
+
+	// EnforceBIP34 requires the coinbase script to begin with the block height.
+	EnforceBIP34 bool
+
+	// EnforceBIP113 checks transaction finality against MedianTimePast instead of the block time.
+	EnforceBIP113 bool
+
+	// EnforceSegwit checks the witness commitment, and counts witness signature operations.
+	// Before segwit is enforced, blocks must not contain witness data.
+	EnforceSegwit bool
+
+	// PrevOut returns the output spent by the given PrevOut, if it was not created in the block
+	// itself. It is needed to check fees, the coinbase value, P2SH and witness signature
+	// operations, and scripts, which are skipped if PrevOut is nil. It should return an
+	// error if the output does not exist or was already spent.
+	PrevOut func(*tx.PrevOut) (*tx.Output, error)
+
+	// PrevCoin is used instead of PrevOut if it is set. It returns the output spent by the
+	// given PrevOut as a Coin, which allows coinbase maturity to be checked as well.
+	PrevCoin func(*tx.PrevOut) (*Coin, error)
+
+	// SkipCoinbaseMaturity must be set to use PrevOut without PrevCoin. It acknowledges that
+	// spends of immature coinbase outputs from earlier blocks will not be detected.
+	SkipCoinbaseMaturity bool
+
+	// CheckScripts is called to verify the input scripts of each non-coinbase transaction,
+	// with the outputs spent by each input. Script checks are skipped if CheckScripts is
+	// nil, or if both PrevOut and PrevCoin are nil. For example, a caller can use interpreter.VerifyTx here.
+	CheckScripts func(txn *tx.Tx, prevOuts []*tx.Output) error
+}
+
+// MainnetValidationOptions returns ValidationOptions for a block at the given height on mainnet,
+// enforcing the soft forks which are active at that height. The caller may set PrevOut or
+// PrevCoin, and CheckScripts, to enable fee, signature operation and script checks.
+func MainnetValidationOptions(height int, medianTimePast uint32) *ValidationOptions {
+	return &ValidationOptions{
+		Height:         height,
+		MedianTimePast: medianTimePast,
+		EnforceBIP34:   height >= MainnetBIP34Height,
+		EnforceBIP113:  height >= MainnetBIP113Height,
+		EnforceSegwit:  height >= MainnetSegwitHeight,
+	}
+}
+
+// BlockSubsidy returns the number of new satoshis which the coinbase of a
+// block at the given height may create, in addition to transaction fees.
+func BlockSubsidy(height int) uint64 {
+	halvings := height / SubsidyHalvingInterval
+	if halvings >= 64 {
+		return 0
+	}
+	return (50 * constants.SatoshisPerBitcoin) >> halvings
+}
+
+// Validate checks the block against the consensus rules. If opts is nil, only context-free
+// checks are performed: the merkle root and CVE-2012-2459 mutation, the coinbase structure,
+// transaction sanity, the block size, and legacy signature operations. Otherwise, the
+// block is also checked for transaction finality, the BIP34 coinbase height, the witness
+// commitment, and weight. If opts.PrevOut or opts.PrevCoin is set, fees, the coinbase value
+// and the total signature operation cost are checked, and scripts are verified with
+// opts.CheckScripts. Coinbase maturity is checked with opts.PrevCoin, and opts.PrevOut may
+// only be used without it if opts.SkipCoinbaseMaturity is set.
+//
+// Returns an error wrapping one of the Err* values in this package describing
+// the first rule which the block breaks.
+func (block *Block) Validate(opts *ValidationOptions) error {
+	if err := block.checkContextFree(); err != nil {
+		return err
+	}
+	if opts == nil {
+		return nil
+	}
+
+	if err := block.checkContextual(opts); err != nil {
+		return err
+	}
+	if opts.PrevOut == nil && opts.PrevCoin == nil {
+		return nil
+	} else if opts.PrevCoin == nil && !opts.SkipCoinbaseMaturity {
+		return ErrCoinbaseMaturityUnchecked
+	}
+
+	return block.checkInputs(opts)
+}
+
+// checkContextFree implements the checks of Bitcoin Core's CheckBlock.
+func (block *Block) checkContextFree() error {
+	if block.Header == nil || len(block.Transactions) == 0 {
+		return ErrNoTransactions
+	}
+
+	if err := block.CheckMerkleRoot(); err != nil {
+		return err
+	}
+
+	strippedSize := block.Header.Size() + varint.VarInt(len(block.Transactions)).Size()
+	for _, txn := range block.Transactions {
+		strippedSize += txn.SizeNoWitness()
+	}
+	if len(block.Transactions)*WitnessScaleFactor > MaxBlockWeight || strippedSize*WitnessScaleFactor > MaxBlockWeight {
+		return fmt.Errorf("%w: stripped size is %d bytes", ErrBlockTooLarge, strippedSize)
+	}
+
+	if !block.Transactions[0].IsCoinbase() {
+		return fmt.Errorf("%w: first transaction is not a coinbase", ErrBadCoinbase)
+	}
+
+	sigOps := 0
+	for i, txn := range block.Transactions {
+		if i > 0 && txn.IsCoinbase() {
+			return fmt.Errorf("%w: transaction %d is a second coinbase", ErrBadCoinbase, i)
+		} else if err := checkTransaction(txn); err != nil {
+			return fmt.Errorf("transaction %d: %w", i, err)
+		}
+		sigOps += legacySigOps(txn)
+	}
+
+	if sigOps*WitnessScaleFactor > MaxBlockSigOpsCost {
+		return fmt.Errorf("%w: %d legacy signature operations", ErrTooManySigOps, sigOps)
+	}
+
+	return nil
+}
+
+// CheckMerkleRoot checks that the block's transactions match the merkle root in its header,
+// and that the merkle tree is not mutated by duplicate transactions. This shows that the
+// transactions are those committed to by the header, but not that they are valid. Returns an
+// error wrapping ErrNoTransactions, ErrBadTransaction, ErrBadMerkleRoot or ErrMutatedMerkleTree.
+func (block *Block) CheckMerkleRoot() error {
+	if block.Header == nil || len(block.Transactions) == 0 {
+		return ErrNoTransactions
+	}
+
+	hashes := make([][32]byte, len(block.Transactions))
+	for i, txn := range block.Transactions {
+		hash, err := txn.Hash(false)
+		if err != nil {
+			return fmt.Errorf("%w: transaction %d: %s", ErrBadTransaction, i, err)
+		}
+		hashes[i] = hash
+	}
+
+	merkleRoot := merkle.MerkleRootHashInternal(hashes)
+	common.ReverseBytesInPlace(merkleRoot[:])
+	if merkleRoot != block.Header.MerkleRootHash {
+		return ErrBadMerkleRoot
+	}
+	if isMerkleTreeMutated(hashes) {
+		return ErrMutatedMerkleTree
+	}
+
+	return nil
+}
+
+// CheckWitnessData checks that the block's witness data is committed to by its coinbase, as
+// required once segwit is enforced. If the coinbase has a witness commitment, it must match the
+// block's witness merkle root. Otherwise, the block may not contain witness data. Together with
+// CheckMerkleRoot, this shows that the witness data was not altered after the block was mined.
+// Returns an error wrapping ErrNoTransactions, ErrBadWitnessCommitment or ErrUnexpectedWitness.
+func (block *Block) CheckWitnessData() error {
+	if len(block.Transactions) == 0 {
+		return ErrNoTransactions
+	}
+
+	if index, commitment := merkle.FindWitnessCommitment(block.Transactions[0]); index >= 0 {
+		return block.checkWitnessCommitment(commitment)
+	}
+
+	for i, txn := range block.Transactions {
+		if hasWitness(txn) {
+			return fmt.Errorf("%w: transaction %d", ErrUnexpectedWitness, i)
+		}
+	}
+	return nil
+}
+
+// checkTransaction implements the checks of Bitcoin Core's CheckTransaction.
+func checkTransaction(txn *tx.Tx) error {
+	if len(txn.Inputs) == 0 {
+		return fmt.Errorf("%w: no inputs", ErrBadTransaction)
+	} else if len(txn.Outputs) == 0 {
+		return fmt.Errorf("%w: no outputs", ErrBadTransaction)
+	} else if txn.SizeNoWitness()*WitnessScaleFactor > MaxBlockWeight {
+		return fmt.Errorf("%w: transaction is too large", ErrBadTransaction)
+	}
+
+	var totalOut uint64
+	for _, output := range txn.Outputs {
+		if output.Value > MaxMoney {
+			return fmt.Errorf("%w: output value %d is too high", ErrBadTransaction, output.Value)
+		}
+		totalOut += output.Value
+		if totalOut > MaxMoney {
+			return fmt.Errorf("%w: total output value is too high", ErrBadTransaction)
+		}
+	}
+
+	spent := make(map[tx.PrevOut]bool, len(txn.Inputs))
+	for _, input := range txn.Inputs {
+		if spent[*input.PrevOut] {
+			return fmt.Errorf("%w: duplicate input %s", ErrBadTransaction, input.PrevOut)
+		}
+		spent[*input.PrevOut] = true
+	}
+
+	if txn.IsCoinbase() {
+		if size := len(txn.Inputs[0].Script); size < CoinbaseScriptMinSize || size > CoinbaseScriptMaxSize {
+			return fmt.Errorf("%w: coinbase script size %d is out of range", ErrBadCoinbase, size)
+		}
+	} else {
+		for _, input := range txn.Inputs {
+			if input.PrevOut.Hash == [32]byte{} && input.PrevOut.Index == 0xffffffff {
+				return fmt.Errorf("%w: input spends null prevout", ErrBadTransaction)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkContextual implements the checks of Bitcoin Core's ContextualCheckBlock.
+func (block *Block) checkContextual(opts *ValidationOptions) error {
+	lockTimeCutoff := int64(block.Header.Time)
+	if opts.EnforceBIP113 {
+		lockTimeCutoff = int64(opts.MedianTimePast)
+	}
+
+	for i, txn := range block.Transactions {
+		if !txn.IsFinal(int64(opts.Height), lockTimeCutoff) {
+			return fmt.Errorf("%w: transaction %d", ErrNonFinalTransaction, i)
+		}
+	}
+
+	coinbase := block.Transactions[0]
+	if opts.EnforceBIP34 {
+		expected := script.PushNumber(int64(opts.Height))
+		if !bytes.HasPrefix(coinbase.Inputs[0].Script, expected) {
+			return fmt.Errorf("%w: expected height %d", ErrBadCoinbaseHeight, opts.Height)
+		}
+	}
+
+	if opts.EnforceSegwit {
+		if err := block.CheckWitnessData(); err != nil {
+			return err
+		}
+	} else {
+		for i, txn := range block.Transactions {
+			if hasWitness(txn) {
+				return fmt.Errorf("%w: transaction %d", ErrUnexpectedWitness, i)
+			}
+		}
+	}
+
+	if weight := block.WeightUnits(); weight > MaxBlockWeight {
+		return fmt.Errorf("%w: weight %d exceeds %d", ErrBlockTooLarge, weight, MaxBlockWeight)
+	}
+
+	return nil
+}
+
+// prevCoin looks up the output spent by prevOut with PrevCoin, or with PrevOut if
+// PrevCoin is not set, in which case the Coin is not known to be from a coinbase.
+func (opts *ValidationOptions) prevCoin(prevOut *tx.PrevOut) (*Coin, error) {
+	if opts.PrevCoin != nil {
+		return opts.PrevCoin(prevOut)
+	}
+
+	output, err := opts.PrevOut(prevOut)
+	if err != nil {
+		return nil, err
+	}
+	return &Coin{Output: output, Height: opts.Height}, nil
+}
+
+// checkInputs checks the values and signature operations of the outputs spent by the block,
+// coinbase maturity, and verifies scripts. It implements parts of Bitcoin Core's ConnectBlock.
+func (block *Block) checkInputs(opts *ValidationOptions) error {
+	// Outputs created by earlier non-coinbase transactions in the block may be spent by
+	// later transactions. The coinbase outputs cannot, since they are not yet mature, so
+	// spends of them are rejected even if PrevOut or PrevCoin can find them.
+	coinbaseHash, err := block.Transactions[0].Hash(false)
+	if err != nil {
+		return err
+	}
+	created := make(map[tx.PrevOut]*tx.Output)
+	spent := make(map[tx.PrevOut]bool)
+
+	var fees uint64
+	sigOpsCost := 0
+
+	for i, txn := range block.Transactions {
+		sigOpsCost += legacySigOps(txn) * WitnessScaleFactor
+
+		if i > 0 {
+			prevOuts := make([]*tx.Output, len(txn.Inputs))
+			var inputValue uint64
+
+			for j, input := range txn.Inputs {
+				if spent[*input.PrevOut] {
+					return fmt.Errorf("%w: transaction %d double-spends %s", ErrBadTransaction, i, input.PrevOut)
+				}
+				spent[*input.PrevOut] = true
+
+				if input.PrevOut.Hash == coinbaseHash {
+					return fmt.Errorf(
+						"%w: transaction %d input %d spends coinbase from the same block",
+						ErrImmatureCoinbaseSpend, i, j,
+					)
+				}
+
+				prevOut, ok := created[*input.PrevOut]
+				if !ok {
+					coin, err := opts.prevCoin(input.PrevOut)
+					if err != nil {
+						return fmt.Errorf("transaction %d input %d: %w", i, j, err)
+					} else if coin.Coinbase && opts.Height-coin.Height < CoinbaseMaturity {
+						return fmt.Errorf(
+							"%w: transaction %d input %d spends coinbase from height %d",
+							ErrImmatureCoinbaseSpend, i, j, coin.Height,
+						)
+					}
+					prevOut = coin.Output
+				}
+				prevOuts[j] = prevOut
+
+				inputValue += prevOut.Value
+				if prevOut.Value > MaxMoney || inputValue > MaxMoney {
+					return fmt.Errorf("%w: transaction %d input value out of range", ErrBadTransaction, i)
+				}
+
+				if script.IsP2SH(prevOut.Script) {
+					sigOpsCost += script.CountP2SHSigOps(input.Script) * WitnessScaleFactor
+				}
+				if opts.EnforceSegwit {
+					var witness [][]byte
+					if j < len(txn.Witnesses) {
+						witness = txn.Witnesses[j]
+					}
+					sigOpsCost += script.CountWitnessSigOps(input.Script, prevOut.Script, witness)
+				}
+			}
+
+			outputValue := totalOutputValue(txn)
+			if inputValue < outputValue {
+				return fmt.Errorf("%w: transaction %d", ErrInsufficientInputValue, i)
+			}
+			fees += inputValue - outputValue
+			if fees > MaxMoney {
+				return fmt.Errorf("%w: total fees out of range", ErrBadTransaction)
+			}
+
+			if opts.CheckScripts != nil {
+				if err := opts.CheckScripts(txn, prevOuts); err != nil {
+					return fmt.Errorf("transaction %d: %w", i, err)
+				}
+			}
+		}
+
+		if sigOpsCost > MaxBlockSigOpsCost {
+			return fmt.Errorf("%w: cost exceeds %d", ErrTooManySigOps, MaxBlockSigOpsCost)
+		}
+
+		if i == 0 {
+			continue
+		}
+		hash, err := txn.Hash(false)
+		if err != nil {
+			return err
+		}
+		for index, output := range txn.Outputs {
+			created[tx.PrevOut{Hash: hash, Index: uint32(index)}] = output
+		}
+	}
+
+	maxCoinbaseValue := BlockSubsidy(opts.Height) + fees
+	if coinbaseValue := totalOutputValue(block.Transactions[0]); coinbaseValue > maxCoinbaseValue {
+		return fmt.Errorf("%w: %d > %d", ErrCoinbaseValueTooHigh, coinbaseValue, maxCoinbaseValue)
+	}
+
+	return nil
+}
+
+// checkWitnessCommitment checks that the coinbase witness commitment matches the
+// witness merkle root of the block, and the coinbase witness reserved value.
+func (block *Block) checkWitnessCommitment(commitment [32]byte) error {
+	reservedValue, err := merkle.CoinbaseWitnessReservedValue(block.Transactions[0])
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBadWitnessCommitment, err)
+	}
+
+	witnessRoot, err := merkle.WitnessMerkleRoot(block.Transactions)
+	if err != nil {
+		return err
+	}
+
+	if merkle.WitnessCommitment(witnessRoot, reservedValue) != commitment {
+		return fmt.Errorf("%w: commitment does not match witness merkle root", ErrBadWitnessCommitment)
+	}
+
+	return nil
+}
+
+// isMerkleTreeMutated returns true if any level of the merkle tree of the given hashes
+// (in internal byte order) contains two identical adjacent nodes which are hashed
+// together. Such a tree has the same root as a tree with the duplicates removed.
+func isMerkleTreeMutated(hashes [][32]byte) bool {
+	for len(hashes) > 1 {
+		for i := 0; i+1 < len(hashes); i += 2 {
+			if hashes[i] == hashes[i+1] {
+				return true
+			}
+		}
+
+		if len(hashes)%2 == 1 {
+			hashes = append(hashes, hashes[len(hashes)-1])
+		}
+
+		nextRow := make([][32]byte, len(hashes)/2)
+		for i := range nextRow {
+			nextRow[i] = bhash.DoubleSha256(append(hashes[2*i][:], hashes[2*i+1][:]...))
+		}
+		hashes = nextRow
+	}
+
+	return false
+}
+
+func legacySigOps(txn *tx.Tx) int {
+	count := 0
+	for _, input := range txn.Inputs {
+		count += script.CountSigOps(input.Script, false)
+	}
+	for _, output := range txn.Outputs {
+		count += script.CountSigOps(output.Script, false)
+	}
+	return count
+}
+
+func hasWitness(txn *tx.Tx) bool {
+	for _, witness := range txn.Witnesses {
+		if len(witness) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func totalOutputValue(txn *tx.Tx) uint64 {
+	var total uint64
+	for _, output := range txn.Outputs {
+		total += output.Value
+	}
+	return total
+}
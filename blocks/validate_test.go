@@ -0,0 +1,412 @@
+package blocks
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+	"github.com/kklash/bitcoinlib/blocks/merkle"
+	"github.com/kklash/bitcoinlib/common"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+func loadFixtureBlocks(t *testing.T) map[int]*Block {
+	var fixtures []struct {
+		BlockNumber int    `json:"blockNumber"`
+		RawHex      string `json:"raw"`
+	}
+
+	data, err := os.ReadFile("fixtures.json")
+	if err != nil {
+		t.Fatalf("failed to read block fixtures file: %s", err)
+	}
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatalf("failed to decode block fixtures JSON: %s", err)
+	}
+
+	blocks := make(map[int]*Block)
+	for _, fixture := range fixtures {
+		blockBytes, err := hex.DecodeString(fixture.RawHex)
+		if err != nil {
+			t.Fatalf("failed to decode block fixture hex: %s", err)
+		}
+		block, err := FromReader(bytes.NewReader(blockBytes))
+		if err != nil {
+			t.Fatalf("failed to decode block %d: %s", fixture.BlockNumber, err)
+		}
+		blocks[fixture.BlockNumber] = block
+	}
+	return blocks
+}
+
+// setMerkleRoot updates the block header to commit to the block's transactions.
+func setMerkleRoot(t *testing.T, block *Block) {
+	hashes := make([][32]byte, len(block.Transactions))
+	for i, txn := range block.Transactions {
+		hash, err := txn.Hash(false)
+		if err != nil {
+			t.Fatalf("failed to hash tx: %s", err)
+		}
+		hashes[i] = hash
+	}
+	block.Header.MerkleRootHash = merkle.MerkleRootHashInternal(hashes)
+	common.ReverseBytesInPlace(block.Header.MerkleRootHash[:])
+}
+
+func makeCoinbase(height int, value uint64) *tx.Tx {
+	return &tx.Tx{
+		Version: 1,
+		Inputs: []*tx.Input{{
+			PrevOut:  &tx.PrevOut{Index: 0xffffffff},
+			Script:   append(script.PushNumber(int64(height)), 0x00),
+			Sequence: tx.SequenceFinal,
+		}},
+		Outputs: []*tx.Output{{Value: value, Script: []byte{constants.OP_TRUE}}},
+	}
+}
+
+func makeSpend(prevOut *tx.PrevOut, value uint64) *tx.Tx {
+	return &tx.Tx{
+		Version: 2,
+		Inputs: []*tx.Input{{
+			PrevOut:  prevOut,
+			Script:   []byte{},
+			Sequence: tx.SequenceFinal,
+		}},
+		Outputs: []*tx.Output{{Value: value, Script: []byte{constants.OP_TRUE}}},
+	}
+}
+
+func mustTxHash(t *testing.T, txn *tx.Tx) [32]byte {
+	hash, err := txn.Hash(false)
+	if err != nil {
+		t.Fatalf("failed to hash tx: %s", err)
+	}
+	return hash
+}
+
+func TestBlockValidate_Fixtures(t *testing.T) {
+	for height, block := range loadFixtureBlocks(t) {
+		if err := block.Validate(nil); err != nil {
+			t.Errorf("block %d failed context-free validation: %s", height, err)
+			continue
+		}
+
+		opts := MainnetValidationOptions(height, block.Header.Time-1)
+		if err := block.Validate(opts); err != nil {
+			t.Errorf("block %d failed contextual validation: %s", height, err)
+			continue
+		}
+	}
+}
+
+func TestBlockValidate_MutatedMerkleTree(t *testing.T) {
+	blocks := loadFixtureBlocks(t)
+
+	// Block 125202 has 9 transactions. Duplicating the last one does not change the merkle root.
+	block := blocks[125202]
+	mutated := &Block{
+		Header:       block.Header,
+		Transactions: append(append([]*tx.Tx{}, block.Transactions...), block.Transactions[8]),
+	}
+
+	// Block 2812 has 6 transactions. The second level of its tree has 3 nodes, so
+	// duplicating the last two transactions does not change the merkle root either.
+	block = blocks[2812]
+	mutated2 := &Block{
+		Header:       block.Header,
+		Transactions: append(append([]*tx.Tx{}, block.Transactions...), block.Transactions[4:]...),
+	}
+
+	for _, block := range []*Block{mutated, mutated2} {
+		if err := block.Validate(nil); !errors.Is(err, ErrMutatedMerkleTree) {
+			t.Errorf("expected ErrMutatedMerkleTree, got %v", err)
+			continue
+		}
+	}
+
+	swapped := &Block{
+		Header:       block.Header,
+		Transactions: append([]*tx.Tx{}, block.Transactions...),
+	}
+	swapped.Transactions[1], swapped.Transactions[2] = swapped.Transactions[2], swapped.Transactions[1]
+	if err := swapped.Validate(nil); !errors.Is(err, ErrBadMerkleRoot) {
+		t.Errorf("expected ErrBadMerkleRoot, got %v", err)
+		return
+	}
+}
+
+func TestBlockValidate(t *testing.T) {
+	const height = 500000
+	subsidy := BlockSubsidy(height)
+
+	fundingOutPoint := &tx.PrevOut{Hash: [32]byte{1}, Index: 3}
+	fundingOutput := &tx.Output{Value: 100000, Script: []byte{constants.OP_TRUE}}
+	prevOutFunc := func(prevOut *tx.PrevOut) (*tx.Output, error) {
+		if *prevOut == *fundingOutPoint {
+			return fundingOutput, nil
+		}
+		return nil, errors.New("unknown prevout")
+	}
+
+	// newBlock creates a block where the coinbase claims the given fee, which spends
+	// fundingOutput, and whose second tx spends the output of the first.
+	newBlock := func(t *testing.T, coinbaseFee uint64, modify func(block *Block)) *Block {
+		spend1 := makeSpend(fundingOutPoint, 90000)
+		spend2 := makeSpend(&tx.PrevOut{Hash: mustTxHash(t, spend1)}, 85000)
+		block := &Block{
+			Header:       &blockheader.BlockHeader{Version: 4, Time: 1600000000},
+			Transactions: []*tx.Tx{makeCoinbase(height, subsidy+coinbaseFee), spend1, spend2},
+		}
+		if modify != nil {
+			modify(block)
+		}
+		setMerkleRoot(t, block)
+		return block
+	}
+
+	newOpts := func() *ValidationOptions {
+		opts := MainnetValidationOptions(height, 1600000000)
+		opts.PrevOut = prevOutFunc
+		opts.SkipCoinbaseMaturity = true
+		return opts
+	}
+
+	type Fixture struct {
+		name        string
+		block       *Block
+		opts        *ValidationOptions
+		expectedErr error
+	}
+
+	noCoinbase := newBlock(t, 0, func(block *Block) { block.Transactions = block.Transactions[1:] })
+	noInputs := newBlock(t, 0, func(block *Block) { block.Transactions[1].Inputs = []*tx.Input{} })
+	secondCoinbase := newBlock(t, 0, func(block *Block) {
+		block.Transactions = append(block.Transactions, makeCoinbase(height, 1))
+	})
+	duplicateInputs := newBlock(t, 0, func(block *Block) {
+		txn := block.Transactions[1]
+		txn.Inputs = append(txn.Inputs, txn.Inputs[0])
+	})
+	excessiveValue := newBlock(t, 0, func(block *Block) { block.Transactions[1].Outputs[0].Value = MaxMoney + 1 })
+	shortCoinbaseScript := newBlock(t, 0, func(block *Block) { block.Transactions[0].Inputs[0].Script = []byte{1} })
+	nonFinal := newBlock(t, 0, func(block *Block) {
+		block.Transactions[2].Locktime = height + 1
+		block.Transactions[2].Inputs[0].Sequence = 0
+	})
+	badHeight := newBlock(t, 0, func(block *Block) {
+		block.Transactions[0].Inputs[0].Script = append(script.PushNumber(height-1), 0x00)
+	})
+	overspend := newBlock(t, 0, func(block *Block) { block.Transactions[2].Outputs[0].Value = 95000 })
+	unknownPrevOut := newBlock(t, 0, func(block *Block) {
+		block.Transactions[1].Inputs[0].PrevOut = &tx.PrevOut{Hash: [32]byte{2}}
+	})
+	doubleSpend := newBlock(t, 0, func(block *Block) {
+		block.Transactions = append(block.Transactions, makeSpend(fundingOutPoint, 1000))
+	})
+
+	withWitness := func(block *Block) {
+		block.Transactions[1].Witnesses = []tx.Witness{{{0xab}}}
+	}
+	unexpectedWitness := newBlock(t, 0, withWitness)
+	committedWitness := newBlock(t, 0, func(block *Block) {
+		withWitness(block)
+		if err := merkle.SetWitnessCommitment(block.Transactions, merkle.DefaultWitnessReservedValue); err != nil {
+			t.Fatalf("failed to set witness commitment: %s", err)
+		}
+	})
+	badCommitment := newBlock(t, 0, func(block *Block) {
+		withWitness(block)
+		if err := merkle.SetWitnessCommitment(block.Transactions, merkle.DefaultWitnessReservedValue); err != nil {
+			t.Fatalf("failed to set witness commitment: %s", err)
+		}
+		block.Transactions[0].Witnesses[0][0] = bytes.Repeat([]byte{1}, 32)
+	})
+
+	preSegwitOpts := newOpts()
+	preSegwitOpts.EnforceSegwit = false
+
+	uncheckedMaturityOpts := newOpts()
+	uncheckedMaturityOpts.SkipCoinbaseMaturity = false
+
+	fixtures := []Fixture{
+		{"valid", newBlock(t, 15000, nil), newOpts(), nil},
+		{"valid without prevouts", newBlock(t, 1e6, nil), MainnetValidationOptions(height, 1600000000), nil},
+		{"context-free", newBlock(t, 1e6, nil), nil, nil},
+		{"no transactions", &Block{Header: &blockheader.BlockHeader{}}, nil, ErrNoTransactions},
+		{"no coinbase", noCoinbase, nil, ErrBadCoinbase},
+		{"second coinbase", secondCoinbase, nil, ErrBadCoinbase},
+		{"no inputs", noInputs, nil, ErrBadTransaction},
+		{"duplicate inputs", duplicateInputs, nil, ErrBadTransaction},
+		{"excessive output value", excessiveValue, nil, ErrBadTransaction},
+		{"short coinbase script", shortCoinbaseScript, nil, ErrBadCoinbase},
+		{"non-final", nonFinal, newOpts(), ErrNonFinalTransaction},
+		{"bad coinbase height", badHeight, newOpts(), ErrBadCoinbaseHeight},
+		{"bad coinbase height before BIP34", badHeight, MainnetValidationOptions(1000, 1600000000), nil},
+		{"coinbase value too high", newBlock(t, 15001, nil), newOpts(), ErrCoinbaseValueTooHigh},
+		{"overspend", overspend, newOpts(), ErrInsufficientInputValue},
+		{"double spend", doubleSpend, newOpts(), ErrBadTransaction},
+		{"unexpected witness", unexpectedWitness, newOpts(), ErrUnexpectedWitness},
+		{"committed witness", committedWitness, newOpts(), nil},
+		{"committed witness before segwit", committedWitness, preSegwitOpts, ErrUnexpectedWitness},
+		{"bad witness commitment", badCommitment, newOpts(), ErrBadWitnessCommitment},
+		{"prevout without coinbase maturity", newBlock(t, 15000, nil), uncheckedMaturityOpts, ErrCoinbaseMaturityUnchecked},
+	}
+
+	for _, fixture := range fixtures {
+		err := fixture.block.Validate(fixture.opts)
+		if fixture.expectedErr == nil && err != nil {
+			t.Errorf("%s: unexpected error: %s", fixture.name, err)
+			continue
+		} else if !errors.Is(err, fixture.expectedErr) {
+			t.Errorf("%s: expected error %v, got %v", fixture.name, fixture.expectedErr, err)
+			continue
+		}
+	}
+
+	if err := unknownPrevOut.Validate(newOpts()); err == nil {
+		t.Errorf("expected error for unknown prevout")
+		return
+	}
+
+	// The coinbase outputs cannot be spent in the same block, even if the caller's lookup
+	// functions can find them.
+	spendsCoinbase := newBlock(t, 0, func(block *Block) {
+		coinbaseOutPoint := &tx.PrevOut{Hash: mustTxHash(t, block.Transactions[0])}
+		block.Transactions = append(block.Transactions, makeSpend(coinbaseOutPoint, 1000))
+	})
+	coinbaseHash := mustTxHash(t, spendsCoinbase.Transactions[0])
+	findCoinbase := func(prevOut *tx.PrevOut) (*tx.Output, error) {
+		if prevOut.Hash == coinbaseHash {
+			return spendsCoinbase.Transactions[0].Outputs[prevOut.Index], nil
+		}
+		return prevOutFunc(prevOut)
+	}
+
+	viaPrevOut := newOpts()
+	viaPrevOut.PrevOut = findCoinbase
+	if err := spendsCoinbase.Validate(viaPrevOut); !errors.Is(err, ErrImmatureCoinbaseSpend) {
+		t.Errorf("expected ErrImmatureCoinbaseSpend for block spending its own coinbase via PrevOut, got %v", err)
+		return
+	}
+
+	viaPrevCoin := MainnetValidationOptions(height, 1600000000)
+	viaPrevCoin.PrevCoin = func(prevOut *tx.PrevOut) (*Coin, error) {
+		output, err := findCoinbase(prevOut)
+		if err != nil {
+			return nil, err
+		}
+		return &Coin{Output: output, Height: height - CoinbaseMaturity}, nil
+	}
+	if err := spendsCoinbase.Validate(viaPrevCoin); !errors.Is(err, ErrImmatureCoinbaseSpend) {
+		t.Errorf("expected ErrImmatureCoinbaseSpend for block spending its own coinbase via PrevCoin, got %v", err)
+		return
+	}
+}
+
+func TestBlockValidate_CoinbaseMaturity(t *testing.T) {
+	const height = 500000
+
+	fundingOutPoint := &tx.PrevOut{Hash: [32]byte{1}}
+	spend := makeSpend(fundingOutPoint, 1000)
+	block := &Block{
+		Header:       &blockheader.BlockHeader{Version: 4, Time: 1600000000},
+		Transactions: []*tx.Tx{makeCoinbase(height, BlockSubsidy(height)), spend},
+	}
+	setMerkleRoot(t, block)
+
+	fixtures := []struct {
+		coin        *Coin
+		expectedErr error
+	}{
+		{&Coin{Height: height - CoinbaseMaturity + 1, Coinbase: true}, ErrImmatureCoinbaseSpend},
+		{&Coin{Height: height - 1, Coinbase: true}, ErrImmatureCoinbaseSpend},
+		{&Coin{Height: height - CoinbaseMaturity, Coinbase: true}, nil},
+		{&Coin{Height: height - 1, Coinbase: false}, nil},
+	}
+
+	for _, fixture := range fixtures {
+		fixture.coin.Output = &tx.Output{Value: 5000, Script: []byte{constants.OP_TRUE}}
+
+		opts := MainnetValidationOptions(height, 1600000000)
+		opts.PrevCoin = func(prevOut *tx.PrevOut) (*Coin, error) {
+			if *prevOut != *fundingOutPoint {
+				return nil, errors.New("unknown prevout")
+			}
+			return fixture.coin, nil
+		}
+
+		err := block.Validate(opts)
+		if fixture.expectedErr == nil && err != nil {
+			t.Errorf("unexpected error spending coin %+v: %s", fixture.coin, err)
+			continue
+		} else if !errors.Is(err, fixture.expectedErr) {
+			t.Errorf("expected error %v spending coin %+v, got %v", fixture.expectedErr, fixture.coin, err)
+			continue
+		}
+	}
+}
+
+func TestBlockValidate_CheckScripts(t *testing.T) {
+	fundingOutPoint := &tx.PrevOut{Hash: [32]byte{1}}
+	fundingOutput := &tx.Output{Value: 5000, Script: []byte{constants.OP_TRUE}}
+
+	spend := makeSpend(fundingOutPoint, 4000)
+	block := &Block{
+		Header:       &blockheader.BlockHeader{Version: 1, Time: 1300000000},
+		Transactions: []*tx.Tx{makeCoinbase(1, BlockSubsidy(1)), spend},
+	}
+	setMerkleRoot(t, block)
+
+	scriptErr := errors.New("script failed")
+	calls := 0
+
+	opts := MainnetValidationOptions(1, 1300000000)
+	opts.PrevOut = func(*tx.PrevOut) (*tx.Output, error) { return fundingOutput, nil }
+	opts.SkipCoinbaseMaturity = true
+	opts.CheckScripts = func(txn *tx.Tx, prevOuts []*tx.Output) error {
+		calls++
+		if txn != spend || len(prevOuts) != 1 || prevOuts[0] != fundingOutput {
+			t.Errorf("CheckScripts called with unexpected arguments")
+		}
+		return scriptErr
+	}
+
+	if err := block.Validate(opts); !errors.Is(err, scriptErr) {
+		t.Errorf("expected script error, got %v", err)
+		return
+	} else if calls != 1 {
+		t.Errorf("expected CheckScripts to be called once, got %d", calls)
+		return
+	}
+
+	opts.CheckScripts = nil
+	if err := block.Validate(opts); err != nil {
+		t.Errorf("expected block to be valid when skipping script checks: %s", err)
+		return
+	}
+}
+
+func TestBlockSubsidy(t *testing.T) {
+	fixtures := map[int]uint64{
+		0:        5000000000,
+		209999:   5000000000,
+		210000:   2500000000,
+		630000:   625000000,
+		840000:   312500000,
+		13440000: 0,
+	}
+
+	for height, expected := range fixtures {
+		if subsidy := BlockSubsidy(height); subsidy != expected {
+			t.Errorf("unexpected subsidy at height %d: wanted %d, got %d", height, expected, subsidy)
+			continue
+		}
+	}
+}
@@ -3,42 +3,66 @@
 package blockscan
 
 import (
-	"encoding/hex"
-	"strings"
+	"context"
 
 	"github.com/kklash/bitcoinlib/blocks"
 	"github.com/kklash/bitcoinlib/rpc"
 )
 
-// BlockScanner is used to fetch and stream blocks from an RPC connection. It can store a context which
-// allows the caller to cancel block streaming.
+// DefaultBatchSize is the default number of blocks requested by a BlockScanner in each RPC batch.
+const DefaultBatchSize = 8
+
+// BlockScanner is used to fetch and stream blocks from an RPC connection.
 type BlockScanner struct {
 	Connection *rpc.Connection
+
+	// BatchSize is the number of blocks each of the scanner's streaming workers requests
+	// from the node in a single JSON-RPC batch.
+	BatchSize uint32
 }
 
 // NewBlockScanner returns a pointer to a BlockScanner which will fetch blocks from the given rpc.Connection.
 func NewBlockScanner(conn *rpc.Connection) *BlockScanner {
-	return &BlockScanner{Connection: conn}
+	return &BlockScanner{
+		Connection: conn,
+		BatchSize:  DefaultBatchSize,
+	}
 }
 
 // GetBlockByHeight returns a pointer to a blocks.Block containing the block data at the given height.
 func (scanner *BlockScanner) GetBlockByHeight(height uint32) (*blocks.Block, error) {
-	blockHash, err := scanner.Connection.Request("getblockhash", height)
+	return scanner.GetBlockByHeightContext(context.Background(), height)
+}
+
+// GetBlockByHeightContext is similar to scanner.GetBlockByHeight, except the RPC
+// requests are canceled if the given context is canceled or its deadline passes.
+func (scanner *BlockScanner) GetBlockByHeightContext(ctx context.Context, height uint32) (*blocks.Block, error) {
+	blockHash, err := scanner.Connection.GetBlockHash(ctx, int(height))
 	if err != nil {
 		return nil, err
 	}
 
-	blockHex, err := scanner.Connection.Request("getblock", blockHash, 0)
+	block, err := scanner.Connection.GetBlock(ctx, blockHash)
 	if err != nil {
 		return nil, err
 	}
 
-	hexReader := hex.NewDecoder(strings.NewReader(blockHex.(string)))
+	return block, nil
+}
 
-	block, err := blocks.FromReader(hexReader)
+// GetBlocksByHeight returns the blocks at each of the given heights. The block hashes and
+// the blocks are each fetched in a single JSON-RPC batch, so all the blocks are held in memory
+// at once.
+func (scanner *BlockScanner) GetBlocksByHeight(ctx context.Context, heights ...uint32) ([]*blocks.Block, error) {
+	intHeights := make([]int, len(heights))
+	for i, height := range heights {
+		intHeights[i] = int(height)
+	}
+
+	blockHashes, err := scanner.Connection.GetBlockHashes(ctx, intHeights...)
 	if err != nil {
 		return nil, err
 	}
 
-	return block, nil
+	return scanner.Connection.GetBlocks(ctx, blockHashes...)
 }
@@ -0,0 +1,283 @@
+package blockscan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/unspent"
+	"github.com/kklash/bitcoinlib/varint"
+)
+
+// MaxReorgDepth is the number of most recently connected blocks for which a ScanState
+// keeps undo data. Deeper chain reorganizations cannot be rolled back. It matches the
+// number of blocks a pruned Bitcoin Core node keeps.
+const MaxReorgDepth = 288
+
+const scanStateVersion byte = 1
+
+var (
+	// ErrBlockNotConnected is returned by ScanState.ConnectBlock if the block's
+	// previous header hash does not match the hash of the scan state's tip.
+	ErrBlockNotConnected = errors.New("block does not connect to scan state tip")
+
+	// ErrReorgTooDeep is returned when a ScanState must disconnect a block
+	// for which it no longer has undo data.
+	ErrReorgTooDeep = errors.New("chain reorganization is deeper than scan state undo history")
+
+	// ErrInvalidScanStateFormat is returned when decoding a ScanState which is not formatted correctly.
+	ErrInvalidScanStateFormat = errors.New("scan state is not formatted correctly")
+)
+
+type scannedBlock struct {
+	hash     [32]byte
+	prevHash [32]byte
+	undo     *unspent.Undo
+}
+
+// ScanState tracks the progress of a UTXO scan which can survive chain reorganizations and
+// be resumed after being interrupted. It holds the set of unspent outputs as of its tip block,
+// and undo data for the last MaxReorgDepth blocks so that they can be disconnected.
+//
+// Block hashes are in RPC byte order, as returned by blockheader.BlockHeader.Hash.
+type ScanState struct {
+	Utxos *unspent.OutputSet
+
+	nextHeight uint32
+	tipHash    [32]byte
+	recent     []*scannedBlock // oldest first
+}
+
+// NewScanState returns a ScanState which starts scanning at nextHeight. The given utxos must be
+// up to date as of the block at nextHeight-1, whose hash is tipHash. To scan from the genesis
+// block, pass a nextHeight of zero and a zero tipHash.
+func NewScanState(utxos *unspent.OutputSet, nextHeight uint32, tipHash [32]byte) *ScanState {
+	if utxos == nil {
+		utxos = new(unspent.OutputSet)
+	}
+
+	return &ScanState{
+		Utxos:      utxos,
+		nextHeight: nextHeight,
+		tipHash:    tipHash,
+	}
+}
+
+// NextHeight returns the height of the next block to be connected to the ScanState.
+func (state *ScanState) NextHeight() uint32 {
+	return state.nextHeight
+}
+
+// TipHash returns the hash of the last block connected to the ScanState, which
+// must be the previous header hash of the next block.
+func (state *ScanState) TipHash() [32]byte {
+	return state.tipHash
+}
+
+// ConnectBlock updates the scan state's UTXOs with the given block, which must be the next
+// block in the chain. It returns ErrBlockNotConnected if the block builds on a different tip.
+func (state *ScanState) ConnectBlock(block *blocks.Block, scriptPubKeys [][]byte) error {
+	if block.Header.PreviousHeaderHash != state.tipHash {
+		return fmt.Errorf("%w: block at height %d builds on %x", ErrBlockNotConnected, state.nextHeight, block.Header.PreviousHeaderHash)
+	}
+
+	hash, err := block.Header.Hash()
+	if err != nil {
+		return err
+	}
+
+	undo, err := state.Utxos.UpdateFromBlockUndo(block, scriptPubKeys)
+	if err != nil {
+		return err
+	}
+
+	state.recent = append(state.recent, &scannedBlock{hash: hash, prevHash: state.tipHash, undo: undo})
+	if len(state.recent) > MaxReorgDepth {
+		state.recent[0] = nil
+		state.recent = state.recent[1:]
+	}
+
+	state.tipHash = hash
+	state.nextHeight++
+	return nil
+}
+
+// DisconnectTip reverts the changes made to the scan state's UTXOs by the tip block, making its
+// parent the new tip. It returns ErrReorgTooDeep if the state has no undo data for the tip.
+func (state *ScanState) DisconnectTip() error {
+	if len(state.recent) == 0 || state.nextHeight == 0 {
+		return fmt.Errorf("%w: cannot disconnect block %x", ErrReorgTooDeep, state.tipHash)
+	}
+
+	tip := state.recent[len(state.recent)-1]
+	state.recent = state.recent[:len(state.recent)-1]
+
+	state.Utxos.Revert(tip.undo)
+	state.tipHash = tip.prevHash
+	state.nextHeight--
+	return nil
+}
+
+// hashAt returns the hash of the block at the given height, which must be no higher than the
+// tip. It returns false if the block is deeper than the state's undo history.
+func (state *ScanState) hashAt(height uint32) ([32]byte, bool) {
+	if height >= state.nextHeight {
+		return [32]byte{}, false
+	}
+
+	depth := int(state.nextHeight - 1 - height)
+	if depth == 0 {
+		return state.tipHash, true
+	} else if depth > len(state.recent) {
+		return [32]byte{}, false
+	}
+	return state.recent[len(state.recent)-depth].prevHash, true
+}
+
+// tipUndo returns the undo data of the tip block, or nil if the state has none.
+func (state *ScanState) tipUndo() *unspent.Undo {
+	if len(state.recent) == 0 {
+		return nil
+	}
+	return state.recent[len(state.recent)-1].undo
+}
+
+// WriteTo implements the io.WriterTo interface. It writes the serialized ScanState, including
+// its UTXOs and undo data, to the given writer.
+func (state *ScanState) WriteTo(w io.Writer) (n int64, err error) {
+	header := make([]byte, 1+4+32)
+	header[0] = scanStateVersion
+	binary.LittleEndian.PutUint32(header[1:], state.nextHeight)
+	copy(header[5:], state.tipHash[:])
+
+	j, err := w.Write(header)
+	n += int64(j)
+	if err != nil {
+		return
+	}
+
+	c, err := varint.VarInt(len(state.recent)).WriteTo(w)
+	n += c
+	if err != nil {
+		return
+	}
+
+	for _, scanned := range state.recent {
+		j, err = w.Write(append(scanned.hash[:], scanned.prevHash[:]...))
+		n += int64(j)
+		if err != nil {
+			return
+		}
+
+		c, err = scanned.undo.WriteTo(w)
+		n += c
+		if err != nil {
+			return
+		}
+	}
+
+	c, err = state.Utxos.WriteTo(w)
+	n += c
+	return
+}
+
+// Bytes returns the serialized ScanState as a byte-slice. Returns
+// nil if any error occurs during serialization.
+func (state *ScanState) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	if _, err := state.WriteTo(buf); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// ScanStateFromReader decodes a serialized ScanState from the given reader.
+func ScanStateFromReader(r io.Reader) (*ScanState, error) {
+	state, err := scanStateFromReader(r)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, unspent.ErrInvalidFormat) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidScanStateFormat, err)
+	}
+	return state, err
+}
+
+func scanStateFromReader(r io.Reader) (*ScanState, error) {
+	header := make([]byte, 1+4+32)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	} else if header[0] != scanStateVersion {
+		return nil, fmt.Errorf("%w: unknown version %d", ErrInvalidScanStateFormat, header[0])
+	}
+
+	state := &ScanState{nextHeight: binary.LittleEndian.Uint32(header[1:])}
+	copy(state.tipHash[:], header[5:])
+
+	nRecent, err := varint.FromReader(r)
+	if err != nil {
+		return nil, err
+	} else if nRecent > MaxReorgDepth {
+		return nil, fmt.Errorf("%w: too many recent blocks", ErrInvalidScanStateFormat)
+	}
+
+	for i := varint.VarInt(0); i < nRecent; i++ {
+		scanned := new(scannedBlock)
+		if _, err := io.ReadFull(r, scanned.hash[:]); err != nil {
+			return nil, err
+		} else if _, err := io.ReadFull(r, scanned.prevHash[:]); err != nil {
+			return nil, err
+		}
+
+		if scanned.undo, err = unspent.UndoFromReader(r); err != nil {
+			return nil, err
+		}
+		state.recent = append(state.recent, scanned)
+	}
+
+	if state.Utxos, err = unspent.OutputSetFromReader(r); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// SaveScanState writes the serialized ScanState to the file at the given path. The file is
+// replaced atomically, so an interrupted save never leaves a partially written state behind.
+func SaveScanState(path string, state *ScanState) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	writer := bufio.NewWriter(tmpFile)
+	if _, err := state.WriteTo(writer); err != nil {
+		tmpFile.Close()
+		return err
+	} else if err := writer.Flush(); err != nil {
+		tmpFile.Close()
+		return err
+	} else if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	} else if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// LoadScanState reads a ScanState from the file at the given path, which was written by SaveScanState.
+func LoadScanState(path string) (*ScanState, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ScanStateFromReader(bufio.NewReader(file))
+}
@@ -24,7 +24,7 @@ func (scanner *BlockScanner) streamBlocks(
 			close(wrappedErrorQueue)
 		}()
 
-		firstBlock, err := scanner.GetBlockByHeight(fromHeight)
+		firstBlock, err := scanner.GetBlockByHeightContext(cancelCtx, fromHeight)
 		if err != nil {
 			wrappedErrorQueue <- err
 			return
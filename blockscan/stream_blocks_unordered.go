@@ -28,29 +28,42 @@ func (scanner *BlockScanner) streamBlocksUnordered(
 
 	wg.Add(int(parallelism))
 
+	batchSize := scanner.BatchSize
+	if batchSize == 0 {
+		batchSize = 1
+	}
+
 	for i := uint32(0); i < parallelism; i++ {
 		go func(i uint32) {
 			defer wg.Done()
 
-			blocksFetched := uint32(0)
-
-			for {
-				nBlockToGet := fromHeight + blocksFetched*parallelism + i
-				if nBlockToGet > toHeight {
+			// Each worker fetches every parallelism-th batch of heights, starting with batch i.
+			for batch := i; ; batch += parallelism {
+				batchStart := uint64(fromHeight) + uint64(batch)*uint64(batchSize)
+				if batchStart > uint64(toHeight) {
 					return
 				}
 
-				block, err := scanner.GetBlockByHeight(nBlockToGet)
+				heights := make([]uint32, 0, batchSize)
+				for height := batchStart; height <= uint64(toHeight) && len(heights) < int(batchSize); height++ {
+					heights = append(heights, uint32(height))
+				}
+
+				blockList, err := scanner.GetBlocksByHeight(ctx, heights...)
 				if err != nil {
-					errorQueue <- err
+					select {
+					case <-ctx.Done():
+					case errorQueue <- err:
+					}
 					return
 				}
 
-				select {
-				case <-ctx.Done():
-					return
-				case blockQueue <- block:
-					blocksFetched += 1
+				for _, block := range blockList {
+					select {
+					case <-ctx.Done():
+						return
+					case blockQueue <- block:
+					}
 				}
 			}
 		}(i)
@@ -3,9 +3,95 @@ package blockscan
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+
+	"github.com/kklash/bitcoinlib/rpc"
+)
+
+const (
+	genesisBlockHex  = "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c0101000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+	genesisBlockHash = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
 )
 
+// newBatchOnlyScanner returns a BlockScanner connected to a fake node which only accepts batch
+// requests, and responds with the genesis block regardless of the requested height.
+func newBatchOnlyScanner(t *testing.T) (*BlockScanner, *int32) {
+	var nBatches int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		atomic.AddInt32(&nBatches, 1)
+
+		responses := make([]map[string]any, len(requests))
+		for i, request := range requests {
+			responses[i] = map[string]any{"id": request.ID, "error": nil}
+			switch request.Method {
+			case "getblockhash":
+				responses[i]["result"] = genesisBlockHash
+			case "getblock":
+				responses[i]["result"] = genesisBlockHex
+			}
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	t.Cleanup(server.Close)
+
+	conn, err := rpc.NewConnection(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("failed to create connection: %s", err)
+	}
+
+	return NewBlockScanner(conn), &nBatches
+}
+
+func TestBlockScanner_StreamBlocksUnordered_Batched(t *testing.T) {
+	scanner, nBatches := newBatchOnlyScanner(t)
+	scanner.BatchSize = 4
+
+	var fromHeight uint32 = 10
+	var toHeight uint32 = 30
+
+	nextBlock := scanner.StreamBlocksUnordered(context.Background(), fromHeight, toHeight, 3)
+
+	var blocksCounted int
+	for ; ; blocksCounted++ {
+		block, err := nextBlock()
+		if err != nil {
+			t.Errorf("failed to get unordered block: %s", err)
+			return
+		} else if block == nil {
+			break
+		}
+
+		if hash, _ := block.Header.Hash(); hex.EncodeToString(hash[:]) != genesisBlockHash {
+			t.Errorf("unexpected block hash: %x", hash)
+			return
+		}
+	}
+
+	if blocksCounted != int(toHeight-fromHeight)+1 {
+		t.Errorf("scanned wrong number of blocks\nwanted %d\ngot    %d", toHeight-fromHeight+1, blocksCounted)
+		return
+	}
+
+	// 21 blocks in batches of 4, with one batch for hashes and one for blocks.
+	if *nBatches != 12 {
+		t.Errorf("sent wrong number of batches\nwanted %d\ngot    %d", 12, *nBatches)
+		return
+	}
+}
+
 func TestBlockScanner_StreamBlocksUnordered(t *testing.T) {
 	t.Skip()
 
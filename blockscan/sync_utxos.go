@@ -0,0 +1,139 @@
+package blockscan
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kklash/bitcoinlib/unspent"
+)
+
+// CheckpointFunc is called by SyncUtxos after each block is connected to or disconnected from a
+// ScanState. changes holds the outputs which were added to and removed from state.Utxos: the
+// block's Undo when it is connected, or its inverse when it is disconnected. Passing state.TipHash()
+// and changes to unspent.Store.Apply keeps a store in step with the state, as StoreCheckpoint does.
+type CheckpointFunc func(state *ScanState, changes *unspent.Undo) error
+
+// StoreCheckpoint returns a CheckpointFunc which applies each block's changes to the given store,
+// so that the store holds the same outputs as the ScanState and has the same tip.
+//
+// The store does not hold the state's height or undo history. To resume a scan from the store
+// alone, pass its outputs and tip to NewScanState along with the height of the tip block; the
+// resumed state cannot disconnect blocks which were connected before it was created.
+func StoreCheckpoint(store unspent.Store) CheckpointFunc {
+	return func(state *ScanState, changes *unspent.Undo) error {
+		return store.Apply(state.TipHash(), changes)
+	}
+}
+
+// SyncUtxos brings the given ScanState up to date with the node's best chain, updating its UTXOs
+// with outputs belonging to the given set of scriptPubKeys. Blocks are fetched in batches of
+// scanner.BatchSize.
+//
+// Before connecting new blocks, and whenever a fetched block does not build on the state's tip,
+// SyncUtxos compares the hashes of the state's recent blocks with the node's chain to find the
+// fork point, and then disconnects the blocks above it from the state. If the fork point is
+// deeper than the state's undo history, which holds at most MaxReorgDepth blocks, it returns
+// ErrReorgTooDeep without disconnecting any blocks.
+//
+// If checkpoint is non-nil, it is called after each block is connected or disconnected, so the
+// caller can persist the state, either entirely with SaveScanState, or incrementally by applying
+// the block's changes to an unspent.Store with StoreCheckpoint. If checkpoint returns an
+// error, SyncUtxos stops and returns it. Because the state's tip and its UTXOs are updated
+// together, a scan interrupted at any point can be resumed by calling SyncUtxos again with the
+// last saved state.
+func (scanner *BlockScanner) SyncUtxos(
+	ctx context.Context,
+	state *ScanState,
+	scriptPubKeys [][]byte,
+	checkpoint CheckpointFunc,
+) error {
+	if checkpoint == nil {
+		checkpoint = func(*ScanState, *unspent.Undo) error { return nil }
+	}
+
+	batchSize := scanner.BatchSize
+	if batchSize == 0 {
+		batchSize = 1
+	}
+
+	for {
+		blockCount, err := scanner.Connection.GetBlockCount(ctx)
+		if err != nil {
+			return err
+		}
+		nodeTip := uint32(blockCount)
+
+		if err := scanner.findForkPoint(ctx, state, nodeTip, checkpoint); err != nil {
+			return err
+		}
+
+		if state.NextHeight() > nodeTip {
+			return nil
+		}
+
+		heights := make([]uint32, 0, batchSize)
+		for height := state.NextHeight(); height <= nodeTip && len(heights) < int(batchSize); height++ {
+			heights = append(heights, height)
+		}
+
+		blockList, err := scanner.GetBlocksByHeight(ctx, heights...)
+		if err != nil {
+			return err
+		}
+
+		for _, block := range blockList {
+			// The node's chain was reorganized since the batch began. The fork
+			// point will be found on the next iteration.
+			if block.Header.PreviousHeaderHash != state.TipHash() {
+				break
+			}
+
+			if err := state.ConnectBlock(block, scriptPubKeys); err != nil {
+				return err
+			} else if err := checkpoint(state, state.tipUndo()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// findForkPoint finds the highest block which the state and the node's best chain, whose tip is
+// at height nodeTip, have in common, and disconnects the blocks above it from the state. The fork
+// point is found by comparing the hashes of the state's recent blocks with the node's chain before
+// anything is disconnected, so if the fork is deeper than the state's undo history, it returns
+// ErrReorgTooDeep and leaves the state unchanged.
+func (scanner *BlockScanner) findForkPoint(
+	ctx context.Context,
+	state *ScanState,
+	nodeTip uint32,
+	checkpoint CheckpointFunc,
+) error {
+	// A fork height of -1 means the state has no blocks in common with the node.
+	forkHeight := int64(state.NextHeight()) - 1
+	for ; forkHeight >= 0; forkHeight-- {
+		hash, ok := state.hashAt(uint32(forkHeight))
+		if !ok {
+			return fmt.Errorf("%w: no block in common with node's chain at or above height %d", ErrReorgTooDeep, forkHeight+1)
+		} else if forkHeight > int64(nodeTip) {
+			continue
+		}
+
+		nodeHash, err := scanner.Connection.GetBlockHash(ctx, int(forkHeight))
+		if err != nil {
+			return err
+		} else if nodeHash == hash {
+			break
+		}
+	}
+
+	for int64(state.NextHeight())-1 > forkHeight {
+		undo := state.tipUndo()
+		if err := state.DisconnectTip(); err != nil {
+			return err
+		} else if err := checkpoint(state, undo.Inverse()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
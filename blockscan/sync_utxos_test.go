@@ -0,0 +1,369 @@
+package blockscan
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+	"github.com/kklash/bitcoinlib/blocks/merkle"
+	"github.com/kklash/bitcoinlib/common"
+	"github.com/kklash/bitcoinlib/rpc"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/unspent"
+)
+
+// fakeNode is an in-process bitcoind RPC server which serves a chain of
+// blocks that can be replaced to simulate a reorganization.
+type fakeNode struct {
+	mutex sync.Mutex
+	chain []*blocks.Block
+}
+
+func (node *fakeNode) setChain(chain []*blocks.Block) {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+	node.chain = chain
+}
+
+func (node *fakeNode) call(method string, params []any) (any, *rpc.ErrRPCFailure) {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	switch method {
+	case "getblockcount":
+		return len(node.chain) - 1, nil
+
+	case "getblockhash":
+		height := int(params[0].(float64))
+		if height < 0 || height >= len(node.chain) {
+			return nil, &rpc.ErrRPCFailure{Code: -8, Message: "Block height out of range"}
+		}
+		hash, _ := node.chain[height].Header.Hash()
+		return hex.EncodeToString(hash[:]), nil
+
+	case "getblock":
+		for _, block := range node.chain {
+			if hash, _ := block.Header.Hash(); hex.EncodeToString(hash[:]) == params[0] {
+				return hex.EncodeToString(block.Bytes()), nil
+			}
+		}
+		return nil, &rpc.ErrRPCFailure{Code: -5, Message: "Block not found"}
+	}
+
+	return nil, &rpc.ErrRPCFailure{Code: -32601, Message: "Method not found"}
+}
+
+func newFakeNode(t *testing.T) (*fakeNode, *BlockScanner) {
+	node := new(fakeNode)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		type request struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+
+		respond := func(req request) map[string]any {
+			result, rpcErr := node.call(req.Method, req.Params)
+			return map[string]any{"id": req.ID, "result": result, "error": rpcErr}
+		}
+
+		var body json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if len(body) > 0 && body[0] == '[' {
+			var requests []request
+			json.Unmarshal(body, &requests)
+
+			responses := make([]map[string]any, len(requests))
+			for i, req := range requests {
+				responses[i] = respond(req)
+			}
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+
+		var req request
+		json.Unmarshal(body, &req)
+		json.NewEncoder(w).Encode(respond(req))
+	}))
+	t.Cleanup(server.Close)
+
+	conn, err := rpc.NewConnection(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("failed to create connection: %s", err)
+	}
+
+	scanner := NewBlockScanner(conn)
+	scanner.BatchSize = 2
+	return node, scanner
+}
+
+// buildChain extends the given chain with one block for each of the given
+// transaction lists. Each block also gets a coinbase paying to otherScript.
+func buildChain(t *testing.T, chain []*blocks.Block, txLists ...[]*tx.Tx) []*blocks.Block {
+	chain = append([]*blocks.Block{}, chain...)
+
+	for _, txns := range txLists {
+		header := &blockheader.BlockHeader{Version: 1, Time: uint32(len(chain)), NBits: 0x207fffff}
+		if len(chain) > 0 {
+			prevHash, err := chain[len(chain)-1].Header.Hash()
+			if err != nil {
+				t.Fatalf("failed to hash block: %s", err)
+			}
+			header.PreviousHeaderHash = prevHash
+		}
+
+		coinbase := &tx.Tx{
+			Version: 1,
+			Inputs: []*tx.Input{{
+				PrevOut:  &tx.PrevOut{Index: 0xffffffff},
+				Script:   []byte{byte(len(chain))},
+				Sequence: 0xffffffff,
+			}},
+			Outputs: []*tx.Output{{Value: 50, Script: otherScript}},
+		}
+
+		block := &blocks.Block{Header: header, Transactions: append([]*tx.Tx{coinbase}, txns...)}
+
+		txids := make([][32]byte, len(block.Transactions))
+		for i, txn := range block.Transactions {
+			hash, _ := txn.Hash(false)
+			txids[i] = hash
+		}
+		header.MerkleRootHash = merkle.MerkleRootHashInternal(txids)
+		common.ReverseBytesInPlace(header.MerkleRootHash[:])
+
+		chain = append(chain, block)
+	}
+
+	return chain
+}
+
+var (
+	ourScript   = mustHex("00148dc8e4f2450a1af2202383d71a259de6ea14d4ba")
+	otherScript = mustHex("0014751e76e8199196d454941c45d1b3a323f1433bd6")
+)
+
+func payTo(script []byte, value uint64, spends ...*tx.PrevOut) *tx.Tx {
+	if len(spends) == 0 {
+		// Spend an unrelated output, because a transaction with no inputs cannot be decoded.
+		spends = []*tx.PrevOut{{Hash: [32]byte{0xee}, Index: uint32(value)}}
+	}
+
+	txn := &tx.Tx{Version: 2, Inputs: []*tx.Input{}, Outputs: []*tx.Output{{Value: value, Script: script}}}
+	for _, prevOut := range spends {
+		txn.Inputs = append(txn.Inputs, &tx.Input{PrevOut: prevOut, Script: []byte{}})
+	}
+	return txn
+}
+
+func outpointOf(txn *tx.Tx, index uint32) *tx.PrevOut {
+	hash, _ := txn.Hash(false)
+	return &tx.PrevOut{Hash: hash, Index: index}
+}
+
+func fullScan(t *testing.T, chain []*blocks.Block) *unspent.OutputSet {
+	utxos := new(unspent.OutputSet)
+	for _, block := range chain {
+		if err := utxos.UpdateFromBlock(block, [][]byte{ourScript}); err != nil {
+			t.Fatalf("failed to scan block: %s", err)
+		}
+	}
+	return utxos
+}
+
+func TestBlockScanner_SyncUtxos(t *testing.T) {
+	ctx := context.Background()
+	node, scanner := newFakeNode(t)
+
+	received1 := payTo(ourScript, 1000)
+	received2 := payTo(ourScript, 2000)
+	spend1 := payTo(otherScript, 900, outpointOf(received1, 0))
+	received3 := payTo(ourScript, 3000)
+
+	// genesis, receive 1, receive 2, spend 1, receive 3
+	chain := buildChain(t, nil, nil, []*tx.Tx{received1}, []*tx.Tx{received2})
+	mainChain := buildChain(t, chain, []*tx.Tx{spend1}, []*tx.Tx{received3})
+	node.setChain(mainChain)
+
+	// The store is kept in step with the state by applying each block's changes.
+	store := unspent.NewMemoryStore()
+	storeCheckpoint := StoreCheckpoint(store)
+
+	var checkpoints []uint32
+	checkpoint := func(state *ScanState, changes *unspent.Undo) error {
+		checkpoints = append(checkpoints, state.NextHeight())
+		return storeCheckpoint(state, changes)
+	}
+
+	checkStore := func(state *ScanState) bool {
+		stored, _ := store.Load()
+		return store.Tip() == state.TipHash() && reflect.DeepEqual(stored, state.Utxos)
+	}
+
+	state := NewScanState(nil, 0, [32]byte{})
+	if err := scanner.SyncUtxos(ctx, state, [][]byte{ourScript}, checkpoint); err != nil {
+		t.Errorf("failed to sync: %s", err)
+		return
+	}
+
+	if !reflect.DeepEqual(state.Utxos, fullScan(t, mainChain)) || state.Utxos.Size() != 2 {
+		t.Errorf("incorrect UTXOs after sync: %+v", state.Utxos.Slice())
+		return
+	} else if !reflect.DeepEqual(checkpoints, []uint32{1, 2, 3, 4, 5}) {
+		t.Errorf("incorrect checkpoints: %v", checkpoints)
+		return
+	} else if tipHash, _ := mainChain[4].Header.Hash(); state.NextHeight() != 5 || state.TipHash() != tipHash {
+		t.Errorf("incorrect tip: %d %x", state.NextHeight(), state.TipHash())
+		return
+	} else if !checkStore(state) {
+		t.Errorf("store does not match scan state after sync")
+		return
+	}
+
+	// Persist the state, then reorganize the node's chain: the blocks which spent output 1
+	// and received output 3 are replaced by a longer branch which spends output 2 instead.
+	statePath := filepath.Join(t.TempDir(), "scan.state")
+	if err := SaveScanState(statePath, state); err != nil {
+		t.Errorf("failed to save scan state: %s", err)
+		return
+	}
+
+	spend2 := payTo(otherScript, 1900, outpointOf(received2, 0))
+	received4 := payTo(ourScript, 4000)
+	forkChain := buildChain(t, chain, []*tx.Tx{spend2}, nil, []*tx.Tx{received4})
+	node.setChain(forkChain)
+
+	resumed, err := LoadScanState(statePath)
+	if err != nil {
+		t.Errorf("failed to load scan state: %s", err)
+		return
+	} else if !reflect.DeepEqual(resumed, state) {
+		t.Errorf("scan state did not round trip")
+		return
+	}
+
+	checkpoints = nil
+	if err := scanner.SyncUtxos(ctx, resumed, [][]byte{ourScript}, checkpoint); err != nil {
+		t.Errorf("failed to sync after reorg: %s", err)
+		return
+	}
+
+	expectedUtxos := fullScan(t, forkChain)
+	if !reflect.DeepEqual(resumed.Utxos, expectedUtxos) {
+		t.Errorf("incorrect UTXOs after reorg\nWanted %+v\nGot    %+v", expectedUtxos.Slice(), resumed.Utxos.Slice())
+		return
+	} else if expectedUtxos.GetByOutpoint(outpointOf(received1, 0)) == nil || expectedUtxos.GetByOutpoint(outpointOf(received4, 0)) == nil {
+		t.Errorf("reorg test fixture is wrong")
+		return
+	}
+
+	// Two blocks disconnected, then three connected.
+	if !reflect.DeepEqual(checkpoints, []uint32{4, 3, 4, 5, 6}) {
+		t.Errorf("incorrect checkpoints after reorg: %v", checkpoints)
+		return
+	} else if !checkStore(resumed) {
+		t.Errorf("store does not match scan state after reorg")
+		return
+	}
+
+	// Syncing again when already at the tip is a no-op.
+	checkpoints = nil
+	if err := scanner.SyncUtxos(ctx, resumed, [][]byte{ourScript}, checkpoint); err != nil {
+		t.Errorf("failed to sync at tip: %s", err)
+		return
+	} else if len(checkpoints) != 0 {
+		t.Errorf("unexpected checkpoints at tip: %v", checkpoints)
+		return
+	}
+
+	// The node reorganizes to a shorter chain.
+	node.setChain(chain)
+	if err := scanner.SyncUtxos(ctx, resumed, [][]byte{ourScript}, nil); err != nil {
+		t.Errorf("failed to sync after reorg to shorter chain: %s", err)
+		return
+	} else if !reflect.DeepEqual(resumed.Utxos, fullScan(t, chain)) || resumed.NextHeight() != 3 {
+		t.Errorf("incorrect state after reorg to shorter chain: %d %+v", resumed.NextHeight(), resumed.Utxos.Slice())
+		return
+	}
+}
+
+func TestBlockScanner_SyncUtxos_Errors(t *testing.T) {
+	ctx := context.Background()
+	node, scanner := newFakeNode(t)
+	node.setChain(buildChain(t, nil, nil, nil, nil, nil))
+
+	// A state resumed from a different chain, with no undo history.
+	state := NewScanState(nil, 3, [32]byte{1})
+	if err := scanner.SyncUtxos(ctx, state, nil, nil); !errors.Is(err, ErrReorgTooDeep) {
+		t.Errorf("expected ErrReorgTooDeep, got %v", err)
+		return
+	}
+
+	// A state whose undo history covers heights 2 to 4, while the node's chain forks after height 0.
+	chain := buildChain(t, nil, nil, []*tx.Tx{payTo(ourScript, 1000)}, nil, nil, nil)
+	node.setChain(chain)
+	tip1, _ := chain[1].Header.Hash()
+	state = NewScanState(nil, 2, tip1)
+	if err := scanner.SyncUtxos(ctx, state, [][]byte{ourScript}, nil); err != nil {
+		t.Errorf("failed to sync: %s", err)
+		return
+	}
+
+	node.setChain(buildChain(t, chain[:1], nil, nil, nil, nil, nil))
+	before := state.Bytes()
+	var checkpoints int
+	err := scanner.SyncUtxos(ctx, state, nil, func(*ScanState, *unspent.Undo) error {
+		checkpoints++
+		return nil
+	})
+	if !errors.Is(err, ErrReorgTooDeep) {
+		t.Errorf("expected ErrReorgTooDeep for fork deeper than undo history, got %v", err)
+		return
+	} else if !bytes.Equal(state.Bytes(), before) || checkpoints != 0 {
+		t.Errorf("state was changed before detecting that reorg is too deep")
+		return
+	}
+
+	node.setChain(buildChain(t, nil, nil, nil, nil, nil))
+	errStop := errors.New("stop")
+	state = NewScanState(nil, 0, [32]byte{})
+	err = scanner.SyncUtxos(ctx, state, nil, func(state *ScanState, _ *unspent.Undo) error {
+		if state.NextHeight() == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Errorf("expected checkpoint error, got %v", err)
+		return
+	} else if state.NextHeight() != 2 {
+		t.Errorf("expected scan to stop at height 2, got %d", state.NextHeight())
+		return
+	}
+
+	block := buildChain(t, nil, nil)[0]
+	if err := state.ConnectBlock(block, nil); !errors.Is(err, ErrBlockNotConnected) {
+		t.Errorf("expected ErrBlockNotConnected, got %v", err)
+		return
+	}
+
+	encoded := state.Bytes()
+	for i := 0; i < len(encoded); i++ {
+		if _, err := ScanStateFromReader(bytes.NewReader(encoded[:i])); !errors.Is(err, ErrInvalidScanStateFormat) {
+			t.Errorf("expected ErrInvalidScanStateFormat for truncated state of length %d, got %v", i, err)
+			return
+		}
+	}
+}
@@ -11,6 +11,9 @@ import (
 // a given set of unspent outputs which should be up to date as of startBlockHeight-1. If a non-nil
 // progressCallback is passed, it is called after each block is scanned successfully. Block
 // streaming is cancelled if the given Context ctx is cancelled or if an error is encountered.
+//
+// UpdateUtxos assumes the chain does not change during the scan. Use SyncUtxos for scans
+// which must survive chain reorganizations, or be resumed after being interrupted.
 func (scanner *BlockScanner) UpdateUtxos(
 	ctx context.Context,
 	utxos *unspent.OutputSet,
@@ -0,0 +1,225 @@
+// Package bloom implements BIP37 bloom filters, which SPV clients send to peers to
+// receive only the transactions relevant to their wallet.
+package bloom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/kklash/bitcoinlib/bhash"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/varint"
+)
+
+// UpdateFlag controls how a peer updates a bloom filter when a transaction matches it.
+type UpdateFlag uint8
+
+const (
+	// UpdateNone never updates the filter.
+	UpdateNone UpdateFlag = 0
+
+	// UpdateAll adds the outpoint of every output whose script matches the filter, so
+	// that transactions spending it also match.
+	UpdateAll UpdateFlag = 1
+
+	// UpdateP2PubKeyOnly adds the outpoint of matching outputs only if they are
+	// pay-to-pubkey or bare multisig outputs.
+	UpdateP2PubKeyOnly UpdateFlag = 2
+
+	// updateMask masks the bits of the flags which select the update behavior.
+	updateMask UpdateFlag = 3
+)
+
+const (
+	// MaxFilterSize is the maximum size in bytes of a bloom filter's data.
+	MaxFilterSize = 36000
+
+	// MaxHashFuncs is the maximum number of hash functions a bloom filter may use.
+	MaxHashFuncs = 50
+
+	// MinFalsePositiveRate and MaxFalsePositiveRate are the limits of the false
+	// positive rate accepted by NewFilter. Rates outside this range are clamped.
+	MinFalsePositiveRate = 1e-9
+	MaxFalsePositiveRate = 1.0
+
+	ln2Squared = math.Ln2 * math.Ln2
+)
+
+var (
+	// ErrFilterTooLarge is returned when decoding a filter larger than MaxFilterSize,
+	// or with more hash functions than MaxHashFuncs.
+	ErrFilterTooLarge = errors.New("bloom filter is too large")
+
+	// ErrInvalidFormat is returned when decoding a filter which is not encoded properly.
+	ErrInvalidFormat = errors.New("bloom filter is not formatted correctly")
+)
+
+// Filter is a BIP37 bloom filter. Its serialization is the payload of a filterload message.
+type Filter struct {
+	// Data is the bit field of the filter.
+	Data []byte
+
+	// HashFuncs is the number of hash functions used to insert each element.
+	HashFuncs uint32
+
+	// Tweak is a random value added to the seed of each hash function.
+	Tweak uint32
+
+	// Flags control how the filter is updated when matching transactions.
+	Flags UpdateFlag
+}
+
+// NewFilter creates an empty bloom filter sized to hold the given number of elements with
+// the given false positive rate, which is clamped between MinFalsePositiveRate and
+// MaxFalsePositiveRate. The filter size is capped at MaxFilterSize, and the number of
+// hash functions at MaxHashFuncs. The tweak should be random.
+func NewFilter(elements int, fpRate float64, tweak uint32, flags UpdateFlag) *Filter {
+	if elements < 1 {
+		elements = 1
+	}
+	fpRate = math.Max(MinFalsePositiveRate, math.Min(fpRate, MaxFalsePositiveRate))
+
+	nBits := -1 / ln2Squared * float64(elements) * math.Log(fpRate)
+	nBytes := int(math.Min(nBits, MaxFilterSize*8)) / 8
+	// Bitcoin Core divides the filter size by the number of elements as integers.
+	hashFuncs := uint32(math.Min(float64(nBytes*8/elements)*math.Ln2, MaxHashFuncs))
+
+	return &Filter{
+		Data:      make([]byte, nBytes),
+		HashFuncs: hashFuncs,
+		Tweak:     tweak,
+		Flags:     flags,
+	}
+}
+
+// bitIndex returns the index of the bit set by the given hash function for data.
+func (filter *Filter) bitIndex(hashNum uint32, data []byte) uint32 {
+	return MurmurHash3(hashNum*0xfba4c795+filter.Tweak, data) % uint32(len(filter.Data)*8)
+}
+
+// Insert adds data to the filter.
+func (filter *Filter) Insert(data []byte) {
+	if len(filter.Data) == 0 {
+		return
+	}
+	for i := uint32(0); i < filter.HashFuncs; i++ {
+		index := filter.bitIndex(i, data)
+		filter.Data[index/8] |= 1 << (index % 8)
+	}
+}
+
+// Contains returns true if data is likely in the filter.
+func (filter *Filter) Contains(data []byte) bool {
+	if len(filter.Data) == 0 {
+		return false
+	}
+	for i := uint32(0); i < filter.HashFuncs; i++ {
+		index := filter.bitIndex(i, data)
+		if filter.Data[index/8]&(1<<(index%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// InsertPrevOut adds a serialized outpoint to the filter, so that transactions spending it match.
+func (filter *Filter) InsertPrevOut(prevOut *tx.PrevOut) {
+	filter.Insert(prevOut.Bytes())
+}
+
+// ContainsPrevOut returns true if the serialized outpoint is likely in the filter.
+func (filter *Filter) ContainsPrevOut(prevOut *tx.PrevOut) bool {
+	return filter.Contains(prevOut.Bytes())
+}
+
+// InsertPubKey adds a public key and its hash160 to the filter, matching transactions
+// which pay to the key with P2PK, P2PKH or P2WPKH outputs, and inputs which spend them.
+func (filter *Filter) InsertPubKey(publicKey []byte) {
+	filter.Insert(publicKey)
+	hash := bhash.Hash160(publicKey)
+	filter.Insert(hash[:])
+}
+
+// InsertScriptHash adds the hash of a redeem or witness script to the filter, matching
+// transactions which pay to it with P2SH (20-byte hash160) or P2WSH (32-byte SHA256) outputs.
+func (filter *Filter) InsertScriptHash(scriptHash []byte) {
+	filter.Insert(scriptHash)
+}
+
+// FromReader decodes a serialized Filter, as found in the payload of a filterload message.
+// Returns ErrFilterTooLarge if the filter exceeds MaxFilterSize or MaxHashFuncs, or
+// ErrInvalidFormat if it is not properly formatted.
+func FromReader(reader io.Reader) (*Filter, error) {
+	filter, err := fromReader(reader)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, ErrInvalidFormat
+	} else if err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func fromReader(reader io.Reader) (*Filter, error) {
+	size, err := varint.FromReader(reader)
+	if err != nil {
+		return nil, err
+	} else if size > MaxFilterSize {
+		return nil, ErrFilterTooLarge
+	}
+
+	filter := &Filter{Data: make([]byte, size)}
+	if _, err := io.ReadFull(reader, filter.Data); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &filter.HashFuncs); err != nil {
+		return nil, err
+	} else if filter.HashFuncs > MaxHashFuncs {
+		return nil, ErrFilterTooLarge
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &filter.Tweak); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &filter.Flags); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// WriteTo implements the io.WriterTo interface. Writes the serialized Filter to the given
+// io.Writer, in the format of a filterload message payload.
+func (filter *Filter) WriteTo(w io.Writer) (n int64, err error) {
+	n, err = varint.VarInt(len(filter.Data)).WriteTo(w)
+	if err != nil {
+		return
+	}
+
+	c, err := w.Write(filter.Data)
+	n += int64(c)
+	if err != nil {
+		return
+	}
+
+	for _, v := range []interface{}{filter.HashFuncs, filter.Tweak, filter.Flags} {
+		if err = binary.Write(w, binary.LittleEndian, v); err != nil {
+			return
+		}
+		n += int64(binary.Size(v))
+	}
+
+	return
+}
+
+// Bytes returns the serialized Filter as a byte-slice.
+func (filter *Filter) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	filter.WriteTo(buf)
+	return buf.Bytes()
+}
@@ -0,0 +1,402 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/common"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+// Test vectors in this file are from Bitcoin Core's bloom_tests.cpp.
+
+func hex2bytes(h string) []byte {
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// prevOutFromTxid creates a PrevOut from a txid in RPC byte order.
+func prevOutFromTxid(txid string, index uint32) *tx.PrevOut {
+	prevOut := &tx.PrevOut{Index: index}
+	copy(prevOut.Hash[:], common.ReverseBytes(hex2bytes(txid)))
+	return prevOut
+}
+
+const testTxHex = "" +
+	"01000000010b26e9b7735eb6aabdf358bab62f9816a21ba9ebdb719d5299e88607d722c190000000008b4830450220070aca" +
+	"44506c5cef3a16ed519d7c3c39f8aab192c4e1c90d065f37b8a4af6141022100a8e160b856c2d43d27d8fba71e5aef6405b8" +
+	"643ac4cb7cb3c462aced7f14711a0141046d11fee51b0e60666d5049a9101a72741df480b96ee26488a4d3466b95c9a40ac5" +
+	"eeef87e10a5cd336c19a84565f80fa6c547957b7700ff4dfbdefe76036c339ffffffff021bff3d11000000001976a9140494" +
+	"3fdd508053c75000106d3bc6e2754dbcff1988ac2f15de00000000001976a914a266436d2965547608b9e15d9032a7b9d64f" +
+	"a43188ac00000000"
+
+const testSpendingTxHex = "" +
+	"01000000016bff7fcd4f8565ef406dd5d63d4ff94f318fe82027fd4dc451b04474019f74b4000000008c493046022100da0d" +
+	"c6aecefe1e06efdf05773757deb168820930e3b0d03f46f5fcf150bf990c022100d25b5c87040076e4f253f8262e763e2dd5" +
+	"1e7ff0be157727c4bc42807f17bd39014104e6c26ef67dc610d2cd192484789a6cf9aea9930b944b7e2db5342b9d9e5b9ff7" +
+	"9aff9a2ee1978dd7fd01dfc522ee02283d3b06a9d03acf8096968d7dbb0f9178ffffffff028ba7940e000000001976a914ba" +
+	"deecfdef0507247fc8f74241d73bc039972d7b88ac4094a802000000001976a914c10932483fec93ed51f5fe95e72559f2cc" +
+	"7043f988ac00000000"
+
+const testBlockHex = "" +
+	"0100000082bb869cf3a793432a66e826e05a6fc37469f8efb7421dc880670100000000007f16c5962e8bd963659c793ce370" +
+	"d95f093bc7e367117b3c30c1f8fdd0d9728776381b4d4c86041b554b85290701000000010000000000000000000000000000" +
+	"000000000000000000000000000000000000ffffffff07044c86041b0136ffffffff0100f2052a01000000434104eaafc231" +
+	"4def4ca98ac970241bcab022b9c1e1f4ea423a20f134c876f2c01ec0f0dd5b2e86e7168cefe0d81113c3807420ce13ad1357" +
+	"231a2252247d97a46a91ac000000000100000001bcad20a6a29827d1424f08989255120bf7f3e9e3cdaaa6bb31b0737fe048" +
+	"724300000000494830450220356e834b046cadc0f8ebb5a8a017b02de59c86305403dad52cd77b55af062ea10221009253cd" +
+	"6c119d4729b77c978e1e2aa19f5ea6e0e52b3f16e32fa608cd5bab753901ffffffff02008d380c010000001976a9142b4b80" +
+	"72ecbba129b6453c63e129e643207249ca88ac0065cd1d000000001976a9141b8dd13b994bcfc787b32aeadf58ccb3615cbd" +
+	"5488ac000000000100000003fdacf9b3eb077412e7a968d2e4f11b9a9dee312d666187ed77ee7d26af16cb0b000000008c49" +
+	"3046022100ea1608e70911ca0de5af51ba57ad23b9a51db8d28f82c53563c56a05c20f5a87022100a8bdc8b4a8acc8634c6b" +
+	"420410150775eb7f2474f5615f7fccd65af30f310fbf01410465fdf49e29b06b9a1582287b6279014f834edc317695d125ef" +
+	"623c1cc3aaece245bd69fcad7508666e9c74a49dc9056d5fc14338ef38118dc4afae5fe2c585caffffffff309e1913634ecb" +
+	"50f3c4f83e96e70b2df071b497b8973a3e75429df397b5af83000000004948304502202bdb79c596a9ffc24e96f4386199ab" +
+	"a386e9bc7b6071516e2b51dda942b3a1ed022100c53a857e76b724fc14d45311eac5019650d415c3abb5428f3aae16d8e69b" +
+	"ec2301ffffffff2089e33491695080c9edc18a428f7d834db5b6d372df13ce2b1b0e0cbcb1e6c10000000049483045022100" +
+	"d4ce67c5896ee251c810ac1ff9ceccd328b497c8f553ab6e08431e7d40bad6b5022033119c0c2b7d792d31f1187779c7bd95" +
+	"aefd93d90a715586d73801d9b47471c601ffffffff0100714460030000001976a914c7b55141d097ea5df7a0ed330cf79437" +
+	"6e53ec8d88ac0000000001000000045bf0e214aa4069a3e792ecee1e1bf0c1d397cde8dd08138f4b72a00681743447000000" +
+	"008b48304502200c45de8c4f3e2c1821f2fc878cba97b1e6f8807d94930713aa1c86a67b9bf1e40221008581abfef2e30f95" +
+	"7815fc89978423746b2086375ca8ecf359c85c2a5b7c88ad01410462bb73f76ca0994fcb8b4271e6fb7561f5c0f9ca0cf648" +
+	"5261c4a0dc894f4ab844c6cdfb97cd0b60ffb5018ffd6238f4d87270efb1d3ae37079b794a92d7ec95ffffffffd669f7d795" +
+	"8d40fc59d2253d88e0f248e29b599c80bbcec344a83dda5f9aa72c000000008a473044022078124c8beeaa825f9e0b30bff9" +
+	"6e564dd859432f2d0cb3b72d3d5d93d38d7e930220691d233b6c0f995be5acb03d70a7f7a65b6bc9bdd426260f38a1346669" +
+	"507a3601410462bb73f76ca0994fcb8b4271e6fb7561f5c0f9ca0cf6485261c4a0dc894f4ab844c6cdfb97cd0b60ffb5018f" +
+	"fd6238f4d87270efb1d3ae37079b794a92d7ec95fffffffff878af0d93f5229a68166cf051fd372bb7a537232946e0a46f53" +
+	"636b4dafdaa4000000008c493046022100c717d1714551663f69c3c5759bdbb3a0fcd3fab023abc0e522fe6440de35d82902" +
+	"21008d9cbe25bffc44af2b18e81c58eb37293fd7fe1c2e7b46fc37ee8c96c50ab1e201410462bb73f76ca0994fcb8b4271e6" +
+	"fb7561f5c0f9ca0cf6485261c4a0dc894f4ab844c6cdfb97cd0b60ffb5018ffd6238f4d87270efb1d3ae37079b794a92d7ec" +
+	"95ffffffff27f2b668859cd7f2f894aa0fd2d9e60963bcd07c88973f425f999b8cbfd7a1e2000000008c493046022100e008" +
+	"47147cbf517bcc2f502f3ddc6d284358d102ed20d47a8aa788a62f0db780022100d17b2d6fa84dcaf1c95d88d7e7c30385ae" +
+	"cf415588d749afd3ec81f6022cecd701410462bb73f76ca0994fcb8b4271e6fb7561f5c0f9ca0cf6485261c4a0dc894f4ab8" +
+	"44c6cdfb97cd0b60ffb5018ffd6238f4d87270efb1d3ae37079b794a92d7ec95ffffffff0100c817a8040000001976a914b6" +
+	"efd80d99179f4f4ff6f4dd0a007d018c385d2188ac000000000100000001834537b2f1ce8ef9373a258e10545ce5a50b758d" +
+	"f616cd4356e0032554ebd3c4000000008b483045022100e68f422dd7c34fdce11eeb4509ddae38201773dd62f284e8aa9d96" +
+	"f85099d0b002202243bd399ff96b649a0fad05fa759d6a882f0af8c90cf7632c2840c29070aec20141045e58067e815c2f46" +
+	"4c6a2a15f987758374203895710c2d452442e28496ff38ba8f5fd901dc20e29e88477167fe4fc299bf818fd0d9e1632d467b" +
+	"2a3d9503b1aaffffffff0280d7e636030000001976a914f34c3e10eb387efe872acb614c89e78bfca7815d88ac404b4c0000" +
+	"0000001976a914a84e272933aaf87e1715d7786c51dfaeb5b65a6f88ac00000000010000000143ac81c8e6f6ef307dfe17f3" +
+	"d906d999e23e0189fda838c5510d850927e03ae7000000008c4930460221009c87c344760a64cb8ae6685a3eec2c1ac1bed5" +
+	"b88c87de51acd0e124f266c16602210082d07c037359c3a257b5c63ebd90f5a5edf97b2ac1c434b08ca998839f346dd40141" +
+	"040ba7e521fa7946d12edbb1d1e95a15c34bd4398195e86433c92b431cd315f455fe30032ede69cad9d1e1ed6c3c4ec0dbfc" +
+	"ed53438c625462afb792dcb098544bffffffff0240420f00000000001976a9144676d1b820d63ec272f1900d59d43bc6463d" +
+	"96f888ac40420f00000000001976a914648d04341d00d7968b3405c034adc38d4d8fb9bd88ac00000000010000000248cc91" +
+	"7501ea5c55f4a8d2009c0567c40cfe037c2e71af017d0a452ff705e3f1000000008b483045022100bf5fdc86dc5f08a5d5c8" +
+	"e43a8c9d5b1ed8c65562e280007b52b133021acd9acc02205e325d613e555f772802bf413d36ba807892ed1a690a77811d30" +
+	"33b3de226e0a01410429fa713b124484cb2bd7b5557b2c0b9df7b2b1fee61825eadc5ae6c37a9920d38bfccdc7dc3cb0c47d" +
+	"7b173dbc9db8d37db0a33ae487982c59c6f8606e9d1791ffffffff41ed70551dd7e841883ab8f0b16bf04176b7d1480e4f0a" +
+	"f9f3d4c3595768d068000000008b4830450221008513ad65187b903aed1102d1d0c47688127658c51106753fed0151ce9c16" +
+	"b80902201432b9ebcb87bd04ceb2de66035fbbaf4bf8b00d1cfe41f1a1f7338f9ad79d210141049d4cf80125bf50be1709f7" +
+	"18c07ad15d0fc612b7da1f5570dddc35f2a352f0f27c978b06820edca9ef982c35fda2d255afba340068c5035552368bc720" +
+	"0c1488ffffffff0100093d00000000001976a9148edb68822f1ad580b043c7b3df2e400f8699eb4888ac00000000"
+
+func TestMurmurHash3(t *testing.T) {
+	fixtures := []struct {
+		seed     uint32
+		data     string
+		expected uint32
+	}{
+		{0x00000000, "", 0x00000000},
+		{0xfba4c795, "", 0x6a396f08},
+		{0xffffffff, "", 0x81f16f39},
+		{0x00000000, "00", 0x514e28b7},
+		{0xfba4c795, "00", 0xea3f0b17},
+		{0x00000000, "ff", 0xfd6cf10d},
+		{0x00000000, "0011", 0x16c6b7ab},
+		{0x00000000, "001122", 0x8eb51c3d},
+		{0x00000000, "00112233", 0xb4471bf8},
+		{0x00000000, "0011223344", 0xe2301fa8},
+		{0x00000000, "001122334455", 0xfc2e4a15},
+		{0x00000000, "00112233445566", 0xb074502c},
+		{0x00000000, "0011223344556677", 0x8034d2a0},
+		{0x00000000, "001122334455667788", 0xb4698def},
+	}
+
+	for _, fixture := range fixtures {
+		if hash := MurmurHash3(fixture.seed, hex2bytes(fixture.data)); hash != fixture.expected {
+			t.Errorf("MurmurHash3(%x, %s) = %x, wanted %x", fixture.seed, fixture.data, hash, fixture.expected)
+			continue
+		}
+	}
+}
+
+func TestFilter_Insert(t *testing.T) {
+	fixtures := []struct {
+		tweak    uint32
+		expected string
+	}{
+		{0, "03614e9b050000000000000001"},
+		{2147483649, "03ce4299050000000100008001"},
+	}
+
+	for _, fixture := range fixtures {
+		filter := NewFilter(3, 0.01, fixture.tweak, UpdateAll)
+
+		filter.Insert(hex2bytes("99108ad8ed9bb6274d3980bab5a85c048f0950c8"))
+		if !filter.Contains(hex2bytes("99108ad8ed9bb6274d3980bab5a85c048f0950c8")) {
+			t.Errorf("filter does not contain inserted element")
+			continue
+		}
+		if filter.Contains(hex2bytes("19108ad8ed9bb6274d3980bab5a85c048f0950c8")) {
+			t.Errorf("filter contains element which was not inserted")
+			continue
+		}
+
+		filter.Insert(hex2bytes("b5a2c786d9ef4658287ced5914b37a1b4aa32eee"))
+		filter.Insert(hex2bytes("b9300670b4c5366e95b2699e8b18bc75e5f729c5"))
+
+		if actual := hex.EncodeToString(filter.Bytes()); actual != fixture.expected {
+			t.Errorf("serialized filter does not match\nWanted %s\nGot    %s", fixture.expected, actual)
+			continue
+		}
+
+		decoded, err := FromReader(bytes.NewReader(filter.Bytes()))
+		if err != nil {
+			t.Errorf("failed to decode filter: %s", err)
+			continue
+		} else if !bytes.Equal(decoded.Bytes(), filter.Bytes()) {
+			t.Errorf("decoded filter does not match")
+			continue
+		} else if !decoded.Contains(hex2bytes("b9300670b4c5366e95b2699e8b18bc75e5f729c5")) {
+			t.Errorf("decoded filter does not contain inserted element")
+			continue
+		}
+	}
+}
+
+func TestFilter_FalsePositiveRateRange(t *testing.T) {
+	fixtures := []struct {
+		fpRate   float64
+		expected string
+	}{
+		{20.9999999769, "00000000000000000001"},
+		{0, "0566d97a91a91b0000000000000001"},
+		{-1, "0566d97a91a91b0000000000000001"},
+	}
+
+	hash := common.ReverseBytes(hex2bytes("02981fa052f0481dbc5868f4fc2166035a10f27a03cfd2de67326471df5bc041"))
+	for _, fixture := range fixtures {
+		filter := NewFilter(1, fixture.fpRate, 0, UpdateAll)
+		filter.Insert(hash)
+
+		if actual := hex.EncodeToString(filter.Bytes()); actual != fixture.expected {
+			t.Errorf("serialized filter does not match\nWanted %s\nGot    %s", fixture.expected, actual)
+			continue
+		}
+	}
+}
+
+func TestNewFilter_Size(t *testing.T) {
+	fixtures := []struct {
+		elements          int
+		fpRate            float64
+		expectedSize      int
+		expectedHashFuncs uint32
+	}{
+		{3, 0.01, 3, 5},
+		{2, 0.001, 3, 8},
+		{3, 0.004, 4, 6},
+		{10, 0.000001, 35, 19},
+		{100000000, 0.01, MaxFilterSize, 0},
+	}
+
+	for _, fixture := range fixtures {
+		filter := NewFilter(fixture.elements, fixture.fpRate, 0, UpdateNone)
+		if len(filter.Data) != fixture.expectedSize {
+			t.Errorf(
+				"filter for %d elements at rate %v has size %d, expected %d",
+				fixture.elements, fixture.fpRate, len(filter.Data), fixture.expectedSize,
+			)
+			continue
+		} else if filter.HashFuncs != fixture.expectedHashFuncs {
+			t.Errorf(
+				"filter for %d elements at rate %v has %d hash functions, expected %d",
+				fixture.elements, fixture.fpRate, filter.HashFuncs, fixture.expectedHashFuncs,
+			)
+			continue
+		}
+	}
+}
+
+func TestFilter_InsertPubKey(t *testing.T) {
+	// Public key of 5Kg1gnAjaLfKiwhhPpGS3QfRg2m6awQvaj98JCZBZQ5SuS2F15C
+	publicKey := hex2bytes(
+		"045b81f0017e2091e2edcd5eecf10d5bdd120a5514cb3ee65b8447ec18bfc4575c" +
+			"6d5bf415e54e03b1067934a0f0ba76b01c6b9ab227142ee1d543764b69d901e0",
+	)
+
+	filter := NewFilter(2, 0.001, 0, UpdateAll)
+	filter.InsertPubKey(publicKey)
+
+	expected := "038fc16b080000000000000001"
+	if actual := hex.EncodeToString(filter.Bytes()); actual != expected {
+		t.Errorf("serialized filter does not match\nWanted %s\nGot    %s", expected, actual)
+		return
+	}
+}
+
+func TestFilter_MatchTx(t *testing.T) {
+	txn, err := tx.FromBytes(hex2bytes(testTxHex))
+	if err != nil {
+		t.Errorf("failed to decode tx: %s", err)
+		return
+	}
+	spendingTx, err := tx.FromBytes(hex2bytes(testSpendingTxHex))
+	if err != nil {
+		t.Errorf("failed to decode spending tx: %s", err)
+		return
+	}
+
+	type Fixture struct {
+		name     string
+		insert   func(filter *Filter)
+		expected bool
+	}
+
+	insertData := func(h string) func(*Filter) {
+		return func(filter *Filter) { filter.Insert(hex2bytes(h)) }
+	}
+	insertPrevOut := func(txid string, index uint32) func(*Filter) {
+		return func(filter *Filter) { filter.InsertPrevOut(prevOutFromTxid(txid, index)) }
+	}
+
+	fixtures := []Fixture{
+		{"txid", insertData("6bff7fcd4f8565ef406dd5d63d4ff94f318fe82027fd4dc451b04474019f74b4"), true},
+		{
+			"input signature",
+			insertData(
+				"30450220070aca44506c5cef3a16ed519d7c3c39f8aab192c4e1c90d065f37b8a4af6141022100a8e160b856c2d43d27d8fba71e5aef6405b8643ac4cb7cb3c462aced7f14711a01",
+			),
+			true,
+		},
+		{
+			"input pubkey",
+			insertData(
+				"046d11fee51b0e60666d5049a9101a72741df480b96ee26488a4d3466b95c9a40ac5eeef87e10a5cd336c19a84565f80fa6c547957b7700ff4dfbdefe76036c339",
+			),
+			true,
+		},
+		{"output address", insertData("a266436d2965547608b9e15d9032a7b9d64fa431"), true},
+		{"outpoint", insertPrevOut("90c122d70786e899529d71dbeba91ba216982fb6ba58f3bdaab65e73b7e9260b", 0), true},
+		{"random txid", insertData("3604112b83ff15b943736458a67be0985bf4d4639784ef622ff384e709000000"), false},
+		{"random address", insertData("0000006d2965547608b9e15d9032a7b9d64fa431"), false},
+		{"wrong outpoint index", insertPrevOut("90c122d70786e899529d71dbeba91ba216982fb6ba58f3bdaab65e73b7e9260b", 1), false},
+		{"wrong outpoint txid", insertPrevOut("000000d70786e899529d71dbeba91ba216982fb6ba58f3bdaab65e73b7e9260b", 0), false},
+	}
+
+	for _, fixture := range fixtures {
+		filter := NewFilter(10, 0.000001, 0, UpdateAll)
+		fixture.insert(filter)
+
+		matched, err := filter.MatchTx(txn)
+		if err != nil {
+			t.Errorf("%s: failed to match tx: %s", fixture.name, err)
+			continue
+		} else if matched != fixture.expected {
+			t.Errorf("%s: expected match %v, got %v", fixture.name, fixture.expected, matched)
+			continue
+		}
+	}
+
+	// Matching an output adds its outpoint, so the transaction spending it also matches.
+	filter := NewFilter(10, 0.000001, 0, UpdateAll)
+	filter.Insert(hex2bytes("04943fdd508053c75000106d3bc6e2754dbcff19"))
+	if matched, err := filter.MatchTx(txn); err != nil || !matched {
+		t.Errorf("expected output address to match: %v", err)
+		return
+	}
+	if matched, err := filter.MatchTx(spendingTx); err != nil || !matched {
+		t.Errorf("expected spending tx to match: %v", err)
+		return
+	}
+
+	// With UpdateNone, the spending transaction does not match.
+	filter = NewFilter(10, 0.000001, 0, UpdateNone)
+	filter.Insert(hex2bytes("04943fdd508053c75000106d3bc6e2754dbcff19"))
+	if matched, err := filter.MatchTx(txn); err != nil || !matched {
+		t.Errorf("expected output address to match: %v", err)
+		return
+	}
+	if matched, err := filter.MatchTx(spendingTx); err != nil || matched {
+		t.Errorf("expected spending tx not to match without updates: %v", err)
+		return
+	}
+}
+
+func TestFilter_UpdateFlags(t *testing.T) {
+	block, err := blocks.FromReader(bytes.NewReader(hex2bytes(testBlockHex)))
+	if err != nil {
+		t.Errorf("failed to decode block: %s", err)
+		return
+	}
+
+	fixtures := []struct {
+		flags           UpdateFlag
+		matchesCoinbase bool
+		matchesP2PKH    bool
+	}{
+		{UpdateAll, true, true},
+		{UpdateP2PubKeyOnly, true, false},
+		{UpdateNone, false, false},
+	}
+
+	for _, fixture := range fixtures {
+		filter := NewFilter(10, 0.000001, 0, fixture.flags)
+
+		// The coinbase's P2PK public key, and the output address of the 4th transaction.
+		filter.Insert(hex2bytes(
+			"04eaafc2314def4ca98ac970241bcab022b9c1e1f4ea423a20f134c876f2c01ec0f0dd5b2e86e7168cefe0d81113c3807420ce13ad1357231a2252247d97a46a91",
+		))
+		filter.Insert(hex2bytes("b6efd80d99179f4f4ff6f4dd0a007d018c385d21"))
+
+		for _, txn := range block.Transactions {
+			if _, err := filter.MatchTx(txn); err != nil {
+				t.Errorf("failed to match tx: %s", err)
+				return
+			}
+		}
+
+		coinbaseOutPoint := prevOutFromTxid("147caa76786596590baa4e98f5d9f48b86c7765e489f7a6ff3360fe5c674360b", 0)
+		if filter.ContainsPrevOut(coinbaseOutPoint) != fixture.matchesCoinbase {
+			t.Errorf("flags %d: expected coinbase outpoint match %v", fixture.flags, fixture.matchesCoinbase)
+			continue
+		}
+
+		p2pkhOutPoint := prevOutFromTxid("02981fa052f0481dbc5868f4fc2166035a10f27a03cfd2de67326471df5bc041", 0)
+		if filter.ContainsPrevOut(p2pkhOutPoint) != fixture.matchesP2PKH {
+			t.Errorf("flags %d: expected P2PKH outpoint match %v", fixture.flags, fixture.matchesP2PKH)
+			continue
+		}
+	}
+}
+
+func TestFilter_Limits(t *testing.T) {
+	filter := NewFilter(100000000, 0.01, 0, UpdateNone)
+	if len(filter.Data) > MaxFilterSize {
+		t.Errorf("filter size %d exceeds MaxFilterSize", len(filter.Data))
+		return
+	}
+
+	filter.HashFuncs = MaxHashFuncs + 1
+	if _, err := FromReader(bytes.NewReader(filter.Bytes())); !errors.Is(err, ErrFilterTooLarge) {
+		t.Errorf("expected ErrFilterTooLarge, got %v", err)
+		return
+	}
+
+	if _, err := FromReader(bytes.NewReader(hex2bytes("fdffff"))); !errors.Is(err, ErrFilterTooLarge) {
+		t.Errorf("expected ErrFilterTooLarge, got %v", err)
+		return
+	}
+
+	if _, err := FromReader(bytes.NewReader(hex2bytes("03614e9b0500000000"))); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("expected ErrInvalidFormat, got %v", err)
+		return
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/kklash/bitcoinlib/bloom
+
+go 1.18
@@ -0,0 +1,120 @@
+package bloom
+
+import (
+	"bytes"
+
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+// MatchTx returns true if the transaction is relevant to the filter, as determined by a BIP37
+// peer. A transaction matches if the filter contains its txid, any data pushed by its output
+// scripts, any outpoint it spends, or any data pushed by its input scripts.
+//
+// When an output script matches, the output's outpoint may be added to the filter, depending on
+// filter.Flags, so that later transactions spending the output also match. This updates the
+// filter in the same way as the peer which received it.
+func (filter *Filter) MatchTx(txn *tx.Tx) (bool, error) {
+	txHash, err := txn.Hash(false)
+	if err != nil {
+		return false, err
+	}
+
+	matched := filter.Contains(txHash[:])
+
+	for i, output := range txn.Outputs {
+		if !filter.containsPush(output.Script) {
+			continue
+		}
+		matched = true
+
+		switch filter.Flags & updateMask {
+		case UpdateAll:
+			filter.InsertPrevOut(&tx.PrevOut{Hash: txHash, Index: uint32(i)})
+		case UpdateP2PubKeyOnly:
+			if isPayToPubKey(output.Script) || isMultisig(output.Script) {
+				filter.InsertPrevOut(&tx.PrevOut{Hash: txHash, Index: uint32(i)})
+			}
+		}
+	}
+
+	if matched {
+		return true, nil
+	}
+
+	for _, input := range txn.Inputs {
+		if filter.ContainsPrevOut(input.PrevOut) || filter.containsPush(input.Script) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// containsPush returns true if the filter contains any non-empty data pushed
+// by the script. Parsing stops at the first invalid push.
+func (filter *Filter) containsPush(s []byte) bool {
+	r := bytes.NewReader(s)
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		if op == 0 || op > constants.OP_PUSHDATA4 {
+			continue
+		}
+
+		r.UnreadByte()
+		data, err := script.ReadData(r)
+		if err != nil {
+			return false
+		} else if len(data) > 0 && filter.Contains(data) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPublicKey(data []byte) bool {
+	switch len(data) {
+	case 33:
+		return data[0] == 0x02 || data[0] == 0x03
+	case 65:
+		return data[0] == 0x04
+	}
+	return false
+}
+
+// isPayToPubKey returns true if the script is <pubkey> OP_CHECKSIG.
+func isPayToPubKey(s []byte) bool {
+	return len(s) >= 2 &&
+		int(s[0]) == len(s)-2 &&
+		isPublicKey(s[1:len(s)-1]) &&
+		s[len(s)-1] == constants.OP_CHECKSIG
+}
+
+// isMultisig returns true if the script is a bare multisig script:
+// OP_m <pubkey>... OP_n OP_CHECKMULTISIG.
+func isMultisig(s []byte) bool {
+	chunks, err := script.Decompile(s)
+	if err != nil || len(chunks) < 4 {
+		return false
+	}
+
+	m, ok1 := chunks[0].(byte)
+	n, ok2 := chunks[len(chunks)-2].(byte)
+	checkMultisig, ok3 := chunks[len(chunks)-1].(byte)
+	if !ok1 || !ok2 || !ok3 || checkMultisig != constants.OP_CHECKMULTISIG ||
+		m < constants.OP_1 || m > constants.OP_16 || n < m || n > constants.OP_16 {
+		return false
+	}
+
+	publicKeys := chunks[1 : len(chunks)-2]
+	if len(publicKeys) != int(n-constants.OP_1)+1 {
+		return false
+	}
+	for _, chunk := range publicKeys {
+		if publicKey, ok := chunk.([]byte); !ok || !isPublicKey(publicKey) {
+			return false
+		}
+	}
+	return true
+}
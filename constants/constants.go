@@ -3,6 +3,10 @@
 package constants
 
 const (
+	// BitcoinSignedMessageMagic is prepended to messages before they are hashed and signed,
+	// so that signed messages cannot be mistaken for transactions.
+	BitcoinSignedMessageMagic = "Bitcoin Signed Message:\n"
+
 	// BitcoinSeedIV is the initialization vector used for creating bitcoin wallet seeds.
 	BitcoinSeedIV = "Bitcoin seed"
 
@@ -15,12 +19,30 @@ const (
 	// Bech32Separator is the separating character in bech32 which separates the HRP from the version number and encoded data.
 	Bech32Separator = "1"
 
+	// Bech32ChecksumConst and Bech32mChecksumConst are the constants which bech32 checksums are XORed with.
+	// Bech32m (BIP350) is used to encode segwit addresses with witness version 1 and higher.
+	Bech32ChecksumConst  = 1
+	Bech32mChecksumConst = 0x2bc830a3
+
 	// Bip32Hardened is the HD key index threshold above which any derived child keys are hardened.
 	Bip32Hardened uint32 = 0x80000000
 
 	// BlockMaxSize is the maximum block size, not including segwit data.
 	BlockMaxSize = 1000000
 
+	// CompactSignatureLength is the byte-size of a recoverable compact ECDSA signature:
+	// one header byte, followed by the 32-byte r and s values.
+	CompactSignatureLength int = 65
+
+	// CompactSignatureHeaderUncompressed, CompactSignatureHeaderCompressed, CompactSignatureHeaderP2SHP2WPKH,
+	// and CompactSignatureHeaderP2WPKH are the base values of the header byte of a compact signature.
+	// The recovery ID (0-3) is added to one of these to form the header byte, which tells a verifier
+	// how to recover and encode the public key. The latter two are the segwit variants from BIP137.
+	CompactSignatureHeaderUncompressed byte = 27
+	CompactSignatureHeaderCompressed   byte = 31
+	CompactSignatureHeaderP2SHP2WPKH   byte = 35
+	CompactSignatureHeaderP2WPKH       byte = 39
+
 	// OpReturnMaxSize is the maximum size of an OP_RETURN output data payload.
 	OpReturnMaxSize = 80
 
@@ -36,6 +58,7 @@ const (
 	SerializedExtendedKeyLength int = 78
 
 	// SigHash enum types
+	SigHashDefault      uint32 = 0
 	SigHashAll          uint32 = 1
 	SigHashNone         uint32 = 2
 	SigHashSingle       uint32 = 3
@@ -60,6 +83,10 @@ const (
 	// WitnessVersionZero is the first witness version introduced. It is used for bech32-encoded
 	// P2WPKH and P2WSH witness programs.
 	WitnessVersionZero = 0
+
+	// WitnessVersionOne is the witness version introduced by BIP341. It is used for bech32m-encoded
+	// P2TR witness programs.
+	WitnessVersionOne = 1
 )
 
 // AddressFormat is used to describe different standardized script pubkey formats.
@@ -70,6 +97,7 @@ const (
 	FormatP2SH        AddressFormat = "P2SH"
 	FormatP2WPKH      AddressFormat = "P2WPKH"
 	FormatP2WSH       AddressFormat = "P2WSH"
+	FormatP2TR        AddressFormat = "P2TR"
 	FormatNONSTANDARD AddressFormat = "NONSTANDARD"
 )
 
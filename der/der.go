@@ -1,4 +1,5 @@
-// package der provides DER encoding for Bitcoin signatures as specified in BIP66.
+// package der provides DER encoding for Bitcoin signatures as specified in BIP66,
+// as well as the lax decoding rules used for signatures which predate BIP66.
 package der
 
 const (
@@ -3,6 +3,7 @@ package der
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"math/big"
 	"testing"
 
@@ -60,3 +61,164 @@ func TestEncodeDecodeSignature(t *testing.T) {
 		}
 	}
 }
+
+func isDefinedSigHashType(sigHashType uint32) bool {
+	sigHashType &^= constants.SigHashAnyoneCanPay
+	return sigHashType >= constants.SigHashAll && sigHashType <= constants.SigHashSingle
+}
+
+func TestDecodeSignature_CoreVectors(t *testing.T) {
+	canonical, err := readSigEncodingFixtures("sig_canonical.json")
+	if err != nil {
+		t.Errorf("failed to read fixtures: %s", err)
+		return
+	}
+
+	for _, sig := range canonical {
+		r, s, sigHashType, err := DecodeSignature(sig)
+		if err != nil {
+			t.Errorf("failed to decode canonical signature %x: %s", sig, err)
+			continue
+		} else if !isDefinedSigHashType(sigHashType) {
+			t.Errorf("unexpected sighash type %d for canonical signature %x", sigHashType, sig)
+			continue
+		}
+
+		laxR, laxS, laxSigHashType, err := DecodeSignatureLax(sig)
+		if err != nil {
+			t.Errorf("failed to lax-decode canonical signature %x: %s", sig, err)
+			continue
+		} else if laxR.Cmp(r) != 0 || laxS.Cmp(s) != 0 || laxSigHashType != sigHashType {
+			t.Errorf("lax decoding does not match strict decoding for signature %x", sig)
+			continue
+		}
+	}
+
+	noncanonical, err := readSigEncodingFixtures("sig_noncanonical.json")
+	if err != nil {
+		t.Errorf("failed to read fixtures: %s", err)
+		return
+	}
+
+	for _, sig := range noncanonical {
+		_, _, sigHashType, err := DecodeSignature(sig)
+		if err == nil && isDefinedSigHashType(sigHashType) {
+			t.Errorf("expected non-canonical signature to fail decoding: %x", sig)
+			continue
+		}
+	}
+}
+
+func TestDecodeSignatureLax(t *testing.T) {
+	type Fixture struct {
+		sig  string
+		r    string
+		s    string
+		fail bool
+	}
+
+	fixtures := []Fixture{
+		// R padded with unnecessary zero bytes
+		{
+			sig: "30450221005990e0584b2b238e1dfaad8d6ed69ecc1a4a13ac85fc0b31d0df395eb1ba610502202d5876262c288beb511d061691bf26777344b702b00f8fe28621fe4e566695ed01",
+			r:   "5990e0584b2b238e1dfaad8d6ed69ecc1a4a13ac85fc0b31d0df395eb1ba6105",
+			s:   "2d5876262c288beb511d061691bf26777344b702b00f8fe28621fe4e566695ed",
+		},
+		// Negative R is interpreted as unsigned
+		{
+			sig: "304402208990e0584b2b238e1dfaad8d6ed69ecc1a4a13ac85fc0b31d0df395eb1ba610502202d5876262c288beb511d061691bf26777344b702b00f8fe28621fe4e566695ed01",
+			r:   "8990e0584b2b238e1dfaad8d6ed69ecc1a4a13ac85fc0b31d0df395eb1ba6105",
+			s:   "2d5876262c288beb511d061691bf26777344b702b00f8fe28621fe4e566695ed",
+		},
+		// Wrong sequence length, long-form integer lengths, and trailing garbage
+		{
+			sig: "3081ff0281205990e0584b2b238e1dfaad8d6ed69ecc1a4a13ac85fc0b31d0df395eb1ba61050282000101deadbeef01",
+			r:   "5990e0584b2b238e1dfaad8d6ed69ecc1a4a13ac85fc0b31d0df395eb1ba6105",
+			s:   "01",
+		},
+		// R larger than 32 bytes overflows to zero
+		{
+			sig: "30250221015990e0584b2b238e1dfaad8d6ed69ecc1a4a13ac85fc0b31d0df395eb1ba6105020101" + "01",
+			r:   "00",
+			s:   "00",
+		},
+		// S equal to the curve order overflows to zero
+		{
+			sig: "3025020101022100fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd036414101",
+			r:   "00",
+			s:   "00",
+		},
+		{sig: "", fail: true},
+		{sig: "01", fail: true},
+		{sig: "314402010102010101", fail: true},
+		{sig: "3006030101020101", fail: true},
+		{sig: "30060201010201", fail: true},
+		{sig: "300602050102010101", fail: true},
+	}
+
+	for _, fixture := range fixtures {
+		sig, _ := hex.DecodeString(fixture.sig)
+		r, s, _, err := DecodeSignatureLax(sig)
+		if fixture.fail {
+			if err == nil {
+				t.Errorf("expected lax decoding to fail for signature %x", sig)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("failed to lax-decode signature %x: %s", sig, err)
+			continue
+		}
+
+		if r.Cmp(bigIntFromHex(fixture.r)) != 0 || s.Cmp(bigIntFromHex(fixture.s)) != 0 {
+			t.Errorf(
+				"unexpected lax-decoded signature values\nWanted (%s, %s)\nGot    (%x, %x)",
+				fixture.r, fixture.s, r, s,
+			)
+			continue
+		}
+	}
+}
+
+func TestNormalizeLowS(t *testing.T) {
+	highS, _ := hex.DecodeString("30450220076045be6f9eca28ff1ec606b833d0b87e70b2a630f5e3a496b110967a40f90a0221008fffd599910eefe00bc803c688c2eca1d2ba7f6b180620eaa03488e6585db6ba01")
+	expected, _ := hex.DecodeString("30440220076045be6f9eca28ff1ec606b833d0b87e70b2a630f5e3a496b110967a40f90a022070002a666ef1101ff437fc39773d135ce7f45d7b97427f511f9dd5a677d88a8701")
+
+	_, s, _, _ := DecodeSignature(highS)
+	if IsLowS(s) {
+		t.Errorf("expected S value to be high: %x", s)
+		return
+	}
+
+	normalized, err := NormalizeLowS(highS)
+	if err != nil {
+		t.Errorf("failed to normalize signature: %s", err)
+		return
+	} else if !bytes.Equal(normalized, expected) {
+		t.Errorf("unexpected normalized signature\nWanted %x\nGot    %x", expected, normalized)
+		return
+	}
+
+	_, s, _, err = DecodeSignature(normalized)
+	if err != nil {
+		t.Errorf("failed to decode normalized signature: %s", err)
+		return
+	} else if !IsLowS(s) {
+		t.Errorf("expected normalized S value to be low: %x", s)
+		return
+	}
+
+	// Low-S signatures are unchanged, aside from conversion to strict-DER.
+	renormalized, err := NormalizeLowS(normalized)
+	if err != nil {
+		t.Errorf("failed to normalize signature: %s", err)
+		return
+	} else if !bytes.Equal(renormalized, normalized) {
+		t.Errorf("expected low-S signature to be unchanged\nWanted %x\nGot    %x", normalized, renormalized)
+		return
+	}
+
+	if _, err := NormalizeLowS([]byte{0x30, 0x06, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00, 0x01}); !errors.Is(err, ErrInvalidSignatureEncoding) {
+		t.Errorf("expected ErrInvalidSignatureEncoding for zero signature values, got %v", err)
+		return
+	}
+}
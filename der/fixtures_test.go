@@ -1,6 +1,7 @@
 package der
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"os"
 )
@@ -12,7 +13,6 @@ type Fixture struct {
 }
 
 // TODO add more fixtures from
-// https://github.com/bitcoin/bitcoin/pull/5713/files
 // https://github.com/bitcoinjs/bip66/blob/master/test/fixtures.json
 
 func readFixtures() ([]*Fixture, error) {
@@ -29,3 +29,27 @@ func readFixtures() ([]*Fixture, error) {
 
 	return fixtures, nil
 }
+
+// readSigEncodingFixtures reads a list of hex-encoded signatures from one of Bitcoin Core's
+// sig_canonical.json or sig_noncanonical.json test data files. Non-hex strings are ignored.
+func readSigEncodingFixtures(filename string) ([][]byte, error) {
+	var entries []string
+
+	fixtureData, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(fixtureData, &entries); err != nil {
+		return nil, err
+	}
+
+	var signatures [][]byte
+	for _, entry := range entries {
+		if sig, err := hex.DecodeString(entry); err == nil {
+			signatures = append(signatures, sig)
+		}
+	}
+
+	return signatures, nil
+}
@@ -1,3 +1,5 @@
 module github.com/kklash/bitcoinlib/der
 
 go 1.18
+
+require github.com/kklash/ekliptic v0.0.0-20220910175110-8d1e695fc7a8
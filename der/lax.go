@@ -0,0 +1,131 @@
+package der
+
+import (
+	"math/big"
+)
+
+// DecodeSignatureLax decodes the given DER-encoded signature byte slice using the lenient
+// rules of the OpenSSL-compatible parser which Bitcoin Core uses to verify signatures that
+// predate BIP66. Returns the signature (r, s) and the signature hash type, which is the
+// last byte of derEncoded.
+//
+// Lax parsing accepts many signatures which DecodeSignature rejects, such as those with
+// negative or zero-padded integers, long-form length bytes, or trailing garbage. If r
+// or s overflow 32 bytes or the secp256k1 curve order, they are returned as zero, which
+// is never a valid signature. Returns ErrInvalidSignatureEncoding if the signature
+// cannot be parsed at all.
+func DecodeSignatureLax(derEncoded []byte) (r, s *big.Int, sigHashType uint32, err error) {
+	if len(derEncoded) == 0 {
+		err = invalidEncodingError("signature is empty")
+		return
+	}
+
+	sigHashType = uint32(derEncoded[len(derEncoded)-1])
+	input := derEncoded[:len(derEncoded)-1]
+	pos := 0
+
+	// Sequence tag byte
+	if pos == len(input) || input[pos] != TypeCompound {
+		err = invalidEncodingError("incorrect DER header byte")
+		return
+	}
+	pos++
+
+	// Sequence length bytes, which are ignored
+	if pos == len(input) {
+		err = invalidEncodingError("missing sequence length")
+		return
+	}
+	lenByte := int(input[pos])
+	pos++
+	if lenByte&0x80 != 0 {
+		lenByte -= 0x80
+		if lenByte > len(input)-pos {
+			err = invalidEncodingError("sequence length out of bounds")
+			return
+		}
+		pos += lenByte
+	}
+
+	rBytes, pos, err := readLaxInteger(input, pos)
+	if err != nil {
+		return
+	}
+
+	sBytes, _, err := readLaxInteger(input, pos)
+	if err != nil {
+		return
+	}
+
+	r, s = new(big.Int), new(big.Int)
+	if len(rBytes) > MaxIntegerSize || len(sBytes) > MaxIntegerSize {
+		return
+	}
+
+	r.SetBytes(rBytes)
+	s.SetBytes(sBytes)
+	if r.Cmp(curveOrder) >= 0 || s.Cmp(curveOrder) >= 0 {
+		r.SetInt64(0)
+		s.SetInt64(0)
+	}
+
+	return
+}
+
+// readLaxInteger reads a DER integer from input at pos, and returns its bytes with any
+// leading zeros removed, and the position of the next byte after the integer.
+func readLaxInteger(input []byte, pos int) (value []byte, next int, err error) {
+	// Integer tag byte
+	if pos == len(input) || input[pos] != TagInteger {
+		err = invalidEncodingError("found incorrect type byte for signature integer")
+		return
+	}
+	pos++
+
+	// Integer length bytes
+	if pos == len(input) {
+		err = invalidEncodingError("missing integer length")
+		return
+	}
+	lenByte := int(input[pos])
+	pos++
+
+	var size int
+	if lenByte&0x80 != 0 {
+		lenByte -= 0x80
+		if lenByte > len(input)-pos {
+			err = invalidEncodingError("integer length out of bounds")
+			return
+		}
+		for lenByte > 0 && input[pos] == 0 {
+			pos++
+			lenByte--
+		}
+		if lenByte >= 8 {
+			err = invalidEncodingError("integer length too large")
+			return
+		}
+		for lenByte > 0 {
+			size = size<<8 + int(input[pos])
+			pos++
+			lenByte--
+		}
+	} else {
+		size = lenByte
+	}
+
+	if size > len(input)-pos {
+		err = invalidEncodingError("integer out of bounds")
+		return
+	}
+
+	value = input[pos : pos+size]
+	next = pos + size
+
+	// Ignore leading zeros
+	for len(value) > 0 && value[0] == 0 {
+		value = value[1:]
+	}
+
+	return
+}
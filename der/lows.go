@@ -0,0 +1,38 @@
+package der
+
+import (
+	"math/big"
+
+	"github.com/kklash/ekliptic"
+)
+
+var (
+	curveOrder     = ekliptic.Secp256k1_CurveOrder
+	curveOrderHalf = ekliptic.Secp256k1_CurveOrderHalf
+)
+
+// IsLowS returns true if the signature value s is less than or equal to half the secp256k1
+// curve order. Bitcoin Core's relay policy requires ECDSA signatures to have a low S value,
+// because for any valid signature (r, s), the signature (r, N - s) is also valid.
+func IsLowS(s *big.Int) bool {
+	return s.Cmp(curveOrderHalf) <= 0
+}
+
+// NormalizeLowS decodes the given DER-encoded signature using the lax parsing rules of
+// DecodeSignatureLax and re-encodes it in strict-DER with a low S value, replacing S with
+// N - S if necessary. The sighash type byte is preserved. Returns ErrInvalidSignatureEncoding
+// if the signature cannot be parsed, or if r or s are out of range.
+func NormalizeLowS(derEncoded []byte) ([]byte, error) {
+	r, s, sigHashType, err := DecodeSignatureLax(derEncoded)
+	if err != nil {
+		return nil, err
+	} else if r.Sign() == 0 || s.Sign() == 0 {
+		return nil, invalidEncodingError("signature values are out of range")
+	}
+
+	if !IsLowS(s) {
+		s = new(big.Int).Sub(curveOrder, s)
+	}
+
+	return EncodeSignature(r, s, sigHashType)
+}
@@ -0,0 +1,101 @@
+package ecc
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/ekliptic"
+)
+
+// ErrInvalidCompactSignature is returned when decoding a compact signature which
+// is of the wrong length, or whose header byte is not recognized.
+var ErrInvalidCompactSignature = errors.New("invalid compact signature encoding")
+
+// EncodeCompactSignature encodes the ECDSA signature (r, s) as a 65-byte compact recoverable signature.
+// The header byte is the sum of headerBase and the recoveryID. headerBase should be one of the
+// constants.CompactSignatureHeader* values, which instruct verifiers how to encode the public key.
+// Returns ErrInvalidCompactSignature if recoveryID or headerBase are invalid, or if r or s are not
+// valid scalar values.
+func EncodeCompactSignature(r, s *big.Int, recoveryID, headerBase byte) ([]byte, error) {
+	if recoveryID > 3 || !isCompactSignatureHeaderBase(headerBase) {
+		return nil, ErrInvalidCompactSignature
+	} else if !ekliptic.IsValidScalar(r) || !ekliptic.IsValidScalar(s) {
+		return nil, ErrInvalidCompactSignature
+	}
+
+	sig := make([]byte, constants.CompactSignatureLength)
+	sig[0] = headerBase + recoveryID
+	r.FillBytes(sig[1:33])
+	s.FillBytes(sig[33:])
+	return sig, nil
+}
+
+// DecodeCompactSignature decodes a 65-byte compact recoverable signature, returning the signature
+// values (r, s), the recovery ID, and the header base value, which is one of the
+// constants.CompactSignatureHeader* values. Returns ErrInvalidCompactSignature if the
+// signature is not properly encoded.
+func DecodeCompactSignature(sig []byte) (r, s *big.Int, recoveryID, headerBase byte, err error) {
+	if len(sig) != constants.CompactSignatureLength {
+		err = ErrInvalidCompactSignature
+		return
+	}
+
+	header := sig[0]
+	if header < constants.CompactSignatureHeaderUncompressed ||
+		header >= constants.CompactSignatureHeaderP2WPKH+4 {
+		err = ErrInvalidCompactSignature
+		return
+	}
+
+	recoveryID = (header - constants.CompactSignatureHeaderUncompressed) % 4
+	headerBase = header - recoveryID
+
+	r = new(big.Int).SetBytes(sig[1:33])
+	s = new(big.Int).SetBytes(sig[33:])
+	if !ekliptic.IsValidScalar(r) || !ekliptic.IsValidScalar(s) {
+		err = ErrInvalidCompactSignature
+		return
+	}
+
+	return
+}
+
+// SignCompact signs the given hash with the given private key and returns a 65-byte
+// compact recoverable signature, whose header byte is offset from the given headerBase.
+// headerBase should be one of the constants.CompactSignatureHeader* values.
+func SignCompact(privateKey, messageHash []byte, headerBase byte) ([]byte, error) {
+	r, s, recoveryID := SignECDSARecoverable(privateKey, messageHash)
+	return EncodeCompactSignature(r, s, recoveryID, headerBase)
+}
+
+// RecoverCompact recovers the public key which created the given compact signature
+// on messageHash. The public key is serialized in uncompressed format if the header
+// indicates an uncompressed key, and in compressed format otherwise. Also returns the
+// header base value of the signature, so that callers can determine which kind of
+// address the signer intended to prove ownership of.
+func RecoverCompact(messageHash, sig []byte) (publicKey []byte, headerBase byte, err error) {
+	r, s, recoveryID, headerBase, err := DecodeCompactSignature(sig)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pubX, pubY, err := RecoverPublicKey(messageHash, r, s, recoveryID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	compressed := headerBase != constants.CompactSignatureHeaderUncompressed
+	return SerializePoint(pubX, pubY, compressed), headerBase, nil
+}
+
+func isCompactSignatureHeaderBase(headerBase byte) bool {
+	switch headerBase {
+	case constants.CompactSignatureHeaderUncompressed,
+		constants.CompactSignatureHeaderCompressed,
+		constants.CompactSignatureHeaderP2SHP2WPKH,
+		constants.CompactSignatureHeaderP2WPKH:
+		return true
+	}
+	return false
+}
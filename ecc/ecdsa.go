@@ -2,6 +2,7 @@ package ecc
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"math/big"
 
 	"github.com/kklash/ekliptic"
@@ -14,7 +15,17 @@ var Q = rfc6979.NewQ(ekliptic.Secp256k1_CurveOrder)
 // returns the two components of the signature: r and s.
 //
 // SignECDSA calculates the secret signature nonce value k deterministically using RFC6979.
+// The returned signature always has a low S value (s <= N/2), as required by BIP62 and
+// Bitcoin Core's relay policy.
 func SignECDSA(privateKey, messageHash []byte) (r, s *big.Int) {
+	r, s, _ = SignECDSARecoverable(privateKey, messageHash)
+	return
+}
+
+// SignECDSARecoverable signs the given hash with the given private key, exactly like SignECDSA,
+// but also returns the recovery ID of the signature. The recovery ID is a number from 0 to 3
+// which allows RecoverPublicKey to reconstruct the signer's public key from (r, s).
+func SignECDSARecoverable(privateKey, messageHash []byte) (r, s *big.Int, recoveryID byte) {
 	if len(messageHash) != 32 {
 		panic("unexpected message hash length for ECDSA signature")
 	} else if len(privateKey) != 32 {
@@ -25,7 +36,93 @@ func SignECDSA(privateKey, messageHash []byte) (r, s *big.Int) {
 	k := Q.Nonce(d, messageHash, sha256.New)
 	z := Q.Bits2int(messageHash)
 
-	r, s = ekliptic.SignECDSA(d, k, z)
+	return signECDSA(d, k, z)
+}
+
+// SignECDSAExtraEntropy signs the given hash with the given private key like SignECDSA, but
+// mixes extraEntropy into the RFC6979 nonce derivation as described in RFC6979 section 3.6.
+// Signatures remain deterministic for a given extraEntropy value. If extraEntropy is empty,
+// the signature is identical to the one returned by SignECDSA.
+func SignECDSAExtraEntropy(privateKey, messageHash, extraEntropy []byte) (r, s *big.Int) {
+	if len(messageHash) != 32 {
+		panic("unexpected message hash length for ECDSA signature")
+	} else if len(privateKey) != 32 {
+		panic("unexpected private key length for ECDSA signature")
+	}
+
+	d := new(big.Int).SetBytes(privateKey)
+	k := nonceRFC6979(d, messageHash, extraEntropy)
+	z := Q.Bits2int(messageHash)
+
+	r, s, _ = signECDSA(d, k, z)
+	return
+}
+
+// SignECDSALowR signs the given hash with the given private key like SignECDSA, but grinds
+// the nonce until the signature has a low R value, one which fits in 32 bytes without a DER
+// padding byte. Like Bitcoin Core, the first attempt uses plain RFC6979, and subsequent attempts
+// pass a little-endian counter padded to 32 bytes as extra entropy to SignECDSAExtraEntropy.
+//
+// Low-R signatures are deterministic, and always DER-encode to 70 bytes or less, so that
+// signatures including a sighash type byte are at most 71 bytes long.
+func SignECDSALowR(privateKey, messageHash []byte) (r, s *big.Int) {
+	r, s = SignECDSA(privateKey, messageHash)
+
+	extraEntropy := make([]byte, 32)
+	for counter := uint32(1); !hasLowR(r); counter++ {
+		binary.LittleEndian.PutUint32(extraEntropy, counter)
+		r, s = SignECDSAExtraEntropy(privateKey, messageHash, extraEntropy)
+	}
+
+	return
+}
+
+// hasLowR returns true if the most significant bit of the 32-byte encoding of r is not set,
+// so that r can be DER-encoded without a padding byte.
+func hasLowR(r *big.Int) bool {
+	return r.BitLen() < 256
+}
+
+// signECDSA computes an ECDSA signature (r, s) on z using the private key d and the nonce k.
+// The recovery ID is derived from the nonce point R = kG. Bit 0 is set if R's y coordinate is
+// odd and bit 1 is set if R's x coordinate overflowed the curve order when reduced to r.
+//
+// The signature is always canonical (s <= N/2). Negating s is equivalent to negating R,
+// so the parity bit of the recovery ID is flipped whenever s is normalized.
+func signECDSA(d, k, z *big.Int) (r, s *big.Int, recoveryID byte) {
+	if !ekliptic.IsValidScalar(k) {
+		panic("ECDSA nonce k is not in range [1, N)")
+	} else if !ekliptic.IsValidScalar(d) {
+		panic("private key is not in range [1, N)")
+	}
+
+	rX, rY := ekliptic.MultiplyBasePoint(k)
+
+	if !isEven(rY) {
+		recoveryID |= 1
+	}
+	if rX.Cmp(ekliptic.Secp256k1_CurveOrder) >= 0 {
+		recoveryID |= 2
+	}
+
+	// r = x mod N
+	r = rX.Mod(rX, ekliptic.Secp256k1_CurveOrder)
+
+	// s = k⁻¹ * (rd + z) mod N
+	s = new(big.Int).Mul(r, d)
+	s.Add(s, z)
+	s.Mul(s, ekliptic.InvertScalar(k))
+	s.Mod(s, ekliptic.Secp256k1_CurveOrder)
+
+	if equal(r, zero) || equal(s, zero) {
+		panic("ECDSA signature produced unexpected r or s of zero")
+	}
+
+	if s.Cmp(ekliptic.Secp256k1_CurveOrderHalf) == 1 {
+		s.Sub(ekliptic.Secp256k1_CurveOrder, s)
+		recoveryID ^= 1
+	}
+
 	return
 }
 
@@ -1,10 +1,13 @@
 package ecc
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"os"
 	"testing"
+
+	"github.com/kklash/ekliptic"
 )
 
 func TestECDSA(t *testing.T) {
@@ -35,6 +38,11 @@ func TestECDSA(t *testing.T) {
 			)
 			return
 		}
+		if s.Cmp(ekliptic.Secp256k1_CurveOrderHalf) > 0 {
+			t.Errorf("Expected signature to have low S value: %X", s)
+			return
+		}
+
 		publicKey := GetPublicKeyCompressed(privateKey)
 		if !VerifyECDSA(publicKey, hash, r, s) {
 			t.Errorf("Expected signature to be verified as valid:\n(\n %X,\n %X\n)", r, s)
@@ -46,3 +54,92 @@ func TestECDSA(t *testing.T) {
 		}
 	}
 }
+
+func TestSignECDSALowR(t *testing.T) {
+	// These fixtures are taken from Bitcoin Core's key_tests.
+	fixtures := []struct {
+		privateKey string
+		r, s       string
+	}{
+		{
+			privateKey: "12b004fff7f4b69ef8650e767f18f11ede158148b425660723b9f9a66e61f747",
+			r:          "5dbbddda71772d95ce91cd2d14b592cfbc1dd0aabd6a394b6c2d377bbe59d31d",
+			s:          "14ddda21494a4e221f0824f0b8b924c43fa43c0ad57dccdaa11f81a6bd4582f6",
+		},
+		{
+			privateKey: "b524c28b61c9b2c49b2c7dd4c2d75887abb78768c054bd7c01af4029f6c0d117",
+			r:          "52d8a32079c11e79db95af63bb9600c5b04f21a9ca33dc129c2bfa8ac9dc1cd5",
+			s:          "61d8ae5e0f6c1a16bde3719c64c2fd70e404b6428ab9a69566962e8771b5944d",
+		},
+	}
+
+	// sha256d("Very deterministic message")
+	hash, _ := hex.DecodeString("5255683da567900bfd3e786ed8836a4e7763c221bf1ac20ece2a5171b9199e8a")
+
+	for _, fixture := range fixtures {
+		privateKey, _ := hex.DecodeString(fixture.privateKey)
+		expectedR, expectedS := pointAtHex(fixture.r, fixture.s)
+
+		r, s := SignECDSALowR(privateKey, hash)
+		if !equal(r, expectedR) || !equal(s, expectedS) {
+			t.Errorf("unexpected low-R signature\nWanted (%X, %X)\nGot    (%X, %X)", expectedR, expectedS, r, s)
+			return
+		}
+	}
+
+	privateKey, _ := hex.DecodeString(fixtures[0].privateKey)
+	groundCount := 0
+
+	for i := 0; i < 64; i++ {
+		hash := sha256.Sum256([]byte{byte(i)})
+
+		plainR, plainS := SignECDSA(privateKey, hash[:])
+		r, s := SignECDSALowR(privateKey, hash[:])
+
+		if r.BitLen() > 255 {
+			t.Errorf("expected signature to have low R value: %X", r)
+			return
+		} else if !VerifyECDSA(GetPublicKeyCompressed(privateKey), hash[:], r, s) {
+			t.Errorf("expected low-R signature to be valid: (%X, %X)", r, s)
+			return
+		}
+
+		if hasLowR(plainR) {
+			if !equal(r, plainR) || !equal(s, plainS) {
+				t.Errorf("expected low-R signing to not change signatures which already have low R")
+				return
+			}
+		} else {
+			groundCount++
+		}
+
+		r2, s2 := SignECDSALowR(privateKey, hash[:])
+		if !equal(r, r2) || !equal(s, s2) {
+			t.Errorf("expected low-R signatures to be deterministic")
+			return
+		}
+	}
+
+	if groundCount == 0 {
+		t.Errorf("expected at least one signature to require grinding")
+		return
+	}
+}
+
+func TestNonceRFC6979(t *testing.T) {
+	d := bigIntFromHex("12b004fff7f4b69ef8650e767f18f11ede158148b425660723b9f9a66e61f747")
+
+	for i := 0; i < 16; i++ {
+		hash := sha256.Sum256([]byte{byte(i)})
+
+		if nonceRFC6979(d, hash[:], nil).Cmp(Q.Nonce(d, hash[:], sha256.New)) != 0 {
+			t.Errorf("nonce with no extra entropy should match RFC6979 nonce")
+			return
+		}
+
+		if nonceRFC6979(d, hash[:], []byte{1}).Cmp(Q.Nonce(d, hash[:], sha256.New)) == 0 {
+			t.Errorf("nonce with extra entropy should not match RFC6979 nonce")
+			return
+		}
+	}
+}
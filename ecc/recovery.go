@@ -0,0 +1,64 @@
+package ecc
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/kklash/ekliptic"
+)
+
+// ErrPublicKeyRecovery is returned by RecoverPublicKey if no valid public key
+// can be recovered from the given signature and recovery ID.
+var ErrPublicKeyRecovery = errors.New("failed to recover public key from ECDSA signature")
+
+// RecoverPublicKey reconstructs the public key point which produced the ECDSA signature (r, s) on
+// messageHash, using the recovery ID returned by SignECDSARecoverable. Returns ErrPublicKeyRecovery
+// if the signature or recovery ID are not valid.
+//
+//	Q = r⁻¹ * (sR - zG)
+func RecoverPublicKey(messageHash []byte, r, s *big.Int, recoveryID byte) (pubX, pubY *big.Int, err error) {
+	if len(messageHash) != 32 {
+		panic("unexpected message hash length for ECDSA public key recovery")
+	}
+
+	if recoveryID > 3 || !ekliptic.IsValidScalar(r) || !ekliptic.IsValidScalar(s) {
+		return nil, nil, ErrPublicKeyRecovery
+	}
+
+	// Reconstruct the x coordinate of the nonce point R.
+	rX := new(big.Int).Set(r)
+	if recoveryID&2 != 0 {
+		rX.Add(rX, ekliptic.Secp256k1_CurveOrder)
+	}
+	if rX.Cmp(ekliptic.Secp256k1_P) >= 0 {
+		return nil, nil, ErrPublicKeyRecovery
+	}
+
+	evenY, oddY := ekliptic.Weierstrass(rX)
+	if evenY == nil || oddY == nil {
+		return nil, nil, ErrPublicKeyRecovery
+	}
+	rY := evenY
+	if recoveryID&1 != 0 {
+		rY = oddY
+	}
+
+	z := Q.Bits2int(messageHash)
+	z.Mod(z, ekliptic.Secp256k1_CurveOrder)
+
+	// sR
+	sRx, sRy := ekliptic.MultiplyAffine(rX, rY, s, nil)
+
+	// sR - zG
+	if !equal(z, zero) {
+		zGx, zGy := ekliptic.MultiplyBasePoint(z)
+		sRx, sRy = ekliptic.SubAffine(sRx, sRy, zGx, zGy)
+	}
+
+	pubX, pubY = ekliptic.MultiplyAffine(sRx, sRy, ekliptic.InvertScalar(r), nil)
+	if equal(pubX, zero) && equal(pubY, zero) {
+		return nil, nil, ErrPublicKeyRecovery
+	}
+
+	return pubX, pubY, nil
+}
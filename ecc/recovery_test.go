@@ -0,0 +1,132 @@
+package ecc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/constants"
+)
+
+func TestRecoverPublicKey(t *testing.T) {
+	var fixtures []map[string]string
+
+	fixtureData, err := os.ReadFile("ecdsa_fixtures.json")
+	if err != nil {
+		t.Errorf("failed to read ECDSA fixtures: %s", err)
+		return
+	}
+
+	if err := json.Unmarshal(fixtureData, &fixtures); err != nil {
+		t.Errorf("failed to parse ECDSA fixtures: %s", err)
+		return
+	}
+
+	for _, fixture := range fixtures {
+		hash, _ := hex.DecodeString(fixture["hash"])
+		privateKey, _ := hex.DecodeString(fixture["d"])
+		expectedR, expectedS := pointAtHex(fixture["r"], fixture["s"])
+
+		r, s, recoveryID := SignECDSARecoverable(privateKey, hash)
+		if !equal(r, expectedR) || !equal(s, expectedS) {
+			t.Errorf("recoverable signature does not match ECDSA fixture for %q", fixture["description"])
+			return
+		}
+
+		pubX, pubY, err := RecoverPublicKey(hash, r, s, recoveryID)
+		if err != nil {
+			t.Errorf("failed to recover public key: %s", err)
+			return
+		}
+
+		expectedPub := GetPublicKeyCompressed(privateKey)
+		if recovered := SerializePointCompressed(pubX, pubY); !bytes.Equal(recovered, expectedPub) {
+			t.Errorf("recovered incorrect public key\nWanted %x\nGot    %x", expectedPub, recovered)
+			return
+		}
+
+		// The wrong recovery ID should recover a different key or fail.
+		pubX, pubY, err = RecoverPublicKey(hash, r, s, recoveryID^1)
+		if err == nil && bytes.Equal(SerializePointCompressed(pubX, pubY), expectedPub) {
+			t.Errorf("recovered correct public key from incorrect recovery ID")
+			return
+		}
+	}
+
+	if _, _, err := RecoverPublicKey(make([]byte, 32), big.NewInt(1), big.NewInt(1), 4); !errors.Is(err, ErrPublicKeyRecovery) {
+		t.Errorf("expected ErrPublicKeyRecovery for recovery ID out of range; got %v", err)
+	}
+}
+
+func TestCompactSignature(t *testing.T) {
+	privateKey, _ := hex.DecodeString("e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35")
+	hash, _ := hex.DecodeString("06ef2b193b83b3d701f765f1db34672ab84897e1252343cc2197829af3a30456")
+
+	headerBases := []byte{
+		constants.CompactSignatureHeaderUncompressed,
+		constants.CompactSignatureHeaderCompressed,
+		constants.CompactSignatureHeaderP2SHP2WPKH,
+		constants.CompactSignatureHeaderP2WPKH,
+	}
+
+	for _, headerBase := range headerBases {
+		sig, err := SignCompact(privateKey, hash, headerBase)
+		if err != nil {
+			t.Errorf("failed to create compact signature: %s", err)
+			return
+		} else if len(sig) != constants.CompactSignatureLength {
+			t.Errorf("unexpected compact signature length %d", len(sig))
+			return
+		} else if sig[0] < headerBase || sig[0] > headerBase+3 {
+			t.Errorf("unexpected compact signature header byte %d for header base %d", sig[0], headerBase)
+			return
+		}
+
+		r, s := SignECDSA(privateKey, hash)
+		decodedR, decodedS, _, decodedHeaderBase, err := DecodeCompactSignature(sig)
+		if err != nil {
+			t.Errorf("failed to decode compact signature: %s", err)
+			return
+		} else if !equal(r, decodedR) || !equal(s, decodedS) {
+			t.Errorf("decoded compact signature does not match ECDSA signature")
+			return
+		} else if decodedHeaderBase != headerBase {
+			t.Errorf("decoded wrong header base\nWanted %d\nGot    %d", headerBase, decodedHeaderBase)
+			return
+		}
+
+		publicKey, recoveredHeaderBase, err := RecoverCompact(hash, sig)
+		if err != nil {
+			t.Errorf("failed to recover public key from compact signature: %s", err)
+			return
+		}
+
+		compressed := headerBase != constants.CompactSignatureHeaderUncompressed
+		expectedPub := GetPublicKey(privateKey, compressed)
+		if !bytes.Equal(publicKey, expectedPub) {
+			t.Errorf("recovered incorrect public key\nWanted %x\nGot    %x", expectedPub, publicKey)
+			return
+		} else if recoveredHeaderBase != headerBase {
+			t.Errorf("recovered wrong header base\nWanted %d\nGot    %d", headerBase, recoveredHeaderBase)
+			return
+		}
+	}
+
+	invalidSigs := [][]byte{
+		nil,
+		make([]byte, 64),
+		append([]byte{26}, make([]byte, 64)...),
+		append([]byte{43}, make([]byte, 64)...),
+		append([]byte{31}, make([]byte, 64)...), // r = s = 0
+	}
+
+	for _, sig := range invalidSigs {
+		if _, _, _, _, err := DecodeCompactSignature(sig); !errors.Is(err, ErrInvalidCompactSignature) {
+			t.Errorf("expected ErrInvalidCompactSignature for %x; got %v", sig, err)
+		}
+	}
+}
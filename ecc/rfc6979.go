@@ -0,0 +1,52 @@
+package ecc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/kklash/ekliptic"
+)
+
+// nonceRFC6979 calculates a deterministic ECDSA nonce k for the private key d and messageHash,
+// as specified in RFC6979. If extraEntropy is not empty, it is appended to the private key and
+// message hash when seeding the HMAC-DRBG, as described in RFC6979 section 3.6. This matches
+// the nonce function used by libsecp256k1. With no extraEntropy, the result is the same as Q.Nonce.
+func nonceRFC6979(d *big.Int, messageHash, extraEntropy []byte) *big.Int {
+	hmacSHA256 := func(key []byte, data ...[]byte) []byte {
+		mac := hmac.New(sha256.New, key)
+		for _, chunk := range data {
+			mac.Write(chunk)
+		}
+		return mac.Sum(nil)
+	}
+
+	z := Q.Bits2int(messageHash)
+	z.Mod(z, ekliptic.Secp256k1_CurveOrder)
+
+	seed := make([]byte, 64, 64+len(extraEntropy))
+	d.FillBytes(seed[:32])
+	z.FillBytes(seed[32:])
+	seed = append(seed, extraEntropy...)
+
+	v := make([]byte, 32)
+	for i := range v {
+		v[i] = 0x01
+	}
+	k := make([]byte, 32)
+
+	k = hmacSHA256(k, v, []byte{0x00}, seed)
+	v = hmacSHA256(k, v)
+	k = hmacSHA256(k, v, []byte{0x01}, seed)
+	v = hmacSHA256(k, v)
+
+	for {
+		v = hmacSHA256(k, v)
+		nonce := new(big.Int).SetBytes(v)
+		if ekliptic.IsValidScalar(nonce) {
+			return nonce
+		}
+		k = hmacSHA256(k, v, []byte{0x00})
+		v = hmacSHA256(k, v)
+	}
+}
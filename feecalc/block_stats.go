@@ -0,0 +1,278 @@
+package feecalc
+
+import (
+	"sort"
+
+	"github.com/kklash/bitcoinlib/blocks"
+)
+
+// FeeRatePercentiles are the percentiles of block weight at which BlockFeeStats reports fee
+// rates. They are the same as those of the feerate_percentiles field of getblockstats.
+var FeeRatePercentiles = [5]float64{10, 25, 50, 75, 90}
+
+// BlockFeeStats summarizes the fees paid by the non-coinbase transactions in a block.
+// Fee rates are in satoshis per virtual byte.
+type BlockFeeStats struct {
+	// TxCount is the number of transactions in the block, excluding the coinbase.
+	TxCount int
+
+	// TotalFee is the sum of the fees paid by the block's transactions.
+	TotalFee uint64
+
+	// TotalWeight is the sum of the weights of the block's transactions, excluding the coinbase.
+	TotalWeight int
+
+	// MinFeeRate and MaxFeeRate are the lowest and highest package fee rates in the block.
+	MinFeeRate float64
+	MaxFeeRate float64
+
+	// AverageFeeRate is TotalFee divided by the total virtual size of the block's transactions.
+	AverageFeeRate float64
+
+	// Percentiles holds the package fee rates at each of FeeRatePercentiles, weighted by
+	// transaction weight: the fee rate below which that proportion of block space was sold.
+	Percentiles [len(FeeRatePercentiles)]float64
+}
+
+// NewBlockFeeStats computes fee statistics for the given block. Fee rates are package fee rates,
+// as returned by PackageFeeRates, so that a low fee parent transaction which was mined because
+// of a high fee child is not counted as a low fee rate, and the child not as a high fee rate.
+func NewBlockFeeStats(block *blocks.Block, getPrevOutValue PrevOutValueFunc) (*BlockFeeStats, error) {
+	fees, weights, err := blockFeesAndWeights(block, getPrevOutValue)
+	if err != nil {
+		return nil, err
+	}
+	feeRates := packageFeeRates(block, fees, weights)
+
+	stats := &BlockFeeStats{TxCount: len(fees)}
+	if len(fees) == 0 {
+		return stats, nil
+	}
+
+	type scoredTx struct {
+		feeRate float64
+		weight  int
+	}
+	scores := make([]scoredTx, len(fees))
+
+	stats.MinFeeRate = feeRates[0]
+	for i, fee := range fees {
+		stats.TotalFee += fee
+		stats.TotalWeight += weights[i]
+		scores[i] = scoredTx{feeRates[i], weights[i]}
+
+		if feeRates[i] < stats.MinFeeRate {
+			stats.MinFeeRate = feeRates[i]
+		}
+		if feeRates[i] > stats.MaxFeeRate {
+			stats.MaxFeeRate = feeRates[i]
+		}
+	}
+	stats.AverageFeeRate = feeRate(stats.TotalFee, stats.TotalWeight)
+
+	// This follows the algorithm used by getblockstats.
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].feeRate < scores[j].feeRate })
+
+	next := 0
+	cumulativeWeight := 0
+	for _, score := range scores {
+		cumulativeWeight += score.weight
+		for next < len(FeeRatePercentiles) &&
+			float64(cumulativeWeight) >= float64(stats.TotalWeight)*FeeRatePercentiles[next]/100 {
+			stats.Percentiles[next] = score.feeRate
+			next++
+		}
+	}
+	for ; next < len(FeeRatePercentiles); next++ {
+		stats.Percentiles[next] = scores[len(scores)-1].feeRate
+	}
+
+	return stats, nil
+}
+
+// PackageFeeRates returns the package fee rate of each non-coinbase transaction in the block,
+// in satoshis per virtual byte. A transaction's package fee rate is the fee rate of the
+// package it was mined in, assuming the miner selected packages of transactions with their
+// unconfirmed ancestors by highest fee rate, as Bitcoin Core does. A transaction which has no
+// parents or children in the block has a package fee rate equal to its own fee rate. The result
+// has one entry for each of block.Transactions[1:].
+func PackageFeeRates(block *blocks.Block, getPrevOutValue PrevOutValueFunc) ([]float64, error) {
+	fees, weights, err := blockFeesAndWeights(block, getPrevOutValue)
+	if err != nil {
+		return nil, err
+	}
+	return packageFeeRates(block, fees, weights), nil
+}
+
+// feeRate returns the fee rate in satoshis per virtual byte for the given fee and weight.
+func feeRate(fee uint64, weight int) float64 {
+	if weight == 0 {
+		return 0
+	}
+	return float64(fee) * 4 / float64(weight)
+}
+
+func blockFeesAndWeights(block *blocks.Block, getPrevOutValue PrevOutValueFunc) ([]uint64, []int, error) {
+	if len(block.Transactions) == 0 {
+		return nil, nil, nil
+	}
+
+	txns := block.Transactions[1:]
+	fees := make([]uint64, len(txns))
+	weights := make([]int, len(txns))
+	for i, txn := range txns {
+		fee, err := TotalFeeValue(txn, getPrevOutValue)
+		if err != nil {
+			return nil, nil, err
+		}
+		fees[i] = fee
+		weights[i] = txn.WeightUnits()
+	}
+	return fees, weights, nil
+}
+
+// packageFeeRates computes the package fee rates of the block's non-coinbase transactions,
+// given their fees and weights. Transactions are grouped into clusters which are connected
+// by spending each other's outputs, and within each cluster, the ancestor package with the
+// highest fee rate is repeatedly selected and removed.
+func packageFeeRates(block *blocks.Block, fees []uint64, weights []int) []float64 {
+	feeRates := make([]float64, len(fees))
+	if len(fees) == 0 {
+		return feeRates
+	}
+	txns := block.Transactions[1:]
+
+	indexByHash := make(map[[32]byte]int, len(txns))
+	for i, txn := range txns {
+		if hash, err := txn.Hash(false); err == nil {
+			indexByHash[hash] = i
+		}
+	}
+
+	// Find the parents of each transaction within the block, and union them into clusters.
+	parents := make([][]int, len(txns))
+	cluster := make([]int, len(txns))
+	for i := range cluster {
+		cluster[i] = i
+	}
+	root := func(i int) int {
+		for cluster[i] != i {
+			cluster[i] = cluster[cluster[i]]
+			i = cluster[i]
+		}
+		return i
+	}
+
+	for i, txn := range txns {
+		seen := make(map[int]bool)
+		for _, input := range txn.Inputs {
+			parent, ok := indexByHash[input.PrevOut.Hash]
+			if !ok || parent == i || seen[parent] {
+				continue
+			}
+			seen[parent] = true
+			parents[i] = append(parents[i], parent)
+			cluster[root(i)] = root(parent)
+		}
+	}
+
+	clusters := make(map[int][]int)
+	for i := range txns {
+		clusters[root(i)] = append(clusters[root(i)], i)
+	}
+
+	for _, members := range clusters {
+		if len(members) == 1 {
+			i := members[0]
+			feeRates[i] = feeRate(fees[i], weights[i])
+			continue
+		}
+		selectPackages(members, parents, fees, weights, feeRates)
+	}
+
+	return feeRates
+}
+
+// selectPackages assigns package fee rates to the members of a cluster, by repeatedly selecting
+// the remaining transaction whose ancestor package has the highest fee rate. Ties are broken in
+// favor of the transaction which appears first in the block. As in Bitcoin Core's miner, the
+// fee and weight of each transaction's remaining ancestors are kept as running totals, which
+// are updated when a package is selected, so selection takes quadratic time in the cluster size.
+func selectPackages(members []int, parents [][]int, fees []uint64, weights []int, feeRates []float64) {
+	local := make(map[int]int, len(members))
+	for n, i := range members {
+		local[i] = n
+	}
+
+	// ancestors[n] is the set of ancestors of members[n] within the cluster. Members are in
+	// block order, so each transaction's parents have their ancestor sets computed already.
+	words := (len(members) + 63) / 64
+	ancestors := make([][]uint64, len(members))
+	for n, i := range members {
+		ancestors[n] = make([]uint64, words)
+		for _, parent := range parents[i] {
+			p := local[parent]
+			ancestors[n][p/64] |= 1 << (p % 64)
+			for w, bits := range ancestors[p] {
+				ancestors[n][w] |= bits
+			}
+		}
+	}
+	isAncestor := func(n, a int) bool { return ancestors[n][a/64]&(1<<(a%64)) != 0 }
+
+	// The fee and weight of each member together with its remaining ancestors.
+	packageFees := make([]uint64, len(members))
+	packageWeights := make([]int, len(members))
+	for n, i := range members {
+		packageFees[n] = fees[i]
+		packageWeights[n] = weights[i]
+		for a, j := range members[:n] {
+			if isAncestor(n, a) {
+				packageFees[n] += fees[j]
+				packageWeights[n] += weights[j]
+			}
+		}
+	}
+
+	remaining := make([]bool, len(members))
+	for n := range remaining {
+		remaining[n] = true
+	}
+
+	for left := len(members); left > 0; {
+		best := -1
+		var bestFeeRate float64
+		for n := range members {
+			if !remaining[n] {
+				continue
+			}
+			if rate := feeRate(packageFees[n], packageWeights[n]); best < 0 || rate > bestFeeRate {
+				best = n
+				bestFeeRate = rate
+			}
+		}
+
+		var selected []int
+		for n := range members {
+			if remaining[n] && (n == best || isAncestor(best, n)) {
+				selected = append(selected, n)
+				remaining[n] = false
+				feeRates[members[n]] = bestFeeRate
+			}
+		}
+		left -= len(selected)
+
+		// Remove the selected transactions from the packages of their remaining descendants.
+		for n := range members {
+			if !remaining[n] {
+				continue
+			}
+			for _, a := range selected {
+				if isAncestor(n, a) {
+					packageFees[n] -= fees[members[a]]
+					packageWeights[n] -= weights[members[a]]
+				}
+			}
+		}
+	}
+}
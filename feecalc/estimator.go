@@ -0,0 +1,170 @@
+package feecalc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/kklash/bitcoinlib/blocks"
+)
+
+// DefaultEstimatorBlocks is the number of recent blocks used by a FeeEstimator if MaxBlocks is zero.
+const DefaultEstimatorBlocks = 144
+
+// DefaultSuccessThreshold is the proportion of recent blocks in which an estimated fee rate must
+// have confirmed within the target, used by a FeeEstimator if SuccessThreshold is zero.
+const DefaultSuccessThreshold = 0.85
+
+var (
+	// ErrNotEnoughBlocks is returned by FeeEstimator.EstimateFeeRate if fewer blocks
+	// have been added than the confirmation target.
+	ErrNotEnoughBlocks = errors.New("not enough blocks to estimate fee rate")
+
+	// ErrInvalidConfTarget is returned by FeeEstimator.EstimateFeeRate if the confirmation target is less than one.
+	ErrInvalidConfTarget = errors.New("confirmation target must be at least one block")
+)
+
+// FeeEstimator estimates the fee rate needed for a transaction to confirm within a given
+// number of blocks, from the package fee rates of recently mined blocks. Blocks should be
+// added in ascending height order, for example from a blockscan.NextBlockFunc. The methods
+// of FeeEstimator are safe for concurrent use.
+//
+// A block's minimum fee rate is taken to be the 10th percentile of its package fee rates, which
+// ignores the few transactions that miners include for their own reasons. A fee rate would have
+// confirmed within N blocks of a given block if any of those N blocks had a minimum fee rate at
+// or below it. The estimate for N blocks is the lowest fee rate which would have done so for
+// at least SuccessThreshold of the recent blocks. Blocks with no transactions besides the
+// coinbase say nothing about which fee rates would have confirmed, so they are ignored, and
+// windows containing only such blocks are not counted.
+//
+// The estimate only reflects recent blocks, not the current mempool, and it is not bounded
+// below by the minimum relay fee rate, which callers should apply themselves.
+type FeeEstimator struct {
+	// MaxBlocks is the number of most recent blocks to keep. If zero, DefaultEstimatorBlocks is used.
+	MaxBlocks int
+
+	// SuccessThreshold is the proportion of windows of recent blocks in which an estimate must have
+	// confirmed within the target. If zero, DefaultSuccessThreshold is used.
+	SuccessThreshold float64
+
+	mutex sync.Mutex
+	stats []*BlockFeeStats // oldest first
+}
+
+// NewFeeEstimator returns a FeeEstimator which keeps the statistics of the
+// given number of recent blocks. If maxBlocks is zero, DefaultEstimatorBlocks is used.
+func NewFeeEstimator(maxBlocks int) *FeeEstimator {
+	return &FeeEstimator{MaxBlocks: maxBlocks}
+}
+
+func (estimator *FeeEstimator) maxBlocks() int {
+	if estimator.MaxBlocks <= 0 {
+		return DefaultEstimatorBlocks
+	}
+	return estimator.MaxBlocks
+}
+
+func (estimator *FeeEstimator) successThreshold() float64 {
+	if estimator.SuccessThreshold <= 0 || estimator.SuccessThreshold > 1 {
+		return DefaultSuccessThreshold
+	}
+	return estimator.SuccessThreshold
+}
+
+// AddStats adds the statistics of the next block to the estimator, discarding the oldest
+// block's statistics if the estimator already holds MaxBlocks blocks.
+func (estimator *FeeEstimator) AddStats(stats *BlockFeeStats) {
+	estimator.mutex.Lock()
+	defer estimator.mutex.Unlock()
+
+	estimator.stats = append(estimator.stats, stats)
+	if excess := len(estimator.stats) - estimator.maxBlocks(); excess > 0 {
+		estimator.stats = append([]*BlockFeeStats(nil), estimator.stats[excess:]...)
+	}
+}
+
+// AddBlock computes the statistics of the next block and adds them to the estimator.
+func (estimator *FeeEstimator) AddBlock(block *blocks.Block, getPrevOutValue PrevOutValueFunc) (*BlockFeeStats, error) {
+	stats, err := NewBlockFeeStats(block, getPrevOutValue)
+	if err != nil {
+		return nil, err
+	}
+	estimator.AddStats(stats)
+	return stats, nil
+}
+
+// AddBlocks adds every block returned by nextBlock, until it returns two nil values, as a
+// blockscan.NextBlockFunc does. newPrevOutValueFunc is called for each block, to return a
+// PrevOutValueFunc for the outputs it spends, such as one from NewPrefetchedPrevOutFunc.
+func (estimator *FeeEstimator) AddBlocks(
+	nextBlock func() (*blocks.Block, error),
+	newPrevOutValueFunc func(*blocks.Block) (PrevOutValueFunc, error),
+) error {
+	for {
+		block, err := nextBlock()
+		if err != nil {
+			return err
+		} else if block == nil {
+			return nil
+		}
+
+		getPrevOutValue, err := newPrevOutValueFunc(block)
+		if err != nil {
+			return err
+		}
+
+		if _, err := estimator.AddBlock(block, getPrevOutValue); err != nil {
+			return err
+		}
+	}
+}
+
+// Blocks returns the number of blocks currently held by the estimator.
+func (estimator *FeeEstimator) Blocks() int {
+	estimator.mutex.Lock()
+	defer estimator.mutex.Unlock()
+	return len(estimator.stats)
+}
+
+// EstimateFeeRate returns the estimated fee rate, in satoshis per virtual byte, for a transaction
+// to confirm within confTarget blocks. Returns ErrNotEnoughBlocks if fewer than confTarget blocks
+// have been added, or if none of them contain transactions.
+func (estimator *FeeEstimator) EstimateFeeRate(confTarget int) (float64, error) {
+	estimator.mutex.Lock()
+	defer estimator.mutex.Unlock()
+
+	if confTarget < 1 {
+		return 0, ErrInvalidConfTarget
+	} else if len(estimator.stats) < confTarget {
+		return 0, fmt.Errorf("%w: have %d, target is %d", ErrNotEnoughBlocks, len(estimator.stats), confTarget)
+	}
+
+	// For each window of confTarget blocks, find the lowest fee rate
+	// which would have confirmed in one of the window's blocks.
+	needed := make([]float64, 0, len(estimator.stats)-confTarget+1)
+	for start := 0; start+confTarget <= len(estimator.stats); start++ {
+		minFeeRate := math.Inf(1)
+		for _, stats := range estimator.stats[start : start+confTarget] {
+			if stats.TxCount > 0 && stats.Percentiles[0] < minFeeRate {
+				minFeeRate = stats.Percentiles[0]
+			}
+		}
+		if !math.IsInf(minFeeRate, 1) {
+			needed = append(needed, minFeeRate)
+		}
+	}
+
+	if len(needed) == 0 {
+		return 0, fmt.Errorf("%w: no blocks with transactions", ErrNotEnoughBlocks)
+	}
+
+	sort.Float64s(needed)
+	// Allow for rounding error, so that thresholds like 0.85*20 are not rounded up.
+	index := int(math.Ceil(estimator.successThreshold()*float64(len(needed))-1e-9)) - 1
+	if index < 0 {
+		index = 0
+	}
+	return needed[index], nil
+}
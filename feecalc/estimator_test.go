@@ -0,0 +1,303 @@
+package feecalc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/unspent"
+)
+
+// feeRateTestBlock builds blocks whose transactions pay exact fee rates. Every transaction
+// has one input and one output, so they all have the same size.
+type feeRateTestBlock struct {
+	t       *testing.T
+	block   *blocks.Block
+	funding *unspent.OutputSet
+	nonce   byte
+}
+
+func newFeeRateTestBlock(t *testing.T) *feeRateTestBlock {
+	return &feeRateTestBlock{
+		t:       t,
+		block:   &blocks.Block{Transactions: []*tx.Tx{newTestTx(0xcb, 5000000000)}},
+		funding: unspent.NewOutputSet(nil),
+	}
+}
+
+// spend adds a transaction to the block which spends prevOut, worth inputValue,
+// paying the given fee rate in sats/vbyte.
+func (b *feeRateTestBlock) spend(prevOut *tx.PrevOut, inputValue uint64, rate uint64) *tx.Tx {
+	txn := newTestTx(0, 0)
+	txn.Inputs[0].PrevOut = prevOut
+	txn.Outputs[0].Value = inputValue - rate*uint64(txn.VSize())
+
+	b.block.Transactions = append(b.block.Transactions, txn)
+	return txn
+}
+
+// fund adds a transaction to the block which spends a new output from outside the block.
+func (b *feeRateTestBlock) fund(rate uint64) *tx.Tx {
+	b.nonce++
+	prevOut := &tx.PrevOut{Hash: [32]byte{0xf0, b.nonce}}
+	b.funding.AddOutput(&unspent.Output{
+		Outpoint: prevOut,
+		TxOut:    &tx.Output{Value: 1000000, Script: []byte{0x51}},
+	})
+	return b.spend(prevOut, 1000000, rate)
+}
+
+// child adds a transaction to the block which spends the output of parent.
+func (b *feeRateTestBlock) child(parent *tx.Tx, rate uint64) *tx.Tx {
+	return b.spend(spending(b.t, parent, 0)[0], parent.Outputs[0].Value, rate)
+}
+
+func (b *feeRateTestBlock) getPrevOutValue() PrevOutValueFunc {
+	outputs := unspent.NewOutputSet(nil)
+	for _, txn := range b.block.Transactions {
+		outputs.AddOutput(&unspent.Output{Outpoint: spending(b.t, txn, 0)[0], TxOut: txn.Outputs[0]})
+	}
+
+	fromFunding := NewOutputSetPrevOutFunc(b.funding)
+	inBlock := NewOutputSetPrevOutFunc(outputs)
+
+	return PrevOutFunc(func(prevOut *tx.PrevOut) (*tx.Output, error) {
+		if output, err := fromFunding(prevOut); err == nil {
+			return output, nil
+		}
+		return inBlock(prevOut)
+	}).ValueFunc()
+}
+
+func TestNewBlockFeeStats(t *testing.T) {
+	b := newFeeRateTestBlock(t)
+	for _, rate := range []uint64{7, 2, 9, 1, 10, 4, 3, 8, 6, 5} {
+		b.fund(rate)
+	}
+
+	stats, err := NewBlockFeeStats(b.block, b.getPrevOutValue())
+	if err != nil {
+		t.Errorf("failed to compute block fee stats: %s", err)
+		return
+	}
+
+	weight := b.block.Transactions[1].WeightUnits()
+	expected := &BlockFeeStats{
+		TxCount:        10,
+		TotalFee:       55 * uint64(weight/4),
+		TotalWeight:    10 * weight,
+		MinFeeRate:     1,
+		MaxFeeRate:     10,
+		AverageFeeRate: 5.5,
+		Percentiles:    [5]float64{1, 3, 5, 8, 9},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("incorrect block fee stats\nWanted %+v\nGot    %+v", expected, stats)
+		return
+	}
+
+	// A block with only a coinbase has zero fee rates.
+	stats, err = NewBlockFeeStats(&blocks.Block{Transactions: b.block.Transactions[:1]}, b.getPrevOutValue())
+	if err != nil {
+		t.Errorf("failed to compute block fee stats for empty block: %s", err)
+		return
+	} else if !reflect.DeepEqual(stats, &BlockFeeStats{}) {
+		t.Errorf("expected zero stats for empty block, got %+v", stats)
+		return
+	}
+}
+
+func TestPackageFeeRates(t *testing.T) {
+	b := newFeeRateTestBlock(t)
+
+	// A low fee parent bumped by a high fee child (CPFP).
+	cpfpParent := b.fund(1)
+	b.child(cpfpParent, 19)
+
+	// A high fee parent with a low fee child is mined on its own.
+	highParent := b.fund(30)
+	b.child(highParent, 2)
+
+	// A chain of three, where the grandchild bumps both ancestors.
+	root := b.fund(2)
+	middle := b.child(root, 2)
+	b.child(middle, 26)
+
+	// An unrelated transaction.
+	b.fund(5)
+
+	feeRates, err := PackageFeeRates(b.block, b.getPrevOutValue())
+	if err != nil {
+		t.Errorf("failed to compute package fee rates: %s", err)
+		return
+	}
+
+	expected := []float64{
+		10, 10,
+		30, 2,
+		10, 10, 10,
+		5,
+	}
+	if !reflect.DeepEqual(feeRates, expected) {
+		t.Errorf("incorrect package fee rates\nWanted %v\nGot    %v", expected, feeRates)
+		return
+	}
+
+	stats, err := NewBlockFeeStats(b.block, b.getPrevOutValue())
+	if err != nil {
+		t.Errorf("failed to compute block fee stats: %s", err)
+		return
+	} else if stats.MinFeeRate != 2 || stats.MaxFeeRate != 30 {
+		t.Errorf("incorrect fee rate range: %f - %f", stats.MinFeeRate, stats.MaxFeeRate)
+		return
+	}
+}
+
+func TestPackageFeeRates_LongChain(t *testing.T) {
+	b := newFeeRateTestBlock(t)
+
+	// A long chain of low fee transactions, all bumped by the last one.
+	const chainLength = 2000
+	txn := b.fund(1)
+	for i := 1; i < chainLength-1; i++ {
+		txn = b.child(txn, 1)
+	}
+	b.child(txn, chainLength+1)
+
+	feeRates, err := PackageFeeRates(b.block, b.getPrevOutValue())
+	if err != nil {
+		t.Errorf("failed to compute package fee rates: %s", err)
+		return
+	} else if len(feeRates) != chainLength {
+		t.Errorf("expected %d fee rates, got %d", chainLength, len(feeRates))
+		return
+	}
+
+	for i, rate := range feeRates {
+		if rate != 2 {
+			t.Errorf("incorrect package fee rate for tx %d\nWanted 2\nGot    %f", i, rate)
+			return
+		}
+	}
+}
+
+func TestFeeEstimator(t *testing.T) {
+	estimator := NewFeeEstimator(10)
+
+	if _, err := estimator.EstimateFeeRate(1); !errors.Is(err, ErrNotEnoughBlocks) {
+		t.Errorf("expected ErrNotEnoughBlocks, got %v", err)
+		return
+	}
+
+	// The first two blocks are discarded when the rest are added.
+	for _, minFeeRate := range []float64{100, 100, 5, 1, 8, 2, 9, 3, 7, 4, 6, 10} {
+		estimator.AddStats(&BlockFeeStats{TxCount: 1, Percentiles: [5]float64{minFeeRate, 50, 60, 70, 80}})
+	}
+
+	if estimator.Blocks() != 10 {
+		t.Errorf("expected estimator to hold 10 blocks, got %d", estimator.Blocks())
+		return
+	}
+
+	expectedEstimates := map[int]float64{1: 9, 2: 4, 3: 4, 5: 3, 10: 1}
+	for confTarget, expected := range expectedEstimates {
+		estimate, err := estimator.EstimateFeeRate(confTarget)
+		if err != nil {
+			t.Errorf("failed to estimate fee rate for target %d: %s", confTarget, err)
+			continue
+		} else if estimate != expected {
+			t.Errorf("incorrect estimate for target %d\nWanted %f\nGot    %f", confTarget, expected, estimate)
+			continue
+		}
+	}
+
+	if _, err := estimator.EstimateFeeRate(11); !errors.Is(err, ErrNotEnoughBlocks) {
+		t.Errorf("expected ErrNotEnoughBlocks, got %v", err)
+		return
+	} else if _, err := estimator.EstimateFeeRate(0); !errors.Is(err, ErrInvalidConfTarget) {
+		t.Errorf("expected ErrInvalidConfTarget, got %v", err)
+		return
+	}
+
+	estimator.SuccessThreshold = 0.5
+	if estimate, _ := estimator.EstimateFeeRate(1); estimate != 5 {
+		t.Errorf("incorrect estimate with 50%% threshold\nWanted 5\nGot    %f", estimate)
+		return
+	}
+}
+
+func TestFeeEstimator_EmptyBlocks(t *testing.T) {
+	estimator := NewFeeEstimator(10)
+
+	// Blocks with only a coinbase have zero fee rates, which must not be taken as the minimum.
+	estimator.AddStats(new(BlockFeeStats))
+	if _, err := estimator.EstimateFeeRate(1); !errors.Is(err, ErrNotEnoughBlocks) {
+		t.Errorf("expected ErrNotEnoughBlocks with only empty blocks, got %v", err)
+		return
+	}
+
+	estimator = NewFeeEstimator(10)
+	for _, minFeeRate := range []float64{4, 0, 6, 0, 2} {
+		stats := new(BlockFeeStats)
+		if minFeeRate > 0 {
+			stats.TxCount = 1
+			stats.Percentiles[0] = minFeeRate
+		}
+		estimator.AddStats(stats)
+	}
+
+	expectedEstimates := map[int]float64{1: 6, 2: 6, 5: 2}
+	for confTarget, expected := range expectedEstimates {
+		estimate, err := estimator.EstimateFeeRate(confTarget)
+		if err != nil {
+			t.Errorf("failed to estimate fee rate for target %d: %s", confTarget, err)
+			continue
+		} else if estimate != expected {
+			t.Errorf("incorrect estimate for target %d\nWanted %f\nGot    %f", confTarget, expected, estimate)
+			continue
+		}
+	}
+}
+
+func TestFeeEstimator_AddBlocks(t *testing.T) {
+	var testBlocks []*feeRateTestBlock
+	for i := uint64(1); i <= 3; i++ {
+		b := newFeeRateTestBlock(t)
+		b.fund(i)
+		b.fund(i * 10)
+		testBlocks = append(testBlocks, b)
+	}
+
+	n := 0
+	nextBlock := func() (*blocks.Block, error) {
+		if n == len(testBlocks) {
+			return nil, nil
+		}
+		n++
+		return testBlocks[n-1].block, nil
+	}
+	newPrevOutValueFunc := func(block *blocks.Block) (PrevOutValueFunc, error) {
+		return testBlocks[n-1].getPrevOutValue(), nil
+	}
+
+	estimator := NewFeeEstimator(0)
+	if err := estimator.AddBlocks(nextBlock, newPrevOutValueFunc); err != nil {
+		t.Errorf("failed to add blocks: %s", err)
+		return
+	}
+
+	if estimate, err := estimator.EstimateFeeRate(3); err != nil || estimate != 1 {
+		t.Errorf("incorrect estimate from added blocks: %f, %v", estimate, err)
+		return
+	}
+
+	fetchErr := errors.New("prev out fetch failed")
+	n = 0
+	err := estimator.AddBlocks(nextBlock, func(*blocks.Block) (PrevOutValueFunc, error) { return nil, fetchErr })
+	if err != fetchErr {
+		t.Errorf("expected fetch error, got %v", err)
+		return
+	}
+}
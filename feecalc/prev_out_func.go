@@ -0,0 +1,278 @@
+package feecalc
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/common"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/unspent"
+)
+
+// DefaultCacheSize is the number of transactions cached by NewCachedPrevOutFunc if cacheSize is zero.
+const DefaultCacheSize = 1000
+
+// DefaultPrefetchBatchSize is the number of transactions requested in each call to the
+// GetTxsFunc passed to NewPrefetchedPrevOutFunc, if batchSize is zero.
+const DefaultPrefetchBatchSize = 100
+
+// DefaultPrefetchConcurrency is the number of concurrent calls to the GetTxsFunc
+// passed to NewPrefetchedPrevOutFunc, if concurrency is zero.
+const DefaultPrefetchConcurrency = 4
+
+// A PrevOutFunc should return the output spent by a given PrevOut, including its script.
+// Should return ErrPrevOutNotFound if the given PrevOut could not be found. A PrevOutFunc
+// can be used as blocks.ValidationOptions.PrevOut.
+type PrevOutFunc func(*tx.PrevOut) (*tx.Output, error)
+
+// A GetTxFunc should return the transaction with the given txid, in RPC byte order. It should
+// return a nil transaction if it could not be found. For example, it can wrap rpc.Connection.GetRawTransaction.
+type GetTxFunc func(txid [32]byte) (*tx.Tx, error)
+
+// A GetTxsFunc should return the transactions with each of the given txids, in RPC byte order,
+// with nil entries for transactions which could not be found. For example, it can wrap
+// rpc.Connection.GetRawTransactions.
+type GetTxsFunc func(txids ...[32]byte) ([]*tx.Tx, error)
+
+// ValueFunc returns a PrevOutValueFunc which returns the values of outputs returned by getPrevOut.
+func (getPrevOut PrevOutFunc) ValueFunc() PrevOutValueFunc {
+	return func(prevOut *tx.PrevOut) (uint64, error) {
+		output, err := getPrevOut(prevOut)
+		if err != nil {
+			return 0, err
+		}
+		return output.Value, nil
+	}
+}
+
+func prevOutNotFound(prevOut *tx.PrevOut, reason string) error {
+	return fmt.Errorf(
+		"%w: %s - %x:%d",
+		ErrPrevOutNotFound, reason, common.ReverseBytes(prevOut.Hash[:]), prevOut.Index,
+	)
+}
+
+// outputOf returns the output of txn spent by prevOut.
+func outputOf(txn *tx.Tx, prevOut *tx.PrevOut) (*tx.Output, error) {
+	if txn == nil {
+		return nil, prevOutNotFound(prevOut, "txid not found")
+	} else if int(prevOut.Index) >= len(txn.Outputs) {
+		return nil, prevOutNotFound(prevOut, "bad index")
+	}
+	return txn.Outputs[prevOut.Index], nil
+}
+
+// txCache is a least-recently-used cache of transactions, keyed by their hash in internal byte order.
+type txCache struct {
+	mutex    sync.Mutex
+	capacity int
+	order    *list.List // front is most recently used
+	elements map[[32]byte]*list.Element
+}
+
+type txCacheEntry struct {
+	hash [32]byte
+	txn  *tx.Tx
+}
+
+func newTxCache(capacity int) *txCache {
+	return &txCache{
+		capacity: capacity,
+		order:    list.New(),
+		elements: make(map[[32]byte]*list.Element),
+	}
+}
+
+func (cache *txCache) get(hash [32]byte) (*tx.Tx, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, ok := cache.elements[hash]
+	if !ok {
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	return element.Value.(*txCacheEntry).txn, true
+}
+
+func (cache *txCache) add(hash [32]byte, txn *tx.Tx) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, ok := cache.elements[hash]; ok {
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.elements[hash] = cache.order.PushFront(&txCacheEntry{hash, txn})
+	if cache.order.Len() > cache.capacity {
+		oldest := cache.order.Remove(cache.order.Back()).(*txCacheEntry)
+		delete(cache.elements, oldest.hash)
+	}
+}
+
+// NewCachedPrevOutFunc returns a PrevOutFunc which fetches transactions using getTx, and caches
+// the cacheSize most recently used transactions, so that inputs spending outputs of the same
+// transaction only fetch it once. If cacheSize is zero, DefaultCacheSize is used. Transactions
+// which could not be found are not cached. The returned function is safe for concurrent use.
+func NewCachedPrevOutFunc(getTx GetTxFunc, cacheSize int) PrevOutFunc {
+	if cacheSize <= 0 {
+		cacheSize = DefaultCacheSize
+	}
+	cache := newTxCache(cacheSize)
+
+	return func(prevOut *tx.PrevOut) (*tx.Output, error) {
+		txn, ok := cache.get(prevOut.Hash)
+		if !ok {
+			var txid [32]byte
+			copy(txid[:], common.ReverseBytes(prevOut.Hash[:]))
+
+			var err error
+			if txn, err = getTx(txid); err != nil {
+				return nil, err
+			} else if txn != nil {
+				cache.add(prevOut.Hash, txn)
+			}
+		}
+
+		return outputOf(txn, prevOut)
+	}
+}
+
+// NewCachedPrevOutValueFunc returns a PrevOutValueFunc which caches transactions
+// fetched with getTx. See NewCachedPrevOutFunc.
+func NewCachedPrevOutValueFunc(getTx GetTxFunc, cacheSize int) PrevOutValueFunc {
+	return NewCachedPrevOutFunc(getTx, cacheSize).ValueFunc()
+}
+
+// NewPrefetchedPrevOutFunc fetches every transaction whose outputs are spent by the given block,
+// using concurrent calls to getTxs, which each request up to batchSize transactions. If batchSize
+// or concurrency are zero, DefaultPrefetchBatchSize and DefaultPrefetchConcurrency are used.
+// Outputs created earlier in the block itself are not fetched.
+//
+// It returns a PrevOutFunc which looks up the outputs spent by the block, and returns
+// ErrPrevOutNotFound for any other PrevOut, or if a transaction could not be found. Only
+// the spent outputs are kept in memory, not the whole transactions.
+func NewPrefetchedPrevOutFunc(block *blocks.Block, getTxs GetTxsFunc, batchSize, concurrency int) (PrevOutFunc, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultPrefetchBatchSize
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultPrefetchConcurrency
+	}
+
+	inBlock := make(map[[32]byte]*tx.Tx)
+	spentBy := make(map[[32]byte][]*tx.PrevOut)
+	var hashes [][32]byte
+
+	for i, txn := range block.Transactions {
+		if i > 0 {
+			for _, input := range txn.Inputs {
+				if _, ok := inBlock[input.PrevOut.Hash]; ok {
+					continue
+				} else if _, ok := spentBy[input.PrevOut.Hash]; !ok {
+					hashes = append(hashes, input.PrevOut.Hash)
+				}
+				spentBy[input.PrevOut.Hash] = append(spentBy[input.PrevOut.Hash], input.PrevOut)
+			}
+		}
+
+		hash, err := txn.Hash(false)
+		if err != nil {
+			return nil, err
+		}
+		inBlock[hash] = txn
+	}
+
+	var (
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		outputs  = make(map[tx.PrevOut]*tx.Output)
+		missing  = make(map[tx.PrevOut]error)
+	)
+
+	batches := make(chan [][32]byte)
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				mutex.Lock()
+				failed := firstErr != nil
+				mutex.Unlock()
+				if failed {
+					continue
+				}
+
+				txids := make([][32]byte, len(batch))
+				for i, hash := range batch {
+					copy(txids[i][:], common.ReverseBytes(hash[:]))
+				}
+
+				txns, err := getTxs(txids...)
+				if err == nil && len(txns) != len(batch) {
+					err = fmt.Errorf("requested %d transactions, received %d", len(batch), len(txns))
+				}
+
+				mutex.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					for i, hash := range batch {
+						for _, prevOut := range spentBy[hash] {
+							if output, err := outputOf(txns[i], prevOut); err != nil {
+								missing[*prevOut] = err
+							} else {
+								outputs[*prevOut] = output
+							}
+						}
+					}
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for start := 0; start < len(hashes); start += batchSize {
+		end := start + batchSize
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		batches <- hashes[start:end]
+	}
+	close(batches)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	getPrevOut := func(prevOut *tx.PrevOut) (*tx.Output, error) {
+		if output, ok := outputs[*prevOut]; ok {
+			return output, nil
+		} else if err, ok := missing[*prevOut]; ok {
+			return nil, err
+		} else if txn, ok := inBlock[prevOut.Hash]; ok {
+			return outputOf(txn, prevOut)
+		}
+		return nil, prevOutNotFound(prevOut, "not spent by block")
+	}
+	return getPrevOut, nil
+}
+
+// NewOutputSetPrevOutFunc returns a PrevOutFunc which looks up outputs in the given
+// unspent output set. The set must not be modified while the function is in use.
+func NewOutputSetPrevOutFunc(outputs *unspent.OutputSet) PrevOutFunc {
+	return func(prevOut *tx.PrevOut) (*tx.Output, error) {
+		output := outputs.GetByOutpoint(prevOut)
+		if output == nil {
+			return nil, prevOutNotFound(prevOut, "not in output set")
+		}
+		return output.TxOut, nil
+	}
+}
@@ -0,0 +1,260 @@
+package feecalc
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/common"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/unspent"
+)
+
+// Check that PrevOutFunc can be used to validate blocks.
+var _ = blocks.ValidationOptions{PrevOut: NewOutputSetPrevOutFunc(nil)}
+
+// fakeTxSource serves transactions by txid and counts how many times each was requested.
+type fakeTxSource struct {
+	mutex    sync.Mutex
+	txs      map[[32]byte]*tx.Tx
+	requests map[[32]byte]int
+	calls    int
+}
+
+func newFakeTxSource(t *testing.T, txns ...*tx.Tx) *fakeTxSource {
+	source := &fakeTxSource{
+		txs:      make(map[[32]byte]*tx.Tx),
+		requests: make(map[[32]byte]int),
+	}
+	for _, txn := range txns {
+		source.txs[txid(t, txn)] = txn
+	}
+	return source
+}
+
+func (source *fakeTxSource) getTx(txid [32]byte) (*tx.Tx, error) {
+	txns, err := source.getTxs(txid)
+	if err != nil {
+		return nil, err
+	}
+	return txns[0], nil
+}
+
+func (source *fakeTxSource) getTxs(txids ...[32]byte) ([]*tx.Tx, error) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	source.calls++
+	txns := make([]*tx.Tx, len(txids))
+	for i, txid := range txids {
+		source.requests[txid]++
+		txns[i] = source.txs[txid]
+	}
+	return txns, nil
+}
+
+// txid returns the txid of txn in RPC byte order.
+func txid(t *testing.T, txn *tx.Tx) (id [32]byte) {
+	hash, err := txn.Hash(false)
+	if err != nil {
+		t.Fatalf("failed to hash tx: %s", err)
+	}
+	copy(id[:], common.ReverseBytes(hash[:]))
+	return
+}
+
+func newTestTx(nonce byte, outputValues ...uint64) *tx.Tx {
+	txn := &tx.Tx{
+		Version: 2,
+		Inputs: []*tx.Input{{
+			PrevOut:  &tx.PrevOut{Hash: [32]byte{nonce}},
+			Script:   []byte{},
+			Sequence: 0xffffffff,
+		}},
+	}
+	for i, value := range outputValues {
+		txn.Outputs = append(txn.Outputs, &tx.Output{Value: value, Script: []byte{0x51, byte(i)}})
+	}
+	return txn
+}
+
+func spending(t *testing.T, txn *tx.Tx, indexes ...uint32) []*tx.PrevOut {
+	hash, err := txn.Hash(false)
+	if err != nil {
+		t.Fatalf("failed to hash tx: %s", err)
+	}
+
+	prevOuts := make([]*tx.PrevOut, len(indexes))
+	for i, index := range indexes {
+		prevOuts[i] = &tx.PrevOut{Hash: hash, Index: index}
+	}
+	return prevOuts
+}
+
+func TestNewCachedPrevOutFunc(t *testing.T) {
+	parents := []*tx.Tx{
+		newTestTx(1, 1000, 2000, 3000),
+		newTestTx(2, 4000),
+		newTestTx(3, 5000),
+	}
+	source := newFakeTxSource(t, parents...)
+
+	getPrevOut := NewCachedPrevOutFunc(source.getTx, 2)
+
+	for _, prevOut := range spending(t, parents[0], 0, 2, 1) {
+		output, err := getPrevOut(prevOut)
+		if err != nil {
+			t.Errorf("failed to get prev out: %s", err)
+			return
+		} else if output != parents[0].Outputs[prevOut.Index] {
+			t.Errorf("incorrect output returned for index %d", prevOut.Index)
+			return
+		}
+	}
+
+	if n := source.requests[txid(t, parents[0])]; n != 1 {
+		t.Errorf("expected parent to be fetched once, got %d", n)
+		return
+	}
+
+	// Fetching parents 1 and 2 evicts parent 0 from the cache.
+	getPrevOutValue := NewCachedPrevOutFunc(source.getTx, 2).ValueFunc()
+	sequence := []*tx.PrevOut{
+		spending(t, parents[0], 0)[0],
+		spending(t, parents[1], 0)[0],
+		spending(t, parents[0], 1)[0],
+		spending(t, parents[2], 0)[0],
+		spending(t, parents[1], 0)[0],
+		spending(t, parents[0], 2)[0],
+	}
+	for _, prevOut := range sequence {
+		if _, err := getPrevOutValue(prevOut); err != nil {
+			t.Errorf("failed to get prev out value: %s", err)
+			return
+		}
+	}
+
+	expectedRequests := []int{3, 2, 1}
+	for i, parent := range parents {
+		if n := source.requests[txid(t, parent)]; n != expectedRequests[i] {
+			t.Errorf("incorrect number of requests for parent %d\nWanted %d\nGot    %d", i, expectedRequests[i], n)
+			return
+		}
+	}
+
+	if _, err := getPrevOut(&tx.PrevOut{Hash: [32]byte{0xff}}); !errors.Is(err, ErrPrevOutNotFound) {
+		t.Errorf("expected ErrPrevOutNotFound for unknown tx, got %v", err)
+		return
+	} else if _, err := getPrevOut(spending(t, parents[0], 3)[0]); !errors.Is(err, ErrPrevOutNotFound) {
+		t.Errorf("expected ErrPrevOutNotFound for bad index, got %v", err)
+		return
+	}
+}
+
+func TestNewPrefetchedPrevOutFunc(t *testing.T) {
+	var parents []*tx.Tx
+	for i := 0; i < 25; i++ {
+		parents = append(parents, newTestTx(byte(i), 10000, 20000))
+	}
+	source := newFakeTxSource(t, parents[1:]...)
+
+	coinbase := newTestTx(0xcb, 5000000000)
+	block := &blocks.Block{Transactions: []*tx.Tx{coinbase}}
+
+	// Each transaction spends both outputs of a parent, and the last
+	// transaction spends an output created earlier in the block.
+	for _, parent := range parents {
+		child := newTestTx(0, 25000)
+		child.Inputs = nil
+		for _, prevOut := range spending(t, parent, 0, 1) {
+			child.Inputs = append(child.Inputs, &tx.Input{PrevOut: prevOut, Script: []byte{}, Sequence: 0xffffffff})
+		}
+		block.Transactions = append(block.Transactions, child)
+	}
+	inBlockSpend := newTestTx(0, 20000)
+	inBlockSpend.Inputs[0].PrevOut = spending(t, block.Transactions[5], 0)[0]
+	block.Transactions = append(block.Transactions, inBlockSpend)
+
+	getPrevOut, err := NewPrefetchedPrevOutFunc(block, source.getTxs, 10, 3)
+	if err != nil {
+		t.Errorf("failed to prefetch prev outs: %s", err)
+		return
+	}
+
+	if source.calls != 3 {
+		t.Errorf("expected 3 batches, got %d", source.calls)
+		return
+	}
+	for i, parent := range parents {
+		if n := source.requests[txid(t, parent)]; n != 1 {
+			t.Errorf("expected parent %d to be requested once, got %d", i, n)
+			return
+		}
+	}
+
+	for i, parent := range parents[1:] {
+		for _, prevOut := range spending(t, parent, 0, 1) {
+			output, err := getPrevOut(prevOut)
+			if err != nil {
+				t.Errorf("failed to get prev out of parent %d: %s", i+1, err)
+				return
+			} else if output != parent.Outputs[prevOut.Index] {
+				t.Errorf("incorrect output for parent %d", i+1)
+				return
+			}
+		}
+	}
+
+	if output, err := getPrevOut(inBlockSpend.Inputs[0].PrevOut); err != nil || output.Value != 25000 {
+		t.Errorf("failed to get output created in block: %v", err)
+		return
+	}
+
+	if _, err := getPrevOut(spending(t, parents[0], 0)[0]); !errors.Is(err, ErrPrevOutNotFound) {
+		t.Errorf("expected ErrPrevOutNotFound for missing parent, got %v", err)
+		return
+	} else if _, err := getPrevOut(&tx.PrevOut{Hash: [32]byte{0xff}}); !errors.Is(err, ErrPrevOutNotFound) {
+		t.Errorf("expected ErrPrevOutNotFound for unrelated prev out, got %v", err)
+		return
+	}
+
+	// Fees can be computed for every transaction except the one with a missing parent.
+	getPrevOutValue := getPrevOut.ValueFunc()
+	for _, txn := range block.Transactions[2:] {
+		if _, err := TotalFeeValue(txn, getPrevOutValue); err != nil {
+			t.Errorf("failed to compute fee: %s", err)
+			return
+		}
+	}
+
+	fetchErr := errors.New("connection refused")
+	failing := func(...[32]byte) ([]*tx.Tx, error) { return nil, fetchErr }
+	if _, err := NewPrefetchedPrevOutFunc(block, failing, 0, 0); err != fetchErr {
+		t.Errorf("expected fetch error, got %v", err)
+		return
+	}
+}
+
+func TestNewOutputSetPrevOutFunc(t *testing.T) {
+	parent := newTestTx(1, 1000, 2000)
+	prevOuts := spending(t, parent, 0, 1)
+
+	outputSet := unspent.NewOutputSet([]*unspent.Output{
+		{Outpoint: prevOuts[1], TxOut: parent.Outputs[1]},
+	})
+	getPrevOut := NewOutputSetPrevOutFunc(outputSet)
+
+	if output, err := getPrevOut(prevOuts[1]); err != nil || output != parent.Outputs[1] {
+		t.Errorf("failed to get output from set: %v", err)
+		return
+	} else if _, err := getPrevOut(prevOuts[0]); !errors.Is(err, ErrPrevOutNotFound) {
+		t.Errorf("expected ErrPrevOutNotFound, got %v", err)
+		return
+	}
+
+	if value, err := getPrevOut.ValueFunc()(prevOuts[1]); err != nil || value != 2000 {
+		t.Errorf("incorrect value from set: %d, %v", value, err)
+		return
+	}
+}
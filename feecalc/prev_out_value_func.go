@@ -20,7 +20,8 @@ type PrevOutValueFunc func(*tx.PrevOut) (uint64, error)
 // NewNaivePrevOutValueFunc returns a naive non-caching implementation of a PrevOutValueFunc
 // using transaction data fetched by the given getTxHex function. Each call to the
 // PrevOutValueFunc fetches the transaction involved in the requested previous output.
-// The transaction is then parsed and the output value returned.
+// The transaction is then parsed and the output value returned. NewCachedPrevOutValueFunc
+// avoids fetching the same transaction repeatedly.
 func NewNaivePrevOutValueFunc(getTxHex func(txid string) (string, error)) PrevOutValueFunc {
 	return func(prevOut *tx.PrevOut) (uint64, error) {
 		txid := hex.EncodeToString(common.ReverseBytes(prevOut.Hash[:]))
@@ -0,0 +1,281 @@
+package gcs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FilterCheckpointInterval is the number of blocks between filter header checkpoints,
+// as served in BIP157 cfcheckpt messages.
+const FilterCheckpointInterval = 1000
+
+var (
+	// ErrUnknownBlock is returned by FilterHeaderChain methods when given a block hash
+	// which is not in the chain.
+	ErrUnknownBlock = errors.New("block hash is not in filter header chain")
+
+	// ErrInvalidFilterHeaders is returned by FilterHeaderChain methods when a cfheaders
+	// or cfcheckpt response is malformed, or does not connect to the accepted chain.
+	ErrInvalidFilterHeaders = errors.New("invalid filter headers")
+
+	// ErrCheckpointMismatch is returned by FilterHeaderChain.AddFilterHeaders if the
+	// filter headers do not match a checkpoint set by SetCheckpoints.
+	ErrCheckpointMismatch = errors.New("filter headers do not match checkpoint")
+
+	// ErrConflictingFilterHeaders is returned by FilterHeaderChain methods when given
+	// filter headers which conflict with those previously accepted from another source.
+	ErrConflictingFilterHeaders = errors.New("filter headers conflict with previously accepted headers")
+
+	// ErrFilterHeaderMismatch is returned by FilterHeaderChain.VerifyFilter if a
+	// filter does not match the accepted filter header chain.
+	ErrFilterHeaderMismatch = errors.New("filter does not match filter header")
+)
+
+// ChainFilterHeaders computes the filter headers for the given consecutive filter hashes,
+// starting from the filter header of the block before the first filter. This is how
+// the filter hashes in a BIP157 cfheaders message are checked. All hashes are in RPC byte order.
+func ChainFilterHeaders(prevHeader [32]byte, filterHashes [][32]byte) [][32]byte {
+	headers := make([][32]byte, len(filterHashes))
+	for i, filterHash := range filterHashes {
+		prevHeader = FilterHeader(filterHash, prevHeader)
+		headers[i] = prevHeader
+	}
+	return headers
+}
+
+// FirstCheckpointConflict compares the filter header checkpoints served by multiple sources,
+// and returns the index of the first checkpoint on which any two sources disagree, or -1 if
+// they all agree. Checkpoint i is the filter header at height (i+1)*FilterCheckpointInterval.
+// Sources which served fewer checkpoints are only compared up to their length.
+//
+// When sources conflict, a client should download the filter headers and then the block at
+// the conflicting interval, to determine which source is honest.
+func FirstCheckpointConflict(checkpointSets ...[][32]byte) int {
+	for i := 0; ; i++ {
+		var expected *[32]byte
+		remaining := false
+		for _, checkpoints := range checkpointSets {
+			if i >= len(checkpoints) {
+				continue
+			}
+			remaining = true
+			if expected == nil {
+				expected = &checkpoints[i]
+			} else if *expected != checkpoints[i] {
+				return i
+			}
+		}
+		if !remaining {
+			return -1
+		}
+	}
+}
+
+// FilterHeaderChain tracks the BIP157 filter headers of a chain of blocks, as accepted
+// from cfheaders and cfcheckpt responses served by untrusted peers. Block hashes,
+// filter hashes and filter headers are all in RPC byte order, as returned by
+// blockheader.BlockHeader.Hash and Filter.Hash.
+//
+// Filter headers up to CheckpointHeight are verified against checkpoints, so they can only be
+// wrong if the accepted checkpoints are. Checkpoints, and filter headers after the last of
+// them, are accepted from whichever peer served them first. A peer which disagrees is detected
+// with ErrConflictingFilterHeaders, and the client can then use Rollback to discard what was
+// accepted from the first peer, and accept the other peer's checkpoints and filter headers
+// instead, once it has decided which of the two is honest.
+type FilterHeaderChain struct {
+	blockHashes [][32]byte
+	heights     map[[32]byte]int
+	headers     [][32]byte
+	checkpoints [][32]byte
+}
+
+// NewFilterHeaderChain creates a FilterHeaderChain for the given block hashes, which must
+// be the hashes of the best chain of blocks ordered by height, starting with the genesis block.
+func NewFilterHeaderChain(blockHashes [][32]byte) *FilterHeaderChain {
+	chain := &FilterHeaderChain{heights: make(map[[32]byte]int)}
+	chain.AddBlockHashes(blockHashes...)
+	return chain
+}
+
+// AddBlockHashes extends the chain of blocks with the given block hashes, in order of height.
+func (chain *FilterHeaderChain) AddBlockHashes(blockHashes ...[32]byte) {
+	for _, blockHash := range blockHashes {
+		chain.heights[blockHash] = len(chain.blockHashes)
+		chain.blockHashes = append(chain.blockHashes, blockHash)
+	}
+}
+
+// Height returns the height of the last block whose filter header has been accepted,
+// or -1 if no filter headers have been accepted.
+func (chain *FilterHeaderChain) Height() int {
+	return len(chain.headers) - 1
+}
+
+// CheckpointHeight returns the height of the last accepted filter header which is covered
+// by an accepted checkpoint, or -1 if there is none. Every filter header up to this height
+// chains to a checkpoint.
+func (chain *FilterHeaderChain) CheckpointHeight() int {
+	nCovered := chain.Height() / FilterCheckpointInterval
+	if nCovered > len(chain.checkpoints) {
+		nCovered = len(chain.checkpoints)
+	}
+	if nCovered <= 0 {
+		return -1
+	}
+	return nCovered * FilterCheckpointInterval
+}
+
+// FilterHeader returns the accepted filter header of the block with the given hash.
+// The returned bool is false if the block's filter header has not been accepted.
+func (chain *FilterHeaderChain) FilterHeader(blockHash [32]byte) ([32]byte, bool) {
+	height, ok := chain.heights[blockHash]
+	if !ok || height >= len(chain.headers) {
+		return [32]byte{}, false
+	}
+	return chain.headers[height], true
+}
+
+// Rollback discards the accepted filter headers and checkpoints above the given height. It
+// is used when another peer serves filter headers or checkpoints which conflict with those
+// accepted, and the client decides that the other peer is honest:
+//
+//   - If AddFilterHeaders returns ErrConflictingFilterHeaders for headers after the last
+//     checkpoint, Rollback(chain.CheckpointHeight()) discards the headers accepted on trust.
+//   - If SetCheckpoints returns ErrConflictingFilterHeaders, and FirstCheckpointConflict
+//     returns i for the accepted and new checkpoints, Rollback(i*FilterCheckpointInterval)
+//     discards the conflicting checkpoints and every filter header which chains to them.
+//
+// The other peer's checkpoints and filter headers can then be accepted.
+func (chain *FilterHeaderChain) Rollback(height int) {
+	if height < -1 {
+		height = -1
+	}
+	if len(chain.headers) > height+1 {
+		chain.headers = chain.headers[:height+1]
+	}
+
+	nCheckpoints := 0
+	if height > 0 {
+		nCheckpoints = height / FilterCheckpointInterval
+	}
+	if len(chain.checkpoints) > nCheckpoints {
+		chain.checkpoints = chain.checkpoints[:nCheckpoints]
+	}
+}
+
+// Checkpoints returns the filter header checkpoints which have been accepted.
+func (chain *FilterHeaderChain) Checkpoints() [][32]byte {
+	return chain.checkpoints
+}
+
+// SetCheckpoints accepts the filter header checkpoints from a cfcheckpt response for the given
+// stop block. There must be one checkpoint for every FilterCheckpointInterval blocks up to the
+// stop block. Returns an error wrapping ErrConflictingFilterHeaders if the checkpoints disagree
+// with previously accepted checkpoints or filter headers, in which case nothing is changed;
+// see Rollback.
+func (chain *FilterHeaderChain) SetCheckpoints(stopHash [32]byte, checkpoints [][32]byte) error {
+	stopHeight, ok := chain.heights[stopHash]
+	if !ok {
+		return ErrUnknownBlock
+	} else if len(checkpoints) != stopHeight/FilterCheckpointInterval {
+		return fmt.Errorf(
+			"%w: expected %d checkpoints up to height %d, got %d",
+			ErrInvalidFilterHeaders, stopHeight/FilterCheckpointInterval, stopHeight, len(checkpoints),
+		)
+	}
+
+	if i := FirstCheckpointConflict(chain.checkpoints, checkpoints); i >= 0 {
+		return fmt.Errorf("%w: checkpoint at height %d", ErrConflictingFilterHeaders, (i+1)*FilterCheckpointInterval)
+	}
+	for i, checkpoint := range checkpoints {
+		height := (i + 1) * FilterCheckpointInterval
+		if height < len(chain.headers) && chain.headers[height] != checkpoint {
+			return fmt.Errorf("%w: checkpoint at height %d", ErrConflictingFilterHeaders, height)
+		}
+	}
+
+	if len(checkpoints) > len(chain.checkpoints) {
+		chain.checkpoints = append([][32]byte{}, checkpoints...)
+	}
+	return nil
+}
+
+// AddFilterHeaders checks and accepts the filter hashes from a cfheaders response, which
+// ends at the block with the given stop hash. The previous filter header must match the
+// accepted chain, or be all zeros if the filter hashes begin at the genesis block. The
+// resulting filter headers must match any accepted checkpoints in their range.
+//
+// Filter headers which overlap those already accepted must be identical to them, otherwise
+// an error wrapping ErrConflictingFilterHeaders is returned. Filter headers may not skip
+// past the last accepted height.
+//
+// Filter headers after the last checkpoint are accepted on trust from the peer which served
+// them, because there is nothing to check them against. They are checked when a later call
+// to SetCheckpoints covers them, which returns ErrConflictingFilterHeaders if they disagree.
+// CheckpointHeight reports how far the accepted headers are covered by checkpoints, and
+// Rollback discards the headers after it.
+func (chain *FilterHeaderChain) AddFilterHeaders(stopHash, prevHeader [32]byte, filterHashes [][32]byte) error {
+	stopHeight, ok := chain.heights[stopHash]
+	if !ok {
+		return ErrUnknownBlock
+	} else if len(filterHashes) == 0 || len(filterHashes) > stopHeight+1 {
+		return fmt.Errorf("%w: %d filter hashes ending at height %d", ErrInvalidFilterHeaders, len(filterHashes), stopHeight)
+	}
+
+	startHeight := stopHeight - len(filterHashes) + 1
+	if startHeight > len(chain.headers) {
+		return fmt.Errorf(
+			"%w: headers start at height %d, but only accepted up to %d",
+			ErrInvalidFilterHeaders, startHeight, chain.Height(),
+		)
+	}
+
+	var expectedPrevHeader [32]byte
+	if startHeight > 0 {
+		expectedPrevHeader = chain.headers[startHeight-1]
+	}
+	if prevHeader != expectedPrevHeader {
+		return fmt.Errorf("%w: previous filter header at height %d", ErrConflictingFilterHeaders, startHeight-1)
+	}
+
+	headers := ChainFilterHeaders(prevHeader, filterHashes)
+	for i, header := range headers {
+		height := startHeight + i
+		if height < len(chain.headers) && chain.headers[height] != header {
+			return fmt.Errorf("%w: filter header at height %d", ErrConflictingFilterHeaders, height)
+		}
+
+		if height > 0 && height%FilterCheckpointInterval == 0 {
+			if index := height/FilterCheckpointInterval - 1; index < len(chain.checkpoints) && chain.checkpoints[index] != header {
+				return fmt.Errorf("%w: height %d", ErrCheckpointMismatch, height)
+			}
+		}
+	}
+
+	if len(chain.headers) <= stopHeight {
+		chain.headers = append(chain.headers, headers[len(chain.headers)-startHeight:]...)
+	}
+	return nil
+}
+
+// VerifyFilter checks that the given filter for the block with the given hash
+// matches the accepted filter header chain. Returns ErrFilterHeaderMismatch if
+// the filter does not match, or ErrUnknownBlock if the block's filter header
+// has not been accepted. Filters above CheckpointHeight are only as trustworthy
+// as the peer which served their filter headers, until Rollback discards them.
+func (chain *FilterHeaderChain) VerifyFilter(blockHash [32]byte, filter *Filter) error {
+	height, ok := chain.heights[blockHash]
+	if !ok || height >= len(chain.headers) {
+		return ErrUnknownBlock
+	}
+
+	var prevHeader [32]byte
+	if height > 0 {
+		prevHeader = chain.headers[height-1]
+	}
+
+	if FilterHeader(filter.Hash(), prevHeader) != chain.headers[height] {
+		return fmt.Errorf("%w: height %d", ErrFilterHeaderMismatch, height)
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package gcs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/blocks"
+)
+
+func makeTestFilterChain(n int) (blockHashes, filterHashes, filterHeaders [][32]byte) {
+	blockHashes = make([][32]byte, n)
+	filterHashes = make([][32]byte, n)
+	for i := 0; i < n; i++ {
+		var buf [9]byte
+		binary.LittleEndian.PutUint64(buf[:], uint64(i))
+		blockHashes[i] = sha256.Sum256(buf[:])
+		buf[8] = 1
+		filterHashes[i] = sha256.Sum256(buf[:])
+	}
+	filterHeaders = ChainFilterHeaders([32]byte{}, filterHashes)
+	return
+}
+
+func extractCheckpoints(filterHeaders [][32]byte, stopHeight int) [][32]byte {
+	var checkpoints [][32]byte
+	for height := FilterCheckpointInterval; height <= stopHeight; height += FilterCheckpointInterval {
+		checkpoints = append(checkpoints, filterHeaders[height])
+	}
+	return checkpoints
+}
+
+func TestFilterHeaderChain(t *testing.T) {
+	blockHashes, filterHashes, filterHeaders := makeTestFilterChain(2500)
+
+	chain := NewFilterHeaderChain(blockHashes)
+	if err := chain.SetCheckpoints(blockHashes[2499], extractCheckpoints(filterHeaders, 2499)); err != nil {
+		t.Errorf("failed to set checkpoints: %s", err)
+		return
+	}
+
+	// Serve filter headers in batches of up to 2000, as BIP157 peers do.
+	if err := chain.AddFilterHeaders(blockHashes[1999], [32]byte{}, filterHashes[:2000]); err != nil {
+		t.Errorf("failed to add first batch of filter headers: %s", err)
+		return
+	} else if chain.CheckpointHeight() != 1000 {
+		t.Errorf("expected checkpoint height 1000, got %d", chain.CheckpointHeight())
+		return
+	}
+	if err := chain.AddFilterHeaders(blockHashes[2499], filterHeaders[1999], filterHashes[2000:]); err != nil {
+		t.Errorf("failed to add second batch of filter headers: %s", err)
+		return
+	}
+
+	// Headers after the last checkpoint are accepted, but not covered by a checkpoint.
+	if chain.Height() != 2499 {
+		t.Errorf("expected height 2499, got %d", chain.Height())
+		return
+	} else if chain.CheckpointHeight() != 2000 {
+		t.Errorf("expected checkpoint height 2000, got %d", chain.CheckpointHeight())
+		return
+	}
+
+	for _, height := range []int{0, 999, 1000, 2499} {
+		header, ok := chain.FilterHeader(blockHashes[height])
+		if !ok || header != filterHeaders[height] {
+			t.Errorf("unexpected filter header at height %d", height)
+			return
+		}
+	}
+
+	// Overlapping headers which agree with the chain are accepted.
+	if err := chain.AddFilterHeaders(blockHashes[1500], filterHeaders[999], filterHashes[1000:1501]); err != nil {
+		t.Errorf("failed to add overlapping filter headers: %s", err)
+		return
+	}
+}
+
+func TestFilterHeaderChain_Invalid(t *testing.T) {
+	blockHashes, filterHashes, filterHeaders := makeTestFilterChain(2500)
+	checkpoints := extractCheckpoints(filterHeaders, 2499)
+
+	newChain := func() *FilterHeaderChain {
+		chain := NewFilterHeaderChain(blockHashes)
+		if err := chain.SetCheckpoints(blockHashes[2499], checkpoints); err != nil {
+			t.Fatalf("failed to set checkpoints: %s", err)
+		}
+		return chain
+	}
+
+	badFilterHashes := append([][32]byte{}, filterHashes...)
+	badFilterHashes[500][0] ^= 1
+	badFilterHashes[1500][0] ^= 1
+
+	chain := newChain()
+	if err := chain.AddFilterHeaders(blockHashes[1999], [32]byte{}, badFilterHashes[:2000]); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Errorf("expected ErrCheckpointMismatch, got %v", err)
+		return
+	} else if chain.Height() != -1 {
+		t.Errorf("invalid filter headers were accepted")
+		return
+	}
+
+	if err := chain.AddFilterHeaders(blockHashes[1999], [32]byte{1}, filterHashes[:2000]); !errors.Is(err, ErrConflictingFilterHeaders) {
+		t.Errorf("expected ErrConflictingFilterHeaders for wrong previous header, got %v", err)
+		return
+	}
+
+	if err := chain.AddFilterHeaders(blockHashes[2499], filterHeaders[1999], filterHashes[2000:]); !errors.Is(err, ErrInvalidFilterHeaders) {
+		t.Errorf("expected ErrInvalidFilterHeaders for headers which skip ahead, got %v", err)
+		return
+	}
+
+	if err := chain.AddFilterHeaders([32]byte{1}, [32]byte{}, filterHashes[:10]); !errors.Is(err, ErrUnknownBlock) {
+		t.Errorf("expected ErrUnknownBlock, got %v", err)
+		return
+	}
+
+	if err := chain.SetCheckpoints(blockHashes[2499], checkpoints[:1]); !errors.Is(err, ErrInvalidFilterHeaders) {
+		t.Errorf("expected ErrInvalidFilterHeaders for too few checkpoints, got %v", err)
+		return
+	}
+
+	// A second source serving different checkpoints conflicts with the first.
+	badCheckpoints := append([][32]byte{}, checkpoints...)
+	badCheckpoints[1][0] ^= 1
+	if err := chain.SetCheckpoints(blockHashes[2499], badCheckpoints); !errors.Is(err, ErrConflictingFilterHeaders) {
+		t.Errorf("expected ErrConflictingFilterHeaders for checkpoints, got %v", err)
+		return
+	}
+
+	// Without checkpoints, headers from a second source which conflict with verified headers are detected.
+	chain = NewFilterHeaderChain(blockHashes)
+	if err := chain.AddFilterHeaders(blockHashes[1999], [32]byte{}, filterHashes[:2000]); err != nil {
+		t.Errorf("failed to add filter headers: %s", err)
+		return
+	} else if chain.CheckpointHeight() != -1 {
+		t.Errorf("expected no checkpoint height without checkpoints, got %d", chain.CheckpointHeight())
+		return
+	}
+	if err := chain.AddFilterHeaders(blockHashes[1999], filterHeaders[999], badFilterHashes[1000:2000]); !errors.Is(err, ErrConflictingFilterHeaders) {
+		t.Errorf("expected ErrConflictingFilterHeaders, got %v", err)
+		return
+	}
+	badCheckpoints[0][0] ^= 1
+	if err := chain.SetCheckpoints(blockHashes[2499], badCheckpoints); !errors.Is(err, ErrConflictingFilterHeaders) {
+		t.Errorf("expected ErrConflictingFilterHeaders for checkpoints conflicting with headers, got %v", err)
+		return
+	}
+}
+
+func TestFilterHeaderChain_Rollback(t *testing.T) {
+	blockHashes, filterHashes, filterHeaders := makeTestFilterChain(2500)
+	checkpoints := extractCheckpoints(filterHeaders, 2499)
+
+	// The bad peer's filters diverge from the honest ones after the first checkpoint.
+	badFilterHashes := append([][32]byte{}, filterHashes...)
+	badFilterHashes[1500][0] ^= 1
+	badFilterHeaders := ChainFilterHeaders([32]byte{}, badFilterHashes)
+	badCheckpoints := extractCheckpoints(badFilterHeaders, 2499)
+
+	// The bad peer answers first, and its checkpoints and filter headers are accepted.
+	chain := NewFilterHeaderChain(blockHashes)
+	if err := chain.SetCheckpoints(blockHashes[2499], badCheckpoints); err != nil {
+		t.Errorf("failed to set bad checkpoints: %s", err)
+		return
+	} else if err := chain.AddFilterHeaders(blockHashes[1999], [32]byte{}, badFilterHashes[:2000]); err != nil {
+		t.Errorf("failed to add bad filter headers: %s", err)
+		return
+	} else if err := chain.AddFilterHeaders(blockHashes[2499], badFilterHeaders[1999], badFilterHashes[2000:]); err != nil {
+		t.Errorf("failed to add bad filter headers: %s", err)
+		return
+	}
+
+	if err := chain.SetCheckpoints(blockHashes[2499], checkpoints); !errors.Is(err, ErrConflictingFilterHeaders) {
+		t.Errorf("expected ErrConflictingFilterHeaders for honest checkpoints, got %v", err)
+		return
+	}
+
+	conflict := FirstCheckpointConflict(chain.Checkpoints(), checkpoints)
+	if conflict != 1 {
+		t.Errorf("expected conflict at checkpoint 1, got %d", conflict)
+		return
+	}
+	chain.Rollback(conflict * FilterCheckpointInterval)
+	if chain.Height() != 1000 || len(chain.Checkpoints()) != 1 {
+		t.Errorf("expected rollback to height 1000 with 1 checkpoint, got %d with %d", chain.Height(), len(chain.Checkpoints()))
+		return
+	}
+
+	// The honest peer's checkpoints and filter headers now replace the bad peer's.
+	if err := chain.SetCheckpoints(blockHashes[2499], checkpoints); err != nil {
+		t.Errorf("failed to set honest checkpoints after rollback: %s", err)
+		return
+	} else if err := chain.AddFilterHeaders(blockHashes[1999], filterHeaders[1000], filterHashes[1001:2000]); err != nil {
+		t.Errorf("failed to add honest filter headers after rollback: %s", err)
+		return
+	} else if err := chain.AddFilterHeaders(blockHashes[2499], filterHeaders[1999], filterHashes[2000:]); err != nil {
+		t.Errorf("failed to add honest filter headers after rollback: %s", err)
+		return
+	}
+
+	if err := chain.SetCheckpoints(blockHashes[2499], badCheckpoints); !errors.Is(err, ErrConflictingFilterHeaders) {
+		t.Errorf("expected ErrConflictingFilterHeaders for bad checkpoints after rollback, got %v", err)
+		return
+	}
+	for height := 0; height < 2500; height++ {
+		if header, ok := chain.FilterHeader(blockHashes[height]); !ok || header != filterHeaders[height] {
+			t.Errorf("unexpected filter header at height %d after rollback", height)
+			return
+		}
+	}
+
+	// Filter headers after the last checkpoint from a bad peer which answers first are
+	// discarded by rolling back to the checkpoint height.
+	laterBadFilterHashes := append([][32]byte{}, filterHashes...)
+	laterBadFilterHashes[2200][0] ^= 1
+
+	chain = NewFilterHeaderChain(blockHashes)
+	if err := chain.SetCheckpoints(blockHashes[2499], checkpoints); err != nil {
+		t.Errorf("failed to set checkpoints: %s", err)
+		return
+	} else if err := chain.AddFilterHeaders(blockHashes[1999], [32]byte{}, filterHashes[:2000]); err != nil {
+		t.Errorf("failed to add filter headers: %s", err)
+		return
+	} else if err := chain.AddFilterHeaders(blockHashes[2499], filterHeaders[1999], laterBadFilterHashes[2000:]); err != nil {
+		t.Errorf("failed to add bad filter headers after last checkpoint: %s", err)
+		return
+	}
+
+	if err := chain.AddFilterHeaders(blockHashes[2499], filterHeaders[1999], filterHashes[2000:]); !errors.Is(err, ErrConflictingFilterHeaders) {
+		t.Errorf("expected ErrConflictingFilterHeaders for honest filter headers, got %v", err)
+		return
+	}
+
+	chain.Rollback(chain.CheckpointHeight())
+	if chain.Height() != 2000 || len(chain.Checkpoints()) != 2 {
+		t.Errorf("expected rollback to height 2000 with 2 checkpoints, got %d with %d", chain.Height(), len(chain.Checkpoints()))
+		return
+	} else if err := chain.AddFilterHeaders(blockHashes[2499], filterHeaders[2000], filterHashes[2001:]); err != nil {
+		t.Errorf("failed to add honest filter headers after rollback: %s", err)
+		return
+	} else if header, _ := chain.FilterHeader(blockHashes[2499]); header != filterHeaders[2499] {
+		t.Errorf("unexpected filter header at height 2499 after rollback")
+		return
+	}
+}
+
+func TestFirstCheckpointConflict(t *testing.T) {
+	a := [][32]byte{{1}, {2}, {3}}
+	b := [][32]byte{{1}, {2}}
+	c := [][32]byte{{1}, {9}, {3}}
+
+	if i := FirstCheckpointConflict(a, b); i != -1 {
+		t.Errorf("expected no conflict, got %d", i)
+		return
+	}
+	if i := FirstCheckpointConflict(a, b, c); i != 1 {
+		t.Errorf("expected conflict at index 1, got %d", i)
+		return
+	}
+	if i := FirstCheckpointConflict(); i != -1 {
+		t.Errorf("expected no conflict with no sources, got %d", i)
+		return
+	}
+}
+
+func TestFilterHeaderChain_VerifyFilter(t *testing.T) {
+	block, err := blocks.FromReader(bytes.NewReader(hex2bytes(testnetGenesisBlockHex)))
+	if err != nil {
+		t.Errorf("failed to decode block: %s", err)
+		return
+	}
+	blockHash, err := block.Header.Hash()
+	if err != nil {
+		t.Errorf("failed to hash block header: %s", err)
+		return
+	}
+
+	filter, err := BuildBasicFilter(block, nil)
+	if err != nil {
+		t.Errorf("failed to build filter: %s", err)
+		return
+	}
+
+	chain := NewFilterHeaderChain([][32]byte{blockHash})
+	if err := chain.VerifyFilter(blockHash, filter); !errors.Is(err, ErrUnknownBlock) {
+		t.Errorf("expected ErrUnknownBlock before filter headers are verified, got %v", err)
+		return
+	}
+
+	if err := chain.AddFilterHeaders(blockHash, [32]byte{}, [][32]byte{filter.Hash()}); err != nil {
+		t.Errorf("failed to add filter headers: %s", err)
+		return
+	}
+
+	if err := chain.VerifyFilter(blockHash, filter); err != nil {
+		t.Errorf("failed to verify filter: %s", err)
+		return
+	}
+
+	wrongFilter, err := BuildBasicFilter(block, [][]byte{{0x51}})
+	if err != nil {
+		t.Errorf("failed to build filter: %s", err)
+		return
+	}
+	if err := chain.VerifyFilter(blockHash, wrongFilter); !errors.Is(err, ErrFilterHeaderMismatch) {
+		t.Errorf("expected ErrFilterHeaderMismatch, got %v", err)
+		return
+	}
+}
@@ -0,0 +1,101 @@
+package gcs
+
+import (
+	"github.com/kklash/bitcoinlib/bhash"
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+	"github.com/kklash/bitcoinlib/common"
+	"github.com/kklash/bitcoinlib/constants"
+)
+
+const (
+	// BasicFilterP is the Golomb-Rice parameter of BIP158 basic filters.
+	BasicFilterP = 19
+
+	// BasicFilterM is the inverse false positive rate of BIP158 basic filters.
+	BasicFilterM = 784931
+)
+
+// BasicFilterKey returns the SipHash key for the BIP158 basic filter of the block with
+// the given header: the first 16 bytes of the block hash, in internal byte order.
+func BasicFilterKey(header *blockheader.BlockHeader) ([KeySize]byte, error) {
+	var key [KeySize]byte
+	blockHash, err := header.Hash()
+	if err != nil {
+		return key, err
+	}
+
+	copy(key[:], common.ReverseBytes(blockHash[:]))
+	return key, nil
+}
+
+// BuildBasicFilter constructs the BIP158 basic filter for the given block. The filter contains
+// every output script in the block except OP_RETURN outputs, and every script spent by the
+// block's inputs, which the caller must provide in prevOutScripts in any order.
+func BuildBasicFilter(block *blocks.Block, prevOutScripts [][]byte) (*Filter, error) {
+	key, err := BasicFilterKey(block.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var items [][]byte
+	addItem := func(script []byte) {
+		if len(script) == 0 || seen[string(script)] {
+			return
+		}
+		seen[string(script)] = true
+		items = append(items, script)
+	}
+
+	for _, txn := range block.Transactions {
+		for _, output := range txn.Outputs {
+			if len(output.Script) > 0 && output.Script[0] == constants.OP_RETURN {
+				continue
+			}
+			addItem(output.Script)
+		}
+	}
+
+	for _, script := range prevOutScripts {
+		addItem(script)
+	}
+
+	return BuildFilter(BasicFilterP, BasicFilterM, key, items)
+}
+
+// BasicFilterFromBytes decodes a serialized BIP158 basic filter.
+func BasicFilterFromBytes(buf []byte) (*Filter, error) {
+	return FromBytes(BasicFilterP, BasicFilterM, buf)
+}
+
+// MatchBasicFilter returns true if the BIP158 basic filter of the block with the given header
+// likely contains any of the given scripts. A match means the block likely creates or spends
+// an output with one of the scripts, and should be downloaded.
+func MatchBasicFilter(filter *Filter, header *blockheader.BlockHeader, scripts [][]byte) (bool, error) {
+	key, err := BasicFilterKey(header)
+	if err != nil {
+		return false, err
+	}
+	return filter.MatchAny(key, scripts)
+}
+
+// Hash returns the double-SHA256 hash of the serialized filter, in RPC byte order.
+func (filter *Filter) Hash() [32]byte {
+	hash := bhash.DoubleSha256(filter.Bytes())
+	common.ReverseBytesInPlace(hash[:])
+	return hash
+}
+
+// FilterHeader returns the BIP157 filter header which commits to the filter with the given
+// hash, and the filter header of the previous block. The filter header of the genesis block
+// commits to a previous header of all zeros. All hashes are in RPC byte order.
+func FilterHeader(filterHash, prevHeader [32]byte) [32]byte {
+	preimage := make([]byte, 0, 64)
+	preimage = append(preimage, common.ReverseBytes(filterHash[:])...)
+	preimage = append(preimage, common.ReverseBytes(prevHeader[:])...)
+
+	header := bhash.DoubleSha256(preimage)
+	common.ReverseBytesInPlace(header[:])
+	return header
+}
@@ -0,0 +1,225 @@
+package gcs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/blocks"
+)
+
+// The testnet3 genesis block. It is identical to the mainnet genesis block, except for its time and nonce.
+const testnetGenesisBlockHex = "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4adae5494dffff001d1aa4ae180101000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+func TestBuildBasicFilter_Genesis(t *testing.T) {
+	// Test vector from BIP158 testnet-19.json.
+	const (
+		blockHashHex    = "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943"
+		filterHex       = "019dfca8"
+		filterHeaderHex = "21584579b7eb08997773e5aeff3a7f932700042d0ed2a6129012b7d7ae81b750"
+	)
+
+	block, err := blocks.FromReader(bytes.NewReader(hex2bytes(testnetGenesisBlockHex)))
+	if err != nil {
+		t.Errorf("failed to decode block: %s", err)
+		return
+	}
+
+	blockHash, err := block.Header.Hash()
+	if err != nil {
+		t.Errorf("failed to hash block header: %s", err)
+		return
+	} else if hex.EncodeToString(blockHash[:]) != blockHashHex {
+		t.Errorf("unexpected block hash: %x", blockHash)
+		return
+	}
+
+	filter, err := BuildBasicFilter(block, nil)
+	if err != nil {
+		t.Errorf("failed to build filter: %s", err)
+		return
+	}
+
+	if actual := hex.EncodeToString(filter.Bytes()); actual != filterHex {
+		t.Errorf("basic filter does not match\nWanted %s\nGot    %s", filterHex, actual)
+		return
+	}
+
+	header := FilterHeader(filter.Hash(), [32]byte{})
+	if actual := hex.EncodeToString(header[:]); actual != filterHeaderHex {
+		t.Errorf("filter header does not match\nWanted %s\nGot    %s", filterHeaderHex, actual)
+		return
+	}
+
+	coinbaseScript := block.Transactions[0].Outputs[0].Script
+	match, err := MatchBasicFilter(filter, block.Header, [][]byte{{0x51}, coinbaseScript})
+	if err != nil {
+		t.Errorf("failed to match filter: %s", err)
+		return
+	} else if !match {
+		t.Errorf("filter does not match coinbase output script")
+		return
+	}
+
+	match, err = MatchBasicFilter(filter, block.Header, [][]byte{{0x51}, {0x52}})
+	if err != nil {
+		t.Errorf("failed to match filter: %s", err)
+		return
+	} else if match {
+		t.Errorf("filter matched scripts which are not in the block")
+		return
+	}
+}
+
+// bip158Vector is a row of the BIP158 test vectors in testnet-19.json.
+type bip158Vector struct {
+	height         int
+	blockHash      string
+	block          *blocks.Block
+	prevOutScripts [][]byte
+	prevHeader     [32]byte
+	filter         string
+	header         string
+	notes          string
+}
+
+// loadBIP158Vectors reads the rows of testnet-19.json, which is copied verbatim from the
+// BIP158 repository. The first row, and any other row with a single entry, is a comment.
+func loadBIP158Vectors(t *testing.T) []*bip158Vector {
+	data, err := os.ReadFile("testnet-19.json")
+	if err != nil {
+		t.Fatalf("failed to read BIP158 test vectors: %s", err)
+	}
+
+	var rows [][]json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		t.Fatalf("failed to decode BIP158 test vectors: %s", err)
+	}
+
+	var vectors []*bip158Vector
+	for _, row := range rows {
+		if len(row) == 1 {
+			continue
+		} else if len(row) != 8 {
+			t.Fatalf("BIP158 test vector has %d fields, expected 8", len(row))
+		}
+
+		var (
+			vector                  = new(bip158Vector)
+			blockHex, prevHeaderHex string
+			prevOutScriptsHex       []string
+		)
+		fields := []interface{}{
+			&vector.height, &vector.blockHash, &blockHex, &prevOutScriptsHex,
+			&prevHeaderHex, &vector.filter, &vector.header, &vector.notes,
+		}
+		for i, field := range fields {
+			if err := json.Unmarshal(row[i], field); err != nil {
+				t.Fatalf("failed to decode BIP158 test vector field %d: %s", i, err)
+			}
+		}
+
+		vector.block, err = blocks.FromReader(bytes.NewReader(hex2bytes(blockHex)))
+		if err != nil {
+			t.Fatalf("failed to decode block at height %d: %s", vector.height, err)
+		}
+		for _, scriptHex := range prevOutScriptsHex {
+			vector.prevOutScripts = append(vector.prevOutScripts, hex2bytes(scriptHex))
+		}
+		copy(vector.prevHeader[:], hex2bytes(prevHeaderHex))
+
+		vectors = append(vectors, vector)
+	}
+	return vectors
+}
+
+func TestBuildBasicFilter_Vectors(t *testing.T) {
+	vectors := loadBIP158Vectors(t)
+	if len(vectors) == 0 {
+		t.Errorf("no BIP158 test vectors found")
+		return
+	}
+
+	for _, vector := range vectors {
+		blockHash, err := vector.block.Header.Hash()
+		if err != nil {
+			t.Errorf("block %d (%s): failed to hash block header: %s", vector.height, vector.notes, err)
+			continue
+		} else if actual := hex.EncodeToString(blockHash[:]); actual != vector.blockHash {
+			t.Errorf("block %d (%s): block hash does not match\nWanted %s\nGot    %s",
+				vector.height, vector.notes, vector.blockHash, actual)
+			continue
+		}
+
+		filter, err := BuildBasicFilter(vector.block, vector.prevOutScripts)
+		if err != nil {
+			t.Errorf("block %d (%s): failed to build filter: %s", vector.height, vector.notes, err)
+			continue
+		} else if actual := hex.EncodeToString(filter.Bytes()); actual != vector.filter {
+			t.Errorf("block %d (%s): basic filter does not match\nWanted %s\nGot    %s",
+				vector.height, vector.notes, vector.filter, actual)
+			continue
+		}
+
+		header := FilterHeader(filter.Hash(), vector.prevHeader)
+		if actual := hex.EncodeToString(header[:]); actual != vector.header {
+			t.Errorf("block %d (%s): filter header does not match\nWanted %s\nGot    %s",
+				vector.height, vector.notes, vector.header, actual)
+			continue
+		}
+
+		decoded, err := BasicFilterFromBytes(hex2bytes(vector.filter))
+		if err != nil {
+			t.Errorf("block %d (%s): failed to decode filter: %s", vector.height, vector.notes, err)
+			continue
+		} else if !bytes.Equal(decoded.Bytes(), filter.Bytes()) {
+			t.Errorf("block %d (%s): filter did not round trip", vector.height, vector.notes)
+			continue
+		}
+	}
+}
+
+func TestBuildBasicFilter_Items(t *testing.T) {
+	block, err := blocks.FromReader(bytes.NewReader(hex2bytes(testnetGenesisBlockHex)))
+	if err != nil {
+		t.Errorf("failed to decode block: %s", err)
+		return
+	}
+
+	coinbaseScript := block.Transactions[0].Outputs[0].Script
+	spentScript := hex2bytes("0014" + strings.Repeat("ab", 20))
+
+	// Duplicate and empty scripts are ignored, so these filters must be identical.
+	filter1, err := BuildBasicFilter(block, [][]byte{spentScript})
+	if err != nil {
+		t.Errorf("failed to build filter: %s", err)
+		return
+	}
+	filter2, err := BuildBasicFilter(block, [][]byte{spentScript, {}, coinbaseScript, spentScript})
+	if err != nil {
+		t.Errorf("failed to build filter: %s", err)
+		return
+	}
+
+	if filter1.N() != 2 {
+		t.Errorf("expected filter to have 2 items, got %d", filter1.N())
+		return
+	} else if !bytes.Equal(filter1.Bytes(), filter2.Bytes()) {
+		t.Errorf("duplicate and empty scripts changed the filter")
+		return
+	}
+
+	decoded, err := BasicFilterFromBytes(filter1.Bytes())
+	if err != nil {
+		t.Errorf("failed to decode filter: %s", err)
+		return
+	}
+
+	if match, err := MatchBasicFilter(decoded, block.Header, [][]byte{spentScript}); err != nil || !match {
+		t.Errorf("filter does not match spent script")
+		return
+	}
+}
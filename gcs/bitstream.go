@@ -0,0 +1,57 @@
+package gcs
+
+import "io"
+
+// bitWriter appends bits to a byte slice, most significant bit first.
+type bitWriter struct {
+	data      []byte
+	nBitsFree uint8
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if w.nBitsFree == 0 {
+		w.data = append(w.data, 0)
+		w.nBitsFree = 8
+	}
+	w.nBitsFree--
+	if bit {
+		w.data[len(w.data)-1] |= 1 << w.nBitsFree
+	}
+}
+
+// writeBits writes the lowest n bits of value, most significant first.
+func (w *bitWriter) writeBits(value uint64, n uint8) {
+	for i := int(n) - 1; i >= 0; i-- {
+		w.writeBit(value&(1<<uint(i)) != 0)
+	}
+}
+
+// bitReader reads bits from a byte slice, most significant bit first.
+type bitReader struct {
+	data   []byte
+	bitPos uint64
+}
+
+func (r *bitReader) readBit() (bool, error) {
+	if r.bitPos >= uint64(len(r.data))*8 {
+		return false, io.ErrUnexpectedEOF
+	}
+	bit := r.data[r.bitPos/8]&(0x80>>(r.bitPos%8)) != 0
+	r.bitPos++
+	return bit, nil
+}
+
+func (r *bitReader) readBits(n uint8) (uint64, error) {
+	var value uint64
+	for i := uint8(0); i < n; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		value <<= 1
+		if bit {
+			value |= 1
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,221 @@
+// Package gcs implements Golomb-Coded Sets, a compact probabilistic set data structure,
+// and the BIP158 basic block filters which use them.
+package gcs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/kklash/bitcoinlib/varint"
+)
+
+const (
+	// KeySize is the size of the SipHash key used to hash items into a filter.
+	KeySize = 16
+
+	// MaxP is the maximum Golomb-Rice parameter supported.
+	MaxP = 32
+)
+
+var (
+	// ErrInvalidP is returned when building or decoding a Filter with a P parameter greater than MaxP.
+	ErrInvalidP = errors.New("gcs filter P parameter is too large")
+
+	// ErrTooManyItems is returned by BuildFilter if given more than math.MaxUint32 items.
+	ErrTooManyItems = errors.New("too many items for gcs filter")
+
+	// ErrInvalidFormat is returned when decoding or matching against a Filter which is not encoded properly.
+	ErrInvalidFormat = errors.New("gcs filter is not formatted correctly")
+)
+
+// Filter is a Golomb-Coded Set: a sorted set of item hashes, compressed using Golomb-Rice
+// coding of the differences between consecutive hashes. Matching an item which is not in
+// the set returns a false positive with probability 1/M.
+type Filter struct {
+	n    uint32
+	p    uint8
+	m    uint64
+	data []byte
+}
+
+// BuildFilter constructs a Filter with Golomb-Rice parameter p and false positive rate 1/m,
+// containing the given items, which are hashed with SipHash using the given key. Duplicate
+// items should be removed by the caller; they are encoded as separate elements.
+func BuildFilter(p uint8, m uint64, key [KeySize]byte, items [][]byte) (*Filter, error) {
+	if p > MaxP {
+		return nil, ErrInvalidP
+	} else if uint64(len(items)) > math.MaxUint32 {
+		return nil, ErrTooManyItems
+	}
+
+	filter := &Filter{
+		n: uint32(len(items)),
+		p: p,
+		m: m,
+	}
+
+	values := make([]uint64, len(items))
+	for i, item := range items {
+		values[i] = filter.hashToRange(key, item)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	w := new(bitWriter)
+	var lastValue uint64
+	for _, value := range values {
+		delta := value - lastValue
+		lastValue = value
+
+		for q := delta >> p; q > 0; q-- {
+			w.writeBit(true)
+		}
+		w.writeBit(false)
+		w.writeBits(delta, p)
+	}
+	filter.data = w.data
+
+	return filter, nil
+}
+
+// FromBytes decodes a serialized Filter with the given parameters: the number of items
+// as a VarInt, followed by the Golomb-Rice coded set.
+func FromBytes(p uint8, m uint64, buf []byte) (*Filter, error) {
+	if p > MaxP {
+		return nil, ErrInvalidP
+	}
+
+	reader := bytes.NewReader(buf)
+	n, err := varint.FromReader(reader)
+	if err != nil || n > math.MaxUint32 {
+		return nil, ErrInvalidFormat
+	}
+
+	data := make([]byte, reader.Len())
+	copy(data, buf[len(buf)-reader.Len():])
+
+	return &Filter{
+		n:    uint32(n),
+		p:    p,
+		m:    m,
+		data: data,
+	}, nil
+}
+
+// N returns the number of items in the filter.
+func (filter *Filter) N() uint32 {
+	return filter.n
+}
+
+// P returns the Golomb-Rice parameter of the filter.
+func (filter *Filter) P() uint8 {
+	return filter.p
+}
+
+// M returns the inverse false positive rate of the filter.
+func (filter *Filter) M() uint64 {
+	return filter.m
+}
+
+// WriteTo implements the io.WriterTo interface. Writes the serialized Filter to the given io.Writer.
+func (filter *Filter) WriteTo(w io.Writer) (n int64, err error) {
+	n, err = varint.VarInt(filter.n).WriteTo(w)
+	if err != nil {
+		return
+	}
+
+	c, err := w.Write(filter.data)
+	n += int64(c)
+	return
+}
+
+// Bytes returns the serialized Filter as a byte-slice.
+func (filter *Filter) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	filter.WriteTo(buf)
+	return buf.Bytes()
+}
+
+// hashToRange hashes the item with SipHash and maps it uniformly to the range [0, N*M).
+func (filter *Filter) hashToRange(key [KeySize]byte, item []byte) uint64 {
+	k0 := binary.LittleEndian.Uint64(key[:8])
+	k1 := binary.LittleEndian.Uint64(key[8:])
+	hi, _ := bits.Mul64(sipHash24(k0, k1, item), uint64(filter.n)*filter.m)
+	return hi
+}
+
+// values returns a function which decodes each successive value from the filter.
+func (filter *Filter) values() func() (uint64, error) {
+	r := &bitReader{data: filter.data}
+	var lastValue uint64
+
+	return func() (uint64, error) {
+		var q uint64
+		for {
+			bit, err := r.readBit()
+			if err != nil {
+				return 0, fmt.Errorf("%w: %s", ErrInvalidFormat, err)
+			} else if !bit {
+				break
+			}
+			q++
+		}
+
+		remainder, err := r.readBits(filter.p)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s", ErrInvalidFormat, err)
+		}
+
+		lastValue += (q << filter.p) + remainder
+		return lastValue, nil
+	}
+}
+
+// Match returns true if the item is likely in the filter, which was built with the given key.
+// It returns an error wrapping ErrInvalidFormat if the filter data is truncated.
+func (filter *Filter) Match(key [KeySize]byte, item []byte) (bool, error) {
+	return filter.MatchAny(key, [][]byte{item})
+}
+
+// MatchAny returns true if any of the items are likely in the filter, which was built with the
+// given key. It returns an error wrapping ErrInvalidFormat if the filter data is truncated.
+func (filter *Filter) MatchAny(key [KeySize]byte, items [][]byte) (bool, error) {
+	if filter.n == 0 || len(items) == 0 {
+		return false, nil
+	}
+
+	queries := make([]uint64, len(items))
+	for i, item := range items {
+		queries[i] = filter.hashToRange(key, item)
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i] < queries[j] })
+
+	next := filter.values()
+	value, err := next()
+	if err != nil {
+		return false, err
+	}
+
+	decoded := uint32(1)
+	for _, query := range queries {
+		for value < query {
+			if decoded == filter.n {
+				return false, nil
+			}
+			if value, err = next(); err != nil {
+				return false, err
+			}
+			decoded++
+		}
+		if value == query {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,160 @@
+package gcs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+func hex2bytes(h string) []byte {
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestSipHash24(t *testing.T) {
+	// Test vectors from the SipHash paper's reference implementation.
+	k0, k1 := uint64(0x0706050403020100), uint64(0x0f0e0d0c0b0a0908)
+	if hash := sipHash24(k0, k1, nil); hash != 0x726fdb47dd0e0e31 {
+		t.Errorf("unexpected SipHash of empty message: %x", hash)
+		return
+	}
+	if hash := sipHash24(k0, k1, hex2bytes("000102030405060708090a0b0c0d0e")); hash != 0xa129ca6149be45e5 {
+		t.Errorf("unexpected SipHash of 15 byte message: %x", hash)
+		return
+	}
+}
+
+func TestFilter(t *testing.T) {
+	type Fixture struct {
+		P              uint8    `json:"p"`
+		M              uint64   `json:"m"`
+		Key            string   `json:"key"`
+		Items          []string `json:"items"`
+		Filter         string   `json:"filter"`
+		NonItems       []string `json:"non_items"`
+		NonItemMatches []bool   `json:"non_item_matches"`
+	}
+
+	data, err := os.ReadFile("fixtures.json")
+	if err != nil {
+		t.Errorf("failed to read fixtures: %s", err)
+		return
+	}
+
+	var fixtures []Fixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Errorf("failed to decode fixtures: %s", err)
+		return
+	}
+
+	for _, fixture := range fixtures {
+		var key [KeySize]byte
+		copy(key[:], hex2bytes(fixture.Key))
+
+		items := make([][]byte, len(fixture.Items))
+		for i, item := range fixture.Items {
+			items[i] = hex2bytes(item)
+		}
+
+		filter, err := BuildFilter(fixture.P, fixture.M, key, items)
+		if err != nil {
+			t.Errorf("failed to build filter: %s", err)
+			continue
+		}
+
+		if actual := hex.EncodeToString(filter.Bytes()); actual != fixture.Filter {
+			t.Errorf("filter does not match\nWanted %s\nGot    %s", fixture.Filter, actual)
+			continue
+		}
+
+		decoded, err := FromBytes(fixture.P, fixture.M, hex2bytes(fixture.Filter))
+		if err != nil {
+			t.Errorf("failed to decode filter: %s", err)
+			continue
+		} else if decoded.N() != uint32(len(items)) {
+			t.Errorf("decoded filter has wrong N: %d", decoded.N())
+			continue
+		}
+
+		for _, item := range items {
+			if match, err := decoded.Match(key, item); err != nil {
+				t.Errorf("failed to match filter: %s", err)
+				break
+			} else if !match {
+				t.Errorf("filter does not match item %x", item)
+				break
+			}
+		}
+
+		for i, item := range fixture.NonItems {
+			if match, err := decoded.Match(key, hex2bytes(item)); err != nil {
+				t.Errorf("failed to match filter: %s", err)
+				break
+			} else if match != fixture.NonItemMatches[i] {
+				t.Errorf("unexpected match result for non-item %s: %v", item, match)
+				break
+			}
+		}
+
+		nonItems := make([][]byte, len(fixture.NonItems))
+		for i, item := range fixture.NonItems {
+			nonItems[i] = hex2bytes(item)
+		}
+		if match, err := decoded.MatchAny(key, append(nonItems, items[len(items)-1])); err != nil {
+			t.Errorf("failed to match filter: %s", err)
+			continue
+		} else if !match {
+			t.Errorf("MatchAny did not match set containing an item")
+			continue
+		}
+	}
+}
+
+func TestFilter_Empty(t *testing.T) {
+	filter, err := BuildFilter(BasicFilterP, BasicFilterM, [KeySize]byte{}, nil)
+	if err != nil {
+		t.Errorf("failed to build empty filter: %s", err)
+		return
+	}
+
+	if !bytes.Equal(filter.Bytes(), []byte{0}) {
+		t.Errorf("unexpected empty filter encoding: %x", filter.Bytes())
+		return
+	}
+
+	if match, err := filter.Match([KeySize]byte{}, []byte("anything")); err != nil || match {
+		t.Errorf("empty filter should not match anything")
+		return
+	}
+}
+
+func TestFilter_Invalid(t *testing.T) {
+	if _, err := BuildFilter(MaxP+1, 1, [KeySize]byte{}, nil); !errors.Is(err, ErrInvalidP) {
+		t.Errorf("expected ErrInvalidP, got %v", err)
+		return
+	}
+
+	if _, err := FromBytes(BasicFilterP, BasicFilterM, nil); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("expected ErrInvalidFormat, got %v", err)
+		return
+	}
+
+	// Claims 3 items, but only encodes data for one.
+	filter, err := FromBytes(BasicFilterP, BasicFilterM, hex2bytes("039dfca8"))
+	if err != nil {
+		t.Errorf("failed to decode filter: %s", err)
+		return
+	}
+
+	queries := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}
+	if _, err := filter.MatchAny([KeySize]byte{}, queries); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("expected ErrInvalidFormat, got %v", err)
+		return
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/kklash/bitcoinlib/gcs
+
+go 1.18
@@ -0,0 +1,325 @@
+package interpreter
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/der"
+	"github.com/kklash/bitcoinlib/ecc"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+// validationWeightPerSigOp is the amount of tapscript validation weight budget consumed by each
+// signature check with a non-empty signature, as specified in BIP342.
+const validationWeightPerSigOp = 50
+
+// Prefixes of hybrid public keys with even and odd Y coordinates.
+const (
+	publicKeyHybridEvenPrefix = 0x06
+	publicKeyHybridOddPrefix  = 0x07
+)
+
+// checkSig verifies a signature for OP_CHECKSIG, OP_CHECKSIGVERIFY and OP_CHECKSIGADD. It returns
+// false with no error if the signature is invalid but the script may continue executing.
+func (e *engine) checkSig(signature, publicKey, scriptCode []byte, sigVersion sigVersion) (bool, error) {
+	if sigVersion == sigVersionTapscript {
+		return e.checkSchnorrSig(signature, publicKey)
+	}
+
+	if err := e.checkSignatureEncoding(signature); err != nil {
+		return false, err
+	} else if err := e.checkPublicKeyEncoding(publicKey, sigVersion); err != nil {
+		return false, err
+	}
+
+	if sigVersion == sigVersionBase {
+		scriptCode = findAndDelete(scriptCode, signature)
+	}
+
+	ok := e.verifyECDSA(signature, publicKey, scriptCode, sigVersion)
+	if !ok && e.flags&FlagNullFail != 0 && len(signature) > 0 {
+		return false, ErrNullFail
+	}
+
+	return ok, nil
+}
+
+// checkMultiSig pops the operands of OP_CHECKMULTISIG from the stack and verifies the signatures
+// in order against the public keys. Returns the number of public keys, which count towards the
+// operation limit.
+func (e *engine) checkMultiSig(st *stack, scriptCode []byte, sigVersion sigVersion) (bool, int, error) {
+	requireMinimal := e.flags&FlagMinimalData != 0
+
+	if len(*st) < 1 {
+		return false, 0, ErrInvalidStackOperation
+	}
+	nKeys, err := decodeScriptNumber(st.pop(), 4, requireMinimal)
+	if err != nil {
+		return false, 0, err
+	} else if nKeys < 0 || nKeys > maxPubKeysPerMultiSig {
+		return false, 0, ErrPublicKeyCount
+	}
+
+	if int64(len(*st)) < nKeys {
+		return false, 0, ErrInvalidStackOperation
+	}
+	publicKeys := make([][]byte, nKeys)
+	for i := range publicKeys {
+		publicKeys[i] = st.pop()
+	}
+
+	if len(*st) < 1 {
+		return false, 0, ErrInvalidStackOperation
+	}
+	nSigs, err := decodeScriptNumber(st.pop(), 4, requireMinimal)
+	if err != nil {
+		return false, 0, err
+	} else if nSigs < 0 || nSigs > nKeys {
+		return false, 0, ErrSignatureCount
+	}
+
+	if int64(len(*st)) < nSigs {
+		return false, 0, ErrInvalidStackOperation
+	}
+	signatures := make([][]byte, nSigs)
+	for i := range signatures {
+		signatures[i] = st.pop()
+	}
+
+	// An extra element is consumed because of a bug in the original implementation.
+	if len(*st) < 1 {
+		return false, 0, ErrInvalidStackOperation
+	}
+	dummy := st.pop()
+	if e.flags&FlagNullDummy != 0 && len(dummy) != 0 {
+		return false, 0, ErrNullDummy
+	}
+
+	if sigVersion == sigVersionBase {
+		for _, signature := range signatures {
+			scriptCode = findAndDelete(scriptCode, signature)
+		}
+	}
+
+	// Signatures and public keys were popped from the top of the stack, so they are
+	// already in the order in which they must be checked.
+	ok := true
+	remainingSignatures := signatures
+	for len(remainingSignatures) > 0 {
+		if len(remainingSignatures) > len(publicKeys) {
+			ok = false
+			break
+		}
+
+		signature, publicKey := remainingSignatures[0], publicKeys[0]
+		if err := e.checkSignatureEncoding(signature); err != nil {
+			return false, 0, err
+		} else if err := e.checkPublicKeyEncoding(publicKey, sigVersion); err != nil {
+			return false, 0, err
+		}
+
+		if e.verifyECDSA(signature, publicKey, scriptCode, sigVersion) {
+			remainingSignatures = remainingSignatures[1:]
+		}
+		publicKeys = publicKeys[1:]
+	}
+
+	if !ok && e.flags&FlagNullFail != 0 {
+		for _, signature := range signatures {
+			if len(signature) > 0 {
+				return false, 0, ErrNullFail
+			}
+		}
+	}
+
+	return ok, int(nKeys), nil
+}
+
+// checkSignatureEncoding validates an ECDSA signature's encoding according to the engine's flags.
+// Empty signatures are always allowed, as a compact way to provide an invalid signature.
+func (e *engine) checkSignatureEncoding(signature []byte) error {
+	if len(signature) == 0 {
+		return nil
+	}
+
+	if e.flags&(FlagDERSignatures|FlagLowS|FlagStrictEncoding) != 0 {
+		_, s, _, err := der.DecodeSignature(signature)
+		if err != nil {
+			return ErrSignatureEncoding
+		}
+
+		if e.flags&FlagLowS != 0 && !der.IsLowS(s) {
+			return ErrSignatureHighS
+		}
+	}
+
+	if e.flags&FlagStrictEncoding != 0 {
+		sigHashType := uint32(signature[len(signature)-1]) &^ constants.SigHashAnyoneCanPay
+		if sigHashType < constants.SigHashAll || sigHashType > constants.SigHashSingle {
+			return ErrSignatureEncoding
+		}
+	}
+
+	return nil
+}
+
+// checkPublicKeyEncoding validates an ECDSA public key's encoding according to the engine's flags.
+func (e *engine) checkPublicKeyEncoding(publicKey []byte, sigVersion sigVersion) error {
+	if e.flags&FlagStrictEncoding != 0 && !isCompressedOrUncompressedPublicKey(publicKey) {
+		return ErrPublicKeyType
+	}
+
+	if e.flags&FlagWitnessPubKeyType != 0 && sigVersion == sigVersionWitnessV0 &&
+		!ecc.IsCompressedPublicKey(publicKey) {
+		return ErrWitnessPublicKeyType
+	}
+
+	return nil
+}
+
+func isCompressedOrUncompressedPublicKey(publicKey []byte) bool {
+	return ecc.IsCompressedPublicKey(publicKey) ||
+		(len(publicKey) == constants.PublicKeyUncompressedLength &&
+			publicKey[0] == constants.PublicKeyUncompressedPrefix)
+}
+
+// unhybridPublicKey converts a hybrid public key, an uncompressed key whose prefix also encodes
+// the parity of its Y coordinate, to the ordinary uncompressed encoding. Hybrid keys are valid in
+// signatures unless FlagStrictEncoding is set. Other keys are returned unchanged.
+func unhybridPublicKey(publicKey []byte) []byte {
+	if len(publicKey) != constants.PublicKeyUncompressedLength ||
+		(publicKey[0] != publicKeyHybridEvenPrefix && publicKey[0] != publicKeyHybridOddPrefix) {
+		return publicKey
+	}
+
+	// The prefix must agree with the parity of the Y coordinate.
+	if publicKey[0]&1 != publicKey[len(publicKey)-1]&1 {
+		return publicKey
+	}
+
+	uncompressed := append([]byte{constants.PublicKeyUncompressedPrefix}, publicKey[1:]...)
+	return uncompressed
+}
+
+// verifyECDSA returns true if signature is a valid signature by publicKey on the legacy
+// or segwit v0 signature hash of the transaction input. Signatures are parsed with the lax
+// DER rules which were used before BIP66, since strict encoding is enforced separately.
+func (e *engine) verifyECDSA(signature, publicKey, scriptCode []byte, sigVersion sigVersion) bool {
+	publicKey = unhybridPublicKey(publicKey)
+	if len(signature) == 0 || !isCompressedOrUncompressedPublicKey(publicKey) {
+		return false
+	}
+
+	r, s, sigHashType, err := der.DecodeSignatureLax(signature)
+	if err != nil {
+		return false
+	}
+
+	var sigHash [32]byte
+	if sigVersion == sigVersionWitnessV0 {
+		sigHash, err = e.tx.SignatureHashForWitnessInput(e.nInput, scriptCode, sigHashType, e.prevOuts[e.nInput].Value)
+	} else {
+		sigHash, err = e.tx.SignatureHashForInput(e.nInput, scriptCode, sigHashType)
+	}
+	if err != nil {
+		return false
+	}
+
+	return ecc.VerifyECDSA(publicKey, sigHash[:], r, s)
+}
+
+// checkSchnorrSig implements signature checking for tapscript as specified in BIP342.
+func (e *engine) checkSchnorrSig(signature, publicKey []byte) (bool, error) {
+	if len(signature) > 0 {
+		e.validationWeightLeft -= validationWeightPerSigOp
+		if e.validationWeightLeft < 0 {
+			return false, ErrTapscriptValidationWeight
+		}
+	}
+
+	if len(publicKey) == 0 {
+		return false, ErrPublicKeyType
+	} else if len(publicKey) != constants.PublicKeySchnorrLength {
+		// Unknown public key types are reserved for future soft forks, and always succeed.
+		return len(signature) > 0, nil
+	}
+
+	if len(signature) == 0 {
+		return false, nil
+	}
+
+	if err := e.verifySchnorr(signature, publicKey, true); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// verifySchnorr verifies a BIP340 signature by publicKey on the taproot signature hash of the
+// transaction input. If scriptPath is true, the tapscript signature hash is used.
+func (e *engine) verifySchnorr(signature, publicKey []byte, scriptPath bool) error {
+	sigHashType := constants.SigHashDefault
+	switch len(signature) {
+	case 64:
+	case 65:
+		sigHashType = uint32(signature[64])
+		if sigHashType == constants.SigHashDefault {
+			return ErrSchnorrSigHashType
+		}
+		signature = signature[:64]
+	default:
+		return ErrSchnorrSignatureSize
+	}
+
+	var (
+		sigHash [32]byte
+		err     error
+	)
+	if scriptPath {
+		sigHash, err = e.tx.SignatureHashForTapscriptInput(
+			e.nInput, e.prevOuts, sigHashType, e.annex, e.tapLeafHash, e.codeSeparatorPosition,
+		)
+	} else {
+		sigHash, err = e.tx.SignatureHashForTaprootInput(e.nInput, e.prevOuts, sigHashType, e.annex)
+	}
+	if errors.Is(err, tx.ErrInvalidTaprootSigHash) {
+		return ErrSchnorrSigHashType
+	} else if err != nil {
+		return err
+	}
+
+	if !ecc.VerifySchnorr(publicKey, sigHash[:], signature) {
+		return ErrSchnorrSignature
+	}
+
+	return nil
+}
+
+// findAndDelete removes every push of the given data from scriptCode, as done by legacy
+// signature hashing. Only pushes which exactly match the canonical push encoding of data are
+// removed.
+func findAndDelete(scriptCode, data []byte) []byte {
+	if len(data) == 0 {
+		return scriptCode
+	}
+
+	pushed := script.PushData(data)
+	result := make([]byte, 0, len(scriptCode))
+	for pc := 0; pc < len(scriptCode); {
+		_, _, next, err := parseOp(scriptCode, pc)
+		if err != nil {
+			// Keep the remaining unparseable bytes intact.
+			result = append(result, scriptCode[pc:]...)
+			break
+		}
+
+		if !bytes.Equal(scriptCode[pc:next], pushed) {
+			result = append(result, scriptCode[pc:next]...)
+		}
+		pc = next
+	}
+
+	return result
+}
@@ -0,0 +1,731 @@
+package interpreter
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/kklash/bitcoinlib/bhash"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+// sigVersion determines which signature hashing and verification rules apply to a script.
+type sigVersion int
+
+const (
+	sigVersionBase sigVersion = iota
+	sigVersionWitnessV0
+	sigVersionTapscript
+)
+
+const (
+	// lockTimeThreshold is the locktime value below which locktimes are interpreted as
+	// block heights, and above which they are interpreted as unix timestamps.
+	lockTimeThreshold = 500000000
+
+	sequenceFinal               = 0xffffffff
+	sequenceLockTimeDisableFlag = 1 << 31
+	sequenceLockTimeTypeFlag    = 1 << 22
+	sequenceLockTimeMask        = 0x0000ffff
+)
+
+// engine holds the transaction context needed to verify a single input.
+type engine struct {
+	tx       *tx.Tx
+	nInput   int
+	prevOuts []*tx.Output
+	flags    Flags
+
+	// Taproot script-path execution context.
+	annex                 []byte
+	tapLeafHash           [32]byte
+	codeSeparatorPosition uint32
+	validationWeightLeft  int64
+}
+
+// parseOp reads the opcode at position pc of the given script, and the data it pushes if
+// it is a push opcode. Returns the position of the next opcode. Returns ErrMalformedScript
+// if the script ends before a push opcode's data.
+func parseOp(scriptBytes []byte, pc int) (op byte, data []byte, next int, err error) {
+	op = scriptBytes[pc]
+	pc++
+
+	if op > constants.OP_PUSHDATA4 {
+		return op, nil, pc, nil
+	}
+
+	var size int
+	switch op {
+	case constants.OP_PUSHDATA1:
+		if pc+1 > len(scriptBytes) {
+			return 0, nil, 0, ErrMalformedScript
+		}
+		size = int(scriptBytes[pc])
+		pc++
+	case constants.OP_PUSHDATA2:
+		if pc+2 > len(scriptBytes) {
+			return 0, nil, 0, ErrMalformedScript
+		}
+		size = int(binary.LittleEndian.Uint16(scriptBytes[pc:]))
+		pc += 2
+	case constants.OP_PUSHDATA4:
+		if pc+4 > len(scriptBytes) {
+			return 0, nil, 0, ErrMalformedScript
+		}
+		size = int(binary.LittleEndian.Uint32(scriptBytes[pc:]))
+		pc += 4
+	default:
+		size = int(op)
+	}
+
+	if size < 0 || pc+size > len(scriptBytes) {
+		return 0, nil, 0, ErrMalformedScript
+	}
+
+	return op, scriptBytes[pc : pc+size], pc + size, nil
+}
+
+// isMinimalPush returns true if the given push opcode is the smallest
+// possible way to push data onto the stack.
+func isMinimalPush(op byte, data []byte) bool {
+	switch {
+	case len(data) == 0:
+		return op == constants.OP_0
+	case len(data) == 1 && data[0] >= 1 && data[0] <= 16:
+		return false // should have used OP_1 - OP_16
+	case len(data) == 1 && data[0] == 0x81:
+		return false // should have used OP_1NEGATE
+	case len(data) <= constants.OP_DATA_75:
+		return int(op) == len(data)
+	case len(data) <= 0xff:
+		return op == constants.OP_PUSHDATA1
+	case len(data) <= 0xffff:
+		return op == constants.OP_PUSHDATA2
+	}
+	return true
+}
+
+// isDisabledOpcode returns true for opcodes which cause a script to fail
+// even when they are not executed.
+func isDisabledOpcode(op byte) bool {
+	switch op {
+	case constants.OP_CAT, constants.OP_SUBSTR, constants.OP_LEFT, constants.OP_RIGHT,
+		constants.OP_INVERT, constants.OP_AND, constants.OP_OR, constants.OP_XOR,
+		constants.OP_2MUL, constants.OP_2DIV, constants.OP_MUL, constants.OP_DIV,
+		constants.OP_MOD, constants.OP_LSHIFT, constants.OP_RSHIFT:
+		return true
+	}
+	return false
+}
+
+// isOpSuccess returns true for the opcodes which BIP342 redefines as OP_SUCCESSx in tapscript.
+func isOpSuccess(op byte) bool {
+	return op == 80 || op == 98 ||
+		(op >= 126 && op <= 129) ||
+		(op >= 131 && op <= 134) ||
+		(op >= 137 && op <= 138) ||
+		(op >= 141 && op <= 142) ||
+		(op >= 149 && op <= 153) ||
+		(op >= 187 && op <= 254)
+}
+
+func allTrue(conditions []bool) bool {
+	for _, c := range conditions {
+		if !c {
+			return false
+		}
+	}
+	return true
+}
+
+// evalScript executes the given script using st as the initial stack. When evalScript
+// returns, st holds the final state of the stack.
+func (e *engine) evalScript(st *stack, scriptBytes []byte, sigVersion sigVersion) error {
+	if sigVersion != sigVersionTapscript && len(scriptBytes) > maxScriptSize {
+		return ErrScriptSize
+	}
+
+	var (
+		altStack   stack
+		conditions []bool
+		opCount    int
+
+		// Byte offset in scriptBytes after the last executed OP_CODESEPARATOR.
+		codeSeparatorOffset int
+
+		// Index of the current opcode, used for BIP342 codesep_pos.
+		opIndex uint32
+	)
+
+	e.codeSeparatorPosition = 0xffffffff
+
+	requireMinimal := e.flags&FlagMinimalData != 0
+
+	for pc := 0; pc < len(scriptBytes); opIndex++ {
+		op, data, next, err := parseOp(scriptBytes, pc)
+		if err != nil {
+			return err
+		}
+		pc = next
+
+		executing := allTrue(conditions)
+
+		if len(data) > maxScriptElementSize {
+			return ErrPushSize
+		}
+
+		if sigVersion != sigVersionTapscript && op > constants.OP_16 {
+			opCount++
+			if opCount > maxOpsPerScript {
+				return ErrOpCount
+			}
+		}
+
+		if isDisabledOpcode(op) {
+			return ErrDisabledOpcode
+		}
+
+		if op <= constants.OP_PUSHDATA4 {
+			if executing {
+				if requireMinimal && !isMinimalPush(op, data) {
+					return ErrMinimalData
+				}
+				st.push(append([]byte{}, data...))
+			}
+		} else if executing || (op >= constants.OP_IF && op <= constants.OP_ENDIF) {
+			switch op {
+			case constants.OP_1NEGATE:
+				st.pushNumber(-1)
+
+			case constants.OP_1, constants.OP_2, constants.OP_3, constants.OP_4,
+				constants.OP_5, constants.OP_6, constants.OP_7, constants.OP_8,
+				constants.OP_9, constants.OP_10, constants.OP_11, constants.OP_12,
+				constants.OP_13, constants.OP_14, constants.OP_15, constants.OP_16:
+				st.pushNumber(int64(op-constants.OP_1) + 1)
+
+			case constants.OP_NOP, constants.OP_NOP1, constants.OP_NOP4, constants.OP_NOP5,
+				constants.OP_NOP6, constants.OP_NOP7, constants.OP_NOP8, constants.OP_NOP9,
+				constants.OP_NOP10:
+				// Do nothing.
+
+			case constants.OP_CHECKLOCKTIMEVERIFY:
+				if e.flags&FlagCheckLockTimeVerify != 0 {
+					if err := e.checkLockTimeVerify(*st); err != nil {
+						return err
+					}
+				}
+
+			case constants.OP_CHECKSEQUENCEVERIFY:
+				if e.flags&FlagCheckSequenceVerify != 0 {
+					if err := e.checkSequenceVerify(*st); err != nil {
+						return err
+					}
+				}
+
+			case constants.OP_IF, constants.OP_NOTIF:
+				value := false
+				if executing {
+					if len(*st) < 1 {
+						return ErrUnbalancedConditional
+					}
+					item := st.pop()
+					if sigVersion == sigVersionTapscript ||
+						(sigVersion == sigVersionWitnessV0 && e.flags&FlagMinimalIf != 0) {
+						if len(item) > 1 || (len(item) == 1 && item[0] != 1) {
+							return ErrMinimalIf
+						}
+					}
+					value = castToBool(item)
+					if op == constants.OP_NOTIF {
+						value = !value
+					}
+				}
+				conditions = append(conditions, value)
+
+			case constants.OP_ELSE:
+				if len(conditions) == 0 {
+					return ErrUnbalancedConditional
+				}
+				conditions[len(conditions)-1] = !conditions[len(conditions)-1]
+
+			case constants.OP_ENDIF:
+				if len(conditions) == 0 {
+					return ErrUnbalancedConditional
+				}
+				conditions = conditions[:len(conditions)-1]
+
+			case constants.OP_VERIFY:
+				if len(*st) < 1 {
+					return ErrInvalidStackOperation
+				}
+				if !castToBool(st.pop()) {
+					return ErrVerify
+				}
+
+			case constants.OP_RETURN:
+				return ErrOpReturn
+
+			case constants.OP_CODESEPARATOR:
+				codeSeparatorOffset = pc
+				e.codeSeparatorPosition = opIndex
+
+			case constants.OP_CHECKSIG, constants.OP_CHECKSIGVERIFY:
+				if len(*st) < 2 {
+					return ErrInvalidStackOperation
+				}
+				publicKey := st.pop()
+				signature := st.pop()
+				ok, err := e.checkSig(signature, publicKey, scriptBytes[codeSeparatorOffset:], sigVersion)
+				if err != nil {
+					return err
+				}
+				if op == constants.OP_CHECKSIGVERIFY {
+					if !ok {
+						return ErrVerify
+					}
+				} else {
+					st.pushBool(ok)
+				}
+
+			case constants.OP_CHECKSIGADD:
+				if sigVersion != sigVersionTapscript {
+					return ErrBadOpcode
+				}
+				if len(*st) < 3 {
+					return ErrInvalidStackOperation
+				}
+				publicKey := st.pop()
+				n, err := decodeScriptNumber(st.pop(), 4, requireMinimal)
+				if err != nil {
+					return err
+				}
+				signature := st.pop()
+				ok, err := e.checkSig(signature, publicKey, nil, sigVersion)
+				if err != nil {
+					return err
+				}
+				if ok {
+					n++
+				}
+				st.pushNumber(n)
+
+			case constants.OP_CHECKMULTISIG, constants.OP_CHECKMULTISIGVERIFY:
+				if sigVersion == sigVersionTapscript {
+					return ErrTapscriptCheckMultiSig
+				}
+				ok, nKeys, err := e.checkMultiSig(st, scriptBytes[codeSeparatorOffset:], sigVersion)
+				if err != nil {
+					return err
+				}
+				opCount += nKeys
+				if opCount > maxOpsPerScript {
+					return ErrOpCount
+				}
+				if op == constants.OP_CHECKMULTISIGVERIFY {
+					if !ok {
+						return ErrVerify
+					}
+				} else {
+					st.pushBool(ok)
+				}
+
+			default:
+				if err := e.execStackOp(op, st, &altStack, requireMinimal); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(*st)+len(altStack) > maxStackSize {
+			return ErrStackSize
+		}
+	}
+
+	if len(conditions) != 0 {
+		return ErrUnbalancedConditional
+	}
+
+	return nil
+}
+
+// execStackOp executes opcodes which only manipulate the stack, including arithmetic and hashing.
+func (e *engine) execStackOp(op byte, st, altStack *stack, requireMinimal bool) error {
+	need := func(n int) error {
+		if len(*st) < n {
+			return ErrInvalidStackOperation
+		}
+		return nil
+	}
+
+	popNumber := func() (int64, error) {
+		return decodeScriptNumber(st.pop(), 4, requireMinimal)
+	}
+
+	switch op {
+	case constants.OP_TOALTSTACK:
+		if err := need(1); err != nil {
+			return err
+		}
+		altStack.push(st.pop())
+
+	case constants.OP_FROMALTSTACK:
+		if len(*altStack) < 1 {
+			return ErrInvalidAltStackOperation
+		}
+		st.push(altStack.pop())
+
+	case constants.OP_2DROP:
+		if err := need(2); err != nil {
+			return err
+		}
+		st.pop()
+		st.pop()
+
+	case constants.OP_2DUP:
+		if err := need(2); err != nil {
+			return err
+		}
+		a, b := st.peek(1), st.peek(0)
+		st.push(a)
+		st.push(b)
+
+	case constants.OP_3DUP:
+		if err := need(3); err != nil {
+			return err
+		}
+		a, b, c := st.peek(2), st.peek(1), st.peek(0)
+		st.push(a)
+		st.push(b)
+		st.push(c)
+
+	case constants.OP_2OVER:
+		if err := need(4); err != nil {
+			return err
+		}
+		a, b := st.peek(3), st.peek(2)
+		st.push(a)
+		st.push(b)
+
+	case constants.OP_2ROT:
+		if err := need(6); err != nil {
+			return err
+		}
+		a := st.remove(5)
+		b := st.remove(4)
+		st.push(a)
+		st.push(b)
+
+	case constants.OP_2SWAP:
+		if err := need(4); err != nil {
+			return err
+		}
+		a := st.remove(3)
+		b := st.remove(2)
+		st.push(a)
+		st.push(b)
+
+	case constants.OP_IFDUP:
+		if err := need(1); err != nil {
+			return err
+		}
+		if castToBool(st.top()) {
+			st.push(st.top())
+		}
+
+	case constants.OP_DEPTH:
+		st.pushNumber(int64(len(*st)))
+
+	case constants.OP_DROP:
+		if err := need(1); err != nil {
+			return err
+		}
+		st.pop()
+
+	case constants.OP_DUP:
+		if err := need(1); err != nil {
+			return err
+		}
+		st.push(st.top())
+
+	case constants.OP_NIP:
+		if err := need(2); err != nil {
+			return err
+		}
+		st.remove(1)
+
+	case constants.OP_OVER:
+		if err := need(2); err != nil {
+			return err
+		}
+		st.push(st.peek(1))
+
+	case constants.OP_PICK, constants.OP_ROLL:
+		if err := need(2); err != nil {
+			return err
+		}
+		n, err := popNumber()
+		if err != nil {
+			return err
+		} else if n < 0 || n >= int64(len(*st)) {
+			return ErrInvalidStackOperation
+		}
+		if op == constants.OP_PICK {
+			st.push(st.peek(int(n)))
+		} else {
+			st.push(st.remove(int(n)))
+		}
+
+	case constants.OP_ROT:
+		if err := need(3); err != nil {
+			return err
+		}
+		st.push(st.remove(2))
+
+	case constants.OP_SWAP:
+		if err := need(2); err != nil {
+			return err
+		}
+		st.push(st.remove(1))
+
+	case constants.OP_TUCK:
+		if err := need(2); err != nil {
+			return err
+		}
+		top := st.pop()
+		second := st.pop()
+		st.push(top)
+		st.push(second)
+		st.push(top)
+
+	case constants.OP_SIZE:
+		if err := need(1); err != nil {
+			return err
+		}
+		st.pushNumber(int64(len(st.top())))
+
+	case constants.OP_EQUAL, constants.OP_EQUALVERIFY:
+		if err := need(2); err != nil {
+			return err
+		}
+		equal := bytes.Equal(st.pop(), st.pop())
+		if op == constants.OP_EQUALVERIFY {
+			if !equal {
+				return ErrVerify
+			}
+		} else {
+			st.pushBool(equal)
+		}
+
+	case constants.OP_1ADD, constants.OP_1SUB, constants.OP_NEGATE, constants.OP_ABS,
+		constants.OP_NOT, constants.OP_0NOTEQUAL:
+		if err := need(1); err != nil {
+			return err
+		}
+		n, err := popNumber()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case constants.OP_1ADD:
+			n++
+		case constants.OP_1SUB:
+			n--
+		case constants.OP_NEGATE:
+			n = -n
+		case constants.OP_ABS:
+			if n < 0 {
+				n = -n
+			}
+		case constants.OP_NOT:
+			n = boolToNumber(n == 0)
+		case constants.OP_0NOTEQUAL:
+			n = boolToNumber(n != 0)
+		}
+		st.pushNumber(n)
+
+	case constants.OP_ADD, constants.OP_SUB, constants.OP_BOOLAND, constants.OP_BOOLOR,
+		constants.OP_NUMEQUAL, constants.OP_NUMEQUALVERIFY, constants.OP_NUMNOTEQUAL,
+		constants.OP_LESSTHAN, constants.OP_GREATERTHAN, constants.OP_LESSTHANOREQUAL,
+		constants.OP_GREATERTHANOREQUAL, constants.OP_MIN, constants.OP_MAX:
+		if err := need(2); err != nil {
+			return err
+		}
+		b, err := popNumber()
+		if err != nil {
+			return err
+		}
+		a, err := popNumber()
+		if err != nil {
+			return err
+		}
+
+		var n int64
+		switch op {
+		case constants.OP_ADD:
+			n = a + b
+		case constants.OP_SUB:
+			n = a - b
+		case constants.OP_BOOLAND:
+			n = boolToNumber(a != 0 && b != 0)
+		case constants.OP_BOOLOR:
+			n = boolToNumber(a != 0 || b != 0)
+		case constants.OP_NUMEQUAL, constants.OP_NUMEQUALVERIFY:
+			n = boolToNumber(a == b)
+		case constants.OP_NUMNOTEQUAL:
+			n = boolToNumber(a != b)
+		case constants.OP_LESSTHAN:
+			n = boolToNumber(a < b)
+		case constants.OP_GREATERTHAN:
+			n = boolToNumber(a > b)
+		case constants.OP_LESSTHANOREQUAL:
+			n = boolToNumber(a <= b)
+		case constants.OP_GREATERTHANOREQUAL:
+			n = boolToNumber(a >= b)
+		case constants.OP_MIN:
+			n = a
+			if b < a {
+				n = b
+			}
+		case constants.OP_MAX:
+			n = a
+			if b > a {
+				n = b
+			}
+		}
+
+		if op == constants.OP_NUMEQUALVERIFY {
+			if n == 0 {
+				return ErrVerify
+			}
+		} else {
+			st.pushNumber(n)
+		}
+
+	case constants.OP_WITHIN:
+		if err := need(3); err != nil {
+			return err
+		}
+		max, err := popNumber()
+		if err != nil {
+			return err
+		}
+		min, err := popNumber()
+		if err != nil {
+			return err
+		}
+		x, err := popNumber()
+		if err != nil {
+			return err
+		}
+		st.pushBool(min <= x && x < max)
+
+	case constants.OP_RIPEMD160, constants.OP_SHA1, constants.OP_SHA256,
+		constants.OP_HASH160, constants.OP_HASH256:
+		if err := need(1); err != nil {
+			return err
+		}
+		item := st.pop()
+		var hashed []byte
+		switch op {
+		case constants.OP_RIPEMD160:
+			h := bhash.Ripemd160(item)
+			hashed = h[:]
+		case constants.OP_SHA1:
+			h := sha1.Sum(item)
+			hashed = h[:]
+		case constants.OP_SHA256:
+			h := sha256.Sum256(item)
+			hashed = h[:]
+		case constants.OP_HASH160:
+			h := bhash.Hash160(item)
+			hashed = h[:]
+		case constants.OP_HASH256:
+			h := bhash.DoubleSha256(item)
+			hashed = h[:]
+		}
+		st.push(hashed)
+
+	default:
+		return ErrBadOpcode
+	}
+
+	return nil
+}
+
+func boolToNumber(v bool) int64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// checkLockTimeVerify implements OP_CHECKLOCKTIMEVERIFY as specified in BIP65.
+func (e *engine) checkLockTimeVerify(st stack) error {
+	if len(st) < 1 {
+		return ErrInvalidStackOperation
+	}
+
+	// Locktimes may be up to 5 bytes long, to allow timestamps beyond 2038.
+	lockTime, err := decodeScriptNumber(st.top(), 5, e.flags&FlagMinimalData != 0)
+	if err != nil {
+		return err
+	} else if lockTime < 0 {
+		return ErrNegativeLockTime
+	}
+
+	txLockTime := int64(e.tx.Locktime)
+	if (txLockTime < lockTimeThreshold) != (lockTime < lockTimeThreshold) {
+		return ErrUnsatisfiedLockTime
+	} else if lockTime > txLockTime {
+		return ErrUnsatisfiedLockTime
+	}
+
+	// A final sequence number disables the transaction's locktime.
+	if e.tx.Inputs[e.nInput].Sequence == sequenceFinal {
+		return ErrUnsatisfiedLockTime
+	}
+
+	return nil
+}
+
+// checkSequenceVerify implements OP_CHECKSEQUENCEVERIFY as specified in BIP112.
+func (e *engine) checkSequenceVerify(st stack) error {
+	if len(st) < 1 {
+		return ErrInvalidStackOperation
+	}
+
+	sequence, err := decodeScriptNumber(st.top(), 5, e.flags&FlagMinimalData != 0)
+	if err != nil {
+		return err
+	} else if sequence < 0 {
+		return ErrNegativeLockTime
+	}
+
+	// If the disable flag is set, OP_CHECKSEQUENCEVERIFY behaves as a NOP.
+	if sequence&sequenceLockTimeDisableFlag != 0 {
+		return nil
+	}
+
+	if e.tx.Version < 2 {
+		return ErrUnsatisfiedLockTime
+	}
+
+	txSequence := int64(e.tx.Inputs[e.nInput].Sequence)
+	if txSequence&sequenceLockTimeDisableFlag != 0 {
+		return ErrUnsatisfiedLockTime
+	}
+
+	mask := int64(sequenceLockTimeTypeFlag | sequenceLockTimeMask)
+	sequence &= mask
+	txSequence &= mask
+
+	if (txSequence < sequenceLockTimeTypeFlag) != (sequence < sequenceLockTimeTypeFlag) {
+		return ErrUnsatisfiedLockTime
+	} else if sequence > txSequence {
+		return ErrUnsatisfiedLockTime
+	}
+
+	return nil
+}
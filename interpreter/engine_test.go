@@ -0,0 +1,262 @@
+package interpreter
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+// assemble concatenates opcodes and data pushes into a script. Byte slices
+// are pushed as data, and integers are appended as raw opcodes.
+func assemble(items ...interface{}) []byte {
+	buf := new(bytes.Buffer)
+	for _, item := range items {
+		switch v := item.(type) {
+		case int:
+			buf.WriteByte(byte(v))
+		case []byte:
+			buf.Write(script.PushData(v))
+		default:
+			panic("unexpected script item type")
+		}
+	}
+	return buf.Bytes()
+}
+
+// spendingTx creates a transaction with a single input which spends an
+// output with the given script, and returns it along with its prevOuts.
+func spendingTx(scriptSig, scriptPubKey []byte, witness tx.Witness) (*tx.Tx, []*tx.Output) {
+	txn := &tx.Tx{
+		Version: 2,
+		Inputs: []*tx.Input{
+			{
+				PrevOut:  &tx.PrevOut{Index: 0},
+				Script:   scriptSig,
+				Sequence: 0xfffffffe,
+			},
+		},
+		Outputs: []*tx.Output{
+			{Script: []byte{constants.OP_RETURN}},
+		},
+		Locktime: 600000,
+	}
+	if witness != nil {
+		txn.Witnesses = []tx.Witness{witness}
+	}
+
+	prevOuts := []*tx.Output{
+		{Script: scriptPubKey, Value: 100000},
+	}
+	return txn, prevOuts
+}
+
+func TestEvalScript(t *testing.T) {
+	fixtures := []struct {
+		name         string
+		scriptSig    []byte
+		scriptPubKey []byte
+		flags        Flags
+		err          error
+	}{
+		{
+			name:         "OP_TRUE",
+			scriptSig:    []byte{},
+			scriptPubKey: assemble(constants.OP_1),
+			flags:        StandardFlags,
+		},
+		{
+			name:         "arithmetic",
+			scriptSig:    assemble(constants.OP_2, constants.OP_3),
+			scriptPubKey: assemble(constants.OP_ADD, constants.OP_5, constants.OP_EQUAL),
+			flags:        StandardFlags,
+		},
+		{
+			name:         "negative arithmetic",
+			scriptSig:    assemble(constants.OP_1NEGATE, constants.OP_16),
+			scriptPubKey: assemble(constants.OP_SUB, []byte{0x91}, constants.OP_NUMEQUAL),
+			flags:        StandardFlags,
+		},
+		{
+			name:         "stack manipulation",
+			scriptSig:    assemble(constants.OP_1, constants.OP_2, constants.OP_3),
+			scriptPubKey: assemble(constants.OP_ROT, constants.OP_1, constants.OP_EQUALVERIFY, constants.OP_2DROP, constants.OP_1),
+			flags:        StandardFlags,
+		},
+		{
+			name:         "conditional",
+			scriptSig:    assemble(constants.OP_0),
+			scriptPubKey: assemble(constants.OP_IF, constants.OP_RETURN, constants.OP_ELSE, constants.OP_1, constants.OP_ENDIF),
+			flags:        StandardFlags,
+		},
+		{
+			name:         "sha256",
+			scriptSig:    assemble([]byte("abc")),
+			scriptPubKey: assemble(constants.OP_SHA256, hex2bytes("ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"), constants.OP_EQUAL),
+			flags:        StandardFlags,
+		},
+		{
+			name:         "checklocktimeverify satisfied",
+			scriptSig:    []byte{},
+			scriptPubKey: assemble(encodeScriptNumber(500000), constants.OP_CHECKLOCKTIMEVERIFY),
+			flags:        ConsensusFlags,
+		},
+		{
+			name:         "checklocktimeverify unsatisfied",
+			scriptSig:    []byte{},
+			scriptPubKey: assemble(encodeScriptNumber(700000), constants.OP_CHECKLOCKTIMEVERIFY),
+			flags:        ConsensusFlags,
+			err:          ErrUnsatisfiedLockTime,
+		},
+		{
+			name:         "false result",
+			scriptSig:    []byte{},
+			scriptPubKey: assemble(constants.OP_0),
+			flags:        StandardFlags,
+			err:          ErrEvalFalse,
+		},
+		{
+			name:         "OP_RETURN",
+			scriptSig:    []byte{},
+			scriptPubKey: assemble(constants.OP_1, constants.OP_RETURN),
+			flags:        StandardFlags,
+			err:          ErrOpReturn,
+		},
+		{
+			name:         "OP_VERIFY",
+			scriptSig:    []byte{},
+			scriptPubKey: assemble(constants.OP_0, constants.OP_VERIFY, constants.OP_1),
+			flags:        StandardFlags,
+			err:          ErrVerify,
+		},
+		{
+			name:         "unbalanced conditional",
+			scriptSig:    []byte{},
+			scriptPubKey: assemble(constants.OP_1, constants.OP_IF, constants.OP_1),
+			flags:        StandardFlags,
+			err:          ErrUnbalancedConditional,
+		},
+		{
+			name:         "disabled opcode in unexecuted branch",
+			scriptSig:    []byte{},
+			scriptPubKey: assemble(constants.OP_0, constants.OP_IF, constants.OP_CAT, constants.OP_ENDIF, constants.OP_1),
+			flags:        StandardFlags,
+			err:          ErrDisabledOpcode,
+		},
+		{
+			name:         "invalid stack operation",
+			scriptSig:    []byte{},
+			scriptPubKey: assemble(constants.OP_DROP, constants.OP_1),
+			flags:        StandardFlags,
+			err:          ErrInvalidStackOperation,
+		},
+		{
+			name:         "malformed push",
+			scriptSig:    []byte{},
+			scriptPubKey: []byte{constants.OP_1, constants.OP_DATA_4, 0x01},
+			flags:        StandardFlags,
+			err:          ErrMalformedScript,
+		},
+		{
+			name:         "non-minimal push",
+			scriptSig:    []byte{constants.OP_PUSHDATA1, 0x01, 0x01},
+			scriptPubKey: assemble(constants.OP_1),
+			flags:        StandardFlags,
+			err:          ErrMinimalData,
+		},
+		{
+			name:         "non-minimal push without flag",
+			scriptSig:    []byte{constants.OP_PUSHDATA1, 0x01, 0x01},
+			scriptPubKey: assemble(constants.OP_1),
+			flags:        ConsensusFlags,
+		},
+		{
+			name:         "non push-only scriptSig",
+			scriptSig:    assemble(constants.OP_1, constants.OP_DUP),
+			scriptPubKey: assemble(constants.OP_EQUAL),
+			flags:        StandardFlags,
+			err:          ErrSigPushOnly,
+		},
+		{
+			name:         "cleanstack",
+			scriptSig:    assemble(constants.OP_1, constants.OP_1),
+			scriptPubKey: assemble(constants.OP_1),
+			flags:        StandardFlags,
+			err:          ErrCleanStack,
+		},
+		{
+			name:         "P2SH redeem script",
+			scriptSig:    assemble(constants.OP_2, assemble(constants.OP_2, constants.OP_EQUAL)),
+			scriptPubKey: script.MakeP2SHFromScript(assemble(constants.OP_2, constants.OP_EQUAL)),
+			flags:        StandardFlags,
+		},
+		{
+			name:         "P2SH redeem script evaluates false",
+			scriptSig:    assemble(constants.OP_3, assemble(constants.OP_2, constants.OP_EQUAL)),
+			scriptPubKey: script.MakeP2SHFromScript(assemble(constants.OP_2, constants.OP_EQUAL)),
+			flags:        StandardFlags,
+			err:          ErrEvalFalse,
+		},
+	}
+
+	for _, fixture := range fixtures {
+		txn, prevOuts := spendingTx(fixture.scriptSig, fixture.scriptPubKey, nil)
+		err := VerifyInput(txn, 0, prevOuts, fixture.flags)
+
+		if fixture.err == nil && err != nil {
+			t.Errorf("%s: unexpected error: %s", fixture.name, err)
+			continue
+		} else if fixture.err != nil && !errors.Is(err, fixture.err) {
+			t.Errorf("%s: expected error %q, got %v", fixture.name, fixture.err, err)
+			continue
+		}
+	}
+}
+
+func TestScriptNumber(t *testing.T) {
+	fixtures := []struct {
+		n       int64
+		encoded []byte
+	}{
+		{0, []byte{}},
+		{1, []byte{0x01}},
+		{-1, []byte{0x81}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x00}},
+		{-128, []byte{0x80, 0x80}},
+		{255, []byte{0xff, 0x00}},
+		{256, []byte{0x00, 0x01}},
+		{-32768, []byte{0x00, 0x80, 0x80}},
+		{2147483647, []byte{0xff, 0xff, 0xff, 0x7f}},
+	}
+
+	for _, fixture := range fixtures {
+		encoded := encodeScriptNumber(fixture.n)
+		if !bytes.Equal(encoded, fixture.encoded) {
+			t.Errorf("failed to encode script number %d\nWanted %x\nGot    %x", fixture.n, fixture.encoded, encoded)
+			continue
+		}
+
+		decoded, err := decodeScriptNumber(encoded, 4, true)
+		if err != nil {
+			t.Errorf("failed to decode script number %x: %s", encoded, err)
+			continue
+		} else if decoded != fixture.n {
+			t.Errorf("decoded script number does not match\nWanted %d\nGot    %d", fixture.n, decoded)
+			continue
+		}
+	}
+
+	if _, err := decodeScriptNumber([]byte{0x01, 0x00}, 4, true); err == nil {
+		t.Errorf("expected error decoding non-minimal script number")
+		return
+	}
+
+	if _, err := decodeScriptNumber([]byte{0x01, 0x02, 0x03, 0x04, 0x05}, 4, false); err == nil {
+		t.Errorf("expected error decoding oversized script number")
+		return
+	}
+}
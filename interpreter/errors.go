@@ -0,0 +1,144 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrScriptFailed is the base error returned when an input fails script verification.
+	// All other script verification errors wrap ErrScriptFailed.
+	ErrScriptFailed = errors.New("script verification failed")
+
+	// ErrEvalFalse is returned when a script finishes executing with an empty
+	// stack, or with a false value on top of the stack.
+	ErrEvalFalse = fmt.Errorf("%w: script evaluated without error but finished with a false/empty top stack element", ErrScriptFailed)
+
+	// ErrMalformedScript is returned when a script contains a push opcode which
+	// claims more data than remains in the script.
+	ErrMalformedScript = fmt.Errorf("%w: malformed push opcode", ErrScriptFailed)
+
+	// ErrScriptSize is returned when a script exceeds the maximum script size.
+	ErrScriptSize = fmt.Errorf("%w: script is too large", ErrScriptFailed)
+
+	// ErrPushSize is returned when a script or witness pushes an element larger than 520 bytes.
+	ErrPushSize = fmt.Errorf("%w: push value size limit exceeded", ErrScriptFailed)
+
+	// ErrOpCount is returned when a script executes more than 201 non-push opcodes.
+	ErrOpCount = fmt.Errorf("%w: operation limit exceeded", ErrScriptFailed)
+
+	// ErrStackSize is returned when the combined size of the stack and alt-stack exceeds 1000 elements.
+	ErrStackSize = fmt.Errorf("%w: stack size limit exceeded", ErrScriptFailed)
+
+	// ErrBadOpcode is returned when a script executes an unknown or reserved opcode.
+	ErrBadOpcode = fmt.Errorf("%w: opcode missing or not understood", ErrScriptFailed)
+
+	// ErrDisabledOpcode is returned when a script contains a disabled opcode, such as OP_CAT.
+	ErrDisabledOpcode = fmt.Errorf("%w: attempted to use a disabled opcode", ErrScriptFailed)
+
+	// ErrInvalidStackOperation is returned when an opcode needs more stack elements than are available.
+	ErrInvalidStackOperation = fmt.Errorf("%w: operation not valid with the current stack size", ErrScriptFailed)
+
+	// ErrInvalidAltStackOperation is returned when OP_FROMALTSTACK is executed with an empty alt-stack.
+	ErrInvalidAltStackOperation = fmt.Errorf("%w: operation not valid with the current altstack size", ErrScriptFailed)
+
+	// ErrUnbalancedConditional is returned when OP_IF/OP_NOTIF/OP_ELSE/OP_ENDIF are not balanced.
+	ErrUnbalancedConditional = fmt.Errorf("%w: invalid OP_IF construction", ErrScriptFailed)
+
+	// ErrOpReturn is returned when a script executes OP_RETURN.
+	ErrOpReturn = fmt.Errorf("%w: OP_RETURN was encountered", ErrScriptFailed)
+
+	// ErrVerify is returned when OP_VERIFY, or any opcode with an implicit OP_VERIFY, fails.
+	ErrVerify = fmt.Errorf("%w: script failed an OP_VERIFY operation", ErrScriptFailed)
+
+	// ErrInvalidNumber is returned when a stack element used as a number is too
+	// large, or is not minimally encoded when FlagMinimalData is set.
+	ErrInvalidNumber = fmt.Errorf("%w: invalid script number", ErrScriptFailed)
+
+	// ErrMinimalData is returned when FlagMinimalData is set and data is not pushed minimally.
+	ErrMinimalData = fmt.Errorf("%w: data push larger than necessary", ErrScriptFailed)
+
+	// ErrMinimalIf is returned when the argument to OP_IF or OP_NOTIF is not exactly empty or 0x01,
+	// in contexts where MINIMALIF is required.
+	ErrMinimalIf = fmt.Errorf("%w: OP_IF/NOTIF argument must be minimal", ErrScriptFailed)
+
+	// ErrNegativeLockTime is returned when OP_CHECKLOCKTIMEVERIFY or
+	// OP_CHECKSEQUENCEVERIFY are used with a negative operand.
+	ErrNegativeLockTime = fmt.Errorf("%w: negative locktime", ErrScriptFailed)
+
+	// ErrUnsatisfiedLockTime is returned when the spending transaction's locktime or
+	// sequence does not satisfy OP_CHECKLOCKTIMEVERIFY or OP_CHECKSEQUENCEVERIFY.
+	ErrUnsatisfiedLockTime = fmt.Errorf("%w: locktime requirement not satisfied", ErrScriptFailed)
+
+	// ErrSignatureEncoding is returned when a signature is not correctly encoded, or
+	// has an undefined sighash type when FlagStrictEncoding is set.
+	ErrSignatureEncoding = fmt.Errorf("%w: non-canonical signature", ErrScriptFailed)
+
+	// ErrSignatureHighS is returned when FlagLowS is set and an ECDSA signature has an S value above N/2.
+	ErrSignatureHighS = fmt.Errorf("%w: non-canonical signature: S value is unnecessarily high", ErrScriptFailed)
+
+	// ErrPublicKeyType is returned when a public key is not encoded in an acceptable format.
+	ErrPublicKeyType = fmt.Errorf("%w: public key is neither compressed nor uncompressed", ErrScriptFailed)
+
+	// ErrWitnessPublicKeyType is returned when FlagWitnessPubKeyType is set and a
+	// segwit v0 script uses an uncompressed public key.
+	ErrWitnessPublicKeyType = fmt.Errorf("%w: using non-compressed keys in segwit", ErrScriptFailed)
+
+	// ErrNullFail is returned when FlagNullFail is set and a failing signature check was given a non-empty signature.
+	ErrNullFail = fmt.Errorf("%w: signature must be zero for failed CHECK(MULTI)SIG operation", ErrScriptFailed)
+
+	// ErrNullDummy is returned when FlagNullDummy is set and the OP_CHECKMULTISIG dummy element is not empty.
+	ErrNullDummy = fmt.Errorf("%w: dummy CHECKMULTISIG argument must be zero", ErrScriptFailed)
+
+	// ErrPublicKeyCount is returned when OP_CHECKMULTISIG is used with a public key count out of range.
+	ErrPublicKeyCount = fmt.Errorf("%w: public key count out of range", ErrScriptFailed)
+
+	// ErrSignatureCount is returned when OP_CHECKMULTISIG is used with a signature count out of range.
+	ErrSignatureCount = fmt.Errorf("%w: signature count out of range", ErrScriptFailed)
+
+	// ErrSigPushOnly is returned when an input script contains non-push opcodes, in contexts where this is not allowed.
+	ErrSigPushOnly = fmt.Errorf("%w: only push operators allowed in signatures", ErrScriptFailed)
+
+	// ErrCleanStack is returned when FlagCleanStack is set and a script leaves more than one element on the stack.
+	ErrCleanStack = fmt.Errorf("%w: stack size must be exactly one after execution", ErrScriptFailed)
+
+	// ErrWitnessProgramWrongLength is returned when a segwit v0 witness program is neither 20 nor 32 bytes.
+	ErrWitnessProgramWrongLength = fmt.Errorf("%w: witness program has incorrect length", ErrScriptFailed)
+
+	// ErrWitnessProgramWitnessEmpty is returned when spending a witness program with an empty witness.
+	ErrWitnessProgramWitnessEmpty = fmt.Errorf("%w: witness program was passed an empty witness", ErrScriptFailed)
+
+	// ErrWitnessProgramMismatch is returned when the witness data does not match the witness program.
+	ErrWitnessProgramMismatch = fmt.Errorf("%w: witness program hash mismatch", ErrScriptFailed)
+
+	// ErrWitnessMalleated is returned when a native witness program is spent with a non-empty input script,
+	// or when a P2SH-wrapped witness program is spent with an input script other than a single push.
+	ErrWitnessMalleated = fmt.Errorf("%w: witness requires empty or canonical scriptSig", ErrScriptFailed)
+
+	// ErrWitnessUnexpected is returned when an input which is not a witness program has witness data.
+	ErrWitnessUnexpected = fmt.Errorf("%w: witness provided for non-witness script", ErrScriptFailed)
+
+	// ErrDiscourageUpgradableWitnessProgram is returned when FlagDiscourageUpgradableWitnessProgram
+	// is set and an input spends a witness program, taproot leaf version, or OP_SUCCESS opcode
+	// which is reserved for future soft forks.
+	ErrDiscourageUpgradableWitnessProgram = fmt.Errorf("%w: witness version reserved for soft-fork upgrades", ErrScriptFailed)
+
+	// ErrSchnorrSignature is returned when a schnorr signature fails verification.
+	ErrSchnorrSignature = fmt.Errorf("%w: invalid schnorr signature", ErrScriptFailed)
+
+	// ErrSchnorrSignatureSize is returned when a schnorr signature is neither 64 nor 65 bytes long.
+	ErrSchnorrSignatureSize = fmt.Errorf("%w: invalid schnorr signature size", ErrScriptFailed)
+
+	// ErrSchnorrSigHashType is returned when a schnorr signature has an invalid sighash type.
+	ErrSchnorrSigHashType = fmt.Errorf("%w: invalid schnorr signature hash type", ErrScriptFailed)
+
+	// ErrTaprootControlBlockSize is returned when a taproot control block has an invalid size.
+	ErrTaprootControlBlockSize = fmt.Errorf("%w: invalid taproot control block size", ErrScriptFailed)
+
+	// ErrTapscriptValidationWeight is returned when a tapscript executes too many signature checks
+	// for the size of its witness.
+	ErrTapscriptValidationWeight = fmt.Errorf("%w: too much signature validation relative to witness weight", ErrScriptFailed)
+
+	// ErrTapscriptCheckMultiSig is returned when a tapscript uses OP_CHECKMULTISIG(VERIFY).
+	ErrTapscriptCheckMultiSig = fmt.Errorf("%w: OP_CHECKMULTISIG(VERIFY) is not available in tapscript", ErrScriptFailed)
+)
@@ -0,0 +1,3 @@
+module github.com/kklash/bitcoinlib/interpreter
+
+go 1.18
@@ -0,0 +1,265 @@
+// Package interpreter provides a Bitcoin script interpreter, which can verify that transaction
+// inputs correctly spend the outputs they reference. Legacy, P2SH, segwit v0 (BIP141/BIP143)
+// and taproot (BIP341/BIP342) spending rules are supported.
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+// Flags is a bit field which enables script verification rules. Rules which were introduced
+// by soft forks must be enabled to be enforced, as in Bitcoin Core.
+type Flags uint32
+
+const (
+	// FlagP2SH enables evaluation of BIP16 P2SH redeem scripts.
+	FlagP2SH Flags = 1 << iota
+
+	// FlagStrictEncoding requires public keys to be compressed or uncompressed
+	// SEC1 encodings, and signatures to use a defined sighash type.
+	FlagStrictEncoding
+
+	// FlagDERSignatures requires ECDSA signatures to be strict-DER encoded as specified in BIP66.
+	FlagDERSignatures
+
+	// FlagLowS requires ECDSA signatures to have an S value less than or equal to N/2.
+	FlagLowS
+
+	// FlagNullDummy requires the extra stack element consumed by OP_CHECKMULTISIG to be empty (BIP147).
+	FlagNullDummy
+
+	// FlagSigPushOnly requires input scripts to contain only push opcodes.
+	FlagSigPushOnly
+
+	// FlagMinimalData requires data pushes and script numbers to be minimally encoded.
+	FlagMinimalData
+
+	// FlagCleanStack requires exactly one element to remain on the stack after
+	// execution. Only allowed in combination with FlagP2SH and FlagWitness.
+	FlagCleanStack
+
+	// FlagCheckLockTimeVerify enables OP_CHECKLOCKTIMEVERIFY (BIP65).
+	FlagCheckLockTimeVerify
+
+	// FlagCheckSequenceVerify enables OP_CHECKSEQUENCEVERIFY (BIP112).
+	FlagCheckSequenceVerify
+
+	// FlagWitness enables segregated witness verification (BIP141).
+	FlagWitness
+
+	// FlagDiscourageUpgradableWitnessProgram causes inputs spending witness programs of unknown
+	// versions, unknown taproot leaf versions, or tapscripts with OP_SUCCESS opcodes to fail.
+	FlagDiscourageUpgradableWitnessProgram
+
+	// FlagMinimalIf requires the argument of OP_IF and OP_NOTIF to be exactly empty or 0x01 in
+	// segwit v0 scripts. This rule is always enforced in tapscript.
+	FlagMinimalIf
+
+	// FlagNullFail requires signatures to be empty when a signature check fails.
+	FlagNullFail
+
+	// FlagWitnessPubKeyType requires public keys in segwit v0 scripts to be compressed.
+	FlagWitnessPubKeyType
+
+	// FlagTaproot enables taproot verification (BIP341 and BIP342).
+	FlagTaproot
+)
+
+const (
+	// ConsensusFlags are the verification flags which all transactions mined
+	// on the Bitcoin network must satisfy, as of the taproot soft fork.
+	ConsensusFlags = FlagP2SH |
+		FlagDERSignatures |
+		FlagNullDummy |
+		FlagCheckLockTimeVerify |
+		FlagCheckSequenceVerify |
+		FlagWitness |
+		FlagTaproot
+
+	// StandardFlags are the verification flags which Bitcoin Core enforces
+	// on transactions before relaying them or including them in the mempool.
+	StandardFlags = ConsensusFlags |
+		FlagStrictEncoding |
+		FlagLowS |
+		FlagSigPushOnly |
+		FlagMinimalData |
+		FlagCleanStack |
+		FlagDiscourageUpgradableWitnessProgram |
+		FlagMinimalIf |
+		FlagNullFail |
+		FlagWitnessPubKeyType
+)
+
+const (
+	maxScriptSize         = 10000
+	maxScriptElementSize  = 520
+	maxOpsPerScript       = 201
+	maxStackSize          = 1000
+	maxPubKeysPerMultiSig = 20
+)
+
+// ErrInvalidInput is returned by VerifyInput when the input index is out of range,
+// or when the given prevOuts do not match the transaction's inputs.
+var ErrInvalidInput = errors.New("cannot verify input")
+
+// VerifyInput verifies that input nInput of txn correctly spends the output it references,
+// under the rules enabled by flags. prevOuts must contain the outputs spent by every input
+// of txn, in the same order as txn.Inputs. All previous outputs are needed to compute
+// taproot signature hashes.
+//
+// Returns an error wrapping ErrScriptFailed if the input does not satisfy its previous output
+// script, or an error wrapping ErrInvalidInput if the input cannot be verified at all.
+func VerifyInput(txn *tx.Tx, nInput int, prevOuts []*tx.Output, flags Flags) error {
+	if nInput < 0 || nInput >= len(txn.Inputs) {
+		return fmt.Errorf("%w: input index %d out of range", ErrInvalidInput, nInput)
+	} else if len(prevOuts) != len(txn.Inputs) {
+		return fmt.Errorf("%w: expected %d prevouts, got %d", ErrInvalidInput, len(txn.Inputs), len(prevOuts))
+	} else if prevOuts[nInput] == nil {
+		return fmt.Errorf("%w: missing prevout for input %d", ErrInvalidInput, nInput)
+	}
+
+	var witness tx.Witness
+	if nInput < len(txn.Witnesses) {
+		witness = txn.Witnesses[nInput]
+	}
+
+	e := &engine{
+		tx:       txn,
+		nInput:   nInput,
+		prevOuts: prevOuts,
+		flags:    flags,
+	}
+
+	return e.verify(txn.Inputs[nInput].Script, prevOuts[nInput].Script, witness)
+}
+
+// VerifyTx verifies every input of txn using VerifyInput. prevOuts must contain the
+// outputs spent by every input of txn, in the same order as txn.Inputs.
+func VerifyTx(txn *tx.Tx, prevOuts []*tx.Output, flags Flags) error {
+	for i := range txn.Inputs {
+		if err := VerifyInput(txn, i, prevOuts, flags); err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// verify runs the full verification procedure for one input, mirroring VerifyScript in Bitcoin Core.
+func (e *engine) verify(scriptSig, scriptPubKey []byte, witness tx.Witness) error {
+	if e.flags&FlagSigPushOnly != 0 && !isPushOnly(scriptSig) {
+		return ErrSigPushOnly
+	}
+
+	var st stack
+	if err := e.evalScript(&st, scriptSig, sigVersionBase); err != nil {
+		return err
+	}
+
+	var stackCopy stack
+	if e.flags&FlagP2SH != 0 {
+		stackCopy = st.clone()
+	}
+
+	if err := e.evalScript(&st, scriptPubKey, sigVersionBase); err != nil {
+		return err
+	} else if len(st) == 0 || !castToBool(st.top()) {
+		return ErrEvalFalse
+	}
+
+	hadWitness := false
+
+	if e.flags&FlagWitness != 0 {
+		if version, program, ok := parseWitnessProgram(scriptPubKey); ok {
+			hadWitness = true
+			if len(scriptSig) != 0 {
+				return ErrWitnessMalleated
+			}
+			if err := e.verifyWitnessProgram(witness, version, program, false); err != nil {
+				return err
+			}
+			// Bypass the cleanstack check at the end.
+			st = st[:1]
+		}
+	}
+
+	if e.flags&FlagP2SH != 0 && script.IsP2SH(scriptPubKey) {
+		if !isPushOnly(scriptSig) {
+			return ErrSigPushOnly
+		}
+
+		st = stackCopy
+		if len(st) == 0 {
+			return ErrEvalFalse
+		}
+
+		redeemScript := st.pop()
+		if err := e.evalScript(&st, redeemScript, sigVersionBase); err != nil {
+			return err
+		} else if len(st) == 0 || !castToBool(st.top()) {
+			return ErrEvalFalse
+		}
+
+		if e.flags&FlagWitness != 0 {
+			if version, program, ok := parseWitnessProgram(redeemScript); ok {
+				hadWitness = true
+				if string(scriptSig) != string(script.PushData(redeemScript)) {
+					return ErrWitnessMalleated
+				}
+				if err := e.verifyWitnessProgram(witness, version, program, true); err != nil {
+					return err
+				}
+				st = st[:1]
+			}
+		}
+	}
+
+	if e.flags&FlagCleanStack != 0 && len(st) != 1 {
+		return ErrCleanStack
+	}
+
+	if e.flags&FlagWitness != 0 && !hadWitness && len(witness) > 0 {
+		return ErrWitnessUnexpected
+	}
+
+	return nil
+}
+
+// isPushOnly returns true if the given script contains only push opcodes.
+// OP_RESERVED is counted as a push opcode, as in Bitcoin Core.
+func isPushOnly(scriptBytes []byte) bool {
+	for pc := 0; pc < len(scriptBytes); {
+		op, _, next, err := parseOp(scriptBytes, pc)
+		if err != nil || op > constants.OP_16 {
+			return false
+		}
+		pc = next
+	}
+	return true
+}
+
+// parseWitnessProgram returns the version and program of a witness program
+// output script. Returns false if the script is not a witness program.
+func parseWitnessProgram(scriptPubKey []byte) (version int, program []byte, ok bool) {
+	if len(scriptPubKey) < 4 || len(scriptPubKey) > 42 {
+		return
+	}
+
+	op := scriptPubKey[0]
+	if op != constants.OP_0 && (op < constants.OP_1 || op > constants.OP_16) {
+		return
+	}
+
+	if int(scriptPubKey[1])+2 != len(scriptPubKey) {
+		return
+	}
+
+	if op != constants.OP_0 {
+		version = int(op-constants.OP_1) + 1
+	}
+	return version, scriptPubKey[2:], true
+}
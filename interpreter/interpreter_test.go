@@ -0,0 +1,381 @@
+package interpreter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/bhash"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/der"
+	"github.com/kklash/bitcoinlib/ecc"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/signer"
+	"github.com/kklash/bitcoinlib/taproot"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+func hex2bytes(h string) []byte {
+	d, _ := hex.DecodeString(h)
+	return d
+}
+
+var (
+	testPrivateKey1 = hex2bytes("52193c7c8a290a1b93fb140bf3f011ccfc39db77234d9aa7fde059cf011005e9")
+	testPrivateKey2 = hex2bytes("0000000000000000000000000000000000000000000000000000000000000003")
+)
+
+func TestVerifyInput_Signed(t *testing.T) {
+	publicKey := ecc.GetPublicKeyCompressed(testPrivateKey1)
+	publicKeyUncompressed := ecc.GetPublicKeyUncompressed(testPrivateKey1)
+	publicKeySchnorr := ecc.GetPublicKeySchnorr(testPrivateKey1)
+	publicKeyHash := bhash.Hash160(publicKey)
+
+	p2tr, _ := script.MakeP2TR(publicKeySchnorr, nil)
+
+	type Fixture struct {
+		name         string
+		scriptPubKey []byte
+		sign         func(txn *tx.Tx, prevOuts []*tx.Output) error
+	}
+
+	fixtures := []Fixture{
+		{
+			name:         "P2PKH",
+			scriptPubKey: script.MakeP2PKHFromHash(publicKeyHash),
+			sign: func(txn *tx.Tx, prevOuts []*tx.Output) error {
+				return signer.SignInputP2PKH(txn, 0, testPrivateKey1, constants.SigHashAll)
+			},
+		},
+		{
+			name:         "P2PKH uncompressed",
+			scriptPubKey: script.MakeP2PKHFromHash(bhash.Hash160(publicKeyUncompressed)),
+			sign: func(txn *tx.Tx, prevOuts []*tx.Output) error {
+				return signer.SignInputP2PKHUncompressed(txn, 0, testPrivateKey1, constants.SigHashAll)
+			},
+		},
+		{
+			name:         "P2WPKH",
+			scriptPubKey: script.MakeP2WPKHFromHash(publicKeyHash),
+			sign: func(txn *tx.Tx, prevOuts []*tx.Output) error {
+				return signer.SignInputP2WPKH(txn, 0, testPrivateKey1, constants.SigHashAll, prevOuts[0].Value)
+			},
+		},
+		{
+			name:         "P2SH-P2WPKH",
+			scriptPubKey: script.MakeP2SHFromScript(script.MakeP2WPKHFromHash(publicKeyHash)),
+			sign: func(txn *tx.Tx, prevOuts []*tx.Output) error {
+				return signer.SignInputP2SHNestedP2WPKH(txn, 0, testPrivateKey1, constants.SigHashAll, prevOuts[0].Value)
+			},
+		},
+		{
+			name:         "P2TR key path",
+			scriptPubKey: p2tr,
+			sign: func(txn *tx.Tx, prevOuts []*tx.Output) error {
+				return signer.SignInputP2TR(txn, 0, testPrivateKey1, constants.SigHashDefault, prevOuts)
+			},
+		},
+		{
+			name:         "P2TR key path SIGHASH_ALL|ANYONECANPAY",
+			scriptPubKey: p2tr,
+			sign: func(txn *tx.Tx, prevOuts []*tx.Output) error {
+				sigHashType := constants.SigHashAll | constants.SigHashAnyoneCanPay
+				return signer.SignInputP2TR(txn, 0, testPrivateKey1, sigHashType, prevOuts)
+			},
+		},
+	}
+
+	for _, fixture := range fixtures {
+		txn, prevOuts := spendingTx([]byte{}, fixture.scriptPubKey, nil)
+		if err := fixture.sign(txn, prevOuts); err != nil {
+			t.Errorf("%s: failed to sign input: %s", fixture.name, err)
+			continue
+		}
+
+		if err := VerifyInput(txn, 0, prevOuts, StandardFlags); err != nil {
+			t.Errorf("%s: failed to verify signed input: %s", fixture.name, err)
+			continue
+		}
+
+		// Changing the spent amount invalidates segwit signatures, so change the outputs instead.
+		txn.Outputs[0].Value++
+		if err := VerifyInput(txn, 0, prevOuts, StandardFlags); !errors.Is(err, ErrScriptFailed) {
+			t.Errorf("%s: expected tampered transaction to fail verification, got %v", fixture.name, err)
+			continue
+		}
+	}
+}
+
+func TestVerifyInput_P2WSHMultiSig(t *testing.T) {
+	publicKey1 := ecc.GetPublicKeyCompressed(testPrivateKey1)
+	publicKey2 := ecc.GetPublicKeyCompressed(testPrivateKey2)
+	witnessScript := script.MakeP2MS(2, publicKey1, publicKey2)
+
+	txn, prevOuts := spendingTx([]byte{}, script.MakeP2WSHFromScript(witnessScript), nil)
+
+	sigHash, err := txn.SignatureHashForWitnessInput(0, witnessScript, constants.SigHashAll, prevOuts[0].Value)
+	if err != nil {
+		t.Errorf("failed to compute sighash: %s", err)
+		return
+	}
+
+	sig1, err := signer.SignSigHash(sigHash[:], testPrivateKey1, constants.SigHashAll)
+	if err != nil {
+		t.Errorf("failed to sign: %s", err)
+		return
+	}
+	sig2, err := signer.SignSigHash(sigHash[:], testPrivateKey2, constants.SigHashAll)
+	if err != nil {
+		t.Errorf("failed to sign: %s", err)
+		return
+	}
+
+	txn.Witnesses = []tx.Witness{{[]byte{}, sig1, sig2, witnessScript}}
+	if err := VerifyInput(txn, 0, prevOuts, StandardFlags); err != nil {
+		t.Errorf("failed to verify P2WSH multisig input: %s", err)
+		return
+	}
+
+	// Signatures out of order
+	txn.Witnesses = []tx.Witness{{[]byte{}, sig2, sig1, witnessScript}}
+	if err := VerifyInput(txn, 0, prevOuts, StandardFlags); !errors.Is(err, ErrNullFail) {
+		t.Errorf("expected ErrNullFail for out-of-order signatures, got %v", err)
+		return
+	}
+
+	// Non-null dummy
+	txn.Witnesses = []tx.Witness{{[]byte{1}, sig1, sig2, witnessScript}}
+	if err := VerifyInput(txn, 0, prevOuts, StandardFlags); !errors.Is(err, ErrNullDummy) {
+		t.Errorf("expected ErrNullDummy, got %v", err)
+		return
+	}
+
+	// Wrong witness script
+	txn.Witnesses = []tx.Witness{{[]byte{}, sig1, sig2, script.MakeP2MS(1, publicKey1, publicKey2)}}
+	if err := VerifyInput(txn, 0, prevOuts, StandardFlags); !errors.Is(err, ErrWitnessProgramMismatch) {
+		t.Errorf("expected ErrWitnessProgramMismatch, got %v", err)
+		return
+	}
+}
+
+func TestVerifyInput_TapscriptScriptPath(t *testing.T) {
+	internalPublicKey := ecc.GetPublicKeySchnorr(testPrivateKey1)
+	leafPublicKey := ecc.GetPublicKeySchnorr(testPrivateKey2)
+
+	leaf := &script.MastLeaf{
+		Version: constants.TaprootLeafVersionTapscript,
+		Script:  assemble(leafPublicKey, constants.OP_CHECKSIG),
+	}
+	otherLeaf := &script.MastLeaf{
+		Version: constants.TaprootLeafVersionTapscript,
+		Script:  assemble(constants.OP_0),
+	}
+	tree := script.MastBranch{leaf, otherLeaf}
+
+	scriptPubKey, err := script.MakeP2TR(internalPublicKey, tree)
+	if err != nil {
+		t.Errorf("failed to make P2TR script: %s", err)
+		return
+	}
+
+	treeHash := tree.Hash()
+	_, hasOddY, err := taproot.TweakPublicKey(internalPublicKey, treeHash[:])
+	if err != nil {
+		t.Errorf("failed to tweak public key: %s", err)
+		return
+	}
+
+	controlBlock := []byte{constants.TaprootLeafVersionTapscript}
+	if hasOddY {
+		controlBlock[0] |= 1
+	}
+	otherLeafHash := otherLeaf.Hash()
+	controlBlock = append(controlBlock, internalPublicKey...)
+	controlBlock = append(controlBlock, otherLeafHash[:]...)
+
+	txn, prevOuts := spendingTx([]byte{}, scriptPubKey, nil)
+
+	sigHash, err := txn.SignatureHashForTapscriptInput(0, prevOuts, constants.SigHashDefault, nil, leaf.Hash(), 0xffffffff)
+	if err != nil {
+		t.Errorf("failed to compute tapscript sighash: %s", err)
+		return
+	}
+	signature, err := signer.SignSigHashSchnorr(sigHash[:], testPrivateKey2, constants.SigHashDefault)
+	if err != nil {
+		t.Errorf("failed to sign: %s", err)
+		return
+	}
+
+	txn.Witnesses = []tx.Witness{{signature, leaf.Script, controlBlock}}
+	if err := VerifyInput(txn, 0, prevOuts, StandardFlags); err != nil {
+		t.Errorf("failed to verify tapscript input: %s", err)
+		return
+	}
+
+	// Invalid signature
+	badSignature := append([]byte{}, signature...)
+	badSignature[0] ^= 1
+	txn.Witnesses = []tx.Witness{{badSignature, leaf.Script, controlBlock}}
+	if err := VerifyInput(txn, 0, prevOuts, StandardFlags); !errors.Is(err, ErrSchnorrSignature) {
+		t.Errorf("expected ErrSchnorrSignature, got %v", err)
+		return
+	}
+
+	// Empty signature fails without an error from OP_CHECKSIG itself
+	txn.Witnesses = []tx.Witness{{[]byte{}, leaf.Script, controlBlock}}
+	if err := VerifyInput(txn, 0, prevOuts, StandardFlags); !errors.Is(err, ErrEvalFalse) {
+		t.Errorf("expected ErrEvalFalse, got %v", err)
+		return
+	}
+
+	// Wrong control block parity
+	badControlBlock := append([]byte{}, controlBlock...)
+	badControlBlock[0] ^= 1
+	txn.Witnesses = []tx.Witness{{signature, leaf.Script, badControlBlock}}
+	if err := VerifyInput(txn, 0, prevOuts, StandardFlags); !errors.Is(err, ErrWitnessProgramMismatch) {
+		t.Errorf("expected ErrWitnessProgramMismatch, got %v", err)
+		return
+	}
+
+	// Invalid control block size
+	txn.Witnesses = []tx.Witness{{signature, leaf.Script, controlBlock[:40]}}
+	if err := VerifyInput(txn, 0, prevOuts, StandardFlags); !errors.Is(err, ErrTaprootControlBlockSize) {
+		t.Errorf("expected ErrTaprootControlBlockSize, got %v", err)
+		return
+	}
+}
+
+func TestVerifyInput_WitnessErrors(t *testing.T) {
+	publicKeyHash := bhash.Hash160(ecc.GetPublicKeyCompressed(testPrivateKey1))
+	p2wpkh := script.MakeP2WPKHFromHash(publicKeyHash)
+
+	fixtures := []struct {
+		name         string
+		scriptSig    []byte
+		scriptPubKey []byte
+		witness      tx.Witness
+		err          error
+	}{
+		{
+			name:         "empty P2WSH witness",
+			scriptSig:    []byte{},
+			scriptPubKey: script.MakeP2WSHFromScript([]byte{constants.OP_1}),
+			witness:      tx.Witness{},
+			err:          ErrWitnessProgramWitnessEmpty,
+		},
+		{
+			name:         "P2WPKH with wrong witness size",
+			scriptSig:    []byte{},
+			scriptPubKey: p2wpkh,
+			witness:      tx.Witness{{}},
+			err:          ErrWitnessProgramMismatch,
+		},
+		{
+			name:         "malleated native witness program",
+			scriptSig:    assemble(constants.OP_1),
+			scriptPubKey: script.MakeP2WSHFromScript([]byte{constants.OP_1}),
+			witness:      tx.Witness{{constants.OP_1}},
+			err:          ErrWitnessMalleated,
+		},
+		{
+			name:         "unexpected witness",
+			scriptSig:    []byte{},
+			scriptPubKey: []byte{constants.OP_1},
+			witness:      tx.Witness{{}},
+			err:          ErrWitnessUnexpected,
+		},
+		{
+			name:         "upgradable witness version",
+			scriptSig:    []byte{},
+			scriptPubKey: append([]byte{constants.OP_2, constants.OP_DATA_2}, 0xab, 0xcd),
+			witness:      tx.Witness{},
+			err:          ErrDiscourageUpgradableWitnessProgram,
+		},
+		{
+			name:         "P2WSH witness script",
+			scriptSig:    []byte{},
+			scriptPubKey: script.MakeP2WSHFromScript([]byte{constants.OP_1}),
+			witness:      tx.Witness{{constants.OP_1}},
+		},
+	}
+
+	for _, fixture := range fixtures {
+		txn, prevOuts := spendingTx(fixture.scriptSig, fixture.scriptPubKey, fixture.witness)
+		err := VerifyInput(txn, 0, prevOuts, StandardFlags)
+
+		if fixture.err == nil && err != nil {
+			t.Errorf("%s: unexpected error: %s", fixture.name, err)
+			continue
+		} else if fixture.err != nil && !errors.Is(err, fixture.err) {
+			t.Errorf("%s: expected error %q, got %v", fixture.name, fixture.err, err)
+			continue
+		}
+	}
+
+	txn, prevOuts := spendingTx([]byte{}, p2wpkh, nil)
+	if err := VerifyInput(txn, 1, prevOuts, StandardFlags); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for out-of-range input, got %v", err)
+		return
+	}
+}
+
+func TestVerifyInput_SignatureEncoding(t *testing.T) {
+	publicKey := ecc.GetPublicKeyCompressed(testPrivateKey1)
+	scriptPubKey := script.MakeP2PKHFromHash(bhash.Hash160(publicKey))
+
+	txn, prevOuts := spendingTx([]byte{}, scriptPubKey, nil)
+	if err := signer.SignInputP2PKH(txn, 0, testPrivateKey1, constants.SigHashAll); err != nil {
+		t.Errorf("failed to sign input: %s", err)
+		return
+	}
+
+	signature, err := script.ReadData(bytes.NewReader(txn.Inputs[0].Script))
+	if err != nil {
+		t.Errorf("failed to read signature from scriptSig: %s", err)
+		return
+	}
+
+	r, s, _, err := der.DecodeSignature(signature)
+	if err != nil {
+		t.Errorf("failed to decode signature: %s", err)
+		return
+	}
+
+	// Pad R with an unnecessary zero byte, which is allowed only by lax DER parsing.
+	rBytes := append([]byte{0, 0}, r.Bytes()...)
+	sEncoded, _ := der.EncodeBigInt(s)
+	laxSignature := []byte{der.TypeCompound, byte(2 + len(rBytes) + len(sEncoded)), der.TagInteger, byte(len(rBytes))}
+	laxSignature = append(laxSignature, rBytes...)
+	laxSignature = append(laxSignature, sEncoded...)
+	laxSignature = append(laxSignature, byte(constants.SigHashAll))
+
+	curveOrder, _ := new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	highSSignature, _ := der.EncodeSignature(r, new(big.Int).Sub(curveOrder, s), constants.SigHashAll)
+
+	fixtures := []struct {
+		name      string
+		signature []byte
+		flags     Flags
+		err       error
+	}{
+		{"lax DER without BIP66", laxSignature, FlagP2SH, nil},
+		{"lax DER with BIP66", laxSignature, ConsensusFlags, ErrSignatureEncoding},
+		{"high S consensus", highSSignature, ConsensusFlags, nil},
+		{"high S standard", highSSignature, StandardFlags, ErrSignatureHighS},
+	}
+
+	for _, fixture := range fixtures {
+		txn.Inputs[0].Script = assemble(fixture.signature, publicKey)
+		err := VerifyInput(txn, 0, prevOuts, fixture.flags)
+
+		if fixture.err == nil && err != nil {
+			t.Errorf("%s: unexpected error: %s", fixture.name, err)
+			continue
+		} else if fixture.err != nil && !errors.Is(err, fixture.err) {
+			t.Errorf("%s: expected error %q, got %v", fixture.name, fixture.err, err)
+			continue
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package interpreter
+
+// The reference tests in script_tests.json, tx_valid.json and tx_invalid.json come from Bitcoin Core
+// (https://github.com/bitcoin/bitcoin), and are distributed under the MIT software license.
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/common"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+// referenceFlags maps the names of flags used in Bitcoin Core's reference tests to Flags.
+var referenceFlags = map[string]Flags{
+	"P2SH":                                  FlagP2SH,
+	"STRICTENC":                             FlagStrictEncoding,
+	"DERSIG":                                FlagDERSignatures,
+	"LOW_S":                                 FlagLowS,
+	"NULLDUMMY":                             FlagNullDummy,
+	"SIGPUSHONLY":                           FlagSigPushOnly,
+	"MINIMALDATA":                           FlagMinimalData,
+	"CLEANSTACK":                            FlagCleanStack,
+	"CHECKLOCKTIMEVERIFY":                   FlagCheckLockTimeVerify,
+	"CHECKSEQUENCEVERIFY":                   FlagCheckSequenceVerify,
+	"WITNESS":                               FlagWitness,
+	"DISCOURAGE_UPGRADABLE_WITNESS_PROGRAM": FlagDiscourageUpgradableWitnessProgram,
+	"MINIMALIF":                             FlagMinimalIf,
+	"NULLFAIL":                              FlagNullFail,
+	"WITNESS_PUBKEYTYPE":                    FlagWitnessPubKeyType,
+	"TAPROOT":                               FlagTaproot,
+}
+
+// parseReferenceFlags parses a comma-separated list of flag names. It returns false if
+// any of the flags are not supported by this package, such as DISCOURAGE_UPGRADABLE_NOPS.
+func parseReferenceFlags(names string) (Flags, bool) {
+	var flags Flags
+	for _, name := range strings.Split(names, ",") {
+		if name == "" || name == "NONE" {
+			continue
+		}
+		flag, ok := referenceFlags[name]
+		if !ok {
+			return 0, false
+		}
+		flags |= flag
+	}
+	return flags, true
+}
+
+// parseShortForm assembles a script written in the short form used by Bitcoin Core's
+// reference tests, as parsed by ParseScript in Bitcoin Core's core_read.cpp.
+func parseShortForm(shortForm string) ([]byte, error) {
+	buf := bytes.NewBuffer([]byte{})
+	for _, token := range strings.Fields(shortForm) {
+		if n, err := strconv.ParseInt(token, 10, 64); err == nil {
+			buf.Write(script.PushNumber(n))
+		} else if strings.HasPrefix(token, "0x") && len(token) > 2 {
+			raw, err := hex.DecodeString(token[2:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex token %q: %w", token, err)
+			}
+			buf.Write(raw)
+		} else if len(token) >= 2 && token[0] == '\'' && token[len(token)-1] == '\'' {
+			buf.Write(script.PushData([]byte(token[1 : len(token)-1])))
+		} else if op, ok := constants.OpCodes[token]; ok {
+			buf.WriteByte(op)
+		} else if op, ok := constants.OpCodes["OP_"+token]; ok {
+			buf.WriteByte(op)
+		} else {
+			return nil, fmt.Errorf("unknown script token %q", token)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// loadReferenceTests reads one of Bitcoin Core's reference test files. Rows which
+// consist of a single string are comments, and are skipped.
+func loadReferenceTests(t *testing.T, fileName string) [][]json.RawMessage {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", fileName, err)
+	}
+
+	var rows [][]json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		t.Fatalf("failed to decode %s: %s", fileName, err)
+	}
+
+	var tests [][]json.RawMessage
+	for _, row := range rows {
+		if len(row) > 1 {
+			tests = append(tests, row)
+		}
+	}
+	return tests
+}
+
+// creditingTxs returns the transactions used to evaluate a script test: one which creates an
+// output with scriptPubKey, and one which spends it with scriptSig and witness.
+func creditingTxs(scriptSig, scriptPubKey []byte, witness tx.Witness, amount uint64) (*tx.Tx, []*tx.Output, error) {
+	prevOut := &tx.Output{Value: amount, Script: scriptPubKey}
+	crediting := &tx.Tx{
+		Version: 1,
+		Inputs: []*tx.Input{{
+			PrevOut:  &tx.PrevOut{Index: 0xffffffff},
+			Script:   []byte{constants.OP_0, constants.OP_0},
+			Sequence: tx.SequenceFinal,
+		}},
+		Outputs: []*tx.Output{prevOut},
+	}
+
+	hash, err := crediting.Hash(false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	spending := &tx.Tx{
+		Version: 1,
+		Inputs: []*tx.Input{{
+			PrevOut:  &tx.PrevOut{Hash: hash, Index: 0},
+			Script:   scriptSig,
+			Sequence: tx.SequenceFinal,
+		}},
+		Outputs: []*tx.Output{{Value: amount, Script: []byte{}}},
+	}
+	if witness != nil {
+		spending.Witnesses = []tx.Witness{witness}
+	}
+
+	return spending, []*tx.Output{prevOut}, nil
+}
+
+func TestScriptReferenceTests(t *testing.T) {
+	ran, skipped := 0, 0
+
+	for _, row := range loadReferenceTests(t, "script_tests.json") {
+		var (
+			witness tx.Witness
+			amount  uint64
+		)
+
+		// Witness tests begin with an array of witness items, followed by the amount in BTC.
+		if row[0][0] == '[' {
+			var witnessItems []json.RawMessage
+			if err := json.Unmarshal(row[0], &witnessItems); err != nil || len(witnessItems) == 0 {
+				t.Errorf("invalid witness in script test %s", row)
+				continue
+			}
+
+			var amountBTC float64
+			if err := json.Unmarshal(witnessItems[len(witnessItems)-1], &amountBTC); err != nil {
+				t.Errorf("invalid amount in script test %s", row)
+				continue
+			}
+			amount = uint64(math.Round(amountBTC * constants.SatoshisPerBitcoin))
+
+			witness = tx.Witness{}
+			for _, item := range witnessItems[:len(witnessItems)-1] {
+				var itemHex string
+				if err := json.Unmarshal(item, &itemHex); err != nil {
+					t.Errorf("invalid witness item in script test %s", row)
+					continue
+				}
+				witness = append(witness, hex2bytes(itemHex))
+			}
+			row = row[1:]
+		}
+
+		if len(row) < 4 {
+			t.Errorf("script test has too few fields: %s", row)
+			continue
+		}
+
+		var scriptSigText, scriptPubKeyText, flagNames, expected string
+		for i, field := range []*string{&scriptSigText, &scriptPubKeyText, &flagNames, &expected} {
+			if err := json.Unmarshal(row[i], field); err != nil {
+				t.Errorf("invalid field %d in script test %s", i, row)
+				continue
+			}
+		}
+
+		flags, ok := parseReferenceFlags(flagNames)
+		if !ok {
+			skipped++
+			continue
+		}
+
+		scriptSig, err := parseShortForm(scriptSigText)
+		if err != nil {
+			t.Errorf("failed to parse scriptSig of script test %s: %s", row, err)
+			continue
+		}
+		scriptPubKey, err := parseShortForm(scriptPubKeyText)
+		if err != nil {
+			t.Errorf("failed to parse scriptPubKey of script test %s: %s", row, err)
+			continue
+		}
+
+		spending, prevOuts, err := creditingTxs(scriptSig, scriptPubKey, witness, amount)
+		if err != nil {
+			t.Errorf("failed to build transactions for script test %s: %s", row, err)
+			continue
+		}
+
+		ran++
+		err = VerifyInput(spending, 0, prevOuts, flags)
+		if expected == "OK" && err != nil {
+			t.Errorf("script test failed: %s\n%s", row, err)
+			continue
+		} else if expected != "OK" && err == nil {
+			t.Errorf("script test passed, expected %s: %s", expected, row)
+			continue
+		}
+	}
+
+	t.Logf("ran %d script tests, skipped %d with unsupported flags", ran, skipped)
+}
+
+// referenceTx is a test from tx_valid.json or tx_invalid.json.
+type referenceTx struct {
+	txn      *tx.Tx
+	prevOuts []*tx.Output
+	flags    Flags
+}
+
+// parseReferenceTx decodes a test from tx_valid.json or tx_invalid.json. It returns
+// nil if the test uses flags which are not supported by this package.
+func parseReferenceTx(row []json.RawMessage) (*referenceTx, error) {
+	if len(row) != 3 {
+		return nil, fmt.Errorf("expected 3 fields, got %d", len(row))
+	}
+
+	var (
+		inputs    [][]json.RawMessage
+		txHex     string
+		flagNames string
+	)
+	if err := json.Unmarshal(row[0], &inputs); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(row[1], &txHex); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(row[2], &flagNames); err != nil {
+		return nil, err
+	}
+
+	flags, ok := parseReferenceFlags(flagNames)
+	if !ok {
+		return nil, nil
+	}
+
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, err
+	}
+	txn, err := tx.FromBytes(txBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	prevOutsByOutpoint := make(map[tx.PrevOut]*tx.Output)
+	for _, input := range inputs {
+		if len(input) < 3 || len(input) > 4 {
+			return nil, fmt.Errorf("input has %d fields", len(input))
+		}
+
+		var (
+			hashHex    string
+			index      int64
+			scriptText string
+			amount     int64
+		)
+		if err := json.Unmarshal(input[0], &hashHex); err != nil {
+			return nil, err
+		} else if err := json.Unmarshal(input[1], &index); err != nil {
+			return nil, err
+		} else if err := json.Unmarshal(input[2], &scriptText); err != nil {
+			return nil, err
+		} else if len(input) == 4 {
+			if err := json.Unmarshal(input[3], &amount); err != nil {
+				return nil, err
+			}
+		}
+
+		scriptPubKey, err := parseShortForm(scriptText)
+		if err != nil {
+			return nil, err
+		}
+
+		var prevOut tx.PrevOut
+		copy(prevOut.Hash[:], common.ReverseBytes(hex2bytes(hashHex)))
+		prevOut.Index = uint32(index)
+		prevOutsByOutpoint[prevOut] = &tx.Output{Value: uint64(amount), Script: scriptPubKey}
+	}
+
+	prevOuts := make([]*tx.Output, len(txn.Inputs))
+	for i, input := range txn.Inputs {
+		if prevOuts[i] = prevOutsByOutpoint[*input.PrevOut]; prevOuts[i] == nil {
+			return nil, fmt.Errorf("missing prevout for input %d", i)
+		}
+	}
+
+	return &referenceTx{txn, prevOuts, flags}, nil
+}
+
+func TestTxValidReferenceTests(t *testing.T) {
+	for _, row := range loadReferenceTests(t, "tx_valid.json") {
+		test, err := parseReferenceTx(row)
+		if err != nil {
+			t.Errorf("failed to parse tx test %s: %s", row, err)
+			continue
+		} else if test == nil {
+			continue
+		}
+
+		if err := VerifyTx(test.txn, test.prevOuts, test.flags); err != nil {
+			t.Errorf("valid tx test failed: %s\n%s", row, err)
+			continue
+		}
+	}
+}
+
+func TestTxInvalidReferenceTests(t *testing.T) {
+	for _, row := range loadReferenceTests(t, "tx_invalid.json") {
+		test, err := parseReferenceTx(row)
+		if err != nil {
+			t.Errorf("failed to parse tx test %s: %s", row, err)
+			continue
+		} else if test == nil {
+			continue
+		}
+
+		if err := VerifyTx(test.txn, test.prevOuts, test.flags); err == nil {
+			t.Errorf("invalid tx test passed: %s", row)
+			continue
+		}
+	}
+}
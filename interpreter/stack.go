@@ -0,0 +1,138 @@
+package interpreter
+
+// stack is the main and alt-stack used during script execution.
+// The top of the stack is the last element in the slice.
+type stack [][]byte
+
+func (st *stack) push(item []byte) {
+	*st = append(*st, item)
+}
+
+func (st *stack) pushBool(v bool) {
+	if v {
+		st.push([]byte{1})
+	} else {
+		st.push([]byte{})
+	}
+}
+
+func (st *stack) pushNumber(n int64) {
+	st.push(encodeScriptNumber(n))
+}
+
+// pop removes and returns the top element of the stack. Callers
+// must check the stack size before calling pop.
+func (st *stack) pop() []byte {
+	s := *st
+	item := s[len(s)-1]
+	*st = s[:len(s)-1]
+	return item
+}
+
+// top returns the top element of the stack without removing it.
+func (st stack) top() []byte {
+	return st[len(st)-1]
+}
+
+// peek returns the element at the given depth from the top of the stack, where depth 0 is the top.
+func (st stack) peek(depth int) []byte {
+	return st[len(st)-1-depth]
+}
+
+// remove deletes the element at the given depth from the top of the stack and returns it.
+func (st *stack) remove(depth int) []byte {
+	s := *st
+	i := len(s) - 1 - depth
+	item := s[i]
+	*st = append(s[:i], s[i+1:]...)
+	return item
+}
+
+func (st stack) clone() stack {
+	clone := make(stack, len(st))
+	for i, item := range st {
+		clone[i] = append([]byte{}, item...)
+	}
+	return clone
+}
+
+// castToBool interprets a stack element as a boolean. Any non-zero value is true,
+// except for negative zero: a value whose only set bit is the sign bit of the last byte.
+func castToBool(item []byte) bool {
+	for i, b := range item {
+		if b != 0 {
+			if i == len(item)-1 && b == 0x80 {
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// decodeScriptNumber decodes a little-endian sign-magnitude script number from a stack element,
+// which may be at most maxSize bytes long. If requireMinimal is true, the number must not
+// have any unnecessary trailing zero bytes.
+func decodeScriptNumber(item []byte, maxSize int, requireMinimal bool) (int64, error) {
+	if len(item) > maxSize {
+		return 0, ErrInvalidNumber
+	}
+
+	if requireMinimal && len(item) > 0 {
+		// The most significant byte may only be zero (ignoring the sign bit) if the
+		// next byte has its high bit set, and thus needs the extra sign byte.
+		if item[len(item)-1]&0x7f == 0 {
+			if len(item) == 1 || item[len(item)-2]&0x80 == 0 {
+				return 0, ErrInvalidNumber
+			}
+		}
+	}
+
+	if len(item) == 0 {
+		return 0, nil
+	}
+
+	var n int64
+	for i, b := range item {
+		n |= int64(b) << (8 * uint(i))
+	}
+
+	// The sign bit is the high bit of the last byte.
+	if item[len(item)-1]&0x80 != 0 {
+		n &^= int64(0x80) << (8 * uint(len(item)-1))
+		return -n, nil
+	}
+
+	return n, nil
+}
+
+// encodeScriptNumber encodes n as a minimally-encoded little-endian sign-magnitude script number.
+func encodeScriptNumber(n int64) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+
+	isNegative := n < 0
+	abs := uint64(n)
+	if isNegative {
+		abs = uint64(-n)
+	}
+
+	result := make([]byte, 0, 9)
+	for abs > 0 {
+		result = append(result, byte(abs&0xff))
+		abs >>= 8
+	}
+
+	if result[len(result)-1]&0x80 != 0 {
+		if isNegative {
+			result = append(result, 0x80)
+		} else {
+			result = append(result, 0x00)
+		}
+	} else if isNegative {
+		result[len(result)-1] |= 0x80
+	}
+
+	return result
+}
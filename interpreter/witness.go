@@ -0,0 +1,185 @@
+package interpreter
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/script"
+	"github.com/kklash/bitcoinlib/taproot"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+const (
+	// annexTag is the first byte of the last witness element which identifies it as a taproot annex.
+	annexTag = 0x50
+
+	taprootLeafMask         = 0xfe
+	taprootControlBaseSize  = 33
+	taprootControlNodeSize  = 32
+	taprootControlMaxNodes  = 128
+	validationWeightOffset  = 50
+	witnessV0KeyHashSize    = 20
+	witnessV0ScriptHashSize = 32
+	witnessV1TaprootSize    = 32
+)
+
+// verifyWitnessProgram verifies the witness stack of an input spending a witness program,
+// as specified in BIP141 and BIP341. If isP2SH is true, the witness program was wrapped in
+// a P2SH redeem script, which prevents it from being spent as a taproot output.
+func (e *engine) verifyWitnessProgram(witness tx.Witness, version int, program []byte, isP2SH bool) error {
+	st := make(stack, len(witness))
+	copy(st, witness)
+
+	switch {
+	case version == 0:
+		switch len(program) {
+		case witnessV0ScriptHashSize:
+			if len(st) == 0 {
+				return ErrWitnessProgramWitnessEmpty
+			}
+			witnessScript := st.pop()
+			scriptHash := sha256.Sum256(witnessScript)
+			if !bytes.Equal(scriptHash[:], program) {
+				return ErrWitnessProgramMismatch
+			}
+			return e.executeWitnessScript(st, witnessScript, sigVersionWitnessV0)
+
+		case witnessV0KeyHashSize:
+			if len(st) != 2 {
+				return ErrWitnessProgramMismatch
+			}
+			var keyHash [20]byte
+			copy(keyHash[:], program)
+			return e.executeWitnessScript(st, script.MakeP2PKHFromHash(keyHash), sigVersionWitnessV0)
+
+		default:
+			return ErrWitnessProgramWrongLength
+		}
+
+	case version == 1 && len(program) == witnessV1TaprootSize && !isP2SH:
+		if e.flags&FlagTaproot == 0 {
+			return nil
+		}
+		return e.verifyTaproot(st, witness, program)
+
+	default:
+		// Unknown witness versions are reserved for future soft forks, and always succeed.
+		if e.flags&FlagDiscourageUpgradableWitnessProgram != 0 {
+			return ErrDiscourageUpgradableWitnessProgram
+		}
+		return nil
+	}
+}
+
+// verifyTaproot verifies a key-path or script-path spend of a taproot output
+// with the given 32-byte output public key.
+func (e *engine) verifyTaproot(st stack, witness tx.Witness, outputPublicKey []byte) error {
+	if len(st) == 0 {
+		return ErrWitnessProgramWitnessEmpty
+	}
+
+	if len(st) >= 2 && len(st.top()) > 0 && st.top()[0] == annexTag {
+		e.annex = st.pop()
+	}
+
+	if len(st) == 1 {
+		// Key path spending: the only witness element is a signature by the output key.
+		return e.verifySchnorr(st[0], outputPublicKey, false)
+	}
+
+	// Script path spending.
+	controlBlock := st.pop()
+	tapscript := st.pop()
+
+	if len(controlBlock) < taprootControlBaseSize ||
+		len(controlBlock) > taprootControlBaseSize+taprootControlMaxNodes*taprootControlNodeSize ||
+		(len(controlBlock)-taprootControlBaseSize)%taprootControlNodeSize != 0 {
+		return ErrTaprootControlBlockSize
+	}
+
+	leaf := &script.MastLeaf{
+		Version: controlBlock[0] & taprootLeafMask,
+		Script:  tapscript,
+	}
+	e.tapLeafHash = leaf.Hash()
+
+	if !verifyTaprootCommitment(controlBlock, outputPublicKey, e.tapLeafHash) {
+		return ErrWitnessProgramMismatch
+	}
+
+	if leaf.Version != constants.TaprootLeafVersionTapscript {
+		// Unknown leaf versions are reserved for future soft forks, and always succeed.
+		if e.flags&FlagDiscourageUpgradableWitnessProgram != 0 {
+			return ErrDiscourageUpgradableWitnessProgram
+		}
+		return nil
+	}
+
+	// Any OP_SUCCESSx opcode causes the tapscript to succeed unconditionally,
+	// even if it would otherwise fail to parse after that opcode.
+	for pc := 0; pc < len(tapscript); {
+		op, _, next, err := parseOp(tapscript, pc)
+		if err != nil {
+			return err
+		}
+		if isOpSuccess(op) {
+			if e.flags&FlagDiscourageUpgradableWitnessProgram != 0 {
+				return ErrDiscourageUpgradableWitnessProgram
+			}
+			return nil
+		}
+		pc = next
+	}
+
+	e.validationWeightLeft = int64(witness.Size()) + validationWeightOffset
+	return e.executeWitnessScript(st, tapscript, sigVersionTapscript)
+}
+
+// verifyTaprootCommitment returns true if the control block proves that outputPublicKey
+// commits to a script tree containing the leaf with the given hash.
+func verifyTaprootCommitment(controlBlock, outputPublicKey []byte, leafHash [32]byte) bool {
+	internalPublicKey := controlBlock[1:taprootControlBaseSize]
+
+	var node script.Hasher = script.MastLeafHash(leafHash)
+	for i := taprootControlBaseSize; i < len(controlBlock); i += taprootControlNodeSize {
+		var sibling script.MastLeafHash
+		copy(sibling[:], controlBlock[i:i+taprootControlNodeSize])
+		node = script.MastLeafHash(script.MastBranch{node, sibling}.Hash())
+	}
+	merkleRoot := node.Hash()
+
+	tweakedPublicKey, hasOddY, err := taproot.TweakPublicKey(internalPublicKey, merkleRoot[:])
+	if err != nil {
+		return false
+	}
+
+	parity := controlBlock[0]&1 == 1
+	return bytes.Equal(tweakedPublicKey, outputPublicKey) && parity == hasOddY
+}
+
+// executeWitnessScript executes a segwit v0 script or tapscript with the given initial witness stack.
+// The script must leave exactly one true element on the stack.
+func (e *engine) executeWitnessScript(st stack, witnessScript []byte, sigVersion sigVersion) error {
+	if sigVersion == sigVersionTapscript && len(st) > maxStackSize {
+		return ErrStackSize
+	}
+
+	for _, item := range st {
+		if len(item) > maxScriptElementSize {
+			return ErrPushSize
+		}
+	}
+
+	if err := e.evalScript(&st, witnessScript, sigVersion); err != nil {
+		return err
+	}
+
+	if len(st) != 1 {
+		return ErrCleanStack
+	} else if !castToBool(st.top()) {
+		return ErrEvalFalse
+	}
+
+	return nil
+}
@@ -0,0 +1,3 @@
+module github.com/kklash/bitcoinlib/message
+
+go 1.18
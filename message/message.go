@@ -0,0 +1,36 @@
+// Package message provides signing and verification of Bitcoin Signed Messages as
+// specified in BIP137, which allow the owner of an address to prove control of it.
+package message
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/kklash/bitcoinlib/bhash"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/varint"
+)
+
+var (
+	// ErrInvalidSignature is returned when verifying a signature which
+	// is not a properly encoded base64 compact signature.
+	ErrInvalidSignature = errors.New("message signature is not formatted correctly")
+
+	// ErrUnsupportedAddressFormat is returned when signing or verifying a message
+	// for an address format which cannot be used with BIP137 signatures.
+	ErrUnsupportedAddressFormat = errors.New("address format is not supported for message signing")
+)
+
+// Hash returns the double-SHA256 hash of the given message, prefixed with the varint-length-prefixed
+// constants.BitcoinSignedMessageMagic string. The message itself is also varint-length-prefixed.
+func Hash(msg string) [32]byte {
+	buf := new(bytes.Buffer)
+
+	varint.VarInt(len(constants.BitcoinSignedMessageMagic)).WriteTo(buf)
+	buf.WriteString(constants.BitcoinSignedMessageMagic)
+
+	varint.VarInt(len(msg)).WriteTo(buf)
+	buf.WriteString(msg)
+
+	return bhash.DoubleSha256(buf.Bytes())
+}
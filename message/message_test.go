@@ -0,0 +1,141 @@
+package message
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/address"
+	"github.com/kklash/bitcoinlib/constants"
+)
+
+func TestHash(t *testing.T) {
+	hash := Hash("This is an example of a signed message.")
+	expected := "d0e5595ac689a1df9f0b13443e0efd876eeb762d50a05f7179b1506bfccfeec5"
+
+	if actual := hex.EncodeToString(hash[:]); actual != expected {
+		t.Errorf("unexpected message hash\nWanted %s\nGot    %s", expected, actual)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	type Fixture struct {
+		wifKey        string
+		msg           string
+		addressFormat constants.AddressFormat
+		address       string
+		signature     string
+	}
+
+	// These fixtures pulled from bitcoinjs-message
+	fixtures := []Fixture{
+		{
+			wifKey:        "L4rK1yDtCWekvXuE6oXD9jCYfFNV2cWRpVuPLBcCU2z8TrisoyY1",
+			msg:           "This is an example of a signed message.",
+			addressFormat: constants.FormatP2PKH,
+			address:       "1F3sAm6ZtwLAUnj7d38pGFxtP3RVEvtsbV",
+			signature:     "H9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk=",
+		},
+		{
+			wifKey:        "L4rK1yDtCWekvXuE6oXD9jCYfFNV2cWRpVuPLBcCU2z8TrisoyY1",
+			msg:           "This is an example of a signed message.",
+			addressFormat: constants.FormatP2SH,
+			address:       "3DnW8JGpPViEZdpqat8qky1zc26EKbXnmM",
+			signature:     "I9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk=",
+		},
+		{
+			wifKey:        "L4rK1yDtCWekvXuE6oXD9jCYfFNV2cWRpVuPLBcCU2z8TrisoyY1",
+			msg:           "This is an example of a signed message.",
+			addressFormat: constants.FormatP2WPKH,
+			address:       "bc1qngw83fg8dz0k749cg7k3emc7v98wy0c74dlrkd",
+			signature:     "J9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk=",
+		},
+		{
+			wifKey:        "5KYZdUEo39z3FPrtuX2QbbwGnNP5zTd7yyr2SC1j299sBCnWjss",
+			msg:           "This is an example of a signed message.",
+			addressFormat: constants.FormatP2PKH,
+			address:       "1HZwkjkeaoZfTSaJxDw6aKkxp45agDiEzN",
+			signature:     "G9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk=",
+		},
+	}
+
+	for _, fixture := range fixtures {
+		signature, err := SignWIF(fixture.wifKey, fixture.msg, fixture.addressFormat)
+		if err != nil {
+			t.Errorf("failed to sign message: %s", err)
+			return
+		} else if signature != fixture.signature {
+			t.Errorf("unexpected %s message signature\nWanted %s\nGot    %s", fixture.addressFormat, fixture.signature, signature)
+			return
+		}
+
+		valid, err := Verify(fixture.address, fixture.msg, fixture.signature)
+		if err != nil {
+			t.Errorf("failed to verify message signature: %s", err)
+			return
+		} else if !valid {
+			t.Errorf("expected signature to be valid for address %s", fixture.address)
+			return
+		}
+
+		valid, err = Verify(fixture.address, fixture.msg+"!", fixture.signature)
+		if err != nil {
+			t.Errorf("failed to verify message signature: %s", err)
+			return
+		} else if valid {
+			t.Errorf("expected signature on modified message to be invalid for address %s", fixture.address)
+			return
+		}
+	}
+}
+
+func TestVerify_Electrum(t *testing.T) {
+	// Electrum signs for segwit addresses using the P2PKH header byte.
+	p2pkhSignature := "H9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk="
+	msg := "This is an example of a signed message."
+
+	for _, addr := range []string{"3DnW8JGpPViEZdpqat8qky1zc26EKbXnmM", "bc1qngw83fg8dz0k749cg7k3emc7v98wy0c74dlrkd"} {
+		valid, err := Verify(addr, msg, p2pkhSignature)
+		if err != nil {
+			t.Errorf("failed to verify message signature: %s", err)
+			return
+		} else if !valid {
+			t.Errorf("expected Electrum-style signature to be valid for address %s", addr)
+			return
+		}
+	}
+
+	// Signatures with segwit headers should only be valid for the address type they declare.
+	p2wpkhSignature := "J9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk="
+	for _, addr := range []string{"1F3sAm6ZtwLAUnj7d38pGFxtP3RVEvtsbV", "3DnW8JGpPViEZdpqat8qky1zc26EKbXnmM"} {
+		valid, err := Verify(addr, msg, p2wpkhSignature)
+		if err != nil {
+			t.Errorf("failed to verify message signature: %s", err)
+			return
+		} else if valid {
+			t.Errorf("expected P2WPKH signature to be invalid for address %s", addr)
+			return
+		}
+	}
+}
+
+func TestVerify_Errors(t *testing.T) {
+	msg := "This is an example of a signed message."
+
+	if _, err := Verify("1F3sAm6ZtwLAUnj7d38pGFxtP3RVEvtsbV", msg, "not base64!"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for non-base64 signature; got %v", err)
+	}
+
+	if _, err := Verify("1F3sAm6ZtwLAUnj7d38pGFxtP3RVEvtsbV", msg, "H9L5yLFjti0QTHhP"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for short signature; got %v", err)
+	}
+
+	signature := "H9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk="
+	if _, err := Verify("1F3sAm6ZtwLAUnj7d38pGFxtP3RVEvtsbW", msg, signature); !errors.Is(err, address.ErrInvalidAddress) {
+		t.Errorf("expected ErrInvalidAddress for invalid address; got %v", err)
+	}
+
+	if _, err := Sign(make([]byte, 32), false, msg, constants.FormatP2WPKH); !errors.Is(err, ErrUnsupportedAddressFormat) {
+		t.Errorf("expected ErrUnsupportedAddressFormat for uncompressed P2WPKH signature; got %v", err)
+	}
+}
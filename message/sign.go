@@ -0,0 +1,58 @@
+package message
+
+import (
+	"encoding/base64"
+
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/ecc"
+	"github.com/kklash/bitcoinlib/wif"
+)
+
+// Sign signs the given message with a private key and returns a base64-encoded compact signature.
+// The header byte of the signature encodes the addressFormat the signer wishes to prove ownership
+// of, as specified by BIP137:
+//
+//   - constants.FormatP2PKH: a P2PKH address of the compressed or uncompressed public key.
+//   - constants.FormatP2SH: a P2SH-P2WPKH nested segwit address.
+//   - constants.FormatP2WPKH: a native P2WPKH segwit address.
+//
+// Segwit formats require a compressed public key. Returns ErrUnsupportedAddressFormat if the
+// address format cannot be signed for.
+//
+// Electrum and some other wallets sign for segwit addresses using the P2PKH header byte.
+// Verify accepts such signatures, so FormatP2PKH can be used to produce Electrum-style
+// signatures for any address of a compressed public key.
+func Sign(privateKey []byte, compressed bool, msg string, addressFormat constants.AddressFormat) (string, error) {
+	var headerBase byte
+	switch {
+	case addressFormat == constants.FormatP2PKH && compressed:
+		headerBase = constants.CompactSignatureHeaderCompressed
+	case addressFormat == constants.FormatP2PKH:
+		headerBase = constants.CompactSignatureHeaderUncompressed
+	case addressFormat == constants.FormatP2SH && compressed:
+		headerBase = constants.CompactSignatureHeaderP2SHP2WPKH
+	case addressFormat == constants.FormatP2WPKH && compressed:
+		headerBase = constants.CompactSignatureHeaderP2WPKH
+	default:
+		return "", ErrUnsupportedAddressFormat
+	}
+
+	hash := Hash(msg)
+	sig, err := ecc.SignCompact(privateKey, hash[:], headerBase)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// SignWIF signs the given message with a WIF-encoded private key. Whether the public key is compressed is
+// determined by the WIF key. See Sign for the meaning of addressFormat.
+func SignWIF(wifKey, msg string, addressFormat constants.AddressFormat) (string, error) {
+	privateKey, _, compressed, err := wif.Decode(wifKey)
+	if err != nil {
+		return "", err
+	}
+
+	return Sign(privateKey, compressed, msg, addressFormat)
+}
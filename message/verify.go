@@ -0,0 +1,79 @@
+package message
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/kklash/bitcoinlib/address"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/ecc"
+	"github.com/kklash/bitcoinlib/script"
+)
+
+// Verify returns true if the given base64-encoded signature on msg was created by the owner
+// of the given address. The address is decoded using constants.CurrentNetwork.
+//
+// Signatures with P2PKH headers made by compressed keys are accepted for P2SH-P2WPKH and
+// P2WPKH addresses too, as this is the convention used by Electrum. Signatures with segwit
+// headers are only accepted for the specific address format their header indicates.
+//
+// Returns ErrInvalidSignature if the signature cannot be decoded, and an error wrapping
+// address.ErrInvalidAddress if the address cannot be decoded. Returns false with no error
+// if the signature is well-formed but was not made by the owner of the address.
+func Verify(addr, msg, signature string) (bool, error) {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+
+	addressFormat, scriptPubKey, err := address.Decode(addr)
+	if err != nil {
+		return false, err
+	}
+
+	switch addressFormat {
+	case constants.FormatP2PKH, constants.FormatP2SH, constants.FormatP2WPKH:
+	default:
+		return false, ErrUnsupportedAddressFormat
+	}
+
+	hash := Hash(msg)
+	publicKey, headerBase, err := ecc.RecoverCompact(hash[:], sig)
+	if errors.Is(err, ecc.ErrInvalidCompactSignature) {
+		return false, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	} else if err != nil {
+		return false, nil
+	}
+
+	for _, candidate := range candidateScripts(publicKey, headerBase) {
+		if bytes.Equal(candidate, scriptPubKey) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// candidateScripts returns the output scripts which a signature with the given
+// header base value is allowed to prove ownership of.
+func candidateScripts(publicKey []byte, headerBase byte) [][]byte {
+	p2pkh, _ := script.MakeP2PKHFromPublicKey(publicKey)
+
+	if headerBase == constants.CompactSignatureHeaderUncompressed {
+		return [][]byte{p2pkh}
+	}
+
+	p2wpkh, _ := script.MakeP2WPKHFromPublicKey(publicKey)
+	p2shP2wpkh := script.MakeP2SHFromScript(p2wpkh)
+
+	switch headerBase {
+	case constants.CompactSignatureHeaderP2SHP2WPKH:
+		return [][]byte{p2shP2wpkh}
+	case constants.CompactSignatureHeaderP2WPKH:
+		return [][]byte{p2wpkh}
+	default:
+		return [][]byte{p2pkh, p2shP2wpkh, p2wpkh}
+	}
+}
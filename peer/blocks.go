@@ -0,0 +1,97 @@
+package peer
+
+import (
+	"fmt"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/wire"
+)
+
+// GetBlocks downloads the blocks with the given hashes from the remote peer, and returns
+// them in the same order. Witness data is requested if the remote peer advertises
+// wire.ServiceNodeWitness. Returns ErrBlockNotFound if the peer does not have any of the blocks.
+//
+// Each block's transactions are checked against the merkle root in its header, and its witness
+// data against the coinbase witness commitment if witness data was requested, so that a peer
+// cannot alter a block without changing its hash. Returns an error wrapping ErrInvalidBlock if
+// either check fails. The blocks are not otherwise validated.
+func (peer *Peer) GetBlocks(hashes ...[32]byte) ([]*blocks.Block, error) {
+	if len(hashes) > wire.MaxInvCount {
+		return nil, fmt.Errorf("cannot request more than %d blocks at once", wire.MaxInvCount)
+	}
+
+	peer.mutex.Lock()
+	defer peer.mutex.Unlock()
+
+	if err := peer.setDeadline(); err != nil {
+		return nil, err
+	}
+
+	invType := wire.InvTypeBlock
+	if peer.remoteVersion.Services&wire.ServiceNodeWitness != 0 {
+		invType = wire.InvTypeWitnessBlock
+	}
+
+	request := &wire.MsgGetData{Inventory: make([]*wire.InvVect, len(hashes))}
+	pending := make(map[[32]byte][]int, len(hashes))
+	for i, hash := range hashes {
+		request.Inventory[i] = &wire.InvVect{Type: invType, Hash: hash}
+		pending[hash] = append(pending[hash], i)
+	}
+
+	if err := peer.writeMessage(request); err != nil {
+		return nil, err
+	}
+
+	result := make([]*blocks.Block, len(hashes))
+	for len(pending) > 0 {
+		msg, err := peer.readMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		switch msg := msg.(type) {
+		case *wire.MsgBlock:
+			hash, err := msg.Block.Header.Hash()
+			if err != nil {
+				return nil, err
+			}
+
+			indexes, ok := pending[hash]
+			if !ok {
+				continue
+			}
+
+			if err := msg.Block.CheckMerkleRoot(); err != nil {
+				return nil, fmt.Errorf("%w: block %x: %s", ErrInvalidBlock, hash, err)
+			} else if invType == wire.InvTypeWitnessBlock {
+				if err := msg.Block.CheckWitnessData(); err != nil {
+					return nil, fmt.Errorf("%w: block %x: %s", ErrInvalidBlock, hash, err)
+				}
+			}
+
+			for _, i := range indexes {
+				result[i] = msg.Block
+			}
+			delete(pending, hash)
+
+		case *wire.MsgNotFound:
+			for _, inv := range msg.Inventory {
+				if _, ok := pending[inv.Hash]; ok {
+					return nil, fmt.Errorf("%w: %x", ErrBlockNotFound, inv.Hash)
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// GetBlock downloads the block with the given hash from the remote peer.
+func (peer *Peer) GetBlock(hash [32]byte) (*blocks.Block, error) {
+	result, err := peer.GetBlocks(hash)
+	if err != nil {
+		return nil, err
+	}
+	return result[0], nil
+}
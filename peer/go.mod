@@ -0,0 +1,3 @@
+module github.com/kklash/bitcoinlib/peer
+
+go 1.18
@@ -0,0 +1,93 @@
+package peer
+
+import (
+	"fmt"
+
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+	"github.com/kklash/bitcoinlib/wire"
+)
+
+// GetHeaders requests the headers of up to wire.MaxHeadersCount blocks which follow the
+// last block in locator known to the remote peer, stopping at hashStop if it is not zero.
+// The locator should list block hashes from the local chain tip back to genesis.
+func (peer *Peer) GetHeaders(locator [][32]byte, hashStop [32]byte) ([]*blockheader.BlockHeader, error) {
+	peer.mutex.Lock()
+	defer peer.mutex.Unlock()
+
+	if err := peer.setDeadline(); err != nil {
+		return nil, err
+	}
+
+	request := &wire.MsgGetHeaders{
+		Version:      uint32(wire.ProtocolVersion),
+		BlockLocator: locator,
+		HashStop:     hashStop,
+	}
+	if err := peer.writeMessage(request); err != nil {
+		return nil, err
+	}
+
+	for {
+		msg, err := peer.readMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		if headers, ok := msg.(*wire.MsgHeaders); ok {
+			return headers.Headers, nil
+		}
+	}
+}
+
+// SyncHeaders downloads the chain of block headers from the remote peer which follows
+// the given locator, by calling GetHeaders repeatedly until the peer returns fewer than
+// wire.MaxHeadersCount headers. To download the entire header chain, pass a locator
+// containing only the genesis block hash. Returns ErrHeadersNotConnected if the remote
+// peer sends headers which do not connect to the locator or to each other.
+//
+// Headers are not checked for proof-of-work.
+func (peer *Peer) SyncHeaders(locator [][32]byte) ([]*blockheader.BlockHeader, error) {
+	var chain []*blockheader.BlockHeader
+
+	for {
+		headers, err := peer.GetHeaders(locator, [32]byte{})
+		if err != nil {
+			return nil, err
+		} else if len(headers) == 0 {
+			return chain, nil
+		}
+
+		if len(chain) == 0 {
+			if !locatorContains(locator, headers[0].PreviousHeaderHash) {
+				return nil, fmt.Errorf("%w: first header does not connect to locator", ErrHeadersNotConnected)
+			}
+		}
+
+		var lastHash [32]byte
+		for i, header := range headers {
+			if i > 0 && header.PreviousHeaderHash != lastHash {
+				return nil, fmt.Errorf("%w: header %d does not connect", ErrHeadersNotConnected, len(chain)+i)
+			}
+
+			if lastHash, err = header.Hash(); err != nil {
+				return nil, err
+			}
+		}
+
+		chain = append(chain, headers...)
+		if len(headers) < wire.MaxHeadersCount {
+			return chain, nil
+		}
+
+		locator = [][32]byte{lastHash}
+	}
+}
+
+func locatorContains(locator [][32]byte, hash [32]byte) bool {
+	for _, h := range locator {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,298 @@
+// Package peer provides a lightweight client for the Bitcoin peer-to-peer network, which can
+// download block headers and blocks directly from a node over TCP, without RPC credentials.
+package peer
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/kklash/bitcoinlib/wire"
+)
+
+// MinProtocolVersion is the oldest protocol version of remote peers accepted
+// by the handshake. It is the first version to support getheaders.
+const MinProtocolVersion int32 = 31800
+
+// DefaultTimeout is the timeout used for each operation when Config.Timeout is zero.
+const DefaultTimeout = 30 * time.Second
+
+var (
+	// ErrMissingServices is returned by the handshake if the remote peer does
+	// not advertise all the service flags in Config.RequiredServices.
+	ErrMissingServices = errors.New("peer does not offer required services")
+
+	// ErrProtocolVersionTooLow is returned by the handshake if the remote
+	// peer's protocol version is older than MinProtocolVersion.
+	ErrProtocolVersionTooLow = errors.New("peer protocol version is too old")
+
+	// ErrSelfConnection is returned by the handshake if the remote peer is
+	// actually the local peer, as detected by the version message nonce.
+	ErrSelfConnection = errors.New("connected to self")
+
+	// ErrHandshakeFailed is returned if the remote peer sends an unexpected
+	// message before the version handshake is complete.
+	ErrHandshakeFailed = errors.New("version handshake failed")
+
+	// ErrBlockNotFound is returned by GetBlocks if the remote peer does not have a requested block.
+	ErrBlockNotFound = errors.New("peer does not have requested block")
+
+	// ErrInvalidBlock is returned by GetBlocks if the remote peer sends a block whose
+	// transactions or witness data are not those committed to by its header.
+	ErrInvalidBlock = errors.New("peer sent block which does not match its header")
+
+	// ErrHeadersNotConnected is returned by SyncHeaders if the remote peer
+	// sends headers which do not form a chain.
+	ErrHeadersNotConnected = errors.New("received headers do not connect")
+)
+
+// Config holds options for connecting to a remote peer.
+type Config struct {
+	// Magic is the network magic, such as wire.MagicMainnet.
+	Magic uint32
+
+	// UserAgent is sent to the remote peer in the version message.
+	UserAgent string
+
+	// Services is the bit field of services advertised to the remote peer.
+	Services uint64
+
+	// RequiredServices is the bit field of services which the remote peer must advertise.
+	RequiredServices uint64
+
+	// StartHeight is the height of the local peer's best block, sent in the version message.
+	StartHeight int32
+
+	// Relay indicates whether the remote peer should announce new transactions.
+	Relay bool
+
+	// Timeout is the maximum time allowed for the handshake and each request.
+	// If zero, DefaultTimeout is used.
+	Timeout time.Duration
+}
+
+// DefaultConfig returns a Config for a non-relaying client on the given network,
+// which requires peers to serve full witness blocks.
+func DefaultConfig(magic uint32) *Config {
+	return &Config{
+		Magic:            magic,
+		UserAgent:        "/bitcoinlib:0.1/",
+		RequiredServices: wire.ServiceNodeNetwork | wire.ServiceNodeWitness,
+	}
+}
+
+// Peer is a connection to a remote peer on which the version handshake has been completed.
+// Messages from the remote peer are only read while a method of Peer is waiting for a reply;
+// ping messages received in the meantime are answered automatically. A Peer's methods
+// are safe for concurrent use, but requests are processed one at a time.
+type Peer struct {
+	conn   net.Conn
+	config Config
+	nonce  uint64
+	mutex  sync.Mutex
+
+	remoteVersion *wire.MsgVersion
+	sendAddrV2    bool
+	wtxidRelay    bool
+}
+
+// Connect opens a TCP connection to the given address and performs the version
+// handshake. If config is nil, DefaultConfig(wire.MagicMainnet) is used.
+func Connect(address string, config *Config) (*Peer, error) {
+	if config == nil {
+		config = DefaultConfig(wire.MagicMainnet)
+	}
+
+	conn, err := net.DialTimeout("tcp", address, timeout(config))
+	if err != nil {
+		return nil, err
+	}
+
+	peer, err := NewPeer(conn, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return peer, nil
+}
+
+// NewPeer performs the version handshake on an existing connection to a remote peer.
+// If config is nil, DefaultConfig(wire.MagicMainnet) is used. The caller is responsible
+// for closing conn if the handshake fails.
+func NewPeer(conn net.Conn, config *Config) (*Peer, error) {
+	if config == nil {
+		config = DefaultConfig(wire.MagicMainnet)
+	}
+
+	nonceBytes := make([]byte, 8)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return nil, err
+	}
+
+	peer := &Peer{
+		conn:   conn,
+		config: *config,
+		nonce:  binary.LittleEndian.Uint64(nonceBytes),
+	}
+
+	if err := peer.handshake(); err != nil {
+		return nil, err
+	}
+
+	return peer, nil
+}
+
+func timeout(config *Config) time.Duration {
+	if config.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return config.Timeout
+}
+
+// setDeadline sets the deadline for reads and writes on the connection for the next operation.
+func (peer *Peer) setDeadline() error {
+	return peer.conn.SetDeadline(time.Now().Add(timeout(&peer.config)))
+}
+
+func (peer *Peer) handshake() error {
+	if err := peer.setDeadline(); err != nil {
+		return err
+	}
+
+	localVersion := &wire.MsgVersion{
+		Version:     wire.ProtocolVersion,
+		Services:    peer.config.Services,
+		Timestamp:   time.Now().Unix(),
+		AddrRecv:    netAddress(peer.conn.RemoteAddr()),
+		AddrFrom:    wire.NetAddress{Services: peer.config.Services},
+		Nonce:       peer.nonce,
+		UserAgent:   peer.config.UserAgent,
+		StartHeight: peer.config.StartHeight,
+		Relay:       peer.config.Relay,
+	}
+	if err := peer.writeMessage(localVersion); err != nil {
+		return err
+	}
+
+	gotVerack := false
+	for peer.remoteVersion == nil || !gotVerack {
+		msg, err := peer.readMessage()
+		if err != nil {
+			return err
+		}
+
+		switch msg := msg.(type) {
+		case *wire.MsgVersion:
+			if peer.remoteVersion != nil {
+				return fmt.Errorf("%w: received duplicate version message", ErrHandshakeFailed)
+			} else if err := peer.checkRemoteVersion(msg); err != nil {
+				return err
+			}
+			peer.remoteVersion = msg
+
+			// Feature negotiation messages must be sent between version and verack.
+			if msg.Version >= wire.ProtocolVersion {
+				if err := peer.writeMessage(new(wire.MsgWtxidRelay)); err != nil {
+					return err
+				}
+			}
+			if err := peer.writeMessage(new(wire.MsgSendAddrV2)); err != nil {
+				return err
+			}
+			if err := peer.writeMessage(new(wire.MsgVerack)); err != nil {
+				return err
+			}
+
+		case *wire.MsgVerack:
+			if peer.remoteVersion == nil {
+				return fmt.Errorf("%w: received verack before version", ErrHandshakeFailed)
+			}
+			gotVerack = true
+
+		case *wire.MsgWtxidRelay:
+			peer.wtxidRelay = true
+
+		case *wire.MsgSendAddrV2:
+			peer.sendAddrV2 = true
+
+		case *wire.MsgUnknown:
+			// Unknown feature negotiation messages are ignored, as specified in BIP339.
+
+		default:
+			if peer.remoteVersion == nil {
+				return fmt.Errorf("%w: received %s message before version", ErrHandshakeFailed, msg.Command())
+			}
+		}
+	}
+
+	return nil
+}
+
+func (peer *Peer) checkRemoteVersion(remote *wire.MsgVersion) error {
+	if remote.Nonce == peer.nonce {
+		return ErrSelfConnection
+	} else if remote.Version < MinProtocolVersion {
+		return fmt.Errorf("%w: %d < %d", ErrProtocolVersionTooLow, remote.Version, MinProtocolVersion)
+	} else if remote.Services&peer.config.RequiredServices != peer.config.RequiredServices {
+		return fmt.Errorf(
+			"%w: wanted %#x, peer offers %#x",
+			ErrMissingServices, peer.config.RequiredServices, remote.Services,
+		)
+	}
+	return nil
+}
+
+func netAddress(addr net.Addr) wire.NetAddress {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return wire.NetAddress{IP: tcpAddr.IP, Port: uint16(tcpAddr.Port)}
+	}
+	return wire.NetAddress{}
+}
+
+// RemoteVersion returns the version message sent by the remote peer during the handshake.
+func (peer *Peer) RemoteVersion() *wire.MsgVersion {
+	return peer.remoteVersion
+}
+
+// SupportsAddrV2 returns true if the remote peer signaled support for addrv2 messages.
+func (peer *Peer) SupportsAddrV2() bool {
+	return peer.sendAddrV2
+}
+
+// SupportsWtxidRelay returns true if the remote peer signaled support for wtxid relay.
+func (peer *Peer) SupportsWtxidRelay() bool {
+	return peer.wtxidRelay
+}
+
+// Close closes the connection to the remote peer.
+func (peer *Peer) Close() error {
+	return peer.conn.Close()
+}
+
+func (peer *Peer) writeMessage(msg wire.Message) error {
+	return wire.WriteMessage(peer.conn, peer.config.Magic, msg)
+}
+
+// readMessage reads the next message from the remote peer, answering any pings.
+func (peer *Peer) readMessage() (wire.Message, error) {
+	for {
+		msg, err := wire.ReadMessage(peer.conn, peer.config.Magic)
+		if err != nil {
+			return nil, err
+		}
+
+		switch msg := msg.(type) {
+		case *wire.MsgPing:
+			if err := peer.writeMessage(&wire.MsgPong{Nonce: msg.Nonce}); err != nil {
+				return nil, err
+			}
+		default:
+			return msg, nil
+		}
+	}
+}
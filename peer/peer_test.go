@@ -0,0 +1,372 @@
+package peer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/wire"
+)
+
+const genesisBlockHex = "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c0101000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+// fakePeer is an in-process remote peer which serves a chain of blocks.
+type fakePeer struct {
+	listener net.Listener
+	services uint64
+	version  int32
+	chain    []*blocks.Block
+	hashes   [][32]byte
+
+	// pongs receives the nonces of pong messages sent by the client.
+	pongs chan uint64
+}
+
+// newFakePeer creates a fakePeer serving the genesis block, followed by
+// nBlocks empty blocks which reuse the genesis coinbase transaction.
+func newFakePeer(t *testing.T, nBlocks int, services uint64) *fakePeer {
+	genesisBytes, _ := hex.DecodeString(genesisBlockHex)
+	genesis, err := blocks.FromReader(bytes.NewReader(genesisBytes))
+	if err != nil {
+		t.Fatalf("failed to decode genesis block: %s", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+
+	fake := &fakePeer{
+		listener: listener,
+		services: services,
+		version:  wire.ProtocolVersion,
+		pongs:    make(chan uint64, 16),
+	}
+
+	block := genesis
+	for i := 0; i <= nBlocks; i++ {
+		hash, err := block.Header.Hash()
+		if err != nil {
+			t.Fatalf("failed to hash block: %s", err)
+		}
+
+		fake.chain = append(fake.chain, block)
+		fake.hashes = append(fake.hashes, hash)
+
+		header := *block.Header
+		header.PreviousHeaderHash = hash
+		header.Time++
+		block = &blocks.Block{Header: &header, Transactions: genesis.Transactions}
+	}
+
+	return fake
+}
+
+func (fake *fakePeer) addr() string {
+	return fake.listener.Addr().String()
+}
+
+func (fake *fakePeer) close() {
+	fake.listener.Close()
+}
+
+func (fake *fakePeer) indexOf(hash [32]byte) int {
+	for i, h := range fake.hashes {
+		if h == hash {
+			return i
+		}
+	}
+	return -1
+}
+
+// serve accepts a single connection and responds to the client's messages until it disconnects.
+func (fake *fakePeer) serve() {
+	conn, err := fake.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	send := func(msg wire.Message) {
+		wire.WriteMessage(conn, wire.MagicRegtest, msg)
+	}
+
+	for {
+		msg, err := wire.ReadMessage(conn, wire.MagicRegtest)
+		if err != nil {
+			return
+		}
+
+		switch msg := msg.(type) {
+		case *wire.MsgVersion:
+			send(&wire.MsgVersion{
+				Version:     fake.version,
+				Services:    fake.services,
+				Nonce:       msg.Nonce + 1,
+				UserAgent:   "/fake:0.1/",
+				StartHeight: int32(len(fake.chain) - 1),
+			})
+			send(new(wire.MsgWtxidRelay))
+			send(new(wire.MsgSendAddrV2))
+			send(new(wire.MsgVerack))
+
+		case *wire.MsgPong:
+			fake.pongs <- msg.Nonce
+
+		case *wire.MsgGetHeaders:
+			// Ping the client before responding, to check it answers pings while waiting.
+			send(&wire.MsgPing{Nonce: 0xc0ffee})
+
+			start := 0
+			for _, hash := range msg.BlockLocator {
+				if i := fake.indexOf(hash); i >= 0 {
+					start = i + 1
+					break
+				}
+			}
+
+			response := new(wire.MsgHeaders)
+			for i := start; i < len(fake.chain) && len(response.Headers) < wire.MaxHeadersCount; i++ {
+				response.Headers = append(response.Headers, fake.chain[i].Header)
+			}
+			send(response)
+
+		case *wire.MsgGetData:
+			notFound := new(wire.MsgNotFound)
+			for _, inv := range msg.Inventory {
+				if i := fake.indexOf(inv.Hash); i >= 0 {
+					send(&wire.MsgBlock{Block: fake.chain[i]})
+				} else {
+					notFound.Inventory = append(notFound.Inventory, inv)
+				}
+			}
+			if len(notFound.Inventory) > 0 {
+				send(notFound)
+			}
+		}
+	}
+}
+
+func testConfig() *Config {
+	config := DefaultConfig(wire.MagicRegtest)
+	config.Timeout = 5 * time.Second
+	return config
+}
+
+func TestConnect(t *testing.T) {
+	fake := newFakePeer(t, 0, wire.ServiceNodeNetwork|wire.ServiceNodeWitness)
+	defer fake.close()
+	go fake.serve()
+
+	peer, err := Connect(fake.addr(), testConfig())
+	if err != nil {
+		t.Errorf("failed to connect to peer: %s", err)
+		return
+	}
+	defer peer.Close()
+
+	if peer.RemoteVersion().UserAgent != "/fake:0.1/" {
+		t.Errorf("unexpected remote user agent: %s", peer.RemoteVersion().UserAgent)
+		return
+	}
+
+	if !peer.SupportsWtxidRelay() || !peer.SupportsAddrV2() {
+		t.Errorf("expected peer to negotiate wtxidrelay and addrv2")
+		return
+	}
+}
+
+func TestConnect_MissingServices(t *testing.T) {
+	fake := newFakePeer(t, 0, wire.ServiceNodeNetworkLimited)
+	defer fake.close()
+	go fake.serve()
+
+	_, err := Connect(fake.addr(), testConfig())
+	if !errors.Is(err, ErrMissingServices) {
+		t.Errorf("expected ErrMissingServices, got %v", err)
+		return
+	}
+}
+
+func TestConnect_ProtocolVersionTooLow(t *testing.T) {
+	fake := newFakePeer(t, 0, wire.ServiceNodeNetwork|wire.ServiceNodeWitness)
+	fake.version = 209
+	defer fake.close()
+	go fake.serve()
+
+	_, err := Connect(fake.addr(), testConfig())
+	if !errors.Is(err, ErrProtocolVersionTooLow) {
+		t.Errorf("expected ErrProtocolVersionTooLow, got %v", err)
+		return
+	}
+}
+
+func TestPeer_SyncHeaders(t *testing.T) {
+	nBlocks := wire.MaxHeadersCount + 500
+	fake := newFakePeer(t, nBlocks, wire.ServiceNodeNetwork|wire.ServiceNodeWitness)
+	defer fake.close()
+	go fake.serve()
+
+	peer, err := Connect(fake.addr(), testConfig())
+	if err != nil {
+		t.Errorf("failed to connect to peer: %s", err)
+		return
+	}
+	defer peer.Close()
+
+	headers, err := peer.SyncHeaders([][32]byte{fake.hashes[0]})
+	if err != nil {
+		t.Errorf("failed to sync headers: %s", err)
+		return
+	}
+
+	if len(headers) != nBlocks {
+		t.Errorf("expected %d headers, got %d", nBlocks, len(headers))
+		return
+	}
+
+	for i, header := range headers {
+		if header.PreviousHeaderHash != fake.hashes[i] {
+			t.Errorf("header %d does not match fake chain", i+1)
+			return
+		}
+	}
+
+	select {
+	case nonce := <-fake.pongs:
+		if nonce != 0xc0ffee {
+			t.Errorf("expected pong with nonce %x, got %x", 0xc0ffee, nonce)
+			return
+		}
+	case <-time.After(time.Second):
+		t.Errorf("client did not answer ping")
+		return
+	}
+
+	// Syncing from the tip should return no more headers.
+	headers, err = peer.SyncHeaders([][32]byte{fake.hashes[nBlocks]})
+	if err != nil {
+		t.Errorf("failed to sync headers from tip: %s", err)
+		return
+	} else if len(headers) != 0 {
+		t.Errorf("expected no headers after tip, got %d", len(headers))
+		return
+	}
+}
+
+func TestPeer_SyncHeaders_NotConnected(t *testing.T) {
+	fake := newFakePeer(t, 10, wire.ServiceNodeNetwork|wire.ServiceNodeWitness)
+	defer fake.close()
+
+	// Break the link between blocks 5 and 6.
+	brokenHeader := *fake.chain[6].Header
+	brokenHeader.PreviousHeaderHash = [32]byte{1}
+	fake.chain[6] = &blocks.Block{Header: &brokenHeader}
+	go fake.serve()
+
+	peer, err := Connect(fake.addr(), testConfig())
+	if err != nil {
+		t.Errorf("failed to connect to peer: %s", err)
+		return
+	}
+	defer peer.Close()
+
+	_, err = peer.SyncHeaders([][32]byte{fake.hashes[0]})
+	if !errors.Is(err, ErrHeadersNotConnected) {
+		t.Errorf("expected ErrHeadersNotConnected, got %v", err)
+		return
+	}
+}
+
+func TestPeer_GetBlocks(t *testing.T) {
+	fake := newFakePeer(t, 5, wire.ServiceNodeNetwork|wire.ServiceNodeWitness)
+	defer fake.close()
+	go fake.serve()
+
+	peer, err := Connect(fake.addr(), testConfig())
+	if err != nil {
+		t.Errorf("failed to connect to peer: %s", err)
+		return
+	}
+	defer peer.Close()
+
+	genesis, err := peer.GetBlock(fake.hashes[0])
+	if err != nil {
+		t.Errorf("failed to get genesis block: %s", err)
+		return
+	} else if hex.EncodeToString(genesis.Bytes()) != genesisBlockHex {
+		t.Errorf("genesis block does not match\nWanted %s\nGot    %x", genesisBlockHex, genesis.Bytes())
+		return
+	}
+
+	hashes := [][32]byte{fake.hashes[4], fake.hashes[2], fake.hashes[4]}
+	result, err := peer.GetBlocks(hashes...)
+	if err != nil {
+		t.Errorf("failed to get blocks: %s", err)
+		return
+	}
+
+	for i, block := range result {
+		if block == nil {
+			t.Errorf("block %d was not returned", i)
+			return
+		}
+
+		hash, _ := block.Header.Hash()
+		if hash != hashes[i] {
+			t.Errorf("block %d returned out of order", i)
+			return
+		}
+	}
+
+	_, err = peer.GetBlocks(fake.hashes[1], [32]byte{0xff})
+	if !errors.Is(err, ErrBlockNotFound) {
+		t.Errorf("expected ErrBlockNotFound, got %v", err)
+		return
+	}
+}
+
+func TestPeer_GetBlocks_Invalid(t *testing.T) {
+	fake := newFakePeer(t, 2, wire.ServiceNodeNetwork|wire.ServiceNodeWitness)
+	defer fake.close()
+
+	// Block 1 has the transactions of another block, and block 2 has
+	// witness data which is not committed to by its coinbase.
+	coinbase := fake.chain[0].Transactions[0]
+	altered := coinbase.Clone()
+	altered.Outputs[0].Value--
+	fake.chain[1] = &blocks.Block{Header: fake.chain[1].Header, Transactions: []*tx.Tx{altered}}
+
+	malleated := coinbase.Clone()
+	malleated.Witnesses = []tx.Witness{{make([]byte, 32)}}
+	fake.chain[2] = &blocks.Block{Header: fake.chain[2].Header, Transactions: []*tx.Tx{malleated}}
+
+	go fake.serve()
+
+	peer, err := Connect(fake.addr(), testConfig())
+	if err != nil {
+		t.Errorf("failed to connect to peer: %s", err)
+		return
+	}
+	defer peer.Close()
+
+	if _, err := peer.GetBlock(fake.hashes[1]); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("expected ErrInvalidBlock for block with altered transactions, got %v", err)
+		return
+	}
+
+	if _, err := peer.GetBlock(fake.hashes[2]); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("expected ErrInvalidBlock for block with uncommitted witness data, got %v", err)
+		return
+	}
+
+	if _, err := peer.GetBlock(fake.hashes[0]); err != nil {
+		t.Errorf("failed to get valid block after invalid ones: %s", err)
+		return
+	}
+}
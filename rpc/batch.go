@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// BatchRequest is a single method call sent as part of a JSON-RPC batch with conn.RequestBatch.
+type BatchRequest struct {
+	Method string
+	Params []any
+
+	// Result is a pointer into which the call's result is decoded. If nil, RequestBatch
+	// sets it to a new *any, into which the result is decoded.
+	Result any
+
+	// Err is set by conn.RequestBatch if the node returned an error for this call,
+	// or if the call's result could not be decoded.
+	Err error
+}
+
+// NewBatchRequest returns a BatchRequest which calls the given method, and
+// decodes the call's result into resultPtr.
+func NewBatchRequest(resultPtr any, method string, params ...any) *BatchRequest {
+	return &BatchRequest{
+		Method: method,
+		Params: params,
+		Result: resultPtr,
+	}
+}
+
+// RequestBatch sends all of the given requests to the node in a single JSON-RPC batch. The
+// node executes the calls in order, but each call succeeds or fails independently. The
+// returned error is only non-nil if the batch as a whole failed. Errors from individual
+// calls are stored in the Err field of each BatchRequest. Use BatchError to return the
+// first of those errors.
+func (conn *Connection) RequestBatch(ctx context.Context, requests ...*BatchRequest) error {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	messages := make([]*rpcRequestMessage, len(requests))
+	requestsByID := make(map[int]*BatchRequest, len(requests))
+	unanswered := make(map[int]bool, len(requests))
+
+	for i, request := range requests {
+		messages[i] = conn.newRequestMessage(request.Method, request.Params)
+		id := messages[i].ID
+
+		if request.Result == nil {
+			request.Result = new(any)
+		}
+		request.Err = nil
+
+		requestsByID[id] = request
+		unanswered[id] = true
+	}
+
+	bodyBytes, statusCode, err := conn.post(ctx, messages)
+	if err != nil {
+		return err
+	}
+
+	var rawResponses []json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &rawResponses); err != nil {
+		if statusCode != http.StatusOK {
+			return fmt.Errorf("%w: %s", ErrInvalidResponseFormat, bodyBytes)
+		}
+
+		return fmt.Errorf("%w: %s", ErrInvalidResponseFormat, err)
+	}
+
+	for _, rawResponse := range rawResponses {
+		var header struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(rawResponse, &header); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidResponseFormat, err)
+		}
+
+		request, ok := requestsByID[header.ID]
+		if !ok {
+			return fmt.Errorf("%w: unexpected response ID %d in batch", ErrInvalidResponseFormat, header.ID)
+		}
+
+		responseObj := &rpcResponseMessage{Result: request.Result}
+		if err := json.Unmarshal(rawResponse, responseObj); err != nil {
+			request.Err = fmt.Errorf("%w: %s", ErrInvalidResponseFormat, err)
+		} else if responseObj.Error != nil {
+			request.Err = responseObj.Error
+		} else if responseObj.Result == nil {
+			request.Err = ErrInvalidResponseFormat
+		}
+
+		delete(unanswered, header.ID)
+	}
+
+	for id := range unanswered {
+		requestsByID[id].Err = fmt.Errorf("%w: no response for %s call in batch", ErrInvalidResponseFormat, requestsByID[id].Method)
+	}
+
+	return nil
+}
+
+// BatchError returns the first non-nil Err among the given requests, or nil if they all succeeded.
+func BatchError(requests ...*BatchRequest) error {
+	for _, request := range requests {
+		if request.Err != nil {
+			return request.Err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package rpc
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kklash/bitcoinlib/blocks"
+)
+
+func TestConnection_RequestBatch(t *testing.T) {
+	ctx := context.Background()
+
+	genesis, err := blocks.FromReader(hex.NewDecoder(strings.NewReader(genesisBlockHex)))
+	if err != nil {
+		t.Fatalf("failed to decode genesis block: %s", err)
+	}
+	coinbase := genesis.Transactions[0]
+
+	conn := newFakeBitcoind(t, map[string]fakeMethod{
+		"getblockhash": func(params []any) (any, *ErrRPCFailure) {
+			if params[0] != float64(0) {
+				return nil, &ErrRPCFailure{Code: -8, Message: "Block height out of range"}
+			}
+			return genesisBlockHash, nil
+		},
+		"getblock": func(params []any) (any, *ErrRPCFailure) {
+			if params[0] != genesisBlockHash {
+				return nil, &ErrRPCFailure{Code: -5, Message: "Block not found"}
+			}
+			return genesisBlockHex, nil
+		},
+		"getblockcount": func([]any) (any, *ErrRPCFailure) {
+			return 0, nil
+		},
+		"getrawtransaction": func(params []any) (any, *ErrRPCFailure) {
+			if params[0] == genesisBlockHash {
+				return nil, &ErrRPCFailure{Code: -8, Message: "The genesis block coinbase is not considered an ordinary transaction"}
+			} else if params[0] != genesisTxid {
+				return nil, &ErrRPCFailure{Code: -5, Message: "No such mempool or blockchain transaction"}
+			}
+			return coinbase.Hex(), nil
+		},
+	})
+
+	var (
+		hashHex    string
+		blockCount int
+	)
+	requests := []*BatchRequest{
+		NewBatchRequest(&hashHex, "getblockhash", 0),
+		NewBatchRequest(&blockCount, "getblockcount"),
+		NewBatchRequest(nil, "getblockhash", 1),
+		NewBatchRequest(nil, "getblock", genesisBlockHash, 0),
+		NewBatchRequest(nil, "notamethod"),
+	}
+
+	if err := conn.RequestBatch(ctx, requests...); err != nil {
+		t.Errorf("failed to send batch: %s", err)
+		return
+	}
+
+	if requests[0].Err != nil || hashHex != genesisBlockHash {
+		t.Errorf("incorrect getblockhash result: %q, %v", hashHex, requests[0].Err)
+		return
+	} else if requests[1].Err != nil || blockCount != 0 {
+		t.Errorf("incorrect getblockcount result: %d, %v", blockCount, requests[1].Err)
+		return
+	} else if !errors.Is(requests[2].Err, ErrorsByCode[-8]) {
+		t.Errorf("expected invalid parameter error, got %v", requests[2].Err)
+		return
+	} else if requests[3].Err != nil || *requests[3].Result.(*any) != genesisBlockHex {
+		t.Errorf("incorrect getblock result: %v", requests[3].Err)
+		return
+	} else if rpcErr, ok := requests[4].Err.(*ErrRPCFailure); !ok || rpcErr.Code != -32601 {
+		t.Errorf("expected method not found error, got %v", requests[4].Err)
+		return
+	}
+
+	if err := BatchError(requests...); err != requests[2].Err {
+		t.Errorf("expected BatchError to return first failed call's error, got %v", err)
+		return
+	}
+
+	blockHashes, err := conn.GetBlockHashes(ctx, 0, 0)
+	if err != nil {
+		t.Errorf("failed to get block hashes: %s", err)
+		return
+	} else if len(blockHashes) != 2 || blockHashes[0] != hex2hash(genesisBlockHash) || blockHashes[1] != blockHashes[0] {
+		t.Errorf("incorrect block hashes: %x", blockHashes)
+		return
+	}
+
+	blockList, err := conn.GetBlocks(ctx, blockHashes...)
+	if err != nil {
+		t.Errorf("failed to get blocks: %s", err)
+		return
+	} else if len(blockList) != 2 {
+		t.Errorf("incorrect number of blocks: %d", len(blockList))
+		return
+	}
+	for _, block := range blockList {
+		if hash, _ := block.Header.Hash(); hash != blockHashes[0] {
+			t.Errorf("incorrect block returned: %x", hash)
+			return
+		}
+	}
+
+	txns, err := conn.GetRawTransactions(ctx, hex2hash(genesisTxid), [32]byte{1}, hex2hash(genesisTxid))
+	if err != nil {
+		t.Errorf("failed to get transactions: %s", err)
+		return
+	} else if len(txns) != 3 || !reflect.DeepEqual(txns[0], coinbase) || txns[1] != nil || !reflect.DeepEqual(txns[2], coinbase) {
+		t.Errorf("incorrect transactions returned: %v", txns)
+		return
+	}
+
+	if _, err := conn.GetRawTransactions(ctx, hex2hash(genesisTxid), hex2hash(genesisBlockHash)); !errors.Is(err, ErrorsByCode[-8]) {
+		t.Errorf("expected invalid parameter error, got %v", err)
+		return
+	}
+
+	if _, err := conn.GetBlockHashes(ctx, 0, 1); !errors.Is(err, ErrorsByCode[-8]) {
+		t.Errorf("expected invalid parameter error, got %v", err)
+		return
+	}
+
+	if err := conn.RequestBatch(ctx); err != nil {
+		t.Errorf("empty batch should succeed, got %s", err)
+		return
+	}
+}
+
+func TestConnection_RequestBatch_MissingResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id": 0, "result": 1, "error": null}]`))
+	}))
+	defer server.Close()
+
+	conn, err := NewConnection(server.URL, "user", "pass")
+	if err != nil {
+		t.Errorf("failed to create connection: %s", err)
+		return
+	}
+
+	var first, second int
+	requests := []*BatchRequest{
+		NewBatchRequest(&first, "getblockcount"),
+		NewBatchRequest(&second, "getblockcount"),
+	}
+	if err := conn.RequestBatch(context.Background(), requests...); err != nil {
+		t.Errorf("failed to send batch: %s", err)
+		return
+	}
+
+	if requests[0].Err != nil || first != 1 {
+		t.Errorf("incorrect first result: %d, %v", first, requests[0].Err)
+		return
+	} else if !errors.Is(requests[1].Err, ErrInvalidResponseFormat) {
+		t.Errorf("expected ErrInvalidResponseFormat for missing response, got %v", requests[1].Err)
+		return
+	}
+}
+
+// newBusyBitcoind returns a Connection to a server which rejects the first
+// nBusy requests as if its RPC work queue were full.
+func newBusyBitcoind(t *testing.T, nBusy int32) (*Connection, *int32) {
+	var nRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&nRequests, 1) <= nBusy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(workQueueExceededMessage))
+			return
+		}
+		w.Write([]byte(`{"id": 0, "result": 800000, "error": null}`))
+	}))
+	t.Cleanup(server.Close)
+
+	conn, err := NewConnection(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("failed to create connection: %s", err)
+	}
+	conn.InitialBackoff = time.Millisecond
+	conn.MaxBackoff = 4 * time.Millisecond
+	conn.MaxRetries = 5
+
+	return conn, &nRequests
+}
+
+func TestConnection_Backoff(t *testing.T) {
+	conn, nRequests := newBusyBitcoind(t, 5)
+
+	var blockCount int
+	if err := conn.RequestSetResult(&blockCount, "getblockcount"); err != nil {
+		t.Errorf("failed to request after retries: %s", err)
+		return
+	} else if blockCount != 800000 {
+		t.Errorf("incorrect block count: %d", blockCount)
+		return
+	} else if *nRequests != 6 {
+		t.Errorf("expected 6 requests, got %d", *nRequests)
+		return
+	}
+
+	conn, nRequests = newBusyBitcoind(t, 6)
+	if err := conn.RequestSetResult(&blockCount, "getblockcount"); !errors.Is(err, ErrWorkQueueExceeded) {
+		t.Errorf("expected ErrWorkQueueExceeded, got %v", err)
+		return
+	} else if *nRequests != 6 {
+		t.Errorf("expected 6 requests, got %d", *nRequests)
+		return
+	}
+
+	conn, _ = newBusyBitcoind(t, 1000)
+	conn.MaxRetries = 1000
+	conn.InitialBackoff = time.Hour
+	conn.MaxBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := conn.RequestSetResultContext(ctx, &blockCount, "getblockcount"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded during backoff, got %v", err)
+		return
+	}
+}
+
+func TestConnection_RequestContext(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	conn, err := NewConnection(server.URL, "user", "pass")
+	if err != nil {
+		t.Errorf("failed to create connection: %s", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	if _, err := conn.RequestContext(ctx, "getblockcount"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+		return
+	}
+
+	if err := conn.RequestBatch(ctx, NewBatchRequest(nil, "getblockcount")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from batch, got %v", err)
+		return
+	}
+}
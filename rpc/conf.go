@@ -0,0 +1,177 @@
+package rpc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrInvalidConfigFile is returned when a bitcoin.conf file cannot be parsed.
+var ErrInvalidConfigFile = errors.New("bitcoin.conf file not formatted correctly")
+
+// Chain identifies which block chain a node runs on, using the names accepted
+// by the -chain option, and used for bitcoin.conf section names.
+type Chain string
+
+const (
+	ChainMain     Chain = "main"
+	ChainTestnet3 Chain = "test"
+	ChainTestnet4 Chain = "testnet4"
+	ChainSignet   Chain = "signet"
+	ChainRegtest  Chain = "regtest"
+)
+
+// DataDirSubdir returns the subdirectory of the node's data directory in which
+// it stores data for the chain, including the RPC cookie file. It is empty for mainnet.
+func (chain Chain) DataDirSubdir() string {
+	switch chain {
+	case ChainMain:
+		return ""
+	case ChainTestnet3:
+		return "testnet3"
+	default:
+		return string(chain)
+	}
+}
+
+// DefaultRPCPort returns the port on which the node's RPC server listens by default for the chain.
+func (chain Chain) DefaultRPCPort() int {
+	switch chain {
+	case ChainTestnet3:
+		return 18332
+	case ChainTestnet4:
+		return 48332
+	case ChainSignet:
+		return 38332
+	case ChainRegtest:
+		return 18443
+	default:
+		return 8332
+	}
+}
+
+// networkOnlyOptions are options which bitcoind ignores in the default section of bitcoin.conf
+// unless running on mainnet. For other chains they must be set in the chain's section.
+var networkOnlyOptions = map[string]bool{
+	"addnode":   true,
+	"bind":      true,
+	"connect":   true,
+	"port":      true,
+	"rpcbind":   true,
+	"rpcport":   true,
+	"wallet":    true,
+	"whitebind": true,
+}
+
+// Config holds the options parsed from a bitcoin.conf file.
+type Config struct {
+	// sections maps section names to option names to values, in the order
+	// they appear in the file. The default section has an empty name.
+	sections map[string]map[string][]string
+}
+
+// ParseConfig parses a bitcoin.conf file from the given reader. Options in a [section] apply only
+// to the chain with that name. Options in the default section may also be prefixed with a chain
+// name, as in "regtest.rpcport=18443".
+func ParseConfig(reader io.Reader) (*Config, error) {
+	conf := &Config{sections: make(map[string]map[string][]string)}
+	section := ""
+
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			continue
+		} else if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("%w: line %d has no '='", ErrInvalidConfigFile, lineNumber)
+		}
+		key = strings.TrimPrefix(strings.TrimSpace(key), "-")
+		value = strings.TrimSpace(value)
+
+		keySection := section
+		if section == "" {
+			if prefix, name, ok := strings.Cut(key, "."); ok {
+				keySection, key = prefix, name
+			}
+		}
+
+		if conf.sections[keySection] == nil {
+			conf.sections[keySection] = make(map[string][]string)
+		}
+		conf.sections[keySection][key] = append(conf.sections[keySection][key], value)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// ReadConfigFile parses the bitcoin.conf file at the given path.
+func ReadConfigFile(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseConfig(file)
+}
+
+// Get returns the value of the given option for the given chain. Values in the chain's section
+// take precedence over the default section, and if an option is set more than once in a
+// section, the first value is used, as bitcoind does.
+func (conf *Config) Get(chain Chain, key string) (string, bool) {
+	if values := conf.sections[string(chain)][key]; len(values) > 0 {
+		return values[0], true
+	}
+
+	if chain != ChainMain && networkOnlyOptions[key] {
+		return "", false
+	}
+
+	if values := conf.sections[""][key]; len(values) > 0 {
+		return values[0], true
+	}
+
+	return "", false
+}
+
+// Chain returns the chain selected by the chain, testnet, testnet4,
+// signet or regtest options. It returns ChainMain if none are set.
+func (conf *Config) Chain() Chain {
+	if chain, ok := conf.Get(ChainMain, "chain"); ok && chain != "" {
+		return Chain(chain)
+	}
+
+	for _, chain := range []Chain{ChainRegtest, ChainSignet, ChainTestnet4} {
+		if conf.isSet(string(chain)) {
+			return chain
+		}
+	}
+	if conf.isSet("testnet") {
+		return ChainTestnet3
+	}
+
+	return ChainMain
+}
+
+func (conf *Config) isSet(key string) bool {
+	value, ok := conf.Get(ChainMain, key)
+	return ok && value != "0"
+}
@@ -0,0 +1,191 @@
+package rpc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+# global options
+server=1
+rpcuser=alice
+rpcpassword=global-secret
+rpcport=9999
+rpccookiefile=first  # bitcoind uses the first value
+rpccookiefile=custom.cookie
+test.rpcport=19999
+
+[test]
+rpcpassword=test-secret
+
+[regtest]
+rpcport=28443
+rpcport=38443
+`
+
+func TestConfig_Get(t *testing.T) {
+	conf, err := ParseConfig(strings.NewReader(testConfig))
+	if err != nil {
+		t.Errorf("failed to parse config: %s", err)
+		return
+	}
+
+	fixtures := []struct {
+		chain    Chain
+		key      string
+		expected string
+		found    bool
+	}{
+		{ChainMain, "rpcuser", "alice", true},
+		{ChainMain, "rpcpassword", "global-secret", true},
+		{ChainMain, "rpcport", "9999", true},
+		{ChainMain, "rpccookiefile", "first", true},
+		{ChainMain, "server", "1", true},
+		{ChainMain, "txindex", "", false},
+		{ChainTestnet3, "rpcuser", "alice", true},
+		{ChainTestnet3, "rpcpassword", "test-secret", true},
+		{ChainTestnet3, "rpcport", "19999", true},
+		{ChainRegtest, "rpcport", "28443", true},
+		{ChainRegtest, "rpcpassword", "global-secret", true},
+		{ChainSignet, "rpcport", "", false},
+		{ChainTestnet4, "rpcuser", "alice", true},
+	}
+
+	for _, fixture := range fixtures {
+		value, found := conf.Get(fixture.chain, fixture.key)
+		if value != fixture.expected || found != fixture.found {
+			t.Errorf(
+				"incorrect value for %s on %s\nWanted %q, %v\nGot    %q, %v",
+				fixture.key, fixture.chain, fixture.expected, fixture.found, value, found,
+			)
+			continue
+		}
+	}
+
+	if _, err := ParseConfig(strings.NewReader("rpcuser alice\n")); !errors.Is(err, ErrInvalidConfigFile) {
+		t.Errorf("expected ErrInvalidConfigFile, got %v", err)
+		return
+	}
+}
+
+func TestConfig_Chain(t *testing.T) {
+	fixtures := map[string]Chain{
+		"":                       ChainMain,
+		"server=1":               ChainMain,
+		"testnet=1":              ChainTestnet3,
+		"testnet=0":              ChainMain,
+		"testnet4=1":             ChainTestnet4,
+		"signet=1":               ChainSignet,
+		"regtest=1":              ChainRegtest,
+		"chain=signet":           ChainSignet,
+		"chain=main\nregtest=1":  ChainMain,
+		"[regtest]\nregtest=1\n": ChainMain,
+	}
+
+	for confText, expected := range fixtures {
+		conf, err := ParseConfig(strings.NewReader(confText))
+		if err != nil {
+			t.Errorf("failed to parse config %q: %s", confText, err)
+			continue
+		}
+
+		if chain := conf.Chain(); chain != expected {
+			t.Errorf("incorrect chain for config %q\nWanted %s\nGot    %s", confText, expected, chain)
+			continue
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatalf("failed to create directory: %s", err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dataDir := t.TempDir()
+	writeFile(t, filepath.Join(dataDir, ".cookie"), "__cookie__:main\n")
+	writeFile(t, filepath.Join(dataDir, "testnet3", ".cookie"), "__cookie__:testnet3\n")
+	writeFile(t, filepath.Join(dataDir, "testnet4", ".cookie"), "__cookie__:testnet4\n")
+	writeFile(t, filepath.Join(dataDir, "signet", "other.cookie"), "__cookie__:signet\n")
+	writeFile(t, filepath.Join(dataDir, "bitcoin.conf"), strings.Join([]string{
+		"regtest=1",
+		"rpcport=8000",
+		"[signet]",
+		"rpccookiefile=other.cookie",
+		"rpcconnect=10.0.0.1:1234",
+		"[regtest]",
+		"rpcuser=bob",
+		"rpcpassword=hunter2",
+		"rpcconnect=node.local",
+	}, "\n"))
+
+	fixtures := []struct {
+		chain    Chain
+		expected NodeConfig
+	}{
+		{ChainMain, NodeConfig{ChainMain, "http://127.0.0.1:8000", "__cookie__", "main"}},
+		{ChainTestnet3, NodeConfig{ChainTestnet3, "http://127.0.0.1:18332", "__cookie__", "testnet3"}},
+		{ChainTestnet4, NodeConfig{ChainTestnet4, "http://127.0.0.1:48332", "__cookie__", "testnet4"}},
+		{ChainSignet, NodeConfig{ChainSignet, "http://10.0.0.1:1234", "__cookie__", "signet"}},
+		{ChainRegtest, NodeConfig{ChainRegtest, "http://node.local:18443", "bob", "hunter2"}},
+		{"", NodeConfig{ChainRegtest, "http://node.local:18443", "bob", "hunter2"}},
+	}
+
+	for _, fixture := range fixtures {
+		nodeConfig, err := Discover(dataDir, fixture.chain)
+		if err != nil {
+			t.Errorf("failed to discover %q node: %s", fixture.chain, err)
+			continue
+		}
+
+		if *nodeConfig != fixture.expected {
+			t.Errorf("incorrect node config for %q\nWanted %+v\nGot    %+v", fixture.chain, fixture.expected, *nodeConfig)
+			continue
+		}
+	}
+
+	os.Remove(filepath.Join(dataDir, "testnet3", ".cookie"))
+	if _, err := Discover(dataDir, ChainTestnet3); !errors.Is(err, ErrCookieNotFound) {
+		t.Errorf("expected ErrCookieNotFound, got %v", err)
+		return
+	}
+
+	username, password, err := ReadChainCookieFile(dataDir, ChainTestnet4)
+	if err != nil {
+		t.Errorf("failed to read testnet4 cookie: %s", err)
+		return
+	} else if username != "__cookie__" || password != "testnet4" {
+		t.Errorf("incorrect testnet4 cookie: %s:%s", username, password)
+		return
+	}
+}
+
+func TestReadCookieFile_DataDir(t *testing.T) {
+	confDir := t.TempDir()
+	dataDir := t.TempDir()
+
+	writeFile(t, filepath.Join(confDir, "bitcoin.conf"), "datadir="+dataDir+"\n")
+	writeFile(t, filepath.Join(dataDir, "regtest", ".cookie"), "__cookie__:abc123")
+
+	username, password, err := ReadChainCookieFile(confDir, ChainRegtest)
+	if err != nil {
+		t.Errorf("failed to read cookie: %s", err)
+		return
+	} else if username != "__cookie__" || password != "abc123" {
+		t.Errorf("incorrect cookie: %s:%s", username, password)
+		return
+	}
+
+	writeFile(t, filepath.Join(dataDir, ".cookie"), "nocolon")
+	if _, _, err := ReadCookieFile(confDir); !errors.Is(err, ErrInvalidCookieFile) {
+		t.Errorf("expected ErrInvalidCookieFile, got %v", err)
+		return
+	}
+}
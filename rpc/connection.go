@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -22,12 +23,38 @@ var (
 
 	// ErrInvalidUrl is returned when initiating a connection to an unsupported URL.
 	ErrInvalidUrl = errors.New("invalid URL protocol for Connection")
+
+	// ErrWorkQueueExceeded is returned if the node's RPC work queue is
+	// still full after the Connection has retried a request MaxRetries times.
+	ErrWorkQueueExceeded = errors.New("bitcoind RPC work queue depth exceeded")
+)
+
+const (
+	// DefaultMaxRetries is the default number of times a Connection retries a
+	// request which was rejected because the node's RPC work queue was full.
+	DefaultMaxRetries = 10
+
+	// DefaultInitialBackoff is the default delay before a Connection first retries a request.
+	DefaultInitialBackoff = 50 * time.Millisecond
+
+	// DefaultMaxBackoff is the default upper bound on the delay between retries.
+	DefaultMaxBackoff = 5 * time.Second
 )
 
+const workQueueExceededMessage = "Work queue depth exceeded"
+
 // Connection represents an HTTP connection to a Bitcoin node.
 type Connection struct {
-	URL                *url.URL
-	HttpClient         *http.Client
+	URL        *url.URL
+	HttpClient *http.Client
+
+	// MaxRetries is the number of times a request is retried if the node's RPC work queue
+	// is full. The delay between retries starts at InitialBackoff and doubles after each
+	// attempt, up to MaxBackoff.
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
 	username, password string
 	requestID          int
 	requestIDMutex     *sync.Mutex
@@ -35,24 +62,14 @@ type Connection struct {
 
 // NewConnection initiates a new Connection to the given URL.
 func NewConnection(uri, username, password string) (*Connection, error) {
-	parsedURL, err := url.Parse(uri)
-	if err != nil {
-		return nil, err
-	}
-
-	if parsedURL.Scheme != "https" && parsedURL.Scheme != "http" {
-		return nil, ErrInvalidUrl
-	}
-
-	conn := &Connection{
-		URL:            parsedURL,
-		HttpClient:     http.DefaultClient,
-		username:       username,
-		password:       password,
-		requestIDMutex: new(sync.Mutex),
-	}
+	return New(uri, WithCredentials(username, password))
+}
 
-	return conn, nil
+type rpcRequestMessage struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
 }
 
 type rpcResponseMessage struct {
@@ -60,11 +77,39 @@ type rpcResponseMessage struct {
 	Result any            `json:"result"`
 }
 
+func (conn *Connection) nextRequestID() int {
+	conn.requestIDMutex.Lock()
+	defer conn.requestIDMutex.Unlock()
+
+	requestID := conn.requestID
+	conn.requestID += 1
+	return requestID
+}
+
+func (conn *Connection) newRequestMessage(method string, params []any) *rpcRequestMessage {
+	if params == nil {
+		params = []any{}
+	}
+
+	return &rpcRequestMessage{
+		JSONRPC: "1.0",
+		ID:      conn.nextRequestID(),
+		Method:  method,
+		Params:  params,
+	}
+}
+
 // Request initiates a new RPC method call over the Connection and returns the
 // RPC response's "result" property as a JSON-decoded any.
 func (conn *Connection) Request(method string, params ...any) (any, error) {
+	return conn.RequestContext(context.Background(), method, params...)
+}
+
+// RequestContext is similar to conn.Request, except the request is
+// canceled if the given context is canceled or its deadline passes.
+func (conn *Connection) RequestContext(ctx context.Context, method string, params ...any) (any, error) {
 	var result any
-	if err := conn.RequestSetResult(&result, method, params...); err != nil {
+	if err := conn.RequestSetResultContext(ctx, &result, method, params...); err != nil {
 		return nil, err
 	}
 
@@ -74,66 +119,22 @@ func (conn *Connection) Request(method string, params ...any) (any, error) {
 // RequestSetResult is similar to conn.Request, except the caller can
 // pass a pointer into which the RPC response result is decoded.
 func (conn *Connection) RequestSetResult(resultPtr any, method string, params ...any) error {
-	conn.requestIDMutex.Lock()
-	requestID := conn.requestID
-	conn.requestID += 1
-	conn.requestIDMutex.Unlock()
-
-	reqBodyMap := map[string]any{
-		"jsonrpc": "1.0",
-		"id":      requestID,
-		"method":  method,
-		"params":  params,
-	}
-
-	reqBody := new(bytes.Buffer)
-
-	if err := json.NewEncoder(reqBody).Encode(reqBodyMap); err != nil {
-		return err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, conn.URL.String(), reqBody)
-	if err != nil {
-		return err
-	}
-
-	req.SetBasicAuth(conn.username, conn.password)
+	return conn.RequestSetResultContext(context.Background(), resultPtr, method, params...)
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// RequestSetResultContext is similar to conn.RequestSetResult, except the request
+// is canceled if the given context is canceled or its deadline passes.
+func (conn *Connection) RequestSetResultContext(ctx context.Context, resultPtr any, method string, params ...any) error {
+	bodyBytes, statusCode, err := conn.post(ctx, conn.newRequestMessage(method, params))
 	if err != nil {
 		return err
 	}
 
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 401 {
-		return ErrInvalidCredentials
-	}
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	bodyString := string(bodyBytes)
-
-	if bodyString == "Work queue depth exceeded" {
-		fmt.Fprintln(
-			os.Stderr,
-			"bitcoin/rpc WARNING: Exceeding work queue depth for bitcoind.",
-			"Request will be retried, but you should decrease parallel request",
-			"load, or increase -rpcworkqueue and -rpcthreads settings in",
-			"bitcoin.conf (defaults are 16 and 4 respectively).",
-		)
-		time.Sleep(100 * time.Millisecond)
-		return conn.RequestSetResult(resultPtr, method, params...)
-	}
-
 	responseObj := &rpcResponseMessage{Result: resultPtr}
 
 	if err := json.Unmarshal(bodyBytes, &responseObj); err != nil {
-		if resp.StatusCode != 200 {
-			return fmt.Errorf("%w: %s", ErrInvalidResponseFormat, bodyString)
+		if statusCode != http.StatusOK {
+			return fmt.Errorf("%w: %s", ErrInvalidResponseFormat, bodyBytes)
 		}
 
 		return fmt.Errorf("%w: %s", ErrInvalidResponseFormat, err)
@@ -149,3 +150,70 @@ func (conn *Connection) RequestSetResult(resultPtr any, method string, params ..
 
 	return ErrInvalidResponseFormat
 }
+
+// post sends the JSON-encoded request body to the node and returns the response body and
+// status code. If the node's RPC work queue is full, the request is retried with exponential
+// backoff, up to conn.MaxRetries times.
+func (conn *Connection) post(ctx context.Context, reqBodyObj any) ([]byte, int, error) {
+	reqBody, err := json.Marshal(reqBodyObj)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	backoff := conn.InitialBackoff
+
+	for retries := 0; ; retries++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, conn.URL.String(), bytes.NewReader(reqBody))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(conn.username, conn.password)
+
+		resp, err := conn.HttpClient.Do(req)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		bodyBytes, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, resp.StatusCode, ErrInvalidCredentials
+		} else if err != nil {
+			return nil, resp.StatusCode, fmt.Errorf("failed to read response body: %w", err)
+		}
+
+		if string(bodyBytes) != workQueueExceededMessage {
+			return bodyBytes, resp.StatusCode, nil
+		}
+
+		if retries >= conn.MaxRetries {
+			return nil, resp.StatusCode, ErrWorkQueueExceeded
+		}
+
+		if retries == 0 {
+			fmt.Fprintln(
+				os.Stderr,
+				"bitcoin/rpc WARNING: Exceeding work queue depth for bitcoind.",
+				"Request will be retried, but you should decrease parallel request",
+				"load, or increase -rpcworkqueue and -rpcthreads settings in",
+				"bitcoin.conf (defaults are 16 and 4 respectively).",
+			)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, 0, ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > conn.MaxBackoff {
+			backoff = conn.MaxBackoff
+		}
+	}
+}
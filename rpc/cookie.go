@@ -2,8 +2,12 @@ package rpc
 
 import (
 	"errors"
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 )
 
@@ -12,27 +16,88 @@ var (
 	ErrInvalidCookieFile = errors.New("cookie file not formatted correctly")
 )
 
+// DefaultDataDir returns the default data directory used by bitcoind on the current operating system.
+func DefaultDataDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		appData, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(appData, "Bitcoin"), nil
+
+	case "darwin":
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(homeDir, "Library", "Application Support", "Bitcoin"), nil
+
+	default:
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(homeDir, ".bitcoin"), nil
+	}
+}
+
+// FindCookie reads the mainnet RPC cookie file from the default data directory.
 func FindCookie() (username, password string, err error) {
-	var homeDir string
-	homeDir, err = os.UserHomeDir()
+	return FindChainCookie(ChainMain)
+}
+
+// FindChainCookie reads the RPC cookie file for the given chain from the default data directory.
+func FindChainCookie(chain Chain) (username, password string, err error) {
+	dataDir, err := DefaultDataDir()
 	if err != nil {
-		return
+		return "", "", err
 	}
 
-	username, password, err = ReadCookieFile(filepath.Join(homeDir, ".bitcoin"))
-	return
+	return ReadChainCookieFile(dataDir, chain)
 }
 
+// ReadCookieFile reads the mainnet RPC cookie file from the given data directory.
 func ReadCookieFile(dataDir string) (username, password string, err error) {
-	cookieContents, err := os.ReadFile(filepath.Join(dataDir, ".cookie"))
+	return ReadChainCookieFile(dataDir, ChainMain)
+}
+
+// ReadChainCookieFile reads the RPC cookie file for the given chain from the given data directory.
+// The datadir and rpccookiefile options in the directory's bitcoin.conf file are respected.
+func ReadChainCookieFile(dataDir string, chain Chain) (username, password string, err error) {
+	conf, err := readDataDirConfig(dataDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	return readCookie(conf, dataDir, chain)
+}
+
+func readDataDirConfig(dataDir string) (*Config, error) {
+	conf, err := ReadConfigFile(filepath.Join(dataDir, "bitcoin.conf"))
 	if errors.Is(err, os.ErrNotExist) {
-		newDataDir, err := readDataDirConfigParam(dataDir)
-		if err != nil {
-			return "", "", err
-		}
+		return &Config{}, nil
+	}
+	return conf, err
+}
+
+func readCookie(conf *Config, dataDir string, chain Chain) (username, password string, err error) {
+	if newDataDir, ok := conf.Get(chain, "datadir"); ok && newDataDir != "" {
+		dataDir = newDataDir
+	}
+	chainDir := filepath.Join(dataDir, chain.DataDirSubdir())
 
-		// Try data dir for cookie file
-		return ReadCookieFile(newDataDir)
+	cookiePath := ".cookie"
+	if path, ok := conf.Get(chain, "rpccookiefile"); ok && path != "" {
+		cookiePath = path
+	}
+	if !filepath.IsAbs(cookiePath) {
+		cookiePath = filepath.Join(chainDir, cookiePath)
+	}
+
+	cookieContents, err := os.ReadFile(cookiePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", fmt.Errorf("%w: %s", ErrCookieNotFound, cookiePath)
 	} else if err != nil {
 		return "", "", err
 	}
@@ -47,20 +112,75 @@ func ReadCookieFile(dataDir string) (username, password string, err error) {
 	return
 }
 
-func readDataDirConfigParam(dataDir string) (string, error) {
-	configFileContents, err := os.ReadFile(filepath.Join(dataDir, "bitcoin.conf"))
+// NodeConfig describes how to connect to the RPC server of a local node.
+type NodeConfig struct {
+	Chain    Chain
+	URL      string
+	Username string
+	Password string
+}
+
+// Discover reads the RPC settings of the node whose data directory is dataDir. If dataDir is
+// empty, DefaultDataDir is used. If chain is empty, the chain selected in bitcoin.conf is used.
+//
+// The host and port are taken from the rpcconnect and rpcport options, defaulting to localhost
+// and the chain's default RPC port. As with bitcoin-cli, rpcport overrides a port in rpcconnect. If rpcpassword is set, rpcuser and rpcpassword are used as
+// credentials. Otherwise, they are read from the chain's cookie file.
+func Discover(dataDir string, chain Chain) (*NodeConfig, error) {
+	if dataDir == "" {
+		var err error
+		if dataDir, err = DefaultDataDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	conf, err := readDataDirConfig(dataDir)
 	if err != nil {
-		return "", ErrCookieNotFound
+		return nil, err
 	}
 
-	configFileLines := strings.Split(string(configFileContents), "\n")
+	if chain == "" {
+		chain = conf.Chain()
+	}
 
-	for _, line := range configFileLines {
-		if strings.HasPrefix(line, "datadir=") {
-			newDataDir := strings.TrimSpace(strings.TrimPrefix(line, "datadir="))
-			return newDataDir, nil
+	host, rpcPort := "127.0.0.1", ""
+	if rpcConnect, ok := conf.Get(chain, "rpcconnect"); ok && rpcConnect != "" {
+		host = rpcConnect
+		if h, p, err := net.SplitHostPort(rpcConnect); err == nil {
+			host, rpcPort = h, p
 		}
 	}
+	if p, ok := conf.Get(chain, "rpcport"); ok {
+		rpcPort = p
+	}
+
+	port := chain.DefaultRPCPort()
+	if rpcPort != "" {
+		if port, err = strconv.Atoi(rpcPort); err != nil {
+			return nil, fmt.Errorf("%w: invalid rpcport %q", ErrInvalidConfigFile, rpcPort)
+		}
+	}
+
+	nodeConfig := &NodeConfig{
+		Chain: chain,
+		URL:   "http://" + net.JoinHostPort(host, strconv.Itoa(port)),
+	}
+
+	if rpcPassword, ok := conf.Get(chain, "rpcpassword"); ok {
+		nodeConfig.Username, _ = conf.Get(chain, "rpcuser")
+		nodeConfig.Password = rpcPassword
+	} else {
+		nodeConfig.Username, nodeConfig.Password, err = readCookie(conf, dataDir, chain)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nodeConfig, nil
+}
 
-	return "", ErrCookieNotFound
+// Connect returns a new Connection to the node, configured by the given options.
+func (nodeConfig *NodeConfig) Connect(options ...Option) (*Connection, error) {
+	options = append([]Option{WithCredentials(nodeConfig.Username, nodeConfig.Password)}, options...)
+	return New(nodeConfig.URL, options...)
 }
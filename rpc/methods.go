@@ -0,0 +1,354 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+	"github.com/kklash/bitcoinlib/common"
+	"github.com/kklash/bitcoinlib/satutil"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/unspent"
+)
+
+// Hashes passed to and returned by the methods in this file are in RPC byte order, as
+// returned by blockheader.BlockHeader.Hash, and displayed by bitcoind and block explorers.
+// Each method is canceled if its context is canceled or its deadline passes.
+
+func encodeHash(hash [32]byte) string {
+	return hex.EncodeToString(hash[:])
+}
+
+func decodeHash(hashHex string) ([32]byte, error) {
+	var hash [32]byte
+	decoded, err := hex.DecodeString(hashHex)
+	if err != nil || len(decoded) != 32 {
+		return hash, fmt.Errorf("%w: invalid hash %q", ErrInvalidResponseFormat, hashHex)
+	}
+	copy(hash[:], decoded)
+	return hash, nil
+}
+
+func (conn *Connection) requestHex(ctx context.Context, method string, params ...any) ([]byte, error) {
+	var resultHex string
+	if err := conn.RequestSetResultContext(ctx, &resultHex, method, params...); err != nil {
+		return nil, err
+	}
+
+	decoded, err := hex.DecodeString(resultHex)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidResponseFormat, err)
+	}
+	return decoded, nil
+}
+
+// GetBlockCount returns the height of the node's best block.
+func (conn *Connection) GetBlockCount(ctx context.Context) (int, error) {
+	var blockCount int
+	if err := conn.RequestSetResultContext(ctx, &blockCount, "getblockcount"); err != nil {
+		return 0, err
+	}
+	return blockCount, nil
+}
+
+// GetBlockHash returns the hash of the block at the given height in the node's best chain.
+func (conn *Connection) GetBlockHash(ctx context.Context, height int) ([32]byte, error) {
+	var hashHex string
+	if err := conn.RequestSetResultContext(ctx, &hashHex, "getblockhash", height); err != nil {
+		return [32]byte{}, err
+	}
+	return decodeHash(hashHex)
+}
+
+// GetBlock returns the block with the given hash.
+func (conn *Connection) GetBlock(ctx context.Context, blockHash [32]byte) (*blocks.Block, error) {
+	blockBytes, err := conn.requestHex(ctx, "getblock", encodeHash(blockHash), 0)
+	if err != nil {
+		return nil, err
+	}
+	return blocks.FromReader(bytes.NewReader(blockBytes))
+}
+
+// GetBlockHashes returns the hashes of the blocks at each of the given heights in
+// the node's best chain, using a single batch request.
+func (conn *Connection) GetBlockHashes(ctx context.Context, heights ...int) ([][32]byte, error) {
+	hashHexes := make([]string, len(heights))
+	requests := make([]*BatchRequest, len(heights))
+	for i, height := range heights {
+		requests[i] = NewBatchRequest(&hashHexes[i], "getblockhash", height)
+	}
+
+	if err := conn.RequestBatch(ctx, requests...); err != nil {
+		return nil, err
+	} else if err := BatchError(requests...); err != nil {
+		return nil, err
+	}
+
+	hashes := make([][32]byte, len(heights))
+	for i, hashHex := range hashHexes {
+		hash, err := decodeHash(hashHex)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+	}
+
+	return hashes, nil
+}
+
+// GetBlocks returns the blocks with each of the given hashes, using a single batch request.
+func (conn *Connection) GetBlocks(ctx context.Context, blockHashes ...[32]byte) ([]*blocks.Block, error) {
+	blockHexes := make([]string, len(blockHashes))
+	requests := make([]*BatchRequest, len(blockHashes))
+	for i, blockHash := range blockHashes {
+		requests[i] = NewBatchRequest(&blockHexes[i], "getblock", encodeHash(blockHash), 0)
+	}
+
+	if err := conn.RequestBatch(ctx, requests...); err != nil {
+		return nil, err
+	} else if err := BatchError(requests...); err != nil {
+		return nil, err
+	}
+
+	blockList := make([]*blocks.Block, len(blockHashes))
+	for i, blockHex := range blockHexes {
+		block, err := blocks.FromReader(hex.NewDecoder(strings.NewReader(blockHex)))
+		if err != nil {
+			return nil, err
+		}
+		blockList[i] = block
+	}
+
+	return blockList, nil
+}
+
+// GetBlockHeader returns the header of the block with the given hash.
+func (conn *Connection) GetBlockHeader(ctx context.Context, blockHash [32]byte) (*blockheader.BlockHeader, error) {
+	headerBytes, err := conn.requestHex(ctx, "getblockheader", encodeHash(blockHash), false)
+	if err != nil {
+		return nil, err
+	}
+	return blockheader.FromReader(bytes.NewReader(headerBytes))
+}
+
+// GetRawTransaction returns the transaction with the given txid. Unless the node runs with
+// -txindex, the transaction must be in the mempool, or the node's wallet.
+func (conn *Connection) GetRawTransaction(ctx context.Context, txid [32]byte) (*tx.Tx, error) {
+	txBytes, err := conn.requestHex(ctx, "getrawtransaction", encodeHash(txid), false)
+	if err != nil {
+		return nil, err
+	}
+	return tx.FromBytes(txBytes)
+}
+
+// GetRawTransactions returns the transactions with each of the given txids, using a single batch
+// request. Transactions which the node cannot find are returned as nil; any other error fails
+// the whole call. As with GetRawTransaction, confirmed transactions can only be found if the
+// node runs with -txindex.
+func (conn *Connection) GetRawTransactions(ctx context.Context, txids ...[32]byte) ([]*tx.Tx, error) {
+	txHexes := make([]string, len(txids))
+	requests := make([]*BatchRequest, len(txids))
+	for i, txid := range txids {
+		requests[i] = NewBatchRequest(&txHexes[i], "getrawtransaction", encodeHash(txid), false)
+	}
+
+	if err := conn.RequestBatch(ctx, requests...); err != nil {
+		return nil, err
+	}
+
+	txns := make([]*tx.Tx, len(txids))
+	for i, request := range requests {
+		var rpcErr *ErrRPCFailure
+		if errors.As(request.Err, &rpcErr) && rpcErr.Code == -5 {
+			continue
+		} else if request.Err != nil {
+			return nil, request.Err
+		}
+
+		txBytes, err := hex.DecodeString(txHexes[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidResponseFormat, err)
+		}
+		if txns[i], err = tx.FromBytes(txBytes); err != nil {
+			return nil, err
+		}
+	}
+
+	return txns, nil
+}
+
+// SendRawTransaction submits the transaction to the node's mempool and broadcasts
+// it to the network. It returns the txid of the transaction.
+func (conn *Connection) SendRawTransaction(ctx context.Context, txn *tx.Tx) ([32]byte, error) {
+	var txidHex string
+	if err := conn.RequestSetResultContext(ctx, &txidHex, "sendrawtransaction", hex.EncodeToString(txn.Bytes())); err != nil {
+		return [32]byte{}, err
+	}
+	return decodeHash(txidHex)
+}
+
+// TestMempoolAccept checks whether the transactions would be accepted to the node's mempool,
+// without submitting them. It returns one result for each transaction.
+func (conn *Connection) TestMempoolAccept(ctx context.Context, txns ...*tx.Tx) ([]*MempoolAcceptResult, error) {
+	rawTxs := make([]string, len(txns))
+	for i, txn := range txns {
+		rawTxs[i] = hex.EncodeToString(txn.Bytes())
+	}
+
+	var results []*MempoolAcceptResult
+	if err := conn.RequestSetResultContext(ctx, &results, "testmempoolaccept", rawTxs); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetBlockchainInfo returns information about the node's block chain.
+func (conn *Connection) GetBlockchainInfo(ctx context.Context) (*BlockchainInfo, error) {
+	info := new(BlockchainInfo)
+	if err := conn.RequestSetResultContext(ctx, info, "getblockchaininfo"); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// GetMempoolInfo returns information about the node's mempool.
+func (conn *Connection) GetMempoolInfo(ctx context.Context) (*MempoolInfo, error) {
+	info := new(MempoolInfo)
+	if err := conn.RequestSetResultContext(ctx, info, "getmempoolinfo"); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// EstimateSmartFee estimates the fee rate needed for a transaction to confirm within
+// confTarget blocks. If mode is empty, the node's default estimation mode is used.
+func (conn *Connection) EstimateSmartFee(ctx context.Context, confTarget int, mode EstimateMode) (*FeeEstimate, error) {
+	params := []any{confTarget}
+	if mode != "" {
+		params = append(params, mode)
+	}
+
+	estimate := new(FeeEstimate)
+	if err := conn.RequestSetResultContext(ctx, estimate, "estimatesmartfee", params...); err != nil {
+		return nil, err
+	}
+	return estimate, nil
+}
+
+type txOutResponse struct {
+	BestBlock     string  `json:"bestblock"`
+	Confirmations int     `json:"confirmations"`
+	Value         float64 `json:"value"`
+	ScriptPubKey  struct {
+		Hex string `json:"hex"`
+	} `json:"scriptPubKey"`
+	Coinbase bool `json:"coinbase"`
+}
+
+// GetTxOut returns the unspent output at the given outpoint. If includeMempool is true,
+// outputs created or spent by mempool transactions are taken into account. It returns
+// a nil TxOut and a nil error if the output does not exist or has been spent.
+func (conn *Connection) GetTxOut(ctx context.Context, prevOut *tx.PrevOut, includeMempool bool) (*TxOut, error) {
+	txid := common.ReverseBytes(prevOut.Hash[:])
+
+	var response *txOutResponse
+	err := conn.RequestSetResultContext(ctx, &response, "gettxout", hex.EncodeToString(txid), prevOut.Index, includeMempool)
+	if err != nil {
+		return nil, err
+	} else if response == nil {
+		return nil, nil
+	}
+
+	bestBlock, err := decodeHash(response.BestBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	script, err := hex.DecodeString(response.ScriptPubKey.Hex)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidResponseFormat, err)
+	}
+
+	txOut := &TxOut{
+		BestBlock:     bestBlock,
+		Confirmations: response.Confirmations,
+		Output: &tx.Output{
+			Value:  satutil.BitcoinsToSats(response.Value),
+			Script: script,
+		},
+		Coinbase: response.Coinbase,
+	}
+	return txOut, nil
+}
+
+type scanTxOutSetResponse struct {
+	Height      int     `json:"height"`
+	BestBlock   string  `json:"bestblock"`
+	TotalAmount float64 `json:"total_amount"`
+	Unspents    []struct {
+		Txid         string  `json:"txid"`
+		Vout         uint32  `json:"vout"`
+		ScriptPubKey string  `json:"scriptPubKey"`
+		Desc         string  `json:"desc"`
+		Amount       float64 `json:"amount"`
+		Coinbase     bool    `json:"coinbase"`
+		Height       int     `json:"height"`
+	} `json:"unspents"`
+}
+
+// ScanTxOutSet scans the node's UTXO set for outputs matching any of the given output
+// descriptors, such as "addr(bc1q...)" or "wpkh([fingerprint/84h/0h/0h]xpub.../0/*)".
+// This can take several minutes, during which the node will reject other scans.
+func (conn *Connection) ScanTxOutSet(ctx context.Context, descriptors ...string) (*ScanTxOutSetResult, error) {
+	var response scanTxOutSetResponse
+	if err := conn.RequestSetResultContext(ctx, &response, "scantxoutset", "start", descriptors); err != nil {
+		return nil, err
+	}
+
+	bestBlock, err := decodeHash(response.BestBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ScanTxOutSetResult{
+		Height:      response.Height,
+		BestBlock:   bestBlock,
+		Unspents:    make([]*ScannedUnspent, len(response.Unspents)),
+		TotalAmount: satutil.BitcoinsToSats(response.TotalAmount),
+	}
+
+	for i, utxo := range response.Unspents {
+		txid, err := decodeHash(utxo.Txid)
+		if err != nil {
+			return nil, err
+		}
+
+		script, err := hex.DecodeString(utxo.ScriptPubKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidResponseFormat, err)
+		}
+
+		outpoint := &tx.PrevOut{Index: utxo.Vout}
+		copy(outpoint.Hash[:], common.ReverseBytes(txid[:]))
+
+		result.Unspents[i] = &ScannedUnspent{
+			Output: &unspent.Output{
+				Outpoint: outpoint,
+				TxOut: &tx.Output{
+					Value:  satutil.BitcoinsToSats(utxo.Amount),
+					Script: script,
+				},
+			},
+			Descriptor: utxo.Desc,
+			Coinbase:   utxo.Coinbase,
+			Height:     utxo.Height,
+		}
+	}
+
+	return result, nil
+}
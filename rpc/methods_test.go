@@ -0,0 +1,464 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+const (
+	genesisBlockHex  = "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c0101000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+	genesisBlockHash = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	genesisTxid      = "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+)
+
+func hex2bytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func hex2hash(s string) (hash [32]byte) {
+	copy(hash[:], hex2bytes(s))
+	return
+}
+
+type fakeRequest struct {
+	ID     int    `json:"id"`
+	Method string `json:"method"`
+	Params []any  `json:"params"`
+}
+
+type fakeMethod func(params []any) (any, *ErrRPCFailure)
+
+// newFakeBitcoind returns a Connection to an in-process HTTP server which
+// responds to JSON-RPC requests using the given method handlers.
+func newFakeBitcoind(t *testing.T, methods map[string]fakeMethod) *Connection {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var requests []*fakeRequest
+		isBatch := len(body) > 0 && body[0] == '['
+		if !isBatch {
+			body = append(append([]byte{'['}, body...), ']')
+		}
+		if err := json.Unmarshal(body, &requests); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		responses := make([]map[string]any, len(requests))
+		for i, request := range requests {
+			responses[i] = map[string]any{"id": request.ID, "result": nil, "error": nil}
+
+			if method, ok := methods[request.Method]; !ok {
+				responses[i]["error"] = &ErrRPCFailure{Code: -32601, Message: "Method not found"}
+			} else if result, rpcErr := method(request.Params); rpcErr != nil {
+				responses[i]["error"] = rpcErr
+			} else {
+				responses[i]["result"] = result
+			}
+		}
+
+		if isBatch {
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+
+		if responses[0]["error"] != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(responses[0])
+	}))
+	t.Cleanup(server.Close)
+
+	conn, err := NewConnection(server.URL, "user", "pass")
+	if err != nil {
+		t.Fatalf("failed to create connection: %s", err)
+	}
+	return conn
+}
+
+func TestConnection_GetBlock(t *testing.T) {
+	ctx := context.Background()
+
+	conn := newFakeBitcoind(t, map[string]fakeMethod{
+		"getblockcount": func([]any) (any, *ErrRPCFailure) {
+			return 0, nil
+		},
+		"getblockhash": func(params []any) (any, *ErrRPCFailure) {
+			if len(params) != 1 || params[0] != float64(0) {
+				return nil, &ErrRPCFailure{Code: -8, Message: "Block height out of range"}
+			}
+			return genesisBlockHash, nil
+		},
+		"getblock": func(params []any) (any, *ErrRPCFailure) {
+			if len(params) != 2 || params[0] != genesisBlockHash || params[1] != float64(0) {
+				return nil, &ErrRPCFailure{Code: -5, Message: "Block not found"}
+			}
+			return genesisBlockHex, nil
+		},
+		"getblockheader": func(params []any) (any, *ErrRPCFailure) {
+			if len(params) != 2 || params[0] != genesisBlockHash || params[1] != false {
+				return nil, &ErrRPCFailure{Code: -5, Message: "Block not found"}
+			}
+			return genesisBlockHex[:160], nil
+		},
+	})
+
+	blockCount, err := conn.GetBlockCount(ctx)
+	if err != nil {
+		t.Errorf("failed to get block count: %s", err)
+		return
+	} else if blockCount != 0 {
+		t.Errorf("incorrect block count: %d", blockCount)
+		return
+	}
+
+	blockHash, err := conn.GetBlockHash(ctx, 0)
+	if err != nil {
+		t.Errorf("failed to get block hash: %s", err)
+		return
+	} else if blockHash != hex2hash(genesisBlockHash) {
+		t.Errorf("incorrect block hash\nWanted %s\nGot    %x", genesisBlockHash, blockHash)
+		return
+	}
+
+	block, err := conn.GetBlock(ctx, blockHash)
+	if err != nil {
+		t.Errorf("failed to get block: %s", err)
+		return
+	} else if !bytes.Equal(block.Bytes(), hex2bytes(genesisBlockHex)) {
+		t.Errorf("incorrect block returned")
+		return
+	}
+
+	header, err := conn.GetBlockHeader(ctx, blockHash)
+	if err != nil {
+		t.Errorf("failed to get block header: %s", err)
+		return
+	}
+	if headerHash, err := header.Hash(); err != nil {
+		t.Errorf("failed to hash header: %s", err)
+		return
+	} else if headerHash != blockHash {
+		t.Errorf("incorrect header hash\nWanted %x\nGot    %x", blockHash, headerHash)
+		return
+	}
+
+	_, err = conn.GetBlockHash(ctx, 1)
+	var rpcErr *ErrRPCFailure
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -8 {
+		t.Errorf("expected RPC error -8 for unknown height, got %v", err)
+		return
+	}
+}
+
+func TestConnection_Transactions(t *testing.T) {
+	ctx := context.Background()
+
+	block, err := newFakeBitcoind(t, map[string]fakeMethod{
+		"getblockhash": func([]any) (any, *ErrRPCFailure) { return genesisBlockHash, nil },
+		"getblock":     func([]any) (any, *ErrRPCFailure) { return genesisBlockHex, nil },
+	}).GetBlock(ctx, [32]byte{})
+	if err != nil {
+		t.Errorf("failed to get block: %s", err)
+		return
+	}
+	coinbase := block.Transactions[0]
+	coinbaseHex := coinbase.Hex()
+
+	conn := newFakeBitcoind(t, map[string]fakeMethod{
+		"getrawtransaction": func(params []any) (any, *ErrRPCFailure) {
+			if len(params) != 2 || params[0] != genesisTxid || params[1] != false {
+				return nil, &ErrRPCFailure{Code: -5, Message: "No such mempool or blockchain transaction"}
+			}
+			return coinbaseHex, nil
+		},
+		"sendrawtransaction": func(params []any) (any, *ErrRPCFailure) {
+			if len(params) != 1 || params[0] != coinbaseHex {
+				return nil, &ErrRPCFailure{Code: -22, Message: "TX decode failed"}
+			}
+			return genesisTxid, nil
+		},
+		"testmempoolaccept": func(params []any) (any, *ErrRPCFailure) {
+			rawTxs, ok := params[0].([]any)
+			if len(params) != 1 || !ok || len(rawTxs) != 1 || rawTxs[0] != coinbaseHex {
+				return nil, &ErrRPCFailure{Code: -22, Message: "TX decode failed"}
+			}
+			return []map[string]any{{
+				"txid":          genesisTxid,
+				"wtxid":         genesisTxid,
+				"allowed":       false,
+				"reject-reason": "coinbase",
+			}}, nil
+		},
+	})
+
+	txn, err := conn.GetRawTransaction(ctx, hex2hash(genesisTxid))
+	if err != nil {
+		t.Errorf("failed to get transaction: %s", err)
+		return
+	} else if !reflect.DeepEqual(txn, coinbase) {
+		t.Errorf("incorrect transaction returned")
+		return
+	}
+
+	txid, err := conn.SendRawTransaction(ctx, txn)
+	if err != nil {
+		t.Errorf("failed to send transaction: %s", err)
+		return
+	} else if txid != hex2hash(genesisTxid) {
+		t.Errorf("incorrect txid\nWanted %s\nGot    %x", genesisTxid, txid)
+		return
+	}
+
+	results, err := conn.TestMempoolAccept(ctx, txn)
+	if err != nil {
+		t.Errorf("failed to test mempool acceptance: %s", err)
+		return
+	}
+	expected := []*MempoolAcceptResult{{
+		Txid:         genesisTxid,
+		Wtxid:        genesisTxid,
+		Allowed:      false,
+		RejectReason: "coinbase",
+	}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("incorrect mempool accept results\nWanted %+v\nGot    %+v", expected[0], results[0])
+		return
+	}
+
+	if _, err := conn.SendRawTransaction(ctx, &tx.Tx{Version: 2}); err == nil {
+		t.Errorf("expected error when sending an incomplete transaction")
+		return
+	}
+}
+
+func TestConnection_ChainInfo(t *testing.T) {
+	ctx := context.Background()
+
+	conn := newFakeBitcoind(t, map[string]fakeMethod{
+		"getblockchaininfo": func([]any) (any, *ErrRPCFailure) {
+			return map[string]any{
+				"chain":                "main",
+				"blocks":               800000,
+				"headers":              800001,
+				"bestblockhash":        "00000000000000000002a7c4c1e48d76c5a37902165a270156b7a8d72728a054",
+				"mediantime":           1690165851,
+				"initialblockdownload": false,
+				"pruned":               true,
+				"pruneheight":          750000,
+			}, nil
+		},
+		"getmempoolinfo": func([]any) (any, *ErrRPCFailure) {
+			return map[string]any{
+				"loaded":        true,
+				"size":          12,
+				"bytes":         4500,
+				"total_fee":     0.0012,
+				"mempoolminfee": 0.00001,
+				"minrelaytxfee": 0.00001,
+			}, nil
+		},
+		"estimatesmartfee": func(params []any) (any, *ErrRPCFailure) {
+			if len(params) == 2 && params[1] != string(EstimateModeConservative) {
+				return nil, &ErrRPCFailure{Code: -8, Message: "Invalid estimate_mode parameter"}
+			} else if params[0] == float64(1) {
+				return map[string]any{
+					"errors": []string{"Insufficient data or no feerate found"},
+					"blocks": 0,
+				}, nil
+			}
+			return map[string]any{"feerate": 0.00012345, "blocks": params[0]}, nil
+		},
+	})
+
+	chainInfo, err := conn.GetBlockchainInfo(ctx)
+	if err != nil {
+		t.Errorf("failed to get blockchain info: %s", err)
+		return
+	}
+	expectedChainInfo := &BlockchainInfo{
+		Chain:         "main",
+		Blocks:        800000,
+		Headers:       800001,
+		BestBlockHash: "00000000000000000002a7c4c1e48d76c5a37902165a270156b7a8d72728a054",
+		MedianTime:    1690165851,
+		Pruned:        true,
+		PruneHeight:   750000,
+	}
+	if !reflect.DeepEqual(chainInfo, expectedChainInfo) {
+		t.Errorf("incorrect blockchain info\nWanted %+v\nGot    %+v", expectedChainInfo, chainInfo)
+		return
+	}
+
+	mempoolInfo, err := conn.GetMempoolInfo(ctx)
+	if err != nil {
+		t.Errorf("failed to get mempool info: %s", err)
+		return
+	}
+	expectedMempoolInfo := &MempoolInfo{
+		Loaded:        true,
+		Size:          12,
+		Bytes:         4500,
+		TotalFee:      0.0012,
+		MempoolMinFee: 0.00001,
+		MinRelayTxFee: 0.00001,
+	}
+	if !reflect.DeepEqual(mempoolInfo, expectedMempoolInfo) {
+		t.Errorf("incorrect mempool info\nWanted %+v\nGot    %+v", expectedMempoolInfo, mempoolInfo)
+		return
+	}
+
+	estimate, err := conn.EstimateSmartFee(ctx, 6, EstimateModeConservative)
+	if err != nil {
+		t.Errorf("failed to estimate fee: %s", err)
+		return
+	} else if estimate.Blocks != 6 || estimate.FeeRate != 0.00012345 {
+		t.Errorf("incorrect fee estimate: %+v", estimate)
+		return
+	} else if satsPerVByte := estimate.SatsPerVByte(); satsPerVByte != 12.345 {
+		t.Errorf("incorrect fee rate in sats/vbyte\nWanted 12.345\nGot    %v", satsPerVByte)
+		return
+	}
+
+	estimate, err = conn.EstimateSmartFee(ctx, 1, "")
+	if err != nil {
+		t.Errorf("failed to estimate fee: %s", err)
+		return
+	} else if estimate.FeeRate != 0 || len(estimate.Errors) != 1 {
+		t.Errorf("expected fee estimate with errors, got %+v", estimate)
+		return
+	}
+
+	if _, err := conn.EstimateSmartFee(ctx, 6, "bogus"); !errors.Is(err, ErrorsByCode[-8]) {
+		t.Errorf("expected invalid parameter error, got %v", err)
+		return
+	}
+}
+
+func TestConnection_UTXOs(t *testing.T) {
+	ctx := context.Background()
+
+	const (
+		bestBlock = "00000000000000000002a7c4c1e48d76c5a37902165a270156b7a8d72728a054"
+		txid      = "3b7f895f7dc9ab91e691755766effa703d0ee9ecb1bae93b2f75540bf0d1e8be"
+		script    = "0014751e76e8199196d454941c45d1b3a323f1433bd6"
+	)
+
+	conn := newFakeBitcoind(t, map[string]fakeMethod{
+		"gettxout": func(params []any) (any, *ErrRPCFailure) {
+			if len(params) != 3 || params[0] != txid || params[2] != true {
+				return nil, &ErrRPCFailure{Code: -8, Message: "Invalid parameter"}
+			} else if params[1] != float64(1) {
+				return nil, nil
+			}
+			return map[string]any{
+				"bestblock":     bestBlock,
+				"confirmations": 3,
+				"value":         0.12345678,
+				"scriptPubKey":  map[string]any{"hex": script},
+				"coinbase":      false,
+			}, nil
+		},
+		"scantxoutset": func(params []any) (any, *ErrRPCFailure) {
+			descriptors, ok := params[1].([]any)
+			if len(params) != 2 || params[0] != "start" || !ok || len(descriptors) != 1 {
+				return nil, &ErrRPCFailure{Code: -8, Message: "Invalid parameter"}
+			}
+			return map[string]any{
+				"success":      true,
+				"height":       800000,
+				"bestblock":    bestBlock,
+				"total_amount": 0.12345678,
+				"unspents": []map[string]any{{
+					"txid":         txid,
+					"vout":         1,
+					"scriptPubKey": script,
+					"desc":         descriptors[0],
+					"amount":       0.12345678,
+					"coinbase":     false,
+					"height":       799998,
+				}},
+			}, nil
+		},
+	})
+
+	prevOut := &tx.PrevOut{Index: 1}
+	copy(prevOut.Hash[:], hex2bytes(txid))
+	for i, j := 0, 31; i < j; i, j = i+1, j-1 {
+		prevOut.Hash[i], prevOut.Hash[j] = prevOut.Hash[j], prevOut.Hash[i]
+	}
+
+	expectedOutput := &tx.Output{Value: 12345678, Script: hex2bytes(script)}
+
+	txOut, err := conn.GetTxOut(ctx, prevOut, true)
+	if err != nil {
+		t.Errorf("failed to get tx output: %s", err)
+		return
+	}
+	expectedTxOut := &TxOut{
+		BestBlock:     hex2hash(bestBlock),
+		Confirmations: 3,
+		Output:        expectedOutput,
+	}
+	if !reflect.DeepEqual(txOut, expectedTxOut) {
+		t.Errorf("incorrect tx output\nWanted %+v\nGot    %+v", expectedTxOut, txOut)
+		return
+	}
+
+	if txOut, err := conn.GetTxOut(ctx, &tx.PrevOut{Hash: prevOut.Hash, Index: 0}, true); err != nil {
+		t.Errorf("failed to get spent tx output: %s", err)
+		return
+	} else if txOut != nil {
+		t.Errorf("expected nil TxOut for spent output, got %+v", txOut)
+		return
+	}
+
+	descriptor := "addr(bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4)"
+	scanResult, err := conn.ScanTxOutSet(ctx, descriptor)
+	if err != nil {
+		t.Errorf("failed to scan UTXO set: %s", err)
+		return
+	}
+
+	if scanResult.Height != 800000 || scanResult.BestBlock != hex2hash(bestBlock) ||
+		scanResult.TotalAmount != 12345678 || len(scanResult.Unspents) != 1 {
+		t.Errorf("incorrect scan result: %+v", scanResult)
+		return
+	}
+
+	utxo := scanResult.Unspents[0]
+	if *utxo.Outpoint != *prevOut {
+		t.Errorf("incorrect outpoint\nWanted %s\nGot    %s", prevOut, utxo.Outpoint)
+		return
+	} else if !reflect.DeepEqual(utxo.TxOut, expectedOutput) {
+		t.Errorf("incorrect scanned output\nWanted %+v\nGot    %+v", expectedOutput, utxo.TxOut)
+		return
+	} else if utxo.Descriptor != descriptor || utxo.Height != 799998 || utxo.Coinbase {
+		t.Errorf("incorrect scanned unspent: %+v", utxo)
+		return
+	}
+}
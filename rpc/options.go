@@ -0,0 +1,151 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Option configures a Connection created with New.
+type Option func(*connectionOptions)
+
+type connectionOptions struct {
+	username, password string
+	httpClient         *http.Client
+	tlsConfig          *tls.Config
+	wallet             string
+	maxRetries         int
+	initialBackoff     time.Duration
+	maxBackoff         time.Duration
+}
+
+// WithCredentials sets the username and password used to authenticate RPC requests.
+func WithCredentials(username, password string) Option {
+	return func(opts *connectionOptions) {
+		opts.username = username
+		opts.password = password
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to send RPC requests. By default, http.DefaultClient is used.
+func WithHTTPClient(client *http.Client) Option {
+	return func(opts *connectionOptions) {
+		opts.httpClient = client
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for https URLs, for example to trust a
+// self-signed certificate on an RPC proxy. The Connection uses a copy of its HTTP client,
+// whose transport is a clone of the original with the given TLS configuration.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(opts *connectionOptions) {
+		opts.tlsConfig = tlsConfig
+	}
+}
+
+// WithWallet directs requests to the /wallet/<name> endpoint, which is required to
+// call wallet methods on a node which has more than one wallet loaded.
+func WithWallet(name string) Option {
+	return func(opts *connectionOptions) {
+		opts.wallet = name
+	}
+}
+
+// WithRetries configures how the Connection retries requests which the node rejects because its
+// RPC work queue is full. See the Connection's MaxRetries, InitialBackoff and MaxBackoff fields.
+func WithRetries(maxRetries int, initialBackoff, maxBackoff time.Duration) Option {
+	return func(opts *connectionOptions) {
+		opts.maxRetries = maxRetries
+		opts.initialBackoff = initialBackoff
+		opts.maxBackoff = maxBackoff
+	}
+}
+
+// New initiates a new Connection to the given URL, configured by the given options.
+func New(uri string, options ...Option) (*Connection, error) {
+	parsedURL, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if parsedURL.Scheme != "https" && parsedURL.Scheme != "http" {
+		return nil, ErrInvalidUrl
+	}
+
+	opts := &connectionOptions{
+		httpClient:     http.DefaultClient,
+		maxRetries:     DefaultMaxRetries,
+		initialBackoff: DefaultInitialBackoff,
+		maxBackoff:     DefaultMaxBackoff,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+
+	httpClient := opts.httpClient
+	if opts.tlsConfig != nil {
+		httpClient = withTLSConfig(httpClient, opts.tlsConfig)
+	}
+
+	conn := &Connection{
+		URL:            parsedURL,
+		HttpClient:     httpClient,
+		MaxRetries:     opts.maxRetries,
+		InitialBackoff: opts.initialBackoff,
+		MaxBackoff:     opts.maxBackoff,
+		username:       opts.username,
+		password:       opts.password,
+		requestIDMutex: new(sync.Mutex),
+	}
+
+	if opts.wallet != "" {
+		conn.URL = walletURL(parsedURL, opts.wallet)
+	}
+
+	return conn, nil
+}
+
+// Wallet returns a copy of the Connection which directs requests to
+// the /wallet/<name> endpoint of the same node.
+func (conn *Connection) Wallet(name string) *Connection {
+	return &Connection{
+		URL:            walletURL(conn.URL, name),
+		HttpClient:     conn.HttpClient,
+		MaxRetries:     conn.MaxRetries,
+		InitialBackoff: conn.InitialBackoff,
+		MaxBackoff:     conn.MaxBackoff,
+		username:       conn.username,
+		password:       conn.password,
+		requestIDMutex: new(sync.Mutex),
+	}
+}
+
+func walletURL(nodeURL *url.URL, name string) *url.URL {
+	basePath := nodeURL.Path
+	if i := strings.Index(basePath, "/wallet/"); i >= 0 {
+		basePath = basePath[:i]
+	}
+	basePath = strings.TrimSuffix(basePath, "/")
+
+	u := *nodeURL
+	u.Path = basePath + "/wallet/" + name
+	u.RawPath = basePath + "/wallet/" + url.PathEscape(name)
+	return &u
+}
+
+func withTLSConfig(client *http.Client, tlsConfig *tls.Config) *http.Client {
+	var transport *http.Transport
+	if t, ok := client.Transport.(*http.Transport); ok {
+		transport = t.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	clientCopy := *client
+	clientCopy.Transport = transport
+	return &clientCopy
+}
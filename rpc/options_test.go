@@ -0,0 +1,116 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+type countingTransport struct {
+	count int32
+}
+
+func (transport *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&transport.count, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNew_Options(t *testing.T) {
+	var requestPaths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, _ := r.BasicAuth(); username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requestPaths = append(requestPaths, r.URL.EscapedPath())
+		w.Write([]byte(`{"id": 0, "result": 1, "error": null}`))
+	}))
+	defer server.Close()
+
+	transport := new(countingTransport)
+	conn, err := New(
+		server.URL,
+		WithCredentials("user", "pass"),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithWallet("my wallet"),
+	)
+	if err != nil {
+		t.Errorf("failed to create connection: %s", err)
+		return
+	}
+
+	ctx := context.Background()
+	if _, err := conn.RequestContext(ctx, "getbalance"); err != nil {
+		t.Errorf("failed to request: %s", err)
+		return
+	}
+	if _, err := conn.Wallet("other").RequestContext(ctx, "getbalance"); err != nil {
+		t.Errorf("failed to request: %s", err)
+		return
+	}
+
+	if transport.count != 2 {
+		t.Errorf("custom HTTP client was not used")
+		return
+	}
+
+	expectedPaths := []string{"/wallet/my%20wallet", "/wallet/other"}
+	if len(requestPaths) != 2 || requestPaths[0] != expectedPaths[0] || requestPaths[1] != expectedPaths[1] {
+		t.Errorf("incorrect request paths\nWanted %v\nGot    %v", expectedPaths, requestPaths)
+		return
+	}
+
+	conn, err = New(server.URL, WithCredentials("user", "wrong"))
+	if err != nil {
+		t.Errorf("failed to create connection: %s", err)
+		return
+	}
+	if _, err := conn.Request("getblockcount"); err != ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+		return
+	}
+
+	if _, err := New("ftp://127.0.0.1:8332"); err != ErrInvalidUrl {
+		t.Errorf("expected ErrInvalidUrl, got %v", err)
+		return
+	}
+}
+
+func TestNew_TLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 0, "result": 1, "error": null}`))
+	}))
+	defer server.Close()
+
+	conn, err := New(server.URL)
+	if err != nil {
+		t.Errorf("failed to create connection: %s", err)
+		return
+	}
+	if _, err := conn.Request("getblockcount"); err == nil {
+		t.Errorf("expected certificate verification to fail without TLS config")
+		return
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	conn, err = New(server.URL, WithTLSConfig(&tls.Config{RootCAs: roots}))
+	if err != nil {
+		t.Errorf("failed to create connection: %s", err)
+		return
+	}
+	if _, err := conn.Request("getblockcount"); err != nil {
+		t.Errorf("failed to request with TLS config: %s", err)
+		return
+	}
+
+	if conn.HttpClient == http.DefaultClient {
+		t.Errorf("WithTLSConfig modified http.DefaultClient")
+		return
+	}
+}
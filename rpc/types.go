@@ -0,0 +1,102 @@
+package rpc
+
+import (
+	"github.com/kklash/bitcoinlib/satutil"
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/unspent"
+)
+
+// BlockchainInfo is the result of the getblockchaininfo RPC method.
+type BlockchainInfo struct {
+	Chain                string  `json:"chain"`
+	Blocks               int     `json:"blocks"`
+	Headers              int     `json:"headers"`
+	BestBlockHash        string  `json:"bestblockhash"`
+	Difficulty           float64 `json:"difficulty"`
+	Time                 int64   `json:"time"`
+	MedianTime           int64   `json:"mediantime"`
+	VerificationProgress float64 `json:"verificationprogress"`
+	InitialBlockDownload bool    `json:"initialblockdownload"`
+	ChainWork            string  `json:"chainwork"`
+	SizeOnDisk           uint64  `json:"size_on_disk"`
+	Pruned               bool    `json:"pruned"`
+	PruneHeight          int     `json:"pruneheight"`
+}
+
+// MempoolInfo is the result of the getmempoolinfo RPC method.
+// Fee values are denominated in BTC, and fee rates in BTC per 1000 virtual bytes.
+type MempoolInfo struct {
+	Loaded           bool    `json:"loaded"`
+	Size             int     `json:"size"`
+	Bytes            int     `json:"bytes"`
+	Usage            int     `json:"usage"`
+	TotalFee         float64 `json:"total_fee"`
+	MaxMempool       int     `json:"maxmempool"`
+	MempoolMinFee    float64 `json:"mempoolminfee"`
+	MinRelayTxFee    float64 `json:"minrelaytxfee"`
+	UnbroadcastCount int     `json:"unbroadcastcount"`
+}
+
+// MempoolAcceptResult is the result of testmempoolaccept for a single transaction.
+type MempoolAcceptResult struct {
+	Txid         string `json:"txid"`
+	Wtxid        string `json:"wtxid"`
+	Allowed      bool   `json:"allowed"`
+	VSize        int    `json:"vsize"`
+	RejectReason string `json:"reject-reason"`
+	Fees         struct {
+		Base float64 `json:"base"`
+	} `json:"fees"`
+}
+
+// EstimateMode is the fee estimation mode passed to the estimatesmartfee RPC method.
+type EstimateMode string
+
+const (
+	EstimateModeUnset        EstimateMode = "unset"
+	EstimateModeEconomical   EstimateMode = "economical"
+	EstimateModeConservative EstimateMode = "conservative"
+)
+
+// FeeEstimate is the result of the estimatesmartfee RPC method.
+type FeeEstimate struct {
+	// FeeRate is the estimated fee rate in BTC per 1000 virtual bytes.
+	// It is zero if the node could not produce an estimate.
+	FeeRate float64  `json:"feerate"`
+	Errors  []string `json:"errors"`
+	Blocks  int      `json:"blocks"`
+}
+
+// SatsPerVByte returns the estimated fee rate in satoshis per virtual byte.
+func (estimate *FeeEstimate) SatsPerVByte() float64 {
+	return float64(satutil.BitcoinsToSats(estimate.FeeRate)) / 1000
+}
+
+// TxOut is the result of the gettxout RPC method.
+type TxOut struct {
+	// BestBlock is the hash of the node's best block, in RPC byte order.
+	BestBlock     [32]byte
+	Confirmations int
+	Output        *tx.Output
+	Coinbase      bool
+}
+
+// ScanTxOutSetResult is the result of the scantxoutset RPC method.
+type ScanTxOutSetResult struct {
+	Height int
+
+	// BestBlock is the hash of the block at Height, in RPC byte order.
+	BestBlock   [32]byte
+	Unspents    []*ScannedUnspent
+	TotalAmount uint64
+}
+
+// ScannedUnspent is an unspent output found by the scantxoutset RPC method.
+type ScannedUnspent struct {
+	*unspent.Output
+
+	// Descriptor is the output descriptor which matched the output.
+	Descriptor string
+	Coinbase   bool
+	Height     int
+}
@@ -106,3 +106,43 @@ func MakeP2TR(internalPublicKey []byte, scriptTree Hasher) ([]byte, error) {
 	scriptPubKey := append([]byte{constants.OP_TRUE}, PushData(outputPublicKey)...)
 	return scriptPubKey, nil
 }
+
+// MakeP2TRFromOutputKey creates a taproot P2TR output using an already-tweaked
+// 32-byte output public key. Returns ErrInvalidPublicKeyLength if the key is not
+// a 32-byte schnorr public key.
+//
+//	OP_1 <output_pubkey>
+func MakeP2TRFromOutputKey(outputPublicKey []byte) ([]byte, error) {
+	if len(outputPublicKey) != constants.PublicKeySchnorrLength {
+		return nil, ErrInvalidPublicKeyLength
+	}
+
+	scriptPubKey := append([]byte{constants.OP_1}, PushData(outputPublicKey)...)
+	return scriptPubKey, nil
+}
+
+// IsP2TR returns whether a byte slice is a valid P2TR output script.
+//
+//	script, _ := hex.DecodeString("512053a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343")
+//	IsP2TR(script) // true
+//	IsP2TR(script[1:]) // false
+func IsP2TR(script []byte) bool {
+	return script != nil &&
+		len(script) == 34 &&
+		script[0] == constants.OP_1 &&
+		script[1] == 0x20
+}
+
+// DecodeP2TR attempts to decode the given byte slice as a P2TR script
+// pub key. It returns the output public key contained in the script.
+// Returns ErrInvalidScript if the script is not P2TR.
+func DecodeP2TR(script []byte) (outputPublicKey []byte, err error) {
+	if !IsP2TR(script) {
+		err = ErrInvalidScript
+		return
+	}
+
+	outputPublicKey = make([]byte, 32)
+	copy(outputPublicKey, script[2:34])
+	return
+}
@@ -91,7 +91,7 @@ func TestWitnessP2WSH(t *testing.T) {
 
 	sig1 := hex2bytes("3043021f2723ef0bc37f345b7c7388bd4008963662af2a8c9d09f93dc3b113efc9cf8a022009f9ab57bbe76d9b9d3fcde7c745d1c57d741adb7c36157c872887a9bb329df301")
 	sig2 := hex2bytes("3045022100f9cafaa1a2d2a248a47eda156e21b79110b82b89215ccae9c347ad8f713da51202201e8c40509b33fa18c10307c71cc341faa89cd6d3d6cdf0c1d80cc26ea21e8d0b01")
-	multisigScriptSig := append(RedeemP2MS(sig1, sig2))
+	multisigScriptSig := RedeemP2MS(sig1, sig2)
 
 	w, err := WitnessP2WSH(multisigScriptPubKey, multisigScriptSig)
 	if err != nil {
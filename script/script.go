@@ -35,6 +35,8 @@ func ClassifyOutput(script []byte) constants.AddressFormat {
 		return constants.FormatP2WPKH
 	case IsP2WSH(script):
 		return constants.FormatP2WSH
+	case IsP2TR(script):
+		return constants.FormatP2TR
 	default:
 		return constants.FormatNONSTANDARD
 	}
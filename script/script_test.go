@@ -61,6 +61,10 @@ func TestClassifyOutput(t *testing.T) {
 			hex2bytes("76a91491c79c05a31adead59033ebf47acab299b4cdba488ac"),
 			constants.FormatP2PKH,
 		},
+		{
+			hex2bytes("512053a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343"),
+			constants.FormatP2TR,
+		},
 		{
 			hex2bytes("0014ce6a28589e056b0bdd67c464033677a7ac35ce0511"),
 			constants.FormatNONSTANDARD,
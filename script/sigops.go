@@ -0,0 +1,119 @@
+package script
+
+import (
+	"bytes"
+
+	"github.com/kklash/bitcoinlib/constants"
+)
+
+// MaxPublicKeysPerMultisig is the number of signature operations counted for an
+// OP_CHECKMULTISIG when the number of public keys is not known.
+const MaxPublicKeysPerMultisig = 20
+
+// CountSigOps counts the signature operations in a script, as in Bitcoin Core's
+// GetSigOpCount. OP_CHECKSIG and OP_CHECKSIGVERIFY count as one. OP_CHECKMULTISIG and
+// OP_CHECKMULTISIGVERIFY count as MaxPublicKeysPerMultisig, unless accurate is true and
+// they are preceded by OP_1 through OP_16, in which case that number is counted.
+// Counting stops at the first invalid push in the script.
+func CountSigOps(script []byte, accurate bool) int {
+	count := 0
+	var lastOp byte = 0xff
+
+	r := bytes.NewReader(script)
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		if op > 0 && op <= constants.OP_PUSHDATA4 {
+			r.UnreadByte()
+			if _, err := ReadData(r); err != nil {
+				break
+			}
+		}
+
+		switch op {
+		case constants.OP_CHECKSIG, constants.OP_CHECKSIGVERIFY:
+			count++
+		case constants.OP_CHECKMULTISIG, constants.OP_CHECKMULTISIGVERIFY:
+			if accurate && lastOp >= constants.OP_1 && lastOp <= constants.OP_16 {
+				count += int(lastOp-constants.OP_1) + 1
+			} else {
+				count += MaxPublicKeysPerMultisig
+			}
+		}
+		lastOp = op
+	}
+
+	return count
+}
+
+// CountP2SHSigOps counts the signature operations in the redeem script of a P2SH input,
+// which is the last item pushed by scriptSig. Returns zero if scriptSig is not push-only.
+func CountP2SHSigOps(scriptSig []byte) int {
+	redeemScript, ok := lastPush(scriptSig)
+	if !ok {
+		return 0
+	}
+	return CountSigOps(redeemScript, true)
+}
+
+// CountWitnessSigOps counts the signature operations executed by a segwit input spending
+// scriptPubKey, including P2SH-wrapped witness programs, as in Bitcoin Core's
+// CountWitnessSigOps. P2WPKH inputs count as one, and P2WSH inputs count the signature
+// operations in the witness script. Returns zero for non-segwit inputs and for
+// witness versions other than 0.
+func CountWitnessSigOps(scriptSig, scriptPubKey []byte, witness [][]byte) int {
+	if version, program, ok := parseWitnessProgram(scriptPubKey); ok {
+		return witnessSigOps(version, program, witness)
+	}
+
+	if IsP2SH(scriptPubKey) {
+		if redeemScript, ok := lastPush(scriptSig); ok {
+			if version, program, ok := parseWitnessProgram(redeemScript); ok {
+				return witnessSigOps(version, program, witness)
+			}
+		}
+	}
+
+	return 0
+}
+
+func witnessSigOps(version int, program []byte, witness [][]byte) int {
+	if version != 0 {
+		return 0
+	}
+
+	if len(program) == 20 {
+		return 1
+	} else if len(program) == 32 && len(witness) > 0 {
+		return CountSigOps(witness[len(witness)-1], true)
+	}
+
+	return 0
+}
+
+// parseWitnessProgram returns the version and program of a segwit output script:
+// a version opcode (OP_0 or OP_1 through OP_16) followed by a single push of 2 to 40 bytes.
+func parseWitnessProgram(script []byte) (version int, program []byte, ok bool) {
+	if len(script) < 4 || len(script) > 42 || int(script[1])+2 != len(script) {
+		return 0, nil, false
+	}
+
+	switch {
+	case script[0] == constants.OP_0:
+		version = 0
+	case script[0] >= constants.OP_1 && script[0] <= constants.OP_16:
+		version = int(script[0]-constants.OP_1) + 1
+	default:
+		return 0, nil, false
+	}
+
+	return version, script[2:], true
+}
+
+// lastPush returns the data pushed by the last operation of a push-only script.
+func lastPush(script []byte) ([]byte, bool) {
+	stack, err := Stackify(script)
+	if err != nil || len(stack) == 0 {
+		return nil, false
+	}
+	return stack[len(stack)-1], true
+}
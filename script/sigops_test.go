@@ -0,0 +1,90 @@
+package script
+
+import (
+	"testing"
+
+	"github.com/kklash/bitcoinlib/constants"
+)
+
+func TestCountSigOps(t *testing.T) {
+	publicKey := make([]byte, 33)
+	multisig := MakeP2MS(2, publicKey, publicKey, publicKey)
+
+	fixtures := []struct {
+		script     []byte
+		inaccurate int
+		accurate   int
+	}{
+		{[]byte{}, 0, 0},
+		{[]byte{constants.OP_CHECKSIG}, 1, 1},
+		{[]byte{constants.OP_CHECKSIGVERIFY, constants.OP_CHECKSIG}, 2, 2},
+		{multisig, 20, 3},
+		{[]byte{constants.OP_CHECKMULTISIGVERIFY}, 20, 20},
+		{append(MakeP2MS(1, publicKey), constants.OP_CHECKSIG), 21, 2},
+
+		// Signature opcodes inside pushes are not counted.
+		{PushData([]byte{constants.OP_CHECKSIG}), 0, 0},
+
+		// Counting stops at an invalid push.
+		{[]byte{constants.OP_CHECKSIG, constants.OP_PUSHDATA1, 5, constants.OP_CHECKSIG}, 1, 1},
+	}
+
+	for _, fixture := range fixtures {
+		if count := CountSigOps(fixture.script, false); count != fixture.inaccurate {
+			t.Errorf("unexpected sigop count for %x\nWanted %d\nGot    %d", fixture.script, fixture.inaccurate, count)
+			continue
+		}
+
+		if count := CountSigOps(fixture.script, true); count != fixture.accurate {
+			t.Errorf("unexpected accurate sigop count for %x\nWanted %d\nGot    %d", fixture.script, fixture.accurate, count)
+			continue
+		}
+	}
+}
+
+func TestCountP2SHSigOps(t *testing.T) {
+	publicKey := make([]byte, 33)
+	redeemScript := MakeP2MS(2, publicKey, publicKey, publicKey)
+	scriptSig := append([]byte{constants.OP_0}, PushData(redeemScript)...)
+
+	if count := CountP2SHSigOps(scriptSig); count != 3 {
+		t.Errorf("expected 3 P2SH sigops, got %d", count)
+		return
+	}
+
+	if count := CountP2SHSigOps(append(scriptSig, constants.OP_CHECKSIG)); count != 0 {
+		t.Errorf("expected 0 sigops for non-push-only scriptSig, got %d", count)
+		return
+	}
+}
+
+func TestCountWitnessSigOps(t *testing.T) {
+	publicKey := make([]byte, 33)
+	witnessScript := MakeP2MS(2, publicKey, publicKey, publicKey)
+	p2wpkh := MakeP2WPKHFromHash([20]byte{})
+	p2wsh := MakeP2WSHFromScript(witnessScript)
+
+	fixtures := []struct {
+		name         string
+		scriptSig    []byte
+		scriptPubKey []byte
+		witness      [][]byte
+		expected     int
+	}{
+		{"p2wpkh", nil, p2wpkh, [][]byte{{}, publicKey}, 1},
+		{"p2wsh", nil, p2wsh, [][]byte{{}, {}, witnessScript}, 3},
+		{"p2wsh without witness", nil, p2wsh, nil, 0},
+		{"p2sh-p2wpkh", PushData(p2wpkh), MakeP2SHFromScript(p2wpkh), [][]byte{{}, publicKey}, 1},
+		{"p2sh-p2wsh", PushData(p2wsh), MakeP2SHFromScript(p2wsh), [][]byte{witnessScript}, 3},
+		{"p2tr", nil, append([]byte{constants.OP_1, 32}, make([]byte, 32)...), [][]byte{witnessScript, {0xc0}}, 0},
+		{"p2pkh", nil, MakeP2PKHFromHash([20]byte{}), nil, 0},
+	}
+
+	for _, fixture := range fixtures {
+		count := CountWitnessSigOps(fixture.scriptSig, fixture.scriptPubKey, fixture.witness)
+		if count != fixture.expected {
+			t.Errorf("%s: unexpected witness sigop count\nWanted %d\nGot    %d", fixture.name, fixture.expected, count)
+			continue
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package signer
+
+import (
+	"github.com/kklash/bitcoinlib/taproot"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+// SignInputP2TR signs a key-path spend of a P2TR output whose internal key is the public key of
+// privateKey, and which commits to no script tree. prevOuts must contain the outputs spent by
+// every input of txn, as required by BIP341 signature hashing.
+func SignInputP2TR(txn *tx.Tx, nInput int, privateKey []byte, sigHashType uint32, prevOuts []*tx.Output) error {
+	if nInput < 0 || nInput >= len(txn.Inputs) {
+		return ErrInputOutOfRange
+	}
+
+	tweakedPrivateKey, err := taproot.TweakPrivateKey(privateKey, nil)
+	if err != nil {
+		return err
+	}
+
+	sigHash, err := txn.SignatureHashForTaprootInput(nInput, prevOuts, sigHashType, nil)
+	if err != nil {
+		return err
+	}
+
+	signature, err := SignSigHashSchnorr(sigHash[:], tweakedPrivateKey, sigHashType)
+	if err != nil {
+		return err
+	}
+
+	if txn.Witnesses == nil {
+		txn.Witnesses = make([]tx.Witness, len(txn.Inputs))
+	}
+
+	for i := 0; i < len(txn.Inputs); i++ {
+		if i == nInput {
+			txn.Witnesses[i] = tx.Witness{signature}
+		} else if txn.Witnesses[i] == nil {
+			txn.Witnesses[i] = tx.Witness{}
+		}
+	}
+
+	// Segwit signatures use empty input scripts
+	txn.Inputs[nInput].Script = []byte{}
+
+	return nil
+}
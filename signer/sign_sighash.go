@@ -1,12 +1,41 @@
 package signer
 
 import (
+	"crypto/rand"
+	"math/big"
+
+	"github.com/kklash/bitcoinlib/constants"
 	"github.com/kklash/bitcoinlib/der"
 	"github.com/kklash/bitcoinlib/ecc"
 )
 
-func SignSigHash(hash, privateKey []byte, sigHashType uint32) ([]byte, error) {
-	r, s := ecc.SignECDSA(privateKey, hash)
+// SignOption modifies how SignSigHash creates ECDSA signatures.
+type SignOption int
+
+const (
+	// LowR grinds the RFC6979 extra entropy counter until the signature's R value is less
+	// than 2^255, the same way Bitcoin Core does. The resulting DER signature is at most 71
+	// bytes including the sighash type byte, which makes transaction sizes predictable.
+	LowR SignOption = iota + 1
+)
+
+// SignSigHash signs a signature hash with a deterministic low-S ECDSA signature, and returns
+// the DER-encoded signature with the sigHashType byte appended. Pass LowR to also ensure the
+// signature has a low R value.
+func SignSigHash(hash, privateKey []byte, sigHashType uint32, opts ...SignOption) ([]byte, error) {
+	lowR := false
+	for _, opt := range opts {
+		if opt == LowR {
+			lowR = true
+		}
+	}
+
+	var r, s *big.Int
+	if lowR {
+		r, s = ecc.SignECDSALowR(privateKey, hash)
+	} else {
+		r, s = ecc.SignECDSA(privateKey, hash)
+	}
 
 	signature, err := der.EncodeSignature(r, s, sigHashType)
 	if err != nil {
@@ -15,3 +44,20 @@ func SignSigHash(hash, privateKey []byte, sigHashType uint32) ([]byte, error) {
 
 	return signature, nil
 }
+
+// SignSigHashSchnorr signs a taproot signature hash with a BIP340 schnorr signature, using
+// fresh randomness from crypto/rand as auxiliary data. The sigHashType is appended to the
+// 64-byte signature unless it is constants.SigHashDefault.
+func SignSigHashSchnorr(hash, privateKey []byte, sigHashType uint32) ([]byte, error) {
+	auxRand := make([]byte, 32)
+	if _, err := rand.Read(auxRand); err != nil {
+		return nil, err
+	}
+
+	signature := ecc.SignSchnorr(privateKey, hash, auxRand)
+	if sigHashType != constants.SigHashDefault {
+		signature = append(signature, byte(sigHashType))
+	}
+
+	return signature, nil
+}
@@ -0,0 +1,40 @@
+package signer
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/der"
+)
+
+func TestSignSigHash_LowR(t *testing.T) {
+	privateKey := mustHex("12b004fff7f4b69ef8650e767f18f11ede158148b425660723b9f9a66e61f747")
+
+	for i := 0; i < 32; i++ {
+		hash := sha256.Sum256([]byte{byte(i)})
+
+		signature, err := SignSigHash(hash[:], privateKey, constants.SigHashAll, LowR)
+		if err != nil {
+			t.Errorf("failed to sign sighash: %s", err)
+			return
+		}
+
+		if len(signature) > 71 {
+			t.Errorf("expected low-R signature to be at most 71 bytes; got %d", len(signature))
+			return
+		}
+
+		r, _, sigHashType, err := der.DecodeSignature(signature)
+		if err != nil {
+			t.Errorf("failed to decode signature: %s", err)
+			return
+		} else if r.BitLen() > 255 {
+			t.Errorf("expected signature to have low R value: %X", r)
+			return
+		} else if sigHashType != constants.SigHashAll {
+			t.Errorf("unexpected sighash type %d", sigHashType)
+			return
+		}
+	}
+}
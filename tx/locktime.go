@@ -0,0 +1,49 @@
+package tx
+
+const (
+	// LocktimeThreshold is the Locktime value below which a Locktime is interpreted
+	// as a block height, and at or above which it is interpreted as a unix timestamp.
+	LocktimeThreshold = 500000000
+
+	// SequenceFinal is the input sequence number which disables the transaction Locktime,
+	// if it is set on every input.
+	SequenceFinal = 0xffffffff
+)
+
+// IsCoinbase returns true if the transaction is a coinbase transaction, which has a
+// single input spending a null PrevOut: the zero hash, with index 0xffffffff.
+func (tx *Tx) IsCoinbase() bool {
+	if len(tx.Inputs) != 1 || tx.Inputs[0].PrevOut == nil {
+		return false
+	}
+
+	prevOut := tx.Inputs[0].PrevOut
+	return prevOut.Hash == [32]byte{} && prevOut.Index == 0xffffffff
+}
+
+// IsFinal returns true if the transaction may be included in a block with the given height
+// and time. A transaction is final if its Locktime is zero, if its Locktime is less than the
+// block height or time, or if all its inputs have sequence numbers equal to SequenceFinal.
+//
+// After BIP113, blockTime should be the median time past of the block's previous block.
+func (tx *Tx) IsFinal(height int64, blockTime int64) bool {
+	if tx.Locktime == 0 {
+		return true
+	}
+
+	cutoff := height
+	if tx.Locktime >= LocktimeThreshold {
+		cutoff = blockTime
+	}
+	if int64(tx.Locktime) < cutoff {
+		return true
+	}
+
+	for _, input := range tx.Inputs {
+		if input.Sequence != SequenceFinal {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,62 @@
+package tx
+
+import (
+	"testing"
+)
+
+func TestIsCoinbase(t *testing.T) {
+	coinbase := &Tx{
+		Inputs: []*Input{{PrevOut: &PrevOut{Index: 0xffffffff}, Script: []byte{1, 2}}},
+	}
+	if !coinbase.IsCoinbase() {
+		t.Errorf("expected transaction to be a coinbase")
+		return
+	}
+
+	notCoinbase := &Tx{
+		Inputs: []*Input{{PrevOut: &PrevOut{Index: 0}}},
+	}
+	if notCoinbase.IsCoinbase() {
+		t.Errorf("expected transaction spending index 0 not to be a coinbase")
+		return
+	}
+
+	notCoinbase.Inputs = append(coinbase.Inputs, coinbase.Inputs[0])
+	if notCoinbase.IsCoinbase() {
+		t.Errorf("expected transaction with two inputs not to be a coinbase")
+		return
+	}
+}
+
+func TestIsFinal(t *testing.T) {
+	fixtures := []struct {
+		locktime  uint32
+		sequence  uint32
+		height    int64
+		blockTime int64
+		final     bool
+	}{
+		{0, 0, 0, 0, true},
+		{100, 0, 100, 0, false},
+		{100, 0, 101, 0, true},
+		{100, SequenceFinal, 100, 0, true},
+		{LocktimeThreshold, 0, 1000000000, LocktimeThreshold, false},
+		{LocktimeThreshold, 0, 0, LocktimeThreshold + 1, true},
+		{LocktimeThreshold + 1, SequenceFinal, 0, 0, true},
+	}
+
+	for _, fixture := range fixtures {
+		txn := &Tx{
+			Inputs:   []*Input{{PrevOut: &PrevOut{}, Sequence: fixture.sequence}},
+			Locktime: fixture.locktime,
+		}
+
+		if final := txn.IsFinal(fixture.height, fixture.blockTime); final != fixture.final {
+			t.Errorf(
+				"unexpected finality for locktime %d at height %d and time %d\nWanted %v\nGot    %v",
+				fixture.locktime, fixture.height, fixture.blockTime, fixture.final, final,
+			)
+			continue
+		}
+	}
+}
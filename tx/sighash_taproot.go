@@ -0,0 +1,216 @@
+package tx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/kklash/bitcoinlib/bhash"
+	"github.com/kklash/bitcoinlib/constants"
+	"github.com/kklash/bitcoinlib/varint"
+)
+
+var (
+	// ErrInvalidTaprootSigHash is returned when a BIP341 signature hash cannot be computed,
+	// either because of an invalid sighash type, or because of missing transaction data.
+	ErrInvalidTaprootSigHash = errors.New("cannot compute taproot signature hash")
+
+	tapSigHashHasher = bhash.NewTaggedHasher("TapSighash")
+)
+
+/* https://github.com/bitcoin/bips/blob/master/bip-0341.mediawiki#common-signature-message
+   Tagged hash of the serialization of:
+    1. epoch (0x00)
+    2. hash_type (1 byte)
+    3. nVersion, nLockTime of the transaction (4-byte little endian)
+    4. sha_prevouts, sha_amounts, sha_scriptpubkeys, sha_sequences (unless ANYONECANPAY)
+    5. sha_outputs (unless NONE or SINGLE)
+    6. spend_type (1 byte)
+    7. outpoint, amount, scriptPubKey and nSequence of this input (if ANYONECANPAY),
+       otherwise the index of this input (4-byte little endian)
+    8. sha_annex (if an annex is present)
+    9. sha_single_output (if SINGLE)
+   10. tapleaf_hash, key_version and codesep_pos (for script path spends only)
+*/
+
+// SignatureHashForTaprootInput computes the BIP341 signature hash for a key-path spend of a taproot
+// input. prevOuts must contain the outputs being spent by every input of the transaction, in the
+// same order as tx.Inputs. annex should be nil unless the input's witness includes an annex.
+//
+// Unlike legacy and segwit v0 signature hashes, taproot signature hashes are not reversed when
+// displayed as hex. Returns an error wrapping ErrInvalidTaprootSigHash if sigHashType is not
+// valid under BIP341 or if the transaction data needed to compute the hash is missing.
+func (tx *Tx) SignatureHashForTaprootInput(
+	nInput int,
+	prevOuts []*Output,
+	sigHashType uint32,
+	annex []byte,
+) ([32]byte, error) {
+	return tx.taprootSignatureHash(nInput, prevOuts, sigHashType, annex, nil)
+}
+
+// SignatureHashForTapscriptInput computes the BIP342 signature hash for a script-path spend of
+// a taproot input. leafHash is the tagged hash of the tapscript leaf being executed, and
+// codeSeparatorPosition is the opcode index of the last executed OP_CODESEPARATOR in the
+// tapscript, or 0xffffffff if none was executed. See SignatureHashForTaprootInput for
+// the other parameters.
+func (tx *Tx) SignatureHashForTapscriptInput(
+	nInput int,
+	prevOuts []*Output,
+	sigHashType uint32,
+	annex []byte,
+	leafHash [32]byte,
+	codeSeparatorPosition uint32,
+) ([32]byte, error) {
+	ext := make([]byte, 32+1+4)
+	copy(ext, leafHash[:])
+	ext[32] = 0 // key_version
+	binary.LittleEndian.PutUint32(ext[33:], codeSeparatorPosition)
+	return tx.taprootSignatureHash(nInput, prevOuts, sigHashType, annex, ext)
+}
+
+func isValidTaprootSigHashType(sigHashType uint32) bool {
+	switch sigHashType &^ constants.SigHashAnyoneCanPay {
+	case constants.SigHashAll, constants.SigHashNone, constants.SigHashSingle:
+		return true
+	case constants.SigHashDefault:
+		return sigHashType == constants.SigHashDefault
+	}
+	return false
+}
+
+func (tx *Tx) taprootSignatureHash(
+	nInput int,
+	prevOuts []*Output,
+	sigHashType uint32,
+	annex []byte,
+	ext []byte,
+) (hashed [32]byte, err error) {
+	msg, err := tx.taprootSignatureMessage(nInput, prevOuts, sigHashType, annex, ext)
+	if err != nil {
+		return
+	}
+
+	copy(hashed[:], tapSigHashHasher(msg))
+	return
+}
+
+// taprootSignatureMessage serializes the BIP341 signature message, which is hashed with the
+// TapSighash tag to compute the signature hash. ext is the BIP342 extension for script-path
+// spends, or nil for key-path spends.
+func (tx *Tx) taprootSignatureMessage(
+	nInput int,
+	prevOuts []*Output,
+	sigHashType uint32,
+	annex []byte,
+	ext []byte,
+) (_ []byte, err error) {
+	if !isValidTaprootSigHashType(sigHashType) {
+		err = fmt.Errorf("%w: invalid sighash type 0x%x", ErrInvalidTaprootSigHash, sigHashType)
+		return
+	} else if nInput < 0 || nInput >= len(tx.Inputs) {
+		err = fmt.Errorf("%w: input index %d out of range", ErrInvalidTaprootSigHash, nInput)
+		return
+	} else if len(prevOuts) != len(tx.Inputs) {
+		err = fmt.Errorf("%w: expected %d prevouts, got %d", ErrInvalidTaprootSigHash, len(tx.Inputs), len(prevOuts))
+		return
+	}
+
+	var (
+		outputType          = sigHashType & 0x03
+		sigHashNone         = outputType == constants.SigHashNone
+		sigHashSingle       = outputType == constants.SigHashSingle
+		sigHashAnyoneCanPay = sigHashType&constants.SigHashAnyoneCanPay > 0
+	)
+
+	if sigHashSingle && nInput >= len(tx.Outputs) {
+		err = fmt.Errorf("%w: no output corresponding to SIGHASH_SINGLE input %d", ErrInvalidTaprootSigHash, nInput)
+		return
+	}
+
+	msg := new(bytes.Buffer)
+	msg.WriteByte(0) // epoch
+	msg.WriteByte(byte(sigHashType))
+	binary.Write(msg, binary.LittleEndian, tx.Version)
+	binary.Write(msg, binary.LittleEndian, tx.Locktime)
+
+	if !sigHashAnyoneCanPay {
+		var (
+			shaPrevouts      = sha256.New()
+			shaAmounts       = sha256.New()
+			shaScriptPubKeys = sha256.New()
+			shaSequences     = sha256.New()
+		)
+
+		for i, vin := range tx.Inputs {
+			if prevOuts[i] == nil || prevOuts[i].Script == nil {
+				err = fmt.Errorf("%w: missing prevout for input %d", ErrInvalidTaprootSigHash, i)
+				return
+			}
+			vin.PrevOut.WriteTo(shaPrevouts)
+			binary.Write(shaAmounts, binary.LittleEndian, prevOuts[i].Value)
+			varint.VarInt(len(prevOuts[i].Script)).WriteTo(shaScriptPubKeys)
+			shaScriptPubKeys.Write(prevOuts[i].Script)
+			binary.Write(shaSequences, binary.LittleEndian, vin.Sequence)
+		}
+
+		msg.Write(shaPrevouts.Sum(nil))
+		msg.Write(shaAmounts.Sum(nil))
+		msg.Write(shaScriptPubKeys.Sum(nil))
+		msg.Write(shaSequences.Sum(nil))
+	}
+
+	if !sigHashNone && !sigHashSingle {
+		shaOutputs := sha256.New()
+		for _, vout := range tx.Outputs {
+			if _, err = vout.WriteTo(shaOutputs); err != nil {
+				return
+			}
+		}
+		msg.Write(shaOutputs.Sum(nil))
+	}
+
+	var spendType byte
+	if ext != nil {
+		spendType |= 2
+	}
+	if annex != nil {
+		spendType |= 1
+	}
+	msg.WriteByte(spendType)
+
+	if sigHashAnyoneCanPay {
+		prevOut := prevOuts[nInput]
+		if prevOut == nil || prevOut.Script == nil {
+			err = fmt.Errorf("%w: missing prevout for input %d", ErrInvalidTaprootSigHash, nInput)
+			return
+		}
+		tx.Inputs[nInput].PrevOut.WriteTo(msg)
+		binary.Write(msg, binary.LittleEndian, prevOut.Value)
+		varint.VarInt(len(prevOut.Script)).WriteTo(msg)
+		msg.Write(prevOut.Script)
+		binary.Write(msg, binary.LittleEndian, tx.Inputs[nInput].Sequence)
+	} else {
+		binary.Write(msg, binary.LittleEndian, uint32(nInput))
+	}
+
+	if annex != nil {
+		shaAnnex := sha256.New()
+		varint.VarInt(len(annex)).WriteTo(shaAnnex)
+		shaAnnex.Write(annex)
+		msg.Write(shaAnnex.Sum(nil))
+	}
+
+	if sigHashSingle {
+		shaSingleOutput := sha256.New()
+		if _, err = tx.Outputs[nInput].WriteTo(shaSingleOutput); err != nil {
+			return
+		}
+		msg.Write(shaSingleOutput.Sum(nil))
+	}
+
+	msg.Write(ext)
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,190 @@
+package tx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/constants"
+)
+
+// bip341Tx returns a transaction and spent outputs modelled on the keyPathSpending test
+// vector in BIP341's wallet-test-vectors.json. Only the expected values for input 1 are
+// taken from the vector.
+func bip341Tx() (*Tx, []*Output) {
+	txn, err := FromBytes(mustHex("02000000097de20cbff686da83a54981d2b9bab3586f4ca7e48f57f5b55963115f3b334e9c010000000000000000d7b7cab57b1393ace2d064f4d4a2cb8af6def61273e127517d44759b6dafdd990000000000fffffffff8e1f583384333689228c5d28eac13366be082dc57441760d957275419a418420000000000fffffffff0689180aa63b30cb162a73c6d2a38b7eeda2a83ece74310fda0843ad604853b0100000000feffffff0c638ca38362001f5e128a01ae2b379288eb22cfaf903652b2ec1c88588f487a0000000000feffffff956149bdc66faa968eb2be2d2faa29718acbfe3941215893a2a3446d32acd05000000000000000000081efa267f1f0e46e054ecec01773de7c844721e010c2db5d5864a6a6b53e013a010000000000000000a690669c3c4a62507d93609810c6de3f99d1a6e311fe39dd23683d695c07bdee0000000000ffffffff727ab5f877438496f8613ca84002ff38e8292f7bd11f0a9b9b83ebd16779669e0100000000ffffffff0200ca9a3b000000001976a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac807840cb0000000020ac9a87f5594be208f8532db38cff670c450ed2fea8fcdefcc9a663f78bab962b0065cd1d"))
+	if err != nil {
+		panic(err)
+	}
+
+	utxos := []struct {
+		value  uint64
+		script string
+	}{
+		{420000000, "512053a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343"},
+		{462000000, "5120147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3"},
+		{294000000, "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac"},
+		{504000000, "5120e4d810fd50586274face62b8a807eb9719cef49c04177cc6b76a9a4251d5450e"},
+		{630000000, "512091b64d5324723a985170e4dc5a0f84c041804f2cd12660fa5dec09fc21783605"},
+		{378000000, "00147dd65592d0ab2fe0d0257d571abf032cd9db93dc"},
+		{672000000, "512075169f4001aa68f15bbed28b218df1d0a62cbbcf1188c6665110c293c907b831"},
+		{546000000, "5120712447206d7a5238acc7ff53fbe94a3b64539ad291c7cdbc490b7577e4b17df5"},
+		{588000000, "512077e30a5522dd9f894c3f8b8bd4c4b2cf82ca7da8a3ea6a239655c39c050ab220"},
+	}
+
+	prevOuts := make([]*Output, len(utxos))
+	for i, utxo := range utxos {
+		prevOuts[i] = &Output{Value: utxo.value, Script: mustHex(utxo.script)}
+	}
+	return txn, prevOuts
+}
+
+func TestSigHashTaproot_KeyPathSpending(t *testing.T) {
+	txn, prevOuts := bip341Tx()
+
+	// Input 1 of the BIP341 vector signs with SIGHASH_ANYONECANPAY|SIGHASH_SINGLE, so its
+	// signature message commits only to its own outpoint, spent output and sequence, and
+	// to the output at the same index.
+	fixtures := []struct {
+		nInput      int
+		sigHashType uint32
+		sigMsg      string
+		sigHash     string
+	}{
+		{
+			nInput:      1,
+			sigHashType: constants.SigHashAnyoneCanPay | constants.SigHashSingle,
+			sigMsg:      "0083020000000065cd1d00d7b7cab57b1393ace2d064f4d4a2cb8af6def61273e127517d44759b6dafdd9900000000808f891b00000000225120147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3ffffffffffcef8fb4ca7efc5433f591ecfc57391811ce1e186a3793024def5c884cba51d",
+			sigHash:     "325a644af47e8a5a2591cda0ab0723978537318f10e6a63d4eed783b96a71a4d",
+		},
+	}
+
+	for _, fixture := range fixtures {
+		msg, err := txn.taprootSignatureMessage(fixture.nInput, prevOuts, fixture.sigHashType, nil, nil)
+		if err != nil {
+			t.Errorf("failed to build signature message for input %d: %s", fixture.nInput, err)
+			continue
+		} else if hex.EncodeToString(msg) != fixture.sigMsg {
+			t.Errorf("signature message mismatch for input %d\nWanted %s\nGot    %x", fixture.nInput, fixture.sigMsg, msg)
+			continue
+		}
+
+		sigHash, err := txn.SignatureHashForTaprootInput(fixture.nInput, prevOuts, fixture.sigHashType, nil)
+		if err != nil {
+			t.Errorf("failed to compute signature hash for input %d: %s", fixture.nInput, err)
+			continue
+		} else if hex.EncodeToString(sigHash[:]) != fixture.sigHash {
+			t.Errorf("signature hash mismatch for input %d\nWanted %s\nGot    %x", fixture.nInput, fixture.sigHash, sigHash)
+			continue
+		}
+	}
+}
+
+func TestSigHashTaproot_MessageLength(t *testing.T) {
+	txn, prevOuts := bip341Tx()
+	annex := []byte{0x50, 0x01}
+	ext := make([]byte, 37)
+
+	sigHashTypes := []uint32{
+		constants.SigHashDefault,
+		constants.SigHashAll,
+		constants.SigHashNone,
+		constants.SigHashSingle,
+		constants.SigHashAnyoneCanPay | constants.SigHashAll,
+		constants.SigHashAnyoneCanPay | constants.SigHashNone,
+		constants.SigHashAnyoneCanPay | constants.SigHashSingle,
+	}
+
+	for _, sigHashType := range sigHashTypes {
+		for _, withAnnex := range []bool{false, true} {
+			for _, withExt := range []bool{false, true} {
+				// The length of SigMsg given in BIP341, plus the epoch byte and the BIP342 extension.
+				expectedLength := 1 + 174
+				if sigHashType&constants.SigHashAnyoneCanPay != 0 {
+					expectedLength -= 49
+				}
+				if sigHashType&3 == constants.SigHashNone {
+					expectedLength -= 32
+				}
+
+				var inputAnnex, inputExt []byte
+				if withAnnex {
+					inputAnnex = annex
+					expectedLength += 32
+				}
+				if withExt {
+					inputExt = ext
+					expectedLength += len(ext)
+				}
+
+				// Input 0 spends an output with a 34-byte script, which BIP341's formula assumes.
+				msg, err := txn.taprootSignatureMessage(0, prevOuts, sigHashType, inputAnnex, inputExt)
+				if err != nil {
+					t.Errorf("failed to build signature message with sighash type 0x%x: %s", sigHashType, err)
+					continue
+				} else if len(msg) != expectedLength {
+					t.Errorf(
+						"signature message with sighash type 0x%x, annex %v, ext %v has length %d, expected %d",
+						sigHashType, withAnnex, withExt, len(msg), expectedLength,
+					)
+					continue
+				}
+
+				if msg[0] != 0 || uint32(msg[1]) != sigHashType {
+					t.Errorf("signature message does not begin with epoch and sighash type: %x", msg[:2])
+					continue
+				} else if withExt && !bytes.HasSuffix(msg, ext) {
+					t.Errorf("signature message does not end with extension")
+					continue
+				}
+
+				var spendType byte
+				if withExt {
+					spendType |= 2
+				}
+				if withAnnex {
+					spendType |= 1
+				}
+				spendTypeOffset := 1 + 1 + 4 + 4
+				if sigHashType&constants.SigHashAnyoneCanPay == 0 {
+					spendTypeOffset += 4 * 32
+				}
+				if sigHashType&3 != constants.SigHashNone && sigHashType&3 != constants.SigHashSingle {
+					spendTypeOffset += 32
+				}
+				if msg[spendTypeOffset] != spendType {
+					t.Errorf("expected spend type %d, got %d", spendType, msg[spendTypeOffset])
+					continue
+				}
+			}
+		}
+	}
+}
+
+func TestSigHashTaproot_Errors(t *testing.T) {
+	txn, prevOuts := bip341Tx()
+
+	fixtures := []struct {
+		name        string
+		nInput      int
+		prevOuts    []*Output
+		sigHashType uint32
+	}{
+		{"invalid sighash type", 0, prevOuts, 0x04},
+		{"anyonecanpay with default sighash type", 0, prevOuts, constants.SigHashAnyoneCanPay},
+		{"input out of range", len(txn.Inputs), prevOuts, constants.SigHashDefault},
+		{"negative input", -1, prevOuts, constants.SigHashDefault},
+		{"missing prevouts", 0, prevOuts[1:], constants.SigHashDefault},
+		{"nil prevout", 0, append([]*Output{nil}, prevOuts[1:]...), constants.SigHashDefault},
+		{"single without output", len(txn.Outputs), prevOuts, constants.SigHashSingle},
+	}
+
+	for _, fixture := range fixtures {
+		_, err := txn.SignatureHashForTaprootInput(fixture.nInput, fixture.prevOuts, fixture.sigHashType, nil)
+		if !errors.Is(err, ErrInvalidTaprootSigHash) {
+			t.Errorf("%s: expected ErrInvalidTaprootSigHash, got %v", fixture.name, err)
+			continue
+		}
+	}
+}
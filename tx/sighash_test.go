@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/kklash/bitcoinlib/common"
+	"github.com/kklash/bitcoinlib/constants"
 )
 
 func mustHex(s string) []byte {
@@ -118,3 +119,28 @@ func TestSigHashWitness(t *testing.T) {
 		}
 	}
 }
+
+func TestSigHash_SingleWithoutOutput(t *testing.T) {
+	txn := &Tx{
+		Version: 1,
+		Inputs: []*Input{
+			{PrevOut: &PrevOut{Index: 0}, Script: []byte{}, Sequence: 0xffffffff},
+			{PrevOut: &PrevOut{Index: 1}, Script: []byte{}, Sequence: 0xffffffff},
+		},
+		Outputs: []*Output{{Value: 1000, Script: []byte{}}},
+	}
+
+	// Bitcoin Core signs the number one, as a little-endian uint256, when a SIGHASH_SINGLE
+	// input has no corresponding output.
+	var one [32]byte
+	one[0] = 1
+
+	sigHash, err := txn.SignatureHashForInput(1, []byte{}, constants.SigHashSingle)
+	if err != nil {
+		t.Errorf("failed to compute signature hash: %s", err)
+		return
+	} else if sigHash != one {
+		t.Errorf("expected signature hash of one, got %x", sigHash)
+		return
+	}
+}
@@ -0,0 +1,153 @@
+package unspent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/varint"
+)
+
+// ErrInvalidFormat is returned when decoding a serialized Output, OutputSet or Undo which is not valid.
+var ErrInvalidFormat = errors.New("unspent outputs are not formatted correctly")
+
+// maxOutputCount bounds the number of outputs decoded from a serialized OutputSet or Undo,
+// to avoid huge allocations when reading corrupt data.
+const maxOutputCount = 1 << 28
+
+// WriteTo implements the io.WriterTo interface. It writes the serialized outpoint
+// of the Output, followed by the serialized tx.Output.
+func (o *Output) WriteTo(w io.Writer) (n int64, err error) {
+	c, err := o.Outpoint.WriteTo(w)
+	n += c
+	if err != nil {
+		return
+	}
+
+	c, err = o.TxOut.WriteTo(w)
+	n += c
+	return
+}
+
+// Bytes returns the serialized Output as a byte-slice. Returns
+// nil if any error occurs during serialization.
+func (o *Output) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	if _, err := o.WriteTo(buf); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// OutputFromReader decodes a serialized Output from the given reader.
+func OutputFromReader(r io.Reader) (*Output, error) {
+	outpoint := new(tx.PrevOut)
+	if _, err := io.ReadFull(r, outpoint.Hash[:]); err != nil {
+		return nil, eofToFormatError(err)
+	} else if err := binary.Read(r, binary.LittleEndian, &outpoint.Index); err != nil {
+		return nil, eofToFormatError(err)
+	}
+
+	txOut, err := tx.OutputFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Output{Outpoint: outpoint, TxOut: txOut}, nil
+}
+
+func eofToFormatError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrInvalidFormat
+	}
+	return err
+}
+
+func writeOutputs(w io.Writer, outputs []*Output) (n int64, err error) {
+	c, err := varint.VarInt(len(outputs)).WriteTo(w)
+	n += c
+	if err != nil {
+		return
+	}
+
+	for _, output := range outputs {
+		c, err = output.WriteTo(w)
+		n += c
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func readOutputs(r io.Reader) ([]*Output, error) {
+	count, err := varint.FromReader(r)
+	if err != nil {
+		return nil, eofToFormatError(err)
+	} else if count > maxOutputCount {
+		return nil, ErrInvalidFormat
+	} else if count == 0 {
+		return nil, nil
+	}
+
+	capacity := count
+	if capacity > 1024 {
+		capacity = 1024
+	}
+
+	outputs := make([]*Output, 0, capacity)
+	for i := varint.VarInt(0); i < count; i++ {
+		output, err := OutputFromReader(r)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+// WriteTo implements the io.WriterTo interface. It writes the number of outputs in
+// the set as a varint, followed by each serialized Output, ordered by outpoint.
+func (unspentOutputs *OutputSet) WriteTo(w io.Writer) (int64, error) {
+	return writeOutputs(w, unspentOutputs.sortedOutputs())
+}
+
+// OutputSetFromReader decodes a serialized OutputSet from the given reader.
+func OutputSetFromReader(r io.Reader) (*OutputSet, error) {
+	outputs, err := readOutputs(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewOutputSet(outputs), nil
+}
+
+// WriteTo implements the io.WriterTo interface. It writes the spent outputs
+// followed by the created outputs, each prefixed with a varint count.
+func (undo *Undo) WriteTo(w io.Writer) (n int64, err error) {
+	c, err := writeOutputs(w, undo.Spent)
+	n += c
+	if err != nil {
+		return
+	}
+
+	c, err = writeOutputs(w, undo.Created)
+	n += c
+	return
+}
+
+// UndoFromReader decodes a serialized Undo from the given reader.
+func UndoFromReader(r io.Reader) (*Undo, error) {
+	spent, err := readOutputs(r)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := readOutputs(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Undo{Spent: spent, Created: created}, nil
+}
@@ -0,0 +1,407 @@
+package unspent
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+const (
+	// DefaultMaxJournalSize is the journal size above which a FileStore writes
+	// a new snapshot, used if FileStore.MaxJournalSize is zero.
+	DefaultMaxJournalSize = 16 << 20
+
+	// SnapshotFileName is the name of the snapshot file in a FileStore's directory.
+	SnapshotFileName = "utxos.dat"
+
+	// JournalFileName is the name of the journal file in a FileStore's directory.
+	JournalFileName = "journal.dat"
+)
+
+const (
+	// journalChecksumSize is the size of the checksum which ends each journal record.
+	journalChecksumSize = 4
+
+	// maxJournalRecordSize bounds the size of journal records, so that a corrupt size
+	// is treated as a torn write rather than causing a huge allocation.
+	maxJournalRecordSize = 1 << 30
+)
+
+var (
+	// ErrStoreClosed is returned when using a FileStore after it has been closed.
+	ErrStoreClosed = errors.New("unspent output store is closed")
+
+	// ErrStoreFailed is returned when using a FileStore after a write failed in a way which left
+	// its journal in an unknown state. The files on disk are still consistent, so the store can
+	// be closed and reopened.
+	ErrStoreFailed = errors.New("unspent output store failed and must be reopened")
+)
+
+// FileStore is a Store which keeps its outputs in a directory on disk. The directory holds
+// a snapshot of the outputs, written with OutputSet.WriteSnapshot, and a journal of the
+// changes applied since the snapshot was written, one record per block. Each record is
+// checksummed and synced to disk before Apply returns, so a record torn by a crash is
+// detected and discarded when the store is next opened. When the journal grows larger
+// than MaxJournalSize, the changes are folded into a new snapshot.
+//
+// All outputs are also held in memory, so FileStore suits sets of a moderate size, such
+// as those of the scripts watched by a wallet, rather than the entire UTXO set.
+type FileStore struct {
+	// MaxJournalSize is the size in bytes above which the journal is replaced with
+	// a new snapshot. If zero, DefaultMaxJournalSize is used.
+	MaxJournalSize int64
+
+	mutex       sync.RWMutex
+	dir         string
+	journal     *os.File
+	journalSize int64
+	failure     error  // set if the journal is in an unknown state
+	seq         uint64 // sequence number of the latest change
+	tip         [32]byte
+	outputs     *OutputSet
+}
+
+// OpenFileStore opens the FileStore in the given directory, creating the directory if it
+// does not exist. The snapshot is loaded and the journal replayed, discarding any record
+// left incomplete by a crash. Returns ErrInvalidFormat if the files are corrupt.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	store := &FileStore{
+		dir:     dir,
+		outputs: new(OutputSet),
+	}
+	if err := store.readSnapshot(); err != nil {
+		return nil, err
+	}
+
+	journal, err := os.OpenFile(filepath.Join(dir, JournalFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	store.journal = journal
+	syncDir(dir) // in case the journal was just created
+
+	if err := store.replayJournal(); err != nil {
+		journal.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// readSnapshot loads the snapshot file, if there is one.
+func (store *FileStore) readSnapshot() error {
+	file, err := os.Open(filepath.Join(store.dir, SnapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if err := binary.Read(reader, binary.LittleEndian, &store.seq); err != nil {
+		return eofToFormatError(err)
+	} else if _, err := io.ReadFull(reader, store.tip[:]); err != nil {
+		return eofToFormatError(err)
+	}
+
+	outputs, err := ReadSnapshot(reader)
+	if err != nil {
+		return err
+	}
+	store.outputs = outputs
+	return nil
+}
+
+// replayJournal applies the journal records which are newer than the snapshot, and
+// truncates the journal after the last complete record.
+func (store *FileStore) replayJournal() error {
+	reader := bufio.NewReader(store.journal)
+	var offset int64
+
+	for {
+		seq, tip, changes, size, err := readJournalRecord(reader)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if seq > store.seq {
+			if seq != store.seq+1 {
+				return fmt.Errorf("%w: journal record %d follows %d", ErrInvalidFormat, seq, store.seq)
+			}
+			store.outputs.Apply(changes)
+			store.seq = seq
+			store.tip = tip
+		}
+		offset += size
+	}
+
+	// Discard anything after the last complete record, so that new records follow it.
+	if err := store.journal.Truncate(offset); err != nil {
+		return err
+	} else if _, err := store.journal.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	store.journalSize = offset
+	return nil
+}
+
+// readJournalRecord reads a record from the journal, returning its size in bytes. It returns
+// io.EOF at the end of the journal, or if the remaining data is not a complete and valid record.
+func readJournalRecord(r io.Reader) (seq uint64, tip [32]byte, changes *Undo, size int64, err error) {
+	var payloadSize uint32
+	if err = binary.Read(r, binary.LittleEndian, &payloadSize); err != nil {
+		return 0, tip, nil, 0, io.EOF
+	} else if payloadSize < 8+32 || payloadSize > maxJournalRecordSize {
+		return 0, tip, nil, 0, io.EOF
+	}
+
+	record := make([]byte, payloadSize+journalChecksumSize)
+	if _, err = io.ReadFull(r, record); err != nil {
+		return 0, tip, nil, 0, io.EOF
+	}
+
+	payload, checksum := record[:payloadSize], record[payloadSize:]
+	if !bytes.Equal(checksum, journalChecksum(payload)) {
+		return 0, tip, nil, 0, io.EOF
+	}
+
+	// The record is complete, so from here on any problem means the journal is corrupt.
+	seq = binary.LittleEndian.Uint64(payload)
+	copy(tip[:], payload[8:])
+
+	reader := bytes.NewReader(payload[8+32:])
+	if changes, err = UndoFromReader(reader); err != nil {
+		return 0, tip, nil, 0, err
+	} else if reader.Len() != 0 {
+		return 0, tip, nil, 0, fmt.Errorf("%w: %d trailing bytes in journal record", ErrInvalidFormat, reader.Len())
+	}
+
+	return seq, tip, changes, 4 + int64(len(record)), nil
+}
+
+func journalChecksum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	checksum := sha256.Sum256(first[:])
+	return checksum[:journalChecksumSize]
+}
+
+func encodeJournalRecord(seq uint64, tip [32]byte, changes *Undo) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.Write(make([]byte, 4)) // size, filled in below
+	binary.Write(buf, binary.LittleEndian, seq)
+	buf.Write(tip[:])
+	if _, err := changes.WriteTo(buf); err != nil {
+		return nil, err
+	}
+
+	record := buf.Bytes()
+	payload := record[4:]
+	if len(payload) > maxJournalRecordSize {
+		return nil, fmt.Errorf("journal record of %d bytes is too large", len(payload))
+	}
+	binary.LittleEndian.PutUint32(record, uint32(len(payload)))
+	return append(record, journalChecksum(payload)...), nil
+}
+
+func (store *FileStore) maxJournalSize() int64 {
+	if store.MaxJournalSize <= 0 {
+		return DefaultMaxJournalSize
+	}
+	return store.MaxJournalSize
+}
+
+// checkUsable returns an error if the store has been closed or has failed.
+func (store *FileStore) checkUsable() error {
+	if store.journal == nil {
+		return ErrStoreClosed
+	}
+	return store.failure
+}
+
+// fail marks the store as failed because of err, which is returned wrapped in ErrStoreFailed.
+func (store *FileStore) fail(err error) error {
+	store.failure = fmt.Errorf("%w: %s", ErrStoreFailed, err)
+	return store.failure
+}
+
+// Tip implements Store.
+func (store *FileStore) Tip() [32]byte {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.tip
+}
+
+// Get implements Store.
+func (store *FileStore) Get(outpoint *tx.PrevOut) (*Output, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if err := store.checkUsable(); err != nil {
+		return nil, err
+	}
+	return store.outputs.GetByOutpoint(outpoint), nil
+}
+
+// Load implements Store.
+func (store *FileStore) Load() (*OutputSet, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if err := store.checkUsable(); err != nil {
+		return nil, err
+	}
+	return store.outputs.Clone(), nil
+}
+
+// Apply implements Store. The changes are appended to the journal and synced to disk before
+// they are applied in memory. If the journal has grown larger than MaxJournalSize, a new
+// snapshot is written first.
+func (store *FileStore) Apply(tip [32]byte, changes *Undo) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.checkUsable(); err != nil {
+		return err
+	}
+
+	if store.journalSize > store.maxJournalSize() {
+		if err := store.writeSnapshot(store.seq, store.tip, store.outputs); err != nil {
+			return err
+		}
+	}
+
+	record, err := encodeJournalRecord(store.seq+1, tip, changes)
+	if err != nil {
+		return err
+	}
+
+	if _, err := store.journal.Write(record); err != nil {
+		return store.rollbackJournal(err)
+	} else if err := store.journal.Sync(); err != nil {
+		return store.rollbackJournal(err)
+	}
+
+	store.journalSize += int64(len(record))
+	store.outputs.Apply(changes)
+	store.seq++
+	store.tip = tip
+	return nil
+}
+
+// rollbackJournal discards a partially written record from the end of the journal after
+// the write failed with err, which it returns. If the record cannot be discarded, later
+// records would follow it and be lost when the store is reopened, so the store fails.
+func (store *FileStore) rollbackJournal(err error) error {
+	if truncErr := store.journal.Truncate(store.journalSize); truncErr != nil {
+		return store.fail(truncErr)
+	} else if _, seekErr := store.journal.Seek(store.journalSize, io.SeekStart); seekErr != nil {
+		return store.fail(seekErr)
+	}
+	return err
+}
+
+// Reset implements Store. It writes a new snapshot and empties the journal.
+func (store *FileStore) Reset(tip [32]byte, outputs *OutputSet) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.checkUsable(); err != nil {
+		return err
+	}
+
+	// The new snapshot has a higher sequence number than every record
+	// in the journal, so they are ignored if the journal is not emptied.
+	return store.writeSnapshot(store.seq+1, tip, outputs.Clone())
+}
+
+// Compact writes a new snapshot of the store's outputs and empties the journal.
+// This happens automatically when the journal grows larger than MaxJournalSize.
+func (store *FileStore) Compact() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.checkUsable(); err != nil {
+		return err
+	}
+	return store.writeSnapshot(store.seq, store.tip, store.outputs)
+}
+
+// writeSnapshot atomically replaces the snapshot file, makes the given state the store's state,
+// and then empties the journal. A crash before the journal is emptied leaves records which are
+// already reflected in the snapshot, so these are skipped by their sequence numbers when the
+// store is next opened. If the journal cannot be emptied, the store fails, because the offset
+// at which the next record would be written is unknown.
+func (store *FileStore) writeSnapshot(seq uint64, tip [32]byte, outputs *OutputSet) error {
+	path := filepath.Join(store.dir, SnapshotFileName)
+	tmpFile, err := os.CreateTemp(store.dir, SnapshotFileName+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	writer := bufio.NewWriter(tmpFile)
+	binary.Write(writer, binary.LittleEndian, seq)
+	writer.Write(tip[:])
+	if _, err := outputs.WriteSnapshot(writer); err != nil {
+		tmpFile.Close()
+		return err
+	} else if err := writer.Flush(); err != nil {
+		tmpFile.Close()
+		return err
+	} else if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	} else if err := tmpFile.Close(); err != nil {
+		return err
+	} else if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return err
+	}
+	syncDir(store.dir)
+
+	// The snapshot replaces the store's state from here on, even if emptying the journal fails.
+	store.seq = seq
+	store.tip = tip
+	store.outputs = outputs
+
+	if err := store.journal.Truncate(0); err != nil {
+		return store.fail(err)
+	} else if _, err := store.journal.Seek(0, io.SeekStart); err != nil {
+		return store.fail(err)
+	} else if err := store.journal.Sync(); err != nil {
+		return store.fail(err)
+	}
+	store.journalSize = 0
+	return nil
+}
+
+// syncDir makes a rename within dir durable. Not every platform supports
+// syncing directories, so errors are ignored.
+func syncDir(dir string) {
+	if file, err := os.Open(dir); err == nil {
+		file.Sync()
+		file.Close()
+	}
+}
+
+// Close implements Store. It closes the journal file.
+func (store *FileStore) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.journal == nil {
+		return ErrStoreClosed
+	}
+	err := store.journal.Close()
+	store.journal = nil
+	return err
+}
@@ -150,8 +150,17 @@ func (unspentOutputs *OutputSet) RemoveByTxid(txid string, index uint32) {
 // This function is very computationally expensive. More
 // scriptPubKeys will result in more computational overhead.
 func (unspentOutputs *OutputSet) UpdateFromBlock(block *blocks.Block, scriptPubKeys [][]byte) error {
+	return unspentOutputs.updateFromBlock(block, scriptPubKeys, nil)
+}
+
+func (unspentOutputs *OutputSet) updateFromBlock(block *blocks.Block, scriptPubKeys [][]byte, undo *Undo) error {
 	for _, txn := range block.Transactions {
 		for _, vin := range txn.Inputs {
+			if undo != nil {
+				if spent := unspentOutputs.GetByOutpoint(vin.PrevOut); spent != nil {
+					undo.recordSpent(spent)
+				}
+			}
 			unspentOutputs.RemoveByOutpoint(vin.PrevOut)
 		}
 
@@ -163,13 +172,21 @@ func (unspentOutputs *OutputSet) UpdateFromBlock(block *blocks.Block, scriptPubK
 						return err
 					}
 
-					unspentOutputs.AddOutput(&Output{
+					created := &Output{
 						Outpoint: &tx.PrevOut{Hash: txHash, Index: uint32(outputIndex)},
 						TxOut: &tx.Output{
 							Value:  vout.Value,
 							Script: scriptPubKey,
 						},
-					})
+					}
+
+					if undo != nil {
+						if overwritten := unspentOutputs.GetByOutpoint(created.Outpoint); overwritten != nil {
+							undo.recordSpent(overwritten)
+						}
+						undo.Created = append(undo.Created, created)
+					}
+					unspentOutputs.AddOutput(created)
 				}
 			}
 		}
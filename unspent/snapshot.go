@@ -0,0 +1,169 @@
+package unspent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/kklash/bitcoinlib/tx"
+	"github.com/kklash/bitcoinlib/varint"
+)
+
+// snapshotMagic begins every snapshot written by OutputSet.WriteSnapshot.
+var snapshotMagic = [4]byte{'u', 't', 'x', 'o'}
+
+// snapshotVersion is the version of the snapshot format.
+const snapshotVersion = 1
+
+// snapshotChecksumSize is the size of the double-SHA256 checksum which ends a snapshot.
+const snapshotChecksumSize = 4
+
+// maxSnapshotScriptSize bounds the size of scripts decoded from a snapshot. It matches the
+// bound on journal records, so that any output which FileStore.Apply accepted can be
+// read back from a snapshot after it is compacted.
+const maxSnapshotScriptSize = maxJournalRecordSize
+
+// sortedOutputs returns the outputs of the set ordered by outpoint.
+func (unspentOutputs *OutputSet) sortedOutputs() []*Output {
+	outputs := unspentOutputs.Slice()
+	sort.Slice(outputs, func(i, j int) bool {
+		a, b := outputs[i].Outpoint, outputs[j].Outpoint
+		if c := bytes.Compare(a.Hash[:], b.Hash[:]); c != 0 {
+			return c < 0
+		}
+		return a.Index < b.Index
+	})
+	return outputs
+}
+
+// WriteSnapshot writes the OutputSet to w in a compact form, which is smaller than the output
+// of WriteTo. Outputs are grouped by transaction hash, so that each hash is only written once,
+// and indexes and values are written as varints. The snapshot ends with a checksum, which
+// ReadSnapshot verifies. Any two sets containing the same outputs have the same snapshot.
+func (unspentOutputs *OutputSet) WriteSnapshot(w io.Writer) (int64, error) {
+	outputs := unspentOutputs.sortedOutputs()
+
+	nTxs := 0
+	for i, output := range outputs {
+		if i == 0 || output.Outpoint.Hash != outputs[i-1].Outpoint.Hash {
+			nTxs++
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(snapshotMagic[:])
+	buf.WriteByte(snapshotVersion)
+	buf.Write(varint.VarInt(nTxs).Bytes())
+
+	for start := 0; start < len(outputs); {
+		end := start + 1
+		for end < len(outputs) && outputs[end].Outpoint.Hash == outputs[start].Outpoint.Hash {
+			end++
+		}
+
+		buf.Write(outputs[start].Outpoint.Hash[:])
+		buf.Write(varint.VarInt(end - start).Bytes())
+		for _, output := range outputs[start:end] {
+			buf.Write(varint.VarInt(output.Outpoint.Index).Bytes())
+			buf.Write(varint.VarInt(output.TxOut.Value).Bytes())
+			buf.Write(varint.VarInt(len(output.TxOut.Script)).Bytes())
+			buf.Write(output.TxOut.Script)
+		}
+		start = end
+	}
+
+	first := sha256.Sum256(buf.Bytes())
+	checksum := sha256.Sum256(first[:])
+	buf.Write(checksum[:snapshotChecksumSize])
+
+	return buf.WriteTo(w)
+}
+
+// ReadSnapshot decodes an OutputSet written by OutputSet.WriteSnapshot from r. Returns
+// ErrInvalidFormat if the snapshot is malformed or its checksum does not match.
+func ReadSnapshot(r io.Reader) (*OutputSet, error) {
+	hasher := sha256.New()
+	reader := io.TeeReader(r, hasher)
+
+	var header [len(snapshotMagic) + 1]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return nil, eofToFormatError(err)
+	} else if !bytes.Equal(header[:len(snapshotMagic)], snapshotMagic[:]) {
+		return nil, fmt.Errorf("%w: not a snapshot", ErrInvalidFormat)
+	} else if header[len(snapshotMagic)] != snapshotVersion {
+		return nil, fmt.Errorf("%w: unknown snapshot version %d", ErrInvalidFormat, header[len(snapshotMagic)])
+	}
+
+	readCount := func() (uint64, error) {
+		n, err := varint.FromReader(reader)
+		if err != nil {
+			return 0, eofToFormatError(err)
+		} else if n > maxOutputCount {
+			return 0, ErrInvalidFormat
+		}
+		return uint64(n), nil
+	}
+
+	nTxs, err := readCount()
+	if err != nil {
+		return nil, err
+	}
+
+	unspentOutputs := new(OutputSet)
+	for i := uint64(0); i < nTxs; i++ {
+		var hash [32]byte
+		if _, err := io.ReadFull(reader, hash[:]); err != nil {
+			return nil, eofToFormatError(err)
+		}
+
+		nOutputs, err := readCount()
+		if err != nil {
+			return nil, err
+		}
+
+		for j := uint64(0); j < nOutputs; j++ {
+			index, err := varint.FromReader(reader)
+			if err != nil {
+				return nil, eofToFormatError(err)
+			} else if index > 0xffffffff {
+				return nil, fmt.Errorf("%w: output index %d out of range", ErrInvalidFormat, index)
+			}
+
+			value, err := varint.FromReader(reader)
+			if err != nil {
+				return nil, eofToFormatError(err)
+			}
+
+			scriptSize, err := varint.FromReader(reader)
+			if err != nil {
+				return nil, eofToFormatError(err)
+			} else if scriptSize > maxSnapshotScriptSize {
+				return nil, fmt.Errorf("%w: script size %d too large", ErrInvalidFormat, scriptSize)
+			}
+
+			// The script is copied rather than allocated up front, so that a corrupt
+			// size fails at the end of the snapshot instead of allocating up to the limit.
+			script := bytes.NewBuffer([]byte{})
+			if _, err := io.CopyN(script, reader, int64(scriptSize)); err != nil {
+				return nil, eofToFormatError(err)
+			}
+
+			unspentOutputs.AddOutput(&Output{
+				Outpoint: &tx.PrevOut{Hash: hash, Index: uint32(index)},
+				TxOut:    &tx.Output{Value: uint64(value), Script: script.Bytes()},
+			})
+		}
+	}
+
+	expected := sha256.Sum256(hasher.Sum(nil))
+	var checksum [snapshotChecksumSize]byte
+	if _, err := io.ReadFull(r, checksum[:]); err != nil {
+		return nil, eofToFormatError(err)
+	} else if !bytes.Equal(checksum[:], expected[:snapshotChecksumSize]) {
+		return nil, fmt.Errorf("%w: snapshot checksum mismatch", ErrInvalidFormat)
+	}
+
+	return unspentOutputs, nil
+}
@@ -0,0 +1,115 @@
+package unspent
+
+import (
+	"sync"
+
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+// Store is persistent storage for a set of unspent outputs, which is updated one block at a
+// time. Each store also records the hash of the block its outputs are current as of, its tip,
+// so that a caller can tell where to resume scanning after a restart. A store can be kept in
+// step with a blockscan.ScanState using blockscan.StoreCheckpoint. Tip hashes are opaque
+// to the store, and a zero tip is returned by a store which has never been updated.
+//
+// The changes made by each block are applied atomically: if Apply fails, or the process
+// crashes during it, the store reflects either all or none of the block's changes.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Tip returns the hash of the block the store's outputs are current as of.
+	Tip() [32]byte
+
+	// Get returns the output at the given outpoint, or nil if it is not in the store.
+	Get(outpoint *tx.PrevOut) (*Output, error)
+
+	// Load returns an OutputSet holding every output in the store. It is
+	// a copy, which the caller may modify without affecting the store.
+	Load() (*OutputSet, error)
+
+	// Apply atomically removes the outputs in changes.Spent from the store, adds
+	// the outputs in changes.Created, and sets the store's tip. To revert a block
+	// during a reorg, pass the inverse of its Undo and the hash of its parent.
+	Apply(tip [32]byte, changes *Undo) error
+
+	// Reset atomically replaces the contents of the store with the given outputs and tip.
+	Reset(tip [32]byte, outputs *OutputSet) error
+
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// Inverse returns an Undo which reverses the changes recorded in undo. Applying the
+// inverse with OutputSet.Apply or Store.Apply is the same as calling OutputSet.Revert.
+func (undo *Undo) Inverse() *Undo {
+	return &Undo{Spent: undo.Created, Created: undo.Spent}
+}
+
+// Apply removes the outputs in changes.Spent from the OutputSet, and adds those in
+// changes.Created. It repeats the changes made by the block which changes was
+// returned for, so that they can be replayed on another copy of the OutputSet.
+func (unspentOutputs *OutputSet) Apply(changes *Undo) {
+	for _, spent := range changes.Spent {
+		unspentOutputs.RemoveByOutpoint(spent.Outpoint)
+	}
+
+	for _, created := range changes.Created {
+		unspentOutputs.AddOutput(created)
+	}
+}
+
+// MemoryStore is a Store which keeps its outputs in memory only. It is useful for tests,
+// and for callers which want the Store interface without persistence.
+type MemoryStore struct {
+	mutex   sync.RWMutex
+	tip     [32]byte
+	outputs *OutputSet
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{outputs: new(OutputSet)}
+}
+
+// Tip implements Store.
+func (store *MemoryStore) Tip() [32]byte {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.tip
+}
+
+// Get implements Store.
+func (store *MemoryStore) Get(outpoint *tx.PrevOut) (*Output, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.outputs.GetByOutpoint(outpoint), nil
+}
+
+// Load implements Store.
+func (store *MemoryStore) Load() (*OutputSet, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.outputs.Clone(), nil
+}
+
+// Apply implements Store.
+func (store *MemoryStore) Apply(tip [32]byte, changes *Undo) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.outputs.Apply(changes)
+	store.tip = tip
+	return nil
+}
+
+// Reset implements Store.
+func (store *MemoryStore) Reset(tip [32]byte, outputs *OutputSet) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.outputs = outputs.Clone()
+	store.tip = tip
+	return nil
+}
+
+// Close implements Store. It does nothing.
+func (store *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,422 @@
+package unspent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+func TestOutputSet_Snapshot(t *testing.T) {
+	script := mustHex("00148dc8e4f2450a1af2202383d71a259de6ea14d4ba")
+	outputs := []*Output{
+		makeTestOutput("0000000000000000000000000000000000000000000000000000000000000002", 1, 2000, script),
+		makeTestOutput("0000000000000000000000000000000000000000000000000000000000000001", 7, 1000, script),
+		makeTestOutput("0000000000000000000000000000000000000000000000000000000000000002", 0, 3000, []byte{}),
+		makeTestOutput("0000000000000000000000000000000000000000000000000000000000000002", 300, 21e14, script),
+	}
+
+	snapshot := new(bytes.Buffer)
+	n, err := NewOutputSet(outputs).WriteSnapshot(snapshot)
+	if err != nil {
+		t.Errorf("failed to write snapshot: %s", err)
+		return
+	} else if n != int64(snapshot.Len()) {
+		t.Errorf("WriteSnapshot returned %d bytes written, wrote %d", n, snapshot.Len())
+		return
+	}
+	encoded := snapshot.Bytes()
+
+	reversed := new(bytes.Buffer)
+	NewOutputSet([]*Output{outputs[3], outputs[2], outputs[1], outputs[0]}).WriteSnapshot(reversed)
+	if !bytes.Equal(encoded, reversed.Bytes()) {
+		t.Errorf("snapshot depends on insertion order")
+		return
+	}
+
+	if full := encode(t, NewOutputSet(outputs)); len(encoded) >= len(full) {
+		t.Errorf("snapshot of %d bytes is not smaller than encoding of %d bytes", len(encoded), len(full))
+		return
+	}
+
+	decoded, err := ReadSnapshot(bytes.NewReader(encoded))
+	if err != nil {
+		t.Errorf("failed to read snapshot: %s", err)
+		return
+	} else if !reflect.DeepEqual(decoded, NewOutputSet(outputs)) {
+		t.Errorf("snapshot did not round trip")
+		return
+	}
+
+	for i := 0; i < len(encoded); i++ {
+		if _, err := ReadSnapshot(bytes.NewReader(encoded[:i])); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("expected ErrInvalidFormat reading truncated snapshot of length %d, got %v", i, err)
+			return
+		}
+
+		corrupted := append([]byte(nil), encoded...)
+		corrupted[i] ^= 1
+		if _, err := ReadSnapshot(bytes.NewReader(corrupted)); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("expected ErrInvalidFormat reading snapshot corrupted at byte %d, got %v", i, err)
+			return
+		}
+	}
+
+	empty := new(bytes.Buffer)
+	new(OutputSet).WriteSnapshot(empty)
+	if decoded, err := ReadSnapshot(empty); err != nil {
+		t.Errorf("failed to read empty snapshot: %s", err)
+		return
+	} else if decoded.Size() != 0 {
+		t.Errorf("expected empty set, got %d outputs", decoded.Size())
+		return
+	}
+}
+
+// storeTestChain is a series of changes made by blocks to a set of outputs.
+type storeTestChain struct {
+	tips    [][32]byte
+	changes []*Undo
+	sets    []*OutputSet // the outputs after each block
+}
+
+func newStoreTestChain(nBlocks int) *storeTestChain {
+	chain := new(storeTestChain)
+	outputs := new(OutputSet)
+
+	for i := 0; i < nBlocks; i++ {
+		created := &Output{
+			Outpoint: &tx.PrevOut{Hash: [32]byte{0xaa, byte(i)}, Index: uint32(i)},
+			TxOut:    &tx.Output{Value: uint64(1000 * (i + 1)), Script: []byte{0x51, byte(i)}},
+		}
+		changes := &Undo{Created: []*Output{created}}
+
+		// Each odd block spends the output created by the block before it.
+		if i%2 == 1 {
+			changes.Spent = chain.changes[i-1].Created
+		}
+
+		outputs = outputs.Clone()
+		outputs.Apply(changes)
+
+		chain.tips = append(chain.tips, [32]byte{0xbb, byte(i)})
+		chain.changes = append(chain.changes, changes)
+		chain.sets = append(chain.sets, outputs)
+	}
+
+	return chain
+}
+
+// checkStore returns an error if the store does not hold the given outputs and tip.
+func checkStore(store Store, tip [32]byte, expected *OutputSet) error {
+	if store.Tip() != tip {
+		return errors.New("incorrect tip")
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		return err
+	} else if loaded.Size() != expected.Size() {
+		return fmt.Errorf("store has %d outputs, expected %d", loaded.Size(), expected.Size())
+	}
+
+	for _, output := range expected.Slice() {
+		if found, err := store.Get(output.Outpoint); err != nil {
+			return err
+		} else if !reflect.DeepEqual(found, output) {
+			return errors.New("output not found with Get")
+		} else if !reflect.DeepEqual(loaded.GetByOutpoint(output.Outpoint), output) {
+			return errors.New("output not found in loaded set")
+		}
+	}
+	return nil
+}
+
+func testStore(t *testing.T, store Store) {
+	chain := newStoreTestChain(6)
+
+	if err := checkStore(store, [32]byte{}, new(OutputSet)); err != nil {
+		t.Errorf("new store is not empty: %s", err)
+		return
+	}
+
+	for i, changes := range chain.changes {
+		if err := store.Apply(chain.tips[i], changes); err != nil {
+			t.Errorf("failed to apply block %d: %s", i, err)
+			return
+		} else if err := checkStore(store, chain.tips[i], chain.sets[i]); err != nil {
+			t.Errorf("store is incorrect after block %d: %s", i, err)
+			return
+		}
+	}
+
+	if output, err := store.Get(chain.changes[0].Created[0].Outpoint); err != nil || output != nil {
+		t.Errorf("expected spent output not to be found, got %v, %v", output, err)
+		return
+	}
+
+	// Loaded sets must be copies.
+	loaded, _ := store.Load()
+	loaded.Apply(chain.changes[0])
+	if err := checkStore(store, chain.tips[5], chain.sets[5]); err != nil {
+		t.Errorf("modifying loaded set changed the store: %s", err)
+		return
+	}
+
+	// Revert the last two blocks, as in a reorg.
+	for i := 5; i >= 4; i-- {
+		if err := store.Apply(chain.tips[i-1], chain.changes[i].Inverse()); err != nil {
+			t.Errorf("failed to revert block %d: %s", i, err)
+			return
+		} else if err := checkStore(store, chain.tips[i-1], chain.sets[i-1]); err != nil {
+			t.Errorf("store is incorrect after reverting block %d: %s", i, err)
+			return
+		}
+	}
+
+	if err := store.Reset(chain.tips[1], chain.sets[1]); err != nil {
+		t.Errorf("failed to reset store: %s", err)
+		return
+	} else if err := checkStore(store, chain.tips[1], chain.sets[1]); err != nil {
+		t.Errorf("store is incorrect after reset: %s", err)
+		return
+	}
+
+	if err := store.Close(); err != nil {
+		t.Errorf("failed to close store: %s", err)
+		return
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := OpenFileStore(t.TempDir())
+	if err != nil {
+		t.Errorf("failed to open store: %s", err)
+		return
+	}
+	testStore(t, store)
+
+	if err := store.Apply([32]byte{}, new(Undo)); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("expected ErrStoreClosed, got %v", err)
+		return
+	}
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, JournalFileName)
+	chain := newStoreTestChain(8)
+
+	reopen := func(store *FileStore) *FileStore {
+		if store != nil {
+			store.Close()
+		}
+		store, err := OpenFileStore(dir)
+		if err != nil {
+			t.Fatalf("failed to open store: %s", err)
+		}
+		return store
+	}
+
+	store := reopen(nil)
+	for i := 0; i < 3; i++ {
+		if err := store.Apply(chain.tips[i], chain.changes[i]); err != nil {
+			t.Errorf("failed to apply block %d: %s", i, err)
+			return
+		}
+	}
+
+	store = reopen(store)
+	if err := checkStore(store, chain.tips[2], chain.sets[2]); err != nil {
+		t.Errorf("store is incorrect after reopening: %s", err)
+		return
+	}
+
+	// A record torn by a crash is discarded, and later records are appended after the last complete one.
+	journal, _ := os.ReadFile(journalPath)
+	store.Apply(chain.tips[3], chain.changes[3])
+	store.Close()
+	complete, _ := os.ReadFile(journalPath)
+
+	for size := len(journal); size < len(complete); size++ {
+		if err := os.WriteFile(journalPath, complete[:size], 0o644); err != nil {
+			t.Fatalf("failed to write journal: %s", err)
+		}
+		store = reopen(nil)
+		if err := checkStore(store, chain.tips[2], chain.sets[2]); err != nil {
+			t.Errorf("store is incorrect with journal torn at %d bytes: %s", size, err)
+			return
+		}
+		store.Close()
+	}
+
+	corrupted := append([]byte(nil), complete...)
+	corrupted[len(corrupted)-1] ^= 1
+	os.WriteFile(journalPath, corrupted, 0o644)
+
+	store = reopen(nil)
+	if err := checkStore(store, chain.tips[2], chain.sets[2]); err != nil {
+		t.Errorf("store is incorrect with corrupted last record: %s", err)
+		return
+	} else if err := store.Apply(chain.tips[3], chain.changes[3]); err != nil {
+		t.Errorf("failed to apply block after discarding record: %s", err)
+		return
+	}
+
+	store = reopen(store)
+	if err := checkStore(store, chain.tips[3], chain.sets[3]); err != nil {
+		t.Errorf("store is incorrect after applying block over discarded record: %s", err)
+		return
+	}
+
+	// A small MaxJournalSize causes the journal to be compacted into the snapshot.
+	store.MaxJournalSize = 1
+	for i := 4; i < 8; i++ {
+		// Keep the journal as it was before compaction, to simulate a
+		// crash between writing the snapshot and emptying the journal.
+		journal, _ = os.ReadFile(journalPath)
+
+		if err := store.Apply(chain.tips[i], chain.changes[i]); err != nil {
+			t.Errorf("failed to apply block %d: %s", i, err)
+			return
+		}
+	}
+
+	if info, err := os.Stat(journalPath); err != nil {
+		t.Errorf("failed to stat journal: %s", err)
+		return
+	} else if info.Size() >= int64(len(complete)) {
+		t.Errorf("journal was not compacted, has size %d", info.Size())
+		return
+	}
+
+	store = reopen(store)
+	if err := checkStore(store, chain.tips[7], chain.sets[7]); err != nil {
+		t.Errorf("store is incorrect after compaction: %s", err)
+		return
+	}
+	store.Close()
+
+	current, _ := os.ReadFile(journalPath)
+	stale := append(append(complete, journal...), current...)
+	os.WriteFile(journalPath, stale, 0o644)
+	store = reopen(nil)
+	if err := checkStore(store, chain.tips[7], chain.sets[7]); err != nil {
+		t.Errorf("store replayed stale journal records: %s", err)
+		return
+	}
+
+	if err := store.Reset(chain.tips[0], chain.sets[0]); err != nil {
+		t.Errorf("failed to reset store: %s", err)
+		return
+	}
+	store = reopen(store)
+	if err := checkStore(store, chain.tips[0], chain.sets[0]); err != nil {
+		t.Errorf("store is incorrect after reset: %s", err)
+		return
+	}
+	store.Close()
+}
+
+func TestFileStore_LargeScript(t *testing.T) {
+	dir := t.TempDir()
+	large := &Output{
+		Outpoint: &tx.PrevOut{Hash: [32]byte{0xdd}},
+		TxOut:    &tx.Output{Value: 1000, Script: bytes.Repeat([]byte{0x6a}, 20000)},
+	}
+	expected := NewOutputSet([]*Output{large})
+
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Errorf("failed to open store: %s", err)
+		return
+	} else if err := store.Apply([32]byte{0xcc}, &Undo{Created: []*Output{large}}); err != nil {
+		t.Errorf("failed to apply block: %s", err)
+		return
+	} else if err := store.Compact(); err != nil {
+		t.Errorf("failed to compact store: %s", err)
+		return
+	}
+	store.Close()
+
+	store, err = OpenFileStore(dir)
+	if err != nil {
+		t.Errorf("failed to reopen store with large script in snapshot: %s", err)
+		return
+	} else if err := checkStore(store, [32]byte{0xcc}, expected); err != nil {
+		t.Errorf("store is incorrect after reopening: %s", err)
+		return
+	}
+	store.Close()
+}
+
+func TestFileStore_FailedJournalReset(t *testing.T) {
+	dir := t.TempDir()
+	chain := newStoreTestChain(4)
+
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Errorf("failed to open store: %s", err)
+		return
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.Apply(chain.tips[i], chain.changes[i]); err != nil {
+			t.Errorf("failed to apply block %d: %s", i, err)
+			return
+		}
+	}
+
+	// Closing the journal behind the store's back causes emptying it to fail after the
+	// new snapshot has replaced the old one.
+	store.journal.Close()
+	if err := store.Reset(chain.tips[3], chain.sets[3]); !errors.Is(err, ErrStoreFailed) {
+		t.Errorf("expected ErrStoreFailed from reset, got %v", err)
+		return
+	} else if store.Tip() != chain.tips[3] {
+		t.Errorf("store tip was not updated after snapshot was written")
+		return
+	} else if err := store.Apply(chain.tips[3], new(Undo)); !errors.Is(err, ErrStoreFailed) {
+		t.Errorf("expected ErrStoreFailed from apply after failure, got %v", err)
+		return
+	}
+	store.Close()
+
+	// The journal records which were not emptied are older than the snapshot.
+	store, err = OpenFileStore(dir)
+	if err != nil {
+		t.Errorf("failed to reopen store: %s", err)
+		return
+	} else if err := checkStore(store, chain.tips[3], chain.sets[3]); err != nil {
+		t.Errorf("store is incorrect after reopening: %s", err)
+		return
+	}
+
+	// Records appended after reopening follow the snapshot.
+	changes := &Undo{Spent: chain.sets[3].Slice()[:1]}
+	expected := chain.sets[3].Clone()
+	expected.Apply(changes)
+	if err := store.Apply([32]byte{0xcc}, changes); err != nil {
+		t.Errorf("failed to apply block after reopening: %s", err)
+		return
+	}
+	store.Close()
+
+	store, err = OpenFileStore(dir)
+	if err != nil {
+		t.Errorf("failed to reopen store: %s", err)
+		return
+	} else if err := checkStore(store, [32]byte{0xcc}, expected); err != nil {
+		t.Errorf("record applied after reset was lost: %s", err)
+		return
+	}
+	store.Close()
+}
@@ -0,0 +1,55 @@
+package unspent
+
+import (
+	"github.com/kklash/bitcoinlib/blocks"
+)
+
+// Undo records the changes made to an OutputSet by a block, so that they can be
+// reverted with OutputSet.Revert if the block is disconnected by a chain reorganization.
+type Undo struct {
+	// Spent holds the outputs which were in the OutputSet before the block, and were
+	// removed from the set because they were spent by the block.
+	Spent []*Output
+
+	// Created holds the outputs which were added to the OutputSet by the block. Outputs
+	// which were created and then spent within the same block are not included.
+	Created []*Output
+}
+
+// recordSpent records that output was removed from the OutputSet. If the output was
+// created earlier in the same block, it is forgotten instead.
+func (undo *Undo) recordSpent(output *Output) {
+	for i, created := range undo.Created {
+		if *created.Outpoint == *output.Outpoint {
+			undo.Created = append(undo.Created[:i], undo.Created[i+1:]...)
+			return
+		}
+	}
+
+	undo.Spent = append(undo.Spent, output)
+}
+
+// UpdateFromBlockUndo updates the OutputSet in the same way as UpdateFromBlock, and returns
+// an Undo which can be used to revert the changes. If an error occurs, the OutputSet is
+// left unchanged.
+func (unspentOutputs *OutputSet) UpdateFromBlockUndo(block *blocks.Block, scriptPubKeys [][]byte) (*Undo, error) {
+	undo := new(Undo)
+	if err := unspentOutputs.updateFromBlock(block, scriptPubKeys, undo); err != nil {
+		unspentOutputs.Revert(undo)
+		return nil, err
+	}
+	return undo, nil
+}
+
+// Revert reverses the changes recorded in undo, returning the OutputSet to its state
+// before the block was applied. Blocks must be reverted in the reverse order to which
+// they were applied.
+func (unspentOutputs *OutputSet) Revert(undo *Undo) {
+	for _, created := range undo.Created {
+		unspentOutputs.RemoveByOutpoint(created.Outpoint)
+	}
+
+	for _, spent := range undo.Spent {
+		unspentOutputs.AddOutput(spent)
+	}
+}
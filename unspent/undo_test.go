@@ -0,0 +1,137 @@
+package unspent
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+func makeTestOutput(hash string, index uint32, value uint64, script []byte) *Output {
+	return &Output{
+		Outpoint: &tx.PrevOut{Hash: mustHash(hash), Index: index},
+		TxOut:    &tx.Output{Value: value, Script: script},
+	}
+}
+
+func TestOutputSet_Revert(t *testing.T) {
+	script := mustHex("00148dc8e4f2450a1af2202383d71a259de6ea14d4ba")
+	other := mustHex("76a914d32a2b0ff8a8eb2471157217d25a11c05b072cfb88ac")
+
+	untouched := makeTestOutput("0000000000000000000000000000000000000000000000000000000000000001", 0, 1000, script)
+	spent := makeTestOutput("0000000000000000000000000000000000000000000000000000000000000002", 3, 2000, script)
+
+	// tx1 spends an existing output, and creates two outputs. tx2 spends one of them.
+	tx1 := &tx.Tx{
+		Version: 2,
+		Inputs:  []*tx.Input{{PrevOut: spent.Outpoint, Script: []byte{}}},
+		Outputs: []*tx.Output{
+			{Value: 1500, Script: script},
+			{Value: 400, Script: script},
+			{Value: 99, Script: other},
+		},
+	}
+	tx1Hash, _ := tx1.Hash(false)
+
+	tx2 := &tx.Tx{
+		Version: 2,
+		Inputs:  []*tx.Input{{PrevOut: &tx.PrevOut{Hash: tx1Hash, Index: 1}, Script: []byte{}}},
+		Outputs: []*tx.Output{{Value: 300, Script: other}},
+	}
+
+	block := &blocks.Block{Transactions: []*tx.Tx{tx1, tx2}}
+
+	outputSet := NewOutputSet([]*Output{untouched, spent})
+	original := outputSet.Clone()
+
+	undo, err := outputSet.UpdateFromBlockUndo(block, [][]byte{script})
+	if err != nil {
+		t.Errorf("failed to update output set: %s", err)
+		return
+	}
+
+	created := &Output{
+		Outpoint: &tx.PrevOut{Hash: tx1Hash, Index: 0},
+		TxOut:    &tx.Output{Value: 1500, Script: script},
+	}
+	expectedUndo := &Undo{Spent: []*Output{spent}, Created: []*Output{created}}
+	if !reflect.DeepEqual(undo, expectedUndo) {
+		t.Errorf("incorrect undo\nWanted %+v\nGot    %+v", expectedUndo, undo)
+		return
+	}
+
+	if outputSet.Size() != 2 || outputSet.GetByOutpoint(created.Outpoint) == nil || outputSet.GetByOutpoint(untouched.Outpoint) == nil {
+		t.Errorf("incorrect output set after update: %+v", outputSet.Slice())
+		return
+	}
+
+	decodedUndo, err := UndoFromReader(bytes.NewReader(encode(t, undo)))
+	if err != nil {
+		t.Errorf("failed to decode undo: %s", err)
+		return
+	} else if !reflect.DeepEqual(decodedUndo, undo) {
+		t.Errorf("undo did not round trip\nWanted %+v\nGot    %+v", undo, decodedUndo)
+		return
+	}
+
+	outputSet.Revert(decodedUndo)
+	if !reflect.DeepEqual(outputSet, original) {
+		t.Errorf("revert did not restore original output set\nWanted %+v\nGot    %+v", original.Slice(), outputSet.Slice())
+		return
+	}
+}
+
+func encode(t *testing.T, v io.WriterTo) []byte {
+	buf := new(bytes.Buffer)
+	if _, err := v.WriteTo(buf); err != nil {
+		t.Fatalf("failed to encode: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestOutputSet_Encoding(t *testing.T) {
+	script := mustHex("00148dc8e4f2450a1af2202383d71a259de6ea14d4ba")
+	outputs := []*Output{
+		makeTestOutput("0000000000000000000000000000000000000000000000000000000000000002", 1, 2000, script),
+		makeTestOutput("0000000000000000000000000000000000000000000000000000000000000001", 7, 1000, script),
+		makeTestOutput("0000000000000000000000000000000000000000000000000000000000000002", 0, 3000, []byte{}),
+	}
+
+	encoded := encode(t, NewOutputSet(outputs))
+	if !bytes.Equal(encoded, encode(t, NewOutputSet([]*Output{outputs[2], outputs[1], outputs[0]}))) {
+		t.Errorf("OutputSet encoding depends on insertion order")
+		return
+	}
+
+	expected := append([]byte{3}, outputs[1].Bytes()...)
+	expected = append(expected, outputs[2].Bytes()...)
+	expected = append(expected, outputs[0].Bytes()...)
+	if !bytes.Equal(encoded, expected) {
+		t.Errorf("incorrect OutputSet encoding\nWanted %x\nGot    %x", expected, encoded)
+		return
+	}
+
+	decoded, err := OutputSetFromReader(bytes.NewReader(encoded))
+	if err != nil {
+		t.Errorf("failed to decode OutputSet: %s", err)
+		return
+	} else if !reflect.DeepEqual(decoded, NewOutputSet(outputs)) {
+		t.Errorf("OutputSet did not round trip")
+		return
+	}
+
+	for i := 0; i < len(encoded); i++ {
+		if _, err := OutputSetFromReader(bytes.NewReader(encoded[:i])); err == nil {
+			t.Errorf("expected error decoding truncated OutputSet of length %d", i)
+			return
+		}
+	}
+
+	if _, err := OutputSetFromReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})); err != ErrInvalidFormat {
+		t.Errorf("expected ErrInvalidFormat for huge output count, got %v", err)
+		return
+	}
+}
@@ -0,0 +1,188 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/kklash/bitcoinlib/common"
+	"github.com/kklash/bitcoinlib/varint"
+)
+
+// encoder writes primitive protocol values to an io.Writer. After the first
+// error, all further writes are ignored and the error is kept in err.
+type encoder struct {
+	w   io.Writer
+	err error
+}
+
+func (e *encoder) write(data []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(data)
+	}
+}
+
+func (e *encoder) writeUint8(v uint8) {
+	e.write([]byte{v})
+}
+
+func (e *encoder) writeBool(v bool) {
+	if v {
+		e.writeUint8(1)
+	} else {
+		e.writeUint8(0)
+	}
+}
+
+func (e *encoder) writeUint16BE(v uint16) {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, v)
+	e.write(buf)
+}
+
+func (e *encoder) writeUint32(v uint32) {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, v)
+	e.write(buf)
+}
+
+func (e *encoder) writeUint64(v uint64) {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, v)
+	e.write(buf)
+}
+
+func (e *encoder) writeVarInt(v uint64) {
+	e.write(varint.VarInt(v).Bytes())
+}
+
+func (e *encoder) writeVarBytes(data []byte) {
+	e.writeVarInt(uint64(len(data)))
+	e.write(data)
+}
+
+func (e *encoder) writeHash(hash [32]byte) {
+	e.write(common.ReverseBytes(hash[:]))
+}
+
+func (e *encoder) writeTo(wt io.WriterTo) {
+	if e.err == nil {
+		_, e.err = wt.WriteTo(e.w)
+	}
+}
+
+// decoder reads primitive protocol values from an io.Reader. After the first
+// error, all further reads return zero values and the error is kept in err.
+// Unexpected EOF errors are converted to ErrInvalidFormat.
+type decoder struct {
+	r   io.Reader
+	err error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err != nil {
+		return
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		d.err = fmt.Errorf("%w: unexpected end of payload", ErrInvalidFormat)
+	} else {
+		d.err = err
+	}
+}
+
+func (d *decoder) read(size int) []byte {
+	if d.err != nil {
+		return nil
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		d.fail(err)
+		return nil
+	}
+
+	return buf
+}
+
+func (d *decoder) readUint8() uint8 {
+	buf := d.read(1)
+	if buf == nil {
+		return 0
+	}
+	return buf[0]
+}
+
+func (d *decoder) readBool() bool {
+	return d.readUint8() != 0
+}
+
+func (d *decoder) readUint16BE() uint16 {
+	buf := d.read(2)
+	if buf == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(buf)
+}
+
+func (d *decoder) readUint32() uint32 {
+	buf := d.read(4)
+	if buf == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(buf)
+}
+
+func (d *decoder) readUint64() uint64 {
+	buf := d.read(8)
+	if buf == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(buf)
+}
+
+func (d *decoder) readVarInt() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, err := varint.FromReader(d.r)
+	if err != nil {
+		d.fail(err)
+		return 0
+	}
+
+	return uint64(v)
+}
+
+// readCount reads a varint item count, and fails with ErrInvalidFormat if it exceeds max.
+func (d *decoder) readCount(max int, name string) int {
+	count := d.readVarInt()
+	if d.err == nil && count > uint64(max) {
+		d.fail(fmt.Errorf("%w: too many %s (%d > %d)", ErrInvalidFormat, name, count, max))
+		return 0
+	}
+
+	return int(count)
+}
+
+func (d *decoder) readVarBytes(maxSize int, name string) []byte {
+	size := d.readCount(maxSize, name+" bytes")
+	if d.err != nil {
+		return nil
+	}
+
+	return d.read(size)
+}
+
+func (d *decoder) readHash() (hash [32]byte) {
+	buf := d.read(32)
+	if buf == nil {
+		return
+	}
+
+	copy(hash[:], buf)
+	common.ReverseBytesInPlace(hash[:])
+	return
+}
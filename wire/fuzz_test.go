@@ -0,0 +1,83 @@
+package wire
+
+import (
+	"bytes"
+	"testing"
+)
+
+// checkRoundTrip asserts that a decoded message re-encodes to a
+// payload which decodes and re-encodes to the same bytes.
+func checkRoundTrip(t *testing.T, msg Message) {
+	first := new(bytes.Buffer)
+	if err := msg.Encode(first); err != nil {
+		t.Fatalf("failed to re-encode decoded %s message: %s", msg.Command(), err)
+	}
+
+	decoded := makeEmptyMessage(msg.Command())
+	if decoded == nil {
+		decoded = &MsgUnknown{CommandName: msg.Command()}
+	}
+
+	reader := bytes.NewReader(first.Bytes())
+	if err := decoded.Decode(reader); err != nil {
+		t.Fatalf("failed to decode re-encoded %s message: %s", msg.Command(), err)
+	} else if reader.Len() != 0 {
+		t.Fatalf("re-encoded %s message has %d trailing bytes", msg.Command(), reader.Len())
+	}
+
+	second := new(bytes.Buffer)
+	if err := decoded.Encode(second); err != nil {
+		t.Fatalf("failed to encode %s message: %s", msg.Command(), err)
+	}
+
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatalf("%s message did not round-trip\nWanted %x\nGot    %x", msg.Command(), first.Bytes(), second.Bytes())
+	}
+}
+
+func FuzzReadMessage(f *testing.F) {
+	for _, fixture := range testMessages() {
+		f.Add(hex2bytes(fixture.expected))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := ReadMessage(bytes.NewReader(data), MagicMainnet)
+		if err != nil {
+			return
+		}
+
+		buf := new(bytes.Buffer)
+		if err := WriteMessage(buf, MagicMainnet, msg); err != nil {
+			t.Fatalf("failed to write decoded %s message: %s", msg.Command(), err)
+		}
+
+		if _, err := ReadMessage(buf, MagicMainnet); err != nil {
+			t.Fatalf("failed to read re-encoded %s message: %s", msg.Command(), err)
+		}
+
+		checkRoundTrip(t, msg)
+	})
+}
+
+// FuzzDecodePayload fuzzes message payloads directly, skipping the checksum in the message header.
+func FuzzDecodePayload(f *testing.F) {
+	for _, fixture := range testMessages() {
+		f.Add(fixture.msg.Command(), hex2bytes(fixture.expected)[MessageHeaderSize:])
+	}
+	f.Add(CommandHeaders, hex2bytes("01"+genesisBlockHex[:160]+"00"))
+	f.Add(CommandTx, hex2bytes(genesisBlockHex[162:]))
+
+	f.Fuzz(func(t *testing.T, command string, payload []byte) {
+		msg := makeEmptyMessage(command)
+		if msg == nil {
+			return
+		}
+
+		reader := bytes.NewReader(payload)
+		if err := msg.Decode(reader); err != nil || reader.Len() != 0 {
+			return
+		}
+
+		checkRoundTrip(t, msg)
+	})
+}
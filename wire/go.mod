@@ -0,0 +1,3 @@
+module github.com/kklash/bitcoinlib/wire
+
+go 1.18
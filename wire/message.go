@@ -0,0 +1,140 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/kklash/bitcoinlib/bhash"
+)
+
+// WriteMessage serializes msg with a message header for the network identified by magic,
+// and writes it to w. Returns ErrInvalidCommand if the message's command name is invalid,
+// or ErrPayloadTooLarge if the encoded payload is larger than MaxPayloadSize.
+func WriteMessage(w io.Writer, magic uint32, msg Message) error {
+	command, err := encodeCommand(msg.Command())
+	if err != nil {
+		return err
+	}
+
+	payload := new(bytes.Buffer)
+	if err := msg.Encode(payload); err != nil {
+		return err
+	} else if payload.Len() > MaxPayloadSize {
+		return fmt.Errorf("%w: %d bytes", ErrPayloadTooLarge, payload.Len())
+	}
+
+	checksum := bhash.DoubleSha256(payload.Bytes())
+
+	header := make([]byte, MessageHeaderSize)
+	binary.LittleEndian.PutUint32(header[:4], magic)
+	copy(header[4:16], command[:])
+	binary.LittleEndian.PutUint32(header[16:20], uint32(payload.Len()))
+	copy(header[20:24], checksum[:4])
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload.Bytes()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReadMessage reads a single message for the network identified by magic from r.
+// Messages with commands not known to this package are returned as a *MsgUnknown.
+//
+// Returns ErrInvalidMagic if the message is for a different network, ErrInvalidCommand
+// if the command name is malformed, ErrPayloadTooLarge if the payload length exceeds
+// MaxPayloadSize, ErrInvalidChecksum if the payload checksum is incorrect, or
+// ErrInvalidFormat if the payload cannot be decoded. Returns io.EOF if r is
+// exhausted before the first byte of the message.
+func ReadMessage(r io.Reader, magic uint32) (Message, error) {
+	header := make([]byte, MessageHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	if binary.LittleEndian.Uint32(header[:4]) != magic {
+		return nil, ErrInvalidMagic
+	}
+
+	command, err := decodeCommand(header[4 : 4+CommandSize])
+	if err != nil {
+		return nil, err
+	}
+
+	payloadLength := binary.LittleEndian.Uint32(header[16:20])
+	if payloadLength > MaxPayloadSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrPayloadTooLarge, payloadLength)
+	}
+
+	payload := make([]byte, payloadLength)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	checksum := bhash.DoubleSha256(payload)
+	if !bytes.Equal(checksum[:4], header[20:24]) {
+		return nil, ErrInvalidChecksum
+	}
+
+	msg := makeEmptyMessage(command)
+	if msg == nil {
+		msg = &MsgUnknown{CommandName: command}
+	}
+
+	reader := bytes.NewReader(payload)
+	if err := msg.Decode(reader); err != nil {
+		return nil, fmt.Errorf("failed to decode %s message: %w", command, err)
+	} else if reader.Len() != 0 {
+		return nil, fmt.Errorf("failed to decode %s message: %w: unexpected trailing data", command, ErrInvalidFormat)
+	}
+
+	return msg, nil
+}
+
+// encodeCommand encodes a command name as a null-padded 12 byte array.
+func encodeCommand(command string) (encoded [CommandSize]byte, err error) {
+	if len(command) > CommandSize {
+		err = fmt.Errorf("%w: %q is too long", ErrInvalidCommand, command)
+		return
+	}
+
+	for i := 0; i < len(command); i++ {
+		if command[i] < ' ' || command[i] > 0x7e {
+			err = fmt.Errorf("%w: %q contains non-printable characters", ErrInvalidCommand, command)
+			return
+		}
+	}
+
+	copy(encoded[:], command)
+	return
+}
+
+// decodeCommand decodes a null-padded command name. As in Bitcoin Core, the command
+// must consist of printable ASCII characters, followed only by null bytes.
+func decodeCommand(encoded []byte) (string, error) {
+	end := bytes.IndexByte(encoded, 0)
+	if end < 0 {
+		end = len(encoded)
+	}
+
+	for _, c := range encoded[end:] {
+		if c != 0 {
+			return "", fmt.Errorf("%w: non-null byte after command terminator", ErrInvalidCommand)
+		}
+	}
+
+	command := string(encoded[:end])
+	if _, err := encodeCommand(command); err != nil {
+		return "", err
+	}
+
+	return command, nil
+}
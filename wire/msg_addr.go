@@ -0,0 +1,89 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MsgAddr relays the network addresses of known peers.
+type MsgAddr struct {
+	Addresses []*NetAddress
+}
+
+// Command returns the command name of the message.
+func (msg *MsgAddr) Command() string { return CommandAddr }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgAddr) Encode(w io.Writer) error {
+	if len(msg.Addresses) > MaxAddrCount {
+		return fmt.Errorf("%w: too many addresses (%d > %d)", ErrIncompleteMessage, len(msg.Addresses), MaxAddrCount)
+	}
+
+	e := &encoder{w: w}
+	e.writeVarInt(uint64(len(msg.Addresses)))
+	for _, addr := range msg.Addresses {
+		if addr == nil {
+			return ErrIncompleteMessage
+		}
+		addr.encode(e, true)
+	}
+	return e.err
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgAddr) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	count := d.readCount(MaxAddrCount, "addresses")
+
+	msg.Addresses = make([]*NetAddress, 0, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		addr := new(NetAddress)
+		addr.decode(d, true)
+		msg.Addresses = append(msg.Addresses, addr)
+	}
+
+	return d.err
+}
+
+// MsgAddrV2 relays the network addresses of known peers, including
+// addresses on networks other than IPv4 and IPv6, as specified in BIP155.
+type MsgAddrV2 struct {
+	Addresses []*NetAddressV2
+}
+
+// Command returns the command name of the message.
+func (msg *MsgAddrV2) Command() string { return CommandAddrV2 }
+
+// Encode writes the serialized message payload to w. Returns ErrInvalidFormat
+// if any address does not have the size expected for its network.
+func (msg *MsgAddrV2) Encode(w io.Writer) error {
+	if len(msg.Addresses) > MaxAddrCount {
+		return fmt.Errorf("%w: too many addresses (%d > %d)", ErrIncompleteMessage, len(msg.Addresses), MaxAddrCount)
+	}
+
+	e := &encoder{w: w}
+	e.writeVarInt(uint64(len(msg.Addresses)))
+	for _, addr := range msg.Addresses {
+		if addr == nil {
+			return ErrIncompleteMessage
+		} else if err := addr.encode(e); err != nil {
+			return err
+		}
+	}
+	return e.err
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgAddrV2) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	count := d.readCount(MaxAddrCount, "addresses")
+
+	msg.Addresses = make([]*NetAddressV2, 0, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		addr := new(NetAddressV2)
+		addr.decode(d)
+		msg.Addresses = append(msg.Addresses, addr)
+	}
+
+	return d.err
+}
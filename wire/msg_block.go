@@ -0,0 +1,67 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+// MsgBlock is sent in reply to a getdata message requesting a block.
+type MsgBlock struct {
+	Block *blocks.Block
+}
+
+// Command returns the command name of the message.
+func (msg *MsgBlock) Command() string { return CommandBlock }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgBlock) Encode(w io.Writer) error {
+	if msg.Block == nil || msg.Block.Header == nil {
+		return ErrIncompleteMessage
+	}
+
+	_, err := msg.Block.WriteTo(w)
+	return err
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgBlock) Decode(r io.Reader) error {
+	block, err := blocks.FromReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidFormat, err)
+	}
+
+	msg.Block = block
+	return nil
+}
+
+// MsgTx relays a transaction, either unsolicited or in reply to a getdata message.
+type MsgTx struct {
+	Tx *tx.Tx
+}
+
+// Command returns the command name of the message.
+func (msg *MsgTx) Command() string { return CommandTx }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgTx) Encode(w io.Writer) error {
+	if msg.Tx == nil {
+		return ErrIncompleteMessage
+	}
+
+	_, err := msg.Tx.WriteTo(w)
+	return err
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgTx) Decode(r io.Reader) error {
+	txn, err := tx.FromReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidFormat, err)
+	}
+
+	msg.Tx = txn
+	return nil
+}
@@ -0,0 +1,47 @@
+package wire
+
+import (
+	"io"
+)
+
+// emptyMessage implements Encode and Decode for messages which have no payload.
+type emptyMessage struct{}
+
+// Encode writes nothing, because the message has no payload.
+func (emptyMessage) Encode(w io.Writer) error { return nil }
+
+// Decode reads nothing, because the message has no payload.
+func (emptyMessage) Decode(r io.Reader) error { return nil }
+
+// MsgVerack is sent in reply to a version message to acknowledge it.
+type MsgVerack struct{ emptyMessage }
+
+// Command returns the command name of the message.
+func (*MsgVerack) Command() string { return CommandVerack }
+
+// MsgGetAddr requests addr messages from a peer.
+type MsgGetAddr struct{ emptyMessage }
+
+// Command returns the command name of the message.
+func (*MsgGetAddr) Command() string { return CommandGetAddr }
+
+// MsgSendAddrV2 signals support for addrv2 messages, as specified in BIP155.
+// It must be sent after the version message and before verack.
+type MsgSendAddrV2 struct{ emptyMessage }
+
+// Command returns the command name of the message.
+func (*MsgSendAddrV2) Command() string { return CommandSendAddrV2 }
+
+// MsgSendHeaders requests that new blocks are announced with headers
+// messages instead of inv messages, as specified in BIP130.
+type MsgSendHeaders struct{ emptyMessage }
+
+// Command returns the command name of the message.
+func (*MsgSendHeaders) Command() string { return CommandSendHeaders }
+
+// MsgWtxidRelay signals that transactions should be announced by wtxid, as specified
+// in BIP339. It must be sent after the version message and before verack.
+type MsgWtxidRelay struct{ emptyMessage }
+
+// Command returns the command name of the message.
+func (*MsgWtxidRelay) Command() string { return CommandWtxidRelay }
@@ -0,0 +1,56 @@
+package wire
+
+import (
+	"io"
+)
+
+// MsgFeeFilter asks a peer not to announce transactions whose fee rate is below
+// FeeRate, in satoshis per 1000 virtual bytes, as specified in BIP133.
+type MsgFeeFilter struct {
+	FeeRate int64
+}
+
+// Command returns the command name of the message.
+func (msg *MsgFeeFilter) Command() string { return CommandFeeFilter }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgFeeFilter) Encode(w io.Writer) error {
+	e := &encoder{w: w}
+	e.writeUint64(uint64(msg.FeeRate))
+	return e.err
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgFeeFilter) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	msg.FeeRate = int64(d.readUint64())
+	return d.err
+}
+
+// MsgSendCmpct negotiates the relay of compact blocks, as specified in BIP152.
+type MsgSendCmpct struct {
+	// Announce requests that new blocks are announced with cmpctblock messages.
+	Announce bool
+
+	// Version is the compact block protocol version. Version 2 uses wtxids.
+	Version uint64
+}
+
+// Command returns the command name of the message.
+func (msg *MsgSendCmpct) Command() string { return CommandSendCmpct }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgSendCmpct) Encode(w io.Writer) error {
+	e := &encoder{w: w}
+	e.writeBool(msg.Announce)
+	e.writeUint64(msg.Version)
+	return e.err
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgSendCmpct) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	msg.Announce = d.readBool()
+	msg.Version = d.readUint64()
+	return d.err
+}
@@ -0,0 +1,105 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// blockLocator is the payload shared by getheaders and getblocks messages.
+type blockLocator struct {
+	version  uint32
+	locator  [][32]byte
+	hashStop [32]byte
+}
+
+func (bl *blockLocator) encode(w io.Writer) error {
+	if len(bl.locator) > MaxLocatorCount {
+		return fmt.Errorf("%w: too many locator hashes (%d > %d)", ErrIncompleteMessage, len(bl.locator), MaxLocatorCount)
+	}
+
+	e := &encoder{w: w}
+	e.writeUint32(bl.version)
+	e.writeVarInt(uint64(len(bl.locator)))
+	for _, hash := range bl.locator {
+		e.writeHash(hash)
+	}
+	e.writeHash(bl.hashStop)
+	return e.err
+}
+
+func (bl *blockLocator) decode(r io.Reader) error {
+	d := &decoder{r: r}
+	bl.version = d.readUint32()
+
+	count := d.readCount(MaxLocatorCount, "locator hashes")
+	bl.locator = make([][32]byte, 0, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		bl.locator = append(bl.locator, d.readHash())
+	}
+
+	bl.hashStop = d.readHash()
+	return d.err
+}
+
+// MsgGetHeaders requests a headers message containing the headers of blocks following
+// the last known block in BlockLocator, up to and including HashStop. If HashStop is
+// zero, up to MaxHeadersCount headers are returned.
+type MsgGetHeaders struct {
+	Version uint32
+
+	// BlockLocator is a list of block hashes, starting from the tip of the sender's
+	// chain and going back with exponentially increasing steps towards genesis.
+	BlockLocator [][32]byte
+	HashStop     [32]byte
+}
+
+// Command returns the command name of the message.
+func (msg *MsgGetHeaders) Command() string { return CommandGetHeaders }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgGetHeaders) Encode(w io.Writer) error {
+	bl := blockLocator{msg.Version, msg.BlockLocator, msg.HashStop}
+	return bl.encode(w)
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgGetHeaders) Decode(r io.Reader) error {
+	var bl blockLocator
+	if err := bl.decode(r); err != nil {
+		return err
+	}
+
+	msg.Version, msg.BlockLocator, msg.HashStop = bl.version, bl.locator, bl.hashStop
+	return nil
+}
+
+// MsgGetBlocks requests an inv message announcing the blocks which follow the last
+// known block in BlockLocator, up to and including HashStop, or up to 500 blocks.
+type MsgGetBlocks struct {
+	Version uint32
+
+	// BlockLocator is a list of block hashes, starting from the tip of the sender's
+	// chain and going back with exponentially increasing steps towards genesis.
+	BlockLocator [][32]byte
+	HashStop     [32]byte
+}
+
+// Command returns the command name of the message.
+func (msg *MsgGetBlocks) Command() string { return CommandGetBlocks }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgGetBlocks) Encode(w io.Writer) error {
+	bl := blockLocator{msg.Version, msg.BlockLocator, msg.HashStop}
+	return bl.encode(w)
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgGetBlocks) Decode(r io.Reader) error {
+	var bl blockLocator
+	if err := bl.decode(r); err != nil {
+		return err
+	}
+
+	msg.Version, msg.BlockLocator, msg.HashStop = bl.version, bl.locator, bl.hashStop
+	return nil
+}
@@ -0,0 +1,54 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/kklash/bitcoinlib/blocks/blockheader"
+)
+
+// MsgHeaders is sent in reply to a getheaders message, or to announce new blocks.
+type MsgHeaders struct {
+	Headers []*blockheader.BlockHeader
+}
+
+// Command returns the command name of the message.
+func (msg *MsgHeaders) Command() string { return CommandHeaders }
+
+// Encode writes the serialized message payload to w. Each header
+// is followed by a transaction count, which is always zero.
+func (msg *MsgHeaders) Encode(w io.Writer) error {
+	if len(msg.Headers) > MaxHeadersCount {
+		return fmt.Errorf("%w: too many headers (%d > %d)", ErrIncompleteMessage, len(msg.Headers), MaxHeadersCount)
+	}
+
+	e := &encoder{w: w}
+	e.writeVarInt(uint64(len(msg.Headers)))
+	for _, header := range msg.Headers {
+		if header == nil {
+			return ErrIncompleteMessage
+		}
+		e.writeTo(header)
+		e.writeVarInt(0)
+	}
+	return e.err
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+// As in Bitcoin Core, the transaction count following each header is ignored.
+func (msg *MsgHeaders) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	count := d.readCount(MaxHeadersCount, "headers")
+
+	msg.Headers = make([]*blockheader.BlockHeader, 0, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		header, err := blockheader.FromReader(r)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidFormat, err)
+		}
+		msg.Headers = append(msg.Headers, header)
+		d.readVarInt()
+	}
+
+	return d.err
+}
@@ -0,0 +1,130 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// InvType identifies the type of object referred to by an InvVect.
+type InvType uint32
+
+const (
+	InvTypeError         InvType = 0
+	InvTypeTx            InvType = 1
+	InvTypeBlock         InvType = 2
+	InvTypeFilteredBlock InvType = 3
+	InvTypeCmpctBlock    InvType = 4
+	InvTypeWtx           InvType = 5
+
+	// InvWitnessFlag is set on getdata requests for objects which should include
+	// witness data, as specified in BIP144.
+	InvWitnessFlag InvType = 1 << 30
+
+	InvTypeWitnessTx            = InvTypeTx | InvWitnessFlag
+	InvTypeWitnessBlock         = InvTypeBlock | InvWitnessFlag
+	InvTypeFilteredWitnessBlock = InvTypeFilteredBlock | InvWitnessFlag
+)
+
+// InvVectSize is the serialized size of an InvVect.
+const InvVectSize = 4 + 32
+
+// InvVect is an inventory vector, which identifies a transaction or block by its hash.
+type InvVect struct {
+	Type InvType
+	Hash [32]byte
+}
+
+// invList is the payload shared by inv, getdata and notfound messages.
+type invList []*InvVect
+
+func (list invList) encode(w io.Writer) error {
+	if len(list) > MaxInvCount {
+		return fmt.Errorf("%w: too many inventory vectors (%d > %d)", ErrIncompleteMessage, len(list), MaxInvCount)
+	}
+
+	e := &encoder{w: w}
+	e.writeVarInt(uint64(len(list)))
+	for _, inv := range list {
+		if inv == nil {
+			return ErrIncompleteMessage
+		}
+		e.writeUint32(uint32(inv.Type))
+		e.writeHash(inv.Hash)
+	}
+	return e.err
+}
+
+func decodeInvList(r io.Reader) (invList, error) {
+	d := &decoder{r: r}
+	count := d.readCount(MaxInvCount, "inventory vectors")
+
+	list := make(invList, 0, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		inv := new(InvVect)
+		inv.Type = InvType(d.readUint32())
+		inv.Hash = d.readHash()
+		list = append(list, inv)
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return list, nil
+}
+
+// MsgInv announces transactions or blocks known to the sender.
+type MsgInv struct {
+	Inventory []*InvVect
+}
+
+// Command returns the command name of the message.
+func (msg *MsgInv) Command() string { return CommandInv }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgInv) Encode(w io.Writer) error {
+	return invList(msg.Inventory).encode(w)
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgInv) Decode(r io.Reader) (err error) {
+	msg.Inventory, err = decodeInvList(r)
+	return
+}
+
+// MsgGetData requests transactions or blocks from a peer.
+type MsgGetData struct {
+	Inventory []*InvVect
+}
+
+// Command returns the command name of the message.
+func (msg *MsgGetData) Command() string { return CommandGetData }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgGetData) Encode(w io.Writer) error {
+	return invList(msg.Inventory).encode(w)
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgGetData) Decode(r io.Reader) (err error) {
+	msg.Inventory, err = decodeInvList(r)
+	return
+}
+
+// MsgNotFound is sent in reply to a getdata message for objects the peer does not have.
+type MsgNotFound struct {
+	Inventory []*InvVect
+}
+
+// Command returns the command name of the message.
+func (msg *MsgNotFound) Command() string { return CommandNotFound }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgNotFound) Encode(w io.Writer) error {
+	return invList(msg.Inventory).encode(w)
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgNotFound) Decode(r io.Reader) (err error) {
+	msg.Inventory, err = decodeInvList(r)
+	return
+}
@@ -0,0 +1,50 @@
+package wire
+
+import (
+	"io"
+)
+
+// MsgPing is sent to check that a connection is still alive. The
+// peer replies with a pong message with the same nonce, per BIP31.
+type MsgPing struct {
+	Nonce uint64
+}
+
+// Command returns the command name of the message.
+func (msg *MsgPing) Command() string { return CommandPing }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgPing) Encode(w io.Writer) error {
+	e := &encoder{w: w}
+	e.writeUint64(msg.Nonce)
+	return e.err
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgPing) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	msg.Nonce = d.readUint64()
+	return d.err
+}
+
+// MsgPong is the reply to a ping message.
+type MsgPong struct {
+	Nonce uint64
+}
+
+// Command returns the command name of the message.
+func (msg *MsgPong) Command() string { return CommandPong }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgPong) Encode(w io.Writer) error {
+	e := &encoder{w: w}
+	e.writeUint64(msg.Nonce)
+	return e.err
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgPong) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	msg.Nonce = d.readUint64()
+	return d.err
+}
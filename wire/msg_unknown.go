@@ -0,0 +1,27 @@
+package wire
+
+import (
+	"io"
+)
+
+// MsgUnknown holds the raw payload of a message whose command is not supported
+// by this package. Peers are expected to ignore messages they do not understand.
+type MsgUnknown struct {
+	CommandName string
+	Payload     []byte
+}
+
+// Command returns the command name of the message.
+func (msg *MsgUnknown) Command() string { return msg.CommandName }
+
+// Encode writes the raw message payload to w.
+func (msg *MsgUnknown) Encode(w io.Writer) error {
+	_, err := w.Write(msg.Payload)
+	return err
+}
+
+// Decode reads all remaining data from r into the message payload.
+func (msg *MsgUnknown) Decode(r io.Reader) (err error) {
+	msg.Payload, err = io.ReadAll(r)
+	return
+}
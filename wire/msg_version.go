@@ -0,0 +1,82 @@
+package wire
+
+import (
+	"io"
+)
+
+// Service flags which may be set in the Services bit field of a version message or address.
+const (
+	ServiceNodeNetwork        uint64 = 1 << 0
+	ServiceNodeBloom          uint64 = 1 << 2
+	ServiceNodeWitness        uint64 = 1 << 3
+	ServiceNodeCompactFilters uint64 = 1 << 6
+	ServiceNodeNetworkLimited uint64 = 1 << 10
+)
+
+// MsgVersion is the version message, which each peer sends when a connection is opened.
+type MsgVersion struct {
+	Version   int32
+	Services  uint64
+	Timestamp int64
+
+	// AddrRecv and AddrFrom are the network addresses of the receiving and sending
+	// peers. Their Time fields are not encoded.
+	AddrRecv NetAddress
+	AddrFrom NetAddress
+
+	// Nonce is a random value used to detect connections to self.
+	Nonce uint64
+
+	UserAgent   string
+	StartHeight int32
+
+	// Relay indicates whether the remote peer should announce transactions, as
+	// specified in BIP37. It is true when decoding a message which omits it.
+	Relay bool
+}
+
+// Command returns the command name of the message.
+func (msg *MsgVersion) Command() string { return CommandVersion }
+
+// Encode writes the serialized message payload to w.
+func (msg *MsgVersion) Encode(w io.Writer) error {
+	e := &encoder{w: w}
+	e.writeUint32(uint32(msg.Version))
+	e.writeUint64(msg.Services)
+	e.writeUint64(uint64(msg.Timestamp))
+	msg.AddrRecv.encode(e, false)
+	msg.AddrFrom.encode(e, false)
+	e.writeUint64(msg.Nonce)
+	e.writeVarBytes([]byte(msg.UserAgent))
+	e.writeUint32(uint32(msg.StartHeight))
+	e.writeBool(msg.Relay)
+	return e.err
+}
+
+// Decode reads the message payload from r, replacing the contents of the message.
+func (msg *MsgVersion) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	msg.Version = int32(d.readUint32())
+	msg.Services = d.readUint64()
+	msg.Timestamp = int64(d.readUint64())
+	msg.AddrRecv.decode(d, false)
+	msg.AddrFrom.decode(d, false)
+	msg.Nonce = d.readUint64()
+	msg.UserAgent = string(d.readVarBytes(MaxUserAgentSize, "user agent"))
+	msg.StartHeight = int32(d.readUint32())
+	if d.err != nil {
+		return d.err
+	}
+
+	// The relay flag is optional.
+	relay := make([]byte, 1)
+	if _, err := io.ReadFull(r, relay); err == io.EOF {
+		msg.Relay = true
+	} else if err != nil {
+		return err
+	} else {
+		msg.Relay = relay[0] != 0
+	}
+
+	return nil
+}
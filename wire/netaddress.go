@@ -0,0 +1,140 @@
+package wire
+
+import (
+	"fmt"
+	"net"
+)
+
+// NetAddress is a network address of a peer, as encoded in version and addr messages.
+type NetAddress struct {
+	// Time is the unix timestamp at which the peer was last seen. It is not
+	// encoded in the addresses of version messages.
+	Time uint32
+
+	// Services is a bit field of the services offered by the peer.
+	Services uint64
+
+	// IP is the IPv6 or IPv4 address of the peer. A nil IP is encoded as the unspecified address.
+	IP net.IP
+
+	Port uint16
+}
+
+func (addr *NetAddress) encode(e *encoder, withTime bool) {
+	if withTime {
+		e.writeUint32(addr.Time)
+	}
+	e.writeUint64(addr.Services)
+
+	ip := addr.IP.To16()
+	if ip == nil {
+		ip = net.IPv6unspecified
+	}
+	e.write(ip)
+	e.writeUint16BE(addr.Port)
+}
+
+func (addr *NetAddress) decode(d *decoder, withTime bool) {
+	if withTime {
+		addr.Time = d.readUint32()
+	}
+	addr.Services = d.readUint64()
+	addr.IP = net.IP(d.read(net.IPv6len))
+	addr.Port = d.readUint16BE()
+}
+
+// NetworkID identifies the type of network address in a NetAddressV2, as defined by BIP155.
+type NetworkID uint8
+
+const (
+	NetworkIPv4  NetworkID = 1
+	NetworkIPv6  NetworkID = 2
+	NetworkTorV2 NetworkID = 3
+	NetworkTorV3 NetworkID = 4
+	NetworkI2P   NetworkID = 5
+	NetworkCJDNS NetworkID = 6
+)
+
+// MaxAddrV2Size is the maximum byte length of an address in a NetAddressV2.
+const MaxAddrV2Size = 512
+
+// Size returns the expected byte length of addresses on this network,
+// or zero if the network is unknown.
+func (id NetworkID) Size() int {
+	switch id {
+	case NetworkIPv4:
+		return net.IPv4len
+	case NetworkIPv6, NetworkCJDNS:
+		return net.IPv6len
+	case NetworkTorV2:
+		return 10
+	case NetworkTorV3, NetworkI2P:
+		return 32
+	}
+	return 0
+}
+
+// NetAddressV2 is a network address of a peer as encoded in addrv2 messages, which
+// supports addresses on networks other than IPv4 and IPv6, as specified in BIP155.
+type NetAddressV2 struct {
+	// Time is the unix timestamp at which the peer was last seen.
+	Time uint32
+
+	// Services is a bit field of the services offered by the peer.
+	Services uint64
+
+	Network NetworkID
+
+	// Addr is the raw address, whose length must match the size expected for Network.
+	// Addresses on unknown networks may have any size up to MaxAddrV2Size.
+	Addr []byte
+
+	Port uint16
+}
+
+// IP returns the address as a net.IP if it is an IPv4, IPv6 or CJDNS address, or nil otherwise.
+func (addr *NetAddressV2) IP() net.IP {
+	switch addr.Network {
+	case NetworkIPv4, NetworkIPv6, NetworkCJDNS:
+		if len(addr.Addr) == addr.Network.Size() {
+			return net.IP(addr.Addr)
+		}
+	}
+	return nil
+}
+
+func (addr *NetAddressV2) encode(e *encoder) error {
+	if err := checkAddrV2Size(addr.Network, len(addr.Addr)); err != nil {
+		return err
+	}
+
+	e.writeUint32(addr.Time)
+	e.writeVarInt(addr.Services)
+	e.writeUint8(uint8(addr.Network))
+	e.writeVarBytes(addr.Addr)
+	e.writeUint16BE(addr.Port)
+	return nil
+}
+
+func (addr *NetAddressV2) decode(d *decoder) {
+	addr.Time = d.readUint32()
+	addr.Services = d.readVarInt()
+	addr.Network = NetworkID(d.readUint8())
+	addr.Addr = d.readVarBytes(MaxAddrV2Size, "address")
+	addr.Port = d.readUint16BE()
+
+	if d.err == nil {
+		if err := checkAddrV2Size(addr.Network, len(addr.Addr)); err != nil {
+			d.fail(err)
+		}
+	}
+}
+
+func checkAddrV2Size(network NetworkID, size int) error {
+	if expected := network.Size(); expected != 0 && size != expected {
+		return fmt.Errorf("%w: network %d address must be %d bytes, got %d", ErrInvalidFormat, network, expected, size)
+	} else if size > MaxAddrV2Size {
+		return fmt.Errorf("%w: address is too large (%d > %d)", ErrInvalidFormat, size, MaxAddrV2Size)
+	}
+	return nil
+}
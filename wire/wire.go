@@ -0,0 +1,158 @@
+// Package wire implements encoding and decoding of messages in the Bitcoin peer-to-peer
+// network protocol. Messages are framed with a header which contains the network magic,
+// command name, payload length and checksum.
+//
+// All 32-byte hashes in this package, such as inventory hashes and block locators, are
+// stored in the same reversed byte order as returned by blockheader.BlockHeader.Hash,
+// and are reversed when serialized.
+package wire
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	// ProtocolVersion is the latest version of the peer-to-peer protocol supported
+	// by this package. Version 70016 supports the wtxidrelay message from BIP339.
+	ProtocolVersion int32 = 70016
+
+	// MagicMainnet, MagicTestnet3, MagicSignet and MagicRegtest are the network magic
+	// values which begin every message on each network, in little-endian byte order.
+	MagicMainnet  uint32 = 0xd9b4bef9
+	MagicTestnet3 uint32 = 0x0709110b
+	MagicSignet   uint32 = 0x40cf030a
+	MagicRegtest  uint32 = 0xdab5bffa
+
+	// CommandSize is the byte size of the null-padded command name in a message header.
+	CommandSize = 12
+
+	// MessageHeaderSize is the byte size of a message header: the magic, command,
+	// payload length, and checksum.
+	MessageHeaderSize = 4 + CommandSize + 4 + 4
+
+	// MaxPayloadSize is the maximum size of a message payload accepted by Bitcoin Core.
+	MaxPayloadSize = 4 * 1000 * 1000
+
+	// MaxInvCount is the maximum number of entries in an inv, getdata or notfound message.
+	MaxInvCount = 50000
+
+	// MaxHeadersCount is the maximum number of headers in a headers message.
+	MaxHeadersCount = 2000
+
+	// MaxLocatorCount is the maximum number of hashes in a block locator.
+	MaxLocatorCount = 101
+
+	// MaxAddrCount is the maximum number of addresses in an addr or addrv2 message.
+	MaxAddrCount = 1000
+
+	// MaxUserAgentSize is the maximum byte length of the user agent in a version message.
+	MaxUserAgentSize = 256
+)
+
+var (
+	// ErrInvalidMagic is returned by ReadMessage if a message begins with the wrong network magic.
+	ErrInvalidMagic = errors.New("message has incorrect network magic")
+
+	// ErrInvalidChecksum is returned by ReadMessage if the checksum of a message payload is incorrect.
+	ErrInvalidChecksum = errors.New("message payload checksum is incorrect")
+
+	// ErrInvalidCommand is returned when reading or writing a message with a command name
+	// which is too long, or which contains characters which are not printable ASCII.
+	ErrInvalidCommand = errors.New("message command is not valid")
+
+	// ErrPayloadTooLarge is returned when reading or writing a message whose payload
+	// is larger than MaxPayloadSize.
+	ErrPayloadTooLarge = errors.New("message payload is too large")
+
+	// ErrInvalidFormat is returned when decoding a message payload which is not formatted correctly.
+	ErrInvalidFormat = errors.New("message payload is not formatted correctly")
+
+	// ErrIncompleteMessage is returned when trying to encode a message with missing fields.
+	ErrIncompleteMessage = errors.New("cannot encode incomplete message")
+)
+
+// Message is a peer-to-peer protocol message which can be encoded and decoded.
+type Message interface {
+	// Command returns the command name of the message.
+	Command() string
+
+	// Encode writes the serialized message payload to w.
+	Encode(w io.Writer) error
+
+	// Decode reads the message payload from r, replacing the contents of the message.
+	Decode(r io.Reader) error
+}
+
+// Command names of the messages supported by this package.
+const (
+	CommandVersion     = "version"
+	CommandVerack      = "verack"
+	CommandPing        = "ping"
+	CommandPong        = "pong"
+	CommandInv         = "inv"
+	CommandGetData     = "getdata"
+	CommandNotFound    = "notfound"
+	CommandGetHeaders  = "getheaders"
+	CommandHeaders     = "headers"
+	CommandGetBlocks   = "getblocks"
+	CommandBlock       = "block"
+	CommandTx          = "tx"
+	CommandGetAddr     = "getaddr"
+	CommandAddr        = "addr"
+	CommandAddrV2      = "addrv2"
+	CommandSendAddrV2  = "sendaddrv2"
+	CommandSendHeaders = "sendheaders"
+	CommandFeeFilter   = "feefilter"
+	CommandSendCmpct   = "sendcmpct"
+	CommandWtxidRelay  = "wtxidrelay"
+)
+
+// makeEmptyMessage returns an empty message for the given command, or
+// nil if the command is not supported by this package.
+func makeEmptyMessage(command string) Message {
+	switch command {
+	case CommandVersion:
+		return new(MsgVersion)
+	case CommandVerack:
+		return new(MsgVerack)
+	case CommandPing:
+		return new(MsgPing)
+	case CommandPong:
+		return new(MsgPong)
+	case CommandInv:
+		return new(MsgInv)
+	case CommandGetData:
+		return new(MsgGetData)
+	case CommandNotFound:
+		return new(MsgNotFound)
+	case CommandGetHeaders:
+		return new(MsgGetHeaders)
+	case CommandHeaders:
+		return new(MsgHeaders)
+	case CommandGetBlocks:
+		return new(MsgGetBlocks)
+	case CommandBlock:
+		return new(MsgBlock)
+	case CommandTx:
+		return new(MsgTx)
+	case CommandGetAddr:
+		return new(MsgGetAddr)
+	case CommandAddr:
+		return new(MsgAddr)
+	case CommandAddrV2:
+		return new(MsgAddrV2)
+	case CommandSendAddrV2:
+		return new(MsgSendAddrV2)
+	case CommandSendHeaders:
+		return new(MsgSendHeaders)
+	case CommandFeeFilter:
+		return new(MsgFeeFilter)
+	case CommandSendCmpct:
+		return new(MsgSendCmpct)
+	case CommandWtxidRelay:
+		return new(MsgWtxidRelay)
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/kklash/bitcoinlib/blocks"
+)
+
+const genesisBlockHex = "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c0101000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+func hex2bytes(s string) []byte {
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return decoded
+}
+
+func testHash() (hash [32]byte) {
+	for i := range hash {
+		hash[i] = byte(i)
+	}
+	return
+}
+
+func testMessages() []struct {
+	magic    uint32
+	msg      Message
+	expected string
+} {
+	genesis, err := blocks.FromReader(bytes.NewReader(hex2bytes(genesisBlockHex)))
+	if err != nil {
+		panic(err)
+	}
+
+	torV3 := make([]byte, 32)
+	for i := range torV3 {
+		torV3[i] = byte(i)
+	}
+
+	return []struct {
+		magic    uint32
+		msg      Message
+		expected string
+	}{
+		{
+			magic:    MagicMainnet,
+			msg:      new(MsgVerack),
+			expected: "f9beb4d976657261636b000000000000000000005df6e0e2",
+		},
+		{
+			magic:    MagicMainnet,
+			msg:      &MsgPing{Nonce: 0x0123456789abcdef},
+			expected: "f9beb4d970696e6700000000000000000800000033bc15e5efcdab8967452301",
+		},
+		{
+			magic: MagicMainnet,
+			msg: &MsgVersion{
+				Version:     ProtocolVersion,
+				Services:    ServiceNodeNetwork | ServiceNodeWitness | ServiceNodeNetworkLimited,
+				Timestamp:   1700000000,
+				AddrRecv:    NetAddress{IP: net.ParseIP("127.0.0.1"), Port: 8333},
+				AddrFrom:    NetAddress{Services: 0x409, IP: net.IPv6unspecified},
+				Nonce:       0xdeadbeef,
+				UserAgent:   "/bitcoinlib:0.1/",
+				StartHeight: 820000,
+				Relay:       true,
+			},
+			expected: "f9beb4d976657273696f6e0000000000660000008b4f175380110100090400000000000000f1536500000000000000000000000000000000000000000000ffff7f000001208d0904000000000000000000000000000000000000000000000000efbeadde00000000102f626974636f696e6c69623a302e312f20830c0001",
+		},
+		{
+			magic:    MagicMainnet,
+			msg:      &MsgInv{Inventory: []*InvVect{{InvTypeWitnessBlock, testHash()}}},
+			expected: "f9beb4d9696e76000000000000000000250000002fb0aecc01020000401f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100",
+		},
+		{
+			magic:    MagicMainnet,
+			msg:      &MsgGetHeaders{Version: uint32(ProtocolVersion), BlockLocator: [][32]byte{testHash()}},
+			expected: "f9beb4d967657468656164657273000045000000fa47e61980110100011f1e1d1c1b1a191817161514131211100f0e0d0c0b0a090807060504030201000000000000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			magic: MagicMainnet,
+			msg: &MsgAddrV2{Addresses: []*NetAddressV2{
+				{Time: 1700000000, Services: 0x409, Network: NetworkTorV3, Addr: torV3, Port: 9050},
+			}},
+			expected: "f9beb4d96164647276320000000000002c000000cd8da4090100f15365fd09040420000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f235a",
+		},
+		{
+			magic: MagicTestnet3,
+			msg: &MsgAddr{Addresses: []*NetAddress{
+				{Time: 1700000000, Services: ServiceNodeNetwork, IP: net.ParseIP("10.0.0.1"), Port: 18333},
+			}},
+			expected: "0b1109076164647200000000000000001f0000002b65610b0100f15365010000000000000000000000000000000000ffff0a000001479d",
+		},
+		{
+			magic:    MagicMainnet,
+			msg:      &MsgSendCmpct{Announce: false, Version: 2},
+			expected: "f9beb4d973656e64636d70637400000009000000e92f5ef8000200000000000000",
+		},
+		{
+			magic:    MagicMainnet,
+			msg:      &MsgFeeFilter{FeeRate: 1000},
+			expected: "f9beb4d966656566696c74657200000008000000e80fd19fe803000000000000",
+		},
+		{
+			magic:    MagicRegtest,
+			msg:      new(MsgWtxidRelay),
+			expected: "fabfb5da777478696472656c61790000000000005df6e0e2",
+		},
+		{
+			magic:    MagicMainnet,
+			msg:      &MsgBlock{Block: genesis},
+			expected: "f9beb4d9626c6f636b000000000000001d010000f71a2403" + genesisBlockHex,
+		},
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	for _, fixture := range testMessages() {
+		buf := new(bytes.Buffer)
+		if err := WriteMessage(buf, fixture.magic, fixture.msg); err != nil {
+			t.Errorf("failed to write %s message: %s", fixture.msg.Command(), err)
+			continue
+		}
+
+		if actual := hex.EncodeToString(buf.Bytes()); actual != fixture.expected {
+			t.Errorf(
+				"%s message encoded incorrectly\nWanted %s\nGot    %s",
+				fixture.msg.Command(), fixture.expected, actual,
+			)
+			continue
+		}
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	for _, fixture := range testMessages() {
+		msg, err := ReadMessage(bytes.NewReader(hex2bytes(fixture.expected)), fixture.magic)
+		if err != nil {
+			t.Errorf("failed to read %s message: %s", fixture.msg.Command(), err)
+			continue
+		}
+
+		if msg.Command() != fixture.msg.Command() {
+			t.Errorf("expected %s message, got %s", fixture.msg.Command(), msg.Command())
+			continue
+		}
+
+		buf := new(bytes.Buffer)
+		if err := WriteMessage(buf, fixture.magic, msg); err != nil {
+			t.Errorf("failed to re-encode %s message: %s", msg.Command(), err)
+			continue
+		}
+
+		if actual := hex.EncodeToString(buf.Bytes()); actual != fixture.expected {
+			t.Errorf(
+				"%s message did not round-trip\nWanted %s\nGot    %s",
+				msg.Command(), fixture.expected, actual,
+			)
+			continue
+		}
+	}
+}
+
+func TestReadMessage_VersionWithoutRelay(t *testing.T) {
+	msg := &MsgVersion{Version: 70001, UserAgent: "/test/", Relay: false}
+	payload := new(bytes.Buffer)
+	if err := msg.Encode(payload); err != nil {
+		t.Errorf("failed to encode version message: %s", err)
+		return
+	}
+
+	// Strip the relay flag
+	decoded := new(MsgVersion)
+	if err := decoded.Decode(bytes.NewReader(payload.Bytes()[:payload.Len()-1])); err != nil {
+		t.Errorf("failed to decode version message without relay flag: %s", err)
+		return
+	}
+
+	if !decoded.Relay {
+		t.Errorf("expected relay flag to default to true")
+		return
+	}
+}
+
+func TestReadMessage_Errors(t *testing.T) {
+	fixtures := []struct {
+		name     string
+		hex      string
+		expected error
+	}{
+		{"empty", "", io.EOF},
+		{"short header", "f9beb4d976657261", io.ErrUnexpectedEOF},
+		{"wrong magic", "0b11090776657261636b000000000000000000005df6e0e2", ErrInvalidMagic},
+		{"bad checksum", "f9beb4d976657261636b000000000000000000005df6e0e3", ErrInvalidChecksum},
+		{"command non-null padding", "f9beb4d976657261636b000000000001000000005df6e0e2", ErrInvalidCommand},
+		{"command non-printable", "f9beb4d976657261636b0a0000000000000000005df6e0e2", ErrInvalidCommand},
+		{"payload too large", "f9beb4d976657261636b000000000000ffffffff5df6e0e2", ErrPayloadTooLarge},
+		{"truncated payload", "f9beb4d970696e6700000000000000000800000033bc15e5efcdab89", io.ErrUnexpectedEOF},
+		{"trailing data", "f9beb4d976657261636b000000000000010000001406e05800", ErrInvalidFormat},
+		{"short ping", "f9beb4d970696e67000000000000000004000000671a0eadefcdab89", ErrInvalidFormat},
+		{"too many inv", "f9beb4d9696e7600000000000000000005000000ec7fd97bfe51c30000", ErrInvalidFormat},
+		{"addrv2 wrong size", "f9beb4d96164647276320000000000000d000000" + "9df98b51" + "0100f153650001030102030000", ErrInvalidFormat},
+	}
+
+	for _, fixture := range fixtures {
+		_, err := ReadMessage(bytes.NewReader(hex2bytes(fixture.hex)), MagicMainnet)
+		if !errors.Is(err, fixture.expected) {
+			t.Errorf("%s: expected error %v, got %v", fixture.name, fixture.expected, err)
+			continue
+		}
+	}
+}
+
+func TestReadMessage_Unknown(t *testing.T) {
+	msg := &MsgUnknown{CommandName: "cmpctblock", Payload: []byte{1, 2, 3}}
+
+	buf := new(bytes.Buffer)
+	if err := WriteMessage(buf, MagicMainnet, msg); err != nil {
+		t.Errorf("failed to write unknown message: %s", err)
+		return
+	}
+
+	decoded, err := ReadMessage(buf, MagicMainnet)
+	if err != nil {
+		t.Errorf("failed to read unknown message: %s", err)
+		return
+	}
+
+	unknown, ok := decoded.(*MsgUnknown)
+	if !ok {
+		t.Errorf("expected *MsgUnknown, got %T", decoded)
+		return
+	} else if unknown.CommandName != msg.CommandName || !bytes.Equal(unknown.Payload, msg.Payload) {
+		t.Errorf("unknown message did not round-trip: %+v", unknown)
+		return
+	}
+}
+
+func TestWriteMessage_Errors(t *testing.T) {
+	fixtures := []struct {
+		msg      Message
+		expected error
+	}{
+		{&MsgUnknown{CommandName: "waytoolongcommand"}, ErrInvalidCommand},
+		{&MsgUnknown{CommandName: "bad\ncmd"}, ErrInvalidCommand},
+		{&MsgUnknown{CommandName: "big", Payload: make([]byte, MaxPayloadSize+1)}, ErrPayloadTooLarge},
+		{new(MsgBlock), ErrIncompleteMessage},
+		{new(MsgTx), ErrIncompleteMessage},
+		{&MsgInv{Inventory: make([]*InvVect, MaxInvCount+1)}, ErrIncompleteMessage},
+		{&MsgAddrV2{Addresses: []*NetAddressV2{{Network: NetworkIPv4, Addr: []byte{1, 2, 3}}}}, ErrInvalidFormat},
+	}
+
+	for _, fixture := range fixtures {
+		err := WriteMessage(io.Discard, MagicMainnet, fixture.msg)
+		if !errors.Is(err, fixture.expected) {
+			t.Errorf("%s: expected error %v, got %v", fixture.msg.Command(), fixture.expected, err)
+			continue
+		}
+	}
+}
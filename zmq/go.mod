@@ -0,0 +1,3 @@
+module github.com/kklash/bitcoinlib/zmq
+
+go 1.18
@@ -0,0 +1,151 @@
+package zmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/kklash/bitcoinlib/blocks"
+	"github.com/kklash/bitcoinlib/tx"
+)
+
+// Topic is the topic of a bitcoind ZMQ notification. Each topic is published on the
+// address given by the corresponding -zmqpub<topic> option, such as -zmqpubrawblock.
+type Topic string
+
+const (
+	// TopicRawBlock notifications carry each block connected to the node's best chain.
+	TopicRawBlock Topic = "rawblock"
+
+	// TopicHashBlock notifications carry the hash of each block connected to the node's best chain.
+	TopicHashBlock Topic = "hashblock"
+
+	// TopicRawTx notifications carry each transaction added to the mempool or included in a connected block.
+	TopicRawTx Topic = "rawtx"
+
+	// TopicHashTx notifications carry the txid of each transaction added to the
+	// mempool or included in a connected block.
+	TopicHashTx Topic = "hashtx"
+
+	// TopicSequence notifications report blocks connected to and disconnected from
+	// the best chain, and transactions added to and removed from the mempool.
+	TopicSequence Topic = "sequence"
+)
+
+// ErrInvalidNotification is returned when a notification from the publisher cannot be decoded.
+var ErrInvalidNotification = errors.New("invalid ZMQ notification")
+
+// SequenceEventType identifies the kind of event reported by a TopicSequence notification.
+type SequenceEventType byte
+
+const (
+	BlockConnected    SequenceEventType = 'C'
+	BlockDisconnected SequenceEventType = 'D'
+	TxAdded           SequenceEventType = 'A'
+	TxRemoved         SequenceEventType = 'R'
+)
+
+// SequenceEvent is the body of a TopicSequence notification.
+type SequenceEvent struct {
+	Type SequenceEventType
+
+	// Hash is the block hash or txid, in RPC byte order.
+	Hash [32]byte
+
+	// MempoolSequence is the node's mempool sequence number after the event.
+	// It is only set for TxAdded and TxRemoved events.
+	MempoolSequence uint64
+}
+
+// Notification is a message received from bitcoind's ZMQ publisher.
+type Notification struct {
+	Topic Topic
+
+	// Body is the raw message body.
+	Body []byte
+
+	// Sequence is the publisher's sequence number for the notification's topic. It
+	// starts at zero when the node starts, and increments with each notification.
+	Sequence uint32
+
+	// Missed is the number of notifications on this topic which were skipped between
+	// the previous notification and this one, as detected by a gap in Sequence numbers.
+	// Notifications can be dropped by the publisher if the subscriber reads too slowly.
+	Missed uint32
+
+	// Block is set for TopicRawBlock notifications.
+	Block *blocks.Block
+
+	// Tx is set for TopicRawTx notifications.
+	Tx *tx.Tx
+
+	// Hash is set for TopicHashBlock, TopicHashTx and TopicSequence notifications.
+	// It is the block hash or txid, in RPC byte order.
+	Hash [32]byte
+
+	// Event is set for TopicSequence notifications.
+	Event *SequenceEvent
+}
+
+func parseNotification(parts [][]byte) (*Notification, error) {
+	if len(parts) != 3 || len(parts[2]) != 4 {
+		return nil, fmt.Errorf("%w: expected topic, body and sequence number, got %d parts", ErrInvalidNotification, len(parts))
+	}
+
+	notification := &Notification{
+		Topic:    Topic(parts[0]),
+		Body:     parts[1],
+		Sequence: binary.LittleEndian.Uint32(parts[2]),
+	}
+	body := notification.Body
+
+	switch notification.Topic {
+	case TopicRawBlock:
+		block, err := blocks.FromReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to decode block: %s", ErrInvalidNotification, err)
+		}
+		notification.Block = block
+
+	case TopicRawTx:
+		txn, err := tx.FromBytes(body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to decode tx: %s", ErrInvalidNotification, err)
+		}
+		notification.Tx = txn
+
+	case TopicHashBlock, TopicHashTx:
+		if len(body) != 32 {
+			return nil, fmt.Errorf("%w: %s body has length %d", ErrInvalidNotification, notification.Topic, len(body))
+		}
+		copy(notification.Hash[:], body)
+
+	case TopicSequence:
+		if len(body) < 33 {
+			return nil, fmt.Errorf("%w: sequence body has length %d", ErrInvalidNotification, len(body))
+		}
+
+		event := &SequenceEvent{Type: SequenceEventType(body[32])}
+		copy(event.Hash[:], body[:32])
+
+		switch event.Type {
+		case BlockConnected, BlockDisconnected:
+			if len(body) != 33 {
+				return nil, fmt.Errorf("%w: sequence body has length %d", ErrInvalidNotification, len(body))
+			}
+		case TxAdded, TxRemoved:
+			if len(body) != 41 {
+				return nil, fmt.Errorf("%w: sequence body has length %d", ErrInvalidNotification, len(body))
+			}
+			event.MempoolSequence = binary.LittleEndian.Uint64(body[33:])
+		default:
+			return nil, fmt.Errorf("%w: unknown sequence event type %q", ErrInvalidNotification, body[32])
+		}
+
+		notification.Hash = event.Hash
+		notification.Event = event
+	}
+
+	return notification, nil
+}
@@ -0,0 +1,223 @@
+// Package zmq provides a pure-Go client for the ZeroMQ notifications published by bitcoind
+// when it is run with options such as -zmqpubrawblock=tcp://127.0.0.1:28332. It implements
+// just enough of ZMTP 3.0 to act as a SUB socket.
+package zmq
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is the timeout used for connecting to the publisher and for the handshake.
+const DefaultTimeout = 30 * time.Second
+
+// Subscriber is a SUB connection to a bitcoind ZMQ publisher.
+type Subscriber struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	writeMutex sync.Mutex
+
+	lastSequence map[Topic]uint32
+}
+
+// Connect opens a TCP connection to the publisher at the given address, which may be given as
+// in bitcoin.conf, such as "tcp://127.0.0.1:28332", and subscribes to the given topics. If no
+// topics are given, the Subscriber receives every notification sent to the address.
+func Connect(address string, topics ...Topic) (*Subscriber, error) {
+	address = strings.TrimPrefix(address, "tcp://")
+
+	conn, err := net.DialTimeout("tcp", address, DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := NewSubscriber(conn, topics...)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// NewSubscriber performs the ZMTP handshake on an existing connection to a publisher, and
+// subscribes to the given topics. The caller is responsible for closing conn if the handshake fails.
+func NewSubscriber(conn net.Conn, topics ...Topic) (*Subscriber, error) {
+	sub := &Subscriber{
+		conn:         conn,
+		reader:       bufio.NewReader(conn),
+		lastSequence: make(map[Topic]uint32),
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(DefaultTimeout)); err != nil {
+		return nil, err
+	}
+
+	if err := sub.handshake(); err != nil {
+		return nil, err
+	}
+
+	if len(topics) == 0 {
+		topics = []Topic{""}
+	}
+	for _, topic := range topics {
+		if err := sub.subscribe(topic); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+func (sub *Subscriber) handshake() error {
+	if _, err := sub.conn.Write(greeting()); err != nil {
+		return err
+	}
+
+	remoteGreeting := make([]byte, greetingSize)
+	if _, err := io.ReadFull(sub.reader, remoteGreeting); err != nil {
+		return err
+	} else if err := checkGreeting(remoteGreeting); err != nil {
+		return err
+	}
+
+	ready := &command{
+		name: "READY",
+		data: encodeMetadata(map[string]string{"Socket-Type": "SUB"}),
+	}
+	if err := writeFrame(sub.conn, ready.frame()); err != nil {
+		return err
+	}
+
+	f, err := readFrame(sub.reader)
+	if err != nil {
+		return err
+	} else if !f.command {
+		return fmt.Errorf("%w: expected READY command", ErrHandshakeFailed)
+	}
+
+	remoteReady, err := parseCommand(f.body)
+	if err != nil {
+		return err
+	} else if remoteReady.name == "ERROR" {
+		return fmt.Errorf("%w: %s", ErrHandshakeFailed, errorReason(remoteReady.data))
+	} else if remoteReady.name != "READY" {
+		return fmt.Errorf("%w: expected READY command, got %s", ErrHandshakeFailed, remoteReady.name)
+	}
+
+	properties, err := decodeMetadata(remoteReady.data)
+	if err != nil {
+		return err
+	}
+
+	if socketType := properties["socket-type"]; socketType != "PUB" && socketType != "XPUB" {
+		return fmt.Errorf("%w: cannot subscribe to %q socket", ErrHandshakeFailed, socketType)
+	}
+
+	return nil
+}
+
+func errorReason(data []byte) string {
+	if len(data) < 1 || len(data) < 1+int(data[0]) {
+		return "unknown error"
+	}
+	return string(data[1 : 1+int(data[0])])
+}
+
+// subscribe sends a subscription message, which ZMTP 3.0 encodes
+// as a data frame containing 0x01 followed by the topic prefix.
+func (sub *Subscriber) subscribe(topic Topic) error {
+	sub.writeMutex.Lock()
+	defer sub.writeMutex.Unlock()
+
+	return writeFrame(sub.conn, &frame{body: append([]byte{1}, topic...)})
+}
+
+// Receive blocks until the next notification arrives from the publisher, and returns it.
+// If a notification cannot be decoded, Receive returns an error wrapping
+// ErrInvalidNotification, but the Subscriber can continue to be used.
+// Receive should not be called concurrently.
+func (sub *Subscriber) Receive() (*Notification, error) {
+	var parts [][]byte
+
+	for {
+		f, err := readFrame(sub.reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if f.command {
+			if err := sub.handleCommand(f); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		parts = append(parts, f.body)
+		if !f.more {
+			break
+		}
+	}
+
+	// The sequence number is recorded before the body is decoded, so that a notification
+	// which cannot be decoded is not counted as missed by the next one on its topic.
+	var missed uint32
+	if len(parts) == 3 && len(parts[2]) == 4 {
+		topic := Topic(parts[0])
+		sequence := binary.LittleEndian.Uint32(parts[2])
+		if lastSequence, ok := sub.lastSequence[topic]; ok {
+			missed = sequence - lastSequence - 1
+		}
+		sub.lastSequence[topic] = sequence
+	}
+
+	notification, err := parseNotification(parts)
+	if err != nil {
+		return nil, err
+	}
+	notification.Missed = missed
+
+	return notification, nil
+}
+
+// handleCommand responds to ZMTP heartbeats, and returns an
+// error if the publisher reports an error.
+func (sub *Subscriber) handleCommand(f *frame) error {
+	c, err := parseCommand(f.body)
+	if err != nil {
+		return err
+	}
+
+	switch c.name {
+	case "PING":
+		if len(c.data) < 2 {
+			return fmt.Errorf("%w: truncated PING", ErrInvalidFrame)
+		}
+
+		pong := &command{name: "PONG", data: c.data[2:]}
+
+		sub.writeMutex.Lock()
+		defer sub.writeMutex.Unlock()
+		return writeFrame(sub.conn, pong.frame())
+
+	case "ERROR":
+		return fmt.Errorf("publisher sent error: %s", errorReason(c.data))
+	}
+
+	return nil
+}
+
+// Close closes the connection to the publisher. Any blocked call to Receive returns an error.
+func (sub *Subscriber) Close() error {
+	return sub.conn.Close()
+}
@@ -0,0 +1,386 @@
+package zmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kklash/bitcoinlib/common"
+)
+
+const (
+	genesisBlockHex  = "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c0101000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+	genesisBlockHash = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	genesisTxid      = "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+)
+
+func hex2bytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func hex2hash(s string) (hash [32]byte) {
+	copy(hash[:], hex2bytes(s))
+	return
+}
+
+// fakePublisher is an in-process ZMTP 3.1 PUB socket, which behaves like bitcoind's
+// ZMQ notification interface for a single subscriber.
+type fakePublisher struct {
+	listener   net.Listener
+	socketType string
+	mechanism  string
+
+	conn          net.Conn
+	subscriptions [][]byte
+	sequences     map[Topic]uint32
+}
+
+func newFakePublisher(t *testing.T) *fakePublisher {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	return &fakePublisher{
+		listener:   listener,
+		socketType: "PUB",
+		mechanism:  "NULL",
+		sequences:  make(map[Topic]uint32),
+	}
+}
+
+// accept waits for a subscriber to connect, and performs the publisher's side of
+// the handshake. It then reads nSubscriptions subscription messages.
+func (pub *fakePublisher) accept(nSubscriptions int) error {
+	conn, err := pub.listener.Accept()
+	if err != nil {
+		return err
+	}
+	pub.conn = conn
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	g := greeting()
+	g[11] = 1
+	copy(g[12:32], make([]byte, 20))
+	copy(g[12:32], pub.mechanism)
+	if _, err := conn.Write(g); err != nil {
+		return err
+	}
+
+	remoteGreeting := make([]byte, greetingSize)
+	if _, err := io.ReadFull(conn, remoteGreeting); err != nil {
+		return err
+	} else if err := checkGreeting(remoteGreeting); err != nil {
+		return err
+	}
+
+	f, err := readFrame(conn)
+	if err != nil {
+		return err
+	}
+	c, err := parseCommand(f.body)
+	if err != nil {
+		return err
+	}
+	properties, err := decodeMetadata(c.data)
+	if err != nil {
+		return err
+	} else if c.name != "READY" || properties["socket-type"] != "SUB" {
+		return errors.New("subscriber sent unexpected READY command")
+	}
+
+	ready := &command{name: "READY", data: encodeMetadata(map[string]string{"Socket-Type": pub.socketType})}
+	if err := writeFrame(conn, ready.frame()); err != nil {
+		return err
+	}
+
+	for i := 0; i < nSubscriptions; i++ {
+		f, err := readFrame(conn)
+		if err != nil {
+			return err
+		} else if f.command || len(f.body) == 0 || f.body[0] != 1 {
+			return errors.New("subscriber sent unexpected subscription message")
+		}
+		pub.subscriptions = append(pub.subscriptions, f.body[1:])
+	}
+
+	return nil
+}
+
+// publish sends a notification with the next sequence number for its topic.
+func (pub *fakePublisher) publish(topic Topic, body []byte) error {
+	sequence := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sequence, pub.sequences[topic])
+	pub.sequences[topic]++
+
+	return pub.send([]byte(topic), body, sequence)
+}
+
+func (pub *fakePublisher) send(parts ...[]byte) error {
+	for i, part := range parts {
+		if err := writeFrame(pub.conn, &frame{more: i < len(parts)-1, body: part}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func connectToFake(t *testing.T, pub *fakePublisher, topics ...Topic) (*Subscriber, error) {
+	nSubscriptions := len(topics)
+	if nSubscriptions == 0 {
+		nSubscriptions = 1
+	}
+
+	acceptErr := make(chan error, 1)
+	go func() {
+		acceptErr <- pub.accept(nSubscriptions)
+	}()
+
+	sub, err := Connect("tcp://"+pub.listener.Addr().String(), topics...)
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { sub.Close() })
+
+	if err := <-acceptErr; err != nil {
+		t.Fatalf("fake publisher failed to accept subscriber: %s", err)
+	}
+	return sub, nil
+}
+
+func TestSubscriber_Receive(t *testing.T) {
+	pub := newFakePublisher(t)
+	sub, err := connectToFake(t, pub, TopicRawBlock, TopicHashBlock, TopicRawTx, TopicHashTx, TopicSequence)
+	if err != nil {
+		t.Errorf("failed to connect: %s", err)
+		return
+	}
+
+	if len(pub.subscriptions) != 5 || string(pub.subscriptions[0]) != "rawblock" || string(pub.subscriptions[4]) != "sequence" {
+		t.Errorf("incorrect subscriptions: %q", pub.subscriptions)
+		return
+	}
+
+	genesisBytes := hex2bytes(genesisBlockHex)
+	blockHash := hex2hash(genesisBlockHash)
+	txid := hex2hash(genesisTxid)
+	coinbaseBytes := genesisBytes[81:]
+
+	go func() {
+		pub.publish(TopicRawBlock, genesisBytes)
+		pub.publish(TopicHashBlock, blockHash[:])
+
+		// A heartbeat in between messages should be answered transparently.
+		ping := &command{name: "PING", data: []byte{0, 100, 'p', 'i', 'n', 'g'}}
+		writeFrame(pub.conn, ping.frame())
+
+		pub.publish(TopicRawTx, coinbaseBytes)
+		pub.publish(TopicHashTx, txid[:])
+		pub.sequences[TopicHashTx] += 3 // simulate dropped notifications
+		pub.publish(TopicHashTx, txid[:])
+		pub.publish(TopicSequence, append(blockHash[:], 'C'))
+		pub.publish(TopicSequence, append(append(txid[:], 'A'), 7, 0, 0, 0, 0, 0, 0, 0))
+	}()
+
+	notification, err := sub.Receive()
+	if err != nil {
+		t.Errorf("failed to receive rawblock: %s", err)
+		return
+	} else if notification.Topic != TopicRawBlock || notification.Block == nil {
+		t.Errorf("expected rawblock notification, got %+v", notification)
+		return
+	} else if hash, _ := notification.Block.Header.Hash(); hash != blockHash {
+		t.Errorf("incorrect block hash: %x", hash)
+		return
+	}
+
+	notification, err = sub.Receive()
+	if err != nil {
+		t.Errorf("failed to receive hashblock: %s", err)
+		return
+	} else if notification.Topic != TopicHashBlock || notification.Hash != blockHash {
+		t.Errorf("incorrect hashblock notification: %+v", notification)
+		return
+	}
+
+	notification, err = sub.Receive()
+	if err != nil {
+		t.Errorf("failed to receive rawtx: %s", err)
+		return
+	} else if notification.Topic != TopicRawTx || notification.Tx == nil {
+		t.Errorf("expected rawtx notification, got %+v", notification)
+		return
+	} else if hash, _ := notification.Tx.Hash(false); !bytes.Equal(common.ReverseBytes(hash[:]), txid[:]) {
+		t.Errorf("incorrect tx hash: %x", hash)
+		return
+	}
+
+	pong, err := readFrame(pub.conn)
+	if err != nil {
+		t.Errorf("failed to read pong: %s", err)
+		return
+	} else if c, err := parseCommand(pong.body); err != nil || c.name != "PONG" || string(c.data) != "ping" {
+		t.Errorf("incorrect PONG command: %q", pong.body)
+		return
+	}
+
+	notification, err = sub.Receive()
+	if err != nil {
+		t.Errorf("failed to receive hashtx: %s", err)
+		return
+	} else if notification.Topic != TopicHashTx || notification.Hash != txid || notification.Sequence != 0 || notification.Missed != 0 {
+		t.Errorf("incorrect hashtx notification: %+v", notification)
+		return
+	}
+
+	notification, err = sub.Receive()
+	if err != nil {
+		t.Errorf("failed to receive hashtx: %s", err)
+		return
+	} else if notification.Sequence != 4 || notification.Missed != 3 {
+		t.Errorf("expected gap of 3 notifications before sequence 4, got %d missed before %d", notification.Missed, notification.Sequence)
+		return
+	}
+
+	notification, err = sub.Receive()
+	if err != nil {
+		t.Errorf("failed to receive sequence: %s", err)
+		return
+	}
+	expectedEvent := SequenceEvent{Type: BlockConnected, Hash: blockHash}
+	if notification.Event == nil || *notification.Event != expectedEvent || notification.Hash != blockHash {
+		t.Errorf("incorrect sequence notification: %+v", notification.Event)
+		return
+	}
+
+	notification, err = sub.Receive()
+	if err != nil {
+		t.Errorf("failed to receive sequence: %s", err)
+		return
+	}
+	expectedEvent = SequenceEvent{Type: TxAdded, Hash: txid, MempoolSequence: 7}
+	if notification.Event == nil || *notification.Event != expectedEvent || notification.Sequence != 1 || notification.Missed != 0 {
+		t.Errorf("incorrect sequence notification: %+v", notification.Event)
+		return
+	}
+}
+
+func TestSubscriber_InvalidNotification(t *testing.T) {
+	pub := newFakePublisher(t)
+	sub, err := connectToFake(t, pub)
+	if err != nil {
+		t.Errorf("failed to connect: %s", err)
+		return
+	} else if len(pub.subscriptions) != 1 || len(pub.subscriptions[0]) != 0 {
+		t.Errorf("expected subscription to all topics, got %q", pub.subscriptions)
+		return
+	}
+
+	go func() {
+		pub.publish(TopicHashBlock, make([]byte, 32))
+		pub.send([]byte("hashblock"), make([]byte, 32))
+		pub.publish(TopicHashBlock, make([]byte, 31))
+		pub.publish(TopicSequence, append(make([]byte, 32), 'X'))
+		pub.publish(TopicRawTx, []byte{1, 2, 3})
+		pub.publish("unknown", []byte("hello"))
+		pub.publish(TopicHashBlock, make([]byte, 32))
+	}()
+
+	if _, err := sub.Receive(); err != nil {
+		t.Errorf("failed to receive first notification: %s", err)
+		return
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := sub.Receive(); !errors.Is(err, ErrInvalidNotification) {
+			t.Errorf("expected ErrInvalidNotification for message %d, got %v", i, err)
+			return
+		}
+	}
+
+	notification, err := sub.Receive()
+	if err != nil {
+		t.Errorf("failed to receive notification after invalid ones: %s", err)
+		return
+	} else if notification.Topic != "unknown" || string(notification.Body) != "hello" {
+		t.Errorf("incorrect notification: %+v", notification)
+		return
+	}
+
+	// The notification which could not be decoded was received, so it is not missed.
+	notification, err = sub.Receive()
+	if err != nil {
+		t.Errorf("failed to receive notification: %s", err)
+		return
+	} else if notification.Sequence != 2 || notification.Missed != 0 {
+		t.Errorf("expected sequence 2 with none missed, got %d with %d missed", notification.Sequence, notification.Missed)
+		return
+	}
+}
+
+func TestSubscriber_Handshake(t *testing.T) {
+	pub := newFakePublisher(t)
+	pub.socketType = "REP"
+	if _, err := connectToFake(t, pub); !errors.Is(err, ErrHandshakeFailed) {
+		t.Errorf("expected ErrHandshakeFailed for REP socket, got %v", err)
+		return
+	}
+
+	pub = newFakePublisher(t)
+	pub.mechanism = "CURVE"
+
+	go pub.accept(0)
+	if _, err := Connect(pub.listener.Addr().String()); !errors.Is(err, ErrInvalidGreeting) {
+		t.Errorf("expected ErrInvalidGreeting for CURVE mechanism, got %v", err)
+		return
+	}
+}
+
+func TestFrame_RoundTrip(t *testing.T) {
+	fixtures := []*frame{
+		{body: []byte{}},
+		{more: true, body: []byte("rawblock")},
+		{command: true, body: append([]byte{5}, "READY"...)},
+		{body: bytes.Repeat([]byte{0xab}, 255)},
+		{more: true, body: bytes.Repeat([]byte{0xcd}, 256)},
+	}
+
+	for _, fixture := range fixtures {
+		buf := new(bytes.Buffer)
+		if err := writeFrame(buf, fixture); err != nil {
+			t.Errorf("failed to write frame: %s", err)
+			continue
+		}
+
+		if isLong := buf.Bytes()[0]&flagLong != 0; isLong != (len(fixture.body) > 255) {
+			t.Errorf("incorrect long flag for frame of size %d", len(fixture.body))
+			continue
+		}
+
+		decoded, err := readFrame(buf)
+		if err != nil {
+			t.Errorf("failed to read frame: %s", err)
+			continue
+		} else if decoded.more != fixture.more || decoded.command != fixture.command || !bytes.Equal(decoded.body, fixture.body) {
+			t.Errorf("frame did not round trip\nWanted %+v\nGot    %+v", fixture, decoded)
+			continue
+		}
+	}
+
+	if _, err := readFrame(bytes.NewReader([]byte{0x80, 0})); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("expected ErrInvalidFrame for reserved flags, got %v", err)
+	}
+	if _, err := readFrame(bytes.NewReader([]byte{flagLong, 0xff, 0, 0, 0, 0, 0, 0, 0})); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("expected ErrInvalidFrame for oversized frame, got %v", err)
+	}
+}
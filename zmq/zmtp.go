@@ -0,0 +1,179 @@
+package zmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// This file implements the subset of ZMTP 3.0 (https://rfc.zeromq.org/spec/23/) needed
+// by a SUB socket using the NULL security mechanism.
+
+const (
+	greetingSize = 64
+
+	flagMore    byte = 0x01
+	flagLong    byte = 0x02
+	flagCommand byte = 0x04
+
+	// maxFrameSize bounds the size of frames read from the publisher. It
+	// comfortably exceeds the largest possible serialized block.
+	maxFrameSize = 32 * 1024 * 1024
+)
+
+var (
+	// ErrInvalidGreeting is returned if the publisher's ZMTP greeting is malformed,
+	// or advertises an unsupported protocol version or security mechanism.
+	ErrInvalidGreeting = errors.New("invalid ZMTP greeting")
+
+	// ErrInvalidFrame is returned if the publisher sends a malformed ZMTP frame.
+	ErrInvalidFrame = errors.New("invalid ZMTP frame")
+
+	// ErrHandshakeFailed is returned if the publisher does not complete the
+	// NULL mechanism handshake, or is not a PUB or XPUB socket.
+	ErrHandshakeFailed = errors.New("ZMTP handshake failed")
+)
+
+// greeting returns the ZMTP 3.0 greeting for the NULL mechanism. Advertising minor version 0
+// lets the publisher accept subscriptions sent as messages, rather than as the SUBSCRIBE
+// commands introduced in ZMTP 3.1.
+func greeting() []byte {
+	g := make([]byte, greetingSize)
+	g[0] = 0xff
+	g[9] = 0x7f
+	g[10] = 3 // major version
+	g[11] = 0 // minor version
+	copy(g[12:32], "NULL")
+	return g
+}
+
+func checkGreeting(g []byte) error {
+	if g[0] != 0xff || g[9]&0x01 != 0x01 {
+		return fmt.Errorf("%w: bad signature", ErrInvalidGreeting)
+	} else if g[10] < 3 {
+		return fmt.Errorf("%w: unsupported version %d.%d", ErrInvalidGreeting, g[10], g[11])
+	} else if mechanism := string(bytes.TrimRight(g[12:32], "\x00")); mechanism != "NULL" {
+		return fmt.Errorf("%w: unsupported mechanism %q", ErrInvalidGreeting, mechanism)
+	}
+	return nil
+}
+
+type frame struct {
+	more    bool
+	command bool
+	body    []byte
+}
+
+func readFrame(reader io.Reader) (*frame, error) {
+	var header [9]byte
+	if _, err := io.ReadFull(reader, header[:2]); err != nil {
+		return nil, err
+	}
+
+	flags := header[0]
+	if flags&^(flagMore|flagLong|flagCommand) != 0 {
+		return nil, fmt.Errorf("%w: reserved flags set 0x%02x", ErrInvalidFrame, flags)
+	}
+
+	size := uint64(header[1])
+	if flags&flagLong != 0 {
+		if _, err := io.ReadFull(reader, header[2:]); err != nil {
+			return nil, err
+		}
+		size = binary.BigEndian.Uint64(header[1:])
+	}
+
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("%w: frame size %d too large", ErrInvalidFrame, size)
+	}
+
+	f := &frame{
+		more:    flags&flagMore != 0,
+		command: flags&flagCommand != 0,
+		body:    make([]byte, size),
+	}
+	if _, err := io.ReadFull(reader, f.body); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func writeFrame(writer io.Writer, f *frame) error {
+	var flags byte
+	if f.more {
+		flags |= flagMore
+	}
+	if f.command {
+		flags |= flagCommand
+	}
+
+	var header []byte
+	if len(f.body) > 255 {
+		header = make([]byte, 9)
+		header[0] = flags | flagLong
+		binary.BigEndian.PutUint64(header[1:], uint64(len(f.body)))
+	} else {
+		header = []byte{flags, byte(len(f.body))}
+	}
+
+	_, err := writer.Write(append(header, f.body...))
+	return err
+}
+
+// command is a ZMTP command, such as READY or PING.
+type command struct {
+	name string
+	data []byte
+}
+
+func parseCommand(body []byte) (*command, error) {
+	if len(body) < 1 || len(body) < 1+int(body[0]) {
+		return nil, fmt.Errorf("%w: truncated command", ErrInvalidFrame)
+	}
+
+	nameLen := int(body[0])
+	return &command{name: string(body[1 : 1+nameLen]), data: body[1+nameLen:]}, nil
+}
+
+func (c *command) frame() *frame {
+	body := append([]byte{byte(len(c.name))}, c.name...)
+	return &frame{command: true, body: append(body, c.data...)}
+}
+
+// encodeMetadata encodes the properties of a READY command.
+func encodeMetadata(properties map[string]string) []byte {
+	buf := new(bytes.Buffer)
+	for name, value := range properties {
+		buf.WriteByte(byte(len(name)))
+		buf.WriteString(name)
+		binary.Write(buf, binary.BigEndian, uint32(len(value)))
+		buf.WriteString(value)
+	}
+	return buf.Bytes()
+}
+
+// decodeMetadata decodes the properties of a READY command. Property names are case-insensitive,
+// so they are returned in lower case.
+func decodeMetadata(data []byte) (map[string]string, error) {
+	properties := make(map[string]string)
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 1+nameLen+4 {
+			return nil, fmt.Errorf("%w: truncated metadata", ErrInvalidFrame)
+		}
+		name := string(bytes.ToLower(data[1 : 1+nameLen]))
+		data = data[1+nameLen:]
+
+		valueLen := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(len(data)) < uint64(valueLen) {
+			return nil, fmt.Errorf("%w: truncated metadata", ErrInvalidFrame)
+		}
+		properties[name] = string(data[:valueLen])
+		data = data[valueLen:]
+	}
+	return properties, nil
+}